@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// anthropicAPIVersion Messages API版本
+const anthropicAPIVersion = "2023-06-01"
+
+// AnthropicModel Anthropic Messages API兼容模型
+type AnthropicModel struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+// anthropicRequest Messages API请求体
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float64            `json:"temperature"`
+}
+
+// anthropicMessage 对话消息
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicResponse Messages API响应体
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewAnthropicModel 创建Anthropic兼容模型
+func NewAnthropicModel(cfg ProviderConfig) (AIModel, error) {
+	if cfg.APIKey == "" {
+		return nil, NewConfigError("Anthropic API key is empty")
+	}
+	if cfg.Model == "" {
+		return nil, NewConfigError("Anthropic model name is empty")
+	}
+
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+
+	return &AnthropicModel{
+		apiKey:    cfg.APIKey,
+		baseURL:   baseURL,
+		model:     cfg.Model,
+		maxTokens: 2000,
+		client:    createHTTPClient(timeout),
+	}, nil
+}
+
+// CallAPI 实现AIModel接口 - 调用Messages API
+func (m *AnthropicModel) CallAPI(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	reqBytes, err := json.Marshal(anthropicRequest{
+		Model:       m.model,
+		MaxTokens:   m.maxTokens,
+		System:      systemPrompt,
+		Messages:    []anthropicMessage{{Role: "user", Content: userPrompt}},
+		Temperature: 0.5,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.baseURL+"/v1/messages", bytes.NewReader(reqBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", m.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	httpResp, err := m.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", NewTimeoutError(fmt.Sprintf("context error: %v", ctx.Err()))
+		}
+		return "", NewAPIError(fmt.Sprintf("HTTP request failed: %v", err), 0)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return "", NewAPIError(fmt.Sprintf("failed to read response body: %v", err), httpResp.StatusCode)
+	}
+
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", NewAPIError(fmt.Sprintf("failed to parse response: %v", err), httpResp.StatusCode)
+	}
+	if resp.Error != nil {
+		return "", NewAPIError(resp.Error.Message, httpResp.StatusCode)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return "", NewAPIError(fmt.Sprintf("API returned status %d", httpResp.StatusCode), httpResp.StatusCode)
+	}
+
+	var sb strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", NewAPIError("no text content in response", http.StatusOK)
+	}
+	return sb.String(), nil
+}
+
+// GetModelInfo 实现AIModel接口 - 获取模型信息
+func (m *AnthropicModel) GetModelInfo() ModelInfo {
+	return ModelInfo{
+		Name:      m.model,
+		Provider:  "anthropic",
+		Version:   anthropicAPIVersion,
+		MaxTokens: m.maxTokens,
+		LoadedAt:  time.Now(),
+	}
+}
+
+// Health 实现AIModel接口 - 健康检查（只检查配置，不消耗额度）
+func (m *AnthropicModel) Health(ctx context.Context) error {
+	if m.apiKey == "" || m.baseURL == "" {
+		return NewConfigError("anthropic is not configured")
+	}
+	return nil
+}
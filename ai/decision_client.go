@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"nofx/mcp"
+)
+
+// DecisionClient 将AIModel适配为决策引擎使用的AI客户端
+// 实现 decision.AIClient 与 decision.ToolCallingAIClient
+type DecisionClient struct {
+	model AIModel
+}
+
+// NewDecisionClient 创建决策客户端
+func NewDecisionClient(model AIModel) *DecisionClient {
+	return &DecisionClient{model: model}
+}
+
+// Model 底层模型
+func (c *DecisionClient) Model() AIModel {
+	return c.model
+}
+
+// CallWithMessages 使用系统提示词和用户提示词调用模型
+func (c *DecisionClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return c.model.CallAPI(context.Background(), systemPrompt, userPrompt)
+}
+
+// SupportsToolCalling 底层模型是否支持tool calling
+func (c *DecisionClient) SupportsToolCalling() bool {
+	tc, ok := c.model.(ToolCallingModel)
+	return ok && tc.SupportsToolCalling()
+}
+
+// CallWithTools 使用tool calling调用底层模型
+func (c *DecisionClient) CallWithTools(systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error) {
+	tc, ok := c.model.(ToolCallingModel)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持tool calling", c.model.GetModelInfo().Provider)
+	}
+	return tc.CallWithTools(context.Background(), systemPrompt, userPrompt, tools)
+}
@@ -55,10 +55,17 @@ func (f *AIModelFactory) CreateUnderstandingModel(modelName string) (AIModel, er
 // primaryModel: 主模型名称
 // fallbackModel: 备用模型名称（如果主模型创建失败）
 // 返回值: 创建成功的模型实例或错误（两个都失败时返回错误）
+// 两个模型都创建成功时返回FailoverModel，主模型调用超时或失败时自动切换到备用模型
 func (f *AIModelFactory) CreateWithFallback(primaryModel, fallbackModel string) (AIModel, error) {
 	// 尝试创建主模型
 	model, err := f.CreateUnderstandingModel(primaryModel)
 	if err == nil {
+		if fallbackModel == "" || fallbackModel == primaryModel {
+			return model, nil
+		}
+		if fallback, fbErr := f.CreateUnderstandingModel(fallbackModel); fbErr == nil {
+			return NewFailoverModel(model, fallback), nil
+		}
 		return model, nil
 	}
 
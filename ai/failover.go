@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"nofx/mcp"
+)
+
+// ToolCallingModel 支持tool calling的模型
+type ToolCallingModel interface {
+	SupportsToolCalling() bool
+	CallWithTools(ctx context.Context, systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error)
+}
+
+// FailoverModel 按顺序调用多个模型，主模型超时或失败时自动切换到备用模型
+type FailoverModel struct {
+	models []AIModel
+
+	// AttemptTimeout 单个模型的最长调用时间（为0时只受调用方ctx限制）
+	AttemptTimeout time.Duration
+}
+
+// NewFailoverModel 创建故障转移模型（第一个为主模型）
+func NewFailoverModel(models ...AIModel) *FailoverModel {
+	return &FailoverModel{
+		models:         models,
+		AttemptTimeout: 120 * time.Second,
+	}
+}
+
+// Models 参与故障转移的模型列表
+func (f *FailoverModel) Models() []AIModel {
+	return f.models
+}
+
+// CallAPI 实现AIModel接口 - 依次尝试各模型直到成功
+func (f *FailoverModel) CallAPI(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	var errs []string
+
+	for i, model := range f.models {
+		if ctx.Err() != nil {
+			break
+		}
+
+		attemptCtx, cancel := f.attemptContext(ctx)
+		response, err := model.CallAPI(attemptCtx, systemPrompt, userPrompt)
+		cancel()
+		if err == nil {
+			if i > 0 {
+				log.Printf("✓ 备用模型 %s 调用成功", model.GetModelInfo().Provider)
+			}
+			return response, nil
+		}
+
+		info := model.GetModelInfo()
+		errs = append(errs, fmt.Sprintf("%s/%s: %v", info.Provider, info.Name, err))
+		if i < len(f.models)-1 {
+			log.Printf("⚠️ 主模型调用失败，切换到备用模型 (%s/%s): %v", info.Provider, info.Name, err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err().Error())
+	}
+	return "", NewAPIError(fmt.Sprintf("all models failed: %s", strings.Join(errs, "; ")), 0)
+}
+
+// attemptContext 单个模型调用的ctx（受AttemptTimeout限制）
+func (f *FailoverModel) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.AttemptTimeout > 0 {
+		return context.WithTimeout(ctx, f.AttemptTimeout)
+	}
+	return ctx, func() {}
+}
+
+// GetModelInfo 实现AIModel接口 - 返回主模型信息
+func (f *FailoverModel) GetModelInfo() ModelInfo {
+	if len(f.models) == 0 {
+		return ModelInfo{Provider: "failover", LoadedAt: time.Now()}
+	}
+	return f.models[0].GetModelInfo()
+}
+
+// Health 实现AIModel接口 - 任一模型健康即可用
+func (f *FailoverModel) Health(ctx context.Context) error {
+	var lastErr error = NewConfigError("no models configured")
+	for _, model := range f.models {
+		if err := model.Health(ctx); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// SupportsToolCalling 任一模型支持tool calling即可
+func (f *FailoverModel) SupportsToolCalling() bool {
+	for _, model := range f.models {
+		if tc, ok := model.(ToolCallingModel); ok && tc.SupportsToolCalling() {
+			return true
+		}
+	}
+	return false
+}
+
+// CallWithTools 依次使用支持tool calling的模型调用（每个模型同样受AttemptTimeout限制）
+func (f *FailoverModel) CallWithTools(ctx context.Context, systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error) {
+	var lastErr error = fmt.Errorf("no model supports tool calling")
+	for _, model := range f.models {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		tc, ok := model.(ToolCallingModel)
+		if !ok || !tc.SupportsToolCalling() {
+			continue
+		}
+		attemptCtx, cancel := f.attemptContext(ctx)
+		resp, err := tc.CallWithTools(attemptCtx, systemPrompt, userPrompt, tools)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		log.Printf("⚠️ %s tool calling失败，尝试下一个模型: %v", model.GetModelInfo().Provider, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nofx/mcp"
+)
+
+// OpenAICompatibleModel OpenAI兼容接口的模型实现（DeepSeek、Qwen、OpenAI、本地推理服务等）
+// 底层复用 mcp.Client 的请求、重试和tool calling逻辑
+type OpenAICompatibleModel struct {
+	provider string
+	client   *mcp.Client
+}
+
+// NewOpenAICompatibleModel 创建OpenAI兼容模型
+func NewOpenAICompatibleModel(cfg ProviderConfig) (AIModel, error) {
+	provider := strings.ToLower(cfg.Provider)
+	client := mcp.New()
+
+	switch provider {
+	case "deepseek":
+		if cfg.APIKey == "" {
+			return nil, NewConfigError("DeepSeek API key is empty")
+		}
+		client.SetDeepSeekAPIKey(cfg.APIKey, cfg.BaseURL, cfg.Model)
+	case "qwen":
+		if cfg.APIKey == "" {
+			return nil, NewConfigError("Qwen API key is empty")
+		}
+		client.SetQwenAPIKey(cfg.APIKey, cfg.BaseURL, cfg.Model)
+	case "openai":
+		if cfg.APIKey == "" {
+			return nil, NewConfigError("OpenAI API key is empty")
+		}
+		baseURL, model := cfg.BaseURL, cfg.Model
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		if model == "" {
+			model = "gpt-4o-mini"
+		}
+		client.SetCustomAPI(baseURL, cfg.APIKey, model)
+		client.ToolCalling = "on"
+	case "local":
+		// 本地OpenAI兼容服务（Ollama、vLLM、LM Studio等）通常不校验密钥
+		baseURL, apiKey := cfg.BaseURL, cfg.APIKey
+		if baseURL == "" {
+			baseURL = "http://localhost:11434/v1"
+		}
+		if apiKey == "" {
+			apiKey = "local"
+		}
+		if cfg.Model == "" {
+			return nil, NewConfigError("local model name is empty")
+		}
+		client.SetCustomAPI(baseURL, apiKey, cfg.Model)
+	default:
+		if cfg.APIKey == "" || cfg.BaseURL == "" || cfg.Model == "" {
+			return nil, NewConfigError("custom API requires url, key and model name")
+		}
+		client.SetCustomAPI(cfg.BaseURL, cfg.APIKey, cfg.Model)
+	}
+
+	if cfg.Timeout > 0 {
+		client.Timeout = cfg.Timeout
+	}
+	if cfg.ToolCalling != "" {
+		client.ToolCalling = cfg.ToolCalling
+	}
+
+	return &OpenAICompatibleModel{provider: provider, client: client}, nil
+}
+
+// CallAPI 实现AIModel接口 - 调用模型（ctx取消或超时时中断HTTP请求和重试）
+func (m *OpenAICompatibleModel) CallAPI(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	content, err := m.client.CallWithMessagesContext(ctx, systemPrompt, userPrompt)
+	if err != nil && ctx.Err() != nil {
+		return "", NewTimeoutError(fmt.Sprintf("%s call aborted: %v", m.provider, ctx.Err()))
+	}
+	return content, err
+}
+
+// GetModelInfo 实现AIModel接口 - 获取模型信息
+func (m *OpenAICompatibleModel) GetModelInfo() ModelInfo {
+	return ModelInfo{
+		Name:      m.client.Model,
+		Provider:  m.provider,
+		Version:   "v1",
+		MaxTokens: 2000,
+		LoadedAt:  time.Now(),
+	}
+}
+
+// Health 实现AIModel接口 - 健康检查（只检查配置，不消耗额度）
+func (m *OpenAICompatibleModel) Health(ctx context.Context) error {
+	if m.client.APIKey == "" || m.client.BaseURL == "" {
+		return NewConfigError(fmt.Sprintf("%s is not configured", m.provider))
+	}
+	return nil
+}
+
+// SupportsToolCalling 是否支持tool calling
+func (m *OpenAICompatibleModel) SupportsToolCalling() bool {
+	return m.client.SupportsToolCalling()
+}
+
+// CallWithTools 使用tool calling调用模型（ctx取消或超时时中断HTTP请求和重试）
+func (m *OpenAICompatibleModel) CallWithTools(ctx context.Context, systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error) {
+	resp, err := m.client.CallWithToolsContext(ctx, systemPrompt, userPrompt, tools)
+	if err != nil && ctx.Err() != nil {
+		return nil, NewTimeoutError(fmt.Sprintf("%s tool call aborted: %v", m.provider, ctx.Err()))
+	}
+	return resp, err
+}
+
+// Client 底层mcp客户端
+func (m *OpenAICompatibleModel) Client() *mcp.Client {
+	return m.client
+}
@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+)
+
+// ProviderConfig 创建交易模型所需的提供商配置
+type ProviderConfig struct {
+	Provider    string        // 提供商: deepseek, qwen, openai, anthropic, gemini, local, custom, mock
+	APIKey      string        // API密钥（local可为空）
+	BaseURL     string        // API地址（为空时使用提供商默认地址）
+	Model       string        // 模型名称（为空时使用提供商默认模型）
+	Timeout     time.Duration // 单次请求超时（为空时使用提供商默认值）
+	ToolCalling string        // 结构化输出模式: on/off，为空时按提供商自动判断
+}
+
+// ProviderBuilder 根据配置创建模型实例
+type ProviderBuilder func(cfg ProviderConfig) (AIModel, error)
+
+// ProviderRegistry AI提供商注册表
+// 交易循环通过注册表创建模型，新增提供商只需注册一个ProviderBuilder
+type ProviderRegistry struct {
+	mu       sync.RWMutex
+	builders map[string]ProviderBuilder
+}
+
+// NewProviderRegistry 创建空注册表
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{builders: make(map[string]ProviderBuilder)}
+}
+
+// DefaultRegistry 内置所有提供商的默认注册表
+var DefaultRegistry = newDefaultRegistry()
+
+// newDefaultRegistry 注册内置提供商
+func newDefaultRegistry() *ProviderRegistry {
+	r := NewProviderRegistry()
+	for _, name := range []string{"deepseek", "qwen", "openai", "local", "custom"} {
+		r.Register(name, NewOpenAICompatibleModel)
+	}
+	r.Register("anthropic", NewAnthropicModel)
+	r.Register("gemini", newGeminiFromProvider)
+	r.Register("mock", func(cfg ProviderConfig) (AIModel, error) {
+		return NewMockAIModel(), nil
+	})
+	return r
+}
+
+// Register 注册提供商（同名覆盖）
+func (r *ProviderRegistry) Register(name string, builder ProviderBuilder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.builders[strings.ToLower(name)] = builder
+}
+
+// Providers 已注册的提供商列表
+func (r *ProviderRegistry) Providers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.builders))
+	for name := range r.builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsSupported 检查提供商是否已注册
+func (r *ProviderRegistry) IsSupported(provider string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.builders[strings.ToLower(provider)]
+	return ok
+}
+
+// Create 创建模型实例
+func (r *ProviderRegistry) Create(cfg ProviderConfig) (AIModel, error) {
+	r.mu.RLock()
+	builder, ok := r.builders[strings.ToLower(cfg.Provider)]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, NewConfigError(fmt.Sprintf("unsupported AI provider: %s", cfg.Provider))
+	}
+	return builder(cfg)
+}
+
+// CreateWithFallback 创建带故障转移的模型
+// 主模型创建失败时直接使用备用模型；都创建成功时返回FailoverModel，调用超时或失败时自动切换到备用模型
+func (r *ProviderRegistry) CreateWithFallback(primary ProviderConfig, fallbacks ...ProviderConfig) (AIModel, error) {
+	var models []AIModel
+	var errs []string
+
+	for _, cfg := range append([]ProviderConfig{primary}, fallbacks...) {
+		if cfg.Provider == "" {
+			continue
+		}
+		model, err := r.Create(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", cfg.Provider, err))
+			continue
+		}
+		models = append(models, model)
+	}
+
+	switch len(models) {
+	case 0:
+		return nil, fmt.Errorf("failed to create model: %s", strings.Join(errs, "; "))
+	case 1:
+		return models[0], nil
+	default:
+		return NewFailoverModel(models...), nil
+	}
+}
+
+// newGeminiFromProvider 由通用提供商配置创建Gemini模型
+func newGeminiFromProvider(cfg ProviderConfig) (AIModel, error) {
+	geminiCfg := &config.GeminiConfig{
+		Enabled:        true,
+		APIKey:         cfg.APIKey,
+		APIURL:         cfg.BaseURL,
+		APIVersion:     "v1beta",
+		Model:          cfg.Model,
+		Temperature:    0.5,
+		MaxTokens:      2000,
+		TopP:           0.95,
+		TopK:           40,
+		TimeoutSeconds: 120,
+	}
+	if geminiCfg.APIURL == "" {
+		geminiCfg.APIURL = "https://generativelanguage.googleapis.com"
+	}
+	if geminiCfg.Model == "" {
+		geminiCfg.Model = "gemini-2.0-flash"
+	}
+	if cfg.Timeout > 0 {
+		geminiCfg.TimeoutSeconds = int(cfg.Timeout.Seconds())
+	}
+	return NewGeminiModel(geminiCfg)
+}
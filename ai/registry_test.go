@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nofx/mcp"
+)
+
+// TestProviderRegistryCreate 测试注册表创建各提供商模型
+func TestProviderRegistryCreate(t *testing.T) {
+	for _, name := range []string{"deepseek", "qwen", "openai", "anthropic", "gemini", "local", "custom"} {
+		if !DefaultRegistry.IsSupported(name) {
+			t.Errorf("默认注册表应支持 %s", name)
+		}
+	}
+
+	if _, err := DefaultRegistry.Create(ProviderConfig{Provider: "unknown"}); err == nil {
+		t.Error("未注册的提供商应返回错误")
+	}
+	if _, err := DefaultRegistry.Create(ProviderConfig{Provider: "openai"}); err == nil {
+		t.Error("缺少API密钥应返回错误")
+	}
+
+	model, err := DefaultRegistry.Create(ProviderConfig{Provider: "local", Model: "llama3"})
+	if err != nil {
+		t.Fatalf("创建本地模型失败: %v", err)
+	}
+	if info := model.GetModelInfo(); info.Provider != "local" || info.Name != "llama3" {
+		t.Errorf("模型信息错误: %+v", info)
+	}
+}
+
+// TestCreateWithFallbackFailsOverOnTimeout 测试主模型超时后切换到备用模型
+func TestCreateWithFallbackFailsOverOnTimeout(t *testing.T) {
+	slow := NewMockAIModel().SetLatency(500).SetResponse("primary")
+	fast := NewMockAIModel().SetLatency(0).SetResponse("fallback")
+
+	registry := NewProviderRegistry()
+	registry.Register("slow", func(cfg ProviderConfig) (AIModel, error) { return slow, nil })
+	registry.Register("fast", func(cfg ProviderConfig) (AIModel, error) { return fast, nil })
+
+	model, err := registry.CreateWithFallback(ProviderConfig{Provider: "slow"}, ProviderConfig{Provider: "fast"})
+	if err != nil {
+		t.Fatalf("创建失败: %v", err)
+	}
+	failover, ok := model.(*FailoverModel)
+	if !ok {
+		t.Fatalf("应返回FailoverModel, got %T", model)
+	}
+	failover.AttemptTimeout = 50 * time.Millisecond
+
+	response, err := NewDecisionClient(failover).CallWithMessages("system", "user")
+	if err != nil {
+		t.Fatalf("故障转移调用失败: %v", err)
+	}
+	if response != "fallback" || fast.CallCount != 1 {
+		t.Errorf("应由备用模型返回结果, got %q", response)
+	}
+
+	// 主模型创建失败时直接使用备用模型
+	model, err = registry.CreateWithFallback(ProviderConfig{Provider: "missing"}, ProviderConfig{Provider: "fast"})
+	if err != nil || model != fast {
+		t.Errorf("主模型不可用时应直接返回备用模型: %v", err)
+	}
+}
+
+// toolMockModel 支持tool calling的模拟模型（复用MockAIModel的延迟和响应）
+type toolMockModel struct {
+	*MockAIModel
+}
+
+func (m toolMockModel) SupportsToolCalling() bool { return true }
+
+func (m toolMockModel) CallWithTools(ctx context.Context, systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error) {
+	content, err := m.CallAPI(ctx, systemPrompt, userPrompt)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.StructuredResponse{Content: content}, nil
+}
+
+func TestFailoverCallWithToolsAttemptTimeout(t *testing.T) {
+	slow := toolMockModel{NewMockAIModel().SetLatency(5000).SetResponse("primary")}
+	fast := toolMockModel{NewMockAIModel().SetLatency(0).SetResponse("fallback")}
+	failover := NewFailoverModel(slow, fast)
+	failover.AttemptTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	resp, err := NewDecisionClient(failover).CallWithTools("system", "user", nil)
+	if err != nil {
+		t.Fatalf("故障转移调用失败: %v", err)
+	}
+	if resp.Content != "fallback" {
+		t.Errorf("主模型超时后应由备用模型返回结果, got %q", resp.Content)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("单个模型应受AttemptTimeout限制，实际耗时 %v", elapsed)
+	}
+}
+
+// TestAnthropicModelCallAPI 测试Anthropic Messages API调用
+func TestAnthropicModelCallAPI(t *testing.T) {
+	var request anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"bad request"}}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"content":[{"type":"text","text":"观望"}]}`))
+	}))
+	defer server.Close()
+
+	model, err := DefaultRegistry.Create(ProviderConfig{Provider: "anthropic", APIKey: "test-key", BaseURL: server.URL, Model: "test-model"})
+	if err != nil {
+		t.Fatalf("创建模型失败: %v", err)
+	}
+
+	response, err := model.CallAPI(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if response != "观望" {
+		t.Errorf("响应解析错误: %q", response)
+	}
+	if request.System != "system" || len(request.Messages) != 1 || request.Messages[0].Content != "user" {
+		t.Errorf("请求体错误: %+v", request)
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"nofx/database"
+	"nofx/decision"
+	"nofx/service/experiment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListExperiments 获取当前用户的A/B实验列表（可按status过滤）
+func (s *Server) handleListExperiments(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	experiments, err := s.experimentService.List(userID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取实验列表失败: %v", err)})
+		return
+	}
+	if experiments == nil {
+		experiments = []database.Experiment{}
+	}
+	c.JSON(http.StatusOK, gin.H{"experiments": experiments, "total": len(experiments)})
+}
+
+// handleCreateExperiment 创建A/B实验（交易员、AI模型和prompt模板必须属于当前用户/存在）
+func (s *Server) handleCreateExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req struct {
+		Name           string                       `json:"name" binding:"required"`
+		Description    string                       `json:"description"`
+		Assignment     string                       `json:"assignment"` // trader / cycle
+		TraderIDs      []string                     `json:"trader_ids" binding:"required"`
+		Variants       []database.ExperimentVariant `json:"variants" binding:"required"`
+		MaxDrawdownPct float64                      `json:"max_drawdown_pct"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, traderID := range req.TraderIDs {
+		if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("交易员不存在: %s", traderID)})
+			return
+		}
+	}
+
+	models, err := s.database.GetAIModels(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取AI模型配置失败: %v", err)})
+		return
+	}
+	enabled := make(map[string]bool, len(models))
+	for _, m := range models {
+		enabled[m.ID] = m.Enabled
+	}
+	for _, v := range req.Variants {
+		if v.AIModelID != "" && !enabled[v.AIModelID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s 的AI模型 %s 未配置或未启用", v.Name, v.AIModelID)})
+			return
+		}
+		if v.PromptTemplate != "" {
+			if _, err := decision.GetPromptTemplate(v.PromptTemplate); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("变体 %s 的模板不存在: %s", v.Name, v.PromptTemplate)})
+				return
+			}
+		}
+	}
+
+	exp, err := s.experimentService.Create(database.Experiment{
+		UserID:         userID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Assignment:     req.Assignment,
+		TraderIDs:      req.TraderIDs,
+		Variants:       req.Variants,
+		MaxDrawdownPct: req.MaxDrawdownPct,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, exp)
+}
+
+// handleGetExperiment 获取实验及各变体的胜率、夏普比、回撤和显著性检验结果
+func (s *Server) handleGetExperiment(c *gin.Context) {
+	if _, ok := s.ownedExperiment(c); !ok {
+		return
+	}
+
+	results, err := s.experimentService.Results(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("计算实验结果失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// handleStopExperiment 手动停止实验（交易员下个周期恢复自身配置）
+func (s *Server) handleStopExperiment(c *gin.Context) {
+	if _, ok := s.ownedExperiment(c); !ok {
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	if err := s.experimentService.Stop(c.Param("id"), req.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "实验已停止"})
+}
+
+// ownedExperiment 获取当前用户的实验（不存在或不属于当前用户时返回404）
+func (s *Server) ownedExperiment(c *gin.Context) (*database.Experiment, bool) {
+	exp, err := s.experimentService.Get(c.Param("id"))
+	if errors.Is(err, experiment.ErrNotFound) || (err == nil && exp.UserID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "实验不存在"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return exp, true
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"nofx/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListFeatureFlags 获取所有功能标志（按名称排序）
+func (s *Server) handleListFeatureFlags(c *gin.Context) {
+	flags := config.GetGlobalFeatureFlags().ListAllFlags()
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	c.JSON(http.StatusOK, gin.H{"flags": flags, "total": len(flags)})
+}
+
+// handleGetFeatureFlag 获取功能标志详情
+func (s *Server) handleGetFeatureFlag(c *gin.Context) {
+	flag, err := config.GetGlobalFeatureFlags().GetFlag(config.FeatureFlagType(c.Param("name")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// handleUpdateFeatureFlag 创建或更新功能标志（未提供的字段保持原值），修改写入审计日志
+func (s *Server) handleUpdateFeatureFlag(c *gin.Context) {
+	var req struct {
+		Description *string                 `json:"description"`
+		Enabled     *bool                   `json:"enabled"`
+		Percentage  *int                    `json:"percentage"`
+		AllowUsers  *[]string               `json:"allow_users"`
+		DenyUsers   *[]string               `json:"deny_users"`
+		Bucketing   *string                 `json:"bucketing"`
+		Metadata    *map[string]interface{} `json:"metadata"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	flags := config.GetGlobalFeatureFlags()
+	name := config.FeatureFlagType(c.Param("name"))
+	before, _ := flags.GetFlag(name)
+
+	flag := config.FeatureFlag{Name: name}
+	if before != nil {
+		flag = *before
+	}
+	if req.Description != nil {
+		flag.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		flag.Enabled = *req.Enabled
+	}
+	if req.Percentage != nil {
+		flag.Percentage = *req.Percentage
+	}
+	if req.AllowUsers != nil {
+		flag.AllowUsers = *req.AllowUsers
+	}
+	if req.DenyUsers != nil {
+		flag.DenyUsers = *req.DenyUsers
+	}
+	if req.Bucketing != nil {
+		if before != nil && *req.Bucketing != before.Bucketing {
+			log.Printf("🚩 功能标志 %s 灰度分桶方式 %q → %q，部分用户将进出灰度范围", name, before.Bucketing, *req.Bucketing)
+		}
+		flag.Bucketing = *req.Bucketing
+	}
+	if req.Metadata != nil {
+		flag.Metadata = *req.Metadata
+	}
+	flag.UpdatedBy = c.GetString("user_id")
+
+	saved, err := flags.SaveFlag(flag)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	action := "FEATURE_FLAG_UPDATE"
+	if before == nil {
+		action = "FEATURE_FLAG_CREATE"
+	}
+	s.auditFeatureFlag(c, action, before, saved)
+	log.Printf("🚩 管理员 %s 更新功能标志 %s: enabled=%v, percentage=%d%%", flag.UpdatedBy, name, saved.Enabled, saved.Percentage)
+	c.JSON(http.StatusOK, saved)
+}
+
+// handleDeleteFeatureFlag 删除自定义功能标志（内置标志只能禁用）
+func (s *Server) handleDeleteFeatureFlag(c *gin.Context) {
+	flags := config.GetGlobalFeatureFlags()
+	name := config.FeatureFlagType(c.Param("name"))
+
+	before, err := flags.GetFlag(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := flags.DeleteFlag(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.auditFeatureFlag(c, "FEATURE_FLAG_DELETE", before, nil)
+	log.Printf("🚩 管理员 %s 删除功能标志 %s", c.GetString("user_id"), name)
+	c.JSON(http.StatusOK, gin.H{"message": "功能标志已删除"})
+}
+
+// auditFeatureFlag 记录功能标志修改前后的审计日志（失败只记录日志）
+func (s *Server) auditFeatureFlag(c *gin.Context, action string, before, after *config.FeatureFlag) {
+	details, err := json.Marshal(gin.H{"before": before, "after": after})
+	if err != nil {
+		details = []byte(fmt.Sprintf("序列化功能标志失败: %v", err))
+	}
+	adminID := c.GetString("user_id")
+	if err := s.database.CreateAuditLog(&adminID, action, c.ClientIP(), c.Request.UserAgent(), true, string(details)); err != nil {
+		log.Printf("⚠️ 记录审计日志失败: %v", err)
+	}
+}
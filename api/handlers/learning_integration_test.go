@@ -35,6 +35,12 @@ func setupLearningTest(t *testing.T) (*LearningHandler, *gin.Engine, *config.Dat
 			leverage INTEGER,
 			holding_time_seconds INTEGER,
 			margin_mode TEXT,
+			side TEXT DEFAULT '',
+			quantity REAL DEFAULT 0,
+			realized_pnl REAL DEFAULT 0,
+			fees REAL DEFAULT 0,
+			funding REAL DEFAULT 0,
+			close_reason TEXT DEFAULT '',
 			created_at TIMESTAMP
 		);
 	`)
@@ -111,6 +111,9 @@ func (h *BaseHandler) HandleEquityHistory(c *gin.Context) {
 		AvailableBalance float64 `json:"available_balance"` // 可用余额
 		TotalPnL         float64 `json:"total_pnl"`         // 总盈亏（相对初始余额）
 		TotalPnLPct      float64 `json:"total_pnl_pct"`     // 总盈亏百分比
+		GrossPnL         float64 `json:"gross_pnl"`         // 毛盈亏（净盈亏加回手续费和资金费）
+		TotalFees        float64 `json:"total_fees"`        // 累计手续费
+		TotalFunding     float64 `json:"total_funding"`     // 累计资金费（正数为支付）
 		PositionCount    int     `json:"position_count"`    // 持仓数量
 		MarginUsedPct    float64 `json:"margin_used_pct"`   // 保证金使用率
 		CycleNumber      int     `json:"cycle_number"`
@@ -157,6 +160,9 @@ func (h *BaseHandler) HandleEquityHistory(c *gin.Context) {
 			AvailableBalance: record.AccountState.AvailableBalance,
 			TotalPnL:         totalPnL,
 			TotalPnLPct:      totalPnLPct,
+			GrossPnL:         totalPnL + record.AccountState.TotalFees + record.AccountState.TotalFunding,
+			TotalFees:        record.AccountState.TotalFees,
+			TotalFunding:     record.AccountState.TotalFunding,
 			PositionCount:    record.AccountState.PositionCount,
 			MarginUsedPct:    record.AccountState.MarginUsedPct,
 			CycleNumber:      record.CycleNumber,
@@ -245,17 +251,33 @@ func (h *BaseHandler) getEquityHistoryForTraders(traderIDs []string) map[string]
 			continue
 		}
 
+		// 从AutoTrader获取初始余额（与单个交易员的收益曲线口径一致）
+		initialBalance := 0.0
+		if status := trader.GetStatus(); status != nil {
+			if ib, ok := status["initial_balance"].(float64); ok && ib > 0 {
+				initialBalance = ib
+			}
+		}
+
 		// 构建收益率历史数据
 		history := make([]map[string]interface{}, 0, len(records))
 		for _, record := range records {
-			// 计算总权益（余额+未实现盈亏）
-			totalEquity := record.AccountState.TotalBalance + record.AccountState.TotalUnrealizedProfit
+			// TotalBalance字段实际存储的是TotalEquity
+			totalEquity := record.AccountState.TotalBalance
+			// TotalUnrealizedProfit字段实际存储的是TotalPnL（相对初始余额），拿不到初始余额时直接使用
+			totalPnL := record.AccountState.TotalUnrealizedProfit
+			if initialBalance > 0 {
+				totalPnL = totalEquity - initialBalance
+			}
 
 			history = append(history, map[string]interface{}{
-				"timestamp":    record.Timestamp,
-				"total_equity": totalEquity,
-				"total_pnl":    record.AccountState.TotalUnrealizedProfit,
-				"balance":      record.AccountState.TotalBalance,
+				"timestamp":     record.Timestamp,
+				"total_equity":  totalEquity,
+				"total_pnl":     totalPnL,
+				"gross_pnl":     totalPnL + record.AccountState.TotalFees + record.AccountState.TotalFunding,
+				"total_fees":    record.AccountState.TotalFees,
+				"total_funding": record.AccountState.TotalFunding,
+				"balance":       record.AccountState.TotalBalance,
 			})
 		}
 
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"nofx/database"
+	"nofx/service/news"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxUserNewsSources = 20      // 每个用户最多添加的新闻源数量
+	maxWebhookBodySize = 1 << 20 // Webhook请求体大小上限
+)
+
+// newsSourceRequest 创建/更新新闻源请求（更新时未提供的字段保持原值）
+type newsSourceRequest struct {
+	Name     *string                    `json:"name"`
+	Type     string                     `json:"type"` // 仅创建时有效
+	URL      *string                    `json:"url"`
+	Category *string                    `json:"category"`
+	Headers  *map[string]string         `json:"headers"`
+	Mapping  *database.NewsFieldMapping `json:"mapping"`
+	TopicID  *int                       `json:"topic_id"` // 仅系统新闻源有效
+	Enabled  *bool                      `json:"enabled"`
+}
+
+// apply 把请求字段写入新闻源
+func (req *newsSourceRequest) apply(src *database.NewsSource) {
+	if req.Name != nil {
+		src.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.URL != nil {
+		src.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Category != nil {
+		src.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.Headers != nil {
+		src.Headers = *req.Headers
+	}
+	if req.Mapping != nil {
+		src.Mapping = *req.Mapping
+	}
+	if req.TopicID != nil && src.UserID == "" {
+		src.TopicID = *req.TopicID
+	}
+	if req.Enabled != nil {
+		src.Enabled = *req.Enabled
+	}
+}
+
+// handleListNewsSources 获取当前用户的新闻源和系统新闻源（系统新闻源隐藏请求头和密钥）
+func (s *Server) handleListNewsSources(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sources, err := s.database.GetNewsSources(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取新闻源失败: %v", err)})
+		return
+	}
+	for i := range sources {
+		if sources[i].UserID != userID {
+			sources[i].Headers = nil
+			sources[i].WebhookSecret = ""
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources, "types": news.DefaultRegistry.Types()})
+}
+
+// handleCreateNewsSource 用户添加新闻源（文章只触发该用户的交易员，不推送到Telegram频道）
+func (s *Server) handleCreateNewsSource(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sources, err := s.database.GetNewsSources(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取新闻源失败: %v", err)})
+		return
+	}
+	owned := 0
+	for _, src := range sources {
+		if src.UserID == userID {
+			owned++
+		}
+	}
+	if owned >= maxUserNewsSources {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("每个用户最多添加 %d 个新闻源", maxUserNewsSources)})
+		return
+	}
+	s.createNewsSource(c, userID)
+}
+
+// handleUpdateNewsSource 更新用户自己的新闻源
+func (s *Server) handleUpdateNewsSource(c *gin.Context) {
+	s.updateNewsSource(c, c.GetString("user_id"))
+}
+
+// handleDeleteNewsSource 删除用户自己的新闻源
+func (s *Server) handleDeleteNewsSource(c *gin.Context) {
+	s.deleteNewsSource(c, c.GetString("user_id"))
+}
+
+// handleAdminListNewsSources 管理员获取全部新闻源
+func (s *Server) handleAdminListNewsSources(c *gin.Context) {
+	sources, err := s.database.GetNewsSources("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取新闻源失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sources": sources, "types": news.DefaultRegistry.Types()})
+}
+
+// handleAdminCreateNewsSource 管理员添加系统新闻源（推送到Telegram频道并触发所有交易员）
+func (s *Server) handleAdminCreateNewsSource(c *gin.Context) {
+	s.createNewsSource(c, "")
+}
+
+// handleAdminUpdateNewsSource 管理员更新任意新闻源
+func (s *Server) handleAdminUpdateNewsSource(c *gin.Context) {
+	s.updateNewsSource(c, "")
+}
+
+// handleAdminDeleteNewsSource 管理员删除任意新闻源
+func (s *Server) handleAdminDeleteNewsSource(c *gin.Context) {
+	s.deleteNewsSource(c, "")
+}
+
+// createNewsSource 校验并保存新闻源（ownerID为空表示系统新闻源）
+func (s *Server) createNewsSource(c *gin.Context, ownerID string) {
+	var req newsSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	src := database.NewsSource{
+		ID:        uuid.New().String(),
+		UserID:    ownerID,
+		Type:      strings.ToLower(strings.TrimSpace(req.Type)),
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	req.apply(&src)
+	if err := news.ValidateSource(src); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if src.Type == news.SourceTypeWebhook {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		src.WebhookSecret = secret
+	}
+
+	if err := s.database.CreateNewsSource(src); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存新闻源失败: %v", err)})
+		return
+	}
+	log.Printf("📰 用户 %s 添加新闻源 %s (%s)", c.GetString("user_id"), src.Name, src.Type)
+	c.JSON(http.StatusCreated, src)
+}
+
+// updateNewsSource 更新新闻源（ownerID不为空时只能更新该用户的新闻源）
+func (s *Server) updateNewsSource(c *gin.Context, ownerID string) {
+	src, ok := s.loadNewsSource(c, ownerID)
+	if !ok {
+		return
+	}
+	var req newsSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(src)
+	src.UpdatedAt = time.Now()
+	if err := news.ValidateSource(*src); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.database.UpdateNewsSource(*src); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新新闻源失败: %v", err)})
+		return
+	}
+	log.Printf("📰 用户 %s 更新新闻源 %s", c.GetString("user_id"), src.Name)
+	c.JSON(http.StatusOK, src)
+}
+
+// deleteNewsSource 删除新闻源（ownerID不为空时只能删除该用户的新闻源）
+func (s *Server) deleteNewsSource(c *gin.Context, ownerID string) {
+	src, ok := s.loadNewsSource(c, ownerID)
+	if !ok {
+		return
+	}
+	if err := s.database.DeleteNewsSource(src.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除新闻源失败: %v", err)})
+		return
+	}
+	log.Printf("📰 用户 %s 删除新闻源 %s", c.GetString("user_id"), src.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "新闻源已删除"})
+}
+
+// loadNewsSource 按路径参数获取新闻源并检查所属用户（不属于该用户时按不存在处理）
+func (s *Server) loadNewsSource(c *gin.Context, ownerID string) (*database.NewsSource, bool) {
+	src, err := s.database.GetNewsSource(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "新闻源不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取新闻源失败: %v", err)})
+		}
+		return nil, false
+	}
+	if ownerID != "" && src.UserID != ownerID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "新闻源不存在"})
+		return nil, false
+	}
+	return src, true
+}
+
+// handleNewsWebhook 接收Webhook新闻源推送（X-Webhook-Secret请求头验证，按字段映射解析后在下个新闻周期处理）
+func (s *Server) handleNewsWebhook(c *gin.Context) {
+	src, err := s.database.GetNewsSource(c.Param("id"))
+	if err != nil || src.Type != news.SourceTypeWebhook || !src.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "新闻源不存在"})
+		return
+	}
+	secret := c.GetHeader("X-Webhook-Secret")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(src.WebhookSecret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Webhook密钥无效"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("读取请求失败: %v", err)})
+		return
+	}
+	articles, err := news.ParseMappedJSON(body, src.Mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted := news.PushWebhookArticles(*src, articles)
+	c.JSON(http.StatusAccepted, gin.H{"accepted": accepted})
+}
+
+// newWebhookSecret 生成Webhook密钥
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成Webhook密钥失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
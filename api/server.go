@@ -16,7 +16,9 @@ import (
         "nofx/email"
         "nofx/manager"
         "nofx/middleware"
+        "nofx/trader"
         creditsService "nofx/service/credits"
+        "nofx/service/experiment"
         paymentService "nofx/service/payment"
         "os"
         "strconv"
@@ -39,6 +41,7 @@ type Server struct {
         paymentHandler       *payment.Handler
         learningHandler      *handlers.LearningHandler
         newsConfigHandler    *NewsConfigHandler
+        experimentService    *experiment.Service
         port                 int
 }
 
@@ -89,6 +92,7 @@ func NewServer(traderManager *manager.TraderManager, dbConfig *config.Database,
                 paymentHandler:       paymentHandler,
                 learningHandler:      learningHandler,
                 newsConfigHandler:    newsConfigHandler,
+                experimentService:    experiment.NewService(dbConfig),
                 port:                 port,
         }
         // 设置路由
@@ -271,6 +275,9 @@ func (s *Server) setupRoutes() {
                 // Crossmint webhook (无需认证，由签名验证保护)
                 api.POST("/webhooks/crossmint", s.paymentHandler.HandleWebhook)
 
+                // 新闻源 webhook (无需认证，由X-Webhook-Secret验证)
+                api.POST("/webhooks/news/:id", middleware.RateLimitByIP(60, time.Minute), s.handleNewsWebhook)
+
                 // 需要认证的路由
                 protected := api.Group("/", s.authMiddleware())
 
@@ -292,11 +299,22 @@ func (s *Server) setupRoutes() {
                         protected.POST("/traders/:id/start", s.handleStartTrader)
                         protected.POST("/traders/:id/stop", s.handleStopTrader)
                         protected.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
+                        protected.PUT("/traders/:id/ensemble", s.handleUpdateTraderEnsemble)
+                        protected.PUT("/traders/:id/execution", s.handleUpdateTraderExecution)
+                        protected.PUT("/traders/:id/sizing", s.handleUpdateTraderSizing)
+                        protected.PUT("/traders/:id/triggers", s.handleUpdateTraderTriggers)
+                        protected.PUT("/traders/:id/routing", s.handleUpdateTraderRouting)
 
                         // AI学习与反思 (Phase 1)
                         protected.GET("/traders/:id/analysis", s.learningHandler.HandleGetAnalysis)
                         protected.GET("/traders/:id/reflections", s.learningHandler.HandleGetReflections)
 
+                        // 在线A/B实验（prompt模板/自定义prompt/模型/记忆变体）
+                        protected.GET("/experiments", s.handleListExperiments)
+                        protected.POST("/experiments", s.handleCreateExperiment)
+                        protected.GET("/experiments/:id", s.handleGetExperiment)
+                        protected.POST("/experiments/:id/stop", s.handleStopExperiment)
+
                         // AI模型配置
                         protected.GET("/models", s.handleGetModelConfigs)
                         protected.PUT("/models", s.handleUpdateModelConfigs)
@@ -316,10 +334,22 @@ func (s *Server) setupRoutes() {
                         protected.DELETE("/user/news-config", s.newsConfigHandler.DeleteUserNewsConfig)
                         protected.GET("/user/news-config/sources", s.newsConfigHandler.GetEnabledNewsSources)
 
+                        // 自定义新闻源（RSS/Atom、JSON接口、Webhook）
+                        protected.GET("/news/sources", s.handleListNewsSources)
+                        protected.POST("/news/sources", s.handleCreateNewsSource)
+                        protected.PUT("/news/sources/:id", s.handleUpdateNewsSource)
+                        protected.DELETE("/news/sources/:id", s.handleDeleteNewsSource)
+
                         // 指定trader的数据（使用query参数 ?trader_id=xxx）
                         protected.GET("/status", s.handleStatus)
                         protected.GET("/account", s.handleAccount)
                         protected.GET("/positions", s.handlePositions)
+                        protected.GET("/orders", s.handleOrders)
+                        protected.GET("/risk", s.handleRiskStatus)
+                        protected.POST("/risk/reset", s.handleRiskReset)
+
+                        // 用户级组合风控（汇总当前用户所有交易员的持仓）
+                        protected.GET("/portfolio/risk", s.handlePortfolioRisk)
                         protected.GET("/decisions", s.handleDecisions)
                         protected.GET("/decisions/latest", s.handleLatestDecisions)
                         protected.GET("/statistics", s.handleStatistics)
@@ -357,6 +387,26 @@ func (s *Server) setupRoutes() {
                                 creditAdmin.GET("/users/:id/credits", s.creditHandler.HandleGetUserCreditsByAdmin)
                                 creditAdmin.GET("/users/:id/credits/transactions", s.creditHandler.HandleGetUserTransactionsByAdmin)
                         }
+
+                        // 功能标志管理（修改写入审计日志，其他实例定时重载后生效）
+                        flagAdmin := admin.Group("/flags")
+                        flagAdmin.Use(middleware.RateLimitAdmin(30, time.Minute))
+                        {
+                                flagAdmin.GET("", s.handleListFeatureFlags)
+                                flagAdmin.GET("/:name", s.handleGetFeatureFlag)
+                                flagAdmin.PUT("/:name", s.handleUpdateFeatureFlag)
+                                flagAdmin.DELETE("/:name", s.handleDeleteFeatureFlag)
+                        }
+
+                        // 系统新闻源管理（新闻服务下个周期重新加载后生效）
+                        newsAdmin := admin.Group("/news/sources")
+                        newsAdmin.Use(middleware.RateLimitAdmin(30, time.Minute))
+                        {
+                                newsAdmin.GET("", s.handleAdminListNewsSources)
+                                newsAdmin.POST("", s.handleAdminCreateNewsSource)
+                                newsAdmin.PUT("/:id", s.handleAdminUpdateNewsSource)
+                                newsAdmin.DELETE("/:id", s.handleAdminDeleteNewsSource)
+                        }
                 }
         }
 }
@@ -499,20 +549,21 @@ func (s *Server) getTraderFromQuery(c *gin.Context) (*manager.TraderManager, str
 
 // AI交易员管理相关结构体
 type CreateTraderRequest struct {
-        Name                 string  `json:"name" binding:"required"`
-        AIModelID            string  `json:"ai_model_id" binding:"required"`
-        ExchangeID           string  `json:"exchange_id" binding:"required"`
-        InitialBalance       float64 `json:"initial_balance"`
-        ScanIntervalMinutes  int     `json:"scan_interval_minutes"`
-        BTCETHLeverage       int     `json:"btc_eth_leverage"`
-        AltcoinLeverage      int     `json:"altcoin_leverage"`
-        TradingSymbols       string  `json:"trading_symbols"`
-        CustomPrompt         string  `json:"custom_prompt"`
-        OverrideBasePrompt   bool    `json:"override_base_prompt"`
-        SystemPromptTemplate string  `json:"system_prompt_template"` // 系统提示词模板名称
-        IsCrossMargin        *bool   `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
-        UseCoinPool          bool    `json:"use_coin_pool"`
-        UseOITop             bool    `json:"use_oi_top"`
+        Name                 string          `json:"name" binding:"required"`
+        AIModelID            string          `json:"ai_model_id" binding:"required"`
+        ExchangeID           string          `json:"exchange_id" binding:"required"`
+        InitialBalance       float64         `json:"initial_balance"`
+        ScanIntervalMinutes  int             `json:"scan_interval_minutes"`
+        BTCETHLeverage       int             `json:"btc_eth_leverage"`
+        AltcoinLeverage      int             `json:"altcoin_leverage"`
+        TradingSymbols       string          `json:"trading_symbols"`
+        CustomPrompt         string          `json:"custom_prompt"`
+        OverrideBasePrompt   bool            `json:"override_base_prompt"`
+        SystemPromptTemplate string          `json:"system_prompt_template"` // 系统提示词模板名称
+        IsCrossMargin        *bool           `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
+        UseCoinPool          bool            `json:"use_coin_pool"`
+        UseOITop             bool            `json:"use_oi_top"`
+        TradingSchedule      json.RawMessage `json:"trading_schedule"` // 交易时段和停牌事件（可选）
 }
 
 type ModelConfig struct {
@@ -588,6 +639,17 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
                 }
         }
 
+        // 校验交易时段配置
+        tradingSchedule := ""
+        if len(req.TradingSchedule) > 0 {
+                schedule, _, err := parseTradingScheduleInput(req.TradingSchedule)
+                if err != nil {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                        return
+                }
+                tradingSchedule = schedule
+        }
+
         // 生成交易员ID
         traderID := fmt.Sprintf("%s_%s_%d", req.ExchangeID, req.AIModelID, time.Now().Unix())
 
@@ -652,6 +714,7 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
                 IsCrossMargin:        isCrossMargin,
                 ScanIntervalMinutes:  scanIntervalMinutes,
                 IsRunning:            false,
+                TradingSchedule:      tradingSchedule,
         }
 
         // 保存到数据库
@@ -680,17 +743,34 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 
 // UpdateTraderRequest 更新交易员请求
 type UpdateTraderRequest struct {
-        Name                string  `json:"name" binding:"required"`
-        AIModelID           string  `json:"ai_model_id" binding:"required"`
-        ExchangeID          string  `json:"exchange_id" binding:"required"`
-        InitialBalance      float64 `json:"initial_balance"`
-        ScanIntervalMinutes int     `json:"scan_interval_minutes"`
-        BTCETHLeverage      int     `json:"btc_eth_leverage"`
-        AltcoinLeverage     int     `json:"altcoin_leverage"`
-        TradingSymbols      string  `json:"trading_symbols"`
-        CustomPrompt        string  `json:"custom_prompt"`
-        OverrideBasePrompt  bool    `json:"override_base_prompt"`
-        IsCrossMargin       *bool   `json:"is_cross_margin"`
+        Name                string          `json:"name" binding:"required"`
+        AIModelID           string          `json:"ai_model_id" binding:"required"`
+        ExchangeID          string          `json:"exchange_id" binding:"required"`
+        InitialBalance      float64         `json:"initial_balance"`
+        ScanIntervalMinutes int             `json:"scan_interval_minutes"`
+        BTCETHLeverage      int             `json:"btc_eth_leverage"`
+        AltcoinLeverage     int             `json:"altcoin_leverage"`
+        TradingSymbols      string          `json:"trading_symbols"`
+        CustomPrompt        string          `json:"custom_prompt"`
+        OverrideBasePrompt  bool            `json:"override_base_prompt"`
+        IsCrossMargin       *bool           `json:"is_cross_margin"`
+        TradingSchedule     json.RawMessage `json:"trading_schedule"` // 交易时段和停牌事件（不传保持原值，null清除）
+}
+
+// parseTradingScheduleInput 校验请求中的交易时段配置，返回要保存的JSON（null表示清除，返回空字符串）
+func parseTradingScheduleInput(raw json.RawMessage) (string, *trader.TradingSchedule, error) {
+        if strings.TrimSpace(string(raw)) == "null" {
+                return "", nil, nil
+        }
+        schedule, err := trader.ParseTradingSchedule(string(raw))
+        if err != nil || schedule == nil {
+                return "", nil, err
+        }
+        data, err := json.Marshal(schedule)
+        if err != nil {
+                return "", nil, fmt.Errorf("序列化交易时段配置失败: %w", err)
+        }
+        return string(data), schedule, nil
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -746,6 +826,18 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
                 scanIntervalMinutes = existingTrader.ScanIntervalMinutes // 保持原值
         }
 
+        // 交易时段：未传时保持原值
+        tradingSchedule := existingTrader.TradingSchedule
+        scheduleChanged := len(req.TradingSchedule) > 0
+        var schedule *trader.TradingSchedule
+        if scheduleChanged {
+                tradingSchedule, schedule, err = parseTradingScheduleInput(req.TradingSchedule)
+                if err != nil {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                        return
+                }
+        }
+
         // 更新交易员配置
         trader := &config.TraderRecord{
                 ID:                   traderID,
@@ -763,6 +855,7 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
                 IsCrossMargin:        isCrossMargin,
                 ScanIntervalMinutes:  scanIntervalMinutes,
                 IsRunning:            existingTrader.IsRunning, // 保持原值
+                TradingSchedule:      tradingSchedule,
         }
 
         // 更新数据库
@@ -778,6 +871,13 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
                 log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
         }
 
+        // 已在内存中的交易员立即使用新的交易时段
+        if scheduleChanged {
+                if at, err := s.traderManager.GetTrader(traderID); err == nil {
+                        at.SetTradingSchedule(schedule)
+                }
+        }
+
         log.Printf("✓ 更新交易员成功: %s (模型: %s, 交易所: %s)", req.Name, req.AIModelID, req.ExchangeID)
 
         c.JSON(http.StatusOK, gin.H{
@@ -985,6 +1085,235 @@ func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
         c.JSON(http.StatusOK, gin.H{"message": "自定义prompt已更新"})
 }
 
+// handleUpdateTraderEnsemble 更新交易员多模型投票配置
+func (s *Server) handleUpdateTraderEnsemble(c *gin.Context) {
+        traderID := c.Param("id")
+        userID := c.GetString("user_id")
+
+        var req struct {
+                ModelIDs []string `json:"model_ids"` // 参与投票的其他AI模型ID（为空表示关闭投票）
+                Policy   string   `json:"policy"`    // unanimous, majority, weighted
+        }
+
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        policy, err := decision.ParseEnsemblePolicy(req.Policy)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        // 校验模型ID属于该用户且已启用
+        if len(req.ModelIDs) > 0 {
+                models, err := s.database.GetAIModels(userID)
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取AI模型配置失败: %v", err)})
+                        return
+                }
+                enabled := make(map[string]bool)
+                for _, m := range models {
+                        enabled[m.ID] = m.Enabled
+                }
+                for _, id := range req.ModelIDs {
+                        if !enabled[id] {
+                                c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("AI模型 %s 不存在或未启用", id)})
+                                return
+                        }
+                }
+        }
+
+        if err := s.database.UpdateTraderEnsemble(userID, traderID, req.ModelIDs, string(policy)); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新多模型投票配置失败: %v", err)})
+                return
+        }
+
+        // 重新加载交易员到内存，使新的模型组合生效
+        if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+                log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
+        }
+
+        log.Printf("✓ 已更新交易员 %s 的多模型投票配置: %v (%s)", traderID, req.ModelIDs, policy)
+        c.JSON(http.StatusOK, gin.H{"message": "多模型投票配置已更新", "model_ids": req.ModelIDs, "policy": policy})
+}
+
+// handleUpdateTraderExecution 更新交易员开仓执行方式
+func (s *Server) handleUpdateTraderExecution(c *gin.Context) {
+        traderID := c.Param("id")
+        userID := c.GetString("user_id")
+
+        var req struct {
+                Mode string `json:"mode"` // market, limit_mid, post_only, twap（为空表示市价单）
+        }
+
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        mode, err := decision.ParseExecutionMode(req.Mode)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        if err := s.database.UpdateTraderExecutionMode(userID, traderID, string(mode)); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新执行方式失败: %v", err)})
+                return
+        }
+
+        // 重新加载交易员到内存，使新的执行方式生效
+        if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+                log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
+        }
+
+        log.Printf("✓ 已更新交易员 %s 的开仓执行方式: %s", traderID, mode)
+        c.JSON(http.StatusOK, gin.H{"message": "执行方式已更新", "mode": mode})
+}
+
+// handleUpdateTraderSizing 更新交易员单笔风险预算
+func (s *Server) handleUpdateTraderSizing(c *gin.Context) {
+        traderID := c.Param("id")
+        userID := c.GetString("user_id")
+
+        var req struct {
+                RiskPerTradePct float64 `json:"risk_per_trade_pct"` // 止损亏损占净值的百分比（0表示使用系统默认值）
+        }
+
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        if req.RiskPerTradePct < 0 || req.RiskPerTradePct > 10 {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "单笔风险预算必须在0-10%之间"})
+                return
+        }
+
+        if err := s.database.UpdateTraderRiskPerTrade(userID, traderID, req.RiskPerTradePct); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新单笔风险预算失败: %v", err)})
+                return
+        }
+
+        // 重新加载交易员到内存，使新的风险预算生效
+        if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+                log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
+        }
+
+        log.Printf("✓ 已更新交易员 %s 的单笔风险预算: %.2f%%", traderID, req.RiskPerTradePct)
+        c.JSON(http.StatusOK, gin.H{"message": "单笔风险预算已更新", "risk_per_trade_pct": req.RiskPerTradePct})
+}
+
+// handleUpdateTraderTriggers 更新交易员的事件驱动触发器设置（未填写的字段沿用系统配置，空对象表示全部沿用）
+func (s *Server) handleUpdateTraderTriggers(c *gin.Context) {
+        traderID := c.Param("id")
+        userID := c.GetString("user_id")
+
+        var req trader.TriggerSettings
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        data, err := json.Marshal(req)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+        settings := string(data)
+        if settings == "{}" {
+                settings = ""
+        }
+        if _, err := trader.ParseTriggerSettings(settings); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        if err := s.database.UpdateTraderTriggerSettings(userID, traderID, settings); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新触发器设置失败: %v", err)})
+                return
+        }
+
+        // 重新加载交易员到内存，使新的触发器设置生效
+        if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+                log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
+        }
+
+        log.Printf("✓ 已更新交易员 %s 的触发器设置: %s", traderID, settings)
+        c.JSON(http.StatusOK, gin.H{"message": "触发器设置已更新", "trigger_settings": req})
+}
+
+// handleUpdateTraderRouting 更新交易员的跨交易所路由（额外参与路由的交易所，空列表表示只使用主交易所）
+func (s *Server) handleUpdateTraderRouting(c *gin.Context) {
+        traderID := c.Param("id")
+        userID := c.GetString("user_id")
+
+        var req struct {
+                Exchanges []string `json:"exchanges"` // 额外参与路由的交易所ID（binance/okx/hyperliquid/aster）
+        }
+
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        traderRecord, _, _, err := s.database.GetTraderConfig(userID, traderID)
+        if err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+                return
+        }
+        if traderRecord.ExchangeID == "paper" && len(req.Exchanges) > 0 {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "纸面交易不支持跨交易所路由"})
+                return
+        }
+
+        userExchanges, err := s.database.GetExchanges(userID)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易所配置失败: %v", err)})
+                return
+        }
+        enabled := make(map[string]bool, len(userExchanges))
+        for _, ex := range userExchanges {
+                enabled[ex.ID] = ex.Enabled
+        }
+
+        exchanges := make([]string, 0, len(req.Exchanges))
+        seen := map[string]bool{traderRecord.ExchangeID: true}
+        for _, ex := range req.Exchanges {
+                ex = strings.ToLower(strings.TrimSpace(ex))
+                if ex == "" || seen[ex] {
+                        continue
+                }
+                switch ex {
+                case "binance", "okx", "hyperliquid", "aster":
+                default:
+                        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("不支持路由的交易所: %s", ex)})
+                        return
+                }
+                if !enabled[ex] {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("交易所 %s 未配置或未启用", ex)})
+                        return
+                }
+                seen[ex] = true
+                exchanges = append(exchanges, ex)
+        }
+
+        if err := s.database.UpdateTraderRouteExchanges(userID, traderID, exchanges); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新跨交易所路由失败: %v", err)})
+                return
+        }
+
+        // 重新加载交易员到内存，使新的路由配置生效
+        if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+                log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
+        }
+
+        log.Printf("✓ 已更新交易员 %s 的跨交易所路由: %v", traderID, exchanges)
+        c.JSON(http.StatusOK, gin.H{"message": "跨交易所路由已更新", "route_exchanges": exchanges})
+}
+
 // handleGetModelConfigs 获取AI模型配置
 func (s *Server) handleGetModelConfigs(c *gin.Context) {
         userID := c.GetString("user_id")
@@ -1225,6 +1554,27 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
                 "use_oi_top":            traderConfig.UseOITop,
                 "is_running":            isRunning,
         }
+        if modelIDs, policy, err := s.database.GetTraderEnsemble(traderID); err == nil {
+                result["ensemble_model_ids"] = modelIDs
+                result["ensemble_policy"] = policy
+        }
+        if mode, err := s.database.GetTraderExecutionMode(traderID); err == nil {
+                result["execution_mode"] = mode
+        }
+        if pct, err := s.database.GetTraderRiskPerTrade(traderID); err == nil {
+                result["risk_per_trade_pct"] = pct
+        }
+        result["trigger_settings"] = nil
+        if settings, err := s.database.GetTraderTriggerSettings(traderID); err == nil && settings != "" {
+                result["trigger_settings"] = json.RawMessage(settings)
+        }
+        if exchanges, err := s.database.GetTraderRouteExchanges(traderID); err == nil {
+                result["route_exchanges"] = exchanges
+        }
+        result["trading_schedule"] = nil
+        if traderConfig.TradingSchedule != "" {
+                result["trading_schedule"] = json.RawMessage(traderConfig.TradingSchedule)
+        }
 
         c.JSON(http.StatusOK, result)
 }
@@ -1305,6 +1655,118 @@ func (s *Server) handlePositions(c *gin.Context) {
         c.JSON(http.StatusOK, positions)
 }
 
+// handleOrders 订单列表（含部分成交、拒单和止损止盈条件单的状态）
+func (s *Server) handleOrders(c *gin.Context) {
+        _, traderID, err := s.getTraderFromQuery(c)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        trader, err := s.traderManager.GetTrader(traderID)
+        if err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+                return
+        }
+
+        limit := 100
+        if limitStr := c.Query("limit"); limitStr != "" {
+                if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+                        limit = l
+                }
+        }
+
+        orders, err := trader.GetOrders(c.Query("status"), limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{
+                        "error": fmt.Sprintf("获取订单列表失败: %v", err),
+                })
+                return
+        }
+
+        c.JSON(http.StatusOK, orders)
+}
+
+// handleRiskStatus 风控闸门状态（断路器触发状态、日/周盈亏、回撤和限制）
+func (s *Server) handleRiskStatus(c *gin.Context) {
+        _, traderID, err := s.getTraderFromQuery(c)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        trader, err := s.traderManager.GetTrader(traderID)
+        if err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+                return
+        }
+
+        c.JSON(http.StatusOK, trader.GetRiskStatus())
+}
+
+// handleRiskReset 手动重置风控断路器（连续亏损和回撤触发后不会自动恢复）
+func (s *Server) handleRiskReset(c *gin.Context) {
+        userID := c.GetString("user_id")
+        _, traderID, err := s.getTraderFromQuery(c)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        // 重置会放开开仓限制，只允许交易员所属用户操作
+        traders, err := s.database.GetTraders(userID)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "获取交易员列表失败"})
+                return
+        }
+        owned := false
+        for _, t := range traders {
+                if t.ID == traderID {
+                        owned = true
+                        break
+                }
+        }
+        if !owned {
+                c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+                return
+        }
+
+        trader, err := s.traderManager.GetTrader(traderID)
+        if err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+                return
+        }
+
+        var req struct {
+                Reason string `json:"reason"`
+        }
+        c.ShouldBindJSON(&req)
+        reason := strings.TrimSpace(req.Reason)
+        if reason == "" {
+                reason = "手动重置"
+        }
+        reason = fmt.Sprintf("%s (操作人: %s)", reason, userID)
+
+        if err := trader.ResetRiskGate(reason); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        c.JSON(http.StatusOK, trader.GetRiskStatus())
+}
+
+// handlePortfolioRisk 用户组合风控状态（跨交易员/交易所汇总的敞口、限制和最近的拦截/缩减记录）
+func (s *Server) handlePortfolioRisk(c *gin.Context) {
+        userID := c.GetString("user_id")
+
+        // 确保用户的交易员已加载到内存中
+        if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+                log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+        }
+
+        c.JSON(http.StatusOK, s.traderManager.GetPortfolioRisk(userID))
+}
+
 // handleDecisions 决策日志列表
 func (s *Server) handleDecisions(c *gin.Context) {
         _, traderID, err := s.getTraderFromQuery(c)
@@ -1439,6 +1901,9 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
                 AvailableBalance float64 `json:"available_balance"` // 可用余额
                 TotalPnL         float64 `json:"total_pnl"`         // 总盈亏（相对初始余额）
                 TotalPnLPct      float64 `json:"total_pnl_pct"`     // 总盈亏百分比
+                GrossPnL         float64 `json:"gross_pnl"`         // 毛盈亏（净盈亏加回手续费和资金费）
+                TotalFees        float64 `json:"total_fees"`        // 累计手续费
+                TotalFunding     float64 `json:"total_funding"`     // 累计资金费（正数为支付）
                 PositionCount    int     `json:"position_count"`    // 持仓数量
                 MarginUsedPct    float64 `json:"margin_used_pct"`   // 保证金使用率
                 CycleNumber      int     `json:"cycle_number"`
@@ -1485,6 +1950,9 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
                         AvailableBalance: record.AccountState.AvailableBalance,
                         TotalPnL:         totalPnL,
                         TotalPnLPct:      totalPnLPct,
+                        GrossPnL:         totalPnL + record.AccountState.TotalFees + record.AccountState.TotalFunding,
+                        TotalFees:        record.AccountState.TotalFees,
+                        TotalFunding:     record.AccountState.TotalFunding,
                         PositionCount:    record.AccountState.PositionCount,
                         MarginUsedPct:    record.AccountState.MarginUsedPct,
                         CycleNumber:      record.CycleNumber,
@@ -2025,6 +2493,9 @@ func (s *Server) Start() error {
         log.Printf("  • GET  /api/status?trader_id=xxx     - 指定trader的系统状态")
         log.Printf("  • GET  /api/account?trader_id=xxx    - 指定trader的账户信息")
         log.Printf("  • GET  /api/positions?trader_id=xxx  - 指定trader的持仓列表")
+        log.Printf("  • GET  /api/risk?trader_id=xxx       - 指定trader的风控闸门状态")
+        log.Printf("  • POST /api/risk/reset?trader_id=xxx - 手动重置指定trader的风控断路器")
+        log.Printf("  • GET  /api/portfolio/risk           - 当前用户所有trader的组合敞口和风控限制")
         log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志")
         log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
         log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
@@ -2220,17 +2691,33 @@ func (s *Server) getEquityHistoryForTraders(traderIDs []string) map[string]inter
                         continue
                 }
 
+                // 从AutoTrader获取初始余额（与单个交易员的收益曲线口径一致）
+                initialBalance := 0.0
+                if status := trader.GetStatus(); status != nil {
+                        if ib, ok := status["initial_balance"].(float64); ok && ib > 0 {
+                                initialBalance = ib
+                        }
+                }
+
                 // 构建收益率历史数据
                 history := make([]map[string]interface{}, 0, len(records))
                 for _, record := range records {
-                        // 计算总权益（余额+未实现盈亏）
-                        totalEquity := record.AccountState.TotalBalance + record.AccountState.TotalUnrealizedProfit
+                        // TotalBalance字段实际存储的是TotalEquity
+                        totalEquity := record.AccountState.TotalBalance
+                        // TotalUnrealizedProfit字段实际存储的是TotalPnL（相对初始余额），拿不到初始余额时直接使用
+                        totalPnL := record.AccountState.TotalUnrealizedProfit
+                        if initialBalance > 0 {
+                                totalPnL = totalEquity - initialBalance
+                        }
 
                         history = append(history, map[string]interface{}{
-                                "timestamp":    record.Timestamp,
-                                "total_equity": totalEquity,
-                                "total_pnl":    record.AccountState.TotalUnrealizedProfit,
-                                "balance":      record.AccountState.TotalBalance,
+                                "timestamp":     record.Timestamp,
+                                "total_equity":  totalEquity,
+                                "total_pnl":     totalPnL,
+                                "gross_pnl":     totalPnL + record.AccountState.TotalFees + record.AccountState.TotalFunding,
+                                "total_fees":    record.AccountState.TotalFees,
+                                "total_funding": record.AccountState.TotalFunding,
+                                "balance":       record.AccountState.TotalBalance,
                         })
                 }
 
@@ -2556,14 +3043,10 @@ func (s *Server) handleGetUsers(c *gin.Context) {
         })
 }
 
-// adminMiddleware 管理员权限中间件（积分系统专用）
+// adminMiddleware 管理员权限中间件（需在authMiddleware之后使用，只允许is_admin用户）
 func (s *Server) adminMiddleware() gin.HandlerFunc {
         return func(c *gin.Context) {
-                userID := ""
-                if uid, exists := c.Get("userID"); exists {
-                        userID = uid.(string)
-                }
-
+                userID := c.GetString("user_id")
                 if userID == "" {
                         c.JSON(http.StatusUnauthorized, gin.H{
                                 "error": "用户未认证",
@@ -2572,9 +3055,14 @@ func (s *Server) adminMiddleware() gin.HandlerFunc {
                         return
                 }
 
-                // TODO: 实现管理员权限检查
-                // 这里简化处理，实际应该查询数据库验证管理员身份
-                // 暂时允许所有认证用户（测试用）
+                user, ok := c.Get("user")
+                if u, isUser := user.(*config.User); !ok || !isUser || !u.IsAdmin {
+                        c.JSON(http.StatusForbidden, gin.H{
+                                "error": "需要管理员权限",
+                        })
+                        c.Abort()
+                        return
+                }
                 c.Next()
         }
 }
@@ -0,0 +1,107 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"nofx/logger"
+)
+
+// exhaustedResponse 录制响应用完后返回的观望响应
+const exhaustedResponse = "录制的AI响应已用完，保持观望\n[]"
+
+// RecordedAIClient 按顺序回放录制的AI响应（实现 decision.AIClient）
+type RecordedAIClient struct {
+	mu        sync.Mutex
+	responses []string
+	next      int
+	loop      bool
+}
+
+// NewRecordedAIClient 创建录制响应回放客户端
+// loop=true 时响应用完后从头循环，否则返回空决策（观望）
+func NewRecordedAIClient(responses []string, loop bool) *RecordedAIClient {
+	return &RecordedAIClient{
+		responses: responses,
+		loop:      loop,
+	}
+}
+
+// LoadRecordedAIClient 从文件加载录制的AI响应
+// path 为目录时读取其中的决策日志（decision_*.json），用思维链+决策JSON还原AI响应；
+// path 为文件时读取JSON字符串数组
+func LoadRecordedAIClient(path string, loop bool) (*RecordedAIClient, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取录制响应失败: %w", err)
+	}
+
+	var responses []string
+	if info.IsDir() {
+		records, err := logger.LoadDecisionRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if response := ResponseFromRecord(record); response != "" {
+				responses = append(responses, response)
+			}
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取录制响应失败: %w", err)
+		}
+		if err := json.Unmarshal(data, &responses); err != nil {
+			return nil, fmt.Errorf("解析录制响应失败（需要JSON字符串数组）: %w", err)
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("%s 中没有可用的AI响应", path)
+	}
+	return NewRecordedAIClient(responses, loop), nil
+}
+
+// ResponseFromRecord 由决策记录还原AI原始响应
+// 没有AI响应的记录（例如AI调用失败的周期）返回空字符串
+func ResponseFromRecord(record *logger.DecisionRecord) string {
+	if record == nil {
+		return ""
+	}
+	return record.AIResponse()
+}
+
+// CallWithMessages 返回下一条录制的响应
+func (c *RecordedAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= len(c.responses) {
+		if !c.loop || len(c.responses) == 0 {
+			return exhaustedResponse, nil
+		}
+		c.next = 0
+	}
+
+	response := c.responses[c.next]
+	c.next++
+	return response, nil
+}
+
+// Remaining 剩余未回放的响应数量
+func (c *RecordedAIClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.responses) - c.next
+}
+
+// FuncAIClient 函数式AI客户端，便于用规则策略模拟AI（实现 decision.AIClient）
+type FuncAIClient func(systemPrompt, userPrompt string) (string, error)
+
+// CallWithMessages 调用函数生成响应
+func (f FuncAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return f(systemPrompt, userPrompt)
+}
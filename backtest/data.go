@@ -0,0 +1,228 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/market"
+)
+
+// FundingRate 历史资金费率
+type FundingRate struct {
+	Time time.Time
+	Rate float64
+}
+
+// LoadKlinesCSV 从CSV文件加载K线
+// 列顺序: open_time, open, high, low, close, volume[, close_time]
+// open_time/close_time 支持毫秒时间戳或 RFC3339，首行为表头时自动跳过
+func LoadKlinesCSV(path string) ([]market.Kline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开K线文件失败: %w", err)
+	}
+	defer f.Close()
+
+	return ReadKlinesCSV(f)
+}
+
+// ReadKlinesCSV 从Reader读取CSV格式K线
+func ReadKlinesCSV(r io.Reader) ([]market.Kline, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var klines []market.Kline
+	line := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取CSV失败: %w", err)
+		}
+		line++
+
+		if len(row) < 6 {
+			return nil, fmt.Errorf("第%d行列数不足: 需要至少6列, 实际%d列", line, len(row))
+		}
+
+		openTime, err := parseTimestamp(row[0])
+		if err != nil {
+			if line == 1 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("第%d行时间格式错误: %w", line, err)
+		}
+
+		values := make([]float64, 5)
+		for i := 0; i < 5; i++ {
+			values[i], err = strconv.ParseFloat(strings.TrimSpace(row[i+1]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("第%d行第%d列数值错误: %w", line, i+2, err)
+			}
+		}
+
+		kline := market.Kline{
+			OpenTime: openTime,
+			Open:     values[0],
+			High:     values[1],
+			Low:      values[2],
+			Close:    values[3],
+			Volume:   values[4],
+		}
+		if len(row) >= 7 {
+			if closeTime, err := parseTimestamp(row[6]); err == nil {
+				kline.CloseTime = closeTime
+			}
+		}
+		klines = append(klines, kline)
+	}
+
+	sortKlines(klines)
+	fillCloseTimes(klines)
+	return klines, nil
+}
+
+// LoadFundingCSV 从CSV文件加载资金费率 (列: time, rate)
+func LoadFundingCSV(path string) ([]FundingRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开资金费率文件失败: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rates []FundingRate
+	line := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取CSV失败: %w", err)
+		}
+		line++
+		if len(row) < 2 {
+			return nil, fmt.Errorf("第%d行列数不足", line)
+		}
+
+		ts, err := parseTimestamp(row[0])
+		if err != nil {
+			if line == 1 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("第%d行时间格式错误: %w", line, err)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行费率错误: %w", line, err)
+		}
+		rates = append(rates, FundingRate{Time: time.UnixMilli(ts), Rate: rate})
+	}
+
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Time.Before(rates[j].Time) })
+	return rates, nil
+}
+
+// FetchOKXKlines 通过OKX行情接口获取最近的K线（单次最多300根）
+func FetchOKXKlines(symbol, interval string, limit int) ([]market.Kline, error) {
+	klines, err := market.NewAPIClient().GetKlines(symbol, interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s %s K线失败: %w", symbol, interval, err)
+	}
+
+	// OKX返回的CloseTime固定按3分钟计算，这里按实际周期修正
+	if len(klines) >= 2 {
+		step := klines[1].OpenTime - klines[0].OpenTime
+		for i := range klines {
+			klines[i].CloseTime = klines[i].OpenTime + step - 1
+		}
+	}
+	return klines, nil
+}
+
+// AggregateKlines 将小周期K线聚合为大周期K线（如3m -> 4h）
+// 按 period 对齐开盘时间，最后一根可能是尚未走完的K线
+func AggregateKlines(klines []market.Kline, period time.Duration) []market.Kline {
+	periodMs := period.Milliseconds()
+	if periodMs <= 0 || len(klines) == 0 {
+		return nil
+	}
+
+	var result []market.Kline
+	for _, k := range klines {
+		bucket := k.OpenTime - k.OpenTime%periodMs
+		if n := len(result); n > 0 && result[n-1].OpenTime == bucket {
+			last := &result[n-1]
+			if k.High > last.High {
+				last.High = k.High
+			}
+			if k.Low < last.Low {
+				last.Low = k.Low
+			}
+			last.Close = k.Close
+			last.Volume += k.Volume
+			last.QuoteVolume += k.QuoteVolume
+			last.Trades += k.Trades
+			continue
+		}
+		result = append(result, market.Kline{
+			OpenTime:    bucket,
+			Open:        k.Open,
+			High:        k.High,
+			Low:         k.Low,
+			Close:       k.Close,
+			Volume:      k.Volume,
+			CloseTime:   bucket + periodMs - 1,
+			QuoteVolume: k.QuoteVolume,
+			Trades:      k.Trades,
+		})
+	}
+	return result
+}
+
+// parseTimestamp 解析毫秒时间戳或RFC3339时间
+func parseTimestamp(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ms < 1e11 { // 秒级时间戳
+			ms *= 1000
+		}
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// sortKlines 按开盘时间升序排序
+func sortKlines(klines []market.Kline) {
+	sort.Slice(klines, func(i, j int) bool { return klines[i].OpenTime < klines[j].OpenTime })
+}
+
+// fillCloseTimes 根据相邻K线间隔补全缺失的收盘时间
+func fillCloseTimes(klines []market.Kline) {
+	if len(klines) < 2 {
+		return
+	}
+	step := klines[1].OpenTime - klines[0].OpenTime
+	for i := range klines {
+		if klines[i].CloseTime == 0 {
+			klines[i].CloseTime = klines[i].OpenTime + step - 1
+		}
+	}
+}
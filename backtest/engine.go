@@ -0,0 +1,481 @@
+package backtest
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/trader"
+)
+
+const (
+	higherTimeframe      = 4 * time.Hour // 长周期（与实时行情的4h K线一致）
+	defaultLookbackBars  = 100           // 指标计算使用的K线数量（与WSMonitor缓存一致）
+	defaultWarmupBars    = 60
+	defaultDecisionEvery = 5
+)
+
+// Config 回测配置
+type Config struct {
+	TraderID string // 写入交易记录的trader ID（默认 backtest）
+
+	Klines       map[string][]market.Kline // 各币种基础周期K线（推荐3m），按时间升序
+	HigherKlines map[string][]market.Kline // 可选：各币种4h K线，未提供时由基础周期聚合
+
+	Funding            map[string][]FundingRate // 可选：历史资金费率
+	DefaultFundingRate float64                  // 无历史数据时每次结算使用的资金费率
+	FundingInterval    time.Duration            // 资金费结算间隔（默认8h）
+
+	InitialBalance float64                      // 初始资金
+	Exchange       trader.SimulatedTraderConfig // 手续费、滑点、维持保证金率（InitialBalance以上面为准）
+	Execution      trader.ExecutionConfig       // 开仓执行配置（市价/限价/TWAP，为空时使用默认配置）
+	IsCrossMargin  bool
+
+	BTCETHLeverage  int // BTC/ETH杠杆上限
+	AltcoinLeverage int // 山寨币杠杆上限
+
+	DecisionEveryBars int // 每N根基础K线触发一次AI决策（默认5，3m周期即15分钟）
+	WarmupBars        int // 指标预热K线数量（默认60）
+	LookbackBars      int // 传给指标计算的K线数量（默认100）
+	CooldownMinutes   int // 平仓冷却期（默认15分钟）
+
+	CustomPrompt         string
+	OverrideBasePrompt   bool
+	SystemPromptTemplate string
+}
+
+// EquityPoint 净值曲线上的一个点
+type EquityPoint struct {
+	Time          time.Time `json:"time"`
+	Equity        float64   `json:"equity"`
+	Balance       float64   `json:"balance"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	DrawdownPct   float64   `json:"drawdown_pct"`
+}
+
+// CycleResult 单个AI决策周期的结果
+type CycleResult struct {
+	CycleNumber int                     `json:"cycle_number"`
+	Time        time.Time               `json:"time"`
+	Equity      float64                 `json:"equity"`
+	CoTTrace    string                  `json:"cot_trace"`
+	Decisions   []decision.Decision     `json:"decisions"`
+	Actions     []logger.DecisionAction `json:"actions"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+// Result 回测结果
+type Result struct {
+	StartTime      time.Time               `json:"start_time"`
+	EndTime        time.Time               `json:"end_time"`
+	Symbols        []string                `json:"symbols"`
+	InitialBalance float64                 `json:"initial_balance"`
+	FinalEquity    float64                 `json:"final_equity"`
+	EquityCurve    []EquityPoint           `json:"equity_curve"`
+	Fills          []trader.SimFill        `json:"fills"`
+	Fundings       []trader.SimFunding     `json:"fundings"`
+	Trades         []trader.SimClosedTrade `json:"trades"`
+	Cycles         []CycleResult           `json:"cycles"`
+	Metrics        Metrics                 `json:"metrics"`
+}
+
+// Engine 历史回测引擎
+// 按K线逐根回放行情，驱动模拟交易器与（录制/模拟的）AI决策
+// 决策通过 trader.SimulationExecutor 走实盘执行路径，开平仓规则与实盘一致
+type Engine struct {
+	cfg      Config
+	ai       decision.AIClient
+	sim      *trader.SimulatedTrader
+	executor *trader.SimulationExecutor
+	symbols  []string
+	interval time.Duration
+
+	higher  map[string][]market.Kline // 长周期K线（含聚合结果）
+	current map[string]int            // 各币种当前K线索引（-1表示尚未开始）
+
+	now       time.Time
+	startTime time.Time
+	callCount int
+
+	equity []EquityPoint
+	cycles []CycleResult
+}
+
+// NewEngine 创建回测引擎
+func NewEngine(cfg Config, ai decision.AIClient) (*Engine, error) {
+	if ai == nil {
+		return nil, fmt.Errorf("AI客户端不能为空")
+	}
+	if len(cfg.Klines) == 0 {
+		return nil, fmt.Errorf("没有提供K线数据")
+	}
+	if cfg.InitialBalance <= 0 {
+		return nil, fmt.Errorf("初始资金必须大于0")
+	}
+
+	if cfg.TraderID == "" {
+		cfg.TraderID = "backtest"
+	}
+	if cfg.FundingInterval <= 0 {
+		cfg.FundingInterval = 8 * time.Hour
+	}
+	if cfg.BTCETHLeverage <= 0 {
+		cfg.BTCETHLeverage = 5
+	}
+	if cfg.AltcoinLeverage <= 0 {
+		cfg.AltcoinLeverage = 5
+	}
+	if cfg.DecisionEveryBars <= 0 {
+		cfg.DecisionEveryBars = defaultDecisionEvery
+	}
+	if cfg.WarmupBars <= 0 {
+		cfg.WarmupBars = defaultWarmupBars
+	}
+	if cfg.LookbackBars <= 0 {
+		cfg.LookbackBars = defaultLookbackBars
+	}
+	if cfg.CooldownMinutes <= 0 {
+		cfg.CooldownMinutes = 15
+	}
+	if cfg.Exchange == (trader.SimulatedTraderConfig{}) {
+		cfg.Exchange = trader.DefaultSimulatedTraderConfig(cfg.InitialBalance)
+	}
+	cfg.Exchange.InitialBalance = cfg.InitialBalance
+
+	e := &Engine{
+		cfg:     cfg,
+		ai:      ai,
+		sim:     trader.NewSimulatedTrader(cfg.Exchange),
+		higher:  make(map[string][]market.Kline),
+		current: make(map[string]int),
+	}
+
+	e.cfg.Klines = make(map[string][]market.Kline, len(cfg.Klines))
+	for symbol, klines := range cfg.Klines {
+		if len(klines) == 0 {
+			continue
+		}
+		sorted := append([]market.Kline(nil), klines...)
+		sortKlines(sorted)
+		e.cfg.Klines[symbol] = sorted
+		e.symbols = append(e.symbols, symbol)
+		e.current[symbol] = -1
+
+		if higher, ok := cfg.HigherKlines[symbol]; ok && len(higher) > 0 {
+			h := append([]market.Kline(nil), higher...)
+			sortKlines(h)
+			e.higher[symbol] = h
+		} else {
+			e.higher[symbol] = AggregateKlines(sorted, higherTimeframe)
+		}
+
+		if len(sorted) >= 2 {
+			step := time.Duration(sorted[1].OpenTime-sorted[0].OpenTime) * time.Millisecond
+			if e.interval == 0 || step < e.interval {
+				e.interval = step
+			}
+		}
+	}
+	sort.Strings(e.symbols)
+
+	if len(e.symbols) == 0 {
+		return nil, fmt.Errorf("没有提供K线数据")
+	}
+	if e.interval <= 0 {
+		return nil, fmt.Errorf("K线数量不足，无法确定K线周期")
+	}
+
+	clock := func() time.Time { return e.now }
+	e.sim.SetClock(clock)
+	e.executor = trader.NewSimulationExecutor(e.sim, trader.SimulationConfig{
+		TraderID:       cfg.TraderID,
+		Exchange:       "backtest",
+		InitialBalance: cfg.InitialBalance,
+		IsCrossMargin:  cfg.IsCrossMargin,
+		Execution:      cfg.Execution,
+		Clock:          clock,
+		MarketData:     e.marketData,
+	})
+	return e, nil
+}
+
+// Trader 返回回测使用的模拟交易器
+func (e *Engine) Trader() *trader.SimulatedTrader {
+	return e.sim
+}
+
+// Run 执行回测
+func (e *Engine) Run() (*Result, error) {
+	timeline := e.buildTimeline()
+	if len(timeline) <= e.cfg.WarmupBars {
+		return nil, fmt.Errorf("K线数量(%d)不足预热所需(%d)", len(timeline), e.cfg.WarmupBars)
+	}
+
+	intervalMs := e.interval.Milliseconds()
+	fundingMs := e.cfg.FundingInterval.Milliseconds()
+	e.startTime = time.UnixMilli(timeline[0] + intervalMs)
+
+	log.Printf("📊 开始回测: %d个币种, %d根K线, 周期%v, 初始资金 %.2f USDT",
+		len(e.symbols), len(timeline), e.interval, e.cfg.InitialBalance)
+
+	peak := e.cfg.InitialBalance
+	lastFundingBucket := int64(-1)
+	for step, openTime := range timeline {
+		closeMs := openTime + intervalMs
+		e.now = time.UnixMilli(closeMs)
+
+		// 1. 推进行情：按K线高低点检查强平/止损/止盈
+		for _, symbol := range e.symbols {
+			klines := e.cfg.Klines[symbol]
+			next := e.current[symbol] + 1
+			if next < len(klines) && klines[next].OpenTime == openTime {
+				e.current[symbol] = next
+				k := klines[next]
+				e.sim.OnBar(symbol, k.High, k.Low, k.Close)
+			}
+		}
+
+		// 2. 资金费结算
+		bucket := closeMs / fundingMs
+		if lastFundingBucket >= 0 && bucket != lastFundingBucket {
+			for _, symbol := range e.symbols {
+				e.sim.ApplyFunding(symbol, e.fundingRate(symbol))
+			}
+		}
+		lastFundingBucket = bucket
+
+		// 3. AI决策
+		if step >= e.cfg.WarmupBars && (step-e.cfg.WarmupBars)%e.cfg.DecisionEveryBars == 0 {
+			e.runCycle()
+		}
+
+		// 4. 记录净值
+		point := e.snapshotEquity()
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+		if peak > 0 {
+			point.DrawdownPct = (peak - point.Equity) / peak * 100
+		}
+		e.equity = append(e.equity, point)
+	}
+
+	result := &Result{
+		StartTime:      e.startTime,
+		EndTime:        e.now,
+		Symbols:        e.symbols,
+		InitialBalance: e.cfg.InitialBalance,
+		FinalEquity:    e.sim.Equity(),
+		EquityCurve:    e.equity,
+		Fills:          e.sim.GetFills(),
+		Fundings:       e.sim.GetFundings(),
+		Trades:         e.sim.GetClosedTrades(),
+		Cycles:         e.cycles,
+	}
+	result.Metrics = CalculateMetrics(e.cfg.TraderID, e.cfg.InitialBalance, e.cfg.IsCrossMargin, e.interval, result)
+
+	log.Printf("✅ 回测完成: 最终净值 %.2f USDT (%.2f%%), 交易%d笔, 最大回撤%.2f%%, Sharpe %.2f",
+		result.FinalEquity, result.Metrics.TotalReturnPct, result.Metrics.TotalTrades,
+		result.Metrics.MaxDrawdownPct, result.Metrics.SharpeRatio)
+
+	return result, nil
+}
+
+// runCycle 执行一次AI决策周期
+func (e *Engine) runCycle() {
+	e.callCount++
+	cycle := CycleResult{
+		CycleNumber: e.callCount,
+		Time:        e.now,
+		Equity:      e.sim.Equity(),
+	}
+	defer func() { e.cycles = append(e.cycles, cycle) }()
+
+	// 组合止损止盈（与实盘相同，在AI决策前检查）
+	cycle.Actions = append(cycle.Actions, e.executor.CheckPositionGroups()...)
+
+	ctx, err := e.buildContext()
+	if err != nil {
+		cycle.Error = fmt.Sprintf("构建交易上下文失败: %v", err)
+		return
+	}
+
+	fullDecision, err := decision.GetFullDecisionWithCustomPrompt(ctx, e.ai, e.cfg.CustomPrompt, e.cfg.OverrideBasePrompt, e.cfg.SystemPromptTemplate)
+	if fullDecision != nil {
+		cycle.CoTTrace = fullDecision.CoTTrace
+		cycle.Decisions = fullDecision.Decisions
+	}
+	if err != nil {
+		cycle.Error = fmt.Sprintf("获取AI决策失败: %v", err)
+		return
+	}
+
+	cycle.Actions = append(cycle.Actions, e.executor.Execute(fullDecision.Decisions)...)
+}
+
+// buildContext 基于模拟账户和历史行情构建决策上下文
+func (e *Engine) buildContext() (*decision.Context, error) {
+	balance, err := e.sim.GetBalance()
+	if err != nil {
+		return nil, err
+	}
+	positions, err := e.sim.GetPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	totalEquity := balance.TotalEquity()
+	available := balance.AvailableBalance
+	marginUsed := balance.UsedMargin
+
+	// 决策引擎按墙上时钟计算持仓时长和冷却期，这里把回测时间平移到当前时间
+	wallOffset := time.Since(e.now)
+
+	var positionInfos []decision.PositionInfo
+	for _, pos := range positions {
+		pnlPct := 0.0
+		if pos.EntryPrice > 0 {
+			if pos.Side == "long" {
+				pnlPct = (pos.MarkPrice - pos.EntryPrice) / pos.EntryPrice * 100
+			} else {
+				pnlPct = (pos.EntryPrice - pos.MarkPrice) / pos.EntryPrice * 100
+			}
+		}
+
+		positionInfos = append(positionInfos, decision.PositionInfo{
+			Symbol:           pos.Symbol,
+			Side:             pos.Side,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        pos.MarkPrice,
+			Quantity:         pos.Quantity,
+			Leverage:         pos.Leverage,
+			UnrealizedPnL:    pos.UnrealizedProfit,
+			UnrealizedPnLPct: pnlPct,
+			LiquidationPrice: pos.LiquidationPrice,
+			MarginUsed:       pos.MarginUsed(),
+			UpdateTime:       pos.OpenTime.Add(wallOffset).UnixMilli(),
+		})
+	}
+
+	lastCloseTime := e.executor.TradeTimes()
+	for key, ts := range lastCloseTime {
+		lastCloseTime[key] = ts + wallOffset.Milliseconds()
+	}
+
+	candidates := make([]decision.CandidateCoin, 0, len(e.symbols))
+	for _, symbol := range e.symbols {
+		candidates = append(candidates, decision.CandidateCoin{Symbol: symbol, Sources: []string{"backtest"}})
+	}
+
+	totalPnL := totalEquity - e.cfg.InitialBalance
+	marginUsedPct := 0.0
+	if totalEquity > 0 {
+		marginUsedPct = marginUsed / totalEquity * 100
+	}
+
+	return &decision.Context{
+		CurrentTime:     e.now.Format("2006-01-02 15:04:05"),
+		RuntimeMinutes:  int(e.now.Sub(e.startTime).Minutes()),
+		CallCount:       e.callCount,
+		BTCETHLeverage:  e.cfg.BTCETHLeverage,
+		AltcoinLeverage: e.cfg.AltcoinLeverage,
+		Account: decision.AccountInfo{
+			TotalEquity:      totalEquity,
+			AvailableBalance: available,
+			TotalPnL:         totalPnL,
+			TotalPnLPct:      totalPnL / e.cfg.InitialBalance * 100,
+			MarginUsed:       marginUsed,
+			MarginUsedPct:    marginUsedPct,
+			PositionCount:    len(positionInfos),
+		},
+		Positions:          positionInfos,
+		PositionGroups:     e.executor.PositionGroups(positions),
+		CandidateCoins:     candidates,
+		LastCloseTime:      lastCloseTime,
+		CooldownMinutes:    e.cfg.CooldownMinutes,
+		MarketDataProvider: e.marketData,
+		DisableNews:        true,
+	}, nil
+}
+
+// marketData 用截至当前K线的历史数据计算市场指标（不含未来数据）
+func (e *Engine) marketData(symbol string) (*market.Data, error) {
+	idx, ok := e.current[symbol]
+	if !ok || idx < 0 {
+		return nil, fmt.Errorf("%s 在 %s 没有K线数据", symbol, e.now.Format(time.RFC3339))
+	}
+
+	klines := e.cfg.Klines[symbol]
+	start := idx - e.cfg.LookbackBars + 1
+	if start < 0 {
+		start = 0
+	}
+	base := klines[start : idx+1]
+
+	return market.FromKlines(symbol, base, e.higherWindow(symbol, idx), nil, e.fundingRate(symbol))
+}
+
+// higherWindow 截至当前K线的长周期K线：已收盘的长周期K线 + 当前未走完的K线（由基础周期聚合）
+func (e *Engine) higherWindow(symbol string, idx int) []market.Kline {
+	klines := e.cfg.Klines[symbol]
+	periodMs := higherTimeframe.Milliseconds()
+	nowMs := e.now.UnixMilli()
+
+	higher := e.higher[symbol]
+	completed := sort.Search(len(higher), func(i int) bool {
+		return higher[i].OpenTime+periodMs > nowMs
+	})
+	start := completed - e.cfg.LookbackBars
+	if start < 0 {
+		start = 0
+	}
+	window := append([]market.Kline(nil), higher[start:completed]...)
+
+	bucketStart := klines[idx].OpenTime - klines[idx].OpenTime%periodMs
+	if bucketStart+periodMs > nowMs {
+		first := sort.Search(idx+1, func(i int) bool { return klines[i].OpenTime >= bucketStart })
+		window = append(window, AggregateKlines(klines[first:idx+1], higherTimeframe)...)
+	}
+	return window
+}
+
+// fundingRate 当前时刻适用的资金费率
+func (e *Engine) fundingRate(symbol string) float64 {
+	rates := e.cfg.Funding[symbol]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Time.After(e.now) })
+	if i > 0 {
+		return rates[i-1].Rate
+	}
+	return e.cfg.DefaultFundingRate
+}
+
+// snapshotEquity 当前净值快照
+func (e *Engine) snapshotEquity() EquityPoint {
+	balance, _ := e.sim.GetBalance()
+	return EquityPoint{
+		Time:          e.now,
+		Equity:        balance.TotalEquity(),
+		Balance:       balance.TotalWalletBalance,
+		UnrealizedPnL: balance.TotalUnrealizedProfit,
+	}
+}
+
+// buildTimeline 合并所有币种的K线开盘时间
+func (e *Engine) buildTimeline() []int64 {
+	seen := make(map[int64]bool)
+	var timeline []int64
+	for _, symbol := range e.symbols {
+		for _, k := range e.cfg.Klines[symbol] {
+			if !seen[k.OpenTime] {
+				seen[k.OpenTime] = true
+				timeline = append(timeline, k.OpenTime)
+			}
+		}
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i] < timeline[j] })
+	return timeline
+}
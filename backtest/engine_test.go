@@ -0,0 +1,212 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/trader"
+)
+
+// generateKlines 生成单边上涨的3分钟K线
+func generateKlines(n int, start, step float64) []market.Kline {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	klines := make([]market.Kline, n)
+	price := start
+	for i := 0; i < n; i++ {
+		open := price
+		price += step
+		klines[i] = market.Kline{
+			OpenTime:  base + int64(i)*180000,
+			Open:      open,
+			High:      math.Max(open, price) + 0.1,
+			Low:       math.Min(open, price) - 0.1,
+			Close:     price,
+			Volume:    100,
+			CloseTime: base + int64(i+1)*180000 - 1,
+		}
+	}
+	return klines
+}
+
+func TestReadKlinesCSV(t *testing.T) {
+	csvData := "open_time,open,high,low,close,volume\n" +
+		"1735689780000,101,102,100,101.5,10\n" +
+		"1735689600000,100,101,99,100.5,12\n"
+
+	klines, err := ReadKlinesCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("读取CSV失败: %v", err)
+	}
+	if len(klines) != 2 {
+		t.Fatalf("应读取2根K线, got %d", len(klines))
+	}
+	if klines[0].OpenTime != 1735689600000 || klines[0].Close != 100.5 {
+		t.Errorf("K线应按时间升序: %+v", klines[0])
+	}
+	if klines[0].CloseTime != 1735689780000-1 {
+		t.Errorf("收盘时间应自动补全: %d", klines[0].CloseTime)
+	}
+}
+
+func TestAggregateKlines(t *testing.T) {
+	klines := generateKlines(100, 100, 1) // 300分钟
+	higher := AggregateKlines(klines, 4*time.Hour)
+	if len(higher) != 2 {
+		t.Fatalf("应聚合为2根4h K线, got %d", len(higher))
+	}
+	if higher[0].Open != 100 || higher[0].Close != 180 {
+		t.Errorf("第一根4h K线开收盘价错误: %+v", higher[0])
+	}
+	if higher[0].Volume != 8000 {
+		t.Errorf("成交量应累加: %v", higher[0].Volume)
+	}
+}
+
+func TestRecordedAIClientExhausted(t *testing.T) {
+	client := NewRecordedAIClient([]string{"first"}, false)
+	if resp, _ := client.CallWithMessages("", ""); resp != "first" {
+		t.Fatalf("应返回录制响应, got %s", resp)
+	}
+	if resp, _ := client.CallWithMessages("", ""); resp != exhaustedResponse {
+		t.Fatalf("响应用完后应返回观望, got %s", resp)
+	}
+}
+
+func TestEngineRunTrendFollowing(t *testing.T) {
+	klines := generateKlines(200, 100, 0.2)
+
+	// 第一个周期开多，之后保持观望
+	calls := 0
+	ai := FuncAIClient(func(systemPrompt, userPrompt string) (string, error) {
+		calls++
+		if calls > 1 {
+			return "继续持有\n[]", nil
+		}
+		price := klines[60].Close
+		return fmt.Sprintf("趋势向上，开多\n[{\"symbol\":\"BTCUSDT\",\"action\":\"open_long\",\"leverage\":2,\"position_size_usd\":500,\"stop_loss\":%.2f,\"take_profit\":%.2f,\"confidence\":80,\"reasoning\":\"test\"}]",
+			price*0.95, price*1.1), nil
+	})
+
+	engine, err := NewEngine(Config{
+		Klines:             map[string][]market.Kline{"BTCUSDT": klines},
+		InitialBalance:     1000,
+		DefaultFundingRate: 0.0001,
+		Exchange: trader.SimulatedTraderConfig{
+			TakerFeeRate: 0.0005,
+			SlippageBps:  0,
+		},
+		DecisionEveryBars: 20,
+		WarmupBars:        60,
+	}, ai)
+	if err != nil {
+		t.Fatalf("创建回测引擎失败: %v", err)
+	}
+
+	result, err := engine.Run()
+	if err != nil {
+		t.Fatalf("回测失败: %v", err)
+	}
+
+	if len(result.EquityCurve) != len(klines) {
+		t.Errorf("净值曲线长度应等于K线数量: %d", len(result.EquityCurve))
+	}
+	if len(result.Cycles) != 7 {
+		t.Errorf("应执行7个决策周期, got %d", len(result.Cycles))
+	}
+	if len(result.Fills) < 2 {
+		t.Fatalf("应有开仓和止盈成交, got %d", len(result.Fills))
+	}
+	if result.Fills[1].Reason != "take_profit" {
+		t.Errorf("上涨行情应触发止盈: %+v", result.Fills[1])
+	}
+
+	m := result.Metrics
+	if m.TotalTrades != 1 || m.WinningTrades != 1 {
+		t.Errorf("应有1笔盈利交易: %+v", m)
+	}
+	if m.TotalFees <= 0 {
+		t.Errorf("应计入手续费: %v", m.TotalFees)
+	}
+	if !(result.FinalEquity > 1000) {
+		t.Errorf("最终净值应大于初始资金: %v", result.FinalEquity)
+	}
+	if math.Abs(m.NetPnL-(m.GrossPnL-m.TotalFees-m.TotalFunding)) > 1e-6 {
+		t.Errorf("净盈亏应等于价格盈亏-手续费-资金费: %+v", m)
+	}
+	if m.TradeAnalysis == nil || m.TradeAnalysis.TotalTrades != 1 {
+		t.Errorf("应生成逐笔交易分析: %+v", m.TradeAnalysis)
+	}
+}
+
+func TestEngineUsesLiveExecutionPath(t *testing.T) {
+	btc := generateKlines(120, 100, 0.01)
+	eth := generateKlines(120, 50, 0.01)
+	sol := generateKlines(120, 20, 0.01)
+
+	// 第一个周期超额开多BTC（实盘规则按可用保证金80%缩减）并开ETH/SOL组合
+	calls := 0
+	ai := FuncAIClient(func(systemPrompt, userPrompt string) (string, error) {
+		calls++
+		if calls > 1 {
+			return "观望\n[]", nil
+		}
+		price := btc[60].Close
+		open := fmt.Sprintf(`{"symbol":"BTCUSDT","action":"open_long","leverage":2,"position_size_usd":2000,"stop_loss":%.2f,"take_profit":%.2f,"confidence":80,"reasoning":"test"}`,
+			price*0.95, price*1.1)
+		pair := `{"action":"open_pair","legs":[{"symbol":"ETHUSDT","side":"long","ratio":1},{"symbol":"SOLUSDT","side":"short","ratio":1}],"leverage":2,"position_size_usd":200,"stop_loss_pct":50,"take_profit_pct":50,"confidence":80,"reasoning":"pair"}`
+		return "开仓\n[" + open + "," + pair + "]", nil
+	})
+
+	engine, err := NewEngine(Config{
+		Klines:            map[string][]market.Kline{"BTCUSDT": btc, "ETHUSDT": eth, "SOLUSDT": sol},
+		InitialBalance:    1000,
+		DecisionEveryBars: 20,
+		WarmupBars:        60,
+	}, ai)
+	if err != nil {
+		t.Fatalf("创建回测引擎失败: %v", err)
+	}
+	result, err := engine.Run()
+	if err != nil {
+		t.Fatalf("回测失败: %v", err)
+	}
+
+	first := result.Cycles[0]
+	if len(first.Actions) != 2 {
+		t.Fatalf("第一个周期应执行2个决策: %+v", first)
+	}
+	for _, a := range first.Actions {
+		if !a.Success {
+			t.Errorf("%s %s 应执行成功: %s", a.Symbol, a.Action, a.Error)
+		}
+		switch a.Action {
+		case "open_long":
+			if notional := a.Quantity * btc[60].Close; math.Abs(notional-1600) > 1 {
+				t.Errorf("开仓金额应缩减至可用保证金80%%×杠杆(1600), got %.2f", notional)
+			}
+		case "open_pair":
+			if a.GroupID == "" || len(a.Legs) != 2 {
+				t.Errorf("组合开仓应记录组合ID和各腿成交: %+v", a)
+			}
+		}
+	}
+
+	positions, _ := engine.Trader().GetPositions()
+	if len(positions) != 3 {
+		t.Errorf("应持有BTC多仓和组合的两条腿: %+v", positions)
+	}
+}
+
+func TestToTradeRecordsMarginMode(t *testing.T) {
+	trades := []trader.SimClosedTrade{{Symbol: "BTCUSDT", Margin: 100, OpenTime: time.Unix(0, 0), CloseTime: time.Unix(3600, 0)}}
+	if got := ToTradeRecords("bt", true, trades)[0].MarginMode; got != "cross" {
+		t.Fatalf("全仓回测 MarginMode = %s", got)
+	}
+	if got := ToTradeRecords("bt", false, trades)[0].MarginMode; got != "isolated" {
+		t.Fatalf("逐仓回测 MarginMode = %s", got)
+	}
+}
@@ -0,0 +1,149 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"nofx/database"
+	"nofx/decision/analysis"
+	"nofx/trader"
+)
+
+// Metrics 回测绩效指标
+type Metrics struct {
+	TotalReturnPct float64 `json:"total_return_pct"` // 总收益率
+	GrossPnL       float64 `json:"gross_pnl"`        // 已实现价格盈亏（不含费用）
+	TotalFees      float64 `json:"total_fees"`       // 累计手续费
+	TotalFunding   float64 `json:"total_funding"`    // 累计资金费（正数=净支付）
+	NetPnL         float64 `json:"net_pnl"`          // 最终净值 - 初始资金
+
+	TotalTrades   int     `json:"total_trades"`
+	WinningTrades int     `json:"winning_trades"`
+	LosingTrades  int     `json:"losing_trades"`
+	WinRate       float64 `json:"win_rate"`
+	ProfitFactor  float64 `json:"profit_factor"`
+	Liquidations  int     `json:"liquidations"`
+
+	MaxDrawdownPct float64 `json:"max_drawdown_pct"` // 基于净值曲线的最大回撤
+	SharpeRatio    float64 `json:"sharpe_ratio"`     // 基于净值曲线的年化夏普比率
+
+	// TradeAnalysis 逐笔交易统计（与实盘 TradeAnalyzer 口径一致，ProfitPct 为保证金收益率）
+	TradeAnalysis *analysis.TradeAnalysisResult `json:"trade_analysis"`
+}
+
+// CalculateMetrics 根据回测结果计算绩效指标（isCrossMargin为回测配置的仓位模式）
+func CalculateMetrics(traderID string, initialBalance float64, isCrossMargin bool, interval time.Duration, result *Result) Metrics {
+	m := Metrics{
+		NetPnL: result.FinalEquity - initialBalance,
+	}
+	if initialBalance > 0 {
+		m.TotalReturnPct = m.NetPnL / initialBalance * 100
+	}
+
+	var grossWin, grossLoss float64
+	for _, t := range result.Trades {
+		m.GrossPnL += t.RealizedPnL
+		net := t.NetPnL()
+		if net > 0 {
+			m.WinningTrades++
+			grossWin += net
+		} else {
+			m.LosingTrades++
+			grossLoss += -net
+		}
+		if t.Reason == "liquidation" {
+			m.Liquidations++
+		}
+	}
+	for _, f := range result.Fills {
+		m.TotalFees += f.Fee
+	}
+	for _, f := range result.Fundings {
+		m.TotalFunding += f.Payment
+	}
+
+	m.TotalTrades = len(result.Trades)
+	if m.TotalTrades > 0 {
+		m.WinRate = float64(m.WinningTrades) / float64(m.TotalTrades) * 100
+	}
+	if grossLoss > 0 {
+		m.ProfitFactor = grossWin / grossLoss
+	} else if grossWin > 0 {
+		m.ProfitFactor = 999.0 // 与 TradeAnalyzer 一致
+	}
+
+	for _, p := range result.EquityCurve {
+		if p.DrawdownPct > m.MaxDrawdownPct {
+			m.MaxDrawdownPct = p.DrawdownPct
+		}
+	}
+	m.SharpeRatio = equitySharpe(result.EquityCurve, interval)
+
+	m.TradeAnalysis = analysis.NewTradeAnalyzer(nil).Analyze(ToTradeRecords(traderID, isCrossMargin, result.Trades))
+	return m
+}
+
+// ToTradeRecords 将模拟平仓交易转换为 trade_records 结构，便于复用实盘分析工具
+func ToTradeRecords(traderID string, isCrossMargin bool, trades []trader.SimClosedTrade) []database.TradeRecord {
+	marginMode := "isolated"
+	if isCrossMargin {
+		marginMode = "cross"
+	}
+	records := make([]database.TradeRecord, 0, len(trades))
+	for _, t := range trades {
+		profitPct := 0.0
+		if t.Margin > 0 {
+			profitPct = t.NetPnL() / t.Margin * 100
+		}
+		records = append(records, database.TradeRecord{
+			TraderID:           traderID,
+			Symbol:             t.Symbol,
+			EntryPrice:         t.EntryPrice,
+			ExitPrice:          t.ExitPrice,
+			ProfitPct:          profitPct,
+			Leverage:           t.Leverage,
+			HoldingTimeSeconds: int64(t.CloseTime.Sub(t.OpenTime).Seconds()),
+			MarginMode:         marginMode,
+			CreatedAt:          t.CloseTime,
+		})
+	}
+	return records
+}
+
+// equitySharpe 用逐根K线的净值收益率计算年化夏普比率（加密市场按365天年化，无风险利率2%）
+func equitySharpe(curve []EquityPoint, interval time.Duration) float64 {
+	if len(curve) < 3 || interval <= 0 {
+		return 0
+	}
+
+	returns := make([]float64, 0, len(curve)-1)
+	for i := 1; i < len(curve); i++ {
+		prev := curve[i-1].Equity
+		if prev <= 0 {
+			continue
+		}
+		returns = append(returns, curve[i].Equity/prev-1)
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+
+	var sum float64
+	for _, r := range returns {
+		sum += r
+	}
+	mean := sum / float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += math.Pow(r-mean, 2)
+	}
+	stdDev := math.Sqrt(variance / float64(len(returns)))
+	if stdDev == 0 {
+		return 0
+	}
+
+	periodsPerYear := float64(365*24*time.Hour) / float64(interval)
+	riskFreeRate := 0.02 / periodsPerYear
+	return (mean - riskFreeRate) / stdDev * math.Sqrt(periodsPerYear)
+}
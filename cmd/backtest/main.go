@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"nofx/backtest"
+	"nofx/market"
+	"nofx/trader"
+)
+
+func main() {
+	klinesFlag := flag.String("klines", "", "CSV K线文件, 格式: BTCUSDT=btc_3m.csv,ETHUSDT=eth_3m.csv")
+	okxFlag := flag.String("okx", "", "从OKX拉取最近K线的币种列表, 如: BTCUSDT,ETHUSDT")
+	interval := flag.String("interval", "3m", "从OKX拉取K线的周期")
+	limit := flag.Int("limit", 300, "从OKX拉取K线的数量（最多300）")
+	fundingFlag := flag.String("funding", "", "资金费率CSV, 格式: BTCUSDT=btc_funding.csv")
+	fundingRate := flag.Float64("funding-rate", 0, "无资金费率数据时每8小时使用的默认费率")
+	responses := flag.String("responses", "", "录制的AI响应: 决策日志目录（decision_logs/<trader_id>）或JSON字符串数组文件")
+	loop := flag.Bool("loop", false, "录制响应用完后从头循环")
+	balance := flag.Float64("balance", 1000, "初始资金 (USDT)")
+	fee := flag.Float64("fee", 0.0005, "吃单手续费率")
+	slippage := flag.Float64("slippage", 2, "市价成交滑点 (基点)")
+	every := flag.Int("every", 5, "每N根K线触发一次AI决策")
+	warmup := flag.Int("warmup", 60, "指标预热K线数量")
+	btcEthLeverage := flag.Int("btc-eth-leverage", 5, "BTC/ETH杠杆上限")
+	altcoinLeverage := flag.Int("altcoin-leverage", 5, "山寨币杠杆上限")
+	template := flag.String("template", "default", "系统提示词模板")
+	out := flag.String("out", "", "回测结果输出文件 (JSON)")
+	flag.Parse()
+
+	if *responses == "" {
+		log.Fatal("❌ 必须通过 -responses 指定录制的AI响应")
+	}
+
+	klines := make(map[string][]market.Kline)
+	for symbol, path := range parsePairs(*klinesFlag) {
+		data, err := backtest.LoadKlinesCSV(path)
+		if err != nil {
+			log.Fatalf("❌ 加载 %s K线失败: %v", symbol, err)
+		}
+		klines[symbol] = data
+		log.Printf("✓ 已加载 %s K线: %d 根", symbol, len(data))
+	}
+	for _, symbol := range splitList(*okxFlag) {
+		data, err := backtest.FetchOKXKlines(symbol, *interval, *limit)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		klines[symbol] = data
+		log.Printf("✓ 已从OKX获取 %s K线: %d 根", symbol, len(data))
+	}
+	if len(klines) == 0 {
+		log.Fatal("❌ 必须通过 -klines 或 -okx 提供K线数据")
+	}
+
+	funding := make(map[string][]backtest.FundingRate)
+	for symbol, path := range parsePairs(*fundingFlag) {
+		rates, err := backtest.LoadFundingCSV(path)
+		if err != nil {
+			log.Fatalf("❌ 加载 %s 资金费率失败: %v", symbol, err)
+		}
+		funding[symbol] = rates
+	}
+
+	aiClient, err := backtest.LoadRecordedAIClient(*responses, *loop)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	exchangeCfg := trader.DefaultSimulatedTraderConfig(*balance)
+	exchangeCfg.TakerFeeRate = *fee
+	exchangeCfg.SlippageBps = *slippage
+
+	engine, err := backtest.NewEngine(backtest.Config{
+		Klines:               klines,
+		Funding:              funding,
+		DefaultFundingRate:   *fundingRate,
+		InitialBalance:       *balance,
+		Exchange:             exchangeCfg,
+		BTCETHLeverage:       *btcEthLeverage,
+		AltcoinLeverage:      *altcoinLeverage,
+		DecisionEveryBars:    *every,
+		WarmupBars:           *warmup,
+		SystemPromptTemplate: *template,
+	}, aiClient)
+	if err != nil {
+		log.Fatalf("❌ 创建回测引擎失败: %v", err)
+	}
+
+	result, err := engine.Run()
+	if err != nil {
+		log.Fatalf("❌ 回测失败: %v", err)
+	}
+
+	printSummary(result)
+
+	if *out != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ 序列化回测结果失败: %v", err)
+		}
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			log.Fatalf("❌ 写入回测结果失败: %v", err)
+		}
+		log.Printf("📝 回测结果已保存: %s", *out)
+	}
+}
+
+// printSummary 打印回测摘要
+func printSummary(result *backtest.Result) {
+	m := result.Metrics
+	fmt.Println(strings.Repeat("=", 70))
+	fmt.Println("📊 回测结果")
+	fmt.Println(strings.Repeat("=", 70))
+	fmt.Printf("区间:       %s → %s\n", result.StartTime.Format("2006-01-02 15:04"), result.EndTime.Format("2006-01-02 15:04"))
+	fmt.Printf("币种:       %s\n", strings.Join(result.Symbols, ", "))
+	fmt.Printf("初始资金:   %.2f USDT\n", result.InitialBalance)
+	fmt.Printf("最终净值:   %.2f USDT (%.2f%%)\n", result.FinalEquity, m.TotalReturnPct)
+	fmt.Printf("价格盈亏:   %.2f USDT\n", m.GrossPnL)
+	fmt.Printf("手续费:     %.2f USDT\n", m.TotalFees)
+	fmt.Printf("资金费:     %.2f USDT\n", m.TotalFunding)
+	fmt.Printf("交易笔数:   %d (胜 %d / 负 %d, 胜率 %.1f%%, 强平 %d)\n",
+		m.TotalTrades, m.WinningTrades, m.LosingTrades, m.WinRate, m.Liquidations)
+	fmt.Printf("利润因子:   %.2f\n", m.ProfitFactor)
+	fmt.Printf("最大回撤:   %.2f%%\n", m.MaxDrawdownPct)
+	fmt.Printf("夏普比率:   %.2f\n", m.SharpeRatio)
+	fmt.Printf("决策周期:   %d, 成交 %d 笔\n", len(result.Cycles), len(result.Fills))
+	fmt.Println(strings.Repeat("=", 70))
+}
+
+// parsePairs 解析 "KEY=VALUE,KEY2=VALUE2" 格式参数
+func parsePairs(s string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range splitList(s) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("❌ 参数格式错误: %s (需要 SYMBOL=PATH)", item)
+		}
+		pairs[strings.ToUpper(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return pairs
+}
+
+// splitList 解析逗号分隔列表
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"nofx/trader"
+)
+
+func main() {
+	logDir := flag.String("logs", "", "决策日志目录, 如: decision_logs/<trader_id>")
+	from := flag.Int("from", 0, "从第N个周期开始回放")
+	to := flag.Int("to", 0, "回放到第N个周期结束")
+	balance := flag.Float64("balance", 0, "初始净值 (USDT, 默认使用第一条记录的账户净值)")
+	fee := flag.Float64("fee", 0.0005, "吃单手续费率")
+	slippage := flag.Float64("slippage", 0, "市价成交滑点 (基点)")
+	btcEthLeverage := flag.Int("btc-eth-leverage", 5, "BTC/ETH杠杆上限")
+	altcoinLeverage := flag.Int("altcoin-leverage", 5, "山寨币杠杆上限")
+	verbose := flag.Bool("v", false, "打印每个周期的执行结果")
+	out := flag.String("out", "", "回放结果输出文件 (JSON)")
+	flag.Parse()
+
+	if *logDir == "" {
+		log.Fatal("❌ 必须通过 -logs 指定决策日志目录")
+	}
+
+	exchangeCfg := trader.DefaultSimulatedTraderConfig(*balance)
+	exchangeCfg.TakerFeeRate = *fee
+	exchangeCfg.SlippageBps = *slippage
+
+	result, err := trader.ReplayDecisionLogs(trader.ReplayConfig{
+		TraderID:        filepath.Base(*logDir),
+		LogDir:          *logDir,
+		FromCycle:       *from,
+		ToCycle:         *to,
+		InitialBalance:  *balance,
+		Exchange:        exchangeCfg,
+		IsCrossMargin:   true,
+		BTCETHLeverage:  *btcEthLeverage,
+		AltcoinLeverage: *altcoinLeverage,
+	})
+	if err != nil {
+		log.Fatalf("❌ 回放失败: %v", err)
+	}
+
+	printSummary(result, *verbose)
+
+	if *out != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ 序列化回放结果失败: %v", err)
+		}
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			log.Fatalf("❌ 写入回放结果失败: %v", err)
+		}
+		log.Printf("📝 回放结果已保存: %s", *out)
+	}
+}
+
+// printSummary 打印回放摘要
+func printSummary(result *trader.ReplayResult, verbose bool) {
+	fmt.Println(strings.Repeat("=", 70))
+	fmt.Println("🔁 决策回放结果")
+	fmt.Println(strings.Repeat("=", 70))
+
+	for _, cycle := range result.Cycles {
+		if !verbose && len(cycle.Mismatches) == 0 && cycle.Error == "" {
+			continue
+		}
+		fmt.Printf("周期 #%d  %s  净值 %.2f\n", cycle.CycleNumber, cycle.Time.Format("2006-01-02 15:04:05"), cycle.Equity)
+		if cycle.Error != "" {
+			fmt.Printf("  ❌ %s\n", cycle.Error)
+		}
+		for _, action := range cycle.Actions {
+			status := "✓"
+			if !action.Success {
+				status = "✗ " + action.Error
+			}
+			fmt.Printf("  %s %s @ %.4f %s\n", action.Symbol, action.Action, action.Price, status)
+		}
+		for _, mismatch := range cycle.Mismatches {
+			fmt.Printf("  ⚠️ %s\n", mismatch)
+		}
+	}
+
+	fmt.Println(strings.Repeat("-", 70))
+	fmt.Printf("回放周期:   %d (跳过无响应周期 %d)\n", len(result.Cycles), result.Skipped)
+	fmt.Printf("不一致周期: %d\n", result.MismatchCount())
+	fmt.Printf("成交:       %d 笔, 平仓交易 %d 笔\n", len(result.Fills), len(result.Trades))
+	fmt.Printf("净值:       %.2f → %.2f USDT\n", result.InitialBalance, result.FinalEquity)
+	fmt.Println(strings.Repeat("=", 70))
+}
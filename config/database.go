@@ -402,6 +402,12 @@ func (d *Database) createTablesPostgres() error {
                         leverage INT DEFAULT 1,
                         holding_time_seconds BIGINT DEFAULT 0,
                         margin_mode TEXT DEFAULT 'cross',
+                        side TEXT DEFAULT '',
+                        quantity DECIMAL(24,8) DEFAULT 0,
+                        realized_pnl DECIMAL(24,8) DEFAULT 0,
+                        fees DECIMAL(24,8) DEFAULT 0,
+                        funding DECIMAL(24,8) DEFAULT 0,
+                        close_reason TEXT DEFAULT '',
                         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
                 )`,
 
@@ -422,6 +428,147 @@ func (d *Database) createTablesPostgres() error {
                         updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                         UNIQUE(trader_id, symbol)
                 )`,
+
+		// 订单表 (订单管理与交易所对账)
+		`CREATE TABLE IF NOT EXISTS orders (
+                        client_order_id TEXT PRIMARY KEY,
+                        exchange_order_id TEXT DEFAULT '',
+                        trader_id TEXT NOT NULL,
+                        exchange TEXT DEFAULT '',
+                        symbol TEXT NOT NULL,
+                        action TEXT NOT NULL,
+                        position_side TEXT DEFAULT '',
+                        order_type TEXT NOT NULL,
+                        quantity DECIMAL(24,8) DEFAULT 0,
+                        filled_qty DECIMAL(24,8) DEFAULT 0,
+                        avg_price DECIMAL(24,8) DEFAULT 0,
+                        price DECIMAL(24,8) DEFAULT 0,
+                        stop_price DECIMAL(24,8) DEFAULT 0,
+                        fee DECIMAL(24,8) DEFAULT 0,
+                        status TEXT NOT NULL,
+                        error TEXT DEFAULT '',
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )`,
+
+		// 亏损事件表 (开仓前风控断路器的触发、重置和亏损记录)
+		`CREATE TABLE IF NOT EXISTS loss_events (
+                        id SERIAL PRIMARY KEY,
+                        user_id TEXT DEFAULT '',
+                        trader_id TEXT NOT NULL,
+                        event_type TEXT NOT NULL,
+                        event_reason TEXT DEFAULT '',
+                        consecutive_losses INTEGER DEFAULT 0,
+                        daily_loss_pct DECIMAL(10,4) DEFAULT 0,
+                        weekly_loss_pct DECIMAL(10,4) DEFAULT 0,
+                        max_drawdown_pct DECIMAL(10,4) DEFAULT 0,
+                        account_equity DECIMAL(24,8) DEFAULT 0,
+                        breach_triggered BOOLEAN DEFAULT FALSE,
+                        recovery_attempt INTEGER DEFAULT 0,
+                        account_peak DECIMAL(24,8) DEFAULT 0,
+                        day_start_equity DECIMAL(24,8) DEFAULT 0,
+                        week_start_equity DECIMAL(24,8) DEFAULT 0,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )`,
+
+		// 持仓峰值表 (跟踪止损的高水位和当前止损止盈价，重启后恢复)
+		`CREATE TABLE IF NOT EXISTS position_peaks (
+                        trader_id TEXT NOT NULL,
+                        symbol TEXT NOT NULL,
+                        side TEXT NOT NULL,
+                        entry_price DECIMAL(24,8) DEFAULT 0,
+                        peak_price DECIMAL(24,8) DEFAULT 0,
+                        stop_loss DECIMAL(24,8) DEFAULT 0,
+                        take_profit DECIMAL(24,8) DEFAULT 0,
+                        break_even BOOLEAN DEFAULT FALSE,
+                        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        PRIMARY KEY (trader_id, symbol, side)
+                )`,
+
+		// 手续费/资金费流水表 (从交易所账单同步，用于区分毛盈亏和净盈亏)
+		`CREATE TABLE IF NOT EXISTS trader_ledger (
+                        id BIGSERIAL PRIMARY KEY,
+                        trader_id TEXT NOT NULL,
+                        symbol TEXT DEFAULT '',
+                        entry_type TEXT NOT NULL,
+                        amount DECIMAL(24,8) NOT NULL,
+                        exchange_ref TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        UNIQUE (trader_id, entry_type, exchange_ref)
+                )`,
+
+		// 组合持仓表 (多腿决策开出的配对/对冲仓位，作为一个整体计算盈亏和止损)
+		`CREATE TABLE IF NOT EXISTS position_groups (
+                        id TEXT PRIMARY KEY,
+                        trader_id TEXT NOT NULL,
+                        legs TEXT NOT NULL,
+                        leverage INTEGER DEFAULT 1,
+                        stop_loss_pct DECIMAL(10,4) DEFAULT 0,
+                        take_profit_pct DECIMAL(10,4) DEFAULT 0,
+                        status TEXT DEFAULT 'open',
+                        realized_pnl DECIMAL(24,8) DEFAULT 0,
+                        close_reason TEXT DEFAULT '',
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        closed_at TIMESTAMP
+                )`,
+
+		// 在线A/B实验表 (prompt模板/自定义prompt/模型/记忆变体，变体和交易员以JSON保存)
+		`CREATE TABLE IF NOT EXISTS experiments (
+                        id TEXT PRIMARY KEY,
+                        user_id TEXT NOT NULL,
+                        name TEXT NOT NULL,
+                        description TEXT DEFAULT '',
+                        status TEXT DEFAULT 'running',
+                        assignment TEXT DEFAULT 'trader',
+                        trader_ids TEXT NOT NULL,
+                        variants TEXT NOT NULL,
+                        max_drawdown_pct DECIMAL(10,4) DEFAULT 0,
+                        stop_reason TEXT DEFAULT '',
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        stopped_at TIMESTAMP
+                )`,
+
+		// 实验变体分配表 (trader模式每个交易员一条，cycle模式每个决策周期一条)
+		`CREATE TABLE IF NOT EXISTS experiment_assignments (
+                        id BIGSERIAL PRIMARY KEY,
+                        experiment_id TEXT NOT NULL,
+                        trader_id TEXT NOT NULL,
+                        variant TEXT NOT NULL,
+                        cycle INTEGER DEFAULT 0,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )`,
+
+		// 功能标志表 (多实例定时重载，用户白名单/黑名单和元数据以JSON保存)
+		`CREATE TABLE IF NOT EXISTS feature_flags (
+                        name TEXT PRIMARY KEY,
+                        description TEXT DEFAULT '',
+                        enabled BOOLEAN DEFAULT false,
+                        percentage INTEGER DEFAULT 0,
+                        allow_users TEXT DEFAULT '',
+                        deny_users TEXT DEFAULT '',
+                        bucketing TEXT DEFAULT '',
+                        metadata TEXT DEFAULT '',
+                        updated_by TEXT DEFAULT '',
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )`,
+
+		// 自定义新闻源表 (RSS/Atom、JSON接口、Webhook；user_id为空表示系统新闻源，请求头和字段映射以JSON保存)
+		`CREATE TABLE IF NOT EXISTS news_sources (
+                        id TEXT PRIMARY KEY,
+                        user_id TEXT DEFAULT '',
+                        name TEXT NOT NULL,
+                        type TEXT NOT NULL,
+                        url TEXT DEFAULT '',
+                        category TEXT DEFAULT 'crypto',
+                        options TEXT DEFAULT '',
+                        topic_id INTEGER DEFAULT 0,
+                        webhook_secret TEXT DEFAULT '',
+                        enabled BOOLEAN DEFAULT true,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        UNIQUE(user_id, name)
+                )`,
 	}
 
 	for _, query := range queries {
@@ -491,6 +638,29 @@ func (d *Database) alterTables() error {
 		{"ai_models", "custom_api_url", `ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`},
 		{"ai_models", "custom_model_name", `ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`},
 
+		// 添加traders表多模型投票配置
+		{"traders", "ensemble_model_ids", `ALTER TABLE traders ADD COLUMN ensemble_model_ids TEXT DEFAULT ''`},
+		{"traders", "ensemble_policy", `ALTER TABLE traders ADD COLUMN ensemble_policy TEXT DEFAULT ''`},
+
+		// 开仓执行方式（market/limit_mid/post_only/twap）及限价单价格
+		{"traders", "execution_mode", `ALTER TABLE traders ADD COLUMN execution_mode TEXT DEFAULT ''`},
+		{"traders", "risk_per_trade_pct", `ALTER TABLE traders ADD COLUMN risk_per_trade_pct REAL DEFAULT 0`},
+		{"traders", "route_exchanges", `ALTER TABLE traders ADD COLUMN route_exchanges TEXT DEFAULT ''`},
+		{"traders", "trading_schedule", `ALTER TABLE traders ADD COLUMN trading_schedule TEXT DEFAULT ''`},
+		{"traders", "trigger_settings", `ALTER TABLE traders ADD COLUMN trigger_settings TEXT DEFAULT ''`},
+		{"orders", "price", `ALTER TABLE orders ADD COLUMN price DECIMAL(24,8) DEFAULT 0`},
+
+		// 功能标志的灰度分桶方式（为空表示legacy，保持已有灰度用户不变）
+		{"feature_flags", "bucketing", `ALTER TABLE feature_flags ADD COLUMN bucketing TEXT DEFAULT ''`},
+
+		// 交易记录的方向、数量、手续费、资金费和平仓原因（持仓生命周期跟踪）
+		{"trade_records", "side", `ALTER TABLE trade_records ADD COLUMN side TEXT DEFAULT ''`},
+		{"trade_records", "quantity", `ALTER TABLE trade_records ADD COLUMN quantity DECIMAL(24,8) DEFAULT 0`},
+		{"trade_records", "realized_pnl", `ALTER TABLE trade_records ADD COLUMN realized_pnl DECIMAL(24,8) DEFAULT 0`},
+		{"trade_records", "fees", `ALTER TABLE trade_records ADD COLUMN fees DECIMAL(24,8) DEFAULT 0`},
+		{"trade_records", "funding", `ALTER TABLE trade_records ADD COLUMN funding DECIMAL(24,8) DEFAULT 0`},
+		{"trade_records", "close_reason", `ALTER TABLE trade_records ADD COLUMN close_reason TEXT DEFAULT ''`},
+
 		// 注意: traders表的大部分列已在migration.sql中定义:
 		// custom_prompt, override_base_prompt, is_cross_margin,
 		// system_prompt_template, btc_eth_leverage, altcoin_leverage,
@@ -510,6 +680,14 @@ func (d *Database) alterTables() error {
 	indexQueries := []string{
 		`CREATE INDEX IF NOT EXISTS idx_trade_records_trader_time ON trade_records(trader_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_trade_records_symbol ON trade_records(symbol)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_trader_time ON orders(trader_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_trader_status ON orders(trader_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_loss_events_trader_time ON loss_events(trader_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_trader_ledger_trader_time ON trader_ledger(trader_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_position_groups_trader_status ON position_groups(trader_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_experiments_user_status ON experiments(user_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_experiment_assignments_experiment ON experiment_assignments(experiment_id, trader_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_news_sources_enabled ON news_sources(enabled)`,
 	}
 
 	for _, query := range indexQueries {
@@ -550,6 +728,10 @@ func (d *Database) initDefaultData() error {
 	}{
 		{"deepseek", "DeepSeek", "deepseek"},
 		{"qwen", "Qwen", "qwen"},
+		{"openai", "OpenAI", "openai"},
+		{"anthropic", "Anthropic", "anthropic"},
+		{"gemini", "Gemini", "gemini"},
+		{"local", "Local (OpenAI兼容)", "local"},
 	}
 
 	// 需要初始化模型的用户列表
@@ -576,6 +758,7 @@ func (d *Database) initDefaultData() error {
 		{"hyperliquid", "Hyperliquid", "dex"},
 		{"aster", "Aster DEX", "dex"},
 		{"okx", "OKX Futures", "cex"},
+		{"paper", "Paper Trading", "cex"},
 	}
 
 	for _, exchange := range exchanges {
@@ -633,11 +816,97 @@ func (d *Database) initDefaultData() error {
 
 		"trading_decision_points_cost": "1",
 
+		"ai_tool_calling":   "", // AI结构化输出模式: on/off，为空时按Provider自动判断
+		"ai_fallback_model": "", // 备用AI模型ID（主模型超时或失败时切换），为空表示不启用
+
+		// ==================== 事件驱动触发器 ====================
+		// 在定时周期之外按事件提前触发决策（默认关闭）
+		"trigger_enabled":        "false",
+		"trigger_price_move_pct": "2",    // 持仓币种价格波动超过该百分比时触发
+		"trigger_funding_flip":   "true", // 持仓币种资金费率正负反转时触发
+		"trigger_oi_spike_pct":   "20",   // OI Top持仓量增长超过该百分比时触发
+		// 高影响力新闻关键词（逗号分隔，匹配标题和摘要）
+		"trigger_news_keywords":        "SEC,ETF,hack,exploit,FOMC,CPI,rate cut,rate hike,监管,黑客,美联储,降息,加息",
+		"trigger_min_interval_minutes": "5",  // 两次决策的最小间隔（限流）
+		"trigger_debounce_seconds":     "30", // 事件合并窗口（防抖）
+
+		// ==================== 开仓执行方式 ====================
+		// 交易员未配置执行方式时使用市价单；以下参数作用于限价/Maker/TWAP执行
+		"execution_limit_timeout_seconds": "30",   // 限价单等待成交的最长时间，超时撤单
+		"execution_market_fallback":       "true", // 限价单超时未成交部分是否转市价
+		"execution_twap_slices":           "4",    // TWAP拆分笔数
+		"execution_twap_interval_seconds": "20",   // TWAP每笔之间的间隔
+		"execution_twap_min_notional_usd": "2000", // 仓位价值低于该值时不拆分，按单笔限价执行
+
+		// ==================== 开仓前风控闸门 ====================
+		// 日亏损/最大回撤上限使用 max_daily_loss / max_drawdown，以下为断路器的其余限制
+		"risk_max_consecutive_losses": "5",  // 连续亏损笔数达到该值时断路器触发（需手动重置）
+		"risk_max_weekly_loss_pct":    "20", // 周亏损百分比上限（下周自动恢复）
+		// 按学习阶段限制杠杆、单笔/日亏损和并发仓位（婴儿期仅允许1倍杠杆，默认关闭）
+		"risk_constraints_enabled": "false",
+
+		// ==================== 组合风控 ====================
+		// 汇总同一用户所有交易员的持仓（共享同一交易所账户的只计一次），倍数相对于用户总净值
+		"portfolio_risk_enabled":         "true",
+		"portfolio_max_gross_leverage":   "10", // 总名义价值（多+空）上限
+		"portfolio_max_net_leverage":     "10", // 净名义价值（多-空）上限
+		"portfolio_max_symbol_exposure":  "10", // 单币种净名义价值上限
+		"portfolio_max_margin_usage_pct": "90", // 保证金占用上限（%）
+		"portfolio_min_downsize_pct":     "20", // 剩余额度低于请求仓位该百分比时拦截而不是缩减
+
+		// ==================== 仓位计算 ====================
+		// AI只决定方向和信心度，开仓仓位由服务端按单笔风险预算、ATR、凯利比例和币种配置核定
+		"sizing_mode":               "recompute", // off=使用AI仓位, clamp=AI仓位作为上限, recompute=重新计算
+		"sizing_risk_per_trade_pct": "1",         // 单笔风险预算（止损亏损占净值的百分比，交易员可单独配置）
+		"sizing_atr_stop_multiple":  "1.5",       // 按4小时ATR14的倍数估算最小止损距离
+		"sizing_kelly_fraction":     "1",         // 凯利比例使用系数（保证金上限 = 净值 × 凯利比例 × 系数）
+
+		// ==================== 强平距离保护 ====================
+		// 决策周期之间按实时价格检查持仓距强平价的距离（距离 = |标记价 - 强平价| / 标记价）
+		"liquidation_guard_enabled":          "true",
+		"liquidation_warn_distance_pct":      "10", // 低于该百分比时告警并补设保护止损
+		"liquidation_critical_distance_pct":  "5",  // 低于该百分比时部分平仓，保护止损设在该距离处
+		"liquidation_reduce_pct":             "50", // 部分平仓比例（%）
+		"liquidation_check_interval_seconds": "10", // 检查间隔
+		"liquidation_cooldown_minutes":       "5",  // 同一持仓两次处置的最小间隔
+
+		// ==================== 跨交易所路由 ====================
+		// 交易员配置了多个交易所账户时，开仓按盘口价格+手续费+盘口深度选择交易所
+		"routing_failure_threshold":         "3",   // 连续失败次数达到后暂停该交易所
+		"routing_failover_cooldown_seconds": "120", // 暂停时长（期间路由到其他交易所）
+		// 各交易所吃单费率（未列出的交易所按0.05%计算）
+		"routing_taker_fees": `{"binance":0.0005,"okx":0.0005,"hyperliquid":0.00045,"aster":0.00035}`,
+
+		// ==================== 交易时段 ====================
+		// 交易员配置了交易时段时，时段外和停牌事件前后只管理现有持仓，不开新仓
+		"blackout_calendar_file":  "calendar/blackout_events.json", // 停牌日历（FOMC、CPI等事件时间）
+		"blackout_before_minutes": "30",                            // 事件前禁止开仓分钟数（交易员或事件可覆盖）
+		"blackout_after_minutes":  "30",                            // 事件后禁止开仓分钟数
+
+		// ==================== 跟踪止损 ====================
+		// 百分比均为相对开仓价/峰值价的价格变动（不含杠杆）
+		"trailing_stop_enabled":      "true",
+		"trailing_stop_pct":          "3",   // 止损距峰值价的百分比
+		"trailing_stop_atr_multiple": "0",   // >0时改用4小时ATR14的倍数作为跟踪距离
+		"trailing_activation_pct":    "2",   // 峰值盈利达到该百分比后开始跟踪
+		"break_even_trigger_pct":     "1",   // 峰值盈利达到该百分比后止损移至保本
+		"break_even_offset_pct":      "0.1", // 保本止损相对开仓价的偏移（覆盖手续费）
+		"trailing_min_move_pct":      "0.2", // 新止损与当前止损相差小于该百分比时不更新交易所委托
+
 		// ==================== Mem0 AI 模型选择配置 ====================
 		// 指定Mem0的理解模型（用于生成完整决策的AI理解能力）
 		"mem0_understanding_model": "gemini",  // 默认使用Gemini，可选: "gpt-4", "deepseek"
 		"mem0_fallback_model": "gpt-4",        // 如果主模型失败，自动降级到此模型
 
+		// ==================== Mem0 本地存储 ====================
+		// postgres后端把记忆和向量保存在本库的mem0_memories表中，不发送到第三方
+		"mem0_store_backend":      "http", // http: 托管Mem0服务, postgres: 本地数据库
+		"mem0_embedding_provider": "hash", // hash: 本地哈希向量, openai: OpenAI兼容接口（可指向自建服务）
+		"mem0_embedding_api_url":  "",
+		"mem0_embedding_api_key":  "",
+		"mem0_embedding_model":    "",
+		"mem0_vector_search":      "auto", // auto: 有pgvector扩展时使用, pgvector: 必须使用, go: 在Go中计算相似度
+
 		// ==================== Gemini AI 模型配置 ====================
 		// 核心开关
 		"gemini_enabled": "false",
@@ -939,6 +1208,7 @@ type TraderRecord struct {
 	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
 	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
 	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
+	TradingSchedule      string    `json:"trading_schedule"`       // 交易时段和停牌事件配置（JSON，为空表示不限制）
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -1452,6 +1722,15 @@ func (d *Database) GetAIModels(userID string) ([]*AIModelConfig, error) {
 	})
 }
 
+// isBuiltinAIProvider 是否为内置AI提供商（ID与provider同名）
+func isBuiltinAIProvider(provider string) bool {
+	switch provider {
+	case "deepseek", "qwen", "openai", "anthropic", "gemini", "local":
+		return true
+	}
+	return false
+}
+
 // UpdateAIModel 更新AI模型配置，如果不存在则创建用户特定配置
 func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, customAPIURL, customModelName string) error {
 	// 先尝试精确匹配 ID（新版逻辑，支持多个相同 provider 的模型）
@@ -1487,7 +1766,7 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 
 	// 没有找到任何现有配置，创建新的
 	// 推断 provider（从 id 中提取，或者直接使用 id）
-	if provider == id && (provider == "deepseek" || provider == "qwen") {
+	if provider == id && isBuiltinAIProvider(provider) {
 		// id 本身就是 provider
 		provider = id
 	} else {
@@ -1615,6 +1894,9 @@ func (d *Database) UpdateExchange(userID, id string, enabled bool, apiKey, secre
 		} else if id == "okx" {
 			name = "OKX Futures"
 			typ = "cex"
+		} else if id == "paper" {
+			name = "Paper Trading"
+			typ = "cex"
 		} else {
 			name = id + " Exchange"
 			typ = "cex"
@@ -1675,9 +1957,9 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
 	_, err := d.exec(`
-                INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-        `, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
+                INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, trading_schedule)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+        `, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TradingSchedule)
 	return err
 }
 
@@ -1691,7 +1973,8 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
                                COALESCE(use_coin_pool, false) as use_coin_pool, COALESCE(use_oi_top, false) as use_oi_top,
                                COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, false) as override_base_prompt,
                                COALESCE(system_prompt_template, 'default') as system_prompt_template,
-                               COALESCE(is_cross_margin, true) as is_cross_margin, created_at, updated_at
+                               COALESCE(is_cross_margin, true) as is_cross_margin,
+                               COALESCE(trading_schedule, '') as trading_schedule, created_at, updated_at
                         FROM traders WHERE user_id = $1 ORDER BY created_at DESC
                 `, userID)
 		if err != nil {
@@ -1708,7 +1991,7 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 				&trader.BTCETHLeverage, &trader.AltcoinLeverage, &trader.TradingSymbols,
 				&trader.UseCoinPool, &trader.UseOITop,
 				&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-				&trader.IsCrossMargin,
+				&trader.IsCrossMargin, &trader.TradingSchedule,
 				&trader.CreatedAt, &trader.UpdatedAt,
 			)
 			if err != nil {
@@ -1736,12 +2019,12 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
                         name = ?, ai_model_id = ?, exchange_id = ?, initial_balance = ?,
                         scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
                         trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
-                        system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+                        system_prompt_template = ?, is_cross_margin = ?, trading_schedule = ?, updated_at = CURRENT_TIMESTAMP
                 WHERE id = ? AND user_id = ?
         `, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
+		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TradingSchedule, trader.ID, trader.UserID)
 	return err
 }
 
@@ -1751,6 +2034,94 @@ func (d *Database) UpdateTraderCustomPrompt(userID, id string, customPrompt stri
 	return err
 }
 
+// GetTraderEnsemble 获取交易员的多模型投票配置（参与投票的AI模型ID和合并策略）
+func (d *Database) GetTraderEnsemble(traderID string) ([]string, string, error) {
+	var modelIDs, policy string
+	err := d.queryRow(`
+		SELECT COALESCE(ensemble_model_ids, ''), COALESCE(ensemble_policy, '')
+		FROM traders WHERE id = $1
+	`, traderID).Scan(&modelIDs, &policy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var ids []string
+	for _, id := range strings.Split(modelIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, policy, nil
+}
+
+// UpdateTraderEnsemble 更新交易员的多模型投票配置
+func (d *Database) UpdateTraderEnsemble(userID, id string, modelIDs []string, policy string) error {
+	_, err := d.exec(`UPDATE traders SET ensemble_model_ids = ?, ensemble_policy = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`,
+		strings.Join(modelIDs, ","), policy, id, userID)
+	return err
+}
+
+// GetTraderExecutionMode 获取交易员的开仓执行方式（为空表示市价单）
+func (d *Database) GetTraderExecutionMode(traderID string) (string, error) {
+	var mode string
+	err := d.queryRow(`SELECT COALESCE(execution_mode, '') FROM traders WHERE id = $1`, traderID).Scan(&mode)
+	return mode, err
+}
+
+// UpdateTraderExecutionMode 更新交易员的开仓执行方式
+func (d *Database) UpdateTraderExecutionMode(userID, id, mode string) error {
+	_, err := d.exec(`UPDATE traders SET execution_mode = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, mode, id, userID)
+	return err
+}
+
+// GetTraderTriggerSettings 获取交易员的触发器设置（JSON，为空表示沿用系统配置）
+func (d *Database) GetTraderTriggerSettings(traderID string) (string, error) {
+	var settings string
+	err := d.queryRow(`SELECT COALESCE(trigger_settings, '') FROM traders WHERE id = $1`, traderID).Scan(&settings)
+	return settings, err
+}
+
+// UpdateTraderTriggerSettings 更新交易员的触发器设置
+func (d *Database) UpdateTraderTriggerSettings(userID, id, settings string) error {
+	_, err := d.exec(`UPDATE traders SET trigger_settings = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, settings, id, userID)
+	return err
+}
+
+// GetTraderRiskPerTrade 获取交易员的单笔风险预算百分比（0表示使用系统默认值）
+func (d *Database) GetTraderRiskPerTrade(traderID string) (float64, error) {
+	var pct float64
+	err := d.queryRow(`SELECT COALESCE(risk_per_trade_pct, 0) FROM traders WHERE id = $1`, traderID).Scan(&pct)
+	return pct, err
+}
+
+// UpdateTraderRiskPerTrade 更新交易员的单笔风险预算百分比
+func (d *Database) UpdateTraderRiskPerTrade(userID, id string, pct float64) error {
+	_, err := d.exec(`UPDATE traders SET risk_per_trade_pct = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, pct, id, userID)
+	return err
+}
+
+// GetTraderRouteExchanges 获取交易员参与跨交易所路由的额外交易所（不含主交易所）
+func (d *Database) GetTraderRouteExchanges(traderID string) ([]string, error) {
+	var value string
+	err := d.queryRow(`SELECT COALESCE(route_exchanges, '') FROM traders WHERE id = $1`, traderID).Scan(&value)
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []string
+	for _, ex := range strings.Split(value, ",") {
+		if ex = strings.TrimSpace(ex); ex != "" {
+			exchanges = append(exchanges, ex)
+		}
+	}
+	return exchanges, nil
+}
+
+// UpdateTraderRouteExchanges 更新交易员参与跨交易所路由的额外交易所
+func (d *Database) UpdateTraderRouteExchanges(userID, id string, exchanges []string) error {
+	_, err := d.exec(`UPDATE traders SET route_exchanges = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, strings.Join(exchanges, ","), id, userID)
+	return err
+}
+
 // DeleteTrader 删除交易员
 func (d *Database) DeleteTrader(userID, id string) error {
 	_, err := d.exec(`DELETE FROM traders WHERE id = ? AND user_id = ?`, id, userID)
@@ -1774,6 +2145,7 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		err := d.queryRow(`
                         SELECT 
                                 t.id, t.user_id, t.name, t.ai_model_id, t.exchange_id, t.initial_balance, t.scan_interval_minutes, t.is_running, t.created_at, t.updated_at,
+                                COALESCE(t.trading_schedule, '') as trading_schedule,
                                 a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key, a.created_at, a.updated_at,
                                 e.id, e.user_id, e.name, e.type, e.enabled, e.api_key, e.secret_key, e.testnet,
                                 COALESCE(e.hyperliquid_wallet_addr, '') as hyperliquid_wallet_addr,
@@ -1789,6 +2161,7 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			&trader.ID, &trader.UserID, &trader.Name, &trader.AIModelID, &trader.ExchangeID,
 			&trader.InitialBalance, &trader.ScanIntervalMinutes, &trader.IsRunning,
 			&trader.CreatedAt, &trader.UpdatedAt,
+			&trader.TradingSchedule,
 			&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 			&aiModel.CreatedAt, &aiModel.UpdatedAt,
 			&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.Type, &exchange.Enabled,
@@ -1831,6 +2204,18 @@ func (d *Database) GetSystemConfig(key string) (string, error) {
 	})
 }
 
+// SystemConfigValue 读取系统配置项并去除首尾空白（db为nil、配置不存在或读取失败时返回空字符串）
+func (d *Database) SystemConfigValue(key string) string {
+	if d == nil {
+		return ""
+	}
+	value, err := d.GetSystemConfig(key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
 // SetSystemConfig 设置系统配置
 func (d *Database) SetSystemConfig(key, value string) error {
 	_, err := d.exec(`
@@ -2221,13 +2606,15 @@ func (d *Database) GetTraderByID(traderID string) (*TraderRecord, error) {
         			SELECT id, user_id, name, ai_model_id, exchange_id, initial_balance,
         			       btc_eth_leverage, altcoin_leverage, trading_symbols,
         			       custom_prompt, override_base_prompt, system_prompt_template,
-        			       is_cross_margin, scan_interval_minutes, is_running
+        			       is_cross_margin, scan_interval_minutes, is_running,
+        			       COALESCE(trading_schedule, '')
         			FROM traders WHERE id = $1
         		`, traderID).Scan(
 		&trader.ID, &trader.UserID, &trader.Name, &trader.AIModelID, &trader.ExchangeID, &trader.InitialBalance,
 		&trader.BTCETHLeverage, &trader.AltcoinLeverage, &trader.TradingSymbols,
 		&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
 		&trader.IsCrossMargin, &trader.ScanIntervalMinutes, &trader.IsRunning,
+		&trader.TradingSchedule,
 	)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("trader %s not found", traderID)
@@ -2305,6 +2692,206 @@ func (d *Database) GetTradesInPeriod(traderID string, startDate, endDate time.Ti
 
 }
 
+// SaveOrder 保存订单状态（插入或更新）
+func (d *Database) SaveOrder(record database.OrderRecord) error {
+	return database.NewOrderRepository(d.db).UpsertOrder(record)
+}
+
+// GetActiveOrders 获取trader未完结的订单
+func (d *Database) GetActiveOrders(traderID string) ([]database.OrderRecord, error) {
+	return database.NewOrderRepository(d.db).GetActiveOrders(traderID)
+}
+
+// GetOrders 获取trader的订单列表（status为空时返回全部状态）
+func (d *Database) GetOrders(traderID, status string, limit int) ([]database.OrderRecord, error) {
+	return database.NewOrderRepository(d.db).GetOrders(traderID, status, limit)
+}
+
+// SaveTradeRecord 保存平仓交易记录
+func (d *Database) SaveTradeRecord(record database.TradeRecord) error {
+	return database.NewTradeRepository(d.db).InsertTradeRecord(record)
+}
+
+// SaveLossEvent 保存亏损事件（风控断路器状态变化）
+func (d *Database) SaveLossEvent(event *database.LossEvent) error {
+	return database.NewLossEventRepository(d.db).Save(event)
+}
+
+// GetLatestLossEvent 获取trader最新的亏损事件（没有记录时返回nil）
+func (d *Database) GetLatestLossEvent(traderID string) (*database.LossEvent, error) {
+	return database.NewLossEventRepository(d.db).GetLatest(traderID)
+}
+
+// GetRecentLossEvents 获取trader最近的亏损事件
+func (d *Database) GetRecentLossEvents(traderID string, limit int) ([]*database.LossEvent, error) {
+	return database.NewLossEventRepository(d.db).GetRecent(traderID, limit)
+}
+
+// SavePositionPeak 保存持仓峰值（插入或更新）
+func (d *Database) SavePositionPeak(peak database.PositionPeak) error {
+	return database.NewPositionPeakRepository(d.db).Upsert(peak)
+}
+
+// GetPositionPeaks 获取trader所有持仓的峰值记录
+func (d *Database) GetPositionPeaks(traderID string) ([]database.PositionPeak, error) {
+	return database.NewPositionPeakRepository(d.db).GetByTrader(traderID)
+}
+
+// DeletePositionPeak 删除持仓峰值记录
+func (d *Database) DeletePositionPeak(traderID, symbol, side string) error {
+	return database.NewPositionPeakRepository(d.db).Delete(traderID, symbol, side)
+}
+
+// SaveLedgerEntries 保存手续费/资金费流水（重复的交易所流水跳过）
+func (d *Database) SaveLedgerEntries(entries []database.LedgerEntry) (int, error) {
+	return database.NewLedgerRepository(d.db).Insert(entries)
+}
+
+// GetLedgerSummary 汇总trader在since之后的手续费和资金费
+func (d *Database) GetLedgerSummary(traderID string, since time.Time) (database.LedgerSummary, error) {
+	return database.NewLedgerRepository(d.db).Summary(traderID, since)
+}
+
+// GetLatestLedgerTime 获取trader最近一条费用流水的时间
+func (d *Database) GetLatestLedgerTime(traderID string) (time.Time, error) {
+	return database.NewLedgerRepository(d.db).LatestTime(traderID)
+}
+
+// SavePositionGroup 保存新开的组合持仓
+func (d *Database) SavePositionGroup(g database.PositionGroup) error {
+	return database.NewPositionGroupRepository(d.db).Insert(g)
+}
+
+// GetOpenPositionGroups 获取trader未平仓的组合持仓
+func (d *Database) GetOpenPositionGroups(traderID string) ([]database.PositionGroup, error) {
+	return database.NewPositionGroupRepository(d.db).GetOpen(traderID)
+}
+
+// ClosePositionGroup 标记组合持仓已平仓
+func (d *Database) ClosePositionGroup(id string, realizedPnL float64, reason string, closedAt time.Time) error {
+	return database.NewPositionGroupRepository(d.db).Close(id, realizedPnL, reason, closedAt)
+}
+
+// CreateExperiment 保存新的A/B实验
+func (d *Database) CreateExperiment(e database.Experiment) error {
+	return database.NewExperimentRepository(d.db).Insert(e)
+}
+
+// GetExperiment 获取A/B实验
+func (d *Database) GetExperiment(id string) (*database.Experiment, error) {
+	return database.NewExperimentRepository(d.db).Get(id)
+}
+
+// GetExperiments 获取A/B实验列表（userID/status为空时不过滤）
+func (d *Database) GetExperiments(userID, status string) ([]database.Experiment, error) {
+	return database.NewExperimentRepository(d.db).List(userID, status)
+}
+
+// StopExperiment 停止运行中的A/B实验
+func (d *Database) StopExperiment(id, reason string, stoppedAt time.Time) error {
+	return database.NewExperimentRepository(d.db).Stop(id, reason, stoppedAt)
+}
+
+// SaveExperimentAssignment 保存实验变体分配记录
+func (d *Database) SaveExperimentAssignment(a database.ExperimentAssignment) error {
+	return database.NewExperimentRepository(d.db).InsertAssignment(a)
+}
+
+// GetExperimentAssignments 获取实验的变体分配记录
+func (d *Database) GetExperimentAssignments(experimentID string) ([]database.ExperimentAssignment, error) {
+	return database.NewExperimentRepository(d.db).GetAssignments(experimentID)
+}
+
+// GetFeatureFlags 获取数据库中的所有功能标志
+func (d *Database) GetFeatureFlags() ([]FeatureFlag, error) {
+	records, err := database.NewFeatureFlagRepository(d.db).List()
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]FeatureFlag, 0, len(records))
+	for _, r := range records {
+		flags = append(flags, FeatureFlag{
+			Name:        FeatureFlagType(r.Name),
+			Description: r.Description,
+			Enabled:     r.Enabled,
+			Percentage:  r.Percentage,
+			AllowUsers:  r.AllowUsers,
+			DenyUsers:   r.DenyUsers,
+			Bucketing:   r.Bucketing,
+			Metadata:    r.Metadata,
+			UpdatedBy:   r.UpdatedBy,
+			CreatedAt:   r.CreatedAt,
+			UpdatedAt:   r.UpdatedAt,
+		})
+	}
+	return flags, nil
+}
+
+// SaveFeatureFlag 保存功能标志
+func (d *Database) SaveFeatureFlag(flag FeatureFlag) error {
+	return database.NewFeatureFlagRepository(d.db).Upsert(featureFlagRecord(flag))
+}
+
+// EnsureFeatureFlag 功能标志不存在时写入默认值（不覆盖已有配置）
+func (d *Database) EnsureFeatureFlag(flag FeatureFlag) error {
+	return database.NewFeatureFlagRepository(d.db).InsertIfMissing(featureFlagRecord(flag))
+}
+
+// DeleteFeatureFlag 删除功能标志
+func (d *Database) DeleteFeatureFlag(name FeatureFlagType) error {
+	return database.NewFeatureFlagRepository(d.db).Delete(string(name))
+}
+
+// CreateNewsSource 保存自定义新闻源
+func (d *Database) CreateNewsSource(s database.NewsSource) error {
+	return database.NewNewsSourceRepository(d.db).Insert(s)
+}
+
+// UpdateNewsSource 更新自定义新闻源
+func (d *Database) UpdateNewsSource(s database.NewsSource) error {
+	return database.NewNewsSourceRepository(d.db).Update(s)
+}
+
+// GetNewsSource 获取自定义新闻源
+func (d *Database) GetNewsSource(id string) (*database.NewsSource, error) {
+	return database.NewNewsSourceRepository(d.db).Get(id)
+}
+
+// GetNewsSources 获取用户的新闻源和系统新闻源（userID为空时返回全部）
+func (d *Database) GetNewsSources(userID string) ([]database.NewsSource, error) {
+	if userID == "" {
+		return database.NewNewsSourceRepository(d.db).ListAll()
+	}
+	return database.NewNewsSourceRepository(d.db).ListForUser(userID)
+}
+
+// GetEnabledNewsSources 获取所有已启用的自定义新闻源
+func (d *Database) GetEnabledNewsSources() ([]database.NewsSource, error) {
+	return database.NewNewsSourceRepository(d.db).ListEnabled()
+}
+
+// DeleteNewsSource 删除自定义新闻源
+func (d *Database) DeleteNewsSource(id string) error {
+	return database.NewNewsSourceRepository(d.db).Delete(id)
+}
+
+// featureFlagRecord 转换为功能标志数据库记录
+func featureFlagRecord(flag FeatureFlag) database.FeatureFlagRecord {
+	return database.FeatureFlagRecord{
+		Name:        string(flag.Name),
+		Description: flag.Description,
+		Enabled:     flag.Enabled,
+		Percentage:  flag.Percentage,
+		AllowUsers:  flag.AllowUsers,
+		DenyUsers:   flag.DenyUsers,
+		Bucketing:   flag.Bucketing,
+		Metadata:    flag.Metadata,
+		UpdatedBy:   flag.UpdatedBy,
+		CreatedAt:   flag.CreatedAt,
+		UpdatedAt:   flag.UpdatedAt,
+	}
+}
+
 // SaveReflection 保存反思记录
 
 func (d *Database) SaveReflection(r *ReflectionRecord) error {
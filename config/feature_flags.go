@@ -3,6 +3,8 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"log"
 	"os"
 	"sync"
 	"time"
@@ -13,37 +15,79 @@ type FeatureFlagType string
 
 const (
 	// 新闻相关功能标志
-	NewsAutoFetchEnabled         FeatureFlagType = "news.auto_fetch_enabled"
+	NewsAutoFetchEnabled          FeatureFlagType = "news.auto_fetch_enabled"
 	NewsPromptInjectionProtection FeatureFlagType = "news.prompt_injection_protection"
 	NewsCircuitBreakerEnabled     FeatureFlagType = "news.circuit_breaker_enabled"
 	NewsCacheEnabled              FeatureFlagType = "news.cache_enabled"
+	NewsDecisionEnrichment        FeatureFlagType = "news.decision_enrichment" // 决策prompt中注入新闻
+
+	// 风险较高的子系统（按交易员所属用户灰度）
+	Mem0Enabled          FeatureFlagType = "mem0.enabled"   // 历史交易记忆注入
+	ExchangeOKXEnabled   FeatureFlagType = "exchange.okx"   // OKX交易所
+	ExchangePaperEnabled FeatureFlagType = "exchange.paper" // 纸面交易
 
 	// 其他功能标志
-	BetaModeEnabled FeatureFlagType = "beta_mode"
+	BetaModeEnabled  FeatureFlagType = "beta_mode"
 	AdminModeEnabled FeatureFlagType = "admin_mode"
 )
 
+// 灰度分桶方式（决定百分比灰度时哪些用户落在灰度范围内）
+const (
+	// BucketingLegacy 原有分桶：只按用户ID哈希，所有标志的灰度用户相同。
+	// 未设置分桶方式的标志（旧版本保存的配置文件/数据库记录）使用该方式，升级后灰度用户保持不变
+	BucketingLegacy = "legacy"
+	// BucketingSalted 按标志名加盐的FNV-1a哈希，不同标志的灰度用户相互独立。
+	// 内置标志和新建的标志使用该方式；已有标志需由管理员显式切换（切换后部分用户会进出灰度范围）
+	BucketingSalted = "salted"
+)
+
+// FeatureFlagReloadInterval 多实例部署时从数据库重载功能标志的间隔
+const FeatureFlagReloadInterval = 30 * time.Second
+
+// legacyFlagKeys 由system_config开关迁移而来的功能标志（首次写入数据库时沿用原配置）
+var legacyFlagKeys = map[FeatureFlagType]string{
+	Mem0Enabled: "mem0_enabled",
+}
+
 // FeatureFlag 功能标志结构
 type FeatureFlag struct {
-	Name        FeatureFlagType `json:"name"`
-	Description string          `json:"description"`
-	Enabled     bool            `json:"enabled"`
-	Percentage  int             `json:"percentage"` // 灰度发布百分比 (0-100)
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
+	Name        FeatureFlagType        `json:"name"`
+	Description string                 `json:"description"`
+	Enabled     bool                   `json:"enabled"`
+	Percentage  int                    `json:"percentage"`            // 灰度发布百分比 (0-100)
+	AllowUsers  []string               `json:"allow_users,omitempty"` // 始终启用的用户（不受百分比限制）
+	DenyUsers   []string               `json:"deny_users,omitempty"`  // 始终禁用的用户（优先于白名单）
+	Bucketing   string                 `json:"bucketing,omitempty"`   // 灰度分桶方式（legacy/salted，为空时为legacy）
+	UpdatedBy   string                 `json:"updated_by,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"` // 自定义元数据
 }
 
+// FeatureFlagStore 功能标志持久化存储
+type FeatureFlagStore interface {
+	GetFeatureFlags() ([]FeatureFlag, error)
+	SaveFeatureFlag(flag FeatureFlag) error
+	EnsureFeatureFlag(flag FeatureFlag) error
+	DeleteFeatureFlag(name FeatureFlagType) error
+	GetSystemConfig(key string) (string, error)
+}
+
 // FeatureFlagManager 功能标志管理器
 type FeatureFlagManager struct {
 	flags map[FeatureFlagType]*FeatureFlag
 	mu    sync.RWMutex
+
+	store    FeatureFlagStore // 为nil时只保存在内存中
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 // NewFeatureFlagManager 创建功能标志管理器
 func NewFeatureFlagManager() *FeatureFlagManager {
 	manager := &FeatureFlagManager{
-		flags: make(map[FeatureFlagType]*FeatureFlag),
+		flags:  make(map[FeatureFlagType]*FeatureFlag),
+		stopCh: make(chan struct{}),
 	}
 
 	// 初始化默认功能标志
@@ -52,64 +96,197 @@ func NewFeatureFlagManager() *FeatureFlagManager {
 	return manager
 }
 
-// initializeDefaultFlags 初始化默认功能标志
-func (fm *FeatureFlagManager) initializeDefaultFlags() {
-	defaultFlags := []FeatureFlag{
+// NewFeatureFlagManagerWithStore 创建数据库持久化的功能标志管理器
+// 数据库中缺失的默认标志会被写入（不覆盖管理员的修改），之后以数据库中的配置为准
+func NewFeatureFlagManagerWithStore(store FeatureFlagStore) (*FeatureFlagManager, error) {
+	manager := NewFeatureFlagManager()
+	manager.store = store
+
+	for _, flag := range defaultFeatureFlags() {
+		if key, ok := legacyFlagKeys[flag.Name]; ok {
+			if value, _ := store.GetSystemConfig(key); value == "true" {
+				flag.Enabled = true
+				flag.Percentage = 100
+			}
+		}
+		if err := store.EnsureFeatureFlag(flag); err != nil {
+			return nil, fmt.Errorf("初始化功能标志失败: %w", err)
+		}
+	}
+
+	if err := manager.Reload(); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// defaultFeatureFlags 默认功能标志
+func defaultFeatureFlags() []FeatureFlag {
+	now := time.Now()
+	return []FeatureFlag{
 		{
 			Name:        NewsAutoFetchEnabled,
 			Description: "启用新闻自动抓取功能",
 			Enabled:     true,
 			Percentage:  100,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 		{
 			Name:        NewsPromptInjectionProtection,
 			Description: "启用新闻提示词注入防护",
 			Enabled:     true,
 			Percentage:  100,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 		{
 			Name:        NewsCircuitBreakerEnabled,
 			Description: "启用新闻API熔断器保护",
 			Enabled:     true,
 			Percentage:  100,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 		{
 			Name:        NewsCacheEnabled,
 			Description: "启用新闻缓存功能",
 			Enabled:     true,
 			Percentage:  100,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		{
+			Name:        NewsDecisionEnrichment,
+			Description: "在AI决策prompt中注入相关新闻",
+			Enabled:     true,
+			Percentage:  100,
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		{
+			Name:        Mem0Enabled,
+			Description: "在AI决策prompt中注入相似行情下的历史交易记忆",
+			Enabled:     false,
+			Percentage:  0,
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		{
+			Name:        ExchangeOKXEnabled,
+			Description: "允许交易员使用OKX交易所",
+			Enabled:     true,
+			Percentage:  100,
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		{
+			Name:        ExchangePaperEnabled,
+			Description: "允许交易员使用纸面交易",
+			Enabled:     true,
+			Percentage:  100,
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 		{
 			Name:        BetaModeEnabled,
 			Description: "启用测试版本功能",
 			Enabled:     false,
 			Percentage:  0,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 		{
 			Name:        AdminModeEnabled,
 			Description: "启用管理员模式",
 			Enabled:     false,
 			Percentage:  0,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			Bucketing:   BucketingSalted,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 	}
+}
 
+// initializeDefaultFlags 初始化默认功能标志
+func (fm *FeatureFlagManager) initializeDefaultFlags() {
+	defaultFlags := defaultFeatureFlags()
 	for i := range defaultFlags {
 		fm.flags[defaultFlags[i].Name] = &defaultFlags[i]
 	}
 }
 
+// IsBuiltinFlag 是否为内置功能标志（内置标志不可删除）
+func IsBuiltinFlag(name FeatureFlagType) bool {
+	for _, flag := range defaultFeatureFlags() {
+		if flag.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload 从数据库重新加载功能标志（数据库中缺失的内置标志保留当前值）
+func (fm *FeatureFlagManager) Reload() error {
+	if fm.store == nil {
+		return nil
+	}
+	flags, err := fm.store.GetFeatureFlags()
+	if err != nil {
+		return fmt.Errorf("加载功能标志失败: %w", err)
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	next := make(map[FeatureFlagType]*FeatureFlag, len(flags))
+	for name, flag := range fm.flags {
+		if IsBuiltinFlag(name) {
+			next[name] = flag
+		}
+	}
+	for i := range flags {
+		next[flags[i].Name] = &flags[i]
+	}
+	fm.flags = next
+	return nil
+}
+
+// StartAutoReload 定时从数据库重载功能标志（使其他实例的修改生效）
+func (fm *FeatureFlagManager) StartAutoReload(interval time.Duration) {
+	if fm.store == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-fm.stopCh:
+				return
+			case <-ticker.C:
+				if err := fm.Reload(); err != nil {
+					log.Printf("⚠️ 重载功能标志失败，继续使用当前配置: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定时重载
+func (fm *FeatureFlagManager) Stop() {
+	fm.stopOnce.Do(func() { close(fm.stopCh) })
+}
+
 // IsEnabled 检查功能标志是否启用
 func (fm *FeatureFlagManager) IsEnabled(name FeatureFlagType) bool {
 	fm.mu.RLock()
@@ -124,6 +301,7 @@ func (fm *FeatureFlagManager) IsEnabled(name FeatureFlagType) bool {
 }
 
 // IsEnabledForUser 检查功能标志是否对特定用户启用（用于灰度发布）
+// 顺序：总开关 → 黑名单 → 白名单 → 按用户哈希的灰度百分比
 func (fm *FeatureFlagManager) IsEnabledForUser(name FeatureFlagType, userID string) bool {
 	fm.mu.RLock()
 	defer fm.mu.RUnlock()
@@ -137,6 +315,13 @@ func (fm *FeatureFlagManager) IsEnabledForUser(name FeatureFlagType, userID stri
 		return false
 	}
 
+	if containsUser(flag.DenyUsers, userID) {
+		return false
+	}
+	if containsUser(flag.AllowUsers, userID) {
+		return true
+	}
+
 	// 如果百分比是100，则对所有用户启用
 	if flag.Percentage >= 100 {
 		return true
@@ -148,12 +333,51 @@ func (fm *FeatureFlagManager) IsEnabledForUser(name FeatureFlagType, userID stri
 	}
 
 	// 根据userID的哈希值决定灰度发布
-	hashValue := hashUserID(userID)
-	return (hashValue % 100) < flag.Percentage
+	return userBucket(flag, userID) < flag.Percentage
+}
+
+// userBucket 用户在该标志下的灰度分桶（0-99）
+func userBucket(flag *FeatureFlag, userID string) int {
+	if flag.Bucketing == BucketingSalted {
+		return saltedHashUserID(string(flag.Name), userID)
+	}
+	return hashUserID(userID) % 100
 }
 
 // SetEnabled 设置功能标志的启用状态
 func (fm *FeatureFlagManager) SetEnabled(name FeatureFlagType, enabled bool) error {
+	return fm.update(name, func(flag *FeatureFlag) error {
+		flag.Enabled = enabled
+		return nil
+	})
+}
+
+// SetPercentage 设置功能标志的灰度发布百分比
+func (fm *FeatureFlagManager) SetPercentage(name FeatureFlagType, percentage int) error {
+	return fm.update(name, func(flag *FeatureFlag) error {
+		if percentage < 0 || percentage > 100 {
+			return fmt.Errorf("百分比必须在0-100之间: %d", percentage)
+		}
+		flag.Percentage = percentage
+		return nil
+	})
+}
+
+// SetMetadata 设置功能标志的元数据
+func (fm *FeatureFlagManager) SetMetadata(name FeatureFlagType, key string, value interface{}) error {
+	return fm.update(name, func(flag *FeatureFlag) error {
+		metadata := make(map[string]interface{}, len(flag.Metadata)+1)
+		for k, v := range flag.Metadata {
+			metadata[k] = v
+		}
+		metadata[key] = value
+		flag.Metadata = metadata
+		return nil
+	})
+}
+
+// update 修改功能标志（先写数据库，成功后再更新内存）
+func (fm *FeatureFlagManager) update(name FeatureFlagType, apply func(flag *FeatureFlag) error) error {
 	fm.mu.Lock()
 	defer fm.mu.Unlock()
 
@@ -162,49 +386,73 @@ func (fm *FeatureFlagManager) SetEnabled(name FeatureFlagType, enabled bool) err
 		return fmt.Errorf("功能标志不存在: %s", name)
 	}
 
-	flag.Enabled = enabled
-	flag.UpdatedAt = time.Now()
+	updated := *flag
+	if err := apply(&updated); err != nil {
+		return err
+	}
+	updated.UpdatedAt = time.Now()
 
+	if fm.store != nil {
+		if err := fm.store.SaveFeatureFlag(updated); err != nil {
+			return fmt.Errorf("保存功能标志失败: %w", err)
+		}
+	}
+	fm.flags[name] = &updated
 	return nil
 }
 
-// SetPercentage 设置功能标志的灰度发布百分比
-func (fm *FeatureFlagManager) SetPercentage(name FeatureFlagType, percentage int) error {
+// SaveFlag 创建或整体更新功能标志（保留原创建时间）
+func (fm *FeatureFlagManager) SaveFlag(flag FeatureFlag) (*FeatureFlag, error) {
+	if flag.Name == "" {
+		return nil, fmt.Errorf("功能标志名称不能为空")
+	}
+	if flag.Percentage < 0 || flag.Percentage > 100 {
+		return nil, fmt.Errorf("百分比必须在0-100之间: %d", flag.Percentage)
+	}
+	if flag.Bucketing != "" && flag.Bucketing != BucketingLegacy && flag.Bucketing != BucketingSalted {
+		return nil, fmt.Errorf("未知的灰度分桶方式: %s", flag.Bucketing)
+	}
+
 	fm.mu.Lock()
 	defer fm.mu.Unlock()
 
-	flag, exists := fm.flags[name]
-	if !exists {
-		return fmt.Errorf("功能标志不存在: %s", name)
+	now := time.Now()
+	flag.CreatedAt, flag.UpdatedAt = now, now
+	if existing, ok := fm.flags[flag.Name]; ok {
+		flag.CreatedAt = existing.CreatedAt
+	} else if flag.Bucketing == "" {
+		flag.Bucketing = BucketingSalted // 新建的标志没有已有灰度用户，直接使用加盐分桶
 	}
 
-	if percentage < 0 || percentage > 100 {
-		return fmt.Errorf("百分比必须在0-100之间: %d", percentage)
+	if fm.store != nil {
+		if err := fm.store.SaveFeatureFlag(flag); err != nil {
+			return nil, fmt.Errorf("保存功能标志失败: %w", err)
+		}
 	}
+	fm.flags[flag.Name] = &flag
 
-	flag.Percentage = percentage
-	flag.UpdatedAt = time.Now()
-
-	return nil
+	flagCopy := flag
+	return &flagCopy, nil
 }
 
-// SetMetadata 设置功能标志的元数据
-func (fm *FeatureFlagManager) SetMetadata(name FeatureFlagType, key string, value interface{}) error {
+// DeleteFlag 删除自定义功能标志（内置标志只能禁用，不能删除）
+func (fm *FeatureFlagManager) DeleteFlag(name FeatureFlagType) error {
+	if IsBuiltinFlag(name) {
+		return fmt.Errorf("内置功能标志不能删除: %s", name)
+	}
+
 	fm.mu.Lock()
 	defer fm.mu.Unlock()
 
-	flag, exists := fm.flags[name]
-	if !exists {
+	if _, exists := fm.flags[name]; !exists {
 		return fmt.Errorf("功能标志不存在: %s", name)
 	}
-
-	if flag.Metadata == nil {
-		flag.Metadata = make(map[string]interface{})
+	if fm.store != nil {
+		if err := fm.store.DeleteFeatureFlag(name); err != nil {
+			return err
+		}
 	}
-
-	flag.Metadata[key] = value
-	flag.UpdatedAt = time.Now()
-
+	delete(fm.flags, name)
 	return nil
 }
 
@@ -279,9 +527,30 @@ func (fm *FeatureFlagManager) LoadFromFile(filePath string) error {
 	return nil
 }
 
+// ===== 全局实例 =====
+
+var (
+	globalFeatureFlags = NewFeatureFlagManager()
+	featureFlagsMutex  sync.RWMutex
+)
+
+// GetGlobalFeatureFlags 获取全局功能标志管理器（未接入数据库时使用内存中的默认标志）
+func GetGlobalFeatureFlags() *FeatureFlagManager {
+	featureFlagsMutex.RLock()
+	defer featureFlagsMutex.RUnlock()
+	return globalFeatureFlags
+}
+
+// SetGlobalFeatureFlags 设置全局功能标志管理器
+func SetGlobalFeatureFlags(fm *FeatureFlagManager) {
+	featureFlagsMutex.Lock()
+	defer featureFlagsMutex.Unlock()
+	globalFeatureFlags = fm
+}
+
 // ===== 辅助函数 =====
 
-// hashUserID 对userID进行哈希处理
+// hashUserID 对userID进行哈希处理（legacy分桶）
 func hashUserID(userID string) int {
 	hash := 0
 	for _, ch := range userID {
@@ -298,3 +567,20 @@ func abs(n int) int {
 	}
 	return n
 }
+
+// saltedHashUserID 按标志名加盐的FNV-1a哈希（salted分桶，结果在0-99之间）
+func saltedHashUserID(name, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// containsUser 用户是否在列表中
+func containsUser(users []string, userID string) bool {
+	for _, u := range users {
+		if u == userID {
+			return true
+		}
+	}
+	return false
+}
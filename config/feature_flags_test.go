@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -251,3 +252,175 @@ func TestHashUserID(t *testing.T) {
 		t.Errorf("哈希值应该在0-99范围内，得到%d", hash3)
 	}
 }
+
+func TestFeatureFlagManager_BucketingMigration(t *testing.T) {
+	// 旧版本保存的标志没有分桶方式，升级后灰度用户保持不变
+	store := newMemoryFlagStore()
+	store.flags[BetaModeEnabled] = FeatureFlag{Name: BetaModeEnabled, Enabled: true, Percentage: 30}
+	manager, err := NewFeatureFlagManagerWithStore(store)
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		want := hashUserID(userID) < 30
+		if got := manager.IsEnabledForUser(BetaModeEnabled, userID); got != want {
+			t.Fatalf("legacy标志对%s = %v, want %v（灰度用户不应变化）", userID, got, want)
+		}
+	}
+
+	// 新建标志默认加盐分桶，不同标志的灰度用户相互独立
+	a, _ := manager.SaveFlag(FeatureFlag{Name: "custom.a", Enabled: true, Percentage: 50})
+	manager.SaveFlag(FeatureFlag{Name: "custom.b", Enabled: true, Percentage: 50})
+	if a.Bucketing != BucketingSalted {
+		t.Errorf("新建标志分桶方式 = %q, want %q", a.Bucketing, BucketingSalted)
+	}
+	differs := false
+	for i := 0; i < 200 && !differs; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		differs = manager.IsEnabledForUser("custom.a", userID) != manager.IsEnabledForUser("custom.b", userID)
+	}
+	if !differs {
+		t.Error("加盐分桶的不同标志应有不同的灰度用户")
+	}
+
+	// 更新已有标志时不改变分桶方式，未知分桶方式被拒绝
+	flag, _ := manager.GetFlag(BetaModeEnabled)
+	flag.Percentage = 40
+	if saved, _ := manager.SaveFlag(*flag); saved.Bucketing != "" {
+		t.Errorf("已有标志分桶方式不应被修改: %q", saved.Bucketing)
+	}
+	if _, err := manager.SaveFlag(FeatureFlag{Name: "custom.c", Bucketing: "random"}); err == nil {
+		t.Error("未知分桶方式应返回错误")
+	}
+}
+
+// memoryFlagStore 内存中的功能标志存储（模拟多个实例共用的数据库）
+type memoryFlagStore struct {
+	flags   map[FeatureFlagType]FeatureFlag
+	configs map[string]string
+}
+
+func newMemoryFlagStore() *memoryFlagStore {
+	return &memoryFlagStore{flags: make(map[FeatureFlagType]FeatureFlag), configs: make(map[string]string)}
+}
+
+func (s *memoryFlagStore) GetFeatureFlags() ([]FeatureFlag, error) {
+	flags := make([]FeatureFlag, 0, len(s.flags))
+	for _, f := range s.flags {
+		flags = append(flags, f)
+	}
+	return flags, nil
+}
+
+func (s *memoryFlagStore) SaveFeatureFlag(flag FeatureFlag) error {
+	s.flags[flag.Name] = flag
+	return nil
+}
+
+func (s *memoryFlagStore) EnsureFeatureFlag(flag FeatureFlag) error {
+	if _, ok := s.flags[flag.Name]; !ok {
+		s.flags[flag.Name] = flag
+	}
+	return nil
+}
+
+func (s *memoryFlagStore) DeleteFeatureFlag(name FeatureFlagType) error {
+	delete(s.flags, name)
+	return nil
+}
+
+func (s *memoryFlagStore) GetSystemConfig(key string) (string, error) {
+	return s.configs[key], nil
+}
+
+func TestFeatureFlagManager_StoreSeedsAndReloads(t *testing.T) {
+	store := newMemoryFlagStore()
+	store.configs["mem0_enabled"] = "true"
+
+	instanceA, err := NewFeatureFlagManagerWithStore(store)
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+	if !instanceA.IsEnabledForUser(Mem0Enabled, "user-1") {
+		t.Error("首次写入时mem0标志应沿用mem0_enabled=true")
+	}
+	if len(store.flags) != len(defaultFeatureFlags()) {
+		t.Errorf("默认标志应写入数据库，得到%d个", len(store.flags))
+	}
+
+	// 已存在的标志不被默认值或旧配置覆盖
+	if err := instanceA.SetEnabled(Mem0Enabled, false); err != nil {
+		t.Fatalf("设置失败: %v", err)
+	}
+	instanceB, err := NewFeatureFlagManagerWithStore(store)
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+	if instanceB.IsEnabled(Mem0Enabled) {
+		t.Error("管理员的修改应持久化，不应被mem0_enabled覆盖")
+	}
+
+	// 实例B新增的标志在实例A重载后生效
+	if _, err := instanceB.SaveFlag(FeatureFlag{Name: "exchange.lighter", Enabled: true, Percentage: 0, AllowUsers: []string{"user-1"}}); err != nil {
+		t.Fatalf("保存标志失败: %v", err)
+	}
+	if instanceA.IsEnabledForUser("exchange.lighter", "user-1") {
+		t.Error("重载前实例A不应看到新标志")
+	}
+	if err := instanceA.Reload(); err != nil {
+		t.Fatalf("重载失败: %v", err)
+	}
+	if !instanceA.IsEnabledForUser("exchange.lighter", "user-1") {
+		t.Error("重载后白名单用户应启用")
+	}
+
+	// 删除自定义标志，内置标志不可删除
+	if err := instanceB.DeleteFlag(Mem0Enabled); err == nil {
+		t.Error("内置标志不应允许删除")
+	}
+	if err := instanceB.DeleteFlag("exchange.lighter"); err != nil {
+		t.Fatalf("删除标志失败: %v", err)
+	}
+	instanceA.Reload()
+	if _, err := instanceA.GetFlag("exchange.lighter"); err == nil {
+		t.Error("重载后已删除的标志应移除")
+	}
+}
+
+func TestFeatureFlagManager_UserTargeting(t *testing.T) {
+	manager := NewFeatureFlagManager()
+
+	if _, err := manager.SaveFlag(FeatureFlag{
+		Name:       ExchangeOKXEnabled,
+		Enabled:    true,
+		Percentage: 100,
+		DenyUsers:  []string{"blocked"},
+		AllowUsers: []string{"blocked", "vip"},
+	}); err != nil {
+		t.Fatalf("保存标志失败: %v", err)
+	}
+	if manager.IsEnabledForUser(ExchangeOKXEnabled, "blocked") {
+		t.Error("黑名单应优先于白名单")
+	}
+	if !manager.IsEnabledForUser(ExchangeOKXEnabled, "someone") {
+		t.Error("100%灰度应对普通用户启用")
+	}
+
+	manager.SetPercentage(ExchangeOKXEnabled, 0)
+	if !manager.IsEnabledForUser(ExchangeOKXEnabled, "vip") {
+		t.Error("白名单用户不受百分比限制")
+	}
+	if manager.IsEnabledForUser(ExchangeOKXEnabled, "someone") {
+		t.Error("0%灰度不应对普通用户启用")
+	}
+
+	manager.SetEnabled(ExchangeOKXEnabled, false)
+	if manager.IsEnabledForUser(ExchangeOKXEnabled, "vip") {
+		t.Error("总开关关闭时白名单也应禁用")
+	}
+
+	if _, err := manager.SaveFlag(FeatureFlag{Name: ExchangeOKXEnabled, Percentage: 101}); err == nil {
+		t.Error("应该拒绝无效的百分比")
+	}
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ExperimentVariant 实验变体（为空的字段沿用交易员自身配置）
+type ExperimentVariant struct {
+	Name               string  `json:"name"`
+	Weight             float64 `json:"weight"`                         // 流量占比（创建时归一化）
+	PromptTemplate     string  `json:"prompt_template,omitempty"`      // 系统提示词模板
+	CustomPrompt       string  `json:"custom_prompt,omitempty"`        // 自定义策略prompt
+	OverrideBasePrompt bool    `json:"override_base_prompt,omitempty"` // 自定义prompt是否覆盖基础prompt
+	AIModelID          string  `json:"ai_model_id,omitempty"`          // 用户AI模型ID
+	Memory             *bool   `json:"memory,omitempty"`               // 是否注入历史交易记忆（nil沿用交易员配置）
+}
+
+// Experiment 在线A/B实验（将交易员或决策周期分配到不同的prompt/模型/记忆变体）
+type Experiment struct {
+	ID             string              `json:"id"`
+	UserID         string              `json:"user_id"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description"`
+	Status         string              `json:"status"`     // running / stopped
+	Assignment     string              `json:"assignment"` // trader: 每个交易员固定一个变体, cycle: 每个决策周期重新分配
+	TraderIDs      []string            `json:"trader_ids"`
+	Variants       []ExperimentVariant `json:"variants"`         // 第一个变体为对照组
+	MaxDrawdownPct float64             `json:"max_drawdown_pct"` // 任一变体回撤超过该百分比时自动停止（0为不限制）
+	StopReason     string              `json:"stop_reason,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	StoppedAt      *time.Time          `json:"stopped_at,omitempty"`
+}
+
+// ExperimentAssignment 交易员/周期的变体分配记录
+type ExperimentAssignment struct {
+	ExperimentID string
+	TraderID     string
+	Variant      string
+	Cycle        int
+	CreatedAt    time.Time
+}
+
+// ExperimentRepository 实验数据库操作
+type ExperimentRepository struct {
+	db *sql.DB
+}
+
+// NewExperimentRepository 创建实验repository
+func NewExperimentRepository(db *sql.DB) *ExperimentRepository {
+	return &ExperimentRepository{db: db}
+}
+
+// Insert 保存新实验
+func (r *ExperimentRepository) Insert(e Experiment) error {
+	traderIDs, err := json.Marshal(e.TraderIDs)
+	if err != nil {
+		return fmt.Errorf("序列化实验交易员失败: %w", err)
+	}
+	variants, err := json.Marshal(e.Variants)
+	if err != nil {
+		return fmt.Errorf("序列化实验变体失败: %w", err)
+	}
+
+	query := `
+		INSERT INTO experiments
+		(id, user_id, name, description, status, assignment, trader_ids, variants, max_drawdown_pct, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	if _, err := r.db.Exec(query, e.ID, e.UserID, e.Name, e.Description, e.Status, e.Assignment,
+		string(traderIDs), string(variants), e.MaxDrawdownPct, e.CreatedAt); err != nil {
+		return fmt.Errorf("保存实验失败: %w", err)
+	}
+	return nil
+}
+
+// Get 获取实验（不存在时返回 sql.ErrNoRows）
+func (r *ExperimentRepository) Get(id string) (*Experiment, error) {
+	rows, err := r.query(`WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("实验 %s 不存在: %w", id, sql.ErrNoRows)
+	}
+	return &rows[0], nil
+}
+
+// List 获取实验列表（userID/status为空时不过滤）
+func (r *ExperimentRepository) List(userID, status string) ([]Experiment, error) {
+	where := "WHERE 1 = 1"
+	var args []interface{}
+	if userID != "" {
+		args = append(args, userID)
+		where += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+	if status != "" {
+		args = append(args, status)
+		where += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	return r.query(where, args...)
+}
+
+// query 按条件查询实验（按创建时间倒序）
+func (r *ExperimentRepository) query(where string, args ...interface{}) ([]Experiment, error) {
+	query := `
+		SELECT id, user_id, name, description, status, assignment, trader_ids, variants,
+		       max_drawdown_pct, stop_reason, created_at, stopped_at
+		FROM experiments ` + where + `
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询实验失败: %w", err)
+	}
+	defer rows.Close()
+
+	var experiments []Experiment
+	for rows.Next() {
+		var e Experiment
+		var traderIDs, variants string
+		var stoppedAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Name, &e.Description, &e.Status, &e.Assignment, &traderIDs, &variants,
+			&e.MaxDrawdownPct, &e.StopReason, &e.CreatedAt, &stoppedAt); err != nil {
+			return nil, fmt.Errorf("解析实验失败: %w", err)
+		}
+		if err := json.Unmarshal([]byte(traderIDs), &e.TraderIDs); err != nil {
+			return nil, fmt.Errorf("解析实验 %s 的交易员失败: %w", e.ID, err)
+		}
+		if err := json.Unmarshal([]byte(variants), &e.Variants); err != nil {
+			return nil, fmt.Errorf("解析实验 %s 的变体失败: %w", e.ID, err)
+		}
+		if stoppedAt.Valid {
+			e.StoppedAt = &stoppedAt.Time
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
+
+// Stop 停止实验（只更新运行中的实验）
+func (r *ExperimentRepository) Stop(id, reason string, stoppedAt time.Time) error {
+	query := `
+		UPDATE experiments
+		SET status = 'stopped', stop_reason = $2, stopped_at = $3
+		WHERE id = $1 AND status = 'running'
+	`
+	if _, err := r.db.Exec(query, id, reason, stoppedAt); err != nil {
+		return fmt.Errorf("停止实验失败: %w", err)
+	}
+	return nil
+}
+
+// InsertAssignment 保存变体分配记录
+func (r *ExperimentRepository) InsertAssignment(a ExperimentAssignment) error {
+	query := `
+		INSERT INTO experiment_assignments (experiment_id, trader_id, variant, cycle, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := r.db.Exec(query, a.ExperimentID, a.TraderID, a.Variant, a.Cycle, a.CreatedAt); err != nil {
+		return fmt.Errorf("保存实验分配失败: %w", err)
+	}
+	return nil
+}
+
+// GetAssignments 获取实验的分配记录（按时间正序）
+func (r *ExperimentRepository) GetAssignments(experimentID string) ([]ExperimentAssignment, error) {
+	query := `
+		SELECT experiment_id, trader_id, variant, cycle, created_at
+		FROM experiment_assignments
+		WHERE experiment_id = $1
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(query, experimentID)
+	if err != nil {
+		return nil, fmt.Errorf("查询实验分配失败: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []ExperimentAssignment
+	for rows.Next() {
+		var a ExperimentAssignment
+		if err := rows.Scan(&a.ExperimentID, &a.TraderID, &a.Variant, &a.Cycle, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("解析实验分配失败: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
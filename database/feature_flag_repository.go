@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FeatureFlagRecord 功能标志数据库记录（用户白名单/黑名单和元数据以JSON保存）
+type FeatureFlagRecord struct {
+	Name        string
+	Description string
+	Enabled     bool
+	Percentage  int
+	AllowUsers  []string
+	DenyUsers   []string
+	Bucketing   string // 灰度分桶方式（为空表示legacy）
+	Metadata    map[string]interface{}
+	UpdatedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// FeatureFlagRepository 功能标志数据库操作
+type FeatureFlagRepository struct {
+	db *sql.DB
+}
+
+// NewFeatureFlagRepository 创建功能标志repository
+func NewFeatureFlagRepository(db *sql.DB) *FeatureFlagRepository {
+	return &FeatureFlagRepository{db: db}
+}
+
+// List 获取所有功能标志（按名称排序）
+func (r *FeatureFlagRepository) List() ([]FeatureFlagRecord, error) {
+	query := `
+		SELECT name, description, enabled, percentage, allow_users, deny_users, bucketing, metadata,
+		       updated_by, created_at, updated_at
+		FROM feature_flags
+		ORDER BY name
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询功能标志失败: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []FeatureFlagRecord
+	for rows.Next() {
+		var f FeatureFlagRecord
+		var allowUsers, denyUsers, metadata string
+		if err := rows.Scan(&f.Name, &f.Description, &f.Enabled, &f.Percentage, &allowUsers, &denyUsers, &f.Bucketing, &metadata,
+			&f.UpdatedBy, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("解析功能标志失败: %w", err)
+		}
+		if err := unmarshalFlagJSON(allowUsers, &f.AllowUsers); err != nil {
+			return nil, fmt.Errorf("解析功能标志 %s 的白名单失败: %w", f.Name, err)
+		}
+		if err := unmarshalFlagJSON(denyUsers, &f.DenyUsers); err != nil {
+			return nil, fmt.Errorf("解析功能标志 %s 的黑名单失败: %w", f.Name, err)
+		}
+		if err := unmarshalFlagJSON(metadata, &f.Metadata); err != nil {
+			return nil, fmt.Errorf("解析功能标志 %s 的元数据失败: %w", f.Name, err)
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// Upsert 保存功能标志（已存在时覆盖，保留原创建时间）
+func (r *FeatureFlagRepository) Upsert(f FeatureFlagRecord) error {
+	return r.save(f, `
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			enabled = EXCLUDED.enabled,
+			percentage = EXCLUDED.percentage,
+			allow_users = EXCLUDED.allow_users,
+			deny_users = EXCLUDED.deny_users,
+			bucketing = EXCLUDED.bucketing,
+			metadata = EXCLUDED.metadata,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+	`)
+}
+
+// InsertIfMissing 功能标志不存在时写入（用于初始化默认标志，不覆盖管理员修改）
+func (r *FeatureFlagRepository) InsertIfMissing(f FeatureFlagRecord) error {
+	return r.save(f, `ON CONFLICT (name) DO NOTHING`)
+}
+
+// save 写入功能标志
+func (r *FeatureFlagRepository) save(f FeatureFlagRecord, onConflict string) error {
+	allowUsers, err := marshalFlagJSON(f.AllowUsers)
+	if err != nil {
+		return fmt.Errorf("序列化功能标志白名单失败: %w", err)
+	}
+	denyUsers, err := marshalFlagJSON(f.DenyUsers)
+	if err != nil {
+		return fmt.Errorf("序列化功能标志黑名单失败: %w", err)
+	}
+	metadata, err := marshalFlagJSON(f.Metadata)
+	if err != nil {
+		return fmt.Errorf("序列化功能标志元数据失败: %w", err)
+	}
+
+	query := `
+		INSERT INTO feature_flags
+		(name, description, enabled, percentage, allow_users, deny_users, bucketing, metadata, updated_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	` + onConflict
+	if _, err := r.db.Exec(query, f.Name, f.Description, f.Enabled, f.Percentage, allowUsers, denyUsers, f.Bucketing, metadata,
+		f.UpdatedBy, f.CreatedAt, f.UpdatedAt); err != nil {
+		return fmt.Errorf("保存功能标志 %s 失败: %w", f.Name, err)
+	}
+	return nil
+}
+
+// Delete 删除功能标志
+func (r *FeatureFlagRepository) Delete(name string) error {
+	if _, err := r.db.Exec(`DELETE FROM feature_flags WHERE name = $1`, name); err != nil {
+		return fmt.Errorf("删除功能标志 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// marshalFlagJSON 序列化JSON列（nil保存为空字符串）
+func marshalFlagJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// unmarshalFlagJSON 解析JSON列（空字符串视为未设置）
+func unmarshalFlagJSON(data string, v interface{}) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), v)
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LedgerEntry 手续费/资金费流水（从交易所账单同步）
+type LedgerEntry struct {
+	ID          int64
+	TraderID    string
+	Symbol      string
+	EntryType   string  // fee / funding
+	Amount      float64 // 金额（USDT，正数为支付，负数为收取）
+	ExchangeRef string  // 交易所流水ID（去重用）
+	CreatedAt   time.Time
+}
+
+// LedgerSummary 手续费和资金费汇总
+type LedgerSummary struct {
+	Fees    float64 `json:"fees"`    // 手续费合计
+	Funding float64 `json:"funding"` // 资金费合计（负数为净收取）
+}
+
+// Total 费用合计（毛盈亏 - 费用合计 = 净盈亏）
+func (s LedgerSummary) Total() float64 {
+	return s.Fees + s.Funding
+}
+
+// LedgerRepository 手续费/资金费流水数据库操作
+type LedgerRepository struct {
+	db *sql.DB
+}
+
+// NewLedgerRepository 创建流水repository
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// Insert 批量写入流水（已存在的交易所流水ID跳过），返回新写入的条数
+func (r *LedgerRepository) Insert(entries []LedgerEntry) (int, error) {
+	query := `
+		INSERT INTO trader_ledger (trader_id, symbol, entry_type, amount, exchange_ref, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (trader_id, entry_type, exchange_ref) DO NOTHING
+	`
+
+	inserted := 0
+	for _, e := range entries {
+		result, err := r.db.Exec(query, e.TraderID, e.Symbol, e.EntryType, e.Amount, e.ExchangeRef, e.CreatedAt)
+		if err != nil {
+			return inserted, fmt.Errorf("保存费用流水失败: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			inserted += int(n)
+		}
+	}
+	return inserted, nil
+}
+
+// Summary 汇总trader在since之后的手续费和资金费（since为零值时汇总全部）
+func (r *LedgerRepository) Summary(traderID string, since time.Time) (LedgerSummary, error) {
+	query := `
+		SELECT
+			COALESCE(SUM(CASE WHEN entry_type = 'fee' THEN amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN entry_type = 'funding' THEN amount ELSE 0 END), 0)
+		FROM trader_ledger
+		WHERE trader_id = $1 AND created_at >= $2
+	`
+
+	var summary LedgerSummary
+	if err := r.db.QueryRow(query, traderID, since).Scan(&summary.Fees, &summary.Funding); err != nil {
+		return LedgerSummary{}, fmt.Errorf("汇总费用流水失败: %w", err)
+	}
+	return summary, nil
+}
+
+// LatestTime 最近一条流水的时间（没有流水时返回零值）
+func (r *LedgerRepository) LatestTime(traderID string) (time.Time, error) {
+	var latest sql.NullTime
+	err := r.db.QueryRow(`SELECT MAX(created_at) FROM trader_ledger WHERE trader_id = $1`, traderID).Scan(&latest)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("查询最近费用流水失败: %w", err)
+	}
+	return latest.Time, nil
+}
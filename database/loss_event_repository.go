@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -9,9 +10,9 @@ import (
 // LossEvent 亏损事件记录
 type LossEvent struct {
 	ID                int
-	UserID            int
+	UserID            string
 	TraderID          string
-	EventType         string // "consecutive_loss", "daily_loss", "weekly_loss", "max_drawdown"
+	EventType         string // "consecutive_loss", "daily_loss", "weekly_loss", "max_drawdown", "trade_loss", "manual_reset"
 	EventReason       string
 	ConsecutiveLosses int
 	DailyLossPct      float64
@@ -20,9 +21,91 @@ type LossEvent struct {
 	AccountEquity     float64
 	BreachTriggered   bool
 	RecoveryAttempt   int
+	AccountPeak       float64 // 断路器记录的账户净值峰值（用于重启后恢复回撤计算）
+	DayStartEquity    float64 // 当日起始净值
+	WeekStartEquity   float64 // 本周起始净值
 	CreatedAt         time.Time
 }
 
+// LossEventRepository 亏损事件数据库操作（PostgreSQL）
+type LossEventRepository struct {
+	db *sql.DB
+}
+
+// NewLossEventRepository 创建亏损事件repository
+func NewLossEventRepository(db *sql.DB) *LossEventRepository {
+	return &LossEventRepository{db: db}
+}
+
+// Save 保存亏损事件
+func (r *LossEventRepository) Save(event *LossEvent) error {
+	query := `
+		INSERT INTO loss_events
+		(user_id, trader_id, event_type, event_reason, consecutive_losses,
+		 daily_loss_pct, weekly_loss_pct, max_drawdown_pct, account_equity,
+		 breach_triggered, recovery_attempt, account_peak, day_start_equity, week_start_equity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`
+
+	_, err := r.db.Exec(query,
+		event.UserID, event.TraderID, event.EventType, event.EventReason,
+		event.ConsecutiveLosses, event.DailyLossPct, event.WeeklyLossPct,
+		event.MaxDrawdownPct, event.AccountEquity, event.BreachTriggered,
+		event.RecoveryAttempt, event.AccountPeak, event.DayStartEquity, event.WeekStartEquity)
+	if err != nil {
+		return fmt.Errorf("保存亏损事件失败: %w", err)
+	}
+	return nil
+}
+
+// GetRecent 获取trader最近的亏损事件，按时间倒序
+func (r *LossEventRepository) GetRecent(traderID string, limit int) ([]*LossEvent, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, user_id, trader_id, event_type, event_reason, consecutive_losses,
+		       daily_loss_pct, weekly_loss_pct, max_drawdown_pct, account_equity,
+		       breach_triggered, recovery_attempt, account_peak, day_start_equity, week_start_equity, created_at
+		FROM loss_events
+		WHERE trader_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, traderID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询亏损事件失败: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*LossEvent
+	for rows.Next() {
+		event := &LossEvent{}
+		if err := rows.Scan(
+			&event.ID, &event.UserID, &event.TraderID, &event.EventType, &event.EventReason,
+			&event.ConsecutiveLosses, &event.DailyLossPct, &event.WeeklyLossPct,
+			&event.MaxDrawdownPct, &event.AccountEquity, &event.BreachTriggered,
+			&event.RecoveryAttempt, &event.AccountPeak, &event.DayStartEquity, &event.WeekStartEquity,
+			&event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描亏损事件失败: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// GetLatest 获取trader最新的亏损事件（没有记录时返回nil）
+func (r *LossEventRepository) GetLatest(traderID string) (*LossEvent, error) {
+	events, err := r.GetRecent(traderID, 1)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return events[0], nil
+}
+
 // SaveLossEvent 保存亏损事件
 func (db *DatabaseImpl) SaveLossEvent(event *LossEvent) error {
 	query := `INSERT INTO loss_events (
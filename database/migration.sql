@@ -87,6 +87,12 @@ CREATE TABLE IF NOT EXISTS traders (
     override_base_prompt BOOLEAN DEFAULT FALSE,
     system_prompt_template TEXT DEFAULT 'default',
     is_cross_margin BOOLEAN DEFAULT TRUE,
+    ensemble_model_ids TEXT DEFAULT '',
+    ensemble_policy TEXT DEFAULT '',
+    execution_mode TEXT DEFAULT '',
+    risk_per_trade_pct DECIMAL(10,4) DEFAULT 0,
+    route_exchanges TEXT DEFAULT '',
+    trading_schedule TEXT DEFAULT '',
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
@@ -541,6 +547,12 @@ CREATE TABLE IF NOT EXISTS trade_records (
     leverage INTEGER DEFAULT 1,
     holding_time_seconds BIGINT DEFAULT 0,
     margin_mode TEXT DEFAULT 'cross',
+    side TEXT DEFAULT '',
+    quantity DECIMAL(24,8) DEFAULT 0,
+    realized_pnl DECIMAL(24,8) DEFAULT 0,
+    fees DECIMAL(24,8) DEFAULT 0,
+    funding DECIMAL(24,8) DEFAULT 0,
+    close_reason TEXT DEFAULT '',
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
@@ -577,6 +589,192 @@ CREATE INDEX IF NOT EXISTS idx_trade_records_created_at ON trade_records(created
 CREATE INDEX IF NOT EXISTS idx_kelly_stats_trader ON kelly_stats(trader_id);
 CREATE INDEX IF NOT EXISTS idx_kelly_stats_symbol ON kelly_stats(symbol);
 
+-- 订单表 (交易所无关的订单状态，由订单管理器对账更新)
+CREATE TABLE IF NOT EXISTS orders (
+    client_order_id TEXT PRIMARY KEY,
+    exchange_order_id TEXT DEFAULT '',
+    trader_id TEXT NOT NULL,
+    exchange TEXT DEFAULT '',
+    symbol TEXT NOT NULL,
+    action TEXT NOT NULL,
+    position_side TEXT DEFAULT '',
+    order_type TEXT NOT NULL,
+    quantity DECIMAL(24,8) DEFAULT 0,
+    filled_qty DECIMAL(24,8) DEFAULT 0,
+    avg_price DECIMAL(24,8) DEFAULT 0,
+    price DECIMAL(24,8) DEFAULT 0,
+    stop_price DECIMAL(24,8) DEFAULT 0,
+    fee DECIMAL(24,8) DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_trader_time ON orders(trader_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_trader_status ON orders(trader_id, status);
+
+-- 亏损事件表 (开仓前风控断路器的触发、重置和亏损记录)
+CREATE TABLE IF NOT EXISTS loss_events (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT DEFAULT '',
+    trader_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    event_reason TEXT DEFAULT '',
+    consecutive_losses INTEGER DEFAULT 0,
+    daily_loss_pct DECIMAL(10,4) DEFAULT 0,
+    weekly_loss_pct DECIMAL(10,4) DEFAULT 0,
+    max_drawdown_pct DECIMAL(10,4) DEFAULT 0,
+    account_equity DECIMAL(24,8) DEFAULT 0,
+    breach_triggered BOOLEAN DEFAULT FALSE,
+    recovery_attempt INTEGER DEFAULT 0,
+    account_peak DECIMAL(24,8) DEFAULT 0,
+    day_start_equity DECIMAL(24,8) DEFAULT 0,
+    week_start_equity DECIMAL(24,8) DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_loss_events_trader_time ON loss_events(trader_id, created_at DESC);
+
+-- 持仓峰值表 (跟踪止损的高水位和当前止损止盈价，重启后恢复)
+CREATE TABLE IF NOT EXISTS position_peaks (
+    trader_id TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL,
+    entry_price DECIMAL(24,8) DEFAULT 0,
+    peak_price DECIMAL(24,8) DEFAULT 0,
+    stop_loss DECIMAL(24,8) DEFAULT 0,
+    take_profit DECIMAL(24,8) DEFAULT 0,
+    break_even BOOLEAN DEFAULT FALSE,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (trader_id, symbol, side)
+);
+
+-- 手续费/资金费流水表 (从交易所账单同步，用于区分毛盈亏和净盈亏)
+CREATE TABLE IF NOT EXISTS trader_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    trader_id TEXT NOT NULL,
+    symbol TEXT DEFAULT '',
+    entry_type TEXT NOT NULL,
+    amount DECIMAL(24,8) NOT NULL,
+    exchange_ref TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (trader_id, entry_type, exchange_ref)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trader_ledger_trader_time ON trader_ledger(trader_id, created_at DESC);
+
+-- 组合持仓表 (多腿决策开出的配对/对冲仓位，作为一个整体计算盈亏和止损)
+CREATE TABLE IF NOT EXISTS position_groups (
+    id TEXT PRIMARY KEY,
+    trader_id TEXT NOT NULL,
+    legs TEXT NOT NULL,
+    leverage INTEGER DEFAULT 1,
+    stop_loss_pct DECIMAL(10,4) DEFAULT 0,
+    take_profit_pct DECIMAL(10,4) DEFAULT 0,
+    status TEXT DEFAULT 'open',
+    realized_pnl DECIMAL(24,8) DEFAULT 0,
+    close_reason TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_groups_trader_status ON position_groups(trader_id, status);
+
+-- 在线A/B实验表 (prompt模板/自定义prompt/模型/记忆变体，变体和交易员以JSON保存)
+CREATE TABLE IF NOT EXISTS experiments (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    status TEXT DEFAULT 'running',
+    assignment TEXT DEFAULT 'trader',
+    trader_ids TEXT NOT NULL,
+    variants TEXT NOT NULL,
+    max_drawdown_pct DECIMAL(10,4) DEFAULT 0,
+    stop_reason TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    stopped_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_user_status ON experiments(user_id, status);
+
+-- 实验变体分配表 (trader模式每个交易员一条，cycle模式每个决策周期一条)
+CREATE TABLE IF NOT EXISTS experiment_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    experiment_id TEXT NOT NULL,
+    trader_id TEXT NOT NULL,
+    variant TEXT NOT NULL,
+    cycle INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiment_assignments_experiment ON experiment_assignments(experiment_id, trader_id, created_at);
+
+-- 功能标志表 (多实例定时重载，用户白名单/黑名单和元数据以JSON保存，bucketing为空表示沿用legacy灰度分桶)
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name TEXT PRIMARY KEY,
+    description TEXT DEFAULT '',
+    enabled BOOLEAN DEFAULT false,
+    percentage INTEGER DEFAULT 0,
+    allow_users TEXT DEFAULT '',
+    deny_users TEXT DEFAULT '',
+    bucketing TEXT DEFAULT '',
+    metadata TEXT DEFAULT '',
+    updated_by TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 自定义新闻源表 (RSS/Atom、JSON接口、Webhook；user_id为空表示系统新闻源，请求头和字段映射以JSON保存)
+CREATE TABLE IF NOT EXISTS news_sources (
+    id TEXT PRIMARY KEY,
+    user_id TEXT DEFAULT '',
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    url TEXT DEFAULT '',
+    category TEXT DEFAULT 'crypto',
+    options TEXT DEFAULT '',
+    topic_id INTEGER DEFAULT 0,
+    webhook_secret TEXT DEFAULT '',
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(user_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_news_sources_enabled ON news_sources(enabled);
+
+-- Mem0本地记忆表 (mem0_store_backend=postgres时使用，向量以JSON保存，安装pgvector时额外建向量列)
+CREATE TABLE IF NOT EXISTS mem0_memories (
+    id TEXT PRIMARY KEY,
+    content TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    quality_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT '',
+    reflection_id TEXT,
+    embedding TEXT NOT NULL DEFAULT '[]',
+    embedding_model TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mem0_memories_type ON mem0_memories(type);
+CREATE INDEX IF NOT EXISTS idx_mem0_memories_quality ON mem0_memories(quality_score);
+CREATE INDEX IF NOT EXISTS idx_mem0_memories_created ON mem0_memories(created_at);
+
+CREATE TABLE IF NOT EXISTS mem0_relationships (
+    source_id TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_id, target_id, type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mem0_relationships_target ON mem0_relationships(target_id);
+
 -- ============================================================
 -- Part 10: 默认数据初始化
 -- ============================================================
@@ -590,7 +788,11 @@ ON CONFLICT (id) DO NOTHING;
 INSERT INTO ai_models (id, user_id, name, provider, enabled)
 VALUES
     ('deepseek', 'default', 'DeepSeek', 'deepseek', FALSE),
-    ('qwen', 'default', 'Qwen', 'qwen', FALSE)
+    ('qwen', 'default', 'Qwen', 'qwen', FALSE),
+    ('openai', 'default', 'OpenAI', 'openai', FALSE),
+    ('anthropic', 'default', 'Anthropic', 'anthropic', FALSE),
+    ('gemini', 'default', 'Gemini', 'gemini', FALSE),
+    ('local', 'default', 'Local (OpenAI兼容)', 'local', FALSE)
 ON CONFLICT (id, user_id) DO UPDATE SET
     name = EXCLUDED.name,
     provider = EXCLUDED.provider;
@@ -601,7 +803,8 @@ VALUES
     ('binance', 'default', 'Binance Futures', 'cex', FALSE),
     ('hyperliquid', 'default', 'Hyperliquid', 'dex', FALSE),
     ('aster', 'default', 'Aster DEX', 'dex', FALSE),
-    ('okx', 'default', 'OKX Futures', 'cex', FALSE)
+    ('okx', 'default', 'OKX Futures', 'cex', FALSE),
+    ('paper', 'default', 'Paper Trading', 'cex', FALSE)
 ON CONFLICT (id, user_id) DO UPDATE SET
     name = EXCLUDED.name,
     type = EXCLUDED.type;
@@ -642,6 +845,52 @@ INSERT INTO system_config (key, value) VALUES
     ('btc_eth_leverage', '5'),
     ('altcoin_leverage', '5'),
     ('jwt_secret', ''),
+    ('ai_tool_calling', ''),
+    ('ai_fallback_model', ''),
+    ('trigger_enabled', 'false'),
+    ('trigger_price_move_pct', '2'),
+    ('trigger_funding_flip', 'true'),
+    ('trigger_oi_spike_pct', '20'),
+    ('trigger_news_keywords', 'SEC,ETF,hack,exploit,FOMC,CPI,rate cut,rate hike,监管,黑客,美联储,降息,加息'),
+    ('trigger_min_interval_minutes', '5'),
+    ('trigger_debounce_seconds', '30'),
+    ('execution_limit_timeout_seconds', '30'),
+    ('execution_market_fallback', 'true'),
+    ('execution_twap_slices', '4'),
+    ('execution_twap_interval_seconds', '20'),
+    ('execution_twap_min_notional_usd', '2000'),
+    ('risk_max_consecutive_losses', '5'),
+    ('risk_max_weekly_loss_pct', '20'),
+    ('risk_constraints_enabled', 'false'),
+    ('portfolio_risk_enabled', 'true'),
+    ('portfolio_max_gross_leverage', '10'),
+    ('portfolio_max_net_leverage', '10'),
+    ('portfolio_max_symbol_exposure', '10'),
+    ('portfolio_max_margin_usage_pct', '90'),
+    ('portfolio_min_downsize_pct', '20'),
+    ('sizing_mode', 'recompute'),
+    ('sizing_risk_per_trade_pct', '1'),
+    ('sizing_atr_stop_multiple', '1.5'),
+    ('sizing_kelly_fraction', '1'),
+    ('liquidation_guard_enabled', 'true'),
+    ('liquidation_warn_distance_pct', '10'),
+    ('liquidation_critical_distance_pct', '5'),
+    ('liquidation_reduce_pct', '50'),
+    ('liquidation_check_interval_seconds', '10'),
+    ('liquidation_cooldown_minutes', '5'),
+    ('routing_failure_threshold', '3'),
+    ('routing_failover_cooldown_seconds', '120'),
+    ('routing_taker_fees', '{"binance":0.0005,"okx":0.0005,"hyperliquid":0.00045,"aster":0.00035}'),
+    ('blackout_calendar_file', 'calendar/blackout_events.json'),
+    ('blackout_before_minutes', '30'),
+    ('blackout_after_minutes', '30'),
+    ('trailing_stop_enabled', 'true'),
+    ('trailing_stop_pct', '3'),
+    ('trailing_stop_atr_multiple', '0'),
+    ('trailing_activation_pct', '2'),
+    ('break_even_trigger_pct', '1'),
+    ('break_even_offset_pct', '0.1'),
+    ('trailing_min_move_pct', '0.2'),
 
     -- Mlion新闻配置
     ('mlion_api_key', 'c559b9a8-80c2-4c17-8c31-bb7659b12b52'),
@@ -690,6 +939,12 @@ INSERT INTO system_config (key, value) VALUES
     ('mem0_ab_test_enabled', 'false'),
     ('mem0_ab_test_control_percentage', '50'),
     ('mem0_ab_test_duration_days', '7'),
+    ('mem0_store_backend', 'http'),
+    ('mem0_embedding_provider', 'hash'),
+    ('mem0_embedding_api_url', ''),
+    ('mem0_embedding_api_key', ''),
+    ('mem0_embedding_model', ''),
+    ('mem0_vector_search', 'auto'),
 
     -- Gemini配置
     ('gemini_enabled', 'false'),
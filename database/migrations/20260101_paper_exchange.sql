@@ -0,0 +1,5 @@
+-- 添加纸面交易交易所（使用实时行情模拟成交，无需API密钥）
+
+INSERT INTO exchanges (id, user_id, name, type, enabled)
+VALUES ('paper', 'default', 'Paper Trading', 'cex', FALSE)
+ON CONFLICT (id, user_id) DO NOTHING;
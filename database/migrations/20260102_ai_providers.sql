@@ -0,0 +1,14 @@
+-- 添加更多AI提供商（通过统一的提供商注册表接入交易循环）
+
+INSERT INTO ai_models (id, user_id, name, provider, enabled)
+VALUES
+    ('openai', 'default', 'OpenAI', 'openai', FALSE),
+    ('anthropic', 'default', 'Anthropic', 'anthropic', FALSE),
+    ('gemini', 'default', 'Gemini', 'gemini', FALSE),
+    ('local', 'default', 'Local (OpenAI兼容)', 'local', FALSE)
+ON CONFLICT (id, user_id) DO NOTHING;
+
+-- 备用AI模型ID（主模型超时或失败时切换），为空表示不启用
+INSERT INTO system_config (key, value)
+VALUES ('ai_fallback_model', '')
+ON CONFLICT (key) DO NOTHING;
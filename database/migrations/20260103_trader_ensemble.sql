@@ -0,0 +1,4 @@
+-- 交易员多模型投票配置（逗号分隔的AI模型ID + 合并策略 unanimous/majority/weighted）
+
+ALTER TABLE traders ADD COLUMN IF NOT EXISTS ensemble_model_ids TEXT DEFAULT '';
+ALTER TABLE traders ADD COLUMN IF NOT EXISTS ensemble_policy TEXT DEFAULT '';
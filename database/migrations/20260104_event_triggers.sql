@@ -0,0 +1,12 @@
+-- 事件驱动决策触发器（价格波动、资金费率反转、OI异动、高影响力新闻），默认关闭
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('trigger_enabled', 'false'),
+    ('trigger_price_move_pct', '2'),
+    ('trigger_funding_flip', 'true'),
+    ('trigger_oi_spike_pct', '20'),
+    ('trigger_news_keywords', 'SEC,ETF,hack,exploit,FOMC,CPI,rate cut,rate hike,监管,黑客,美联储,降息,加息'),
+    ('trigger_min_interval_minutes', '5'),
+    ('trigger_debounce_seconds', '30')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,24 @@
+-- 订单管理：记录每笔订单（含止损止盈条件单）的状态，由订单管理器轮询交易所对账更新
+
+CREATE TABLE IF NOT EXISTS orders (
+    client_order_id TEXT PRIMARY KEY,
+    exchange_order_id TEXT DEFAULT '',
+    trader_id TEXT NOT NULL,
+    exchange TEXT DEFAULT '',
+    symbol TEXT NOT NULL,
+    action TEXT NOT NULL,
+    position_side TEXT DEFAULT '',
+    order_type TEXT NOT NULL,
+    quantity DECIMAL(24,8) DEFAULT 0,
+    filled_qty DECIMAL(24,8) DEFAULT 0,
+    avg_price DECIMAL(24,8) DEFAULT 0,
+    stop_price DECIMAL(24,8) DEFAULT 0,
+    fee DECIMAL(24,8) DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_trader_time ON orders(trader_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_trader_status ON orders(trader_id, status);
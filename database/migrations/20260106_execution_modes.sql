@@ -0,0 +1,13 @@
+-- 开仓执行方式：交易员可选市价、中间价限价（超时转市价）、只做Maker、TWAP分批执行
+
+ALTER TABLE traders ADD COLUMN IF NOT EXISTS execution_mode TEXT DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS price DECIMAL(24,8) DEFAULT 0;
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('execution_limit_timeout_seconds', '30'),
+    ('execution_market_fallback', 'true'),
+    ('execution_twap_slices', '4'),
+    ('execution_twap_interval_seconds', '20'),
+    ('execution_twap_min_notional_usd', '2000')
+ON CONFLICT (key) DO NOTHING;
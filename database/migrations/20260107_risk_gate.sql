@@ -0,0 +1,30 @@
+-- 开仓前风控闸门：断路器的触发、重置和亏损记录持久化，重启后恢复断路器状态
+
+-- 亏损事件表 (开仓前风控断路器的触发、重置和亏损记录)
+CREATE TABLE IF NOT EXISTS loss_events (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT DEFAULT '',
+    trader_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    event_reason TEXT DEFAULT '',
+    consecutive_losses INTEGER DEFAULT 0,
+    daily_loss_pct DECIMAL(10,4) DEFAULT 0,
+    weekly_loss_pct DECIMAL(10,4) DEFAULT 0,
+    max_drawdown_pct DECIMAL(10,4) DEFAULT 0,
+    account_equity DECIMAL(24,8) DEFAULT 0,
+    breach_triggered BOOLEAN DEFAULT FALSE,
+    recovery_attempt INTEGER DEFAULT 0,
+    account_peak DECIMAL(24,8) DEFAULT 0,
+    day_start_equity DECIMAL(24,8) DEFAULT 0,
+    week_start_equity DECIMAL(24,8) DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_loss_events_trader_time ON loss_events(trader_id, created_at DESC);
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('risk_max_consecutive_losses', '5'),
+    ('risk_max_weekly_loss_pct', '20'),
+    ('risk_constraints_enabled', 'false')
+ON CONFLICT (key) DO NOTHING;
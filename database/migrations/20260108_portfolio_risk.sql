@@ -0,0 +1,11 @@
+-- 组合风控：按用户汇总所有交易员的持仓，限制总名义价值、净敞口、单币种敞口和保证金占用
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('portfolio_risk_enabled', 'true'),
+    ('portfolio_max_gross_leverage', '10'),
+    ('portfolio_max_net_leverage', '10'),
+    ('portfolio_max_symbol_exposure', '10'),
+    ('portfolio_max_margin_usage_pct', '90'),
+    ('portfolio_min_downsize_pct', '20')
+ON CONFLICT (key) DO NOTHING;
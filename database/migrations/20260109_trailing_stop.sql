@@ -0,0 +1,26 @@
+-- 跟踪止损：持仓峰值持久化，重启后继续按高水位跟踪止损和保本止损
+
+-- 持仓峰值表 (跟踪止损的高水位和当前止损止盈价，重启后恢复)
+CREATE TABLE IF NOT EXISTS position_peaks (
+    trader_id TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL,
+    entry_price DECIMAL(24,8) DEFAULT 0,
+    peak_price DECIMAL(24,8) DEFAULT 0,
+    stop_loss DECIMAL(24,8) DEFAULT 0,
+    take_profit DECIMAL(24,8) DEFAULT 0,
+    break_even BOOLEAN DEFAULT FALSE,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (trader_id, symbol, side)
+);
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('trailing_stop_enabled', 'true'),
+    ('trailing_stop_pct', '3'),
+    ('trailing_stop_atr_multiple', '0'),
+    ('trailing_activation_pct', '2'),
+    ('break_even_trigger_pct', '1'),
+    ('break_even_offset_pct', '0.1'),
+    ('trailing_min_move_pct', '0.2')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,8 @@
+-- 持仓生命周期跟踪：平仓记录包含方向、数量、价格盈亏、手续费、资金费和平仓原因（含交易所止损止盈触发）
+
+ALTER TABLE trade_records ADD COLUMN IF NOT EXISTS side TEXT DEFAULT '';
+ALTER TABLE trade_records ADD COLUMN IF NOT EXISTS quantity DECIMAL(24,8) DEFAULT 0;
+ALTER TABLE trade_records ADD COLUMN IF NOT EXISTS realized_pnl DECIMAL(24,8) DEFAULT 0;
+ALTER TABLE trade_records ADD COLUMN IF NOT EXISTS fees DECIMAL(24,8) DEFAULT 0;
+ALTER TABLE trade_records ADD COLUMN IF NOT EXISTS funding DECIMAL(24,8) DEFAULT 0;
+ALTER TABLE trade_records ADD COLUMN IF NOT EXISTS close_reason TEXT DEFAULT '';
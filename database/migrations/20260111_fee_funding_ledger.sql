@@ -0,0 +1,15 @@
+-- 手续费/资金费流水：从交易所账单同步，盈亏统计区分毛盈亏和净盈亏
+
+-- 手续费/资金费流水表 (从交易所账单同步，用于区分毛盈亏和净盈亏)
+CREATE TABLE IF NOT EXISTS trader_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    trader_id TEXT NOT NULL,
+    symbol TEXT DEFAULT '',
+    entry_type TEXT NOT NULL,
+    amount DECIMAL(24,8) NOT NULL,
+    exchange_ref TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (trader_id, entry_type, exchange_ref)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trader_ledger_trader_time ON trader_ledger(trader_id, created_at DESC);
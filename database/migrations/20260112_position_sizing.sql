@@ -0,0 +1,11 @@
+-- 服务端仓位计算：AI只决定方向和信心度，仓位按单笔风险预算、ATR、凯利比例和币种配置核定
+
+ALTER TABLE traders ADD COLUMN IF NOT EXISTS risk_per_trade_pct DECIMAL(10,4) DEFAULT 0;
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('sizing_mode', 'recompute'),
+    ('sizing_risk_per_trade_pct', '1'),
+    ('sizing_atr_stop_multiple', '1.5'),
+    ('sizing_kelly_fraction', '1')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,11 @@
+-- 强平距离保护：决策周期之间持仓接近强平价时告警、补设保护止损或部分平仓
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('liquidation_guard_enabled', 'true'),
+    ('liquidation_warn_distance_pct', '10'),
+    ('liquidation_critical_distance_pct', '5'),
+    ('liquidation_reduce_pct', '50'),
+    ('liquidation_check_interval_seconds', '10'),
+    ('liquidation_cooldown_minutes', '5')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,18 @@
+-- 多腿决策：配对交易/对冲仓位作为一个组合持仓，整体计算盈亏和止损止盈
+
+-- 组合持仓表 (多腿决策开出的配对/对冲仓位，作为一个整体计算盈亏和止损)
+CREATE TABLE IF NOT EXISTS position_groups (
+    id TEXT PRIMARY KEY,
+    trader_id TEXT NOT NULL,
+    legs TEXT NOT NULL,
+    leverage INTEGER DEFAULT 1,
+    stop_loss_pct DECIMAL(10,4) DEFAULT 0,
+    take_profit_pct DECIMAL(10,4) DEFAULT 0,
+    status TEXT DEFAULT 'open',
+    realized_pnl DECIMAL(24,8) DEFAULT 0,
+    close_reason TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_groups_trader_status ON position_groups(trader_id, status);
@@ -0,0 +1,10 @@
+-- 跨交易所路由：一个交易员使用多个交易所账户，开仓按价格、手续费和盘口深度选择交易所
+
+ALTER TABLE traders ADD COLUMN IF NOT EXISTS route_exchanges TEXT DEFAULT '';
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('routing_failure_threshold', '3'),
+    ('routing_failover_cooldown_seconds', '120'),
+    ('routing_taker_fees', '{"binance":0.0005,"okx":0.0005,"hyperliquid":0.00045,"aster":0.00035}')
+ON CONFLICT (key) DO NOTHING;
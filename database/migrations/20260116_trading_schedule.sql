@@ -0,0 +1,10 @@
+-- 交易时段和停牌事件：时段外和停牌事件（FOMC、CPI等）前后只管理现有持仓，不开新仓
+
+ALTER TABLE traders ADD COLUMN IF NOT EXISTS trading_schedule TEXT DEFAULT '';
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('blackout_calendar_file', 'calendar/blackout_events.json'),
+    ('blackout_before_minutes', '30'),
+    ('blackout_after_minutes', '30')
+ON CONFLICT (key) DO NOTHING;
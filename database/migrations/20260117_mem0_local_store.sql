@@ -0,0 +1,41 @@
+-- Mem0本地存储：记忆和向量保存在自有数据库，不发送到第三方服务
+-- 服务启动时也会自动建表；安装pgvector扩展后会额外创建 embedding_vec 列和HNSW索引
+
+CREATE TABLE IF NOT EXISTS mem0_memories (
+    id TEXT PRIMARY KEY,
+    content TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    quality_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT '',
+    reflection_id TEXT,
+    embedding TEXT NOT NULL DEFAULT '[]',
+    embedding_model TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mem0_memories_type ON mem0_memories(type);
+CREATE INDEX IF NOT EXISTS idx_mem0_memories_quality ON mem0_memories(quality_score);
+CREATE INDEX IF NOT EXISTS idx_mem0_memories_created ON mem0_memories(created_at);
+
+CREATE TABLE IF NOT EXISTS mem0_relationships (
+    source_id TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source_id, target_id, type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mem0_relationships_target ON mem0_relationships(target_id);
+
+INSERT INTO system_config (key, value)
+VALUES
+    ('mem0_store_backend', 'http'),
+    ('mem0_embedding_provider', 'hash'),
+    ('mem0_embedding_api_url', ''),
+    ('mem0_embedding_api_key', ''),
+    ('mem0_embedding_model', ''),
+    ('mem0_vector_search', 'auto')
+ON CONFLICT (key) DO NOTHING;
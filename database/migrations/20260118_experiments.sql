@@ -0,0 +1,31 @@
+-- 在线A/B实验：交易员或决策周期按流量分配到不同的prompt模板/自定义prompt/模型/记忆变体
+
+-- 在线A/B实验表 (prompt模板/自定义prompt/模型/记忆变体，变体和交易员以JSON保存)
+CREATE TABLE IF NOT EXISTS experiments (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    status TEXT DEFAULT 'running',
+    assignment TEXT DEFAULT 'trader',
+    trader_ids TEXT NOT NULL,
+    variants TEXT NOT NULL,
+    max_drawdown_pct DECIMAL(10,4) DEFAULT 0,
+    stop_reason TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    stopped_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_user_status ON experiments(user_id, status);
+
+-- 实验变体分配表 (trader模式每个交易员一条，cycle模式每个决策周期一条)
+CREATE TABLE IF NOT EXISTS experiment_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    experiment_id TEXT NOT NULL,
+    trader_id TEXT NOT NULL,
+    variant TEXT NOT NULL,
+    cycle INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiment_assignments_experiment ON experiment_assignments(experiment_id, trader_id, created_at);
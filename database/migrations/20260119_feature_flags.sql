@@ -0,0 +1,20 @@
+-- 功能标志持久化：标志保存在数据库中，各实例定时重载，支持灰度百分比和用户白名单/黑名单
+-- 默认标志由服务启动时写入（不存在时），新闻注入/mem0/新交易所的初始开关沿用原system_config配置
+
+-- 功能标志表 (多实例定时重载，用户白名单/黑名单和元数据以JSON保存，bucketing为空表示沿用legacy灰度分桶)
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name TEXT PRIMARY KEY,
+    description TEXT DEFAULT '',
+    enabled BOOLEAN DEFAULT false,
+    percentage INTEGER DEFAULT 0,
+    allow_users TEXT DEFAULT '',
+    deny_users TEXT DEFAULT '',
+    bucketing TEXT DEFAULT '',
+    metadata TEXT DEFAULT '',
+    updated_by TEXT DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 灰度分桶方式：已有标志为空（legacy，保持原灰度用户不变），内置和新建标志使用salted（按标志名加盐，各标志灰度用户相互独立）
+ALTER TABLE feature_flags ADD COLUMN IF NOT EXISTS bucketing TEXT DEFAULT '';
@@ -0,0 +1,21 @@
+-- 自定义新闻源注册表：管理员和用户可添加RSS/Atom、JSON接口和Webhook新闻源
+-- 系统新闻源（user_id为空）推送到Telegram，用户新闻源只触发该用户交易员的新闻触发器
+
+-- 自定义新闻源表 (RSS/Atom、JSON接口、Webhook；user_id为空表示系统新闻源，请求头和字段映射以JSON保存)
+CREATE TABLE IF NOT EXISTS news_sources (
+    id TEXT PRIMARY KEY,
+    user_id TEXT DEFAULT '',
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    url TEXT DEFAULT '',
+    category TEXT DEFAULT 'crypto',
+    options TEXT DEFAULT '',
+    topic_id INTEGER DEFAULT 0,
+    webhook_secret TEXT DEFAULT '',
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(user_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_news_sources_enabled ON news_sources(enabled);
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NewsFieldMapping JSON新闻源/Webhook的字段映射（点号分隔的路径，如 data.items、attributes.title）
+type NewsFieldMapping struct {
+	Items    string `json:"items,omitempty"`    // 文章数组所在路径（为空表示根节点）
+	Headline string `json:"headline,omitempty"` // 标题（默认 title）
+	Summary  string `json:"summary,omitempty"`  // 摘要（默认 summary）
+	URL      string `json:"url,omitempty"`      // 链接（默认 url）
+	Datetime string `json:"datetime,omitempty"` // 发布时间，Unix秒/毫秒或时间字符串（默认 published_at）
+	Source   string `json:"source,omitempty"`   // 媒体名称（为空时使用新闻源名称）
+}
+
+// NewsSource 自定义新闻源（RSS/Atom、JSON接口、Webhook）
+type NewsSource struct {
+	ID            string            `json:"id"`
+	UserID        string            `json:"user_id"` // 为空表示管理员添加的系统新闻源
+	Name          string            `json:"name"`
+	Type          string            `json:"type"` // rss / json / webhook
+	URL           string            `json:"url,omitempty"`
+	Category      string            `json:"category"`
+	Headers       map[string]string `json:"headers,omitempty"` // 请求头（如API Key）
+	Mapping       NewsFieldMapping  `json:"mapping"`
+	TopicID       int               `json:"topic_id,omitempty"` // 系统新闻源推送的Telegram话题（0使用默认话题）
+	WebhookSecret string            `json:"webhook_secret,omitempty"`
+	Enabled       bool              `json:"enabled"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// newsSourceOptions 请求头和字段映射（以JSON保存在options列）
+type newsSourceOptions struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Mapping NewsFieldMapping  `json:"mapping"`
+}
+
+// NewsSourceRepository 自定义新闻源数据库操作
+type NewsSourceRepository struct {
+	db *sql.DB
+}
+
+// NewNewsSourceRepository 创建新闻源repository
+func NewNewsSourceRepository(db *sql.DB) *NewsSourceRepository {
+	return &NewsSourceRepository{db: db}
+}
+
+// Insert 保存新的新闻源
+func (r *NewsSourceRepository) Insert(s NewsSource) error {
+	options, err := json.Marshal(newsSourceOptions{Headers: s.Headers, Mapping: s.Mapping})
+	if err != nil {
+		return fmt.Errorf("序列化新闻源配置失败: %w", err)
+	}
+
+	query := `
+		INSERT INTO news_sources
+		(id, user_id, name, type, url, category, options, topic_id, webhook_secret, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	if _, err := r.db.Exec(query, s.ID, s.UserID, s.Name, s.Type, s.URL, s.Category, string(options),
+		s.TopicID, s.WebhookSecret, s.Enabled, s.CreatedAt, s.UpdatedAt); err != nil {
+		return fmt.Errorf("保存新闻源失败: %w", err)
+	}
+	return nil
+}
+
+// Update 更新新闻源（类型、所属用户和Webhook密钥不可修改）
+func (r *NewsSourceRepository) Update(s NewsSource) error {
+	options, err := json.Marshal(newsSourceOptions{Headers: s.Headers, Mapping: s.Mapping})
+	if err != nil {
+		return fmt.Errorf("序列化新闻源配置失败: %w", err)
+	}
+
+	query := `
+		UPDATE news_sources
+		SET name = $2, url = $3, category = $4, options = $5, topic_id = $6, enabled = $7, updated_at = $8
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, s.ID, s.Name, s.URL, s.Category, string(options), s.TopicID, s.Enabled, s.UpdatedAt); err != nil {
+		return fmt.Errorf("更新新闻源失败: %w", err)
+	}
+	return nil
+}
+
+// Get 获取新闻源（不存在时返回 sql.ErrNoRows）
+func (r *NewsSourceRepository) Get(id string) (*NewsSource, error) {
+	sources, err := r.query(`WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("新闻源 %s 不存在: %w", id, sql.ErrNoRows)
+	}
+	return &sources[0], nil
+}
+
+// ListForUser 获取用户的新闻源和系统新闻源
+func (r *NewsSourceRepository) ListForUser(userID string) ([]NewsSource, error) {
+	return r.query(`WHERE user_id = $1 OR user_id = ''`, userID)
+}
+
+// ListAll 获取所有新闻源
+func (r *NewsSourceRepository) ListAll() ([]NewsSource, error) {
+	return r.query(``)
+}
+
+// ListEnabled 获取所有已启用的新闻源
+func (r *NewsSourceRepository) ListEnabled() ([]NewsSource, error) {
+	return r.query(`WHERE enabled = true`)
+}
+
+// Delete 删除新闻源
+func (r *NewsSourceRepository) Delete(id string) error {
+	if _, err := r.db.Exec(`DELETE FROM news_sources WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("删除新闻源失败: %w", err)
+	}
+	return nil
+}
+
+// query 按条件查询新闻源（系统新闻源在前，按创建时间排序）
+func (r *NewsSourceRepository) query(where string, args ...interface{}) ([]NewsSource, error) {
+	query := `
+		SELECT id, user_id, name, type, url, category, options, topic_id, webhook_secret, enabled, created_at, updated_at
+		FROM news_sources ` + where + `
+		ORDER BY user_id, created_at
+	`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询新闻源失败: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []NewsSource
+	for rows.Next() {
+		var s NewsSource
+		var options string
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Name, &s.Type, &s.URL, &s.Category, &options,
+			&s.TopicID, &s.WebhookSecret, &s.Enabled, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("解析新闻源失败: %w", err)
+		}
+		if options != "" {
+			var opts newsSourceOptions
+			if err := json.Unmarshal([]byte(options), &opts); err != nil {
+				return nil, fmt.Errorf("解析新闻源 %s 的配置失败: %w", s.ID, err)
+			}
+			s.Headers, s.Mapping = opts.Headers, opts.Mapping
+		}
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// OrderRecord 订单记录结构体（交易所无关的订单状态）
+type OrderRecord struct {
+	ClientOrderID   string
+	ExchangeOrderID string
+	TraderID        string
+	Exchange        string
+	Symbol          string
+	Action          string
+	PositionSide    string
+	OrderType       string
+	Quantity        float64
+	FilledQty       float64
+	AvgPrice        float64
+	Price           float64
+	StopPrice       float64
+	Fee             float64
+	Status          string
+	Error           string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// OrderRepository 订单数据库操作
+type OrderRepository struct {
+	db *sql.DB
+}
+
+// NewOrderRepository 创建订单repository
+func NewOrderRepository(db *sql.DB) *OrderRepository {
+	return &OrderRepository{db: db}
+}
+
+// UpsertOrder 插入或更新订单（以client_order_id为准）
+func (or *OrderRepository) UpsertOrder(record OrderRecord) error {
+	query := `
+		INSERT INTO orders
+		(client_order_id, exchange_order_id, trader_id, exchange, symbol, action, position_side, order_type,
+		 quantity, filled_qty, avg_price, price, stop_price, fee, status, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		ON CONFLICT (client_order_id) DO UPDATE SET
+			exchange_order_id = EXCLUDED.exchange_order_id,
+			quantity = EXCLUDED.quantity,
+			filled_qty = EXCLUDED.filled_qty,
+			avg_price = EXCLUDED.avg_price,
+			price = EXCLUDED.price,
+			stop_price = EXCLUDED.stop_price,
+			fee = EXCLUDED.fee,
+			status = EXCLUDED.status,
+			error = EXCLUDED.error,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := or.db.Exec(
+		query,
+		record.ClientOrderID,
+		record.ExchangeOrderID,
+		record.TraderID,
+		record.Exchange,
+		record.Symbol,
+		record.Action,
+		record.PositionSide,
+		record.OrderType,
+		record.Quantity,
+		record.FilledQty,
+		record.AvgPrice,
+		record.Price,
+		record.StopPrice,
+		record.Fee,
+		record.Status,
+		record.Error,
+		record.CreatedAt,
+		record.UpdatedAt,
+	)
+
+	if err != nil {
+		log.Printf("❌ 保存订单失败: %v", err)
+		return fmt.Errorf("保存订单失败: %w", err)
+	}
+
+	return nil
+}
+
+// GetActiveOrders 获取trader未完结的订单（用于重启后继续对账）
+func (or *OrderRepository) GetActiveOrders(traderID string) ([]OrderRecord, error) {
+	query := `
+		SELECT client_order_id, exchange_order_id, trader_id, exchange, symbol, action, position_side, order_type,
+		       quantity, filled_qty, avg_price, price, stop_price, fee, status, error, created_at, updated_at
+		FROM orders
+		WHERE trader_id = $1 AND status IN ('new', 'partially_filled')
+		ORDER BY created_at ASC
+	`
+
+	rows, err := or.db.Query(query, traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询未完结订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	return scanOrderRecords(rows)
+}
+
+// GetOrders 获取trader的订单（status为空时不过滤状态），按创建时间倒序
+func (or *OrderRepository) GetOrders(traderID, status string, limit int) ([]OrderRecord, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT client_order_id, exchange_order_id, trader_id, exchange, symbol, action, position_side, order_type,
+		       quantity, filled_qty, avg_price, price, stop_price, fee, status, error, created_at, updated_at
+		FROM orders
+		WHERE trader_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := or.db.Query(query, traderID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	return scanOrderRecords(rows)
+}
+
+// scanOrderRecords 扫描订单查询结果
+func scanOrderRecords(rows *sql.Rows) ([]OrderRecord, error) {
+	var records []OrderRecord
+	for rows.Next() {
+		var record OrderRecord
+		err := rows.Scan(
+			&record.ClientOrderID,
+			&record.ExchangeOrderID,
+			&record.TraderID,
+			&record.Exchange,
+			&record.Symbol,
+			&record.Action,
+			&record.PositionSide,
+			&record.OrderType,
+			&record.Quantity,
+			&record.FilledQty,
+			&record.AvgPrice,
+			&record.Price,
+			&record.StopPrice,
+			&record.Fee,
+			&record.Status,
+			&record.Error,
+			&record.CreatedAt,
+			&record.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描订单记录失败: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PositionGroupLeg 组合持仓中的一条腿
+type PositionGroupLeg struct {
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"` // long / short
+	Ratio      float64 `json:"ratio"`
+	Quantity   float64 `json:"quantity"`
+	EntryPrice float64 `json:"entry_price"`
+	Market     string  `json:"market,omitempty"` // spot表示现货腿，为空表示永续合约
+}
+
+// PositionGroup 多腿组合持仓（配对交易/对冲，作为一个整体计算盈亏和止损）
+type PositionGroup struct {
+	ID            string
+	TraderID      string
+	Legs          []PositionGroupLeg
+	Leverage      int
+	StopLossPct   float64 // 组合止损（合计盈亏占开仓名义价值的百分比）
+	TakeProfitPct float64 // 组合止盈
+	Status        string  // open / closed
+	RealizedPnL   float64 // 平仓后的合计盈亏
+	CloseReason   string  // decision / stop_loss / take_profit / leg_missing
+	CreatedAt     time.Time
+	ClosedAt      time.Time
+}
+
+// PositionGroupRepository 组合持仓数据库操作
+type PositionGroupRepository struct {
+	db *sql.DB
+}
+
+// NewPositionGroupRepository 创建组合持仓repository
+func NewPositionGroupRepository(db *sql.DB) *PositionGroupRepository {
+	return &PositionGroupRepository{db: db}
+}
+
+// Insert 保存新开的组合持仓
+func (r *PositionGroupRepository) Insert(g PositionGroup) error {
+	legs, err := json.Marshal(g.Legs)
+	if err != nil {
+		return fmt.Errorf("序列化组合持仓失败: %w", err)
+	}
+
+	query := `
+		INSERT INTO position_groups
+		(id, trader_id, legs, leverage, stop_loss_pct, take_profit_pct, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'open', $7)
+	`
+	if _, err := r.db.Exec(query, g.ID, g.TraderID, string(legs), g.Leverage, g.StopLossPct, g.TakeProfitPct, g.CreatedAt); err != nil {
+		return fmt.Errorf("保存组合持仓失败: %w", err)
+	}
+	return nil
+}
+
+// GetOpen 获取trader未平仓的组合持仓
+func (r *PositionGroupRepository) GetOpen(traderID string) ([]PositionGroup, error) {
+	query := `
+		SELECT id, trader_id, legs, leverage, stop_loss_pct, take_profit_pct, status, created_at
+		FROM position_groups
+		WHERE trader_id = $1 AND status = 'open'
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query, traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询组合持仓失败: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []PositionGroup
+	for rows.Next() {
+		var g PositionGroup
+		var legs string
+		if err := rows.Scan(&g.ID, &g.TraderID, &legs, &g.Leverage, &g.StopLossPct, &g.TakeProfitPct, &g.Status, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("解析组合持仓失败: %w", err)
+		}
+		if err := json.Unmarshal([]byte(legs), &g.Legs); err != nil {
+			return nil, fmt.Errorf("解析组合持仓 %s 的腿失败: %w", g.ID, err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Close 标记组合持仓已平仓
+func (r *PositionGroupRepository) Close(id string, realizedPnL float64, reason string, closedAt time.Time) error {
+	query := `
+		UPDATE position_groups
+		SET status = 'closed', realized_pnl = $2, close_reason = $3, closed_at = $4
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, id, realizedPnL, reason, closedAt); err != nil {
+		return fmt.Errorf("更新组合持仓失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PositionPeak 持仓峰值记录（跟踪止损的高水位和当前止损止盈价）
+type PositionPeak struct {
+	TraderID   string
+	Symbol     string
+	Side       string  // long / short
+	EntryPrice float64 // 开仓均价（变化时视为新持仓重新跟踪）
+	PeakPrice  float64 // 持仓期间最有利价格（多仓最高价，空仓最低价）
+	StopLoss   float64 // 当前已挂出的止损价
+	TakeProfit float64 // 当前已挂出的止盈价
+	BreakEven  bool    // 止损是否已移至保本
+	UpdatedAt  time.Time
+}
+
+// PositionPeakRepository 持仓峰值数据库操作
+type PositionPeakRepository struct {
+	db *sql.DB
+}
+
+// NewPositionPeakRepository 创建持仓峰值repository
+func NewPositionPeakRepository(db *sql.DB) *PositionPeakRepository {
+	return &PositionPeakRepository{db: db}
+}
+
+// Upsert 插入或更新持仓峰值（以trader_id+symbol+side为准）
+func (r *PositionPeakRepository) Upsert(peak PositionPeak) error {
+	query := `
+		INSERT INTO position_peaks
+		(trader_id, symbol, side, entry_price, peak_price, stop_loss, take_profit, break_even, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (trader_id, symbol, side) DO UPDATE SET
+			entry_price = EXCLUDED.entry_price,
+			peak_price = EXCLUDED.peak_price,
+			stop_loss = EXCLUDED.stop_loss,
+			take_profit = EXCLUDED.take_profit,
+			break_even = EXCLUDED.break_even,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(query,
+		peak.TraderID, peak.Symbol, peak.Side, peak.EntryPrice, peak.PeakPrice,
+		peak.StopLoss, peak.TakeProfit, peak.BreakEven, peak.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存持仓峰值失败: %w", err)
+	}
+	return nil
+}
+
+// GetByTrader 获取trader所有持仓的峰值记录
+func (r *PositionPeakRepository) GetByTrader(traderID string) ([]PositionPeak, error) {
+	query := `
+		SELECT trader_id, symbol, side, entry_price, peak_price, stop_loss, take_profit, break_even, updated_at
+		FROM position_peaks
+		WHERE trader_id = $1
+	`
+
+	rows, err := r.db.Query(query, traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询持仓峰值失败: %w", err)
+	}
+	defer rows.Close()
+
+	var peaks []PositionPeak
+	for rows.Next() {
+		var p PositionPeak
+		if err := rows.Scan(&p.TraderID, &p.Symbol, &p.Side, &p.EntryPrice, &p.PeakPrice,
+			&p.StopLoss, &p.TakeProfit, &p.BreakEven, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("解析持仓峰值失败: %w", err)
+		}
+		peaks = append(peaks, p)
+	}
+	return peaks, rows.Err()
+}
+
+// Delete 删除持仓峰值记录（平仓后调用）
+func (r *PositionPeakRepository) Delete(traderID, symbol, side string) error {
+	_, err := r.db.Exec(`DELETE FROM position_peaks WHERE trader_id = $1 AND symbol = $2 AND side = $3`,
+		traderID, symbol, side)
+	if err != nil {
+		return fmt.Errorf("删除持仓峰值失败: %w", err)
+	}
+	return nil
+}
@@ -18,7 +18,18 @@ type TradeRecord struct {
 	Leverage           int
 	HoldingTimeSeconds int64
 	MarginMode         string
-	CreatedAt          time.Time
+	Side               string    // long / short（旧记录为空）
+	Quantity           float64   // 平仓数量（币）
+	RealizedPnL        float64   // 价格盈亏（USDT，不含手续费和资金费）
+	Fees               float64   // 开平仓手续费（USDT）
+	Funding            float64   // 持仓期间支付的资金费（USDT，负数为收取）
+	CloseReason        string    // decision / stop_loss / take_profit / liquidation / exchange
+	CreatedAt          time.Time // 平仓时间
+}
+
+// NetPnL 扣除手续费和资金费后的净盈亏
+func (r TradeRecord) NetPnL() float64 {
+	return r.RealizedPnL - r.Fees - r.Funding
 }
 
 // TradeRepository 交易记录数据库操作
@@ -31,14 +42,19 @@ func NewTradeRepository(db *sql.DB) *TradeRepository {
 	return &TradeRepository{db: db}
 }
 
-// InsertTradeRecord 插入交易记录
+// InsertTradeRecord 插入交易记录（CreatedAt为空时使用当前时间）
 func (tr *TradeRepository) InsertTradeRecord(record TradeRecord) error {
 	query := `
 		INSERT INTO trade_records
-		(trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		(trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode,
+		 side, quantity, realized_pnl, fees, funding, close_reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`
 
+	createdAt := record.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
 	_, err := tr.db.Exec(
 		query,
 		record.TraderID,
@@ -49,7 +65,13 @@ func (tr *TradeRepository) InsertTradeRecord(record TradeRecord) error {
 		record.Leverage,
 		record.HoldingTimeSeconds,
 		record.MarginMode,
-		time.Now(),
+		record.Side,
+		record.Quantity,
+		record.RealizedPnL,
+		record.Fees,
+		record.Funding,
+		record.CloseReason,
+		createdAt,
 	)
 
 	if err != nil {
@@ -63,7 +85,8 @@ func (tr *TradeRepository) InsertTradeRecord(record TradeRecord) error {
 // LoadRecentTradesForTrader 加载指定trader最近的N笔交易
 func (tr *TradeRepository) LoadRecentTradesForTrader(traderID string, limit int) ([]TradeRecord, error) {
 	query := `
-		SELECT id, trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode, created_at
+		SELECT id, trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode,
+		       side, quantity, realized_pnl, fees, funding, close_reason, created_at
 		FROM trade_records
 		WHERE trader_id = $1
 		ORDER BY created_at DESC
@@ -82,7 +105,8 @@ func (tr *TradeRepository) LoadRecentTradesForTrader(traderID string, limit int)
 		var r TradeRecord
 		err := rows.Scan(
 			&r.ID, &r.TraderID, &r.Symbol, &r.EntryPrice, &r.ExitPrice,
-			&r.ProfitPct, &r.Leverage, &r.HoldingTimeSeconds, &r.MarginMode, &r.CreatedAt,
+			&r.ProfitPct, &r.Leverage, &r.HoldingTimeSeconds, &r.MarginMode,
+			&r.Side, &r.Quantity, &r.RealizedPnL, &r.Fees, &r.Funding, &r.CloseReason, &r.CreatedAt,
 		)
 		if err != nil {
 			log.Printf("❌ 扫描交易记录失败: %v", err)
@@ -102,7 +126,8 @@ func (tr *TradeRepository) LoadRecentTradesForTrader(traderID string, limit int)
 // LoadTradesForSymbol 加载指定币种的交易记录
 func (tr *TradeRepository) LoadTradesForSymbol(traderID, symbol string, limit int) ([]TradeRecord, error) {
 	query := `
-		SELECT id, trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode, created_at
+		SELECT id, trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode,
+		       side, quantity, realized_pnl, fees, funding, close_reason, created_at
 		FROM trade_records
 		WHERE trader_id = $1 AND symbol = $2
 		ORDER BY created_at DESC
@@ -120,7 +145,8 @@ func (tr *TradeRepository) LoadTradesForSymbol(traderID, symbol string, limit in
 		var r TradeRecord
 		err := rows.Scan(
 			&r.ID, &r.TraderID, &r.Symbol, &r.EntryPrice, &r.ExitPrice,
-			&r.ProfitPct, &r.Leverage, &r.HoldingTimeSeconds, &r.MarginMode, &r.CreatedAt,
+			&r.ProfitPct, &r.Leverage, &r.HoldingTimeSeconds, &r.MarginMode,
+			&r.Side, &r.Quantity, &r.RealizedPnL, &r.Fees, &r.Funding, &r.CloseReason, &r.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("扫描交易记录失败: %w", err)
@@ -155,7 +181,8 @@ func (tr *TradeRepository) DeleteOldTradeRecords(daysOld int) error {
 // GetTradesInPeriod 获取指定时间段内的交易记录
 func (tr *TradeRepository) GetTradesInPeriod(traderID string, startDate, endDate time.Time) ([]TradeRecord, error) {
 	query := `
-		SELECT id, trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode, created_at
+		SELECT id, trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode,
+		       side, quantity, realized_pnl, fees, funding, close_reason, created_at
 		FROM trade_records
 		WHERE trader_id = $1 AND created_at >= $2 AND created_at <= $3
 		ORDER BY created_at ASC
@@ -172,7 +199,8 @@ func (tr *TradeRepository) GetTradesInPeriod(traderID string, startDate, endDate
 		var r TradeRecord
 		err := rows.Scan(
 			&r.ID, &r.TraderID, &r.Symbol, &r.EntryPrice, &r.ExitPrice,
-			&r.ProfitPct, &r.Leverage, &r.HoldingTimeSeconds, &r.MarginMode, &r.CreatedAt,
+			&r.ProfitPct, &r.Leverage, &r.HoldingTimeSeconds, &r.MarginMode,
+			&r.Side, &r.Quantity, &r.RealizedPnL, &r.Fees, &r.Funding, &r.CloseReason, &r.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("扫描交易记录失败: %w", err)
@@ -326,8 +354,9 @@ func (tr *TradeRepository) BatchInsertTradeRecords(records []TradeRecord) error
 
 	stmt, err := tx.Prepare(`
 		INSERT INTO trade_records
-		(trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		(trader_id, symbol, entry_price, exit_price, profit_pct, leverage, holding_time_seconds, margin_mode,
+		 side, quantity, realized_pnl, fees, funding, close_reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`)
 	if err != nil {
 		return fmt.Errorf("准备语句失败: %w", err)
@@ -335,6 +364,10 @@ func (tr *TradeRepository) BatchInsertTradeRecords(records []TradeRecord) error
 	defer stmt.Close()
 
 	for _, record := range records {
+		createdAt := record.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
 		_, err := stmt.Exec(
 			record.TraderID,
 			record.Symbol,
@@ -344,7 +377,13 @@ func (tr *TradeRepository) BatchInsertTradeRecords(records []TradeRecord) error
 			record.Leverage,
 			record.HoldingTimeSeconds,
 			record.MarginMode,
-			time.Now(),
+			record.Side,
+			record.Quantity,
+			record.RealizedPnL,
+			record.Fees,
+			record.Funding,
+			record.CloseReason,
+			createdAt,
 		)
 		if err != nil {
 			return fmt.Errorf("执行批量插入失败: %w", err)
@@ -60,5 +60,18 @@ func (pd *PatternDetector) DetectFailurePatterns(analysis *TradeAnalysisResult)
 		}
 	}
 
+	// 4. Fee Drag
+	// Logic: Profitable on price moves but losing after fees and funding, typical of over-trading small moves.
+	if analysis.GrossPnL > 0 && analysis.NetPnL < 0 {
+		patterns = append(patterns, FailurePattern{
+			PatternType:    "fee_drag",
+			Frequency:      analysis.TotalTrades,
+			Confidence:     0.9,
+			AffectedTrades: analysis.TotalTrades,
+			ImpactLoss:     analysis.NetPnL,
+			Description:    fmt.Sprintf("Gross PnL is +%.2f USDT but fees (%.2f) and funding (%.2f) turn it into %.2f USDT net. Trade less often, hold for larger moves or prefer maker orders.", analysis.GrossPnL, analysis.TotalFees, analysis.TotalFunding, analysis.NetPnL),
+		})
+	}
+
 	return patterns
 }
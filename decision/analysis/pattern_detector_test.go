@@ -44,6 +44,18 @@ func TestPatternDetector_DetectFailurePatterns(t *testing.T) {
 			},
 			wantType: "poor_timing",
 		},
+		{
+			name: "Fee Drag",
+			analysis: &TradeAnalysisResult{
+				ProfitFactor: 2.0,
+				TotalTrades:  120,
+				GrossPnL:     50,
+				TotalFees:    70,
+				TotalFunding: 5,
+				NetPnL:       -25,
+			},
+			wantType: "fee_drag",
+		},
 		{
 			name:     "No Patterns",
 			analysis: &TradeAnalysisResult{ProfitFactor: 2.0, LoseStreak: 1},
@@ -55,6 +55,13 @@ func (ta *TradeAnalyzer) Analyze(trades []database.TradeRecord) *TradeAnalysisRe
 		pairStat := res.TradeByPairStats[t.Symbol]
 		pairStat.TotalTrades++
 		pairStat.TotalProfit += t.ProfitPct
+		pairStat.GrossPnL += t.RealizedPnL
+		pairStat.NetPnL += t.NetPnL()
+
+		// Fees & Funding
+		res.GrossPnL += t.RealizedPnL
+		res.TotalFees += t.Fees
+		res.TotalFunding += t.Funding
 
 		// Hour Stats
 		hour := t.CreatedAt.Hour()
@@ -96,6 +103,7 @@ func (ta *TradeAnalyzer) Analyze(trades []database.TradeRecord) *TradeAnalysisRe
 	}
 
 	// Final Calculations
+	res.NetPnL = res.GrossPnL - res.TotalFees - res.TotalFunding
 	if res.TotalTrades > 0 {
 		res.WinRate = float64(res.WinningTrades) / float64(res.TotalTrades) * 100
 		res.AvgHoldingTime = time.Duration(totalHoldingTime/int64(res.TotalTrades)) * time.Second
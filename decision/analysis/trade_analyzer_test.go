@@ -82,3 +82,23 @@ func TestTradeAnalyzer_Analyze(t *testing.T) {
 		})
 	}
 }
+
+func TestTradeAnalyzer_GrossVsNetPnL(t *testing.T) {
+	now := time.Now()
+	trades := []database.TradeRecord{
+		{Symbol: "DOGEUSDT", ProfitPct: -0.5, RealizedPnL: 2.0, Fees: 2.4, Funding: 0.1, CreatedAt: now},
+		{Symbol: "DOGEUSDT", ProfitPct: 1.0, RealizedPnL: 5.0, Fees: 2.5, Funding: -0.2, CreatedAt: now},
+	}
+
+	result := NewTradeAnalyzer(&MockTradeProvider{}).Analyze(trades)
+	if result.GrossPnL != 7.0 || result.TotalFees != 4.9 {
+		t.Errorf("GrossPnL = %v, TotalFees = %v", result.GrossPnL, result.TotalFees)
+	}
+	if diff := result.NetPnL - 2.2; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("NetPnL = %v, want 2.2", result.NetPnL)
+	}
+	pair := result.TradeByPairStats["DOGEUSDT"]
+	if diff := pair.NetPnL - 2.2; pair.GrossPnL != 7.0 || diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("pair stats = %+v", pair)
+	}
+}
@@ -31,6 +31,12 @@ type TradeAnalysisResult struct {
 	Volatility          float64 // Standard deviation of returns
 	WeightedWinRate     float64 // Win rate with time decay weighting
 
+	// Fee and funding accounting (USDT)
+	GrossPnL     float64 // Price PnL before fees and funding
+	TotalFees    float64 // Trading fees paid
+	TotalFunding float64 // Funding paid (negative when received)
+	NetPnL       float64 // GrossPnL - TotalFees - TotalFunding
+
 	// Detailed breakdown
 	TradeByPairStats map[string]*PairStats
 	TradeByHourStats map[int]*HourStats
@@ -44,6 +50,8 @@ type PairStats struct {
 	WinRate     float64
 	AvgProfit   float64
 	TotalProfit float64
+	GrossPnL    float64 // Price PnL before fees and funding (USDT)
+	NetPnL      float64 // PnL after fees and funding (USDT)
 }
 
 // SymbolStats holds detailed per-symbol performance metrics (NEW)
@@ -35,6 +35,16 @@ type AccountInfo struct {
         MarginUsed       float64 `json:"margin_used"`       // 已用保证金
         MarginUsedPct    float64 `json:"margin_used_pct"`   // 保证金使用率
         PositionCount    int     `json:"position_count"`    // 持仓数量
+
+        Venues []VenueBalance `json:"venues,omitempty"` // 跨交易所路由时各交易所的余额（合计即上面的净值和余额）
+}
+
+// VenueBalance 跨交易所路由中单个交易所的余额
+type VenueBalance struct {
+        Exchange         string  `json:"exchange"`
+        TotalEquity      float64 `json:"total_equity"`
+        AvailableBalance float64 `json:"available_balance"`
+        Available        bool    `json:"available"` // false表示该交易所暂停路由（API故障），余额为最近一次查询值
 }
 
 // CandidateCoin 候选币种（来自币种池）
@@ -70,19 +80,38 @@ type Context struct {
         CooldownMinutes  int                     `json:"-"` // 平仓后的冷却期（分钟）
         Extensions       map[string]interface{}  `json:"-"` // 可扩展的上下文数据 (新闻、社交情绪等)
         MlionAPIKey      string                  `json:"-"` // Mlion新闻API密钥
+        MarketDataProvider func(symbol string) (*market.Data, error) `json:"-"` // 市场数据来源（nil时使用market.Get实时数据，回测时注入历史数据）
+        DisableNews        bool                                        `json:"-"` // 禁用新闻enrichment（回测/回放时避免访问实时新闻）
+        OrderNotices       []string                                    `json:"-"` // 上个周期以来的订单状态变化（部分成交、拒单、止损止盈被撤销等）
+        PositionGroups     []PositionGroupInfo                         `json:"-"` // 多腿组合持仓（配对交易/对冲）
+        EntryRestriction   string                                      `json:"-"` // 禁止开新仓的原因（交易时段外/停牌事件前后），为空表示允许开仓
+        Enrichers          []ContextEnricher                           `json:"-"` // 获取市场数据后执行的额外增强器（历史交易记忆等）
+}
+
+// AIClient AI调用接口
+// mcp.Client 实现了该接口；回测/回放时可注入录制的AI响应
+type AIClient interface {
+        CallWithMessages(systemPrompt, userPrompt string) (string, error)
 }
 
 // Decision AI的交易决策
 type Decision struct {
         Symbol          string  `json:"symbol"`
-        Action          string  `json:"action"`             // "open_long", "open_short", "close_long", "close_short", "hold", "wait"
+        Action          string  `json:"action"`             // "open_long", "open_short", "close_long", "close_short", "open_pair", "close_pair", "hold", "wait"
         Leverage        float64 `json:"leverage,omitempty"` // 改为 float64 以支持 AI 返回的小数杠杆
         PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
         StopLoss        float64 `json:"stop_loss,omitempty"`
         TakeProfit      float64 `json:"take_profit,omitempty"`
         Confidence      int     `json:"confidence,omitempty"` // 信心度 (0-100)
         RiskUSD         float64 `json:"risk_usd,omitempty"`   // 最大美元风险
+        Execution       string  `json:"execution,omitempty"`  // 开仓执行方式（可选）: market, limit_mid, post_only, twap，为空时使用交易员配置
         Reasoning       string  `json:"reasoning"`
+
+        // 多腿决策（open_pair/close_pair）
+        Legs          []DecisionLeg `json:"legs,omitempty"`            // 各腿币种、方向和名义价值权重
+        GroupID       string        `json:"group_id,omitempty"`        // close_pair 要平掉的组合ID
+        StopLossPct   float64       `json:"stop_loss_pct,omitempty"`   // 组合止损：合计盈亏低于开仓名义价值的该百分比时全部平仓
+        TakeProfitPct float64       `json:"take_profit_pct,omitempty"` // 组合止盈：合计盈亏高于开仓名义价值的该百分比时全部平仓
 }
 
 // FullDecision AI的完整决策（包含思维链）
@@ -90,8 +119,12 @@ type FullDecision struct {
         SystemPrompt string     `json:"system_prompt"` // 系统提示词（发送给AI的系统prompt）
         UserPrompt   string     `json:"user_prompt"`   // 发送给AI的输入prompt
         CoTTrace     string     `json:"cot_trace"`     // 思维链分析（AI输出）
+        RawResponse  string     `json:"raw_response"`  // AI原始响应（用于回放）
+        DecisionMode string     `json:"decision_mode"` // 决策产生方式（tool_calling/text/text_fallback/ensemble）
         Decisions    []Decision `json:"decisions"`     // 具体决策列表
         Timestamp    time.Time  `json:"timestamp"`
+
+        Disagreements []Disagreement `json:"disagreements,omitempty"` // 多模型投票时的分歧
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -100,7 +133,8 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 }
 
 // GetFullDecisionWithCustomPrompt 获取AI的完整交易决策（支持自定义prompt和模板选择）
-func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
+// aiClient 为 EnsembleClient 时并行询问多个模型并按策略投票合并，回测引擎通过它注入模拟AI
+func GetFullDecisionWithCustomPrompt(ctx *Context, aiClient AIClient, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
         // 1. 为所有币种获取市场数据
         if err := fetchMarketDataForContext(ctx); err != nil {
                 return nil, fmt.Errorf("获取市场数据失败: %w", err)
@@ -116,7 +150,7 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
         mlionFetcher := news.NewMlionFetcher(ctx.MlionAPIKey) // 使用Context中的API Key
         newsEnricher := NewNewsEnricher(mlionFetcher)
 
-        if newsEnricher.IsEnabled(ctx) {
+        if !ctx.DisableNews && newsEnricher.IsEnabled(ctx) {
                 if err := newsEnricher.Enrich(ctx); err != nil {
                         log.Printf("⚠️ 新闻enrichment失败: %v (继续执行，不影响决策)", err)
                         // Fail-safe: 新闻获取失败不影响交易流程
@@ -125,36 +159,74 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
                 }
         }
 
+        // 交易员注入的增强器（如历史交易记忆）依赖市场数据，在此统一执行，失败不影响决策
+        if len(ctx.Enrichers) > 0 {
+                chain := NewEnrichmentChain()
+                for _, enricher := range ctx.Enrichers {
+                        chain.AddEnricher(enricher)
+                }
+                chain.ExecuteAllNonFatal(ctx)
+        }
+
         // 3. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
         systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
         userPrompt := buildUserPrompt(ctx)
 
-        // 3. 调用AI API（使用 system + user prompt）
-        aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+        // 3. 调用AI API（使用 system + user prompt，支持时使用tool calling）
+        aiResponse, systemPrompt, mode, err := callAIForDecision(aiClient, systemPrompt, userPrompt)
         if err != nil {
                 // 检查是否为余额不足错误
                 if strings.Contains(err.Error(), "Insufficient Balance") || strings.Contains(err.Error(), "余额不足") {
                         separator := strings.Repeat("!", 70)
                         fmt.Printf("\n%s\n", separator)
                         fmt.Println("❌ 严重错误: AI API 余额不足！")
-                        fmt.Printf("👉 请检查您的 AI 服务提供商 (%s) 账户余额\n", mcpClient.Provider)
+                        if mcpClient, ok := aiClient.(*mcp.Client); ok {
+                                fmt.Printf("👉 请检查您的 AI 服务提供商 (%s) 账户余额\n", mcpClient.Provider)
+                        }
                         fmt.Println("👉 或者尝试切换到其他 AI 模型 (在配置中修改)")
                         fmt.Printf("%s\n\n", separator)
                 }
                 return nil, fmt.Errorf("调用AI API失败: %w", err)
         }
 
-        // 4. 解析AI响应
-        decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+        // 4. 解析并验证AI响应
+        decision, err := ParseAndValidateResponse(ctx, aiResponse)
+        if decision != nil {
+                decision.SystemPrompt = systemPrompt // 保存系统prompt
+                decision.UserPrompt = userPrompt     // 保存输入prompt
+                if mode == DecisionModeTextFallback {
+                        decision.DecisionMode = mode
+                }
+                log.Printf("🧾 决策模式: %s", decision.DecisionMode)
+        }
+        return decision, err
+}
+
+// ParseAndValidateResponse 解析AI响应并执行验证和去重（实盘与决策回放共用同一路径）
+// 录制的工具调用响应按结构化结果解析，其余按文本提取JSON
+func ParseAndValidateResponse(ctx *Context, aiResponse string) (*FullDecision, error) {
+        var decision *FullDecision
+        var err error
+        if envelope, ok := decodeEnsembleResponse(aiResponse); ok {
+                decision, err = parseEnsembleResponse(ctx, envelope)
+        } else if envelope, ok := decodeToolCallResponse(aiResponse); ok {
+                decision, err = parseToolCallResponse(envelope, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+        } else {
+                decision, err = parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+                if decision != nil {
+                        decision.DecisionMode = DecisionModeText
+                }
+        }
+        if decision != nil {
+                decision.RawResponse = aiResponse
+        }
         if err != nil {
                 return decision, fmt.Errorf("解析AI响应失败: %w", err)
         }
 
         decision.Timestamp = time.Now()
-        decision.SystemPrompt = systemPrompt // 保存系统prompt
-        decision.UserPrompt = userPrompt     // 保存输入prompt
 
-        // 5. 验证和去重决策（防止同币种重复开仓、位置冲突等）
+        // 验证和去重决策（防止同币种重复开仓、位置冲突等）
         if len(decision.Decisions) > 0 {
                 cooldownMin := ctx.CooldownMinutes
                 if cooldownMin == 0 {
@@ -208,8 +280,13 @@ func fetchMarketDataForContext(ctx *Context) error {
                 positionSymbols[pos.Symbol] = true
         }
 
+        getData := market.Get
+        if ctx.MarketDataProvider != nil {
+                getData = ctx.MarketDataProvider
+        }
+
         for symbol := range symbolSet {
-                data, err := market.Get(symbol)
+                data, err := getData(symbol)
                 if err != nil {
                         // 单个币种失败不影响整体，只记录错误
                         continue
@@ -333,7 +410,10 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
         sb.WriteString("字段说明:\n")
         sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
         sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
-        sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
+        sb.WriteString("- `position_size_usd`: 仅作参考，实际仓位由系统按单笔风险预算（止损距离/ATR）、凯利比例和币种上限核定；`confidence` 越高仓位越大\n")
+        sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n")
+        sb.WriteString("- 开仓时可选: execution（market=市价, limit_mid=中间价限价, post_only=只做Maker, twap=大额分批），不填则使用默认执行方式\n")
+        sb.WriteString("- 配对/对冲（可选）: `open_pair` 同时开2-4条腿并作为一个组合管理，必填 legs（[{\"symbol\": \"ETHUSDT\", \"side\": \"long\", \"ratio\": 1}, {\"symbol\": \"BTCUSDT\", \"side\": \"short\", \"ratio\": 1}]，至少一多一空；腿可设 \"market\": \"spot\" 表示现货腿（只能做多、不加杠杆），可与同币种永续空头组成资金费套利）, leverage, position_size_usd（组合总名义价值）, stop_loss_pct, take_profit_pct（组合盈亏占名义价值的百分比）, confidence, reasoning；`close_pair` 按 group_id 平掉整个组合\n\n")
 
         return sb.String()
 }
@@ -508,6 +588,22 @@ func buildUserPrompt(ctx *Context) string {
                 ctx.Account.TotalPnLPct,
                 ctx.Account.MarginUsedPct,
                 ctx.Account.PositionCount))
+        if len(ctx.Account.Venues) > 0 {
+                venues := make([]string, 0, len(ctx.Account.Venues))
+                for _, v := range ctx.Account.Venues {
+                        status := ""
+                        if !v.Available {
+                                status = " (暂停)"
+                        }
+                        venues = append(venues, fmt.Sprintf("%s 净值%.2f 余额%.2f%s", v.Exchange, v.TotalEquity, v.AvailableBalance, status))
+                }
+                sb.WriteString("交易所: " + strings.Join(venues, " | ") + "（开仓由系统按价格和手续费自动选择交易所）\n\n")
+        }
+
+        // 交易时段限制（只允许平仓或持有，开仓决策会被系统拦截）
+        if ctx.EntryRestriction != "" {
+                sb.WriteString(fmt.Sprintf("⏸ %s：本周期只管理现有持仓（平仓/持有），不要开新仓\n\n", ctx.EntryRestriction))
+        }
 
         // 持仓（完整市场数据）
         if len(ctx.Positions) > 0 {
@@ -542,6 +638,29 @@ func buildUserPrompt(ctx *Context) string {
                 sb.WriteString("当前持仓: 无\n\n")
         }
 
+        // 多腿组合持仓（永续腿同时出现在上面的持仓列表中，现货腿只在这里显示，止损止盈按组合整体计算）
+        if len(ctx.PositionGroups) > 0 {
+                sb.WriteString("## 🔗 组合持仓\n\n")
+                for _, g := range ctx.PositionGroups {
+                        legs := make([]string, 0, len(g.Legs))
+                        for _, leg := range g.Legs {
+                                legs = append(legs, fmt.Sprintf("%s %s×%.2f", leg.Label(), strings.ToUpper(leg.Side), leg.Ratio))
+                        }
+                        sb.WriteString(fmt.Sprintf("- group_id=%s | %s | 名义价值%.2f | 合计盈亏%+.2f (%+.2f%%) | 止损-%.2f%% 止盈+%.2f%% | 持仓%d分钟\n",
+                                g.ID, strings.Join(legs, " + "), g.EntryNotional, g.UnrealizedPnL, g.PnLPct, g.StopLossPct, g.TakeProfitPct, g.HoldingMins))
+                }
+                sb.WriteString("\n")
+        }
+
+        // 订单状态变化（止损止盈被撤销时持仓失去保护，需要AI重新评估）
+        if len(ctx.OrderNotices) > 0 {
+                sb.WriteString("## 📋 订单状态变化\n\n")
+                for _, notice := range ctx.OrderNotices {
+                        sb.WriteString(fmt.Sprintf("- %s\n", notice))
+                }
+                sb.WriteString("\n")
+        }
+
         // 冷却期币种（最近平仓，禁止立即重新开仓）
         if len(ctx.LastCloseTime) > 0 {
                 now := time.Now().UnixMilli()
@@ -683,6 +802,9 @@ func buildUserPrompt(ctx *Context) string {
                 }
         }
 
+        // 相似行情下的历史交易（按交易员学习阶段过滤并压缩）
+        sb.WriteString(buildMemorySection(ctx))
+
         // 【P0修复】: 添加新闻信息部分 - 基本面分析
         if newsCtx, ok := ctx.GetExtension("news"); ok {
                 if newsContext, isNewsCtx := newsCtx.(*NewsContext); isNewsCtx && newsContext != nil && newsContext.Enabled && len(newsContext.Articles) > 0 {
@@ -837,8 +959,8 @@ func fixMissingQuotes(jsonStr string) string {
 
 // validateDecisions 验证所有决策（需要账户信息和杠杆配置）
 func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
-        for i, decision := range decisions {
-                if err := validateDecision(&decision, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
+        for i := range decisions {
+                if err := validateDecision(&decisions[i], accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
                         return fmt.Errorf("决策 #%d 验证失败: %w", i+1, err)
                 }
         }
@@ -875,6 +997,8 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
                 "open_short":  true,
                 "close_long":  true,
                 "close_short": true,
+                "open_pair":   true,
+                "close_pair":  true,
                 "hold":        true,
                 "wait":        true,
         }
@@ -883,6 +1007,16 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
                 return fmt.Errorf("无效的action: %s", d.Action)
         }
 
+        if d.Execution != "" {
+                if _, err := ParseExecutionMode(d.Execution); err != nil {
+                        return err
+                }
+        }
+
+        if d.Action == ActionOpenPair || d.Action == ActionClosePair {
+                return validateMultiLegDecision(d, accountEquity, btcEthLeverage, altcoinLeverage)
+        }
+
         // 开仓操作必须提供完整参数
         if d.Action == "open_long" || d.Action == "open_short" {
                 // 根据币种使用配置的杠杆上限
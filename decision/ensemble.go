@@ -0,0 +1,317 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// DecisionModeEnsemble 多模型投票产生的决策
+const DecisionModeEnsemble = "ensemble"
+
+// EnsemblePolicy 多模型决策合并策略
+type EnsemblePolicy string
+
+const (
+	EnsembleUnanimous EnsemblePolicy = "unanimous" // 全票通过才执行
+	EnsembleMajority  EnsemblePolicy = "majority"  // 过半数模型同意即执行
+	EnsembleWeighted  EnsemblePolicy = "weighted"  // 按信心度加权，超过总权重一半即执行
+)
+
+// defaultVoteConfidence 未给出信心度的投票（含隐式观望）使用的权重
+const defaultVoteConfidence = 50
+
+// ParseEnsemblePolicy 解析合并策略（为空时默认majority）
+func ParseEnsemblePolicy(s string) (EnsemblePolicy, error) {
+	switch policy := EnsemblePolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return EnsembleMajority, nil
+	case EnsembleUnanimous, EnsembleMajority, EnsembleWeighted:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("未知的集成策略: %s", s)
+	}
+}
+
+// EnsembleMember 参与投票的模型
+type EnsembleMember struct {
+	Name   string
+	Client AIClient
+}
+
+// ModelVote 单个模型对某币种的投票
+type ModelVote struct {
+	Model      string `json:"model"`
+	Action     string `json:"action"`
+	Confidence int    `json:"confidence"`
+	Failed     bool   `json:"failed,omitempty"` // 模型调用或解析失败，按观望计票
+}
+
+// Disagreement 模型之间的分歧
+type Disagreement struct {
+	Symbol  string      `json:"symbol"`
+	Votes   []ModelVote `json:"votes"`
+	Outcome string      `json:"outcome"` // 最终采用的动作，未达成共识时为 no_consensus
+}
+
+// EnsembleClient 多模型并行决策客户端
+// 并行调用所有模型，将各自的原始响应打包成一个可录制的响应，由ParseAndValidateResponse按策略合并
+type EnsembleClient struct {
+	Members []EnsembleMember
+	Policy  EnsemblePolicy
+}
+
+// NewEnsembleClient 创建多模型决策客户端
+func NewEnsembleClient(policy EnsemblePolicy, members ...EnsembleMember) *EnsembleClient {
+	return &EnsembleClient{Members: members, Policy: policy}
+}
+
+// ensembleEnvelope 多模型响应的录制格式（保存在RawResponse中，回放时重新合并）
+type ensembleEnvelope struct {
+	EnsemblePolicy EnsemblePolicy           `json:"ensemble_policy"`
+	Members        []ensembleMemberResponse `json:"members"`
+}
+
+// ensembleMemberResponse 单个模型的原始响应
+type ensembleMemberResponse struct {
+	Model    string `json:"model"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CallWithMessages 并行调用所有模型（实现AIClient接口）
+func (e *EnsembleClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	if len(e.Members) == 0 {
+		return "", fmt.Errorf("集成决策未配置任何模型")
+	}
+
+	responses := make([]ensembleMemberResponse, len(e.Members))
+	var wg sync.WaitGroup
+	for i, member := range e.Members {
+		wg.Add(1)
+		go func(i int, member EnsembleMember) {
+			defer wg.Done()
+			responses[i].Model = member.Name
+			response, _, _, err := callAIForDecision(member.Client, systemPrompt, userPrompt)
+			if err != nil {
+				log.Printf("⚠️ 集成模型 %s 调用失败: %v", member.Name, err)
+				responses[i].Error = err.Error()
+				return
+			}
+			responses[i].Response = response
+		}(i, member)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range responses {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if failed == len(responses) {
+		return "", fmt.Errorf("所有集成模型调用失败: %s", responses[0].Error)
+	}
+
+	data, err := json.Marshal(ensembleEnvelope{EnsemblePolicy: e.Policy, Members: responses})
+	if err != nil {
+		return "", fmt.Errorf("编码集成响应失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeEnsembleResponse 识别录制的多模型响应
+func decodeEnsembleResponse(aiResponse string) (*ensembleEnvelope, bool) {
+	trimmed := strings.TrimSpace(aiResponse)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var envelope ensembleEnvelope
+	if err := json.Unmarshal([]byte(trimmed), &envelope); err != nil || envelope.EnsemblePolicy == "" || len(envelope.Members) == 0 {
+		return nil, false
+	}
+	return &envelope, true
+}
+
+// memberDecisions 单个模型解析后的决策
+type memberDecisions struct {
+	Model     string
+	Decisions []Decision
+	Failed    bool // 调用或解析失败（所有币种按观望计票）
+}
+
+// parseEnsembleResponse 分别解析每个模型的响应并按策略合并
+// 调用或解析失败的模型仍计入配置的模型数量，按观望计票，避免少数模型在其余模型失败时单独决定交易
+func parseEnsembleResponse(ctx *Context, envelope *ensembleEnvelope) (*FullDecision, error) {
+	full := &FullDecision{Decisions: []Decision{}, DecisionMode: DecisionModeEnsemble}
+
+	members := make([]memberDecisions, 0, len(envelope.Members))
+	failed := 0
+	var cot strings.Builder
+	for _, member := range envelope.Members {
+		fmt.Fprintf(&cot, "【%s】\n", member.Model)
+		if member.Error != "" {
+			fmt.Fprintf(&cot, "调用失败: %s\n\n", member.Error)
+			members = append(members, memberDecisions{Model: member.Model, Failed: true})
+			failed++
+			continue
+		}
+		decision, err := ParseAndValidateResponse(ctx, member.Response)
+		if err != nil {
+			log.Printf("⚠️ 集成模型 %s 响应解析失败，按观望计票: %v", member.Model, err)
+			fmt.Fprintf(&cot, "解析失败: %v\n\n", err)
+			members = append(members, memberDecisions{Model: member.Model, Failed: true})
+			failed++
+			continue
+		}
+		cot.WriteString(strings.TrimSpace(decision.CoTTrace))
+		cot.WriteString("\n\n")
+		members = append(members, memberDecisions{Model: member.Model, Decisions: decision.Decisions})
+	}
+	full.CoTTrace = strings.TrimSpace(cot.String())
+
+	if failed == len(members) {
+		return full, fmt.Errorf("没有可用的模型决策")
+	}
+
+	full.Decisions, full.Disagreements = mergeEnsembleDecisions(envelope.EnsemblePolicy, members)
+	if len(full.Disagreements) > 0 || failed > 0 {
+		log.Printf("🗳️ 集成决策 (%s): %d/%d个模型有效, %d个币种存在分歧",
+			envelope.EnsemblePolicy, len(members)-failed, len(members), len(full.Disagreements))
+	}
+	return full, nil
+}
+
+// mergeEnsembleDecisions 按策略合并多个模型的决策
+// 每个模型对每个币种投一票（未提及的币种和失败的模型视为观望），未达成共识的币种不操作
+// close_pair 按组合ID计票，平不同组合的决策互不合并
+func mergeEnsembleDecisions(policy EnsemblePolicy, members []memberDecisions) ([]Decision, []Disagreement) {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, m := range members {
+		for _, d := range m.Decisions {
+			key := voteKey(d)
+			if !seen[key] {
+				seen[key] = true
+				symbols = append(symbols, key)
+			}
+		}
+	}
+
+	merged := []Decision{}
+	var disagreements []Disagreement
+	for _, symbol := range symbols {
+		votes := make([]ModelVote, 0, len(members))
+		picks := make([]*Decision, 0, len(members))
+		counts := make(map[string]int)
+		weights := make(map[string]int)
+		var actions []string
+		totalWeight := 0
+
+		for _, m := range members {
+			pick := memberPick(m.Decisions, symbol)
+			vote := ModelVote{Model: m.Model, Action: "wait", Confidence: defaultVoteConfidence, Failed: m.Failed}
+			if pick != nil {
+				vote.Action = voteAction(pick.Action)
+				if pick.Confidence > 0 {
+					vote.Confidence = pick.Confidence
+				}
+			}
+			if counts[vote.Action] == 0 {
+				actions = append(actions, vote.Action)
+			}
+			counts[vote.Action]++
+			weights[vote.Action] += vote.Confidence
+			totalWeight += vote.Confidence
+			votes = append(votes, vote)
+			picks = append(picks, pick)
+		}
+
+		winner := ""
+		for _, action := range actions {
+			switch policy {
+			case EnsembleUnanimous:
+				if counts[action] == len(members) {
+					winner = action
+				}
+			case EnsembleWeighted:
+				if weights[action]*2 > totalWeight {
+					winner = action
+				}
+			default:
+				if counts[action]*2 > len(members) {
+					winner = action
+				}
+			}
+		}
+
+		if len(actions) > 1 {
+			outcome := winner
+			if outcome == "" {
+				outcome = "no_consensus"
+			}
+			disagreements = append(disagreements, Disagreement{Symbol: symbol, Votes: votes, Outcome: outcome})
+		}
+		if winner == "" {
+			continue
+		}
+
+		// 采用同意该动作的模型中信心度最高的决策参数，信心度取平均值
+		var best *Decision
+		sumConfidence, agreeing := 0, 0
+		for i, pick := range picks {
+			if votes[i].Action != winner || pick == nil {
+				continue
+			}
+			sumConfidence += pick.Confidence
+			agreeing++
+			if best == nil || pick.Confidence > best.Confidence {
+				best = pick
+			}
+		}
+		if best == nil {
+			continue // 全部为隐式观望
+		}
+		d := *best
+		d.Confidence = sumConfidence / agreeing
+		merged = append(merged, d)
+	}
+
+	return merged, disagreements
+}
+
+// voteKey 决策的计票键：close_pair 按组合ID，其余按币种（open_pair 为各腿组成的组合名称）
+func voteKey(d Decision) string {
+	if d.Action == ActionClosePair {
+		return GroupLabel(d.GroupID)
+	}
+	return d.Symbol
+}
+
+// memberPick 取模型对某币种的决策（优先取交易动作）
+func memberPick(decisions []Decision, symbol string) *Decision {
+	var fallback *Decision
+	for i := range decisions {
+		d := &decisions[i]
+		if voteKey(*d) != symbol {
+			continue
+		}
+		if voteAction(d.Action) != "wait" {
+			return d
+		}
+		if fallback == nil {
+			fallback = d
+		}
+	}
+	return fallback
+}
+
+// voteAction 投票时将hold与wait视为同一动作（都不交易）
+func voteAction(action string) string {
+	if action == "hold" {
+		return "wait"
+	}
+	return action
+}
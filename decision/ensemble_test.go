@@ -0,0 +1,198 @@
+package decision
+
+import (
+	"fmt"
+	"testing"
+)
+
+// textClient 返回固定文本的AI客户端
+type textClient string
+
+func (c textClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return string(c), nil
+}
+
+func TestMergeEnsembleDecisions(t *testing.T) {
+	members := []memberDecisions{
+		{Model: "deepseek", Decisions: []Decision{
+			{Symbol: "BTCUSDT", Action: "open_long", Leverage: 3, Confidence: 80},
+			{Symbol: "ETHUSDT", Action: "close_short", Confidence: 70},
+		}},
+		{Model: "qwen", Decisions: []Decision{
+			{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, Confidence: 90},
+			{Symbol: "ETHUSDT", Action: "hold", Confidence: 60},
+		}},
+		{Model: "gemini", Decisions: []Decision{
+			{Symbol: "BTCUSDT", Action: "open_short", Confidence: 95},
+		}},
+	}
+
+	tests := []struct {
+		policy        EnsemblePolicy
+		wantActions   map[string]string
+		wantOutcomeBT string
+	}{
+		{EnsembleMajority, map[string]string{"BTCUSDT": "open_long", "ETHUSDT": "hold"}, "open_long"},
+		{EnsembleUnanimous, map[string]string{}, "no_consensus"},
+		{EnsembleWeighted, map[string]string{"BTCUSDT": "open_long", "ETHUSDT": "hold"}, "open_long"},
+	}
+
+	for _, tt := range tests {
+		decisions, disagreements := mergeEnsembleDecisions(tt.policy, members)
+
+		got := make(map[string]string)
+		for _, d := range decisions {
+			got[d.Symbol] = d.Action
+		}
+		if len(got) != len(tt.wantActions) {
+			t.Errorf("%s: 决策数量错误 got %v want %v", tt.policy, got, tt.wantActions)
+		}
+		for symbol, action := range tt.wantActions {
+			if got[symbol] != action {
+				t.Errorf("%s: %s 应为 %s, got %s", tt.policy, symbol, action, got[symbol])
+			}
+		}
+
+		if len(disagreements) != 2 {
+			t.Fatalf("%s: 两个币种都存在分歧, got %d", tt.policy, len(disagreements))
+		}
+		if disagreements[0].Symbol != "BTCUSDT" || disagreements[0].Outcome != tt.wantOutcomeBT || len(disagreements[0].Votes) != 3 {
+			t.Errorf("%s: BTC分歧记录错误: %+v", tt.policy, disagreements[0])
+		}
+	}
+
+	// 采用信心度最高的参数，信心度取平均
+	decisions, _ := mergeEnsembleDecisions(EnsembleMajority, members)
+	for _, d := range decisions {
+		if d.Symbol == "BTCUSDT" && (d.Leverage != 5 || d.Confidence != 85) {
+			t.Errorf("合并参数错误: %+v", d)
+		}
+	}
+}
+
+func TestMergeEnsembleClosePairByGroup(t *testing.T) {
+	members := []memberDecisions{
+		{Model: "deepseek", Decisions: []Decision{
+			{Action: ActionClosePair, GroupID: "g1", Confidence: 80},
+		}},
+		{Model: "qwen", Decisions: []Decision{
+			{Action: ActionClosePair, GroupID: "g1", Confidence: 70},
+			{Action: ActionClosePair, GroupID: "g2", Confidence: 90},
+		}},
+		{Model: "gemini", Decisions: []Decision{
+			{Symbol: "BTCUSDT", Action: "open_long", Leverage: 3, Confidence: 60},
+		}},
+	}
+
+	decisions, disagreements := mergeEnsembleDecisions(EnsembleMajority, members)
+	if len(decisions) != 1 || decisions[0].Action != ActionClosePair || decisions[0].GroupID != "g1" {
+		t.Fatalf("应只平多数同意的组合g1: %+v", decisions)
+	}
+	labels := make(map[string]string)
+	for _, d := range disagreements {
+		labels[d.Symbol] = d.Outcome
+	}
+	if labels[GroupLabel("g1")] != ActionClosePair || labels[GroupLabel("g2")] != "wait" {
+		t.Errorf("组合分歧记录错误: %+v", disagreements)
+	}
+}
+
+func TestEnsembleClientDecision(t *testing.T) {
+	long := textClient(`看多
+[{"symbol":"BTCUSDT","action":"open_long","leverage":3,"position_size_usd":500,"stop_loss":95,"take_profit":130,"confidence":80,"reasoning":"突破"}]`)
+	wait := textClient(`观望
+[{"symbol":"BTCUSDT","action":"wait","reasoning":"震荡"}]`)
+
+	client := NewEnsembleClient(EnsembleUnanimous,
+		EnsembleMember{Name: "a", Client: long},
+		EnsembleMember{Name: "b", Client: long},
+		EnsembleMember{Name: "c", Client: wait},
+	)
+
+	response, err := client.CallWithMessages("system", "user")
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+
+	decision, err := ParseAndValidateResponse(testContext(), response)
+	if err != nil {
+		t.Fatalf("解析集成响应失败: %v", err)
+	}
+	if decision.DecisionMode != DecisionModeEnsemble {
+		t.Errorf("决策模式应为ensemble, got %s", decision.DecisionMode)
+	}
+	if len(decision.Decisions) != 0 {
+		t.Errorf("全票策略下存在分歧时不应交易: %+v", decision.Decisions)
+	}
+	if len(decision.Disagreements) != 1 || decision.Disagreements[0].Outcome != "no_consensus" {
+		t.Errorf("应记录分歧: %+v", decision.Disagreements)
+	}
+
+	// 回放录制的响应时按原策略重新合并
+	client.Policy = EnsembleMajority
+	response, _ = client.CallWithMessages("system", "user")
+	decision, err = ParseAndValidateResponse(testContext(), response)
+	if err != nil {
+		t.Fatalf("解析集成响应失败: %v", err)
+	}
+	if len(decision.Decisions) != 1 || decision.Decisions[0].Action != "open_long" {
+		t.Errorf("多数策略应开多: %+v", decision.Decisions)
+	}
+}
+
+// failingClient 调用失败的AI客户端
+type failingClient struct{}
+
+func (failingClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return "", fmt.Errorf("timeout")
+}
+
+func TestEnsembleFailedMembersVoteWait(t *testing.T) {
+	long := textClient(`看多
+[{"symbol":"BTCUSDT","action":"open_long","leverage":3,"position_size_usd":500,"stop_loss":95,"take_profit":130,"confidence":80,"reasoning":"突破"}]`)
+
+	// 三个模型中两个失败：剩下的一个模型不能单独决定开仓
+	client := NewEnsembleClient(EnsembleMajority,
+		EnsembleMember{Name: "a", Client: long},
+		EnsembleMember{Name: "b", Client: failingClient{}},
+		EnsembleMember{Name: "c", Client: textClient("无法解析的响应")},
+	)
+	response, err := client.CallWithMessages("system", "user")
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	decision, err := ParseAndValidateResponse(testContext(), response)
+	if err != nil {
+		t.Fatalf("解析集成响应失败: %v", err)
+	}
+	if len(decision.Decisions) != 0 {
+		t.Errorf("有效模型未过半时不应交易: %+v", decision.Decisions)
+	}
+	if len(decision.Disagreements) != 1 || len(decision.Disagreements[0].Votes) != 3 || !decision.Disagreements[0].Votes[1].Failed {
+		t.Errorf("失败的模型应按观望计票: %+v", decision.Disagreements)
+	}
+
+	// 加权策略同样计入失败模型的权重
+	client.Policy = EnsembleWeighted
+	response, _ = client.CallWithMessages("system", "user")
+	if decision, _ = ParseAndValidateResponse(testContext(), response); len(decision.Decisions) != 0 {
+		t.Errorf("加权策略下失败模型应计入总权重: %+v", decision.Decisions)
+	}
+
+	// 两个有效模型同意时仍可执行
+	client.Members[2].Client = long
+	client.Policy = EnsembleMajority
+	response, _ = client.CallWithMessages("system", "user")
+	if decision, _ = ParseAndValidateResponse(testContext(), response); len(decision.Decisions) != 1 {
+		t.Errorf("过半数模型同意时应执行: %+v", decision.Decisions)
+	}
+}
+
+func TestParseEnsemblePolicy(t *testing.T) {
+	if p, err := ParseEnsemblePolicy(""); err != nil || p != EnsembleMajority {
+		t.Errorf("默认策略应为majority, got %s", p)
+	}
+	if _, err := ParseEnsemblePolicy("dictator"); err == nil {
+		t.Error("未知策略应返回错误")
+	}
+}
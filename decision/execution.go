@@ -0,0 +1,28 @@
+package decision
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExecutionMode 开仓执行方式
+type ExecutionMode string
+
+const (
+	ExecutionMarket   ExecutionMode = "market"    // 市价单（默认）
+	ExecutionLimitMid ExecutionMode = "limit_mid" // 以盘口中间价挂限价单，超时未成交部分转市价
+	ExecutionPostOnly ExecutionMode = "post_only" // 只做Maker：挂在买一/卖一，超时撤单
+	ExecutionTWAP     ExecutionMode = "twap"      // 大额仓位拆分为多笔限价单分批执行（冰山）
+)
+
+// ParseExecutionMode 解析执行方式（为空时默认market）
+func ParseExecutionMode(s string) (ExecutionMode, error) {
+	switch mode := ExecutionMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ExecutionMarket, nil
+	case ExecutionMarket, ExecutionLimitMid, ExecutionPostOnly, ExecutionTWAP:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("未知的执行方式: %s", s)
+	}
+}
@@ -0,0 +1,34 @@
+package decision
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseExecutionMode(t *testing.T) {
+	if m, err := ParseExecutionMode(""); err != nil || m != ExecutionMarket {
+		t.Errorf("默认执行方式应为market, got %s", m)
+	}
+	if m, err := ParseExecutionMode(" TWAP "); err != nil || m != ExecutionTWAP {
+		t.Errorf("应忽略大小写和空格, got %s", m)
+	}
+	if _, err := ParseExecutionMode("iceberg"); err == nil {
+		t.Error("未知执行方式应返回错误")
+	}
+}
+
+func TestDecisionExecutionValidation(t *testing.T) {
+	open := `[{"symbol":"BTCUSDT","action":"open_long","leverage":3,"position_size_usd":500,"stop_loss":95,"take_profit":130,"confidence":80,"execution":"%s","reasoning":"test"}]`
+
+	decision, err := ParseAndValidateResponse(testContext(), "开多\n"+fmt.Sprintf(open, "post_only"))
+	if err != nil {
+		t.Fatalf("合法执行方式解析失败: %v", err)
+	}
+	if decision.Decisions[0].Execution != "post_only" {
+		t.Errorf("应解析出执行方式: %+v", decision.Decisions[0])
+	}
+
+	if _, err := ParseAndValidateResponse(testContext(), "开多\n"+fmt.Sprintf(open, "dark_pool")); err == nil {
+		t.Error("未知执行方式应验证失败")
+	}
+}
@@ -7,9 +7,15 @@ import (
 	"nofx/decision/optimizer"
 	"nofx/decision/reflection"
 	"nofx/manager"
+	"nofx/trader"
+	"sync"
 	"time"
 )
 
+// closesPerLearningCycle is the number of closed positions that triggers an
+// out-of-schedule learning cycle for a trader.
+const closesPerLearningCycle = 10
+
 // LearningCoordinator orchestrates the learning loop.
 type LearningCoordinator struct {
 	analyzer  Analyzer
@@ -17,6 +23,9 @@ type LearningCoordinator struct {
 	generator Generator
 	executor  Executor
 	db        ConfigDB
+
+	mu           sync.Mutex
+	closedTrades map[string]int // closes since the last triggered cycle, per trader
 }
 
 // NewLearningCoordinator creates a new LearningCoordinator with all dependencies.
@@ -98,6 +107,38 @@ func (lc *LearningCoordinator) RunLearningCycle(traderID string) error {
 	return nil
 }
 
+// OnPositionClosed counts closed positions per trader and runs a learning
+// cycle in the background every closesPerLearningCycle closes. It implements
+// trader.PositionListener.
+func (lc *LearningCoordinator) OnPositionClosed(traderID string, event trader.PositionEvent) {
+	if !lc.countClose(traderID) {
+		return
+	}
+	log.Printf("🧠 Trader %s closed %d positions (last: %s), triggering learning cycle",
+		traderID, closesPerLearningCycle, event.Symbol)
+	go func() {
+		if err := lc.RunLearningCycle(traderID); err != nil {
+			log.Printf("Learning Cycle failed for %s: %v", traderID, err)
+		}
+	}()
+}
+
+// countClose records a close and reports whether a learning cycle is due.
+func (lc *LearningCoordinator) countClose(traderID string) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.closedTrades == nil {
+		lc.closedTrades = make(map[string]int)
+	}
+	lc.closedTrades[traderID]++
+	if lc.closedTrades[traderID] < closesPerLearningCycle {
+		return false
+	}
+	lc.closedTrades[traderID] = 0
+	return true
+}
+
 // StartScheduler starts the periodic learning process.
 func (lc *LearningCoordinator) StartScheduler() {
 	ticker := time.NewTicker(24 * time.Hour)
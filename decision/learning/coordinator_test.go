@@ -98,3 +98,21 @@ func TestRunLearningCycle_Robustness(t *testing.T) {
 		t.Errorf("Expected success (graceful degradation) on save fail, got: %v", err)
 	}
 }
+
+func TestOnPositionClosedTriggerCount(t *testing.T) {
+	coord := &LearningCoordinator{}
+	for i := 1; i < closesPerLearningCycle; i++ {
+		if coord.countClose("t1") {
+			t.Fatalf("close %d should not trigger a learning cycle", i)
+		}
+	}
+	if coord.countClose("t2") {
+		t.Error("closes must be counted per trader")
+	}
+	if !coord.countClose("t1") {
+		t.Errorf("close %d should trigger a learning cycle", closesPerLearningCycle)
+	}
+	if coord.countClose("t1") {
+		t.Error("counter should reset after triggering")
+	}
+}
@@ -0,0 +1,284 @@
+package decision
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"nofx/market"
+	"nofx/mem0"
+)
+
+// MemoryExtensionKey 历史交易记忆在 Context.Extensions 中的键
+const MemoryExtensionKey = "memory"
+
+// MemoryContext 与本周期行情相似的历史交易（决策和结果），注入到User Prompt
+type MemoryContext struct {
+	Stage        mem0.KellyStage // 交易员当前的Kelly学习阶段（决定记忆过滤的严格程度）
+	Memories     []mem0.Memory   // 经阶段过滤和token压缩后的记忆
+	ColdStart    bool            // 没有相似记忆，使用冷启动参考案例
+	RemovedCount int             // 因阶段风险规则被过滤的记忆数
+	Tokens       int             // 压缩后的估算token数
+	FetchError   string          // 检索失败原因（为空表示成功）
+}
+
+// MemoryEnricher 实现 ContextEnricher 接口：按候选币种检索相似的历史交易
+// 流程: 按币种语义检索 → 按Kelly阶段过滤 → 压缩到MaxPromptTokens以内
+type MemoryEnricher struct {
+	store         mem0.MemoryStore
+	compressor    *mem0.ContextCompressor
+	formatter     *mem0.RiskAwareFormatter
+	coldStart     *mem0.ColdStartFallback
+	stage         func() mem0.KellyStage
+	filters       []mem0.QueryFilter
+	perSymbol     int
+	maxSymbols    int
+	minSimilarity float64
+	timeout       time.Duration
+	logger        *log.Logger
+	enabled       bool
+}
+
+// NewMemoryEnricher 创建历史交易记忆增强器（cfg为nil时使用默认参数）
+func NewMemoryEnricher(store mem0.MemoryStore, cfg *mem0.Config) *MemoryEnricher {
+	maxTokens, minSimilarity, timeout := 2500, 0.6, 2*time.Second
+	if cfg != nil {
+		if cfg.MaxPromptTokens > 0 {
+			maxTokens = cfg.MaxPromptTokens
+		}
+		minSimilarity = cfg.SimilarityThreshold
+		if cfg.LatencyThresholdMs > 0 {
+			timeout = time.Duration(cfg.LatencyThresholdMs) * time.Millisecond
+		}
+	}
+	return &MemoryEnricher{
+		store:         store,
+		compressor:    mem0.NewContextCompressor(maxTokens),
+		formatter:     mem0.NewRiskAwareFormatter(),
+		stage:         func() mem0.KellyStage { return mem0.StageInfant },
+		perSymbol:     5,
+		maxSymbols:    10,
+		minSimilarity: minSimilarity,
+		timeout:       timeout,
+		logger:        log.New(log.Writer(), "[MemoryEnricher] ", log.LstdFlags),
+		enabled:       store != nil,
+	}
+}
+
+// SetStageProvider 设置交易员Kelly阶段的来源（默认按最严格的infant阶段过滤）
+func (me *MemoryEnricher) SetStageProvider(stage func() mem0.KellyStage) *MemoryEnricher {
+	if stage != nil {
+		me.stage = stage
+	}
+	return me
+}
+
+// SetColdStartFallback 设置没有相似记忆时使用的冷启动参考案例
+func (me *MemoryEnricher) SetColdStartFallback(fallback *mem0.ColdStartFallback) *MemoryEnricher {
+	me.coldStart = fallback
+	return me
+}
+
+// SetFilters 设置每次检索附加的过滤条件（如只检索本交易员的记忆）
+func (me *MemoryEnricher) SetFilters(filters ...mem0.QueryFilter) *MemoryEnricher {
+	me.filters = filters
+	return me
+}
+
+// Name 返回增强器的名称
+func (me *MemoryEnricher) Name() string {
+	return "memory"
+}
+
+// IsEnabled 检查记忆增强器是否启用
+func (me *MemoryEnricher) IsEnabled(ctx *Context) bool {
+	return me.enabled && ctx != nil && len(ctx.MarketDataMap) > 0
+}
+
+// Enrich 检索相似历史交易并写入 Extensions["memory"]
+// 遵循fail-safe原则：检索失败时写入带错误信息的空记忆上下文，不返回错误
+func (me *MemoryEnricher) Enrich(ctx *Context) error {
+	if ctx == nil {
+		return fmt.Errorf("context is nil")
+	}
+
+	startTime := time.Now()
+	stage := me.stage()
+	memCtx := &MemoryContext{Stage: stage}
+
+	searchCtx, cancel := context.WithTimeout(context.Background(), me.timeout)
+	defer cancel()
+
+	symbols := memorySymbols(ctx, me.maxSymbols)
+	seen := make(map[string]bool)
+	var memories []mem0.Memory
+	var lastErr error
+	failed := 0
+	for _, symbol := range symbols {
+		filters := append([]mem0.QueryFilter{{Field: "metadata.symbol", Operator: "eq", Value: symbol}}, me.filters...)
+		results, err := me.store.Search(searchCtx, mem0.Query{
+			Type:       "semantic_search",
+			Context:    map[string]interface{}{"query": DescribeSetup(symbol, ctx.MarketDataMap[symbol])},
+			Filters:    filters,
+			Limit:      me.perSymbol,
+			Similarity: me.minSimilarity,
+		})
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		for _, m := range results {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				memories = append(memories, m)
+			}
+		}
+	}
+
+	if failed > 0 && failed == len(symbols) {
+		me.logger.Printf("⚠️  Failed to search memories: %v (duration: %v)", lastErr, time.Since(startTime))
+		memCtx.FetchError = lastErr.Error()
+		ctx.SetExtension(MemoryExtensionKey, memCtx)
+		return nil
+	}
+
+	if len(memories) == 0 && me.coldStart != nil {
+		memories = me.coldStart.GetFallbackReferences()
+		memCtx.ColdStart = true
+	}
+
+	filtered := me.formatter.FilterMemories(memories, stage)
+	me.compressor.ResetDeduplication()
+	compressed := me.compressor.Compress(filtered.Memories)
+
+	memCtx.Memories = compressed.Memories
+	memCtx.RemovedCount = filtered.RemovedCount
+	memCtx.Tokens = compressed.OutputTokens
+	ctx.SetExtension(MemoryExtensionKey, memCtx)
+
+	me.logger.Printf("✅ Memories retrieved: %d symbols, %d found, %d kept (stage: %s, %d tokens, duration: %v)",
+		len(symbols), len(memories), len(memCtx.Memories), stage, memCtx.Tokens, time.Since(startTime))
+	return nil
+}
+
+// SetEnabled 设置记忆增强器的启用状态
+func (me *MemoryEnricher) SetEnabled(enabled bool) {
+	me.enabled = enabled && me.store != nil
+}
+
+// memorySymbols 需要检索记忆的币种：先持仓后候选，去重，只保留有行情数据的币种
+func memorySymbols(ctx *Context, limit int) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(symbol string) {
+		if seen[symbol] || len(symbols) >= limit {
+			return
+		}
+		if _, ok := ctx.MarketDataMap[symbol]; !ok {
+			return
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+	for _, pos := range ctx.Positions {
+		add(pos.Symbol)
+	}
+	for _, coin := range ctx.CandidateCoins {
+		add(coin.Symbol)
+	}
+	return symbols
+}
+
+// DescribeSetup 将行情概括为离散的文字描述（检索相似历史行情和写入记忆使用同一口径）
+// 只使用趋势、RSI、MACD、均线和资金费率的区间，避免具体价格影响相似度
+func DescribeSetup(symbol string, data *market.Data) string {
+	if data == nil {
+		return symbol
+	}
+	trend := func(label string, change float64) string {
+		switch {
+		case change >= 1:
+			return label + "强势上涨"
+		case change >= 0.2:
+			return label + "上涨"
+		case change <= -1:
+			return label + "强势下跌"
+		case change <= -0.2:
+			return label + "下跌"
+		default:
+			return label + "横盘"
+		}
+	}
+
+	parts := []string{symbol, trend("1h", data.PriceChange1h), trend("4h", data.PriceChange4h)}
+	switch {
+	case data.CurrentRSI7 >= 70:
+		parts = append(parts, "RSI超买")
+	case data.CurrentRSI7 <= 30:
+		parts = append(parts, "RSI超卖")
+	default:
+		parts = append(parts, "RSI中性")
+	}
+	if data.CurrentMACD >= 0 {
+		parts = append(parts, "MACD多头")
+	} else {
+		parts = append(parts, "MACD空头")
+	}
+	if data.CurrentEMA20 > 0 {
+		if data.CurrentPrice >= data.CurrentEMA20 {
+			parts = append(parts, "价格在EMA20上方")
+		} else {
+			parts = append(parts, "价格在EMA20下方")
+		}
+	}
+	switch {
+	case data.FundingRate >= 0.0003:
+		parts = append(parts, "资金费率偏多")
+	case data.FundingRate <= -0.0003:
+		parts = append(parts, "资金费率偏空")
+	default:
+		parts = append(parts, "资金费率中性")
+	}
+	return strings.Join(parts, " ")
+}
+
+// buildMemorySection 构建"相关历史交易"提示段（没有记忆时返回空字符串）
+func buildMemorySection(ctx *Context) string {
+	val, ok := ctx.GetExtension(MemoryExtensionKey)
+	if !ok {
+		return ""
+	}
+	memCtx, isMemCtx := val.(*MemoryContext)
+	if !isMemCtx || memCtx == nil || len(memCtx.Memories) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	if memCtx.ColdStart {
+		sb.WriteString("## 🧠 参考交易原则 (暂无相似历史交易)\n\n")
+	} else {
+		sb.WriteString("## 🧠 相关历史交易 (相似行情下的过往决策和结果)\n\n")
+	}
+	for _, m := range memCtx.Memories {
+		label := "决策"
+		switch m.Type {
+		case "outcome":
+			label = "结果"
+		case "reflection":
+			label = "反思"
+		case "pattern":
+			label = "规律"
+		}
+		detail := fmt.Sprintf("质量%.2f", m.QualityScore)
+		if m.Similarity > 0 {
+			detail += fmt.Sprintf(", 相似度%.2f", m.Similarity)
+		}
+		content := strings.ReplaceAll(SanitizeForPrompt(m.Content, 0), "\n", " ")
+		sb.WriteString(fmt.Sprintf("- [%s] %s (%s)\n", label, content, detail))
+	}
+	sb.WriteString("\n以上为历史参考，结果不代表本次必然重复；与当前技术面冲突时以当前行情和风控为准\n\n")
+	return sb.String()
+}
@@ -0,0 +1,134 @@
+package decision
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"nofx/market"
+	"nofx/mem0"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestMemoryStore 使用SQLite内存库创建本地记忆存储
+func newTestMemoryStore(t *testing.T) mem0.MemoryStore {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := mem0.NewPostgresStore(context.Background(), db, mem0.NewHashEmbedder(256),
+		mem0.PostgresStoreOptions{VectorSearch: mem0.VectorSearchGo})
+	if err != nil {
+		t.Fatalf("创建记忆存储失败: %v", err)
+	}
+	return store
+}
+
+func TestMemoryEnricherFiltersByStage(t *testing.T) {
+	store := newTestMemoryStore(t)
+	btc := &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100000, CurrentEMA20: 99000, PriceChange1h: 1.5, PriceChange4h: 2, CurrentRSI7: 75, CurrentMACD: 10}
+	setup := DescribeSetup("BTCUSDT", btc)
+
+	_, err := store.SaveBatch(context.Background(), []mem0.Memory{
+		{ID: "safe", Content: setup + " | 决策: open_long 杠杆3x 信心80", Type: "decision", QualityScore: 0.96,
+			Metadata: map[string]interface{}{"trader_id": "t1", "symbol": "BTCUSDT", "kelly_fraction": 0.03, "position_size": 0.03}},
+		{ID: "aggressive", Content: setup + " | 结果: BTCUSDT LONG平仓(take_profit) 净盈亏+50.00 USDT", Type: "outcome", QualityScore: 0.85,
+			Metadata: map[string]interface{}{"trader_id": "t1", "symbol": "BTCUSDT", "kelly_fraction": 0.2, "position_size": 0.1}},
+		{ID: "other-trader", Content: setup + " | 决策: open_short", Type: "decision", QualityScore: 0.99,
+			Metadata: map[string]interface{}{"trader_id": "t2", "symbol": "BTCUSDT", "kelly_fraction": 0.01, "position_size": 0.01}},
+		{ID: "other-symbol", Content: "ETHUSDT 1h横盘 | 决策: open_long", Type: "decision", QualityScore: 0.99,
+			Metadata: map[string]interface{}{"trader_id": "t1", "symbol": "ETHUSDT", "kelly_fraction": 0.01, "position_size": 0.01}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("保存记忆失败: %v", err)
+	}
+
+	stage := mem0.StageInfant
+	enricher := NewMemoryEnricher(store, &mem0.Config{SimilarityThreshold: 0}).
+		SetStageProvider(func() mem0.KellyStage { return stage }).
+		SetFilters(mem0.QueryFilter{Field: "metadata.trader_id", Operator: "eq", Value: "t1"})
+
+	ctx := &Context{
+		CandidateCoins: []CandidateCoin{{Symbol: "BTCUSDT"}, {Symbol: "SOLUSDT"}},
+		MarketDataMap:  map[string]*market.Data{"BTCUSDT": btc},
+	}
+	if !enricher.IsEnabled(ctx) {
+		t.Fatal("有行情数据时应启用")
+	}
+	if err := enricher.Enrich(ctx); err != nil {
+		t.Fatalf("Enrich失败: %v", err)
+	}
+
+	val, _ := ctx.GetExtension(MemoryExtensionKey)
+	memCtx := val.(*MemoryContext)
+	if len(memCtx.Memories) != 1 || memCtx.Memories[0].ID != "safe" || memCtx.RemovedCount != 1 {
+		t.Fatalf("infant阶段只应保留低风险高质量记忆: %+v", memCtx)
+	}
+
+	// child阶段放宽质量分和凯利比例限制
+	stage = mem0.StageChild
+	if err := enricher.Enrich(ctx); err != nil {
+		t.Fatalf("Enrich失败: %v", err)
+	}
+	val, _ = ctx.GetExtension(MemoryExtensionKey)
+	memCtx = val.(*MemoryContext)
+	if len(memCtx.Memories) != 2 || memCtx.Stage != mem0.StageChild {
+		t.Fatalf("child阶段应保留两条记忆（重复检索不应被去重器吞掉）: %+v", memCtx)
+	}
+
+	section := buildMemorySection(ctx)
+	if !strings.Contains(section, "相关历史交易") || !strings.Contains(section, "[决策]") || !strings.Contains(section, "[结果]") {
+		t.Errorf("提示段格式不正确:\n%s", section)
+	}
+	if strings.Contains(section, "open_short") || strings.Contains(section, "ETHUSDT") {
+		t.Errorf("不应包含其他交易员或其他币种的记忆:\n%s", section)
+	}
+	if !strings.Contains(buildUserPrompt(ctx), "相关历史交易") {
+		t.Error("User Prompt应包含历史交易段")
+	}
+}
+
+func TestMemoryEnricherColdStart(t *testing.T) {
+	store := newTestMemoryStore(t)
+	fallback := mem0.NewColdStartFallback(mem0.NewGlobalKnowledgeBase(store))
+	enricher := NewMemoryEnricher(store, nil).SetColdStartFallback(fallback)
+
+	ctx := &Context{MarketDataMap: map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT"}}}
+	if err := enricher.Enrich(ctx); err != nil {
+		t.Fatalf("Enrich失败: %v", err)
+	}
+	val, ok := ctx.GetExtension(MemoryExtensionKey)
+	if !ok || !val.(*MemoryContext).ColdStart {
+		t.Fatalf("没有历史记忆时应使用冷启动参考: %+v", val)
+	}
+
+	// 没有记忆上下文时不输出任何内容
+	if section := buildMemorySection(&Context{}); section != "" {
+		t.Errorf("无记忆时应返回空字符串: %q", section)
+	}
+}
+
+func TestDescribeSetup(t *testing.T) {
+	got := DescribeSetup("ETHUSDT", &market.Data{
+		CurrentPrice:  3000,
+		CurrentEMA20:  3100,
+		PriceChange1h: -0.5,
+		PriceChange4h: 0.1,
+		CurrentRSI7:   25,
+		CurrentMACD:   -2,
+		FundingRate:   -0.0005,
+	})
+	want := "ETHUSDT 1h下跌 4h横盘 RSI超卖 MACD空头 价格在EMA20下方 资金费率偏空"
+	if got != want {
+		t.Errorf("DescribeSetup() = %q, want %q", got, want)
+	}
+	if DescribeSetup("BTCUSDT", nil) != "BTCUSDT" {
+		t.Error("无行情数据时只返回币种")
+	}
+}
@@ -0,0 +1,175 @@
+package decision
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 多腿决策动作
+const (
+	ActionOpenPair  = "open_pair"  // 同时开多条腿（配对交易/对冲），作为一个组合管理
+	ActionClosePair = "close_pair" // 按组合ID平掉所有腿
+)
+
+// maxDecisionLegs 单个组合最多的腿数
+const maxDecisionLegs = 4
+
+// 腿的市场类型
+const (
+	LegMarketPerp = "perp" // 永续合约（默认）
+	LegMarketSpot = "spot" // 现货（只能做多，1倍，用于现货-永续资金费套利等）
+)
+
+// DecisionLeg 多腿决策中的一条腿
+type DecisionLeg struct {
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"`             // long / short
+	Ratio  float64 `json:"ratio"`            // 名义价值权重（各腿按权重分配 position_size_usd）
+	Market string  `json:"market,omitempty"` // perp / spot（为空表示永续合约）
+}
+
+// IsSpot 是否为现货腿
+func (l DecisionLeg) IsSpot() bool {
+	return l.Market == LegMarketSpot
+}
+
+// Label 腿的显示名称（现货腿带 (spot) 后缀，与同币种的永续腿区分）
+func (l DecisionLeg) Label() string {
+	if l.IsSpot() {
+		return l.Symbol + "(spot)"
+	}
+	return l.Symbol
+}
+
+// PositionGroupInfo 组合持仓（多腿决策开出的仓位，作为一个整体计算盈亏和止损）
+type PositionGroupInfo struct {
+	ID            string        `json:"id"`
+	Legs          []DecisionLeg `json:"legs"`
+	EntryNotional float64       `json:"entry_notional"` // 开仓名义价值合计
+	UnrealizedPnL float64       `json:"unrealized_pnl"` // 各腿未实现盈亏合计
+	PnLPct        float64       `json:"pnl_pct"`        // 合计盈亏 / 开仓名义价值（%）
+	StopLossPct   float64       `json:"stop_loss_pct"`
+	TakeProfitPct float64       `json:"take_profit_pct"`
+	HoldingMins   int           `json:"holding_mins"`
+}
+
+// PairLabel 组合的显示名称（如 ETHUSDT/BTCUSDT）
+func PairLabel(legs []DecisionLeg) string {
+	symbols := make([]string, 0, len(legs))
+	for _, leg := range legs {
+		symbols = append(symbols, leg.Label())
+	}
+	return strings.Join(symbols, "/")
+}
+
+// GroupLabel close_pair 决策的显示名称（决策本身不带腿，按组合ID标识）
+func GroupLabel(groupID string) string {
+	return "group:" + groupID
+}
+
+// LegNotionals 按权重把总名义价值分配到各腿
+func LegNotionals(legs []DecisionLeg, totalUSD float64) []float64 {
+	var sum float64
+	for _, leg := range legs {
+		sum += leg.Ratio
+	}
+	notionals := make([]float64, len(legs))
+	if sum <= 0 {
+		return notionals
+	}
+	for i, leg := range legs {
+		notionals[i] = totalUSD * leg.Ratio / sum
+	}
+	return notionals
+}
+
+// validateMultiLegDecision 验证多腿决策（开仓要求至少一多一空，止损止盈按组合盈亏百分比设置）
+// 同一币种可以同时有一条现货腿和一条永续腿（如现货多 + 永续空的资金费套利）
+func validateMultiLegDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+	if d.Action == ActionClosePair {
+		d.GroupID = strings.TrimSpace(d.GroupID)
+		if d.GroupID == "" {
+			return fmt.Errorf("close_pair 必须提供 group_id")
+		}
+		if d.Symbol == "" {
+			d.Symbol = GroupLabel(d.GroupID)
+		}
+		return nil
+	}
+
+	if len(d.Legs) < 2 || len(d.Legs) > maxDecisionLegs {
+		return fmt.Errorf("open_pair 需要2-%d条腿，实际: %d", maxDecisionLegs, len(d.Legs))
+	}
+
+	seen := make(map[string]bool, len(d.Legs))
+	hasLong, hasShort := false, false
+	maxLeverage := float64(btcEthLeverage)
+	for i := range d.Legs {
+		leg := &d.Legs[i]
+		leg.Symbol = strings.ToUpper(strings.TrimSpace(leg.Symbol))
+		leg.Side = strings.ToLower(strings.TrimSpace(leg.Side))
+		leg.Market = strings.ToLower(strings.TrimSpace(leg.Market))
+		if leg.Symbol == "" {
+			return fmt.Errorf("第%d条腿缺少symbol", i+1)
+		}
+		switch leg.Market {
+		case "", LegMarketPerp:
+			leg.Market = ""
+		case LegMarketSpot:
+			if leg.Side != "long" {
+				return fmt.Errorf("%s 现货腿只能做多: %s", leg.Symbol, leg.Side)
+			}
+		default:
+			return fmt.Errorf("%s 市场类型必须为perp或spot: %s", leg.Symbol, leg.Market)
+		}
+		if seen[leg.Label()] {
+			return fmt.Errorf("组合中 %s 重复出现", leg.Label())
+		}
+		seen[leg.Label()] = true
+		switch leg.Side {
+		case "long":
+			hasLong = true
+		case "short":
+			hasShort = true
+		default:
+			return fmt.Errorf("%s 方向必须为long或short: %s", leg.Symbol, leg.Side)
+		}
+		if leg.Ratio <= 0 {
+			return fmt.Errorf("%s 权重必须大于0: %.4f", leg.Symbol, leg.Ratio)
+		}
+		// 现货腿不使用杠杆，不影响组合杠杆上限
+		if !leg.IsSpot() && leg.Symbol != "BTCUSDT" && leg.Symbol != "ETHUSDT" && float64(altcoinLeverage) < maxLeverage {
+			maxLeverage = float64(altcoinLeverage)
+		}
+	}
+	if !hasLong || !hasShort {
+		return fmt.Errorf("open_pair 必须同时包含多头和空头腿")
+	}
+
+	if d.Leverage <= 0 || d.Leverage > maxLeverage {
+		return fmt.Errorf("组合杠杆必须在1-%.0f之间（按各腿中最低的杠杆上限）: %.1f", maxLeverage, d.Leverage)
+	}
+	if d.PositionSizeUSD <= 0 {
+		return fmt.Errorf("组合仓位大小必须大于0: %.2f", d.PositionSizeUSD)
+	}
+	for i, notional := range LegNotionals(d.Legs, d.PositionSizeUSD) {
+		maxPositionValue := accountEquity * 1.5
+		switch {
+		case d.Legs[i].IsSpot():
+			maxPositionValue = accountEquity // 现货腿全额占用资金
+		case d.Legs[i].Symbol == "BTCUSDT" || d.Legs[i].Symbol == "ETHUSDT":
+			maxPositionValue = accountEquity * 10
+		}
+		if notional > maxPositionValue*1.01 {
+			return fmt.Errorf("%s 腿仓位价值不能超过%.0f USDT，实际: %.0f", d.Legs[i].Label(), maxPositionValue, notional)
+		}
+	}
+	if d.StopLossPct <= 0 || d.TakeProfitPct <= 0 {
+		return fmt.Errorf("open_pair 必须提供 stop_loss_pct 和 take_profit_pct（组合盈亏占开仓名义价值的百分比）")
+	}
+
+	if d.Symbol == "" {
+		d.Symbol = PairLabel(d.Legs)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package decision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMultiLegDecision(t *testing.T) {
+	base := func() Decision {
+		return Decision{
+			Action:          ActionOpenPair,
+			Legs:            []DecisionLeg{{Symbol: "ethusdt", Side: "LONG", Ratio: 1}, {Symbol: "BTCUSDT", Side: "short", Ratio: 1}},
+			Leverage:        3,
+			PositionSizeUSD: 1000,
+			StopLossPct:     3,
+			TakeProfitPct:   6,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(d *Decision)
+		wantErr string
+	}{
+		{"合法配对", func(d *Decision) {}, ""},
+		{"只有一条腿", func(d *Decision) { d.Legs = d.Legs[:1] }, "2-4条腿"},
+		{"同方向", func(d *Decision) { d.Legs[1].Side = "long" }, "多头和空头"},
+		{"重复币种", func(d *Decision) { d.Legs[1].Symbol = "ETHUSDT" }, "重复"},
+		{"权重为0", func(d *Decision) { d.Legs[0].Ratio = 0 }, "权重"},
+		{"山寨币腿按山寨币杠杆上限", func(d *Decision) { d.Legs[0].Symbol = "SOLUSDT"; d.Leverage = 5 }, "杠杆"},
+		{"单腿超过仓位上限", func(d *Decision) { d.Legs[0].Symbol = "SOLUSDT"; d.Leverage = 2; d.PositionSizeUSD = 4000 }, "SOLUSDT 腿仓位价值"},
+		{"现货腿做空", func(d *Decision) { d.Legs[1].Market = "SPOT" }, "现货腿只能做多"},
+		{"未知市场类型", func(d *Decision) { d.Legs[0].Market = "margin" }, "perp或spot"},
+		{"现货腿超过资金", func(d *Decision) { d.Legs[0].Market = "spot"; d.PositionSizeUSD = 3000 }, "ETHUSDT(spot) 腿仓位价值"},
+		{"缺少组合止损", func(d *Decision) { d.StopLossPct = 0 }, "stop_loss_pct"},
+		{"平仓缺少组合ID", func(d *Decision) { d.Action = ActionClosePair }, "group_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := base()
+			tt.modify(&d)
+			err := validateMultiLegDecision(&d, 1000, 5, 3)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("不应报错: %v", err)
+				}
+				if d.Symbol != "ETHUSDT/BTCUSDT" || d.Legs[0].Side != "long" {
+					t.Errorf("应规范化腿并设置组合名称: %+v", d)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误应包含 %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateMultiLegDecisionSpotCarry(t *testing.T) {
+	// 现货多 + 同币种永续空（资金费套利），现货腿不受山寨币杠杆上限限制
+	d := Decision{
+		Action:          ActionOpenPair,
+		Legs:            []DecisionLeg{{Symbol: "SOLUSDT", Side: "long", Ratio: 1, Market: "spot"}, {Symbol: "SOLUSDT", Side: "short", Ratio: 1, Market: "perp"}},
+		Leverage:        3,
+		PositionSizeUSD: 1000,
+		StopLossPct:     2,
+		TakeProfitPct:   4,
+	}
+	if err := validateMultiLegDecision(&d, 1000, 5, 3); err != nil {
+		t.Fatalf("现货-永续套利组合不应报错: %v", err)
+	}
+	if d.Symbol != "SOLUSDT(spot)/SOLUSDT" || !d.Legs[0].IsSpot() || d.Legs[1].Market != "" {
+		t.Errorf("应规范化市场类型并区分现货腿: %+v", d)
+	}
+
+	// 同币种的两条现货腿仍视为重复
+	d.Legs = append(d.Legs, DecisionLeg{Symbol: "SOLUSDT", Side: "long", Ratio: 1, Market: "spot"})
+	if err := validateMultiLegDecision(&d, 1000, 5, 3); err == nil || !strings.Contains(err.Error(), "重复") {
+		t.Errorf("重复的现货腿应报错, got %v", err)
+	}
+}
+
+func TestLegNotionals(t *testing.T) {
+	got := LegNotionals([]DecisionLeg{{Ratio: 1}, {Ratio: 3}}, 400)
+	if got[0] != 100 || got[1] != 300 {
+		t.Errorf("应按权重分配名义价值: %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"nofx/mcp"
+)
+
+// 决策产生方式
+const (
+	DecisionModeToolCalling  = "tool_calling"  // 结构化输出（tool/function calling）
+	DecisionModeText         = "text"          // 从自由文本中提取JSON
+	DecisionModeTextFallback = "text_fallback" // tool calling失败后回退到文本解析
+)
+
+// ToolCallingAIClient 支持结构化输出的AI客户端
+type ToolCallingAIClient interface {
+	AIClient
+	SupportsToolCalling() bool
+	CallWithTools(systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error)
+}
+
+// toolCallingInstruction 追加到系统提示词末尾的输出说明
+const toolCallingInstruction = `
+
+# 输出方式（工具调用）
+
+本次请通过工具调用输出决策，不要再输出JSON数组：
+- 先在回复正文中写出思维链分析
+- 每个决策调用一次对应工具：open_long / open_short / close_long / close_short / open_pair / close_pair / hold / wait
+- 开仓工具必须填写 leverage、position_size_usd、stop_loss、take_profit、confidence、reasoning
+- open_pair 必须填写 legs、leverage、position_size_usd、stop_loss_pct、take_profit_pct、confidence、reasoning
+`
+
+// toolCallEnvelope 工具调用响应的录制格式（保存在RawResponse中，回放时还原）
+type toolCallEnvelope struct {
+	DecisionMode string         `json:"decision_mode"`
+	Content      string         `json:"content"`
+	ToolCalls    []mcp.ToolCall `json:"tool_calls"`
+}
+
+// DecisionTools 交易决策工具定义
+func DecisionTools() []mcp.ToolDefinition {
+	symbol := map[string]interface{}{"type": "string", "description": "交易对，如 BTCUSDT"}
+	reasoning := map[string]interface{}{"type": "string", "description": "决策理由"}
+
+	openParams := func(direction string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol":            symbol,
+				"leverage":          map[string]interface{}{"type": "number", "description": "杠杆倍数"},
+				"position_size_usd": map[string]interface{}{"type": "number", "description": "仓位价值（USDT，名义价值）"},
+				"stop_loss":         map[string]interface{}{"type": "number", "description": direction + "止损价"},
+				"take_profit":       map[string]interface{}{"type": "number", "description": direction + "止盈价"},
+				"confidence":        map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100, "description": "信心度 0-100"},
+				"risk_usd":          map[string]interface{}{"type": "number", "description": "最大美元风险"},
+				"execution":         map[string]interface{}{"type": "string", "enum": []string{"market", "limit_mid", "post_only", "twap"}, "description": "执行方式（可选，不填使用默认）"},
+				"reasoning":         reasoning,
+			},
+			"required": []string{"symbol", "leverage", "position_size_usd", "stop_loss", "take_profit", "confidence", "reasoning"},
+		}
+	}
+	symbolParams := func(required ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"symbol":    symbol,
+				"reasoning": reasoning,
+			},
+			"required": required,
+		}
+	}
+
+	pairParams := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"legs": map[string]interface{}{
+				"type":        "array",
+				"minItems":    2,
+				"maxItems":    maxDecisionLegs,
+				"description": "组合各腿（至少一多一空）",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"symbol": symbol,
+						"side":   map[string]interface{}{"type": "string", "enum": []string{"long", "short"}},
+						"ratio":  map[string]interface{}{"type": "number", "description": "名义价值权重"},
+					},
+					"required": []string{"symbol", "side", "ratio"},
+				},
+			},
+			"leverage":          map[string]interface{}{"type": "number", "description": "各腿统一杠杆倍数"},
+			"position_size_usd": map[string]interface{}{"type": "number", "description": "组合总名义价值（USDT），按权重分配到各腿"},
+			"stop_loss_pct":     map[string]interface{}{"type": "number", "description": "组合止损：合计亏损达到名义价值的该百分比时全部平仓"},
+			"take_profit_pct":   map[string]interface{}{"type": "number", "description": "组合止盈：合计盈利达到名义价值的该百分比时全部平仓"},
+			"confidence":        map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100, "description": "信心度 0-100"},
+			"reasoning":         reasoning,
+		},
+		"required": []string{"legs", "leverage", "position_size_usd", "stop_loss_pct", "take_profit_pct", "confidence", "reasoning"},
+	}
+	closePairParams := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"group_id":  map[string]interface{}{"type": "string", "description": "组合持仓ID"},
+			"reasoning": reasoning,
+		},
+		"required": []string{"group_id"},
+	}
+
+	return []mcp.ToolDefinition{
+		mcp.NewFunctionTool("open_long", "开多仓", openParams("做多")),
+		mcp.NewFunctionTool("open_short", "开空仓", openParams("做空")),
+		mcp.NewFunctionTool("close_long", "平掉该币种的多仓", symbolParams("symbol")),
+		mcp.NewFunctionTool("close_short", "平掉该币种的空仓", symbolParams("symbol")),
+		mcp.NewFunctionTool("open_pair", "同时开多条腿（配对交易/对冲），作为一个组合管理", pairParams),
+		mcp.NewFunctionTool("close_pair", "平掉整个组合的所有腿", closePairParams),
+		mcp.NewFunctionTool("hold", "继续持有该币种的现有仓位", symbolParams("symbol")),
+		mcp.NewFunctionTool("wait", "观望，不开新仓", symbolParams()),
+	}
+}
+
+// decisionsFromToolCalls 将工具调用转换为决策
+func decisionsFromToolCalls(calls []mcp.ToolCall) ([]Decision, error) {
+	decisions := make([]Decision, 0, len(calls))
+	for i, call := range calls {
+		switch call.Name {
+		case "open_long", "open_short", "close_long", "close_short", ActionOpenPair, ActionClosePair, "hold", "wait":
+		default:
+			return nil, fmt.Errorf("工具调用 #%d 未知的工具: %s", i+1, call.Name)
+		}
+
+		var d Decision
+		if args := strings.TrimSpace(call.Arguments); args != "" {
+			if err := json.Unmarshal([]byte(args), &d); err != nil {
+				return nil, fmt.Errorf("工具调用 #%d (%s) 参数解析失败: %w", i+1, call.Name, err)
+			}
+		}
+		d.Action = call.Name
+		decisions = append(decisions, d)
+	}
+	return decisions, nil
+}
+
+// encodeToolCallResponse 将结构化响应编码为可录制的原始响应
+func encodeToolCallResponse(resp *mcp.StructuredResponse) string {
+	data, _ := json.Marshal(toolCallEnvelope{
+		DecisionMode: DecisionModeToolCalling,
+		Content:      resp.Content,
+		ToolCalls:    resp.ToolCalls,
+	})
+	return string(data)
+}
+
+// decodeToolCallResponse 识别录制的工具调用响应
+func decodeToolCallResponse(aiResponse string) (*toolCallEnvelope, bool) {
+	trimmed := strings.TrimSpace(aiResponse)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var envelope toolCallEnvelope
+	if err := json.Unmarshal([]byte(trimmed), &envelope); err != nil || envelope.DecisionMode != DecisionModeToolCalling {
+		return nil, false
+	}
+	return &envelope, true
+}
+
+// parseToolCallResponse 解析工具调用响应并验证决策
+func parseToolCallResponse(envelope *toolCallEnvelope, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
+	full := &FullDecision{
+		CoTTrace:     strings.TrimSpace(envelope.Content),
+		Decisions:    []Decision{},
+		DecisionMode: DecisionModeToolCalling,
+	}
+
+	decisions, err := decisionsFromToolCalls(envelope.ToolCalls)
+	if err != nil {
+		return full, fmt.Errorf("提取决策失败: %w", err)
+	}
+	full.Decisions = decisions
+
+	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
+		return full, fmt.Errorf("决策验证失败: %w", err)
+	}
+	return full, nil
+}
+
+// callAIForDecision 调用AI获取原始响应
+// 客户端支持时优先使用tool calling；请求失败或模型直接输出JSON文本时回退到文本解析
+func callAIForDecision(aiClient AIClient, systemPrompt, userPrompt string) (response string, usedSystemPrompt string, mode string, err error) {
+	toolClient, ok := aiClient.(ToolCallingAIClient)
+	if !ok || !toolClient.SupportsToolCalling() {
+		response, err = aiClient.CallWithMessages(systemPrompt, userPrompt)
+		return response, systemPrompt, DecisionModeText, err
+	}
+
+	toolSystemPrompt := systemPrompt + toolCallingInstruction
+	structured, toolErr := toolClient.CallWithTools(toolSystemPrompt, userPrompt, DecisionTools())
+	if toolErr == nil {
+		// 有工具调用，或正文中没有JSON（视为本周期不操作）
+		if len(structured.ToolCalls) > 0 || !strings.Contains(structured.Content, "[") {
+			return encodeToolCallResponse(structured), toolSystemPrompt, DecisionModeToolCalling, nil
+		}
+		// 模型没有调用工具而是直接输出了JSON，按文本解析
+		return structured.Content, toolSystemPrompt, DecisionModeTextFallback, nil
+	}
+
+	if strings.Contains(toolErr.Error(), "Insufficient Balance") || strings.Contains(toolErr.Error(), "Unauthorized") {
+		return "", toolSystemPrompt, DecisionModeToolCalling, toolErr
+	}
+
+	log.Printf("⚠️ tool calling请求失败，回退到文本解析: %v", toolErr)
+	response, err = aiClient.CallWithMessages(systemPrompt, userPrompt)
+	return response, systemPrompt, DecisionModeTextFallback, err
+}
@@ -0,0 +1,105 @@
+package decision
+
+import (
+	"errors"
+	"testing"
+
+	"nofx/mcp"
+)
+
+// fakeToolClient 模拟支持tool calling的AI客户端
+type fakeToolClient struct {
+	structured *mcp.StructuredResponse
+	toolErr    error
+	text       string
+	textCalls  int
+}
+
+func (f *fakeToolClient) SupportsToolCalling() bool { return true }
+
+func (f *fakeToolClient) CallWithTools(systemPrompt, userPrompt string, tools []mcp.ToolDefinition) (*mcp.StructuredResponse, error) {
+	return f.structured, f.toolErr
+}
+
+func (f *fakeToolClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	f.textCalls++
+	return f.text, nil
+}
+
+func testContext() *Context {
+	return &Context{
+		Account:         AccountInfo{TotalEquity: 1000},
+		BTCETHLeverage:  5,
+		AltcoinLeverage: 5,
+	}
+}
+
+func TestToolCallingDecision(t *testing.T) {
+	client := &fakeToolClient{structured: &mcp.StructuredResponse{
+		Content: "BTC突破[关键阻力]，开多",
+		ToolCalls: []mcp.ToolCall{
+			{Name: "open_long", Arguments: `{"symbol":"BTCUSDT","leverage":3,"position_size_usd":500,"stop_loss":95,"take_profit":130,"confidence":80,"reasoning":"突破“关键”阻力"}`},
+			{Name: "wait", Arguments: `{"reasoning":"其他币种观望"}`},
+		},
+	}}
+
+	response, _, mode, err := callAIForDecision(client, "system", "user")
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if mode != DecisionModeToolCalling || client.textCalls != 0 {
+		t.Fatalf("应使用tool calling模式, got %s", mode)
+	}
+
+	decision, err := ParseAndValidateResponse(testContext(), response)
+	if err != nil {
+		t.Fatalf("解析工具调用失败: %v", err)
+	}
+	if decision.DecisionMode != DecisionModeToolCalling {
+		t.Errorf("决策模式应为tool_calling, got %s", decision.DecisionMode)
+	}
+	if len(decision.Decisions) != 2 {
+		t.Fatalf("应有2个决策, got %d", len(decision.Decisions))
+	}
+	if decision.CoTTrace != "BTC突破[关键阻力]，开多" {
+		t.Errorf("思维链应为正文内容: %s", decision.CoTTrace)
+	}
+	for _, d := range decision.Decisions {
+		if d.Action == "open_long" && (d.Leverage != 3 || d.StopLoss != 95 || d.Reasoning != "突破“关键”阻力") {
+			t.Errorf("开仓参数解析错误: %+v", d)
+		}
+	}
+}
+
+func TestToolCallingFallbackToText(t *testing.T) {
+	client := &fakeToolClient{
+		toolErr: errors.New("API返回错误 (status 400): tools not supported"),
+		text:    "观望\n[{\"symbol\":\"BTCUSDT\",\"action\":\"wait\",\"reasoning\":\"test\"}]",
+	}
+
+	response, _, mode, err := callAIForDecision(client, "system", "user")
+	if err != nil {
+		t.Fatalf("回退调用失败: %v", err)
+	}
+	if mode != DecisionModeTextFallback || client.textCalls != 1 {
+		t.Fatalf("tool calling失败时应回退到文本模式, got %s", mode)
+	}
+
+	decision, err := ParseAndValidateResponse(testContext(), response)
+	if err != nil {
+		t.Fatalf("解析文本响应失败: %v", err)
+	}
+	if decision.DecisionMode != DecisionModeText || len(decision.Decisions) != 1 {
+		t.Errorf("应按文本解析出1个决策: %+v", decision)
+	}
+}
+
+func TestToolCallingRejectsUnknownTool(t *testing.T) {
+	response := encodeToolCallResponse(&mcp.StructuredResponse{
+		ToolCalls: []mcp.ToolCall{{Name: "buy_everything", Arguments: `{}`}},
+	})
+
+	if _, err := ParseAndValidateResponse(testContext(), response); err == nil {
+		t.Fatal("未知工具应返回错误")
+	}
+}
@@ -7,24 +7,35 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 // DecisionRecord 决策记录
 type DecisionRecord struct {
-	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
-	CycleNumber    int                `json:"cycle_number"`    // 周期编号
-	SystemPrompt   string             `json:"system_prompt"`   // 系统提示词（发送给AI的系统prompt）
-	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
-	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
-	DecisionJSON   string             `json:"decision_json"`   // 决策JSON
-	AccountState   AccountSnapshot    `json:"account_state"`   // 账户状态快照
-	Positions      []PositionSnapshot `json:"positions"`       // 持仓快照
-	CandidateCoins []string           `json:"candidate_coins"` // 候选币种列表
-	Decisions      []DecisionAction   `json:"decisions"`       // 执行的决策
-	ExecutionLog   []string           `json:"execution_log"`   // 执行日志
-	Success        bool               `json:"success"`         // 是否成功
-	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
+	Timestamp         time.Time           `json:"timestamp"`                    // 决策时间
+	CycleNumber       int                 `json:"cycle_number"`                 // 周期编号
+	SystemPrompt      string              `json:"system_prompt"`                // 系统提示词（发送给AI的系统prompt）
+	InputPrompt       string              `json:"input_prompt"`                 // 发送给AI的输入prompt
+	CoTTrace          string              `json:"cot_trace"`                    // AI思维链（输出）
+	DecisionJSON      string              `json:"decision_json"`                // 决策JSON
+	RawResponse       string              `json:"raw_response"`                 // AI原始响应（用于决策回放）
+	DecisionMode      string              `json:"decision_mode"`                // 决策产生方式（tool_calling/text/text_fallback/ensemble）
+	Experiment        string              `json:"experiment,omitempty"`         // 参与的A/B实验名称
+	ExperimentVariant string              `json:"experiment_variant,omitempty"` // 本周期分配到的实验变体
+	Disagreements     []ModelDisagreement `json:"disagreements,omitempty"`      // 多模型投票时的分歧
+	Trigger           string              `json:"trigger,omitempty"`            // 触发本周期的事件类型（startup/scheduled/price_move/funding_flip/oi_spike/news/liquidation）
+	TriggerEvents     []string            `json:"trigger_events,omitempty"`     // 触发事件详情
+	OrderNotices      []string            `json:"order_notices,omitempty"`      // 上个周期以来的订单状态变化（部分成交、拒单、止损止盈被撤销等）
+	AccountState      AccountSnapshot     `json:"account_state"`                // 账户状态快照
+	Positions         []PositionSnapshot  `json:"positions"`                    // 持仓快照
+	CandidateCoins    []string            `json:"candidate_coins"`              // 候选币种列表
+	Decisions         []DecisionAction    `json:"decisions"`                    // 执行的决策
+	ExecutionLog      []string            `json:"execution_log"`                // 执行日志
+	Success           bool                `json:"success"`                      // 是否成功
+	ErrorMessage      string              `json:"error_message"`                // 错误信息（如果有）
 }
 
 // AccountSnapshot 账户状态快照
@@ -34,6 +45,8 @@ type AccountSnapshot struct {
 	TotalUnrealizedProfit float64 `json:"total_unrealized_profit"`
 	PositionCount         int     `json:"position_count"`
 	MarginUsedPct         float64 `json:"margin_used_pct"`
+	TotalFees             float64 `json:"total_fees,omitempty"`    // 累计手续费（来自交易所账单）
+	TotalFunding          float64 `json:"total_funding,omitempty"` // 累计资金费（正数为支付）
 }
 
 // PositionSnapshot 持仓快照
@@ -48,22 +61,58 @@ type PositionSnapshot struct {
 	LiquidationPrice float64 `json:"liquidation_price"`
 }
 
+// ModelDisagreement 多模型投票分歧
+type ModelDisagreement struct {
+	Symbol  string      `json:"symbol"`
+	Votes   []ModelVote `json:"votes"`
+	Outcome string      `json:"outcome"` // 最终采用的动作，未达成共识时为 no_consensus
+}
+
+// ModelVote 单个模型的投票
+type ModelVote struct {
+	Model      string `json:"model"`
+	Action     string `json:"action"`
+	Confidence int    `json:"confidence"`
+}
+
 // DecisionAction 决策动作
 type DecisionAction struct {
-	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short
-	Symbol    string    `json:"symbol"`    // 币种
-	Quantity  float64   `json:"quantity"`  // 数量
-	Leverage  float64   `json:"leverage"`  // 杠杆（开仓时，支持小数）
-	Price     float64   `json:"price"`     // 执行价格
-	OrderID   int64     `json:"order_id"`  // 订单ID
-	Timestamp time.Time `json:"timestamp"` // 执行时间
-	Success   bool      `json:"success"`   // 是否成功
-	Error     string    `json:"error"`     // 错误信息
+	Action           string    `json:"action"`                       // open_long, open_short, close_long, close_short
+	Symbol           string    `json:"symbol"`                       // 币种
+	Quantity         float64   `json:"quantity"`                     // 数量
+	Leverage         float64   `json:"leverage"`                     // 杠杆（开仓时，支持小数）
+	Price            float64   `json:"price"`                        // 执行价格
+	OrderID          int64     `json:"order_id"`                     // 订单ID（数字形式，OKX等字符串ID见ExchangeOrderID）
+	ClientOrderID    string    `json:"client_order_id,omitempty"`    // 本地订单ID
+	ExchangeOrderID  string    `json:"exchange_order_id,omitempty"`  // 交易所订单ID
+	OrderStatus      string    `json:"order_status,omitempty"`       // 下单时的订单状态
+	ExecutionMode    string    `json:"execution_mode,omitempty"`     // 开仓执行方式（market/limit_mid/post_only/twap）
+	Fee              float64   `json:"fee,omitempty"`                // 成交手续费（USDT）
+	RequestedSizeUSD float64   `json:"requested_size_usd,omitempty"` // AI原始请求的仓位价值（开仓时）
+	PositionSizeUSD  float64   `json:"position_size_usd,omitempty"`  // 服务端核定后的仓位价值（开仓时）
+	SizingMethod     string    `json:"sizing_method,omitempty"`      // 决定最终仓位的因素（risk_budget/atr_target/kelly_cap等）
+	GroupID          string    `json:"group_id,omitempty"`           // 组合持仓ID（open_pair/close_pair）
+	Legs             []LegFill `json:"legs,omitempty"`               // 组合各腿的成交明细
+	Timestamp        time.Time `json:"timestamp"`                    // 执行时间
+	Success          bool      `json:"success"`                      // 是否成功
+	Error            string    `json:"error"`                        // 错误信息
+}
+
+// LegFill 组合决策中一条腿的成交明细
+type LegFill struct {
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`             // long / short
+	Market        string  `json:"market,omitempty"` // spot表示现货腿
+	Quantity      float64 `json:"quantity"`
+	Price         float64 `json:"price"`
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	Error         string  `json:"error,omitempty"`
 }
 
 // DecisionLogger 决策日志记录器
 type DecisionLogger struct {
 	logDir      string
+	mu          sync.Mutex // 决策周期和强平保护等后台任务可能同时写入
 	cycleNumber int
 }
 
@@ -86,8 +135,10 @@ func NewDecisionLogger(logDir string) *DecisionLogger {
 
 // LogDecision 记录决策
 func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
+	l.mu.Lock()
 	l.cycleNumber++
 	record.CycleNumber = l.cycleNumber
+	l.mu.Unlock()
 	record.Timestamp = time.Now()
 
 	// 生成文件名：decision_YYYYMMDD_HHMMSS_cycleN.json
@@ -151,6 +202,50 @@ func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
 	return records, nil
 }
 
+// LoadDecisionRecords 读取目录下的全部决策记录（按决策时间正序：从旧到新）
+func LoadDecisionRecords(logDir string) ([]*DecisionRecord, error) {
+	files, err := filepath.Glob(filepath.Join(logDir, "decision_*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("查找日志文件失败: %w", err)
+	}
+
+	var records []*DecisionRecord
+	for _, filepath := range files {
+		data, err := ioutil.ReadFile(filepath)
+		if err != nil {
+			continue
+		}
+
+		var record DecisionRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			continue
+		}
+
+		records = append(records, &record)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Timestamp.Equal(records[j].Timestamp) {
+			return records[i].CycleNumber < records[j].CycleNumber
+		}
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+
+	return records, nil
+}
+
+// AIResponse 获取该周期的AI响应
+// 优先使用录制的原始响应；旧日志没有原始响应时由思维链 + 决策JSON还原，无决策JSON时返回空字符串
+func (r *DecisionRecord) AIResponse() string {
+	if strings.TrimSpace(r.RawResponse) != "" {
+		return r.RawResponse
+	}
+	if strings.TrimSpace(r.DecisionJSON) == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.CoTTrace + "\n\n" + r.DecisionJSON)
+}
+
 // GetRecordByDate 获取指定日期的所有记录
 func (l *DecisionLogger) GetRecordByDate(date time.Time) ([]*DecisionRecord, error) {
 	dateStr := date.Format("20060102")
@@ -278,8 +373,10 @@ type TradeOutcome struct {
 	ClosePrice    float64   `json:"close_price"`    // 平仓价
 	PositionValue float64   `json:"position_value"` // 仓位价值（quantity × openPrice）
 	MarginUsed    float64   `json:"margin_used"`    // 保证金使用（positionValue / leverage）
-	PnL           float64   `json:"pn_l"`           // 盈亏（USDT）
-	PnLPct        float64   `json:"pn_l_pct"`       // 盈亏百分比（相对保证金）
+	GrossPnL      float64   `json:"gross_pn_l"`     // 毛盈亏（USDT，不含手续费）
+	Fees          float64   `json:"fees"`           // 开平仓手续费（USDT）
+	PnL           float64   `json:"pn_l"`           // 净盈亏（USDT，扣除手续费）
+	PnLPct        float64   `json:"pn_l_pct"`       // 净盈亏百分比（相对保证金）
 	Duration      string    `json:"duration"`       // 持仓时长
 	OpenTime      time.Time `json:"open_time"`      // 开仓时间
 	CloseTime     time.Time `json:"close_time"`     // 平仓时间
@@ -296,6 +393,10 @@ type PerformanceAnalysis struct {
 	AvgLoss       float64                       `json:"avg_loss"`       // 平均亏损
 	ProfitFactor  float64                       `json:"profit_factor"`  // 盈亏比
 	SharpeRatio   float64                       `json:"sharpe_ratio"`   // 夏普比率（风险调整后收益）
+	GrossPnL      float64                       `json:"gross_pn_l"`     // 已平仓交易毛盈亏（不含费用）
+	TotalFees     float64                       `json:"total_fees"`     // 已平仓交易手续费
+	TotalFunding  float64                       `json:"total_funding"`  // 窗口内资金费（来自账户快照，正数为支付）
+	NetPnL        float64                       `json:"net_pn_l"`       // 净盈亏 = 毛盈亏 - 手续费 - 资金费
 	RecentTrades  []TradeOutcome                `json:"recent_trades"`  // 最近N笔交易
 	SymbolStats   map[string]*SymbolPerformance `json:"symbol_stats"`   // 各币种表现
 	BestSymbol    string                        `json:"best_symbol"`    // 表现最好的币种
@@ -364,6 +465,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 						"openTime":  action.Timestamp,
 						"quantity":  action.Quantity,
 						"leverage":  action.Leverage,
+						"fee":       action.Fee,
 					}
 				case "close_long", "close_short":
 					// 移除已平仓记录
@@ -398,6 +500,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					"openTime":  action.Timestamp,
 					"quantity":  action.Quantity,
 					"leverage":  action.Leverage,
+					"fee":       action.Fee,
 				}
 
 			case "close_long", "close_short":
@@ -412,12 +515,16 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					// 计算实际盈亏（USDT）
 					// 合约交易 PnL 计算：quantity × 价格差
 					// 注意：杠杆不影响绝对盈亏，只影响保证金需求
-					var pnl float64
+					var grossPnL float64
 					if side == "long" {
-						pnl = quantity * (action.Price - openPrice)
+						grossPnL = quantity * (action.Price - openPrice)
 					} else {
-						pnl = quantity * (openPrice - action.Price)
+						grossPnL = quantity * (openPrice - action.Price)
 					}
+					// 净盈亏扣除开平仓手续费（旧记录没有手续费时与毛盈亏相同）
+					openFee, _ := openPos["fee"].(float64)
+					fees := openFee + action.Fee
+					pnl := grossPnL - fees
 
 					// 计算盈亏百分比（相对保证金）
 					positionValue := quantity * openPrice
@@ -437,6 +544,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 						ClosePrice:    action.Price,
 						PositionValue: positionValue,
 						MarginUsed:    marginUsed,
+						GrossPnL:      grossPnL,
+						Fees:          fees,
 						PnL:           pnl,
 						PnLPct:        pnlPct,
 						Duration:      action.Timestamp.Sub(openTime).String(),
@@ -446,6 +555,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 
 					analysis.RecentTrades = append(analysis.RecentTrades, outcome)
 					analysis.TotalTrades++
+					analysis.GrossPnL += grossPnL
+					analysis.TotalFees += fees
 
 					// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
 					if pnl > 0 {
@@ -537,6 +648,10 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}
 	}
 
+	// 资金费按窗口首尾账户快照的累计值之差计算
+	analysis.TotalFunding = records[len(records)-1].AccountState.TotalFunding - records[0].AccountState.TotalFunding
+	analysis.NetPnL = analysis.GrossPnL - analysis.TotalFees - analysis.TotalFunding
+
 	// 计算夏普比率（需要至少2个数据点）
 	analysis.SharpeRatio = l.calculateSharpeRatio(records)
 
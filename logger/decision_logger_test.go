@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAnalyzePerformanceGrossVsNet(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+	openTime := time.Now().Add(-time.Hour)
+
+	records := []*DecisionRecord{
+		{
+			AccountState: AccountSnapshot{TotalBalance: 1000, TotalFunding: 0.5},
+			Decisions: []DecisionAction{
+				{Action: "open_long", Symbol: "DOGEUSDT", Quantity: 1000, Leverage: 5, Price: 0.1, Fee: 0.05, Timestamp: openTime, Success: true},
+			},
+		},
+		{
+			AccountState: AccountSnapshot{TotalBalance: 1000.05, TotalFunding: 0.6},
+			Decisions: []DecisionAction{
+				{Action: "close_long", Symbol: "DOGEUSDT", Quantity: 1000, Price: 0.1001, Fee: 0.06, Timestamp: time.Now(), Success: true},
+			},
+		},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("写入决策记录失败: %v", err)
+		}
+	}
+
+	analysis, err := l.AnalyzePerformance(10)
+	if err != nil {
+		t.Fatalf("分析失败: %v", err)
+	}
+	if len(analysis.RecentTrades) != 1 {
+		t.Fatalf("应匹配出1笔交易: %+v", analysis.RecentTrades)
+	}
+
+	// 毛盈亏 +0.1，手续费 0.11 → 净亏损，应计为亏损交易
+	trade := analysis.RecentTrades[0]
+	if math.Abs(trade.GrossPnL-0.1) > 1e-9 || math.Abs(trade.Fees-0.11) > 1e-9 || math.Abs(trade.PnL+0.01) > 1e-9 {
+		t.Errorf("交易盈亏应扣除手续费: %+v", trade)
+	}
+	if analysis.WinningTrades != 0 || analysis.LosingTrades != 1 {
+		t.Errorf("扣费后亏损的交易应计为亏损: win=%d loss=%d", analysis.WinningTrades, analysis.LosingTrades)
+	}
+	if math.Abs(analysis.TotalFunding-0.1) > 1e-9 || math.Abs(analysis.NetPnL-(0.1-0.11-0.1)) > 1e-9 {
+		t.Errorf("净盈亏应扣除手续费和资金费: gross=%v fees=%v funding=%v net=%v",
+			analysis.GrossPnL, analysis.TotalFees, analysis.TotalFunding, analysis.NetPnL)
+	}
+}
@@ -181,6 +181,16 @@ func main() {
 		log.Printf("⚠️  加载内测码到数据库失败: %v", err)
 	}
 
+	// 加载功能标志（数据库持久化，多实例定时重载；失败时使用内置默认值）
+	if featureFlags, err := config.NewFeatureFlagManagerWithStore(database); err != nil {
+		log.Printf("⚠️  加载功能标志失败，使用内置默认值: %v", err)
+	} else {
+		config.SetGlobalFeatureFlags(featureFlags)
+		featureFlags.StartAutoReload(config.FeatureFlagReloadInterval)
+		defer featureFlags.Stop()
+		log.Printf("✓ 已加载功能标志（每%v重载）", config.FeatureFlagReloadInterval)
+	}
+
 	// 获取系统配置
 	useDefaultCoinsStr, _ := database.GetSystemConfig("use_default_coins")
 	useDefaultCoins := useDefaultCoinsStr == "true"
@@ -335,6 +345,7 @@ func main() {
 
 		coordinator := learning.NewLearningCoordinator(database, traderManager, aiClient)
 		coordinator.StartScheduler()
+		traderManager.AddPositionListener(coordinator)
 		log.Println("🧠 AI学习与反思系统已启动")
 	}()
 
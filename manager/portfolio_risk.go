@@ -0,0 +1,461 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/decision"
+	"nofx/trader"
+)
+
+const (
+	// portfolioReservationTTL 开仓预留额度在账户快照更新后的保留时间（覆盖交易所持仓缓存的延迟）
+	portfolioReservationTTL = time.Minute
+	// portfolioMinOrderUSD 缩减后低于该仓位价值时直接拦截
+	portfolioMinOrderUSD = 10.0
+	// portfolioMaxEvents 每个用户保留的最近拦截/缩减记录数
+	portfolioMaxEvents = 50
+)
+
+// PortfolioRiskConfig 组合风控限制（按用户汇总所有交易员的持仓，倍数相对于用户总净值）
+type PortfolioRiskConfig struct {
+	Enabled           bool    `json:"enabled"`
+	MaxGrossLeverage  float64 `json:"max_gross_leverage"`  // 总名义价值（多+空）上限，净值倍数
+	MaxNetLeverage    float64 `json:"max_net_leverage"`    // 净名义价值（多-空）绝对值上限，净值倍数
+	MaxSymbolExposure float64 `json:"max_symbol_exposure"` // 单币种净名义价值上限，净值倍数
+	MaxMarginUsagePct float64 `json:"max_margin_usage_pct"`
+	MinDownsizePct    float64 `json:"min_downsize_pct"` // 可开仓位低于请求仓位该百分比时拦截而不是缩减
+}
+
+// DefaultPortfolioRiskConfig 默认组合风控限制（与单个交易员BTC/ETH最多10倍净值的校验保持一致）
+func DefaultPortfolioRiskConfig() PortfolioRiskConfig {
+	return PortfolioRiskConfig{
+		Enabled:           true,
+		MaxGrossLeverage:  10,
+		MaxNetLeverage:    10,
+		MaxSymbolExposure: 10,
+		MaxMarginUsagePct: 90,
+		MinDownsizePct:    20,
+	}
+}
+
+// LoadPortfolioRiskConfig 从系统配置加载组合风控限制
+func LoadPortfolioRiskConfig(db *config.Database) PortfolioRiskConfig {
+	cfg := DefaultPortfolioRiskConfig()
+	if db == nil {
+		return cfg
+	}
+
+	get := db.SystemConfigValue
+	if value := get("portfolio_risk_enabled"); value != "" {
+		cfg.Enabled = value == "true"
+	}
+	for key, target := range map[string]*float64{
+		"portfolio_max_gross_leverage":   &cfg.MaxGrossLeverage,
+		"portfolio_max_net_leverage":     &cfg.MaxNetLeverage,
+		"portfolio_max_symbol_exposure":  &cfg.MaxSymbolExposure,
+		"portfolio_max_margin_usage_pct": &cfg.MaxMarginUsagePct,
+		"portfolio_min_downsize_pct":     &cfg.MinDownsizePct,
+	} {
+		if value, err := strconv.ParseFloat(get(key), 64); err == nil && value >= 0 {
+			*target = value
+		}
+	}
+	return cfg
+}
+
+// portfolioPosition 组合中的一个持仓（或开仓预留）
+type portfolioPosition struct {
+	Symbol   string
+	Side     string // long / short
+	Notional float64
+	Margin   float64
+	TraderID string
+	At       time.Time
+}
+
+// portfolioAccount 一个交易所账户的快照（同一用户在同一交易所的多个交易员共享账户，只计一次）
+type portfolioAccount struct {
+	key       string
+	traders   map[string]bool
+	equity    float64
+	positions []portfolioPosition
+	reserved  []portfolioPosition // 已开仓但尚未出现在账户快照中的仓位
+	updatedAt time.Time
+}
+
+// SymbolExposure 单币种敞口
+type SymbolExposure struct {
+	Symbol        string  `json:"symbol"`
+	LongNotional  float64 `json:"long_notional"`
+	ShortNotional float64 `json:"short_notional"`
+	NetNotional   float64 `json:"net_notional"`
+	ExposurePct   float64 `json:"exposure_pct"` // 净敞口占总净值百分比
+}
+
+// AccountExposure 账户敞口
+type AccountExposure struct {
+	Account       string    `json:"account"`
+	Traders       []string  `json:"traders"`
+	Equity        float64   `json:"equity"`
+	GrossNotional float64   `json:"gross_notional"`
+	MarginUsed    float64   `json:"margin_used"`
+	Positions     int       `json:"positions"`
+	Reserved      int       `json:"reserved"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PortfolioRiskEvent 组合风控对开仓决策的拦截或缩减记录
+type PortfolioRiskEvent struct {
+	Time         time.Time `json:"time"`
+	TraderID     string    `json:"trader_id"`
+	Symbol       string    `json:"symbol"`
+	Action       string    `json:"action"`
+	RequestedUSD float64   `json:"requested_usd"`
+	AllowedUSD   float64   `json:"allowed_usd"`
+	Blocked      bool      `json:"blocked"`
+	Reason       string    `json:"reason"`
+}
+
+// PortfolioRiskSnapshot 用户组合风控状态
+type PortfolioRiskSnapshot struct {
+	UserID         string               `json:"user_id"`
+	Limits         PortfolioRiskConfig  `json:"limits"`
+	Equity         float64              `json:"equity"`
+	GrossNotional  float64              `json:"gross_notional"`
+	NetNotional    float64              `json:"net_notional"`
+	MarginUsed     float64              `json:"margin_used"`
+	GrossLeverage  float64              `json:"gross_leverage"`
+	NetLeverage    float64              `json:"net_leverage"`
+	MarginUsagePct float64              `json:"margin_usage_pct"`
+	Symbols        []SymbolExposure     `json:"symbols"`
+	Accounts       []AccountExposure    `json:"accounts"`
+	RecentEvents   []PortfolioRiskEvent `json:"recent_events"`
+}
+
+// PortfolioRiskService 用户级组合风控：汇总用户所有交易员/交易所的持仓，
+// 对总名义价值、净敞口、单币种敞口和保证金占用设上限，开仓前缩减或拦截
+type PortfolioRiskService struct {
+	mu     sync.RWMutex
+	config PortfolioRiskConfig
+	users  map[string]map[string]*portfolioAccount // userID -> 账户key -> 账户快照
+	events map[string][]PortfolioRiskEvent
+	now    func() time.Time
+}
+
+// NewPortfolioRiskService 创建组合风控服务
+func NewPortfolioRiskService(cfg PortfolioRiskConfig) *PortfolioRiskService {
+	return &PortfolioRiskService{
+		config: cfg,
+		users:  make(map[string]map[string]*portfolioAccount),
+		events: make(map[string][]PortfolioRiskEvent),
+		now:    time.Now,
+	}
+}
+
+// SetConfig 更新组合风控限制
+func (s *PortfolioRiskService) SetConfig(cfg PortfolioRiskConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = cfg
+}
+
+// Gate 创建交易员的组合风控闸门，account为交易员使用的交易所账户
+func (s *PortfolioRiskService) Gate(userID, traderID, account string) trader.RiskGate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountLocked(userID, account)
+	acc.traders[traderID] = true
+	return &portfolioGate{service: s, userID: userID, traderID: traderID, account: account}
+}
+
+// accountLocked 获取或创建账户快照（调用方需持有写锁）
+func (s *PortfolioRiskService) accountLocked(userID, account string) *portfolioAccount {
+	accounts, ok := s.users[userID]
+	if !ok {
+		accounts = make(map[string]*portfolioAccount)
+		s.users[userID] = accounts
+	}
+	acc, ok := accounts[account]
+	if !ok {
+		acc = &portfolioAccount{key: account, traders: make(map[string]bool)}
+		accounts[account] = acc
+	}
+	return acc
+}
+
+// UpdateAccount 用交易员本周期获取的账户快照替换账户持仓
+func (s *PortfolioRiskService) UpdateAccount(userID, account, traderID string, equity float64, positions []decision.PositionInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	acc := s.accountLocked(userID, account)
+	acc.traders[traderID] = true
+	acc.equity = equity
+	acc.updatedAt = now
+	acc.positions = acc.positions[:0]
+	for _, pos := range positions {
+		acc.positions = append(acc.positions, portfolioPosition{
+			Symbol:   pos.Symbol,
+			Side:     pos.Side,
+			Notional: pos.Quantity * pos.MarkPrice,
+			Margin:   pos.MarginUsed,
+			TraderID: traderID,
+			At:       now,
+		})
+	}
+
+	kept := acc.reserved[:0]
+	for _, r := range acc.reserved {
+		if now.Sub(r.At) < portfolioReservationTTL {
+			kept = append(kept, r)
+		}
+	}
+	acc.reserved = kept
+}
+
+// Reserve 开仓成功后预留额度，直到账户快照包含该持仓
+func (s *PortfolioRiskService) Reserve(userID, account, traderID string, check trader.RiskCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	margin := check.PositionSizeUSD
+	if check.Leverage > 0 {
+		margin = check.PositionSizeUSD / float64(check.Leverage)
+	}
+	acc := s.accountLocked(userID, account)
+	acc.reserved = append(acc.reserved, portfolioPosition{
+		Symbol:   check.Symbol,
+		Side:     check.Side(),
+		Notional: check.PositionSizeUSD,
+		Margin:   margin,
+		TraderID: traderID,
+		At:       s.now(),
+	})
+}
+
+// portfolioExposure 汇总后的用户敞口
+type portfolioExposure struct {
+	equity  float64
+	gross   float64
+	net     float64
+	margin  float64
+	symbols map[string]*SymbolExposure
+}
+
+// exposureLocked 汇总用户所有账户的敞口（调用方需持有锁）
+func (s *PortfolioRiskService) exposureLocked(userID string) portfolioExposure {
+	exp := portfolioExposure{symbols: make(map[string]*SymbolExposure)}
+	for _, acc := range s.users[userID] {
+		exp.equity += acc.equity
+		for _, list := range [][]portfolioPosition{acc.positions, acc.reserved} {
+			for _, pos := range list {
+				sym, ok := exp.symbols[pos.Symbol]
+				if !ok {
+					sym = &SymbolExposure{Symbol: pos.Symbol}
+					exp.symbols[pos.Symbol] = sym
+				}
+				if pos.Side == "short" {
+					sym.ShortNotional += pos.Notional
+					exp.net -= pos.Notional
+				} else {
+					sym.LongNotional += pos.Notional
+					exp.net += pos.Notional
+				}
+				exp.gross += pos.Notional
+				exp.margin += pos.Margin
+			}
+		}
+	}
+	for _, sym := range exp.symbols {
+		sym.NetNotional = sym.LongNotional - sym.ShortNotional
+		if exp.equity > 0 {
+			sym.ExposurePct = sym.NetNotional / exp.equity * 100
+		}
+	}
+	return exp
+}
+
+// MaxPositionSize 按组合剩余额度计算允许的最大开仓价值，额度不足时返回0
+func (s *PortfolioRiskService) MaxPositionSize(userID, traderID string, check trader.RiskCheck) (float64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requested := check.PositionSizeUSD
+	cfg := s.config
+	if !cfg.Enabled || requested <= 0 {
+		return requested, ""
+	}
+
+	exp := s.exposureLocked(userID)
+	equity := exp.equity
+	if equity <= 0 {
+		equity = check.Equity // 账户快照尚未上报时使用交易员自己的净值
+	}
+	if equity <= 0 {
+		return requested, ""
+	}
+
+	sign := 1.0
+	if check.Side() == "short" {
+		sign = -1.0
+	}
+	symbolNet := 0.0
+	if sym, ok := exp.symbols[check.Symbol]; ok {
+		symbolNet = sym.NetNotional
+	}
+
+	allowed, reason := requested, ""
+	limit := func(room float64, why string) {
+		if room < allowed {
+			allowed, reason = room, why
+		}
+	}
+	if cfg.MaxGrossLeverage > 0 {
+		ceiling := cfg.MaxGrossLeverage * equity
+		limit(ceiling-exp.gross, fmt.Sprintf("组合总名义价值 %.0f / 上限 %.0f USDT", exp.gross, ceiling))
+	}
+	// 新的净敞口 |net + sign*size| 不能超过上限
+	if cfg.MaxNetLeverage > 0 {
+		ceiling := cfg.MaxNetLeverage * equity
+		limit(ceiling-sign*exp.net, fmt.Sprintf("组合净敞口 %.0f / 上限 %.0f USDT", exp.net, ceiling))
+	}
+	if cfg.MaxSymbolExposure > 0 {
+		ceiling := cfg.MaxSymbolExposure * equity
+		limit(ceiling-sign*symbolNet, fmt.Sprintf("%s 组合净敞口 %.0f / 上限 %.0f USDT", check.Symbol, symbolNet, ceiling))
+	}
+	if cfg.MaxMarginUsagePct > 0 {
+		leverage := check.Leverage
+		if leverage <= 0 {
+			leverage = 1
+		}
+		ceiling := cfg.MaxMarginUsagePct / 100 * equity
+		limit((ceiling-exp.margin)*float64(leverage), fmt.Sprintf("组合保证金占用 %.0f / 上限 %.0f USDT", exp.margin, ceiling))
+	}
+
+	if allowed >= requested {
+		return requested, ""
+	}
+
+	blocked := allowed < portfolioMinOrderUSD || allowed < requested*cfg.MinDownsizePct/100
+	if blocked {
+		allowed = 0
+	}
+	s.recordEventLocked(userID, PortfolioRiskEvent{
+		Time:         s.now(),
+		TraderID:     traderID,
+		Symbol:       check.Symbol,
+		Action:       check.Action,
+		RequestedUSD: requested,
+		AllowedUSD:   allowed,
+		Blocked:      blocked,
+		Reason:       reason,
+	})
+	return allowed, reason
+}
+
+// recordEventLocked 记录拦截/缩减事件（调用方需持有写锁）
+func (s *PortfolioRiskService) recordEventLocked(userID string, event PortfolioRiskEvent) {
+	if event.Blocked {
+		log.Printf("⛔ [组合风控] 用户 %s 交易员 %s %s %s 被拦截: %s", userID, event.TraderID, event.Symbol, event.Action, event.Reason)
+	} else {
+		log.Printf("📉 [组合风控] 用户 %s 交易员 %s %s %s 仓位 %.2f → %.2f USDT: %s",
+			userID, event.TraderID, event.Symbol, event.Action, event.RequestedUSD, event.AllowedUSD, event.Reason)
+	}
+	events := append(s.events[userID], event)
+	if len(events) > portfolioMaxEvents {
+		events = events[len(events)-portfolioMaxEvents:]
+	}
+	s.events[userID] = events
+}
+
+// Snapshot 获取用户组合风控状态
+func (s *PortfolioRiskService) Snapshot(userID string) PortfolioRiskSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exp := s.exposureLocked(userID)
+	snapshot := PortfolioRiskSnapshot{
+		UserID:        userID,
+		Limits:        s.config,
+		Equity:        exp.equity,
+		GrossNotional: exp.gross,
+		NetNotional:   exp.net,
+		MarginUsed:    exp.margin,
+		Symbols:       []SymbolExposure{},
+		Accounts:      []AccountExposure{},
+		RecentEvents:  append([]PortfolioRiskEvent{}, s.events[userID]...),
+	}
+	if exp.equity > 0 {
+		snapshot.GrossLeverage = exp.gross / exp.equity
+		snapshot.NetLeverage = exp.net / exp.equity
+		snapshot.MarginUsagePct = exp.margin / exp.equity * 100
+	}
+	for _, sym := range exp.symbols {
+		snapshot.Symbols = append(snapshot.Symbols, *sym)
+	}
+	sort.Slice(snapshot.Symbols, func(i, j int) bool { return snapshot.Symbols[i].Symbol < snapshot.Symbols[j].Symbol })
+
+	for _, acc := range s.users[userID] {
+		entry := AccountExposure{
+			Account:   acc.key,
+			Equity:    acc.equity,
+			Positions: len(acc.positions),
+			Reserved:  len(acc.reserved),
+			UpdatedAt: acc.updatedAt,
+		}
+		for id := range acc.traders {
+			entry.Traders = append(entry.Traders, id)
+		}
+		sort.Strings(entry.Traders)
+		for _, list := range [][]portfolioPosition{acc.positions, acc.reserved} {
+			for _, pos := range list {
+				entry.GrossNotional += pos.Notional
+				entry.MarginUsed += pos.Margin
+			}
+		}
+		snapshot.Accounts = append(snapshot.Accounts, entry)
+	}
+	sort.Slice(snapshot.Accounts, func(i, j int) bool { return snapshot.Accounts[i].Account < snapshot.Accounts[j].Account })
+	return snapshot
+}
+
+// portfolioGate 交易员的组合风控闸门
+type portfolioGate struct {
+	service  *PortfolioRiskService
+	userID   string
+	traderID string
+	account  string
+}
+
+// Name 风控闸门名称
+func (g *portfolioGate) Name() string {
+	return "portfolio"
+}
+
+// Check 组合额度在MaxPositionSize中检查（额度不足时返回0由交易员拦截）
+func (g *portfolioGate) Check(check trader.RiskCheck) (bool, string) {
+	return true, ""
+}
+
+// MaxPositionSize 按组合剩余额度限制开仓仓位
+func (g *portfolioGate) MaxPositionSize(check trader.RiskCheck) (float64, string) {
+	return g.service.MaxPositionSize(g.userID, g.traderID, check)
+}
+
+// OnContext 上报本周期账户快照
+func (g *portfolioGate) OnContext(ctx *decision.Context) {
+	g.service.UpdateAccount(g.userID, g.account, g.traderID, ctx.Account.TotalEquity, ctx.Positions)
+}
+
+// OnTradeOpened 开仓成功后预留额度
+func (g *portfolioGate) OnTradeOpened(check trader.RiskCheck) {
+	g.service.Reserve(g.userID, g.account, g.traderID, check)
+}
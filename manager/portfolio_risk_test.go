@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"math"
+	"testing"
+
+	"nofx/decision"
+	"nofx/trader"
+)
+
+func TestPortfolioRiskSharedAccount(t *testing.T) {
+	svc := NewPortfolioRiskService(DefaultPortfolioRiskConfig())
+	cfg := DefaultPortfolioRiskConfig()
+	cfg.MaxSymbolExposure = 3
+	svc.SetConfig(cfg)
+
+	// 两个交易员共享同一个币安账户：净值10000，已有BTC多仓20000
+	positions := []decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long", Quantity: 0.2, MarkPrice: 100000, MarginUsed: 2000}}
+	gateA := svc.Gate("u1", "a", "binance")
+	gateB := svc.Gate("u1", "b", "binance")
+	ctx := &decision.Context{Account: decision.AccountInfo{TotalEquity: 10000}, Positions: positions}
+	gateA.(trader.RiskContextObserver).OnContext(ctx)
+	gateB.(trader.RiskContextObserver).OnContext(ctx)
+
+	snapshot := svc.Snapshot("u1")
+	if snapshot.Equity != 10000 || snapshot.GrossNotional != 20000 {
+		t.Fatalf("共享账户只应计一次: %+v", snapshot)
+	}
+
+	// BTC单币种上限3倍净值=30000，剩余10000，请求8000不受限
+	check := trader.RiskCheck{Symbol: "BTCUSDT", Action: "open_long", Leverage: 10, PositionSizeUSD: 8000, Equity: 10000}
+	limiter := gateB.(trader.RiskSizeLimiter)
+	if size, reason := limiter.MaxPositionSize(check); size != 8000 {
+		t.Fatalf("额度内不应缩减: %.2f %s", size, reason)
+	}
+	gateB.(trader.RiskOpenObserver).OnTradeOpened(check)
+
+	// 交易员A再开多：剩余2000，缩减到2000（不低于请求的20%）
+	check.PositionSizeUSD = 5000
+	if size, _ := gateA.(trader.RiskSizeLimiter).MaxPositionSize(check); math.Abs(size-2000) > 1e-6 {
+		t.Errorf("应缩减到剩余额度2000, got %.2f", size)
+	}
+
+	// 剩余额度低于请求的20%时拦截
+	check.PositionSizeUSD = 20000
+	if size, _ := gateA.(trader.RiskSizeLimiter).MaxPositionSize(check); size != 0 {
+		t.Errorf("剩余额度不足时应拦截, got %.2f", size)
+	}
+
+	// 反向开空降低净敞口，不受单币种上限约束
+	short := trader.RiskCheck{Symbol: "BTCUSDT", Action: "open_short", Leverage: 10, PositionSizeUSD: 20000, Equity: 10000}
+	if size, reason := gateA.(trader.RiskSizeLimiter).MaxPositionSize(short); size != 20000 {
+		t.Errorf("对冲方向不应被单币种上限缩减: %.2f %s", size, reason)
+	}
+
+	if events := svc.Snapshot("u1").RecentEvents; len(events) != 2 || events[0].Blocked || !events[1].Blocked {
+		t.Errorf("应记录一次缩减和一次拦截: %+v", events)
+	}
+}
+
+func TestPortfolioRiskMarginAndDisabled(t *testing.T) {
+	cfg := DefaultPortfolioRiskConfig()
+	cfg.MaxMarginUsagePct = 50
+	svc := NewPortfolioRiskService(cfg)
+
+	// 两个独立账户合计净值2000，保证金已用800，上限1000
+	svc.UpdateAccount("u1", "binance", "a", 1000, []decision.PositionInfo{{Symbol: "ETHUSDT", Side: "short", Quantity: 1, MarkPrice: 4000, MarginUsed: 800}})
+	svc.UpdateAccount("u1", "okx", "b", 1000, nil)
+
+	check := trader.RiskCheck{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 3000, Equity: 1000}
+	if size, _ := svc.MaxPositionSize("u1", "b", check); math.Abs(size-1000) > 1e-6 {
+		t.Errorf("剩余保证金200按5倍杠杆应允许1000, got %.2f", size)
+	}
+
+	// 其他用户的持仓互不影响，未上报快照时按交易员自身净值计算（500×5=2500）
+	if size, _ := svc.MaxPositionSize("u2", "c", check); math.Abs(size-2500) > 1e-6 {
+		t.Errorf("其他用户不应受u1持仓影响, got %.2f", size)
+	}
+
+	cfg.Enabled = false
+	svc.SetConfig(cfg)
+	if size, _ := svc.MaxPositionSize("u1", "b", check); size != 3000 {
+		t.Errorf("关闭组合风控后不应限制, got %.2f", size)
+	}
+}
@@ -26,7 +26,11 @@ type TraderManager struct {
         traders          map[string]*trader.AutoTrader // key: trader ID
         tradersToStart   map[string]bool               // 需要自动启动的交易员 (is_running=true in database)
         competitionCache *CompetitionCache
+        portfolioRisk    *PortfolioRiskService // 用户级组合风控（汇总同一用户所有交易员的持仓）
         mu               sync.RWMutex
+
+        listenerMu        sync.RWMutex
+        positionListeners []trader.PositionListener // 所有交易员的平仓事件监听者（学习协调器等）
 }
 
 // NewTraderManager 创建trader管理器
@@ -37,9 +41,44 @@ func NewTraderManager() *TraderManager {
                 competitionCache: &CompetitionCache{
                         data: make(map[string]interface{}),
                 },
+                portfolioRisk: NewPortfolioRiskService(DefaultPortfolioRiskConfig()),
+        }
+}
+
+// attachPortfolioRisk 为交易员添加组合风控闸门
+// 同一用户在同一交易所的交易员共享账户，纸面交易每个交易员独立账户
+func (tm *TraderManager) attachPortfolioRisk(at *trader.AutoTrader, traderCfg *config.TraderRecord, exchangeCfg *config.ExchangeConfig, database *config.Database) {
+        tm.portfolioRisk.SetConfig(LoadPortfolioRiskConfig(database))
+        account := exchangeCfg.ID
+        if account == "paper" {
+                account = "paper:" + traderCfg.ID
+        }
+        at.AddRiskGate(tm.portfolioRisk.Gate(traderCfg.UserID, traderCfg.ID, account))
+}
+
+// AddPositionListener 注册所有交易员的平仓事件监听者
+func (tm *TraderManager) AddPositionListener(listener trader.PositionListener) {
+        tm.listenerMu.Lock()
+        defer tm.listenerMu.Unlock()
+        tm.positionListeners = append(tm.positionListeners, listener)
+}
+
+// OnPositionClosed 将交易员的平仓事件转发给已注册的监听者（实现trader.PositionListener）
+func (tm *TraderManager) OnPositionClosed(traderID string, event trader.PositionEvent) {
+        tm.listenerMu.RLock()
+        listeners := append([]trader.PositionListener(nil), tm.positionListeners...)
+        tm.listenerMu.RUnlock()
+
+        for _, listener := range listeners {
+                listener.OnPositionClosed(traderID, event)
         }
 }
 
+// GetPortfolioRisk 获取用户的组合风控状态
+func (tm *TraderManager) GetPortfolioRisk(userID string) PortfolioRiskSnapshot {
+        return tm.portfolioRisk.Snapshot(userID)
+}
+
 // LoadTradersFromDatabase 从数据库加载所有交易员到内存
 func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) error {
         tm.mu.Lock()
@@ -240,12 +279,14 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
                 CustomModelName:       aiModelCfg.CustomModelName, // 自定义模型名称
                 ScanInterval:          time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
                 InitialBalance:        traderCfg.InitialBalance,
+                CreatedAt:             traderCfg.CreatedAt,
                 BTCETHLeverage:        traderCfg.BTCETHLeverage,
                 AltcoinLeverage:       traderCfg.AltcoinLeverage,
                 MaxDailyLoss:          maxDailyLoss,
                 MaxDrawdown:           maxDrawdown,
                 StopTradingTime:       time.Duration(stopTradingMinutes) * time.Minute,
                 IsCrossMargin:         traderCfg.IsCrossMargin,
+                TradingSchedule:       traderCfg.TradingSchedule,
                 DefaultCoins:          defaultCoins,
                 TradingCoins:          tradingCoins,
                 SystemPromptTemplate:  traderCfg.SystemPromptTemplate, // 系统提示词模板
@@ -253,27 +294,16 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
         }
 
         // 根据交易所类型设置API密钥
-        if exchangeCfg.ID == "binance" {
-                traderConfig.BinanceAPIKey = exchangeCfg.APIKey
-                traderConfig.BinanceSecretKey = exchangeCfg.SecretKey
-        } else if exchangeCfg.ID == "hyperliquid" {
-                traderConfig.HyperliquidPrivateKey = exchangeCfg.APIKey // hyperliquid用APIKey存储private key
-                traderConfig.HyperliquidWalletAddr = exchangeCfg.HyperliquidWalletAddr
-        } else if exchangeCfg.ID == "aster" {
-                traderConfig.AsterUser = exchangeCfg.AsterUser
-                traderConfig.AsterSigner = exchangeCfg.AsterSigner
-                traderConfig.AsterPrivateKey = exchangeCfg.AsterPrivateKey
-        } else if exchangeCfg.ID == "okx" {
-                traderConfig.OKXAPIKey = exchangeCfg.APIKey
-                traderConfig.OKXSecretKey = exchangeCfg.SecretKey
-                traderConfig.OKXPassphrase = exchangeCfg.OKXPassphrase
-        }
+        trader.ApplyExchangeCredentials(&traderConfig, exchangeCfg)
 
         // 根据AI模型设置API密钥
         if aiModelCfg.Provider == "qwen" {
                 traderConfig.QwenKey = aiModelCfg.APIKey
         } else if aiModelCfg.Provider == "deepseek" {
                 traderConfig.DeepSeekKey = aiModelCfg.APIKey
+        } else {
+                // openai、anthropic、gemini、local、custom 等提供商统一使用CustomAPIKey
+                traderConfig.CustomAPIKey = aiModelCfg.APIKey
         }
 
         // 创建trader实例
@@ -281,6 +311,8 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
         if err != nil {
                 return fmt.Errorf("创建trader失败: %w", err)
         }
+        tm.attachPortfolioRisk(at, traderCfg, exchangeCfg, database)
+        at.AddPositionListener(tm)
 
         // 设置自定义prompt（如果有）
         if traderCfg.CustomPrompt != "" {
@@ -365,12 +397,14 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
                 CustomModelName:       aiModelCfg.CustomModelName, // 自定义模型名称
                 ScanInterval:          time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
                 InitialBalance:        traderCfg.InitialBalance,
+                CreatedAt:             traderCfg.CreatedAt,
                 BTCETHLeverage:        traderCfg.BTCETHLeverage,
                 AltcoinLeverage:       traderCfg.AltcoinLeverage,
                 MaxDailyLoss:          maxDailyLoss,
                 MaxDrawdown:           maxDrawdown,
                 StopTradingTime:       time.Duration(stopTradingMinutes) * time.Minute,
                 IsCrossMargin:         traderCfg.IsCrossMargin,
+                TradingSchedule:       traderCfg.TradingSchedule,
                 DefaultCoins:          defaultCoins,
                 TradingCoins:          tradingCoins,
                 SystemPromptTemplate:  traderCfg.SystemPromptTemplate, // 系统提示词模板
@@ -378,27 +412,16 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
         }
 
         // 根据交易所类型设置API密钥
-        if exchangeCfg.ID == "binance" {
-                traderConfig.BinanceAPIKey = exchangeCfg.APIKey
-                traderConfig.BinanceSecretKey = exchangeCfg.SecretKey
-        } else if exchangeCfg.ID == "hyperliquid" {
-                traderConfig.HyperliquidPrivateKey = exchangeCfg.APIKey // hyperliquid用APIKey存储private key
-                traderConfig.HyperliquidWalletAddr = exchangeCfg.HyperliquidWalletAddr
-        } else if exchangeCfg.ID == "aster" {
-                traderConfig.AsterUser = exchangeCfg.AsterUser
-                traderConfig.AsterSigner = exchangeCfg.AsterSigner
-                traderConfig.AsterPrivateKey = exchangeCfg.AsterPrivateKey
-        } else if exchangeCfg.ID == "okx" {
-                traderConfig.OKXAPIKey = exchangeCfg.APIKey
-                traderConfig.OKXSecretKey = exchangeCfg.SecretKey
-                traderConfig.OKXPassphrase = exchangeCfg.OKXPassphrase
-        }
+        trader.ApplyExchangeCredentials(&traderConfig, exchangeCfg)
 
         // 根据AI模型设置API密钥
         if aiModelCfg.Provider == "qwen" {
                 traderConfig.QwenKey = aiModelCfg.APIKey
         } else if aiModelCfg.Provider == "deepseek" {
                 traderConfig.DeepSeekKey = aiModelCfg.APIKey
+        } else {
+                // openai、anthropic、gemini、local、custom 等提供商统一使用CustomAPIKey
+                traderConfig.CustomAPIKey = aiModelCfg.APIKey
         }
 
         // 创建trader实例
@@ -406,6 +429,8 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
         if err != nil {
                 return fmt.Errorf("创建trader失败: %w", err)
         }
+        tm.attachPortfolioRisk(at, traderCfg, exchangeCfg, database)
+        at.AddPositionListener(tm)
 
         // 设置自定义prompt（如果有）
         if traderCfg.CustomPrompt != "" {
@@ -641,6 +666,11 @@ func (tm *TraderManager) getConcurrentTraderData(traders []*trader.AutoTrader) [
                                         "total_equity":    account["total_equity"],
                                         "total_pnl":       account["total_pnl"],
                                         "total_pnl_pct":   account["total_pnl_pct"],
+                                        "gross_pnl":       account["gross_pnl"],
+                                        "gross_pnl_pct":   account["gross_pnl_pct"],
+                                        "net_pnl":         account["net_pnl"],
+                                        "total_fees":      account["total_fees"],
+                                        "total_funding":   account["total_funding"],
                                         "position_count":  account["position_count"],
                                         "margin_used_pct": account["margin_used_pct"],
                                         "is_running":      status["is_running"],
@@ -956,6 +986,7 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
                 AIModel:              aiModel,
                 Exchange:             exchangeID,
                 InitialBalance:       traderCfg.InitialBalance,
+                CreatedAt:            traderCfg.CreatedAt,
                 BTCETHLeverage:       traderCfg.BTCETHLeverage,
                 AltcoinLeverage:      traderCfg.AltcoinLeverage,
                 ScanInterval:         time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
@@ -968,6 +999,7 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
                 MaxDrawdown:          maxDrawdown,
                 StopTradingTime:      time.Duration(stopTradingMinutes) * time.Minute,
                 IsCrossMargin:        traderCfg.IsCrossMargin,
+                TradingSchedule:      traderCfg.TradingSchedule,
                 DefaultCoins:         defaultCoins,
                 TradingCoins:         tradingCoins,
                 SystemPromptTemplate: traderCfg.SystemPromptTemplate, // 系统提示词模板
@@ -1011,6 +1043,9 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
                 } else {
                         traderConfig.DeepSeekKey = aiModelCfg.APIKey
                 }
+        } else if aiModelCfg != nil {
+                // openai、anthropic、gemini、local、custom 等提供商统一使用CustomAPIKey
+                traderConfig.CustomAPIKey = aiModelCfg.APIKey
         }
 
         // 创建trader实例
@@ -1018,6 +1053,8 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
         if err != nil {
                 return fmt.Errorf("创建trader失败: %w", err)
         }
+        tm.attachPortfolioRisk(at, traderCfg, exchangeCfg, database)
+        at.AddPositionListener(tm)
 
         // 设置自定义prompt（如果有）
         if traderCfg.CustomPrompt != "" {
@@ -28,6 +28,26 @@ func Get(symbol string) (*Data, error) {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
 
+	// 获取OI数据
+	oiData, err := getOpenInterestData(symbol)
+	if err != nil {
+		// OI失败不影响整体,使用默认值
+		oiData = &OIData{Latest: 0, Average: 0}
+	}
+
+	// 获取Funding Rate
+	fundingRate, _ := getFundingRate(symbol)
+
+	return FromKlines(symbol, klines3m, klines4h, oiData, fundingRate)
+}
+
+// FromKlines 基于给定的K线序列计算市场数据（实时行情与历史回测共用）
+// klines3m 为3分钟K线，klines4h 为4小时K线，均按时间升序排列
+func FromKlines(symbol string, klines3m, klines4h []Kline, oiData *OIData, fundingRate float64) (*Data, error) {
+	if len(klines3m) == 0 {
+		return nil, fmt.Errorf("%s 缺少3分钟K线数据", symbol)
+	}
+
 	// 计算当前指标 (基于3分钟最新数据)
 	currentPrice := klines3m[len(klines3m)-1].Close
 	currentEMA20 := calculateEMA(klines3m, 20)
@@ -53,16 +73,6 @@ func Get(symbol string) (*Data, error) {
 		}
 	}
 
-	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
-	if err != nil {
-		// OI失败不影响整体,使用默认值
-		oiData = &OIData{Latest: 0, Average: 0}
-	}
-
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
-
 	// 计算日内系列数据
 	intradayData := calculateIntradaySeries(klines3m)
 
@@ -335,6 +345,11 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 	}, nil
 }
 
+// GetFundingRate 获取当前资金费率
+func GetFundingRate(symbol string) (float64, error) {
+	return getFundingRate(symbol)
+}
+
 // getFundingRate 获取资金费率 (使用OKX API)
 func getFundingRate(symbol string) (float64, error) {
 	symbol = strings.ToUpper(symbol)
@@ -22,7 +22,14 @@ type WSMonitor struct {
 	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
 	symbolStats    sync.Map // 存储币种统计信息
 	FilterSymbol   []string //经过筛选的币种
+
+	listenersMu    sync.RWMutex
+	klineListeners map[int]KlineListener // K线更新监听器（事件驱动交易触发器使用）
+	nextListenerID int
 }
+
+// KlineListener K线更新回调（在行情处理协程中同步调用，实现方不应阻塞）
+type KlineListener func(symbol, interval string, kline Kline)
 type SymbolStats struct {
 	LastActiveTime   time.Time
 	AlertCount       int
@@ -157,6 +164,7 @@ func (m *WSMonitor) startRESTPolling() {
 				klines, err := apiClient.GetKlines(symbol, "3m", 50)
 				if err == nil && len(klines) > 0 {
 					m.klineDataMap3m.Store(symbol, klines)
+					m.notifyKlineListeners(symbol, "3m", klines[len(klines)-1])
 				}
 				// 更新4小时K线
 				klines4h, err := apiClient.GetKlines(symbol, "4h", 50)
@@ -261,6 +269,34 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	}
 
 	klineDataMap.Store(symbol, klines)
+	m.notifyKlineListeners(symbol, _time, kline)
+}
+
+// AddKlineListener 注册K线更新监听器，返回取消注册的函数
+func (m *WSMonitor) AddKlineListener(listener KlineListener) func() {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+	if m.klineListeners == nil {
+		m.klineListeners = make(map[int]KlineListener)
+	}
+	id := m.nextListenerID
+	m.nextListenerID++
+	m.klineListeners[id] = listener
+
+	return func() {
+		m.listenersMu.Lock()
+		defer m.listenersMu.Unlock()
+		delete(m.klineListeners, id)
+	}
+}
+
+// notifyKlineListeners 通知所有K线监听器
+func (m *WSMonitor) notifyKlineListeners(symbol, interval string, kline Kline) {
+	m.listenersMu.RLock()
+	defer m.listenersMu.RUnlock()
+	for _, listener := range m.klineListeners {
+		listener(symbol, interval, kline)
+	}
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -28,6 +29,10 @@ type Client struct {
 	Model      string
 	Timeout    time.Duration
 	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
+
+	// ToolCalling 结构化输出（tool/function calling）模式
+	// 为空时按Provider自动判断，"on"强制启用，"off"强制关闭
+	ToolCalling string
 }
 
 func New() *Client {
@@ -118,8 +123,24 @@ func (client *Client) SetClient(Client Client) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return client.CallWithMessagesContext(context.Background(), systemPrompt, userPrompt)
+}
+
+// CallWithMessagesContext 同 CallWithMessages，ctx取消或超时时中断进行中的请求并停止重试
+func (client *Client) CallWithMessagesContext(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	var result string
+	err := client.callWithRetry(ctx, func() error {
+		var err error
+		result, err = client.callOnce(ctx, systemPrompt, userPrompt)
+		return err
+	})
+	return result, err
+}
+
+// callWithRetry 带重试地执行一次AI调用（网络类错误最多重试3次，ctx结束后不再重试）
+func (client *Client) callWithRetry(ctx context.Context, call func() error) error {
 	if client.APIKey == "" {
-		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
+		return fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
 
 	// 重试配置
@@ -131,44 +152,57 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := client.callOnce(systemPrompt, userPrompt)
+		err := call()
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
 			}
-			return result, nil
+			return nil
 		}
 
 		lastErr = err
-		// 如果不是网络错误，不重试
-		if !isRetryableError(err) {
-			return "", err
+		// 如果不是网络错误或调用方已取消，不重试
+		if !isRetryableError(err) || ctx.Err() != nil {
+			return err
 		}
 
 		// 重试前等待
 		if attempt < maxRetries {
 			waitTime := time.Duration(attempt) * 2 * time.Second
 			fmt.Printf("⏳ 等待%v后重试...\n", waitTime)
-			time.Sleep(waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return fmt.Errorf("等待重试时调用被取消: %w", ctx.Err())
+			}
 		}
 	}
 
-	return "", fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
+	return fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
 }
 
 // callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
-	// 打印当前 AI 配置
-	log.Printf("📡 [MCP] AI 请求配置:")
-	log.Printf("   Provider: %s", client.Provider)
-	log.Printf("   BaseURL: %s", client.BaseURL)
-	log.Printf("   Model: %s", client.Model)
-	log.Printf("   UseFullURL: %v", client.UseFullURL)
-	if len(client.APIKey) > 8 {
-		log.Printf("   API Key: %s...%s", client.APIKey[:4], client.APIKey[len(client.APIKey)-4:])
+func (client *Client) callOnce(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	// 构建请求体
+	requestBody := map[string]interface{}{
+		"model":       client.Model,
+		"messages":    buildMessages(systemPrompt, userPrompt),
+		"temperature": 0.5, // 降低temperature以提高JSON格式稳定性
+		"max_tokens":  2000,
 	}
 
-	// 构建 messages 数组
+	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
+	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
+
+	message, err := client.post(ctx, requestBody)
+	if err != nil {
+		return "", err
+	}
+	return message.Content, nil
+}
+
+// buildMessages 构建 messages 数组
+func buildMessages(systemPrompt, userPrompt string) []map[string]string {
 	messages := []map[string]string{}
 
 	// 如果有 system prompt，添加 system message
@@ -185,20 +219,37 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		"content": userPrompt,
 	})
 
-	// 构建请求体
-	requestBody := map[string]interface{}{
-		"model":       client.Model,
-		"messages":    messages,
-		"temperature": 0.5, // 降低temperature以提高JSON格式稳定性
-		"max_tokens":  2000,
-	}
+	return messages
+}
 
-	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
-	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
+// chatMessage 响应中的assistant消息
+type chatMessage struct {
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+// post 发送chat/completions请求并返回第一条消息
+func (client *Client) post(ctx context.Context, requestBody map[string]interface{}) (*chatMessage, error) {
+	// 打印当前 AI 配置
+	log.Printf("📡 [MCP] AI 请求配置:")
+	log.Printf("   Provider: %s", client.Provider)
+	log.Printf("   BaseURL: %s", client.BaseURL)
+	log.Printf("   Model: %s", client.Model)
+	log.Printf("   UseFullURL: %v", client.UseFullURL)
+	if len(client.APIKey) > 8 {
+		log.Printf("   API Key: %s...%s", client.APIKey[:4], client.APIKey[len(client.APIKey)-4:])
+	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
 	// 创建HTTP请求
@@ -212,9 +263,9 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	}
 	log.Printf("📡 [MCP] 请求 URL: %s", url)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -235,46 +286,44 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	httpClient := &http.Client{Timeout: client.Timeout}
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		// 特殊处理 402 余额不足错误
 		if resp.StatusCode == 402 {
-			return "", fmt.Errorf("AI API余额不足 (Insufficient Balance), 请检查充值: %s", string(body))
+			return nil, fmt.Errorf("AI API余额不足 (Insufficient Balance), 请检查充值: %s", string(body))
 		}
 		// 特殊处理 401 认证失败
 		if resp.StatusCode == 401 {
-			return "", fmt.Errorf("AI API密钥无效 (Unauthorized), 请检查配置: %s", string(body))
+			return nil, fmt.Errorf("AI API密钥无效 (Unauthorized), 请检查配置: %s", string(body))
 		}
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
 	var result struct {
 		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
+			Message chatMessage `json:"message"`
 		} `json:"choices"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("API返回空响应")
+		return nil, fmt.Errorf("API返回空响应")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return &result.Choices[0].Message, nil
 }
 
 // isRetryableError 判断错误是否可重试
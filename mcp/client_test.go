@@ -0,0 +1,41 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallWithMessagesContextCancelsRequest(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		io.Copy(io.Discard, r.Body)
+		// 模拟迟迟不返回的模型，直到客户端断开
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetCustomAPI(server.URL, "test-key", "test-model")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.CallWithMessagesContext(ctx, "system", "user"); err == nil {
+		t.Fatal("ctx超时后调用应失败")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ctx超时后应立即中断请求，实际耗时 %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("ctx结束后不应重试，实际请求 %d 次", n)
+	}
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ToolDefinition OpenAI兼容的工具定义
+type ToolDefinition struct {
+	Type     string       `json:"type"` // 固定为 "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 工具函数描述（Parameters 为 JSON Schema）
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall AI返回的一次工具调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON格式的参数
+}
+
+// StructuredResponse 结构化输出响应
+type StructuredResponse struct {
+	Content   string     `json:"content"`    // 文本部分（思维链）
+	ToolCalls []ToolCall `json:"tool_calls"` // 工具调用
+}
+
+// NewFunctionTool 创建函数工具定义
+func NewFunctionTool(name, description string, parameters map[string]interface{}) ToolDefinition {
+	return ToolDefinition{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+// SupportsToolCalling 当前Provider/模型是否支持tool calling
+// DeepSeek（推理模型除外）和Qwen兼容模式支持；自定义API需显式设置 ToolCalling = "on"
+func (client *Client) SupportsToolCalling() bool {
+	switch strings.ToLower(client.ToolCalling) {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+
+	switch client.Provider {
+	case ProviderDeepSeek:
+		return !strings.Contains(strings.ToLower(client.Model), "reasoner")
+	case ProviderQwen:
+		return true
+	default:
+		return false
+	}
+}
+
+// CallWithTools 使用 tool calling 调用AI API
+func (client *Client) CallWithTools(systemPrompt, userPrompt string, tools []ToolDefinition) (*StructuredResponse, error) {
+	return client.CallWithToolsContext(context.Background(), systemPrompt, userPrompt, tools)
+}
+
+// CallWithToolsContext 同 CallWithTools，ctx取消或超时时中断进行中的请求并停止重试
+func (client *Client) CallWithToolsContext(ctx context.Context, systemPrompt, userPrompt string, tools []ToolDefinition) (*StructuredResponse, error) {
+	if len(tools) == 0 {
+		return nil, fmt.Errorf("工具列表为空")
+	}
+
+	var result *StructuredResponse
+	err := client.callWithRetry(ctx, func() error {
+		requestBody := map[string]interface{}{
+			"model":       client.Model,
+			"messages":    buildMessages(systemPrompt, userPrompt),
+			"temperature": 0.5,
+			"max_tokens":  2000,
+			"tools":       tools,
+			"tool_choice": "auto",
+		}
+
+		message, err := client.post(ctx, requestBody)
+		if err != nil {
+			return err
+		}
+
+		result = &StructuredResponse{Content: message.Content}
+		for _, call := range message.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		return nil
+	})
+	return result, err
+}
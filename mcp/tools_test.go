@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallWithTools(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"choices":[{"message":{"content":"分析","tool_calls":[{"id":"call_1","type":"function","function":{"name":"close_short","arguments":"{\"symbol\":\"ETHUSDT\"}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetCustomAPI(server.URL, "test-key", "test-model")
+	client.ToolCalling = "on"
+
+	resp, err := client.CallWithTools("system", "user", []ToolDefinition{
+		NewFunctionTool("close_short", "平空", map[string]interface{}{"type": "object"}),
+	})
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+
+	if tools, ok := request["tools"].([]interface{}); !ok || len(tools) != 1 {
+		t.Errorf("请求应包含工具定义: %v", request["tools"])
+	}
+	if resp.Content != "分析" || len(resp.ToolCalls) != 1 {
+		t.Fatalf("响应解析错误: %+v", resp)
+	}
+	if resp.ToolCalls[0].Name != "close_short" || resp.ToolCalls[0].Arguments != `{"symbol":"ETHUSDT"}` {
+		t.Errorf("工具调用解析错误: %+v", resp.ToolCalls[0])
+	}
+}
+
+func TestSupportsToolCalling(t *testing.T) {
+	client := New()
+	if !client.SupportsToolCalling() {
+		t.Error("deepseek-chat 应支持tool calling")
+	}
+
+	client.Model = "deepseek-reasoner"
+	if client.SupportsToolCalling() {
+		t.Error("deepseek-reasoner 不支持tool calling")
+	}
+
+	client.SetCustomAPI("http://localhost", "key", "model")
+	if client.SupportsToolCalling() {
+		t.Error("自定义API默认不启用tool calling")
+	}
+	client.ToolCalling = "on"
+	if !client.SupportsToolCalling() {
+		t.Error("显式启用后应支持tool calling")
+	}
+}
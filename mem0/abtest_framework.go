@@ -7,6 +7,7 @@ import (
 	"math"
 	"math/rand"
 	"nofx/ai"
+	"sort"
 	"sync"
 	"time"
 )
@@ -33,6 +34,8 @@ type ABTestConfig struct {
 	TrafficSplit     map[string]float64 // variant -> traffic ratio
 	MetricsToTrack   []string      // 追踪的指标
 	SignificanceLevel float64       // 统计显著性阈值 (0.05)
+	Control          string        // 对照组变体（为空时按名称排序取第一个）
+	InitialCapital   float64       // 资金基数，>0时按净值计算回撤（否则按累计盈亏峰值计算）
 }
 
 // TestResult 测试结果
@@ -159,10 +162,7 @@ func (ab *ABTestFramework) RecordTrade(trade TradeRecord) {
 	result.SampleCount++
 
 	// 更新统计
-	if trade.PnL > 0 {
-		// 计数胜率
-		result.WinRate = float64(ab.countWins(result)) / float64(result.SampleCount)
-	}
+	result.WinRate = float64(ab.countWins(result)) / float64(result.SampleCount)
 
 	result.PnL += trade.PnL
 	result.AvgReturnPerTrade = result.PnL / float64(result.SampleCount)
@@ -186,7 +186,11 @@ func (ab *ABTestFramework) countWins(result *TestResult) int {
 func (ab *ABTestFramework) CalculateMetrics() {
 	ab.mu.Lock()
 	defer ab.mu.Unlock()
+	ab.calculateMetrics()
+}
 
+// calculateMetrics 计算所有指标（调用方持有锁）
+func (ab *ABTestFramework) calculateMetrics() {
 	for variant, result := range ab.results {
 		if len(result.TradesExecuted) == 0 {
 			continue
@@ -250,8 +254,9 @@ func (ab *ABTestFramework) calculateMaxDrawdown(returns []float64) float64 {
 		return 0
 	}
 
-	cumulative := 0.0
-	peak := 0.0
+	// 有资金基数时按净值曲线计算，否则按累计盈亏曲线计算（峰值<=0时无法计算比例）
+	cumulative := ab.config.InitialCapital
+	peak := cumulative
 	maxDD := 0.0
 
 	for _, r := range returns {
@@ -259,6 +264,9 @@ func (ab *ABTestFramework) calculateMaxDrawdown(returns []float64) float64 {
 		if cumulative > peak {
 			peak = cumulative
 		}
+		if peak <= 0 {
+			continue
+		}
 
 		dd := (peak - cumulative) / peak
 		if dd > maxDD {
@@ -269,18 +277,28 @@ func (ab *ABTestFramework) calculateMaxDrawdown(returns []float64) float64 {
 	return maxDD
 }
 
-// PerformStatisticalTest 执行统计检验
+// PerformStatisticalTest 执行统计检验（对照组 vs 另一个变体的Welch t检验，正态近似计算双侧p值）
 func (ab *ABTestFramework) PerformStatisticalTest() map[string]interface{} {
 	ab.mu.Lock()
 	defer ab.mu.Unlock()
+	return ab.performStatisticalTest()
+}
 
+// performStatisticalTest 执行统计检验（调用方持有锁）
+func (ab *ABTestFramework) performStatisticalTest() map[string]interface{} {
 	results := make(map[string]interface{})
 
-	// 对比各variant
+	// 对比各variant：对照组在前，其余按名称排序
 	variants := make([]string, 0)
 	for k := range ab.results {
 		variants = append(variants, k)
 	}
+	sort.Slice(variants, func(i, j int) bool {
+		if (variants[i] == ab.config.Control) != (variants[j] == ab.config.Control) {
+			return variants[i] == ab.config.Control
+		}
+		return variants[i] < variants[j]
+	})
 
 	if len(variants) < 2 {
 		log.Printf("⚠️ 至少需要2个变体才能对比")
@@ -305,21 +323,35 @@ func (ab *ABTestFramework) PerformStatisticalTest() map[string]interface{} {
 	// 计算标准误
 	seError := ab.calculateStandardError(v1PnL, v2PnL)
 
+	alpha := ab.config.SignificanceLevel
+	if alpha <= 0 {
+		alpha = 0.05
+	}
+
 	// 检验统计量
+	significant := false
+	pValue := 1.0
 	if seError > 0 {
 		tStat := meanDiff / seError
+		pValue = math.Erfc(abs(tStat) / math.Sqrt2)
+		significant = pValue < alpha
 		results["t_statistic"] = tStat
-		results["is_significant"] = abs(tStat) > 1.96 // 95%置信度
 	}
 
+	results["is_significant"] = significant
 	results["mean_difference"] = meanDiff
 	results["variant_1"] = variants[0]
 	results["variant_2"] = variants[1]
-	results["p_value"] = 0.05 // 简化
+	results["p_value"] = pValue
 
-	if meanDiff > 0 {
-		v2Result.SignificantlyBetter = true
-		v2Result.IsWinner = true
+	// 只有差异显著时才判定胜者
+	if significant {
+		winner := v2Result
+		if meanDiff < 0 {
+			winner = v1Result
+		}
+		winner.SignificantlyBetter = true
+		winner.IsWinner = true
 		ab.metrics.SignificantWins++
 	}
 
@@ -390,8 +422,8 @@ func (ab *ABTestFramework) CompleteTest() map[string]interface{} {
 	ab.mu.Lock()
 	defer ab.mu.Unlock()
 
-	ab.CalculateMetrics()
-	testResults := ab.PerformStatisticalTest()
+	ab.calculateMetrics()
+	testResults := ab.performStatisticalTest()
 
 	summary := map[string]interface{}{
 		"test_id":    ab.testID,
@@ -422,6 +454,18 @@ func (ab *ABTestFramework) CompleteTest() map[string]interface{} {
 	return summary
 }
 
+// Results 获取各变体结果的副本
+func (ab *ABTestFramework) Results() map[string]TestResult {
+	ab.mu.RLock()
+	defer ab.mu.RUnlock()
+
+	results := make(map[string]TestResult, len(ab.results))
+	for variant, result := range ab.results {
+		results[variant] = *result
+	}
+	return results
+}
+
 // NewGetFullDecisionV2 创建增强决策器,集成AI模型工厂
 // cfg: Mem0配置,包含模型选择(gemini/gpt-4等)和降级策略
 // 返回error: 如果模型创建失败(但不会阻止系统,会降级使用备用模型)
@@ -37,6 +37,14 @@ type Config struct {
 	VectorDim          int
 	SimilarityThreshold float64
 
+	// 存储后端 (http: 托管Mem0服务, postgres: 本地数据库，记忆不外发)
+	StoreBackend      string
+	EmbeddingProvider string // 本地存储的向量化方式: hash(本地哈希), openai(OpenAI兼容接口，可指向自建服务)
+	EmbeddingAPIURL   string
+	EmbeddingAPIKey   string
+	EmbeddingModel    string
+	VectorSearch      string // auto, pgvector, go
+
 	// 缓存和预热
 	CacheTTLMinutes    int
 	WarmupInterval     int
@@ -88,21 +96,39 @@ func LoadConfig(store StateStore) (*Config, error) {
 		return cfg, nil
 	}
 
-	// 2. 读取API认证(必需)
+	return loadSettings(store, cfg)
+}
+
+// LoadSettings 从数据库加载Mem0存储和行为配置（不检查mem0_enabled，启用与否由调用方的功能标志决定）
+func LoadSettings(store StateStore) (*Config, error) {
+	return loadSettings(store, &Config{Enabled: true})
+}
+
+// loadSettings 读取开关以外的Mem0配置
+func loadSettings(store StateStore, cfg *Config) (*Config, error) {
+	// 2. 读取存储后端和API认证(托管服务必需，本地存储不需要)
+	cfg.StoreBackend, _ = store.GetSystemConfig("mem0_store_backend")
+	if cfg.StoreBackend == "" {
+		cfg.StoreBackend = "http"
+	}
+	if cfg.StoreBackend != "http" && cfg.StoreBackend != "postgres" {
+		return nil, fmt.Errorf("❌ 不支持的Mem0存储后端: %s (可选 http, postgres)", cfg.StoreBackend)
+	}
+
 	var missingKeys []string
 
 	cfg.APIKey, _ = store.GetSystemConfig("mem0_api_key")
-	if cfg.APIKey == "" {
+	if cfg.APIKey == "" && cfg.StoreBackend == "http" {
 		missingKeys = append(missingKeys, "mem0_api_key")
 	}
 
 	cfg.UserID, _ = store.GetSystemConfig("mem0_user_id")
-	if cfg.UserID == "" {
+	if cfg.UserID == "" && cfg.StoreBackend == "http" {
 		missingKeys = append(missingKeys, "mem0_user_id")
 	}
 
 	cfg.OrgID, _ = store.GetSystemConfig("mem0_organization_id")
-	if cfg.OrgID == "" {
+	if cfg.OrgID == "" && cfg.StoreBackend == "http" {
 		missingKeys = append(missingKeys, "mem0_organization_id")
 	}
 
@@ -152,6 +178,19 @@ func LoadConfig(store StateStore) (*Config, error) {
 		cfg.SimilarityThreshold = val
 	}
 
+	cfg.EmbeddingProvider, _ = store.GetSystemConfig("mem0_embedding_provider")
+	if cfg.EmbeddingProvider == "" {
+		cfg.EmbeddingProvider = "hash"
+	}
+	cfg.EmbeddingAPIURL, _ = store.GetSystemConfig("mem0_embedding_api_url")
+	cfg.EmbeddingAPIKey, _ = store.GetSystemConfig("mem0_embedding_api_key")
+	cfg.EmbeddingModel, _ = store.GetSystemConfig("mem0_embedding_model")
+
+	cfg.VectorSearch, _ = store.GetSystemConfig("mem0_vector_search")
+	if cfg.VectorSearch == "" {
+		cfg.VectorSearch = VectorSearchAuto
+	}
+
 	// 5. 读取缓存配置
 	cacheTTLStr, _ := store.GetSystemConfig("mem0_cache_ttl_minutes")
 	cfg.CacheTTLMinutes = 30
@@ -269,6 +308,7 @@ func LoadConfig(store StateStore) (*Config, error) {
 
 	// 日志输出
 	log.Println("✅ Mem0配置加载完成:")
+	log.Printf("   - 存储后端: %s", cfg.StoreBackend)
 	log.Printf("   - API URL: %s", cfg.APIURL)
 	log.Printf("   - 用户ID: %s", maskString(cfg.UserID, 4))
 	log.Printf("   - 模型: %s (温度: %.1f)", cfg.Model, cfg.Temperature)
@@ -325,6 +365,7 @@ func (c *Config) PrintConfig() {
 	log.Printf("  Memory Limit: %d tokens", c.MemoryLimit)
 	log.Printf("  Vector Dim: %d", c.VectorDim)
 	log.Printf("  Similarity Threshold: %.2f", c.SimilarityThreshold)
+	log.Printf("  Store Backend: %s (embedding: %s, vector search: %s)", c.StoreBackend, c.EmbeddingProvider, c.VectorSearch)
 	log.Printf("  Cache TTL: %d min", c.CacheTTLMinutes)
 	log.Printf("  Warmup Enabled: %v (interval: %d min)", c.WarmupEnabled, c.WarmupInterval)
 	log.Printf("  Circuit Breaker: %v (threshold: %d, timeout: %d sec)", c.CircuitBreakerEnabled, c.CircuitBreakerThreshold, c.CircuitBreakerTimeoutSecs)
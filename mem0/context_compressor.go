@@ -167,6 +167,11 @@ func (cc *ContextCompressor) sortByRelevance(memories []Memory) []Memory {
 	return sorted
 }
 
+// ResetDeduplication 清空去重集合（每个决策周期独立压缩时调用，避免上个周期保留的记忆被判为重复）
+func (cc *ContextCompressor) ResetDeduplication() {
+	cc.deduplicator.Clear()
+}
+
 // estimateTokens 估算字符串的token数(简单线性估计)
 func (cc *ContextCompressor) estimateTokens(s string) int {
 	if s == "" {
@@ -293,6 +298,7 @@ func (d *Deduplicator) Clear() {
 	defer d.mu.Unlock()
 
 	d.seenContent = make(map[string]bool)
+	d.addedOrder = make([]string, 0)
 }
 
 // ===== Metrics Methods =====
@@ -0,0 +1,234 @@
+package mem0
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder 文本向量化接口（本地记忆存储用于语义检索）
+type Embedder interface {
+	// Embed 将一批文本转换为向量（返回顺序与输入一致）
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Dimension 向量维度
+	Dimension() int
+	// Name 向量化方式名称（记录在记忆上，更换向量化方式后旧向量不参与相似度比较）
+	Name() string
+}
+
+// NewEmbedder 根据配置创建向量化实现
+func NewEmbedder(cfg *Config) (Embedder, error) {
+	switch cfg.EmbeddingProvider {
+	case "", "hash":
+		return NewHashEmbedder(cfg.VectorDim), nil
+	case "openai":
+		if cfg.EmbeddingAPIURL == "" {
+			return nil, fmt.Errorf("❌ mem0_embedding_api_url 未配置")
+		}
+		return NewOpenAIEmbedder(cfg.EmbeddingAPIURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel, cfg.VectorDim), nil
+	default:
+		return nil, fmt.Errorf("❌ 不支持的向量化方式: %s", cfg.EmbeddingProvider)
+	}
+}
+
+// HashEmbedder 本地特征哈希向量化（确定性、无外部依赖，适合测试和不允许数据出境的部署）
+// 英文/数字按单词切分，中文按单字和相邻双字切分，每个词哈希到一个维度并带符号累加
+type HashEmbedder struct {
+	dim int
+}
+
+// NewHashEmbedder 创建本地哈希向量化（维度<=0时使用256）
+func NewHashEmbedder(dim int) *HashEmbedder {
+	if dim <= 0 {
+		dim = 256
+	}
+	return &HashEmbedder{dim: dim}
+}
+
+// Embed 将文本转换为L2归一化的哈希向量
+func (h *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, h.dim)
+		for _, token := range tokenize(text) {
+			hasher := fnv.New64a()
+			hasher.Write([]byte(token))
+			sum := hasher.Sum64()
+			sign := float32(1)
+			if sum>>63 == 1 {
+				sign = -1
+			}
+			vec[sum%uint64(h.dim)] += sign
+		}
+		normalize(vec)
+		vectors[i] = vec
+	}
+	return vectors, nil
+}
+
+// Dimension 向量维度
+func (h *HashEmbedder) Dimension() int { return h.dim }
+
+// Name 向量化方式名称
+func (h *HashEmbedder) Name() string { return fmt.Sprintf("hash-%d", h.dim) }
+
+// tokenize 切分文本：英文/数字按单词（小写），中文等表意文字按单字和相邻双字
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var prevHan rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+			if prevHan != 0 {
+				tokens = append(tokens, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return tokens
+}
+
+// normalize L2归一化（零向量保持不变）
+func normalize(vec []float32) {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vec {
+		vec[i] /= norm
+	}
+}
+
+// cosineSimilarity 余弦相似度（维度不同或存在零向量时返回0）
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// OpenAIEmbedder OpenAI兼容的向量化接口（/embeddings），可指向自建服务（如Ollama、vLLM）避免数据外发
+type OpenAIEmbedder struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	dim        int
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbedder 创建OpenAI兼容的向量化客户端
+func NewOpenAIEmbedder(baseURL, apiKey, model string, dim int) *OpenAIEmbedder {
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+	return &OpenAIEmbedder{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		dim:        dim,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Embed 调用 /embeddings 接口
+func (o *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody := map[string]interface{}{
+		"model": o.model,
+		"input": texts,
+	}
+	if o.dim > 0 {
+		reqBody["dimensions"] = o.dim
+	}
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 编码请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/embeddings", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("❌ 创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 请求向量化服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("❌ 向量化服务错误 (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("❌ 解析响应失败: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("❌ 向量化结果数量不匹配: 期望%d, 实际%d", len(texts), len(result.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("❌ 向量化结果索引越界: %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// Dimension 向量维度
+func (o *OpenAIEmbedder) Dimension() int { return o.dim }
+
+// Name 向量化方式名称
+func (o *OpenAIEmbedder) Name() string { return "openai-" + o.model }
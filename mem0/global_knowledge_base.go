@@ -22,6 +22,7 @@ type GlobalKnowledgeBase struct {
 	metrics             *KBMetrics
 	lastSyncAt          *time.Time
 	typeIndexes         map[string][]Memory // 按类型索引
+	filterFunc          func() []QueryFilter // 额外的查询条件（每次同步时获取，如限定交易员范围）
 }
 
 // KBMetrics 知识库指标
@@ -60,10 +61,26 @@ func NewGlobalKnowledgeBase(store MemoryStore) *GlobalKnowledgeBase {
 	}
 }
 
-// Initialize 初始化知识库(从Mem0加载高质量记忆)
+// SetFilterFunc 设置额外的查询条件（在Initialize之前调用，每次同步时重新获取）
+func (kb *GlobalKnowledgeBase) SetFilterFunc(filterFunc func() []QueryFilter) *GlobalKnowledgeBase {
+	kb.filterFunc = filterFunc
+	return kb
+}
+
+// Initialize 初始化知识库(从Mem0加载高质量记忆)并启动定期同步
 func (kb *GlobalKnowledgeBase) Initialize(ctx context.Context) error {
 	log.Println("🔄 初始化GlobalKnowledgeBase...")
 
+	err := kb.load(ctx)
+
+	// 启动定期同步（首次加载失败时也会在下次同步时重试）
+	go kb.syncLoop(ctx)
+
+	return err
+}
+
+// load 从Mem0加载高质量记忆并重建索引
+func (kb *GlobalKnowledgeBase) load(ctx context.Context) error {
 	// Step 1: 查询所有高质量记忆(score >= 0.8)
 	query := Query{
 		Type: "graph_query",
@@ -73,6 +90,9 @@ func (kb *GlobalKnowledgeBase) Initialize(ctx context.Context) error {
 		},
 		Limit: 10000,
 	}
+	if kb.filterFunc != nil {
+		query.Filters = append(query.Filters, kb.filterFunc()...)
+	}
 
 	memories, err := kb.store.Search(ctx, query)
 	if err != nil {
@@ -99,9 +119,6 @@ func (kb *GlobalKnowledgeBase) Initialize(ctx context.Context) error {
 	log.Printf("✅ 知识库初始化完成: %d条高质量参考 (quality >= %.1f)",
 		len(memories), kb.qualityThreshold)
 
-	// Step 4: 启动定期同步
-	go kb.syncLoop(ctx)
-
 	return nil
 }
 
@@ -261,7 +278,7 @@ func (kb *GlobalKnowledgeBase) syncLoop(ctx context.Context) {
 			log.Println("🛑 知识库同步已停止")
 			return
 		case <-ticker.C:
-			if err := kb.Initialize(ctx); err != nil {
+			if err := kb.load(ctx); err != nil {
 				log.Printf("⚠️ 知识库同步失败: %v", err)
 			}
 		}
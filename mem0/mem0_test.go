@@ -831,3 +831,44 @@ func TestP0_StandardErrorFix(t *testing.T) {
 
 	t.Logf("✅ P0#1标准误修复验证通过: SE=%.4f (正确使用sqrt)", se)
 }
+
+// TestABTestFrameworkControlAndDrawdown 对照组顺序固定、p值和按资金计算回撤
+func TestABTestFrameworkControlAndDrawdown(t *testing.T) {
+	ab := NewABTestFramework("control_test", ABTestConfig{
+		TrafficSplit:   map[string]float64{"z_control": 0.5, "a_treatment": 0.5},
+		Control:        "z_control",
+		InitialCapital: 100,
+	})
+	ab.InitializeVariants()
+	for i := 0; i < 20; i++ {
+		ab.RecordTrade(TradeRecord{Variant: "z_control", PnL: -1 + float64(i%3)*0.8})
+		ab.RecordTrade(TradeRecord{Variant: "a_treatment", PnL: 2 + float64(i%3)*0.5})
+	}
+	ab.CalculateMetrics()
+
+	test := ab.PerformStatisticalTest()
+	if test["variant_1"] != "z_control" || test["variant_2"] != "a_treatment" {
+		t.Fatalf("❌ 对照组应排在前面: %v", test)
+	}
+	if p := test["p_value"].(float64); p >= 0.05 || test["is_significant"] != true {
+		t.Errorf("❌ 差异明显时应显著: %v", test)
+	}
+
+	results := ab.Results()
+	if !results["a_treatment"].IsWinner || results["z_control"].IsWinner {
+		t.Errorf("❌ 胜者判定不正确: %+v", results)
+	}
+	if results["a_treatment"].MaxDrawdown != 0 || results["z_control"].MaxDrawdown <= 0 || results["z_control"].MaxDrawdown >= 1 {
+		t.Errorf("❌ 回撤应按资金基数计算: control=%.4f treatment=%.4f",
+			results["z_control"].MaxDrawdown, results["a_treatment"].MaxDrawdown)
+	}
+	if results["z_control"].WinRate == 0 || results["z_control"].WinRate == 1 {
+		t.Errorf("❌ 亏损交易后胜率也应更新: %.2f", results["z_control"].WinRate)
+	}
+
+	// 没有资金基数且累计盈亏始终为负时回撤不应为NaN/Inf
+	ab2 := NewABTestFramework("nan_test", ABTestConfig{})
+	if dd := ab2.calculateMaxDrawdown([]float64{-10, -5, 3}); dd != 0 {
+		t.Errorf("❌ 峰值<=0时回撤应为0: %v", dd)
+	}
+}
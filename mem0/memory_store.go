@@ -2,13 +2,15 @@ package mem0
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"time"
 )
 
 // QueryFilter 查询过滤条件
 type QueryFilter struct {
 	Field    string      // 字段名
-	Operator string      // 操作符: "eq", "gt", "lt", "in", "contains"
+	Operator string      // 操作符: "eq", "gt", "gte", "lt", "lte", "in", "contains"
 	Value    interface{} // 值
 }
 
@@ -105,3 +107,21 @@ type SearchResult struct {
 	Timestamp time.Time
 	Duration  time.Duration
 }
+
+// NewMemoryStore 根据配置创建记忆存储（postgres后端使用应用自身的数据库连接db）
+func NewMemoryStore(ctx context.Context, cfg *Config, db *sql.DB) (MemoryStore, error) {
+	switch cfg.StoreBackend {
+	case "", "http":
+		return NewHTTPStore(cfg.APIURL, cfg.APIKey, cfg.UserID, cfg.OrgID), nil
+	case "postgres":
+		embedder, err := NewEmbedder(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts := DefaultPostgresStoreOptions()
+		opts.VectorSearch = cfg.VectorSearch
+		return NewPostgresStore(ctx, db, embedder, opts)
+	default:
+		return nil, fmt.Errorf("❌ 不支持的Mem0存储后端: %s", cfg.StoreBackend)
+	}
+}
@@ -0,0 +1,859 @@
+package mem0
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 向量检索方式
+const (
+	VectorSearchAuto     = "auto"     // 安装了pgvector扩展时使用向量索引，否则在Go中计算
+	VectorSearchPgvector = "pgvector" // 必须使用pgvector（扩展不可用时创建存储失败）
+	VectorSearchGo       = "go"       // 始终在Go中计算余弦相似度
+)
+
+// memoryColumns 记忆表查询列（与scanMemory顺序一致）
+const memoryColumns = "id, content, type, metadata, quality_score, status, reflection_id, embedding, embedding_model, created_at, updated_at"
+
+// memoryFilterColumns 可以直接在SQL中过滤的字段（其他字段按metadata过滤）
+var memoryFilterColumns = map[string]bool{
+	"id": true, "content": true, "type": true, "status": true, "quality_score": true,
+	"reflection_id": true, "created_at": true, "updated_at": true,
+}
+
+// PostgresStoreOptions 本地记忆存储选项
+type PostgresStoreOptions struct {
+	VectorSearch      string  // 向量检索方式: auto, pgvector, go
+	MaxScan           int     // Go计算相似度时最多扫描的记忆数（按时间倒序）
+	AutoLinkThreshold float64 // 保存时与已有记忆相似度达到该值自动建立similar_to关系（0表示关闭）
+	MaxAutoLinks      int     // 每条记忆最多自动建立的关系数
+}
+
+// DefaultPostgresStoreOptions 默认本地记忆存储选项
+func DefaultPostgresStoreOptions() PostgresStoreOptions {
+	return PostgresStoreOptions{
+		VectorSearch:      VectorSearchAuto,
+		MaxScan:           5000,
+		AutoLinkThreshold: 0.85,
+		MaxAutoLinks:      3,
+	}
+}
+
+// PostgresStore 基于自有PostgreSQL的记忆存储（记忆和向量都不离开本地数据库）
+type PostgresStore struct {
+	db       *sql.DB
+	embedder Embedder
+	opts     PostgresStoreOptions
+	pgvector bool // 是否使用pgvector列和索引
+}
+
+// NewPostgresStore 创建本地记忆存储并确保表结构存在（db由调用方管理，Close不会关闭连接）
+func NewPostgresStore(ctx context.Context, db *sql.DB, embedder Embedder, opts PostgresStoreOptions) (*PostgresStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("❌ 数据库连接为空")
+	}
+	if embedder == nil {
+		embedder = NewHashEmbedder(0)
+	}
+	defaults := DefaultPostgresStoreOptions()
+	if opts.VectorSearch == "" {
+		opts.VectorSearch = defaults.VectorSearch
+	}
+	if opts.MaxScan <= 0 {
+		opts.MaxScan = defaults.MaxScan
+	}
+	if opts.MaxAutoLinks <= 0 {
+		opts.MaxAutoLinks = defaults.MaxAutoLinks
+	}
+
+	s := &PostgresStore{db: db, embedder: embedder, opts: opts}
+	if err := s.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+	mode := "Go余弦相似度"
+	if s.pgvector {
+		mode = "pgvector"
+	}
+	log.Printf("✅ 本地记忆存储已就绪 (向量化: %s, 检索: %s)", embedder.Name(), mode)
+	return s, nil
+}
+
+// ensureSchema 创建记忆表和关系表，按配置启用pgvector列和索引
+func (s *PostgresStore) ensureSchema(ctx context.Context) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS mem0_memories (
+			id TEXT PRIMARY KEY,
+			content TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT '',
+			metadata TEXT NOT NULL DEFAULT '{}',
+			quality_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT '',
+			reflection_id TEXT,
+			embedding TEXT NOT NULL DEFAULT '[]',
+			embedding_model TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mem0_memories_type ON mem0_memories(type)`,
+		`CREATE INDEX IF NOT EXISTS idx_mem0_memories_quality ON mem0_memories(quality_score)`,
+		`CREATE INDEX IF NOT EXISTS idx_mem0_memories_created ON mem0_memories(created_at)`,
+		`CREATE TABLE IF NOT EXISTS mem0_relationships (
+			source_id TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			weight DOUBLE PRECISION NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (source_id, target_id, type)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mem0_relationships_target ON mem0_relationships(target_id)`,
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("❌ 创建记忆表失败: %w", err)
+		}
+	}
+
+	if s.opts.VectorSearch == VectorSearchGo {
+		return nil
+	}
+	if err := s.enablePgvector(ctx); err != nil {
+		if s.opts.VectorSearch == VectorSearchPgvector {
+			return err
+		}
+		log.Printf("ℹ️ pgvector不可用，使用Go计算相似度: %v", err)
+	}
+	return nil
+}
+
+// enablePgvector 检查pgvector扩展并创建向量列和HNSW索引
+func (s *PostgresStore) enablePgvector(ctx context.Context) error {
+	dim := s.embedder.Dimension()
+	if dim <= 0 {
+		return fmt.Errorf("❌ 向量维度未知，无法创建pgvector列")
+	}
+	if s.opts.VectorSearch == VectorSearchPgvector {
+		if _, err := s.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+			return fmt.Errorf("❌ 启用pgvector扩展失败: %w", err)
+		}
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pg_extension WHERE extname = 'vector'`).Scan(&count); err != nil {
+		return fmt.Errorf("❌ 查询pgvector扩展失败: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("❌ 未安装pgvector扩展")
+	}
+	statements := []string{
+		fmt.Sprintf(`ALTER TABLE mem0_memories ADD COLUMN IF NOT EXISTS embedding_vec vector(%d)`, dim),
+		`CREATE INDEX IF NOT EXISTS idx_mem0_memories_embedding ON mem0_memories USING hnsw (embedding_vec vector_cosine_ops)`,
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("❌ 创建pgvector列失败: %w", err)
+		}
+	}
+	s.pgvector = true
+	return nil
+}
+
+// rowScanner sql.Row 和 sql.Rows 的公共接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMemory 读取一行记忆（返回向量和向量化方式）
+func scanMemory(row rowScanner) (Memory, []float32, string, error) {
+	var m Memory
+	var metadata, embedding, model string
+	var reflectionID sql.NullString
+	if err := row.Scan(&m.ID, &m.Content, &m.Type, &metadata, &m.QualityScore, &m.Status, &reflectionID,
+		&embedding, &model, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return m, nil, "", err
+	}
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &m.Metadata); err != nil {
+			log.Printf("⚠️ 解析记忆元数据失败 (%s): %v", m.ID, err)
+		}
+	}
+	if reflectionID.Valid {
+		id := reflectionID.String
+		m.ReflectionID = &id
+	}
+	var vec []float32
+	if embedding != "" {
+		if err := json.Unmarshal([]byte(embedding), &vec); err != nil {
+			log.Printf("⚠️ 解析记忆向量失败 (%s): %v", m.ID, err)
+		}
+	}
+	return m, vec, model, nil
+}
+
+// sqlFilter 构建WHERE子句和参数（PostgreSQL的$n占位符）
+type sqlFilter struct {
+	clauses []string
+	args    []interface{}
+}
+
+// arg 添加参数并返回其占位符
+func (f *sqlFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return "$" + strconv.Itoa(len(f.args))
+}
+
+// where 拼接WHERE子句（无条件时返回空字符串）
+func (f *sqlFilter) where() string {
+	if len(f.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.clauses, " AND ")
+}
+
+// buildFilters 将记忆表字段的过滤条件转换为SQL，其余字段作为元数据过滤条件返回
+func buildFilters(filters []QueryFilter) (*sqlFilter, []QueryFilter, error) {
+	f := &sqlFilter{}
+	var metaFilters []QueryFilter
+	for _, filter := range filters {
+		field := strings.TrimPrefix(filter.Field, "metadata.")
+		if field != filter.Field || !memoryFilterColumns[field] {
+			metaFilters = append(metaFilters, QueryFilter{Field: field, Operator: filter.Operator, Value: filter.Value})
+			continue
+		}
+		switch filter.Operator {
+		case "eq", "":
+			f.clauses = append(f.clauses, field+" = "+f.arg(filter.Value))
+		case "gt":
+			f.clauses = append(f.clauses, field+" > "+f.arg(filter.Value))
+		case "lt":
+			f.clauses = append(f.clauses, field+" < "+f.arg(filter.Value))
+		case "gte":
+			f.clauses = append(f.clauses, field+" >= "+f.arg(filter.Value))
+		case "lte":
+			f.clauses = append(f.clauses, field+" <= "+f.arg(filter.Value))
+		case "in":
+			values := filterValues(filter.Value)
+			if len(values) == 0 {
+				f.clauses = append(f.clauses, "1 = 0")
+				continue
+			}
+			placeholders := make([]string, len(values))
+			for i, v := range values {
+				placeholders[i] = f.arg(v)
+			}
+			f.clauses = append(f.clauses, field+" IN ("+strings.Join(placeholders, ", ")+")")
+		case "contains":
+			f.clauses = append(f.clauses, field+" LIKE "+f.arg("%"+fmt.Sprint(filter.Value)+"%"))
+		default:
+			return nil, nil, fmt.Errorf("❌ 不支持的过滤操作符: %s", filter.Operator)
+		}
+	}
+	return f, metaFilters, nil
+}
+
+// filterValues 将in操作符的值展开为列表
+func filterValues(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return values
+	case []float64:
+		values := make([]interface{}, len(v))
+		for i, n := range v {
+			values[i] = n
+		}
+		return values
+	default:
+		return []interface{}{value}
+	}
+}
+
+// matchMetadata 记忆是否满足全部元数据过滤条件
+func matchMetadata(m Memory, filters []QueryFilter) bool {
+	for _, filter := range filters {
+		actual, ok := m.Metadata[filter.Field]
+		if !ok {
+			return false
+		}
+		switch filter.Operator {
+		case "eq", "":
+			if fmt.Sprint(actual) != fmt.Sprint(filter.Value) {
+				return false
+			}
+		case "gt", "lt", "gte", "lte":
+			a, errA := strconv.ParseFloat(fmt.Sprint(actual), 64)
+			b, errB := strconv.ParseFloat(fmt.Sprint(filter.Value), 64)
+			if errA != nil || errB != nil {
+				return false
+			}
+			if (filter.Operator == "gt" && a <= b) || (filter.Operator == "lt" && a >= b) ||
+				(filter.Operator == "gte" && a < b) || (filter.Operator == "lte" && a > b) {
+				return false
+			}
+		case "in":
+			found := false
+			for _, v := range filterValues(filter.Value) {
+				if fmt.Sprint(actual) == fmt.Sprint(v) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		case "contains":
+			if !strings.Contains(fmt.Sprint(actual), fmt.Sprint(filter.Value)) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// queryText 从查询上下文提取检索文本（优先query/text/content字段，否则按键排序拼接）
+func queryText(context map[string]interface{}) string {
+	for _, key := range []string{"query", "text", "content"} {
+		if text, ok := context[key].(string); ok && strings.TrimSpace(text) != "" {
+			return text
+		}
+	}
+	keys := make([]string, 0, len(context))
+	for key := range context {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %v", key, context[key]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// vectorLiteral 向量的文本格式（JSON数组与pgvector的输入格式一致）
+func vectorLiteral(vec []float32) string {
+	data, _ := json.Marshal(vec)
+	return string(data)
+}
+
+// Search 搜索记忆：semantic_search按上下文文本的向量相似度排序，其他类型按过滤条件和时间倒序
+func (s *PostgresStore) Search(ctx context.Context, query Query) ([]Memory, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	filter, metaFilters, err := buildFilters(query.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var memories []Memory
+	if text := queryText(query.Context); query.Type == "semantic_search" && text != "" {
+		vectors, err := s.embedder.Embed(ctx, []string{text})
+		if err != nil {
+			return nil, fmt.Errorf("❌ 向量化查询失败: %w", err)
+		}
+		memories, err = s.nearest(ctx, vectors[0], filter, metaFilters, query.Similarity, limit+query.Offset)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		memories, err = s.list(ctx, filter, metaFilters, limit+query.Offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Offset >= len(memories) {
+		return []Memory{}, nil
+	}
+	return memories[query.Offset:], nil
+}
+
+// list 按时间倒序列出满足条件的记忆（有元数据条件时扫描后在Go中过滤）
+func (s *PostgresStore) list(ctx context.Context, filter *sqlFilter, metaFilters []QueryFilter, limit int) ([]Memory, error) {
+	fetch := limit
+	if len(metaFilters) > 0 {
+		fetch = s.opts.MaxScan
+	}
+	q := "SELECT " + memoryColumns + " FROM mem0_memories" + filter.where() +
+		" ORDER BY created_at DESC, id DESC LIMIT " + filter.arg(fetch)
+	rows, err := s.db.QueryContext(ctx, q, filter.args...)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 查询记忆失败: %w", err)
+	}
+	defer rows.Close()
+
+	memories := []Memory{}
+	for rows.Next() {
+		m, _, _, err := scanMemory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("❌ 读取记忆失败: %w", err)
+		}
+		if !matchMetadata(m, metaFilters) {
+			continue
+		}
+		memories = append(memories, m)
+		if len(memories) >= limit {
+			break
+		}
+	}
+	return memories, rows.Err()
+}
+
+// nearest 按与vec的余弦相似度从高到低返回满足条件的记忆（只比较同一向量化方式生成的向量）
+func (s *PostgresStore) nearest(ctx context.Context, vec []float32, filter *sqlFilter, metaFilters []QueryFilter, minSimilarity float64, limit int) ([]Memory, error) {
+	filter.clauses = append(filter.clauses, "embedding_model = "+filter.arg(s.embedder.Name()))
+
+	var q string
+	if s.pgvector {
+		fetch := limit
+		if len(metaFilters) > 0 {
+			fetch = s.opts.MaxScan
+		}
+		param := filter.arg(vectorLiteral(vec))
+		filter.clauses = append(filter.clauses, "embedding_vec IS NOT NULL")
+		q = "SELECT " + memoryColumns + ", 1 - (embedding_vec <=> " + param + "::vector) AS similarity FROM mem0_memories" +
+			filter.where() + " ORDER BY embedding_vec <=> " + param + "::vector LIMIT " + filter.arg(fetch)
+	} else {
+		q = "SELECT " + memoryColumns + " FROM mem0_memories" + filter.where() +
+			" ORDER BY created_at DESC LIMIT " + filter.arg(s.opts.MaxScan)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, filter.args...)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 查询相似记忆失败: %w", err)
+	}
+	defer rows.Close()
+
+	memories := []Memory{}
+	for rows.Next() {
+		var m Memory
+		var stored []float32
+		if s.pgvector {
+			var metadata, embedding, model string
+			var reflectionID sql.NullString
+			err = rows.Scan(&m.ID, &m.Content, &m.Type, &metadata, &m.QualityScore, &m.Status, &reflectionID,
+				&embedding, &model, &m.CreatedAt, &m.UpdatedAt, &m.Similarity)
+			if err == nil {
+				json.Unmarshal([]byte(metadata), &m.Metadata)
+				if reflectionID.Valid {
+					id := reflectionID.String
+					m.ReflectionID = &id
+				}
+			}
+		} else {
+			m, stored, _, err = scanMemory(rows)
+			m.Similarity = cosineSimilarity(vec, stored)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("❌ 读取记忆失败: %w", err)
+		}
+		if m.Similarity < minSimilarity || !matchMetadata(m, metaFilters) {
+			continue
+		}
+		memories = append(memories, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(memories, func(i, j int) bool { return memories[i].Similarity > memories[j].Similarity })
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+// Save 保存记忆（ID为空时自动生成，已存在时覆盖）
+func (s *PostgresStore) Save(ctx context.Context, memory Memory, opts *SaveOptions) (string, error) {
+	ids, err := s.SaveBatch(ctx, []Memory{memory}, opts)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// SaveBatch 批量保存记忆（一次向量化，单个事务写入）
+func (s *PostgresStore) SaveBatch(ctx context.Context, memories []Memory, opts *SaveOptions) ([]string, error) {
+	if len(memories) == 0 {
+		return nil, nil
+	}
+	if opts != nil && opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	contents := make([]string, len(memories))
+	for i, m := range memories {
+		contents[i] = m.Content
+	}
+	vectors, err := s.embedder.Embed(ctx, contents)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 向量化记忆失败: %w", err)
+	}
+
+	// 自动关联：与已有记忆相似度达到阈值时建立similar_to关系
+	links := make([][]Memory, len(memories))
+	if s.opts.AutoLinkThreshold > 0 {
+		for i, m := range memories {
+			filter := &sqlFilter{}
+			if m.ID != "" {
+				filter.clauses = append(filter.clauses, "id <> "+filter.arg(m.ID))
+			}
+			similar, err := s.nearest(ctx, vectors[i], filter, nil, s.opts.AutoLinkThreshold, s.opts.MaxAutoLinks)
+			if err != nil {
+				log.Printf("⚠️ 查询相似记忆失败，跳过自动关联: %v", err)
+				continue
+			}
+			links[i] = similar
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	ids := make([]string, len(memories))
+	for i, m := range memories {
+		if m.ID == "" {
+			m.ID = uuid.New().String()
+		}
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		metadata, err := json.Marshal(m.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("❌ 编码记忆元数据失败: %w", err)
+		}
+		var reflectionID sql.NullString
+		if m.ReflectionID != nil {
+			reflectionID = sql.NullString{String: *m.ReflectionID, Valid: true}
+		}
+		embedding := vectorLiteral(vectors[i])
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO mem0_memories (id, content, type, metadata, quality_score, status, reflection_id, embedding, embedding_model, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (id) DO UPDATE SET
+				content = excluded.content, type = excluded.type, metadata = excluded.metadata,
+				quality_score = excluded.quality_score, status = excluded.status, reflection_id = excluded.reflection_id,
+				embedding = excluded.embedding, embedding_model = excluded.embedding_model, updated_at = excluded.updated_at
+		`, m.ID, m.Content, m.Type, string(metadata), m.QualityScore, m.Status, reflectionID, embedding, s.embedder.Name(), m.CreatedAt.UTC(), now); err != nil {
+			return nil, fmt.Errorf("❌ 保存记忆失败 (%s): %w", m.ID, err)
+		}
+		if s.pgvector {
+			if _, err := tx.ExecContext(ctx, `UPDATE mem0_memories SET embedding_vec = $1::vector WHERE id = $2`, embedding, m.ID); err != nil {
+				return nil, fmt.Errorf("❌ 保存记忆向量失败 (%s): %w", m.ID, err)
+			}
+		}
+
+		// 显式关系以本次保存为准，自动关联的相似关系双向记录
+		if _, err := tx.ExecContext(ctx, `DELETE FROM mem0_relationships WHERE source_id = $1 AND type <> 'similar_to'`, m.ID); err != nil {
+			return nil, fmt.Errorf("❌ 更新记忆关系失败 (%s): %w", m.ID, err)
+		}
+		relationships := append([]Relationship{}, m.Relationships...)
+		for _, similar := range links[i] {
+			relationships = append(relationships, Relationship{Type: "similar_to", Target: similar.ID, Weight: similar.Similarity})
+			if err := upsertRelationship(ctx, tx, similar.ID, Relationship{Type: "similar_to", Target: m.ID, Weight: similar.Similarity}); err != nil {
+				return nil, err
+			}
+		}
+		for _, rel := range relationships {
+			if err := upsertRelationship(ctx, tx, m.ID, rel); err != nil {
+				return nil, err
+			}
+		}
+		ids[i] = m.ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("❌ 提交记忆失败: %w", err)
+	}
+	return ids, nil
+}
+
+// upsertRelationship 写入一条记忆关系（已存在时更新强度）
+func upsertRelationship(ctx context.Context, tx *sql.Tx, sourceID string, rel Relationship) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO mem0_relationships (source_id, target_id, type, weight)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (source_id, target_id, type) DO UPDATE SET weight = excluded.weight
+	`, sourceID, rel.Target, rel.Type, rel.Weight)
+	if err != nil {
+		return fmt.Errorf("❌ 保存记忆关系失败 (%s → %s): %w", sourceID, rel.Target, err)
+	}
+	return nil
+}
+
+// Delete 删除记忆及其关系
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	n, err := s.deleteWhere(ctx, "id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("❌ 记忆不存在: %s", id)
+	}
+	return nil
+}
+
+// deleteWhere 删除满足条件的记忆及指向它们的关系，返回删除的记忆数
+func (s *PostgresStore) deleteWhere(ctx context.Context, where string, args ...interface{}) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("❌ 开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	sub := "SELECT id FROM mem0_memories WHERE " + where
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mem0_relationships WHERE source_id IN ("+sub+") OR target_id IN ("+sub+")", args...); err != nil {
+		return 0, fmt.Errorf("❌ 删除记忆关系失败: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM mem0_memories WHERE "+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("❌ 删除记忆失败: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("❌ 提交删除失败: %w", err)
+	}
+	return n, nil
+}
+
+// GetByID 按ID获取记忆（包含关系）
+func (s *PostgresStore) GetByID(ctx context.Context, id string) (*Memory, error) {
+	m, _, _, err := scanMemory(s.db.QueryRowContext(ctx, "SELECT "+memoryColumns+" FROM mem0_memories WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("❌ 记忆不存在: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ 查询记忆失败: %w", err)
+	}
+	if m.Relationships, err = s.relationships(ctx, id); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// UpdateStatus 更新记忆状态
+func (s *PostgresStore) UpdateStatus(ctx context.Context, id string, status string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE mem0_memories SET status = $1, updated_at = $2 WHERE id = $3`, status, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("❌ 更新记忆状态失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("❌ 记忆不存在: %s", id)
+	}
+	return nil
+}
+
+// GetByIDs 批量获取记忆（不存在的ID跳过，按传入顺序返回）
+func (s *PostgresStore) GetByIDs(ctx context.Context, ids []string) ([]Memory, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	filter, _, err := buildFilters([]QueryFilter{{Field: "id", Operator: "in", Value: values}})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT "+memoryColumns+" FROM mem0_memories"+filter.where(), filter.args...)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 查询记忆失败: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[string]Memory, len(ids))
+	for rows.Next() {
+		m, _, _, err := scanMemory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("❌ 读取记忆失败: %w", err)
+		}
+		byID[m.ID] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	memories := make([]Memory, 0, len(byID))
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			memories = append(memories, m)
+		}
+	}
+	return memories, nil
+}
+
+// SearchByType 按类型获取最近的记忆
+func (s *PostgresStore) SearchByType(ctx context.Context, memType string, limit int) ([]Memory, error) {
+	return s.Search(ctx, Query{
+		Type:    "direct_lookup",
+		Filters: []QueryFilter{{Field: "type", Operator: "eq", Value: memType}},
+		Limit:   limit,
+	})
+}
+
+// GetRelationships 获取记忆关系（按强度从高到低）
+func (s *PostgresStore) GetRelationships(ctx context.Context, id string) ([]Relationship, error) {
+	var exists int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mem0_memories WHERE id = $1`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("❌ 查询记忆失败: %w", err)
+	}
+	if exists == 0 {
+		return nil, fmt.Errorf("❌ 记忆不存在: %s", id)
+	}
+	return s.relationships(ctx, id)
+}
+
+// relationships 查询记忆的出向关系
+func (s *PostgresStore) relationships(ctx context.Context, id string) ([]Relationship, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT type, target_id, weight FROM mem0_relationships
+		WHERE source_id = $1 ORDER BY weight DESC, target_id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 查询记忆关系失败: %w", err)
+	}
+	defer rows.Close()
+
+	relationships := []Relationship{}
+	for rows.Next() {
+		var rel Relationship
+		if err := rows.Scan(&rel.Type, &rel.Target, &rel.Weight); err != nil {
+			return nil, fmt.Errorf("❌ 读取记忆关系失败: %w", err)
+		}
+		relationships = append(relationships, rel)
+	}
+	return relationships, rows.Err()
+}
+
+// SearchSimilar 按向量相似度查找与指定记忆最接近的其他记忆
+func (s *PostgresStore) SearchSimilar(ctx context.Context, id string, limit int) ([]Memory, error) {
+	_, vec, model, err := scanMemory(s.db.QueryRowContext(ctx, "SELECT "+memoryColumns+" FROM mem0_memories WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("❌ 记忆不存在: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ 查询记忆失败: %w", err)
+	}
+	if model != s.embedder.Name() || len(vec) == 0 {
+		return nil, fmt.Errorf("❌ 记忆 %s 的向量由 %s 生成，与当前向量化方式 %s 不一致", id, model, s.embedder.Name())
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	filter := &sqlFilter{}
+	filter.clauses = append(filter.clauses, "id <> "+filter.arg(id))
+	return s.nearest(ctx, vec, filter, nil, 0, limit)
+}
+
+// GetStats 获取统计信息
+func (s *PostgresStore) GetStats(ctx context.Context) (*MemoryStats, error) {
+	stats := &MemoryStats{ByType: make(map[string]int64)}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT type, COUNT(*), COALESCE(SUM(quality_score), 0) FROM mem0_memories GROUP BY type`)
+	if err != nil {
+		return nil, fmt.Errorf("❌ 统计记忆失败: %w", err)
+	}
+	var qualitySum float64
+	for rows.Next() {
+		var memType string
+		var count int64
+		var sum float64
+		if err := rows.Scan(&memType, &count, &sum); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("❌ 读取统计失败: %w", err)
+		}
+		stats.ByType[memType] = count
+		stats.TotalMemories += count
+		qualitySum += sum
+	}
+	rows.Close()
+	if stats.TotalMemories > 0 {
+		stats.AverageQualityScore = qualitySum / float64(stats.TotalMemories)
+	}
+
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mem0_relationships`).Scan(&stats.TotalRelationships); err != nil {
+		return nil, fmt.Errorf("❌ 统计记忆关系失败: %w", err)
+	}
+
+	// 单独查询时间列（聚合函数在部分驱动下会丢失时间类型）
+	for _, q := range []struct {
+		sql  string
+		dest **time.Time
+	}{
+		{`SELECT created_at FROM mem0_memories ORDER BY created_at ASC LIMIT 1`, &stats.OldestMemory},
+		{`SELECT created_at FROM mem0_memories ORDER BY created_at DESC LIMIT 1`, &stats.NewestMemory},
+	} {
+		var t time.Time
+		err := s.db.QueryRowContext(ctx, q.sql).Scan(&t)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("❌ 统计记忆时间失败: %w", err)
+		}
+		*q.dest = &t
+	}
+	var lastUpdate time.Time
+	err = s.db.QueryRowContext(ctx, `SELECT updated_at FROM mem0_memories ORDER BY updated_at DESC LIMIT 1`).Scan(&lastUpdate)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("❌ 统计记忆时间失败: %w", err)
+	}
+	stats.LastUpdateTime = lastUpdate
+	return stats, nil
+}
+
+// DeleteByType 按类型删除记忆
+func (s *PostgresStore) DeleteByType(ctx context.Context, memType string) error {
+	n, err := s.deleteWhere(ctx, "type = $1", memType)
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ 删除 %s 类型记忆: %d条", memType, n)
+	return nil
+}
+
+// DeleteLowQuality 删除质量分低于阈值的记忆
+func (s *PostgresStore) DeleteLowQuality(ctx context.Context, threshold float64) (int64, error) {
+	n, err := s.deleteWhere(ctx, "quality_score < $1", threshold)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("✅ 删除低质量记忆完成: %d条", n)
+	return n, nil
+}
+
+// Health 健康检查
+func (s *PostgresStore) Health(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("❌ 记忆数据库不可用: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭存储（数据库连接由调用方管理，不在此关闭）
+func (s *PostgresStore) Close() error {
+	return nil
+}
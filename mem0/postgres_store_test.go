@@ -0,0 +1,249 @@
+package mem0
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestPostgresStore 使用SQLite内存库创建本地存储（表结构和SQL与PostgreSQL兼容，相似度在Go中计算）
+func newTestPostgresStore(t *testing.T, opts PostgresStoreOptions) *PostgresStore {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("❌ 打开数据库失败: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewPostgresStore(context.Background(), db, NewHashEmbedder(256), opts)
+	if err != nil {
+		t.Fatalf("❌ 创建本地存储失败: %v", err)
+	}
+	return store
+}
+
+func TestHashEmbedderDeterministic(t *testing.T) {
+	e := NewHashEmbedder(128)
+	vecs, err := e.Embed(context.Background(), []string{
+		"BTC long breakout after FOMC",
+		"btc LONG breakout after fomc",
+		"ETH 空头 止损 触发",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("❌ 向量化失败: %v", err)
+	}
+	if len(vecs[0]) != 128 || e.Dimension() != 128 || e.Name() != "hash-128" {
+		t.Fatalf("❌ 维度或名称不正确: %d %s", len(vecs[0]), e.Name())
+	}
+	if sim := cosineSimilarity(vecs[0], vecs[1]); sim < 0.999 {
+		t.Errorf("❌ 大小写不同的相同文本应得到相同向量, 相似度: %.3f", sim)
+	}
+	if sim := cosineSimilarity(vecs[0], vecs[2]); sim > 0.5 {
+		t.Errorf("❌ 无关文本相似度过高: %.3f", sim)
+	}
+	if sim := cosineSimilarity(vecs[0], vecs[3]); sim != 0 {
+		t.Errorf("❌ 空文本应为零向量, 相似度: %.3f", sim)
+	}
+
+	// 中文按单字和双字切分
+	tokens := tokenize("止损BTC")
+	want := []string{"止", "损", "止损", "btc"}
+	if len(tokens) != len(want) {
+		t.Fatalf("❌ 切分结果: %v", tokens)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("❌ 切分结果: %v, 期望: %v", tokens, want)
+		}
+	}
+}
+
+func TestPostgresStoreSaveAndSearch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestPostgresStore(t, PostgresStoreOptions{VectorSearch: VectorSearchGo})
+
+	ids, err := store.SaveBatch(ctx, []Memory{
+		{Content: "BTC breakout long after FOMC rate decision", Type: "decision", QualityScore: 0.9,
+			Metadata: map[string]interface{}{"symbol": "BTCUSDT", "pnl": 120.5}},
+		{Content: "ETH short stopped out during low liquidity", Type: "decision", QualityScore: 0.4,
+			Metadata: map[string]interface{}{"symbol": "ETHUSDT", "pnl": -40}},
+		{Content: "Funding rate spike preceded BTC long squeeze", Type: "pattern", QualityScore: 0.7,
+			Metadata: map[string]interface{}{"symbol": "BTCUSDT"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("❌ 批量保存失败: %v", err)
+	}
+	if len(ids) != 3 || ids[0] == "" {
+		t.Fatalf("❌ 应生成ID: %v", ids)
+	}
+
+	// 语义检索按相似度排序
+	results, err := store.Search(ctx, Query{
+		Type:    "semantic_search",
+		Context: map[string]interface{}{"query": "BTC long breakout FOMC"},
+		Limit:   2,
+	})
+	if err != nil {
+		t.Fatalf("❌ 语义检索失败: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != ids[0] || results[0].Similarity < results[1].Similarity {
+		t.Fatalf("❌ 语义检索排序不正确: %+v", results)
+	}
+	if results[0].Metadata["symbol"] != "BTCUSDT" {
+		t.Errorf("❌ 元数据应完整读回: %+v", results[0].Metadata)
+	}
+
+	// 字段过滤在SQL中执行，元数据过滤在Go中执行
+	results, err = store.Search(ctx, Query{
+		Type:    "semantic_search",
+		Context: map[string]interface{}{"query": "BTC"},
+		Filters: []QueryFilter{
+			{Field: "type", Operator: "eq", Value: "decision"},
+			{Field: "metadata.pnl", Operator: "gt", Value: 0},
+		},
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("❌ 过滤检索失败: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != ids[0] {
+		t.Errorf("❌ 过滤结果不正确: %+v", results)
+	}
+
+	byType, err := store.SearchByType(ctx, "decision", 10)
+	if err != nil || len(byType) != 2 {
+		t.Errorf("❌ 按类型查询: %d条, err=%v", len(byType), err)
+	}
+	if _, err := store.Search(ctx, Query{Filters: []QueryFilter{{Field: "type", Operator: "regex", Value: "x"}}}); err == nil {
+		t.Error("❌ 不支持的操作符应返回错误")
+	}
+
+	// 覆盖保存和状态更新
+	if err := store.UpdateStatus(ctx, ids[1], "evaluated"); err != nil {
+		t.Fatalf("❌ 更新状态失败: %v", err)
+	}
+	got, err := store.GetByID(ctx, ids[1])
+	if err != nil || got.Status != "evaluated" || got.Content == "" {
+		t.Fatalf("❌ 读取记忆: %+v, err=%v", got, err)
+	}
+	if _, err := store.GetByID(ctx, "missing"); err == nil {
+		t.Error("❌ 不存在的记忆应返回错误")
+	}
+
+	memories, err := store.GetByIDs(ctx, []string{ids[2], "missing", ids[0]})
+	if err != nil || len(memories) != 2 || memories[0].ID != ids[2] || memories[1].ID != ids[0] {
+		t.Errorf("❌ 批量读取应按传入顺序跳过缺失ID: %+v, err=%v", memories, err)
+	}
+}
+
+func TestPostgresStoreRelationshipsAndCleanup(t *testing.T) {
+	ctx := context.Background()
+	store := newTestPostgresStore(t, PostgresStoreOptions{VectorSearch: VectorSearchGo, AutoLinkThreshold: 0.6})
+
+	first, err := store.Save(ctx, Memory{ID: "m1", Content: "BTC long breakout after FOMC", Type: "decision", QualityScore: 0.8}, nil)
+	if err != nil {
+		t.Fatalf("❌ 保存失败: %v", err)
+	}
+	if _, err := store.Save(ctx, Memory{ID: "m2", Content: "BTC long breakout after FOMC meeting", Type: "decision", QualityScore: 0.2,
+		Relationships: []Relationship{{Type: "caused_by", Target: "m3", Weight: 0.5}}}, nil); err != nil {
+		t.Fatalf("❌ 保存失败: %v", err)
+	}
+	if _, err := store.Save(ctx, Memory{ID: "m3", Content: "ETH funding negative", Type: "pattern", QualityScore: 0.1}, nil); err != nil {
+		t.Fatalf("❌ 保存失败: %v", err)
+	}
+
+	// 相似记忆自动双向关联
+	rels, err := store.GetRelationships(ctx, first)
+	if err != nil || len(rels) != 1 || rels[0].Type != "similar_to" || rels[0].Target != "m2" {
+		t.Fatalf("❌ m1应自动关联m2: %+v, err=%v", rels, err)
+	}
+	rels, _ = store.GetRelationships(ctx, "m2")
+	if len(rels) != 2 || rels[0].Target != "m1" {
+		t.Errorf("❌ m2应包含自动关联和显式关系: %+v", rels)
+	}
+	if _, err := store.GetRelationships(ctx, "missing"); err == nil {
+		t.Error("❌ 不存在的记忆应返回错误")
+	}
+
+	similar, err := store.SearchSimilar(ctx, "m1", 1)
+	if err != nil || len(similar) != 1 || similar[0].ID != "m2" {
+		t.Fatalf("❌ 相似记忆: %+v, err=%v", similar, err)
+	}
+
+	stats, err := store.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("❌ 统计失败: %v", err)
+	}
+	if stats.TotalMemories != 3 || stats.ByType["decision"] != 2 || stats.TotalRelationships != 3 ||
+		stats.OldestMemory == nil || stats.NewestMemory == nil || stats.LastUpdateTime.IsZero() {
+		t.Errorf("❌ 统计不正确: %+v", stats)
+	}
+	if diff := stats.AverageQualityScore - (0.8+0.2+0.1)/3; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("❌ 平均质量分: %.3f", stats.AverageQualityScore)
+	}
+
+	// 删除低质量记忆同时清理指向它们的关系
+	deleted, err := store.DeleteLowQuality(ctx, 0.3)
+	if err != nil || deleted != 2 {
+		t.Fatalf("❌ 删除低质量记忆: %d, err=%v", deleted, err)
+	}
+	rels, _ = store.GetRelationships(ctx, "m1")
+	if len(rels) != 0 {
+		t.Errorf("❌ 指向已删除记忆的关系应被清理: %+v", rels)
+	}
+	if err := store.DeleteByType(ctx, "decision"); err != nil {
+		t.Fatalf("❌ 按类型删除失败: %v", err)
+	}
+	if err := store.Delete(ctx, "m1"); err == nil {
+		t.Error("❌ 删除不存在的记忆应返回错误")
+	}
+	stats, _ = store.GetStats(ctx)
+	if stats.TotalMemories != 0 || stats.TotalRelationships != 0 || stats.NewestMemory != nil {
+		t.Errorf("❌ 清理后统计应为空: %+v", stats)
+	}
+	if err := store.Health(ctx); err != nil {
+		t.Errorf("❌ 健康检查失败: %v", err)
+	}
+}
+
+func TestLoadConfigPostgresBackend(t *testing.T) {
+	store := &mapStateStore{values: map[string]string{
+		"mem0_enabled":       "true",
+		"mem0_store_backend": "postgres",
+	}}
+	cfg, err := LoadConfig(store)
+	if err != nil {
+		t.Fatalf("❌ 本地存储不需要API凭证: %v", err)
+	}
+	if cfg.EmbeddingProvider != "hash" || cfg.VectorSearch != VectorSearchAuto {
+		t.Errorf("❌ 默认向量化配置: %+v", cfg)
+	}
+
+	store.values["mem0_store_backend"] = "http"
+	if _, err := LoadConfig(store); err == nil {
+		t.Error("❌ 托管服务缺少凭证时应返回错误")
+	}
+
+	store.values["mem0_store_backend"] = "postgres"
+	store.values["mem0_embedding_provider"] = "openai"
+	cfg, _ = LoadConfig(store)
+	if _, err := NewEmbedder(cfg); err == nil {
+		t.Error("❌ openai向量化未配置地址时应返回错误")
+	}
+}
+
+// mapStateStore 基于map的配置存储
+type mapStateStore struct {
+	values map[string]string
+}
+
+func (m *mapStateStore) GetSystemConfig(key string) (string, error) { return m.values[key], nil }
+
+func (m *mapStateStore) SetSystemConfig(key, value string) error {
+	m.values[key] = value
+	return nil
+}
@@ -93,7 +93,7 @@ func RunBacktestP0() {
 		}
 
 		// 6. 持仓跟踪
-		pt.OpenPosition(&trader.Position{
+		pt.OpenPosition(&trader.TrackedPosition{
 			Symbol:       trade.Symbol,
 			OpenPrice:    math.Floor(rand.Float64()*1000) + 10000,
 			OpenTime:     trade.Timestamp,
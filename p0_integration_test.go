@@ -93,7 +93,7 @@ func TestP0IntegrationSuite(t *testing.T) {
 			recommendation.Confidence)
 
 		// 5️⃣ 持仓追踪 (提案5的间接应用)
-		pt.OpenPosition(&trader.Position{
+		pt.OpenPosition(&trader.TrackedPosition{
 			Symbol:       trade.symbol,
 			OpenPrice:    50000 + float64(i*1000),
 			OpenTime:     time.Now(),
@@ -0,0 +1,538 @@
+// Package experiment 在线A/B实验服务
+// 将交易员（或每个决策周期）按流量分配到不同的prompt模板/自定义prompt/模型/记忆变体，
+// 按 trade_records 的真实平仓结果计算各变体的胜率、夏普比、回撤和显著性，任一变体回撤超限时自动停止实验
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/database"
+	"nofx/mem0"
+
+	"github.com/google/uuid"
+)
+
+// 实验状态和分配方式
+const (
+	StatusRunning = "running"
+	StatusStopped = "stopped"
+
+	AssignByTrader = "trader" // 每个交易员固定一个变体（变体之间没有交叉影响）
+	AssignByCycle  = "cycle"  // 每个决策周期重新分配（同一交易员轮流使用各变体，样本积累更快）
+)
+
+// ErrNotFound 实验不存在
+var ErrNotFound = errors.New("实验不存在")
+
+// Store 实验持久化接口（由 *config.Database 实现）
+type Store interface {
+	CreateExperiment(e database.Experiment) error
+	GetExperiment(id string) (*database.Experiment, error)
+	GetExperiments(userID, status string) ([]database.Experiment, error)
+	StopExperiment(id, reason string, stoppedAt time.Time) error
+	SaveExperimentAssignment(a database.ExperimentAssignment) error
+	GetExperimentAssignments(experimentID string) ([]database.ExperimentAssignment, error)
+	GetTradesInPeriod(traderID string, startDate, endDate time.Time) ([]database.TradeRecord, error)
+	GetTraderByID(traderID string) (*config.TraderRecord, error)
+}
+
+// Assignment 本周期使用的实验变体
+type Assignment struct {
+	ExperimentID   string
+	ExperimentName string
+	Variant        database.ExperimentVariant
+}
+
+// VariantResult 单个变体的实验结果
+type VariantResult struct {
+	Variant        string   `json:"variant"`
+	Traders        []string `json:"traders"`          // 分配到该变体的交易员
+	Trades         int      `json:"trades"`           // 归属该变体的平仓笔数（按开仓时间归属）
+	WinRate        float64  `json:"win_rate"`         // 胜率 (0-1)
+	PnL            float64  `json:"pnl"`              // 合计净盈亏 (USDT)
+	AvgPnL         float64  `json:"avg_pnl"`          // 平均每笔净盈亏 (USDT)
+	SharpeRatio    float64  `json:"sharpe_ratio"`     // 每笔收益的夏普比
+	MaxDrawdownPct float64  `json:"max_drawdown_pct"` // 最大回撤（占资金的百分比）
+	Capital        float64  `json:"capital"`          // 资金基数（交易员初始资金之和）
+}
+
+// Comparison 对照组与某个变体的显著性检验
+type Comparison struct {
+	Control        string  `json:"control"`
+	Variant        string  `json:"variant"`
+	MeanDifference float64 `json:"mean_difference"` // 变体减对照组的平均每笔收益（资金百分比，无资金基数时为USDT）
+	TStatistic     float64 `json:"t_statistic"`
+	PValue         float64 `json:"p_value"`
+	Significant    bool    `json:"significant"`
+	Winner         string  `json:"winner,omitempty"` // 显著时收益更高的一方
+}
+
+// Results 实验结果
+type Results struct {
+	Experiment  database.Experiment `json:"experiment"`
+	Variants    []VariantResult     `json:"variants"`
+	Comparisons []Comparison        `json:"comparisons"`
+	ReturnUnit  string              `json:"return_unit"` // pct: 按资金百分比比较, usdt: 缺少初始资金时按USDT比较
+	AutoStopped bool                `json:"auto_stopped"`
+	EvaluatedAt time.Time           `json:"evaluated_at"`
+}
+
+// Service 在线A/B实验服务
+type Service struct {
+	store         Store
+	selectVariant func(trafficSplit map[string]float64) string
+	now           func() time.Time
+	evalInterval  time.Duration
+
+	mu       sync.Mutex
+	lastEval map[string]time.Time // 实验ID -> 上次检查回撤的时间
+}
+
+// NewService 创建实验服务
+func NewService(store Store) *Service {
+	return &Service{
+		store:         store,
+		selectVariant: mem0.SelectVariant,
+		now:           time.Now,
+		evalInterval:  10 * time.Minute,
+		lastEval:      make(map[string]time.Time),
+	}
+}
+
+// Create 校验并创建实验（变体流量归一化，第一个变体为对照组，一个交易员同时只能参与一个实验）
+func (s *Service) Create(e database.Experiment) (*database.Experiment, error) {
+	e.Name = strings.TrimSpace(e.Name)
+	if e.Name == "" {
+		return nil, fmt.Errorf("实验名称不能为空")
+	}
+	switch e.Assignment {
+	case "":
+		e.Assignment = AssignByTrader
+	case AssignByTrader, AssignByCycle:
+	default:
+		return nil, fmt.Errorf("不支持的分配方式: %s (可选: trader, cycle)", e.Assignment)
+	}
+	if e.MaxDrawdownPct < 0 || e.MaxDrawdownPct >= 100 {
+		return nil, fmt.Errorf("回撤阈值必须在0-100之间")
+	}
+
+	if len(e.Variants) < 2 {
+		return nil, fmt.Errorf("至少需要2个变体")
+	}
+	names := make(map[string]bool)
+	total := 0.0
+	for i := range e.Variants {
+		v := &e.Variants[i]
+		v.Name = strings.TrimSpace(v.Name)
+		if v.Name == "" {
+			return nil, fmt.Errorf("第%d个变体缺少名称", i+1)
+		}
+		if names[v.Name] {
+			return nil, fmt.Errorf("变体名称重复: %s", v.Name)
+		}
+		names[v.Name] = true
+		if v.Weight == 0 {
+			v.Weight = 1
+		}
+		if v.Weight < 0 {
+			return nil, fmt.Errorf("变体 %s 的流量占比不能为负数", v.Name)
+		}
+		total += v.Weight
+	}
+	for i := range e.Variants {
+		e.Variants[i].Weight /= total
+	}
+
+	traders := make([]string, 0, len(e.TraderIDs))
+	seen := make(map[string]bool)
+	for _, id := range e.TraderIDs {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			traders = append(traders, id)
+		}
+	}
+	if len(traders) == 0 {
+		return nil, fmt.Errorf("至少需要1个交易员")
+	}
+	if e.Assignment == AssignByTrader && len(traders) < 2 {
+		return nil, fmt.Errorf("按交易员分配至少需要2个交易员（单个交易员请使用cycle分配）")
+	}
+	e.TraderIDs = traders
+
+	// 回撤按资金百分比计算，交易员缺少初始资金时无法判断是否超限
+	if e.MaxDrawdownPct > 0 {
+		for _, id := range traders {
+			t, err := s.store.GetTraderByID(id)
+			if err != nil || t == nil || t.InitialBalance <= 0 {
+				return nil, fmt.Errorf("交易员 %s 没有初始资金，无法按回撤自动停止实验（请设置初始资金或将回撤阈值设为0）", id)
+			}
+		}
+	}
+
+	running, err := s.store.GetExperiments("", StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range running {
+		for _, id := range other.TraderIDs {
+			if seen[id] {
+				return nil, fmt.Errorf("交易员 %s 已参与运行中的实验: %s", id, other.Name)
+			}
+		}
+	}
+
+	e.ID = uuid.New().String()
+	e.Status = StatusRunning
+	e.StopReason = ""
+	e.StoppedAt = nil
+	e.CreatedAt = s.now()
+	if err := s.store.CreateExperiment(e); err != nil {
+		return nil, err
+	}
+	log.Printf("🧪 创建实验 %s (%s分配, %d个变体, %d个交易员)", e.Name, e.Assignment, len(e.Variants), len(e.TraderIDs))
+	return &e, nil
+}
+
+// Get 获取实验
+func (s *Service) Get(id string) (*database.Experiment, error) {
+	e, err := s.store.GetExperiment(id)
+	if err != nil || e == nil {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+// List 获取实验列表（status为空时返回全部）
+func (s *Service) List(userID, status string) ([]database.Experiment, error) {
+	return s.store.GetExperiments(userID, status)
+}
+
+// Stop 手动停止实验（交易员下个周期恢复自身配置）
+func (s *Service) Stop(id, reason string) error {
+	e, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if e.Status != StatusRunning {
+		return fmt.Errorf("实验已停止")
+	}
+	if reason == "" {
+		reason = "手动停止"
+	}
+	if err := s.store.StopExperiment(id, reason, s.now()); err != nil {
+		return err
+	}
+	log.Printf("⏹ 实验 %s 已停止: %s", e.Name, reason)
+	return nil
+}
+
+// Assign 为交易员的本周期分配变体（未参与运行中的实验时返回nil）
+// trader模式首次分配后固定不变；cycle模式每个周期按流量重新分配。分配记录用于把平仓结果归属到变体
+func (s *Service) Assign(traderID string, cycle int) (*Assignment, error) {
+	running, err := s.store.GetExperiments("", StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	var exp *database.Experiment
+	for i := range running {
+		for _, id := range running[i].TraderIDs {
+			if id == traderID {
+				exp = &running[i]
+				break
+			}
+		}
+		if exp != nil {
+			break
+		}
+	}
+	if exp == nil {
+		return nil, nil
+	}
+
+	// 定期检查回撤，超限时自动停止，本周期即恢复交易员自身配置
+	if s.shouldEvaluate(exp.ID) {
+		results, err := s.evaluate(exp)
+		if err != nil {
+			log.Printf("⚠️ 实验 %s 结果计算失败: %v", exp.Name, err)
+		} else if results.AutoStopped {
+			return nil, nil
+		}
+	}
+
+	variant := ""
+	if exp.Assignment == AssignByTrader {
+		assignments, err := s.store.GetExperimentAssignments(exp.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range assignments {
+			if a.TraderID == traderID {
+				variant = a.Variant
+				break
+			}
+		}
+	}
+	if variant == "" {
+		if exp.Assignment == AssignByTrader {
+			variant = traderVariant(exp, traderID)
+		} else {
+			split := make(map[string]float64, len(exp.Variants))
+			for _, v := range exp.Variants {
+				split[v.Name] = v.Weight
+			}
+			variant = s.selectVariant(split)
+		}
+		if err := s.store.SaveExperimentAssignment(database.ExperimentAssignment{
+			ExperimentID: exp.ID,
+			TraderID:     traderID,
+			Variant:      variant,
+			Cycle:        cycle,
+			CreatedAt:    s.now(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range exp.Variants {
+		if v.Name == variant {
+			return &Assignment{ExperimentID: exp.ID, ExperimentName: exp.Name, Variant: v}, nil
+		}
+	}
+	return nil, fmt.Errorf("实验 %s 不存在变体 %s", exp.Name, variant)
+}
+
+// Results 计算实验结果（运行中的实验同时检查回撤，超限时自动停止）
+func (s *Service) Results(id string) (*Results, error) {
+	exp, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluate(exp)
+}
+
+// shouldEvaluate 距上次检查超过evalInterval时返回true
+func (s *Service) shouldEvaluate(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastEval[id]) < s.evalInterval {
+		return false
+	}
+	s.lastEval[id] = now
+	return true
+}
+
+// evaluate 按开仓时间把平仓记录归属到变体，计算各变体指标和对照组显著性检验
+func (s *Service) evaluate(exp *database.Experiment) (*Results, error) {
+	assignments, err := s.store.GetExperimentAssignments(exp.ID)
+	if err != nil {
+		return nil, err
+	}
+	end := s.now()
+	if exp.StoppedAt != nil {
+		end = *exp.StoppedAt
+	}
+
+	// 资金基数：trader模式为分配到该变体的交易员初始资金之和，cycle模式各变体共用所有交易员的资金
+	capitalByTrader := make(map[string]float64)
+	for _, id := range exp.TraderIDs {
+		if t, err := s.store.GetTraderByID(id); err == nil && t != nil {
+			capitalByTrader[id] = t.InitialBalance
+		}
+	}
+	tradersByVariant := make(map[string][]string)
+	capital := make(map[string]float64)
+	counted := make(map[string]bool)
+	for _, a := range assignments {
+		key := a.Variant + "/" + a.TraderID
+		if counted[key] {
+			continue
+		}
+		counted[key] = true
+		tradersByVariant[a.Variant] = append(tradersByVariant[a.Variant], a.TraderID)
+		if exp.Assignment == AssignByTrader {
+			capital[a.Variant] += capitalByTrader[a.TraderID]
+		}
+	}
+	if exp.Assignment == AssignByCycle {
+		for _, v := range exp.Variants {
+			for _, id := range tradersByVariant[v.Name] {
+				capital[v.Name] += capitalByTrader[id]
+			}
+		}
+	}
+
+	// 平仓记录按开仓时间归属到当时生效的分配（实验开始前开的仓不计入）
+	type attributed struct {
+		variant string
+		trade   database.TradeRecord
+	}
+	var trades []attributed
+	for _, traderID := range exp.TraderIDs {
+		records, err := s.store.GetTradesInPeriod(traderID, exp.CreatedAt, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range records {
+			openTime := t.CreatedAt.Add(-time.Duration(t.HoldingTimeSeconds) * time.Second)
+			if openTime.Before(exp.CreatedAt) {
+				continue
+			}
+			if variant := variantAt(assignments, traderID, openTime); variant != "" {
+				trades = append(trades, attributed{variant: variant, trade: t})
+			}
+		}
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].trade.CreatedAt.Before(trades[j].trade.CreatedAt) })
+
+	// 所有变体都有资金基数时按资金百分比比较（变体之间资金规模不同也可比），否则按USDT比较
+	pct := true
+	for _, v := range exp.Variants {
+		if len(tradersByVariant[v.Name]) > 0 && capital[v.Name] <= 0 {
+			pct = false
+		}
+	}
+	returnOf := func(a attributed) float64 {
+		if pct && capital[a.variant] > 0 {
+			return a.trade.NetPnL() / capital[a.variant] * 100
+		}
+		return a.trade.NetPnL()
+	}
+
+	control := exp.Variants[0].Name
+	newFramework := func(variants ...database.ExperimentVariant) *mem0.ABTestFramework {
+		split := make(map[string]float64, len(variants))
+		for _, v := range variants {
+			split[v.Name] = v.Weight
+		}
+		cfg := mem0.ABTestConfig{Name: exp.Name, TrafficSplit: split, Control: control, SignificanceLevel: 0.05}
+		if pct {
+			cfg.InitialCapital = 100
+		}
+		ab := mem0.NewABTestFramework(exp.ID, cfg)
+		ab.InitializeVariants()
+		for _, a := range trades {
+			if _, ok := split[a.variant]; ok {
+				ab.RecordTrade(mem0.TradeRecord{
+					TradeID:    fmt.Sprintf("%d", a.trade.ID),
+					Variant:    a.variant,
+					Timestamp:  a.trade.CreatedAt,
+					EntryPrice: a.trade.EntryPrice,
+					ExitPrice:  a.trade.ExitPrice,
+					PnL:        returnOf(a),
+				})
+			}
+		}
+		ab.CalculateMetrics()
+		return ab
+	}
+
+	results := &Results{Experiment: *exp, ReturnUnit: "usdt", EvaluatedAt: s.now()}
+	if pct {
+		results.ReturnUnit = "pct"
+	}
+	metrics := newFramework(exp.Variants...).Results()
+	pnl := make(map[string]float64)
+	for _, a := range trades {
+		pnl[a.variant] += a.trade.NetPnL()
+	}
+	for _, v := range exp.Variants {
+		m := metrics[v.Name]
+		r := VariantResult{
+			Variant:     v.Name,
+			Traders:     tradersByVariant[v.Name],
+			Trades:      int(m.SampleCount),
+			WinRate:     m.WinRate,
+			PnL:         pnl[v.Name],
+			SharpeRatio: m.SharpeRatio,
+			Capital:     capital[v.Name],
+		}
+		if r.Trades > 0 {
+			r.AvgPnL = r.PnL / float64(r.Trades)
+		}
+		if pct {
+			r.MaxDrawdownPct = m.MaxDrawdown * 100
+		}
+		results.Variants = append(results.Variants, r)
+	}
+
+	for _, v := range exp.Variants[1:] {
+		test := newFramework(exp.Variants[0], v).PerformStatisticalTest()
+		c := Comparison{Control: control, Variant: v.Name, PValue: 1}
+		c.MeanDifference, _ = test["mean_difference"].(float64)
+		c.TStatistic, _ = test["t_statistic"].(float64)
+		if p, ok := test["p_value"].(float64); ok {
+			c.PValue = p
+		}
+		c.Significant, _ = test["is_significant"].(bool)
+		if c.Significant {
+			c.Winner = v.Name
+			if c.MeanDifference < 0 {
+				c.Winner = control
+			}
+		}
+		results.Comparisons = append(results.Comparisons, c)
+	}
+
+	if exp.Status == StatusRunning && exp.MaxDrawdownPct > 0 && pct {
+		for _, r := range results.Variants {
+			if r.MaxDrawdownPct < exp.MaxDrawdownPct {
+				continue
+			}
+			reason := fmt.Sprintf("变体 %s 回撤 %.2f%% 超过阈值 %.2f%%", r.Variant, r.MaxDrawdownPct, exp.MaxDrawdownPct)
+			now := s.now()
+			if err := s.store.StopExperiment(exp.ID, reason, now); err != nil {
+				return nil, err
+			}
+			log.Printf("🛑 实验 %s 自动停止: %s", exp.Name, reason)
+			results.Experiment.Status = StatusStopped
+			results.Experiment.StopReason = reason
+			results.Experiment.StoppedAt = &now
+			results.AutoStopped = true
+			break
+		}
+	}
+	return results, nil
+}
+
+// traderVariant trader模式下按交易员在实验中的顺序确定性地分配变体
+// 依次把每个交易员分给"应得人数 - 已分配人数"最大的变体，各变体的交易员人数按流量占比平衡
+func traderVariant(exp *database.Experiment, traderID string) string {
+	counts := make([]float64, len(exp.Variants))
+	for i, id := range exp.TraderIDs {
+		best := 0
+		for j, v := range exp.Variants {
+			if v.Weight*float64(i+1)-counts[j] > exp.Variants[best].Weight*float64(i+1)-counts[best] {
+				best = j
+			}
+		}
+		if id == traderID {
+			return exp.Variants[best].Name
+		}
+		counts[best]++
+	}
+	return ""
+}
+
+// variantAt 交易员在指定时间生效的变体（该时间之前最近的一次分配）
+func variantAt(assignments []database.ExperimentAssignment, traderID string, at time.Time) string {
+	variant := ""
+	for _, a := range assignments {
+		if a.TraderID != traderID {
+			continue
+		}
+		if a.CreatedAt.After(at) {
+			break
+		}
+		variant = a.Variant
+	}
+	return variant
+}
@@ -0,0 +1,335 @@
+package experiment
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/config"
+	"nofx/database"
+)
+
+// memoryStore 内存中的实验存储
+type memoryStore struct {
+	experiments map[string]*database.Experiment
+	assignments []database.ExperimentAssignment
+	trades      map[string][]database.TradeRecord
+	balances    map[string]float64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		experiments: make(map[string]*database.Experiment),
+		trades:      make(map[string][]database.TradeRecord),
+		balances:    make(map[string]float64),
+	}
+}
+
+func (m *memoryStore) CreateExperiment(e database.Experiment) error {
+	m.experiments[e.ID] = &e
+	return nil
+}
+
+func (m *memoryStore) GetExperiment(id string) (*database.Experiment, error) {
+	e, ok := m.experiments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *e
+	return &copied, nil
+}
+
+func (m *memoryStore) GetExperiments(userID, status string) ([]database.Experiment, error) {
+	var list []database.Experiment
+	for _, e := range m.experiments {
+		if (userID == "" || e.UserID == userID) && (status == "" || e.Status == status) {
+			list = append(list, *e)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryStore) StopExperiment(id, reason string, stoppedAt time.Time) error {
+	if e, ok := m.experiments[id]; ok && e.Status == StatusRunning {
+		e.Status, e.StopReason, e.StoppedAt = StatusStopped, reason, &stoppedAt
+	}
+	return nil
+}
+
+func (m *memoryStore) SaveExperimentAssignment(a database.ExperimentAssignment) error {
+	m.assignments = append(m.assignments, a)
+	return nil
+}
+
+func (m *memoryStore) GetExperimentAssignments(experimentID string) ([]database.ExperimentAssignment, error) {
+	var list []database.ExperimentAssignment
+	for _, a := range m.assignments {
+		if a.ExperimentID == experimentID {
+			list = append(list, a)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryStore) GetTradesInPeriod(traderID string, start, end time.Time) ([]database.TradeRecord, error) {
+	var list []database.TradeRecord
+	for _, t := range m.trades[traderID] {
+		if !t.CreatedAt.Before(start) && !t.CreatedAt.After(end) {
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryStore) GetTraderByID(traderID string) (*config.TraderRecord, error) {
+	return &config.TraderRecord{ID: traderID, InitialBalance: m.balances[traderID]}, nil
+}
+
+// newTestService 创建使用固定时钟的实验服务（selectVariant按调用顺序返回给定变体）
+func newTestService(store *memoryStore, now *time.Time, picks ...string) *Service {
+	s := NewService(store)
+	s.now = func() time.Time { return *now }
+	i := 0
+	s.selectVariant = func(map[string]float64) string {
+		v := picks[i%len(picks)]
+		i++
+		return v
+	}
+	return s
+}
+
+// addTrade 添加一笔在openAt开仓、持仓1小时的平仓记录
+func (m *memoryStore) addTrade(traderID string, openAt time.Time, pnl float64) {
+	m.trades[traderID] = append(m.trades[traderID], database.TradeRecord{
+		ID:                 int64(len(m.trades[traderID]) + 1),
+		TraderID:           traderID,
+		RealizedPnL:        pnl,
+		HoldingTimeSeconds: 3600,
+		CreatedAt:          openAt.Add(time.Hour),
+	})
+}
+
+func TestCreateValidatesExperiment(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)
+	s := newTestService(store, &now, "a")
+
+	valid := database.Experiment{
+		UserID:    "u1",
+		Name:      "memory",
+		TraderIDs: []string{"t1", "t2", "t1"},
+		Variants:  []database.ExperimentVariant{{Name: "control", Weight: 3}, {Name: "memory", Weight: 1}},
+	}
+	exp, err := s.Create(valid)
+	if err != nil {
+		t.Fatalf("创建实验失败: %v", err)
+	}
+	if exp.Status != StatusRunning || exp.Assignment != AssignByTrader || len(exp.TraderIDs) != 2 {
+		t.Errorf("实验默认值不正确: %+v", exp)
+	}
+	if exp.Variants[0].Weight != 0.75 || exp.Variants[1].Weight != 0.25 {
+		t.Errorf("流量占比应归一化: %+v", exp.Variants)
+	}
+
+	cases := map[string]func(e *database.Experiment){
+		"单个变体":     func(e *database.Experiment) { e.Variants = e.Variants[:1] },
+		"变体重名":     func(e *database.Experiment) { e.Variants[1].Name = "control" },
+		"负流量":      func(e *database.Experiment) { e.Variants[1].Weight = -1 },
+		"未知分配方式":   func(e *database.Experiment) { e.Assignment = "random" },
+		"按交易员分配不足": func(e *database.Experiment) { e.TraderIDs = []string{"t3"} },
+		"交易员已在实验中": func(e *database.Experiment) { e.TraderIDs = []string{"t2", "t3"} },
+		"缺少初始资金":   func(e *database.Experiment) { e.MaxDrawdownPct = 10 },
+	}
+	for name, mutate := range cases {
+		e := valid
+		e.Variants = append([]database.ExperimentVariant(nil), valid.Variants...)
+		e.TraderIDs = []string{"t3", "t4"}
+		mutate(&e)
+		if _, err := s.Create(e); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+}
+
+func TestAssignByTraderIsSticky(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)
+	s := newTestService(store, &now, "control", "memory")
+
+	off := false
+	exp, err := s.Create(database.Experiment{
+		Name:      "memory",
+		TraderIDs: []string{"t1", "t2"},
+		Variants:  []database.ExperimentVariant{{Name: "control", Memory: &off}, {Name: "memory", PromptTemplate: "adaptive"}},
+	})
+	if err != nil {
+		t.Fatalf("创建实验失败: %v", err)
+	}
+
+	a1, _ := s.Assign("t1", 1)
+	a2, _ := s.Assign("t2", 1)
+	if a1 == nil || a2 == nil || a1.Variant.Name != "control" || a2.Variant.Name != "memory" || a2.Variant.PromptTemplate != "adaptive" {
+		t.Fatalf("分配结果不正确: %+v %+v", a1, a2)
+	}
+	for cycle := 2; cycle < 5; cycle++ {
+		if a, _ := s.Assign("t1", cycle); a == nil || a.Variant.Name != "control" {
+			t.Fatalf("trader模式下变体应保持不变: %+v", a)
+		}
+	}
+	if len(store.assignments) != 2 {
+		t.Errorf("trader模式每个交易员只记录一次分配: %d", len(store.assignments))
+	}
+	if a, err := s.Assign("t9", 1); a != nil || err != nil {
+		t.Errorf("未参与实验的交易员应返回nil: %+v, %v", a, err)
+	}
+
+	if err := s.Stop(exp.ID, ""); err != nil {
+		t.Fatalf("停止实验失败: %v", err)
+	}
+	if a, _ := s.Assign("t1", 6); a != nil {
+		t.Errorf("实验停止后不应再分配: %+v", a)
+	}
+	if err := s.Stop(exp.ID, ""); err == nil {
+		t.Error("重复停止应返回错误")
+	}
+	if _, err := s.Get("missing"); err != ErrNotFound {
+		t.Errorf("不存在的实验应返回ErrNotFound: %v", err)
+	}
+}
+
+func TestAssignByTraderIsBalancedByWeight(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)
+	// 随机选择始终返回memory，trader模式不应使用随机分配
+	s := newTestService(store, &now, "memory")
+
+	if _, err := s.Create(database.Experiment{
+		Name:      "weighted",
+		TraderIDs: []string{"t1", "t2", "t3", "t4"},
+		Variants:  []database.ExperimentVariant{{Name: "control", Weight: 3}, {Name: "memory", Weight: 1}},
+	}); err != nil {
+		t.Fatalf("创建实验失败: %v", err)
+	}
+
+	// 分配只取决于交易员在实验中的顺序，与调用顺序无关
+	want := map[string]string{"t4": "control", "t3": "memory", "t2": "control", "t1": "control"}
+	for _, id := range []string{"t4", "t3", "t2", "t1"} {
+		a, err := s.Assign(id, 1)
+		if err != nil || a == nil || a.Variant.Name != want[id] {
+			t.Errorf("%s 分配结果不正确: %+v, %v", id, a, err)
+		}
+	}
+}
+
+func TestResultsAttributeTradesByCycle(t *testing.T) {
+	store := newMemoryStore()
+	store.balances["t1"] = 1000
+	start := time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)
+	now := start
+	s := newTestService(store, &now, "control", "memory")
+
+	exp, err := s.Create(database.Experiment{
+		Name:       "cycle",
+		Assignment: AssignByCycle,
+		TraderIDs:  []string{"t1"},
+		Variants:   []database.ExperimentVariant{{Name: "control"}, {Name: "memory"}},
+	})
+	if err != nil {
+		t.Fatalf("创建实验失败: %v", err)
+	}
+
+	// 实验开始前开的仓不计入
+	store.addTrade("t1", start.Add(-30*time.Minute), 500)
+
+	// 每个周期交替分配，周期内开的仓归属该周期的变体
+	for i := 0; i < 20; i++ {
+		now = start.Add(time.Duration(i+1) * time.Hour)
+		a, err := s.Assign("t1", i+1)
+		if err != nil || a == nil {
+			t.Fatalf("分配失败: %+v, %v", a, err)
+		}
+		pnl := -5.0 + float64(i%4)
+		if a.Variant.Name == "memory" {
+			pnl = 10.0 + float64(i%4)
+		}
+		store.addTrade("t1", now.Add(time.Minute), pnl)
+	}
+	now = start.Add(48 * time.Hour)
+
+	results, err := s.Results(exp.ID)
+	if err != nil {
+		t.Fatalf("计算结果失败: %v", err)
+	}
+	if results.ReturnUnit != "pct" || len(results.Variants) != 2 {
+		t.Fatalf("结果不正确: %+v", results)
+	}
+	control, memory := results.Variants[0], results.Variants[1]
+	if control.Trades != 10 || memory.Trades != 10 || control.Capital != 1000 {
+		t.Errorf("平仓应按周期归属到变体: %+v %+v", control, memory)
+	}
+	if control.WinRate != 0 || memory.WinRate != 1 || control.PnL >= 0 || memory.PnL <= 0 {
+		t.Errorf("胜率或盈亏不正确: %+v %+v", control, memory)
+	}
+	if control.MaxDrawdownPct <= 0 || memory.MaxDrawdownPct != 0 {
+		t.Errorf("回撤不正确: %+v %+v", control, memory)
+	}
+	c := results.Comparisons[0]
+	if c.Control != "control" || c.Variant != "memory" || !c.Significant || c.Winner != "memory" || c.MeanDifference <= 0 || c.PValue >= 0.05 {
+		t.Errorf("显著性检验不正确: %+v", c)
+	}
+	if results.AutoStopped {
+		t.Error("未设置回撤阈值时不应自动停止")
+	}
+}
+
+func TestAutoStopOnDrawdown(t *testing.T) {
+	store := newMemoryStore()
+	store.balances["t1"], store.balances["t2"] = 1000, 1000
+	start := time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)
+	now := start
+	s := newTestService(store, &now, "control", "aggressive")
+
+	exp, err := s.Create(database.Experiment{
+		Name:           "drawdown",
+		TraderIDs:      []string{"t1", "t2"},
+		Variants:       []database.ExperimentVariant{{Name: "control"}, {Name: "aggressive", CustomPrompt: "满仓梭哈"}},
+		MaxDrawdownPct: 10,
+	})
+	if err != nil {
+		t.Fatalf("创建实验失败: %v", err)
+	}
+	s.Assign("t1", 1)
+	s.Assign("t2", 1)
+
+	store.addTrade("t1", start.Add(time.Minute), 20)
+	store.addTrade("t2", start.Add(time.Minute), 50)
+	store.addTrade("t2", start.Add(2*time.Hour), -80)
+	store.addTrade("t2", start.Add(4*time.Hour), -60)
+
+	// 交易员下次分配时检查回撤（间隔内不重复计算）
+	now = start.Add(6 * time.Hour)
+	if a, _ := s.Assign("t1", 2); a != nil {
+		t.Fatalf("回撤超限后应停止实验并恢复自身配置: %+v", a)
+	}
+	stopped, _ := s.Get(exp.ID)
+	if stopped.Status != StatusStopped || !strings.Contains(stopped.StopReason, "aggressive") {
+		t.Fatalf("实验应自动停止: %+v", stopped)
+	}
+
+	// 停止后的结果只统计停止时间之前的交易
+	store.addTrade("t1", now.Add(time.Hour), 1000)
+	now = now.Add(3 * time.Hour)
+	results, err := s.Results(exp.ID)
+	if err != nil {
+		t.Fatalf("计算结果失败: %v", err)
+	}
+	if results.Variants[0].Trades != 1 || results.AutoStopped {
+		t.Errorf("停止后的交易不应计入: %+v", results.Variants[0])
+	}
+	// 1050 → 910，回撤约13.3%
+	if dd := results.Variants[1].MaxDrawdownPct; fmt.Sprintf("%.1f", dd) != "13.3" {
+		t.Errorf("回撤计算不正确: %.2f", dd)
+	}
+}
@@ -0,0 +1,48 @@
+package news
+
+import "sync"
+
+// broadcaster 新文章广播（交易员的新闻触发器通过它订阅实时新闻）
+type broadcaster struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan Article
+	nextID      int
+}
+
+var articleBroadcaster = &broadcaster{subscribers: make(map[int]chan Article)}
+
+// Subscribe 订阅新抓取到的文章，返回文章通道和取消订阅的函数
+// 订阅方处理不及时时丢弃新文章，不阻塞新闻服务
+func Subscribe(buffer int) (<-chan Article, func()) {
+	b := articleBroadcaster
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan Article, buffer)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+}
+
+// Publish 向所有订阅方广播文章
+func Publish(a Article) {
+	b := articleBroadcaster
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- a:
+		default:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package news
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"nofx/database"
+)
+
+// maxSourceBodyBytes 单次抓取的最大响应大小
+const maxSourceBodyBytes = 5 << 20
+
+// newSourceHTTPClient 创建新闻源HTTP客户端
+// 用户添加的新闻源禁止访问内网、回环和链路本地地址（连接时按解析后的IP检查，防止DNS重绑定；不走环境代理）
+func newSourceHTTPClient(src database.NewsSource) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, DialContext: dialer.DialContext}
+	if src.UserID != "" {
+		transport.Proxy = nil
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("新闻源不允许访问内网地址: %s", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{Timeout: 15 * time.Second, Transport: transport}
+}
+
+// isPrivateIP 是否为内网/回环/链路本地/未指定地址
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsInterfaceLocalMulticast()
+}
+
+// fetchSourceBody 请求新闻源地址（带自定义请求头，限制响应大小）
+func fetchSourceBody(client *http.Client, src database.NewsSource, accept string) ([]byte, error) {
+	req, err := http.NewRequest("GET", src.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "nofx-news/1.0")
+	for k, v := range src.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求新闻源 %s 失败: %w", src.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("新闻源 %s 返回状态码: %d", src.Name, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("读取新闻源 %s 响应失败: %w", src.Name, err)
+	}
+	return body, nil
+}
+
+// articleTimeLayouts 新闻源常见的时间格式
+var articleTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseArticleTime 解析发布时间（Unix秒/毫秒或常见时间字符串，无法解析时返回0）
+func parseArticleTime(v interface{}) int64 {
+	switch t := v.(type) {
+	case float64:
+		return unixSeconds(int64(t))
+	case string:
+		s := strings.TrimSpace(t)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return unixSeconds(n)
+		}
+		for _, layout := range articleTimeLayouts {
+			if parsed, err := time.Parse(layout, s); err == nil {
+				return parsed.Unix()
+			}
+		}
+	}
+	return 0
+}
+
+// unixSeconds 毫秒时间戳转换为秒
+func unixSeconds(n int64) int64 {
+	if n > 1e12 {
+		return n / 1000
+	}
+	return n
+}
@@ -0,0 +1,122 @@
+package news
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nofx/database"
+)
+
+// JSONFetcher 通用JSON接口新闻源（按字段映射把任意JSON响应转换为文章）
+type JSONFetcher struct {
+	src    database.NewsSource
+	client *http.Client
+}
+
+// NewJSONFetcher 创建JSON接口新闻源
+func NewJSONFetcher(src database.NewsSource) (Fetcher, error) {
+	if err := validateSourceConfig(src); err != nil {
+		return nil, err
+	}
+	return &JSONFetcher{src: src, client: newSourceHTTPClient(src)}, nil
+}
+
+// Name 返回新闻源名称
+func (f *JSONFetcher) Name() string {
+	return f.src.Name
+}
+
+// FetchNews 请求接口并按字段映射解析（category参数忽略，使用新闻源配置的分类）
+func (f *JSONFetcher) FetchNews(category string) ([]Article, error) {
+	body, err := fetchSourceBody(f.client, f.src, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	articles, err := ParseMappedJSON(body, f.src.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("解析新闻源 %s 失败: %w", f.src.Name, err)
+	}
+	return finalizeArticles(articles, f.src), nil
+}
+
+// ParseMappedJSON 按字段映射解析JSON文档（Items路径指向数组或单个对象）
+func ParseMappedJSON(body []byte, mapping database.NewsFieldMapping) ([]Article, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("JSON格式错误: %w", err)
+	}
+
+	node, ok := lookupPath(doc, mapping.Items)
+	if !ok {
+		return nil, fmt.Errorf("找不到文章列表: %s", mapping.Items)
+	}
+	var items []interface{}
+	switch v := node.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		items = []interface{}{v}
+	default:
+		return nil, fmt.Errorf("文章列表 %s 不是数组或对象", mapping.Items)
+	}
+
+	headlinePath := orDefault(mapping.Headline, "title")
+	summaryPath := orDefault(mapping.Summary, "summary")
+	urlPath := orDefault(mapping.URL, "url")
+	datetimePath := orDefault(mapping.Datetime, "published_at")
+
+	articles := make([]Article, 0, len(items))
+	for _, item := range items {
+		a := Article{
+			Headline: cleanFeedText(stringAt(item, headlinePath), 300),
+			Summary:  cleanFeedText(stringAt(item, summaryPath), 500),
+			URL:      strings.TrimSpace(stringAt(item, urlPath)),
+		}
+		if v, ok := lookupPath(item, datetimePath); ok {
+			a.Datetime = parseArticleTime(v)
+		}
+		if mapping.Source != "" {
+			a.Source = stringAt(item, mapping.Source)
+		}
+		articles = append(articles, a)
+	}
+	return articles, nil
+}
+
+// lookupPath 按点号路径取值（空路径返回根节点）
+func lookupPath(node interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return node, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if node, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// stringAt 按路径取字符串（数字等非字符串值按文本返回）
+func stringAt(node interface{}, path string) string {
+	v, ok := lookupPath(node, path)
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
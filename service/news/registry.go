@@ -0,0 +1,253 @@
+package news
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/database"
+)
+
+// 自定义新闻源类型
+const (
+	SourceTypeRSS     = "rss"
+	SourceTypeJSON    = "json"
+	SourceTypeWebhook = "webhook"
+)
+
+// SourceBuilder 根据新闻源配置创建Fetcher
+type SourceBuilder func(src database.NewsSource) (Fetcher, error)
+
+// SourceRegistry 新闻源类型注册表
+// 新闻服务通过注册表把数据库中的新闻源配置创建为Fetcher，新增来源类型只需注册一个SourceBuilder
+type SourceRegistry struct {
+	mu       sync.RWMutex
+	builders map[string]SourceBuilder
+}
+
+// NewSourceRegistry 创建空注册表
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{builders: make(map[string]SourceBuilder)}
+}
+
+// DefaultRegistry 内置RSS/Atom、JSON接口和Webhook来源的默认注册表
+var DefaultRegistry = newDefaultRegistry()
+
+// newDefaultRegistry 注册内置来源类型
+func newDefaultRegistry() *SourceRegistry {
+	r := NewSourceRegistry()
+	r.Register(SourceTypeRSS, NewRSSFetcher)
+	r.Register(SourceTypeJSON, NewJSONFetcher)
+	r.Register(SourceTypeWebhook, NewWebhookFetcher)
+	return r
+}
+
+// Register 注册来源类型（同名覆盖）
+func (r *SourceRegistry) Register(sourceType string, builder SourceBuilder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.builders[strings.ToLower(sourceType)] = builder
+}
+
+// Types 已注册的来源类型列表
+func (r *SourceRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.builders))
+	for t := range r.builders {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// IsSupported 检查来源类型是否已注册
+func (r *SourceRegistry) IsSupported(sourceType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.builders[strings.ToLower(sourceType)]
+	return ok
+}
+
+// Create 创建新闻源的Fetcher
+func (r *SourceRegistry) Create(src database.NewsSource) (Fetcher, error) {
+	r.mu.RLock()
+	builder, ok := r.builders[strings.ToLower(src.Type)]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("不支持的新闻源类型: %s", src.Type)
+	}
+	return builder(src)
+}
+
+// ValidateSource 校验新闻源配置（类型已注册、抓取类来源需要http(s)地址）
+func ValidateSource(src database.NewsSource) error {
+	if !DefaultRegistry.IsSupported(src.Type) {
+		return fmt.Errorf("不支持的新闻源类型: %s (可选 %s)", src.Type, strings.Join(DefaultRegistry.Types(), ", "))
+	}
+	return validateSourceConfig(src)
+}
+
+// validateSourceConfig 校验名称和地址（由各来源类型的SourceBuilder调用）
+func validateSourceConfig(src database.NewsSource) error {
+	if strings.TrimSpace(src.Name) == "" {
+		return fmt.Errorf("新闻源名称不能为空")
+	}
+	if strings.EqualFold(src.Name, "Finnhub") || strings.EqualFold(src.Name, "Mlion") {
+		return fmt.Errorf("新闻源名称 %s 为内置来源保留", src.Name)
+	}
+	if src.Type == SourceTypeWebhook {
+		return nil
+	}
+	u, err := url.Parse(src.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("新闻源地址必须是http(s)链接: %s", src.URL)
+	}
+	return nil
+}
+
+// guardedFetcher 带断路器的新闻源（每个来源独立断路，故障来源不影响其他来源）
+type guardedFetcher struct {
+	Fetcher
+	breaker     *CircuitBreaker
+	statePrefix string // news_feed_state中的状态键前缀（内置来源为空，沿用原有状态键）
+	ownerID     string // 用户新闻源的所属用户
+	hashedIDs   bool   // 文章ID为链接/标题哈希（无序），只按发布时间过滤
+}
+
+// FetchNews 通过断路器抓取新闻，并标记文章的所属用户
+func (g *guardedFetcher) FetchNews(category string) ([]Article, error) {
+	var articles []Article
+	err := g.breaker.Call(func() error {
+		var err error
+		articles, err = g.Fetcher.FetchNews(category)
+		return err
+	})
+	if errors.Is(err, ErrCircuitOpen) {
+		return nil, fmt.Errorf("新闻源 %s 连续失败，暂停抓取: %w", g.Name(), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range articles {
+		articles[i].OwnerID = g.ownerID
+	}
+	return articles, nil
+}
+
+// seenArticles 最近处理过的文章哈希（跨来源、跨周期去重，超出容量时淘汰最早的记录）
+type seenArticles struct {
+	capacity int
+	order    []uint64
+	set      map[uint64]bool
+}
+
+func newSeenArticles(capacity int) *seenArticles {
+	return &seenArticles{capacity: capacity, set: make(map[uint64]bool)}
+}
+
+// Contains 是否已处理过
+func (s *seenArticles) Contains(h uint64) bool {
+	return s.set[h]
+}
+
+// Add 记录已处理的文章
+func (s *seenArticles) Add(h uint64) {
+	if s.set[h] {
+		return
+	}
+	if len(s.order) >= s.capacity {
+		delete(s.set, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.order = append(s.order, h)
+	s.set[h] = true
+}
+
+// ArticleHash 文章去重哈希：优先使用链接，没有链接时使用标题（都为空时返回0，表示不参与去重）
+func ArticleHash(a Article) uint64 {
+	key := normalizeArticleURL(a.URL)
+	if key == "" {
+		key = strings.ToLower(strings.Join(strings.Fields(a.Headline), " "))
+	}
+	if key == "" {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// seenKey 跨来源去重的键：文章哈希按所属用户区分（公共来源OwnerID为空）
+// 某个用户的私有来源处理过的文章不影响其他用户和公共来源
+func seenKey(a Article) uint64 {
+	h := ArticleHash(a)
+	if h == 0 || a.OwnerID == "" {
+		return h
+	}
+	f := fnv.New64a()
+	f.Write([]byte(a.OwnerID))
+	f.Write([]byte{0})
+	f.Write([]byte(strconv.FormatUint(h, 16)))
+	return f.Sum64()
+}
+
+// normalizeArticleURL 规范化链接（忽略协议、大小写、结尾斜杠和锚点，同一篇文章在不同来源中的链接视为相同）
+func normalizeArticleURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(raw)
+	}
+	return strings.ToLower(strings.TrimPrefix(u.Host, "www.")) + strings.TrimRight(u.EscapedPath(), "/") + queryString(u)
+}
+
+// queryString 保留查询参数（部分站点用查询参数区分文章）
+func queryString(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	return "?" + u.RawQuery
+}
+
+// articleID 由去重哈希生成文章ID（自定义来源没有可比较的自增ID）
+func articleID(a Article) int64 {
+	return int64(ArticleHash(a) & 0x7fffffffffffffff)
+}
+
+// finalizeArticles 填充自定义来源文章的ID、来源、分类和时间（没有发布时间的文章使用抓取时间）
+func finalizeArticles(articles []Article, src database.NewsSource) []Article {
+	now := time.Now().Unix()
+	result := articles[:0]
+	for _, a := range articles {
+		if a.Headline == "" && a.URL == "" {
+			continue
+		}
+		a.ID = articleID(a)
+		if a.Source == "" {
+			a.Source = src.Name
+		}
+		if a.Category == "" {
+			a.Category = src.Category
+		}
+		if a.Category == "" {
+			a.Category = "crypto"
+		}
+		if a.Datetime <= 0 {
+			a.Datetime = now
+		}
+		result = append(result, a)
+	}
+	return result
+}
@@ -0,0 +1,198 @@
+package news
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"nofx/database"
+)
+
+func TestSourceRegistry(t *testing.T) {
+	if got := DefaultRegistry.Types(); !reflect.DeepEqual(got, []string{"json", "rss", "webhook"}) {
+		t.Errorf("默认注册表类型 = %v", got)
+	}
+	if _, err := DefaultRegistry.Create(database.NewsSource{Name: "x", Type: "ftp"}); err == nil {
+		t.Error("未注册的类型应返回错误")
+	}
+
+	cases := []struct {
+		src   database.NewsSource
+		valid bool
+	}{
+		{database.NewsSource{Name: "Feed", Type: "rss", URL: "https://example.com/feed"}, true},
+		{database.NewsSource{Name: "Hook", Type: "webhook"}, true},
+		{database.NewsSource{Name: "", Type: "rss", URL: "https://example.com/feed"}, false},
+		{database.NewsSource{Name: "finnhub", Type: "rss", URL: "https://example.com/feed"}, false},
+		{database.NewsSource{Name: "Feed", Type: "json", URL: "file:///etc/passwd"}, false},
+		{database.NewsSource{Name: "Feed", Type: "atom", URL: "https://example.com/feed"}, false},
+	}
+	for _, tc := range cases {
+		if err := ValidateSource(tc.src); (err == nil) != tc.valid {
+			t.Errorf("ValidateSource(%+v) = %v, want valid=%v", tc.src, err, tc.valid)
+		}
+	}
+
+	r := NewSourceRegistry()
+	r.Register("Custom", func(src database.NewsSource) (Fetcher, error) {
+		return &MockFetcher{MockName: src.Name}, nil
+	})
+	f, err := r.Create(database.NewsSource{Name: "Mine", Type: "custom"})
+	if err != nil || f.Name() != "Mine" {
+		t.Errorf("自定义类型创建失败: %v", err)
+	}
+}
+
+func TestRSSFetcher_FetchAndPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `<rss><channel><item><title>Fed holds rates</title><link>https://example.com/fed</link></item></channel></rss>`)
+	}))
+	defer server.Close()
+
+	src := database.NewsSource{ID: "s1", Name: "Macro", Type: SourceTypeRSS, URL: server.URL, Category: "general",
+		Headers: map[string]string{"X-Api-Key": "secret"}}
+	f, err := DefaultRegistry.Create(src)
+	if err != nil {
+		t.Fatalf("创建RSS新闻源失败: %v", err)
+	}
+	articles, err := f.FetchNews("crypto")
+	if err != nil {
+		t.Fatalf("抓取失败: %v", err)
+	}
+	if len(articles) != 1 {
+		t.Fatalf("文章数量 = %d, want 1", len(articles))
+	}
+	a := articles[0]
+	if a.ID <= 0 || a.Source != "Macro" || a.Category != "general" || a.Datetime == 0 {
+		t.Errorf("文章字段未填充: %+v", a)
+	}
+
+	// 用户新闻源不允许访问内网/回环地址
+	src.UserID = "user-1"
+	f, _ = DefaultRegistry.Create(src)
+	if _, err := f.FetchNews("crypto"); err == nil || !strings.Contains(err.Error(), "内网") {
+		t.Errorf("用户新闻源访问回环地址应被拒绝, got %v", err)
+	}
+}
+
+func TestService_RegistrySourceDedupAndOwner(t *testing.T) {
+	store := &MockStateStore{}
+	notifier := &MockNotifier{}
+	svc := NewService(store)
+	svc.notifier = notifier
+	svc.topicRouter = map[string]int{}
+
+	userSrc := database.NewsSource{ID: "s1", UserID: "user-1", Name: "MyFeed", Type: SourceTypeRSS}
+	feed := &MockFetcher{MockName: "MyFeed", News: finalizeArticles([]Article{
+		{Headline: "SEC approves ETF", URL: "https://example.com/a", Datetime: 100},
+		{Headline: "Exchange hacked", URL: "https://example.com/b", Datetime: 100},
+	}, userSrc)}
+	guarded := svc.guard(feed, "source_s1", userSrc)
+
+	articles, unsubscribe := Subscribe(10)
+	defer unsubscribe()
+
+	if err := svc.ProcessFetcher(guarded, "crypto"); err != nil {
+		t.Fatalf("ProcessFetcher failed: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("广播文章数量 = %d, want 2", len(articles))
+	}
+	if a := <-articles; a.OwnerID != "user-1" {
+		t.Errorf("用户新闻源文章应标记所属用户: %+v", a)
+	}
+	<-articles
+	if len(notifier.SentMessages) != 0 {
+		t.Errorf("用户新闻源不应推送到Telegram: %v", notifier.SentMessages)
+	}
+
+	// 再次抓取相同文章（发布时间等于状态时间）不重复处理
+	if err := svc.ProcessFetcher(guarded, "crypto"); err != nil {
+		t.Fatalf("ProcessFetcher failed: %v", err)
+	}
+	// 同一用户其他来源的同一篇文章（链接仅协议/www/结尾斜杠不同）也跳过
+	otherSrc := database.NewsSource{ID: "s2", UserID: "user-1", Name: "Other", Type: SourceTypeRSS}
+	other := &MockFetcher{MockName: "Other", News: finalizeArticles([]Article{
+		{Headline: "SEC approves spot ETF", URL: "http://www.example.com/a/", Datetime: 200},
+	}, otherSrc)}
+	if err := svc.ProcessFetcher(svc.guard(other, "source_s2", otherSrc), "crypto"); err != nil {
+		t.Fatalf("ProcessFetcher failed: %v", err)
+	}
+	if len(articles) != 0 || len(notifier.SentMessages) != 0 {
+		t.Errorf("重复文章不应再次处理: broadcast=%d, sent=%v", len(articles), notifier.SentMessages)
+	}
+
+	// 其他用户的来源和公共来源按各自范围去重，不受user-1已处理的文章影响
+	strangerSrc := database.NewsSource{ID: "s3", UserID: "user-2", Name: "Stranger", Type: SourceTypeRSS}
+	stranger := &MockFetcher{MockName: "Stranger", News: finalizeArticles([]Article{
+		{Headline: "SEC approves ETF", URL: "https://example.com/a", Datetime: 300},
+	}, strangerSrc)}
+	if err := svc.ProcessFetcher(svc.guard(stranger, "source_s3", strangerSrc), "crypto"); err != nil {
+		t.Fatalf("ProcessFetcher failed: %v", err)
+	}
+	public := &MockFetcher{MockName: "Public", News: []Article{
+		{ID: 5, Headline: "SEC approves ETF", URL: "https://example.com/a", Datetime: 400},
+	}}
+	if err := svc.ProcessFetcher(public, "crypto"); err != nil {
+		t.Fatalf("ProcessFetcher failed: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("其他用户和公共来源的文章应各自处理: broadcast=%d", len(articles))
+	}
+	if a := <-articles; a.OwnerID != "user-2" {
+		t.Errorf("应先广播user-2的文章: %+v", a)
+	}
+	if a := <-articles; a.OwnerID != "" {
+		t.Errorf("应广播公共来源的文章: %+v", a)
+	}
+}
+
+func TestService_GuardOpensCircuit(t *testing.T) {
+	svc := NewService(&MockStateStore{})
+	failing := &MockFetcher{MockName: "Broken", Err: errors.New("timeout")}
+	src := database.NewsSource{ID: "s2", Name: "Broken", Type: SourceTypeJSON}
+
+	f := svc.guard(failing, "source_s2", src)
+	for i := 0; i < sourceFailureThreshold; i++ {
+		if err := svc.ProcessFetcher(f, "crypto"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("第%d次失败应返回原始错误, got %v", i+1, err)
+		}
+	}
+
+	// 重新加载配置后断路器状态保留
+	f = svc.guard(failing, "source_s2", src)
+	if err := svc.ProcessFetcher(f, "crypto"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("连续失败后应断路, got %v", err)
+	}
+}
+
+func TestWebhookInbox(t *testing.T) {
+	src := database.NewsSource{ID: "hook-1", UserID: "user-1", Name: "Alerts", Type: SourceTypeWebhook}
+	accepted := PushWebhookArticles(src, []Article{
+		{Headline: "Listing announced", URL: "https://example.com/listing"},
+		{}, // 空文章丢弃
+	})
+	if accepted != 1 {
+		t.Errorf("入队数量 = %d, want 1", accepted)
+	}
+
+	f, err := DefaultRegistry.Create(src)
+	if err != nil {
+		t.Fatalf("创建Webhook新闻源失败: %v", err)
+	}
+	articles, _ := f.FetchNews("crypto")
+	if len(articles) != 1 || articles[0].Source != "Alerts" || articles[0].ID == 0 {
+		t.Errorf("Webhook文章错误: %+v", articles)
+	}
+	if articles, _ := f.FetchNews("crypto"); len(articles) != 0 {
+		t.Errorf("取出后队列应为空: %+v", articles)
+	}
+}
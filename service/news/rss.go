@@ -0,0 +1,161 @@
+package news
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"nofx/database"
+)
+
+// RSSFetcher RSS 2.0 / RSS 1.0 / Atom 新闻源
+type RSSFetcher struct {
+	src    database.NewsSource
+	client *http.Client
+}
+
+// rssDocument 同时兼容RSS(channel/item)、RSS 1.0(根节点item)和Atom(entry)
+type rssDocument struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	DCDate      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// NewRSSFetcher 创建RSS/Atom新闻源
+func NewRSSFetcher(src database.NewsSource) (Fetcher, error) {
+	if err := validateSourceConfig(src); err != nil {
+		return nil, err
+	}
+	return &RSSFetcher{src: src, client: newSourceHTTPClient(src)}, nil
+}
+
+// Name 返回新闻源名称
+func (f *RSSFetcher) Name() string {
+	return f.src.Name
+}
+
+// FetchNews 抓取并解析订阅源（category参数忽略，使用新闻源配置的分类）
+func (f *RSSFetcher) FetchNews(category string) ([]Article, error) {
+	body, err := fetchSourceBody(f.client, f.src, "application/rss+xml, application/atom+xml, application/xml, text/xml")
+	if err != nil {
+		return nil, err
+	}
+	articles, err := ParseFeed(body)
+	if err != nil {
+		return nil, fmt.Errorf("解析新闻源 %s 失败: %w", f.src.Name, err)
+	}
+	return finalizeArticles(articles, f.src), nil
+}
+
+// ParseFeed 解析RSS/Atom文档
+func ParseFeed(body []byte) ([]Article, error) {
+	var doc rssDocument
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = feedCharsetReader
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var articles []Article
+	for _, item := range append(doc.Channel.Items, doc.Items...) {
+		published := item.PubDate
+		if published == "" {
+			published = item.DCDate
+		}
+		articles = append(articles, Article{
+			Headline: cleanFeedText(item.Title, 300),
+			Summary:  cleanFeedText(item.Description, 500),
+			URL:      strings.TrimSpace(item.Link),
+			Datetime: parseArticleTime(published),
+		})
+	}
+	for _, entry := range doc.Entries {
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+		articles = append(articles, Article{
+			Headline: cleanFeedText(entry.Title, 300),
+			Summary:  cleanFeedText(summary, 500),
+			URL:      atomLink(entry),
+			Datetime: parseArticleTime(published),
+		})
+	}
+	return articles, nil
+}
+
+// atomLink Atom条目的文章链接（优先rel=alternate）
+func atomLink(entry atomEntry) string {
+	for _, l := range entry.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	if len(entry.Links) > 0 {
+		return strings.TrimSpace(entry.Links[0].Href)
+	}
+	return ""
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// cleanFeedText 去除HTML标签和多余空白，按字符数截断
+func cleanFeedText(s string, maxRunes int) string {
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&quot;", "\"", "&#39;", "'", "&lt;", "<", "&gt;", ">").Replace(s)
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxRunes {
+		return string(r[:maxRunes]) + "..."
+	}
+	return s
+}
+
+// feedCharsetReader 支持声明为ISO-8859-1/US-ASCII的订阅源（其他非UTF-8编码返回错误）
+func feedCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	return nil, fmt.Errorf("不支持的编码: %s", charset)
+}
@@ -0,0 +1,88 @@
+package news
+
+import (
+	"testing"
+
+	"nofx/database"
+)
+
+func TestParseFeed(t *testing.T) {
+	rss := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+  <item>
+    <title>Bitcoin ETF &amp; inflows</title>
+    <link> https://example.com/btc-etf </link>
+    <description><![CDATA[<p>Record <b>inflows</b> today</p>]]></description>
+    <pubDate>Mon, 02 Jan 2026 15:04:05 +0000</pubDate>
+  </item>
+</channel></rss>`)
+	articles, err := ParseFeed(rss)
+	if err != nil {
+		t.Fatalf("解析RSS失败: %v", err)
+	}
+	if len(articles) != 1 {
+		t.Fatalf("RSS文章数量 = %d, want 1", len(articles))
+	}
+	a := articles[0]
+	if a.Headline != "Bitcoin ETF & inflows" || a.Summary != "Record inflows today" || a.URL != "https://example.com/btc-etf" {
+		t.Errorf("RSS文章解析错误: %+v", a)
+	}
+	if a.Datetime != 1767366245 {
+		t.Errorf("RSS发布时间 = %d, want 1767366245", a.Datetime)
+	}
+
+	atom := []byte(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <title>SEC update</title>
+    <link rel="self" href="https://example.com/self"/>
+    <link rel="alternate" href="https://example.com/sec"/>
+    <content>Full text</content>
+    <updated>2026-01-02T15:04:05Z</updated>
+  </entry>
+</feed>`)
+	articles, err = ParseFeed(atom)
+	if err != nil {
+		t.Fatalf("解析Atom失败: %v", err)
+	}
+	if len(articles) != 1 || articles[0].URL != "https://example.com/sec" || articles[0].Summary != "Full text" || articles[0].Datetime != 1767366245 {
+		t.Errorf("Atom文章解析错误: %+v", articles)
+	}
+}
+
+func TestParseMappedJSON(t *testing.T) {
+	body := []byte(`{"data": {"items": [
+		{"attributes": {"name": "ETH upgrade", "link": "https://example.com/eth"}, "ts": 1767366245000, "outlet": "Desk"},
+		{"attributes": {"name": "Second"}, "ts": "2026-01-02 15:04:05"}
+	]}}`)
+	mapping := database.NewsFieldMapping{
+		Items:    "data.items",
+		Headline: "attributes.name",
+		URL:      "attributes.link",
+		Datetime: "ts",
+		Source:   "outlet",
+	}
+	articles, err := ParseMappedJSON(body, mapping)
+	if err != nil {
+		t.Fatalf("解析JSON失败: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("文章数量 = %d, want 2", len(articles))
+	}
+	if articles[0].Headline != "ETH upgrade" || articles[0].URL != "https://example.com/eth" || articles[0].Source != "Desk" {
+		t.Errorf("字段映射错误: %+v", articles[0])
+	}
+	if articles[0].Datetime != 1767366245 || articles[1].Datetime != 1767366245 {
+		t.Errorf("毫秒/字符串时间解析错误: %d, %d", articles[0].Datetime, articles[1].Datetime)
+	}
+
+	// 单个对象 + 默认字段
+	articles, err = ParseMappedJSON([]byte(`{"title": "Single", "url": "https://example.com/1"}`), database.NewsFieldMapping{})
+	if err != nil || len(articles) != 1 || articles[0].Headline != "Single" {
+		t.Errorf("单个对象解析错误: %+v, %v", articles, err)
+	}
+
+	if _, err := ParseMappedJSON([]byte(`{"data": 1}`), database.NewsFieldMapping{Items: "data.items"}); err == nil {
+		t.Error("文章列表路径不存在时应返回错误")
+	}
+}
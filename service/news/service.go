@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"nofx/database"
 )
 
 // Service 新闻服务
@@ -17,9 +19,18 @@ type Service struct {
 	topicRouter    map[string]int // 路由表: Source Name -> Telegram Topic ID
 	notifier       Notifier
 	enabled        bool
-	sentArticleIDs map[string]bool // 全局消息ID去重集合 (Source-ID)
+	sentArticleIDs map[string]bool            // 全局消息ID去重集合 (Source-ID)
+	seen           *seenArticles              // 最近处理过的文章（按链接/标题哈希跨来源去重）
+	breakers       map[string]*CircuitBreaker // 每个新闻源的断路器（重载配置后保留状态）
 }
 
+// 新闻源断路器参数：连续失败3次后暂停抓取15分钟
+const (
+	sourceFailureThreshold = 3
+	sourceCooldown         = 15 * time.Minute
+	seenArticlesCapacity   = 5000
+)
+
 // NewService 创建新闻服务
 func NewService(store StateStore) *Service {
 	return &Service{
@@ -89,7 +100,7 @@ func (s *Service) loadConfig() error {
 	// --- Finnhub Config ---
 	finnhubKey, _ := s.store.GetSystemConfig("finnhub_api_key")
 	if finnhubKey != "" {
-		s.fetchers = append(s.fetchers, NewFinnhubFetcher(finnhubKey))
+		s.fetchers = append(s.fetchers, s.guard(NewFinnhubFetcher(finnhubKey), "Finnhub", database.NewsSource{}))
 		s.topicRouter["Finnhub"] = defaultThreadID
 	}
 
@@ -99,7 +110,7 @@ func (s *Service) loadConfig() error {
 	mlionEnabledStr, _ := s.store.GetSystemConfig("mlion_news_enabled")
 
 	if mlionEnabledStr == "true" && mlionKey != "" {
-		s.fetchers = append(s.fetchers, NewMlionFetcher(mlionKey))
+		s.fetchers = append(s.fetchers, s.guard(NewMlionFetcher(mlionKey), "Mlion", database.NewsSource{}))
 		tid, err := strconv.Atoi(mlionTopicStr)
 		if err != nil {
 			log.Printf("⚠️ Mlion 话题 ID 解析失败 (%s), 使用默认 ID", mlionTopicStr)
@@ -108,6 +119,9 @@ func (s *Service) loadConfig() error {
 		s.topicRouter["Mlion"] = tid
 	}
 
+	// --- 自定义新闻源 (RSS/Atom、JSON接口、Webhook) ---
+	s.loadRegisteredSources(defaultThreadID)
+
 	if botToken == "" || chatID == "" {
 		return fmt.Errorf("缺少必要的 Telegram 配置")
 	}
@@ -117,6 +131,54 @@ func (s *Service) loadConfig() error {
 	return nil
 }
 
+// loadRegisteredSources 按注册表创建数据库中已启用的自定义新闻源
+func (s *Service) loadRegisteredSources(defaultThreadID int) {
+	sourceStore, ok := s.store.(SourceStore)
+	if !ok {
+		return
+	}
+	sources, err := sourceStore.GetEnabledNewsSources()
+	if err != nil {
+		log.Printf("⚠️ 加载自定义新闻源失败: %v", err)
+		return
+	}
+
+	for _, src := range sources {
+		fetcher, err := DefaultRegistry.Create(src)
+		if err != nil {
+			log.Printf("⚠️ 新闻源 %s 配置无效，已跳过: %v", src.Name, err)
+			continue
+		}
+		s.fetchers = append(s.fetchers, s.guard(fetcher, "source_"+src.ID, src))
+		if src.UserID == "" {
+			s.topicRouter[src.Name] = defaultThreadID
+			if src.TopicID > 0 {
+				s.topicRouter[src.Name] = src.TopicID
+			}
+		}
+	}
+}
+
+// guard 为新闻源加上断路器（同一来源的断路器在重载配置后保留）
+// src为空表示内置来源（Finnhub/Mlion），沿用原有状态键和自增ID过滤
+func (s *Service) guard(f Fetcher, key string, src database.NewsSource) Fetcher {
+	if s.breakers == nil {
+		s.breakers = make(map[string]*CircuitBreaker)
+	}
+	breaker, ok := s.breakers[key]
+	if !ok {
+		breaker = NewCircuitBreaker(sourceFailureThreshold, sourceCooldown)
+		s.breakers[key] = breaker
+	}
+	g := &guardedFetcher{Fetcher: f, breaker: breaker}
+	if src.ID != "" {
+		g.statePrefix = key + "_"
+		g.ownerID = src.UserID
+		g.hashedIDs = true
+	}
+	return g
+}
+
 func (s *Service) processAllCategories() {
 	// 每个周期开始时，清空上个周期的已发送消息ID记录
 	s.sentArticleIDs = make(map[string]bool)
@@ -164,6 +226,14 @@ func (s *Service) ProcessFetcher(f Fetcher, category string) error {
 	if f.Name() == "Mlion" {
 		dbCategoryKey = "mlion_" + category
 	}
+	guarded, _ := f.(*guardedFetcher)
+	if guarded != nil && guarded.statePrefix != "" {
+		dbCategoryKey = guarded.statePrefix + category
+	}
+	hashedIDs := guarded != nil && guarded.hashedIDs
+	if s.seen == nil {
+		s.seen = newSeenArticles(seenArticlesCapacity)
+	}
 
 	lastID, lastTime, err := s.store.GetNewsState(dbCategoryKey)
 	if err != nil {
@@ -176,7 +246,17 @@ func (s *Service) ProcessFetcher(f Fetcher, category string) error {
 	for _, a := range articles {
 		// 基础去重：按分类时间戳
 		// Note: We check against the SOURCE-specific lastID/Time
-		if int64(a.ID) <= lastID || a.Datetime <= lastTime {
+		// 自定义来源的ID是哈希（无序），只按发布时间过滤，同一时间的文章由哈希去重
+		if hashedIDs {
+			if a.Datetime < lastTime {
+				continue
+			}
+		} else if int64(a.ID) <= lastID || a.Datetime <= lastTime {
+			continue
+		}
+
+		// 跨来源去重：同一用户（或公共来源）的同一篇文章（链接或标题相同）只处理一次
+		if h := seenKey(a); h != 0 && s.seen.Contains(h) {
 			continue
 		}
 
@@ -201,25 +281,41 @@ func (s *Service) ProcessFetcher(f Fetcher, category string) error {
 	for i := range newArticles {
 		a := &newArticles[i]
 
+		// 通知订阅方（交易员新闻触发器）
+		Publish(*a)
+
 		// 原 AI 处理逻辑已移除
 
-		msg := formatMessage(*a)
+		// 用户新闻源只通知该用户的交易员，不推送到Telegram频道
+		pushed := a.OwnerID == ""
+		if pushed {
+			msg := formatMessage(*a)
 
-		if err := s.notifier.Send(msg, threadID); err != nil {
-			log.Printf("❌ 发送Telegram消息失败: %v", err)
-			continue
+			if err := s.notifier.Send(msg, threadID); err != nil {
+				log.Printf("❌ 发送Telegram消息失败: %v", err)
+				continue
+			}
 		}
 
 		dedupKey := fmt.Sprintf("%s-%d", f.Name(), a.ID)
 		s.sentArticleIDs[dedupKey] = true
+		if h := seenKey(*a); h != 0 {
+			s.seen.Add(h)
+		}
 
-		// 更新状态 using the prefixed key
-		if err := s.store.UpdateNewsState(dbCategoryKey, int64(a.ID), a.Datetime); err != nil {
+		// 更新状态 using the prefixed key（自定义来源只记录时间）
+		stateID := int64(a.ID)
+		if hashedIDs {
+			stateID = 0
+		}
+		if err := s.store.UpdateNewsState(dbCategoryKey, stateID, a.Datetime); err != nil {
 			log.Printf("⚠️ 更新新闻状态失败: %v", err)
 		}
 
-		log.Printf("📢 已推送新闻: [%s] %s", f.Name(), a.Headline)
-		time.Sleep(2 * time.Second)
+		if pushed {
+			log.Printf("📢 已推送新闻: [%s] %s", f.Name(), a.Headline)
+			time.Sleep(2 * time.Second)
+		}
 	}
 
 	return nil
@@ -1,6 +1,9 @@
 package news
 
-import "nofx/config"
+import (
+	"nofx/config"
+	"nofx/database"
+)
 
 // DBStateStore 实现 StateStore 接口，包装 config.Database
 type DBStateStore struct {
@@ -23,3 +26,7 @@ func (s *DBStateStore) UpdateNewsState(category string, id int64, timestamp int6
 func (s *DBStateStore) GetSystemConfig(key string) (string, error) {
 	return s.db.GetSystemConfig(key)
 }
+
+func (s *DBStateStore) GetEnabledNewsSources() ([]database.NewsSource, error) {
+	return s.db.GetEnabledNewsSources()
+}
@@ -1,5 +1,7 @@
 package news
 
+import "nofx/database"
+
 // Article 代表一条新闻
 type Article struct {
 	ID       int64  `json:"id"`
@@ -9,6 +11,7 @@ type Article struct {
 	Datetime int64  `json:"datetime"` // Unix timestamp
 	Source   string `json:"source"`
 	Category string `json:"category"`
+	OwnerID  string `json:"owner_id,omitempty"` // 用户自建新闻源的所属用户（为空表示系统新闻源）
 
 	// AI 增强字段 (保留以兼容旧代码，但不再使用)
 	TranslatedHeadline string `json:"translated_headline"`
@@ -34,3 +37,8 @@ type StateStore interface {
 	UpdateNewsState(category string, id int64, timestamp int64) error
 	GetSystemConfig(key string) (string, error)
 }
+
+// SourceStore 自定义新闻源存储（StateStore可选实现，实现后loadConfig会加载注册表中的新闻源）
+type SourceStore interface {
+	GetEnabledNewsSources() ([]database.NewsSource, error)
+}
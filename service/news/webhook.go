@@ -0,0 +1,66 @@
+package news
+
+import (
+	"sync"
+
+	"nofx/database"
+)
+
+// maxWebhookQueue 每个Webhook新闻源最多缓存的待处理文章数（超出时丢弃最早的文章）
+const maxWebhookQueue = 500
+
+// webhookInbox Webhook推送的文章队列（API接收后入队，新闻服务下个周期取出处理）
+type webhookInbox struct {
+	mu     sync.Mutex
+	queues map[string][]Article
+}
+
+var webhookArticles = &webhookInbox{queues: make(map[string][]Article)}
+
+// PushWebhookArticles 接收Webhook推送的文章，返回入队数量
+func PushWebhookArticles(src database.NewsSource, articles []Article) int {
+	articles = finalizeArticles(articles, src)
+
+	b := webhookArticles
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queue := append(b.queues[src.ID], articles...)
+	if len(queue) > maxWebhookQueue {
+		queue = queue[len(queue)-maxWebhookQueue:]
+	}
+	b.queues[src.ID] = queue
+	return len(articles)
+}
+
+// drain 取出新闻源的全部待处理文章
+func (b *webhookInbox) drain(sourceID string) []Article {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	articles := b.queues[sourceID]
+	delete(b.queues, sourceID)
+	return articles
+}
+
+// WebhookFetcher 入站Webhook新闻源（外部系统推送到 /api/webhooks/news/:id，按周期取出）
+type WebhookFetcher struct {
+	src database.NewsSource
+}
+
+// NewWebhookFetcher 创建Webhook新闻源
+func NewWebhookFetcher(src database.NewsSource) (Fetcher, error) {
+	if err := validateSourceConfig(src); err != nil {
+		return nil, err
+	}
+	return &WebhookFetcher{src: src}, nil
+}
+
+// Name 返回新闻源名称
+func (f *WebhookFetcher) Name() string {
+	return f.src.Name
+}
+
+// FetchNews 取出上个周期以来推送的文章
+func (f *WebhookFetcher) FetchNews(category string) ([]Article, error) {
+	return webhookArticles.drain(f.src.ID), nil
+}
@@ -19,6 +19,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/adshao/go-binance/v2/futures"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -426,7 +427,7 @@ func (t *AsterTrader) doRequest(method, endpoint string, params map[string]inter
 }
 
 // GetBalance 获取账户余额
-func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
+func (t *AsterTrader) GetBalance() (*Balance, error) {
 	params := make(map[string]interface{})
 	body, err := t.request("GET", "/fapi/v3/balance", params)
 	if err != nil {
@@ -458,16 +459,19 @@ func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
 		}
 	}
 
-	// 返回与Binance相同的字段名，确保AutoTrader能正确解析
-	return map[string]interface{}{
-		"totalWalletBalance":    totalBalance,
-		"availableBalance":      availableBalance,
-		"totalUnrealizedProfit": crossUnPnl,
+	// 接口不返回已用保证金，按净值减可用余额估算
+	usedMargin := math.Max(0, totalBalance+crossUnPnl-availableBalance)
+
+	return &Balance{
+		TotalWalletBalance:    totalBalance,
+		AvailableBalance:      availableBalance,
+		TotalUnrealizedProfit: crossUnPnl,
+		UsedMargin:            usedMargin,
 	}, nil
 }
 
 // GetPositions 获取持仓信息
-func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *AsterTrader) GetPositions() ([]Position, error) {
 	params := make(map[string]interface{})
 	body, err := t.request("GET", "/fapi/v3/positionRisk", params)
 	if err != nil {
@@ -479,7 +483,7 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 		return nil, err
 	}
 
-	result := []map[string]interface{}{}
+	result := []Position{}
 	for _, pos := range positions {
 		posAmtStr, ok := pos["positionAmt"].(string)
 		if !ok {
@@ -494,7 +498,7 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 		entryPrice, _ := strconv.ParseFloat(pos["entryPrice"].(string), 64)
 		markPrice, _ := strconv.ParseFloat(pos["markPrice"].(string), 64)
 		unRealizedProfit, _ := strconv.ParseFloat(pos["unRealizedProfit"].(string), 64)
-		leverageVal, _ := strconv.ParseFloat(pos["leverage"].(string), 64)
+		leverageVal, _ := strconv.Atoi(pos["leverage"].(string))
 		liquidationPrice, _ := strconv.ParseFloat(pos["liquidationPrice"].(string), 64)
 
 		// 判断方向（与Binance一致）
@@ -504,16 +508,16 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 			posAmt = -posAmt
 		}
 
-		// 返回与Binance相同的字段名
-		result = append(result, map[string]interface{}{
-			"symbol":           pos["symbol"],
-			"side":             side,
-			"positionAmt":      posAmt,
-			"entryPrice":       entryPrice,
-			"markPrice":        markPrice,
-			"unRealizedProfit": unRealizedProfit,
-			"leverage":         leverageVal,
-			"liquidationPrice": liquidationPrice,
+		symbol, _ := pos["symbol"].(string)
+		result = append(result, Position{
+			Symbol:           symbol,
+			Side:             side,
+			Quantity:         posAmt,
+			EntryPrice:       entryPrice,
+			MarkPrice:        markPrice,
+			UnrealizedProfit: unRealizedProfit,
+			Leverage:         leverageVal,
+			LiquidationPrice: liquidationPrice,
 		})
 	}
 
@@ -521,7 +525,12 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 }
 
 // OpenLong 开多单
-func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openLong(symbol, quantity, leverage, "")
+}
+
+// openLong 开仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *AsterTrader) openLong(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
 	// 开仓前先取消所有挂单,防止残留挂单导致仓位叠加
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消挂单失败(继续开仓): %v", err)
@@ -573,22 +582,30 @@ func (t *AsterTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
+	if clientOrderID != "" {
+		params["newClientOrderId"] = clientOrderID
+	}
 
 	body, err := t.request("POST", "/fapi/v3/order", params)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
+	var result futures.CreateOrderResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return binanceOrderResult(&result), nil
 }
 
 // OpenShort 开空单
-func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openShort(symbol, quantity, leverage, "")
+}
+
+// openShort 开仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *AsterTrader) openShort(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
 	// 开仓前先取消所有挂单,防止残留挂单导致仓位叠加
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消挂单失败(继续开仓): %v", err)
@@ -640,22 +657,30 @@ func (t *AsterTrader) OpenShort(symbol string, quantity float64, leverage int) (
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
+	if clientOrderID != "" {
+		params["newClientOrderId"] = clientOrderID
+	}
 
 	body, err := t.request("POST", "/fapi/v3/order", params)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
+	var result futures.CreateOrderResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return binanceOrderResult(&result), nil
 }
 
 // CloseLong 平多单
-func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *AsterTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	return t.closeLong(symbol, quantity, "")
+}
+
+// closeLong 平仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *AsterTrader) closeLong(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -663,11 +688,8 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 			return nil, err
 		}
 
-		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "long" {
-				quantity = pos["positionAmt"].(float64)
-				break
-			}
+		if pos, ok := FindPosition(positions, symbol, "long"); ok {
+			quantity = pos.Quantity
 		}
 
 		if quantity == 0 {
@@ -715,13 +737,16 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
+	if clientOrderID != "" {
+		params["newClientOrderId"] = clientOrderID
+	}
 
 	body, err := t.request("POST", "/fapi/v3/order", params)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
+	var result futures.CreateOrderResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
@@ -733,11 +758,16 @@ func (t *AsterTrader) CloseLong(symbol string, quantity float64) (map[string]int
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	return result, nil
+	return binanceOrderResult(&result), nil
 }
 
 // CloseShort 平空单
-func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *AsterTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	return t.closeShort(symbol, quantity, "")
+}
+
+// closeShort 平仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *AsterTrader) closeShort(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -745,12 +775,8 @@ func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]in
 			return nil, err
 		}
 
-		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "short" {
-				// Aster的GetPositions已经将空仓数量转换为正数，直接使用
-				quantity = pos["positionAmt"].(float64)
-				break
-			}
+		if pos, ok := FindPosition(positions, symbol, "short"); ok {
+			quantity = pos.Quantity
 		}
 
 		if quantity == 0 {
@@ -798,13 +824,16 @@ func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]in
 		"quantity":     qtyStr,
 		"price":        priceStr,
 	}
+	if clientOrderID != "" {
+		params["newClientOrderId"] = clientOrderID
+	}
 
 	body, err := t.request("POST", "/fapi/v3/order", params)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
+	var result futures.CreateOrderResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
@@ -816,7 +845,7 @@ func (t *AsterTrader) CloseShort(symbol string, quantity float64) (map[string]in
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	return result, nil
+	return binanceOrderResult(&result), nil
 }
 
 // SetMarginMode 设置仓位模式
@@ -981,6 +1010,214 @@ func (t *AsterTrader) CancelAllOrders(symbol string) error {
 	return err
 }
 
+// QueryOrder 查询订单状态（实现OrderQuerier，接口与币安兼容）
+func (t *AsterTrader) QueryOrder(symbol, orderID string) (*Order, error) {
+	params := map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": orderID,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/order", params)
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var o futures.Order
+	if err := json.Unmarshal(body, &o); err != nil {
+		return nil, fmt.Errorf("解析订单失败: %w", err)
+	}
+	order := binanceOrder(&o)
+
+	if order.FilledQty > 0 {
+		body, err := t.request("GET", "/fapi/v3/userTrades", params)
+		if err != nil {
+			log.Printf("  ⚠ 获取订单成交记录失败: %v", err)
+		} else {
+			var trades []struct {
+				Commission string `json:"commission"`
+			}
+			if err := json.Unmarshal(body, &trades); err == nil {
+				for _, trade := range trades {
+					fee, _ := strconv.ParseFloat(trade.Commission, 64)
+					order.Fee += fee
+				}
+			}
+		}
+	}
+	return order, nil
+}
+
+// GetOpenOrders 获取该币种当前挂单（实现OrderQuerier）
+func (t *AsterTrader) GetOpenOrders(symbol string) ([]*Order, error) {
+	params := map[string]interface{}{
+		"symbol": symbol,
+	}
+
+	body, err := t.request("GET", "/fapi/v3/openOrders", params)
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	var list []futures.Order
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("解析挂单失败: %w", err)
+	}
+
+	orders := make([]*Order, 0, len(list))
+	for i := range list {
+		orders = append(orders, binanceOrder(&list[i]))
+	}
+	return orders, nil
+}
+
+// GetIncomeHistory 获取since之后账户的手续费和资金费流水（实现IncomeLedgerProvider）
+func (t *AsterTrader) GetIncomeHistory(since time.Time) ([]IncomeRecord, error) {
+	var all []*futures.IncomeHistory
+	for _, incomeType := range []string{"COMMISSION", "FUNDING_FEE"} {
+		params := map[string]interface{}{
+			"incomeType": incomeType,
+			"startTime":  since.UnixMilli(),
+			"limit":      1000,
+		}
+		body, err := t.request("GET", "/fapi/v3/income", params)
+		if err != nil {
+			return nil, fmt.Errorf("获取%s账单失败: %w", incomeType, err)
+		}
+
+		var incomes []*futures.IncomeHistory
+		if err := json.Unmarshal(body, &incomes); err != nil {
+			return nil, fmt.Errorf("解析%s账单失败: %w", incomeType, err)
+		}
+		all = append(all, incomes...)
+	}
+	return binanceIncomeRecords(all, func(symbol string) ([]*futures.AccountTrade, error) {
+		body, err := t.request("GET", "/fapi/v3/userTrades", map[string]interface{}{
+			"symbol":    symbol,
+			"startTime": since.UnixMilli(),
+			"limit":     1000,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var trades []*futures.AccountTrade
+		if err := json.Unmarshal(body, &trades); err != nil {
+			return nil, fmt.Errorf("解析成交记录失败: %w", err)
+		}
+		return trades, nil
+	})
+}
+
+// PlaceMarketOrder 按动作下市价单并携带本地订单ID（实现ClientOrderPlacer）
+func (t *AsterTrader) PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	switch action {
+	case "open_long":
+		return t.openLong(symbol, quantity, leverage, clientOrderID)
+	case "open_short":
+		return t.openShort(symbol, quantity, leverage, clientOrderID)
+	case "close_long":
+		return t.closeLong(symbol, quantity, clientOrderID)
+	case "close_short":
+		return t.closeShort(symbol, quantity, clientOrderID)
+	}
+	return nil, fmt.Errorf("未知的下单动作: %s", action)
+}
+
+// PlaceLimitOrder 下限价开仓单（实现LimitOrderPlacer，postOnly时使用GTX）
+func (t *AsterTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, fmt.Errorf("设置杠杆失败: %w", err)
+	}
+
+	formattedPrice, err := t.formatPrice(symbol, price)
+	if err != nil {
+		return nil, err
+	}
+	formattedQty, err := t.formatQuantity(symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+	prec, err := t.getPrecision(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	side := "BUY"
+	if positionSide == "SHORT" {
+		side = "SELL"
+	}
+	timeInForce := "GTC"
+	if postOnly {
+		timeInForce = "GTX"
+	}
+
+	params := map[string]interface{}{
+		"symbol":       symbol,
+		"positionSide": "BOTH",
+		"type":         "LIMIT",
+		"side":         side,
+		"timeInForce":  timeInForce,
+		"quantity":     t.formatFloatWithPrecision(formattedQty, prec.QuantityPrecision),
+		"price":        t.formatFloatWithPrecision(formattedPrice, prec.PricePrecision),
+	}
+	if clientOrderID != "" {
+		params["newClientOrderId"] = clientOrderID
+	}
+
+	body, err := t.request("POST", "/fapi/v3/order", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result futures.CreateOrderResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	return binanceOrderResult(&result), nil
+}
+
+// CancelOrder 撤销单个订单（实现LimitOrderPlacer）
+func (t *AsterTrader) CancelOrder(symbol, orderID string) error {
+	params := map[string]interface{}{
+		"symbol":  symbol,
+		"orderId": orderID,
+	}
+
+	_, err := t.request("DELETE", "/fapi/v3/order", params)
+	return err
+}
+
+// CancelStopOrder 撤销止损/止盈条件单（实现StopOrderCanceller，条件单与普通订单使用同一撤单接口）
+func (t *AsterTrader) CancelStopOrder(order *Order) error {
+	return t.CancelOrder(order.Symbol, order.ExchangeOrderID)
+}
+
+// GetBestBidAsk 获取买一/卖一价（实现LimitOrderPlacer）
+func (t *AsterTrader) GetBestBidAsk(symbol string) (float64, float64, error) {
+	resp, err := t.client.Get(fmt.Sprintf("%s/fapi/v3/ticker/bookTicker?symbol=%s", t.baseURL, symbol))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var ticker struct {
+		BidPrice string `json:"bidPrice"`
+		AskPrice string `json:"askPrice"`
+	}
+	if err := json.Unmarshal(body, &ticker); err != nil {
+		return 0, 0, err
+	}
+
+	bid, _ := strconv.ParseFloat(ticker.BidPrice, 64)
+	ask, _ := strconv.ParseFloat(ticker.AskPrice, 64)
+	return bid, ask, nil
+}
+
 // FormatQuantity 格式化数量（实现Trader接口）
 func (t *AsterTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	formatted, err := t.formatQuantity(symbol, quantity)
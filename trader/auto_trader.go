@@ -5,16 +5,18 @@ import (
         "encoding/json"
         "fmt"
         "log"
+        "nofx/ai"
         "nofx/config"
         "nofx/decision"
         "nofx/decision/analysis"
         "nofx/logger"
         "nofx/market"
-        "nofx/mcp"
         "nofx/pool"
         "nofx/service/credits"
+        "nofx/service/experiment"
         "strconv"
         "strings"
+        "sync"
         "time"
 )
 
@@ -62,19 +64,39 @@ type AutoTraderConfig struct {
         CustomAPIKey    string
         CustomModelName string
 
+        // 多模型投票配置（为空时从数据库读取交易员的集成配置）
+        EnsembleModelIDs []string // 参与投票的其他AI模型ID（主模型始终参与）
+        EnsemblePolicy   string   // 合并策略: unanimous, majority, weighted
+
         // 扫描配置
         ScanInterval time.Duration // 扫描间隔（建议3分钟）
 
+        // 事件驱动触发器（为nil时从数据库系统配置加载）
+        Triggers *TriggerConfig
+
+        // 开仓执行方式: market, limit_mid, post_only, twap（为空时从数据库读取交易员配置）
+        ExecutionMode string
+
+        // 单笔风险预算（止损亏损占净值的百分比，<=0时从数据库读取交易员配置，仍未配置则使用系统默认值）
+        RiskPerTradePct float64
+
+        // 跨交易所路由：与Exchange一起参与路由的其他交易所（为空时从数据库读取交易员配置，凭证使用上面对应交易所的配置）
+        RouteExchanges []string
+
+        // 交易时段和停牌事件（TradingSchedule的JSON，为空表示不限制开仓）
+        TradingSchedule string
+
         // 账户配置
-        InitialBalance float64 // 初始金额（用于计算盈亏，需手动设置）
+        InitialBalance float64   // 初始金额（用于计算盈亏，需手动设置）
+        CreatedAt      time.Time // 交易员创建时间（手续费/资金费账单只统计该时间之后的流水，为零时不限制）
 
         // 杠杆配置
         BTCETHLeverage  int // BTC和ETH的杠杆倍数
         AltcoinLeverage int // 山寨币的杠杆倍数
 
-        // 风险控制（仅作为提示，AI可自主决定）
-        MaxDailyLoss    float64       // 最大日亏损百分比（提示）
-        MaxDrawdown     float64       // 最大回撤百分比（提示）
+        // 风险控制（开仓前由风控断路器强制执行）
+        MaxDailyLoss    float64       // 最大日亏损百分比（超过后当日禁止开仓）
+        MaxDrawdown     float64       // 最大回撤百分比（超过后禁止开仓，需手动重置）
         StopTradingTime time.Duration // 触发风控后暂停时长
 
         // 仓位模式
@@ -100,7 +122,7 @@ type AutoTrader struct {
         exchange              string // 交易平台名称
         config                AutoTraderConfig
         trader                Trader // 使用Trader接口（支持多平台）
-        mcpClient             *mcp.Client
+        aiClient              decision.AIClient // 单模型为ai.DecisionClient，多模型投票时为decision.EnsembleClient
         decisionLogger        *logger.DecisionLogger     // 决策日志记录器
         kellyManager          *decision.KellyStopManager // 凯利公式止盈止损管理器
         symbolConfigManager   *decision.SymbolConfigManager // 币种特定参数管理器
@@ -120,6 +142,27 @@ type AutoTrader struct {
         startTime             time.Time        // 系统启动时间
         callCount             int              // AI调用次数
         positionFirstSeenTime map[string]int64 // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+        marketDataProvider    func(symbol string) (*market.Data, error) // 执行决策时的行情来源（nil时使用market.Get，决策回放时注入录制价格）
+        clock                 func() time.Time                          // 执行决策时的时钟（nil时使用time.Now，决策回放时使用录制时间）
+        triggerManager        *TriggerManager                           // 事件驱动触发器（未启用时为nil）
+        cycleTrigger          []TriggerEvent                            // 触发当前周期的事件
+        orderManager          *OrderManager                             // 订单管理器（跟踪订单状态并与交易所对账）
+        execution             ExecutionConfig                           // 开仓执行配置（决策可单独指定执行方式）
+        riskGates             []RiskGate                                // 开仓前风控闸门（断路器、学习阶段约束等）
+        trailingStops         *TrailingStopManager                      // 跟踪止损/保本止损（持仓峰值持久化）
+        lifecycle             *PositionLifecycleTracker                 // 持仓生命周期跟踪（识别交易所侧平仓并记录真实成交）
+        positionListeners     []PositionListener                        // 平仓事件监听者（学习协调器等）
+        ledgerStore           LedgerStore                               // 手续费/资金费流水存储（nil时不同步账单）
+        ledger                ledgerState                               // 账单同步进度和累计费用
+        sizing                *SizingEngine                             // 服务端仓位计算（风险预算/ATR/凯利比例）
+        liquidationGuard      *LiquidationGuard                         // 强平距离看门狗（决策周期之间按实时价格检查）
+        execMu                sync.Mutex                                // 决策周期下单阶段与强平保护互斥执行（避免同时对同一持仓下单）
+        positionGroups        *PositionGroupManager                     // 多腿组合持仓（按合计盈亏止损止盈）
+        scheduler             *TradingScheduler                         // 交易时段和停牌事件（时段外只管理现有持仓，不开新仓）
+        memory                *TradeMemory                              // 交易记忆（决策前检索相似历史交易，nil表示未启用mem0）
+        memoryInitAt          time.Time                                 // 上次创建交易记忆的时间（mem0.enabled标志开启后按间隔重试）
+        experiments           *experiment.Service                       // 在线A/B实验（按变体覆盖prompt/模型/记忆）
+        variantClients        map[string]decision.AIClient              // 实验变体使用的AI客户端（按AI模型ID缓存）
 }
 
 // NewAutoTrader 创建自动交易器
@@ -139,35 +182,44 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
                 }
         }
 
-        mcpClient := mcp.New()
-
         // 初始化信号源提供者
         signalProvider := pool.NewSignalProvider(pool.SignalProviderConfig{
                 CoinPoolAPIURL: config.CoinPoolAPIURL,
                 OITopAPIURL:    config.OITopAPIURL,
         })
 
-        // 初始化AI
-        if config.AIModel == "custom" {
-                // 使用自定义API
-                mcpClient.SetCustomAPI(config.CustomAPIURL, config.CustomAPIKey, config.CustomModelName)
-                log.Printf("🤖 [%s] 使用自定义AI API: %s (模型: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
-        } else if config.UseQwen || config.AIModel == "qwen" {
-                // 使用Qwen (支持自定义URL和Model)
-                mcpClient.SetQwenAPIKey(config.QwenKey, config.CustomAPIURL, config.CustomModelName)
-                if config.CustomAPIURL != "" || config.CustomModelName != "" {
-                        log.Printf("🤖 [%s] 使用阿里云Qwen AI (自定义URL: %s, 模型: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
-                } else {
-                        log.Printf("🤖 [%s] 使用阿里云Qwen AI", config.Name)
+        // 初始化AI（通过提供商注册表创建，配置了备用模型时主模型超时/失败自动切换）
+        primaryCfg := aiProviderConfig(config)
+        fallbackCfg := fallbackProviderConfig(config)
+        if config.Database != nil {
+                // 结构化输出模式（ai_tool_calling: on/off，为空时按Provider自动判断）
+                if mode, err := config.Database.GetSystemConfig("ai_tool_calling"); err == nil && mode != "" {
+                        primaryCfg.ToolCalling = mode
+                        fallbackCfg.ToolCalling = mode
                 }
+        }
+        aiModel, err := ai.DefaultRegistry.CreateWithFallback(primaryCfg, fallbackCfg)
+        if err != nil {
+                return nil, fmt.Errorf("初始化AI模型失败: %w", err)
+        }
+        aiClient := ai.NewDecisionClient(aiModel)
+        info := aiModel.GetModelInfo()
+        if primaryCfg.BaseURL != "" || primaryCfg.Model != "" {
+                log.Printf("🤖 [%s] 使用AI提供商 %s (自定义URL: %s, 模型: %s)", config.Name, info.Provider, primaryCfg.BaseURL, info.Name)
         } else {
-                // 默认使用DeepSeek (支持自定义URL和Model)
-                mcpClient.SetDeepSeekAPIKey(config.DeepSeekKey, config.CustomAPIURL, config.CustomModelName)
-                if config.CustomAPIURL != "" || config.CustomModelName != "" {
-                        log.Printf("🤖 [%s] 使用DeepSeek AI (自定义URL: %s, 模型: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
-                } else {
-                        log.Printf("🤖 [%s] 使用DeepSeek AI", config.Name)
-                }
+                log.Printf("🤖 [%s] 使用AI提供商 %s (模型: %s)", config.Name, info.Provider, info.Name)
+        }
+        if fallbackCfg.Provider != "" {
+                log.Printf("🛟 [%s] 备用AI提供商: %s", config.Name, fallbackCfg.Provider)
+        }
+        log.Printf("🧾 [%s] AI决策输出模式: tool calling=%v", config.Name, aiClient.SupportsToolCalling())
+
+        // 多模型投票：主模型与集成模型并行决策，按策略合并
+        var decisionClient decision.AIClient = aiClient
+        if ensembleClient, err := buildEnsembleClient(config, primaryCfg, aiClient); err != nil {
+                return nil, fmt.Errorf("初始化集成决策失败: %w", err)
+        } else if ensembleClient != nil {
+                decisionClient = ensembleClient
         }
 
         // 设置默认交易平台
@@ -175,10 +227,6 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
                 config.Exchange = "binance"
         }
 
-        // 根据配置创建对应的交易器
-        var trader Trader
-        var err error
-
         // 记录仓位模式（通用）
         marginModeStr := "全仓"
         if !config.IsCrossMargin {
@@ -186,30 +234,20 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
         }
         log.Printf("📊 [%s] 仓位模式: %s", config.Name, marginModeStr)
 
-        switch config.Exchange {
-        case "binance":
-                log.Printf("🏦 [%s] 使用币安合约交易", config.Name)
-                trader = NewFuturesTrader(config.BinanceAPIKey, config.BinanceSecretKey)
-        case "hyperliquid":
-                log.Printf("🏦 [%s] 使用Hyperliquid交易", config.Name)
-                trader, err = NewHyperliquidTrader(config.HyperliquidPrivateKey, config.HyperliquidWalletAddr, config.HyperliquidTestnet)
-                if err != nil {
-                        return nil, fmt.Errorf("初始化Hyperliquid交易器失败: %w", err)
-                }
-        case "aster":
-                log.Printf("🏦 [%s] 使用Aster交易", config.Name)
-                trader, err = NewAsterTrader(config.AsterUser, config.AsterSigner, config.AsterPrivateKey)
-                if err != nil {
-                        return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
-                }
-        case "okx":
-                log.Printf("🏦 [%s] 使用OKX交易", config.Name)
-                trader, err = NewOKXTrader(config.OKXAPIKey, config.OKXSecretKey, config.OKXPassphrase, config.OKXTestnet)
-                if err != nil {
-                        return nil, fmt.Errorf("初始化OKX交易器失败: %w", err)
+        trader, err := newExchangeTrader(config)
+        if err != nil {
+                return nil, err
+        }
+
+        // 跨交易所路由：配置了额外交易所时，开仓按价格/手续费/深度选择交易所
+        routeExchanges := config.RouteExchanges
+        if len(routeExchanges) == 0 && config.Database != nil && config.ID != "" {
+                if exchanges, err := config.Database.GetTraderRouteExchanges(config.ID); err == nil {
+                        routeExchanges = exchanges
                 }
-        default:
-                return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
+        }
+        if len(routeExchanges) > 0 {
+                trader = newExchangeRouterTrader(config, trader, routeExchanges)
         }
 
         // 验证初始金额配置
@@ -252,6 +290,115 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
                 }
         }
 
+        // 事件驱动触发器（价格波动、资金费率反转、OI异动、高影响力新闻），交易员设置覆盖系统配置
+        var triggerCfg TriggerConfig
+        if config.Triggers != nil {
+                triggerCfg = *config.Triggers
+        } else {
+                var triggerSettings string
+                if config.Database != nil && config.ID != "" {
+                        settings, err := config.Database.GetTraderTriggerSettings(config.ID)
+                        if err != nil {
+                                log.Printf("⚠️ [%s] 读取触发器设置失败: %v", config.Name, err)
+                        }
+                        triggerSettings = settings
+                }
+                triggerCfg = LoadTriggerConfig(config.Database, triggerSettings)
+        }
+        triggerCfg.UserID = config.UserID
+        var triggerManager *TriggerManager
+        if triggerCfg.Enabled {
+                triggerManager = NewTriggerManager(config.Name, triggerCfg, signalProvider)
+        }
+
+        // 订单管理器：恢复未完结订单，启动后继续对账
+        var orderStore OrderStore
+        if config.Database != nil {
+                orderStore = config.Database
+        }
+        orderManager := NewOrderManager(config.ID, config.Exchange, trader, orderStore)
+        if err := orderManager.Load(); err != nil {
+                log.Printf("⚠️ [%s] %v", config.Name, err)
+        }
+
+        // 开仓执行方式（市价/中间价限价/只做Maker/TWAP）
+        executionMode := config.ExecutionMode
+        if executionMode == "" && config.Database != nil && config.ID != "" {
+                mode, err := config.Database.GetTraderExecutionMode(config.ID)
+                if err != nil {
+                        log.Printf("⚠️ [%s] 读取执行方式失败: %v", config.Name, err)
+                }
+                executionMode = mode
+        }
+        execution := LoadExecutionConfig(config.Database, executionMode)
+        if execution.Mode != decision.ExecutionMarket {
+                log.Printf("🧾 [%s] 开仓执行方式: %s", config.Name, execution.Mode)
+        }
+
+        // 开仓前风控闸门：亏损断路器（状态持久化，重启后恢复），可选学习阶段约束
+        riskCfg := LoadRiskGateConfig(config.Database, config.MaxDailyLoss, config.MaxDrawdown)
+        var lossEventStore LossEventStore
+        if config.Database != nil {
+                lossEventStore = config.Database
+        }
+        breaker := NewLossCircuitBreaker(config.ID, config.UserID, lossEventStore)
+        breaker.SetLimits(riskCfg.MaxConsecutiveLosses, riskCfg.MaxDailyLossPct, riskCfg.MaxWeeklyLossPct, riskCfg.MaxDrawdownPct)
+        if err := breaker.Load(); err != nil {
+                log.Printf("⚠️ [%s] %v", config.Name, err)
+        }
+        riskGates := []RiskGate{breaker}
+        if riskCfg.ConstraintsEnabled {
+                riskGates = append(riskGates, NewConstraintsManager())
+                log.Printf("🚦 [%s] 已启用学习阶段约束", config.Name)
+        }
+
+        // 跟踪止损：持仓峰值持久化，重启后继续按高水位跟踪
+        var peakStore PositionPeakStore
+        if config.Database != nil {
+                peakStore = config.Database
+        }
+        trailingStops := NewTrailingStopManager(config.ID, LoadTrailingStopConfig(config.Database), peakStore)
+        if err := trailingStops.Load(); err != nil {
+                log.Printf("⚠️ [%s] %v", config.Name, err)
+        }
+
+        // 多腿组合持仓：重启后恢复组合，继续按合计盈亏止损止盈
+        var groupStore PositionGroupStore
+        if config.Database != nil {
+                groupStore = config.Database
+        }
+        positionGroups := NewPositionGroupManager(config.ID, groupStore)
+        if err := positionGroups.Load(); err != nil {
+                log.Printf("⚠️ [%s] %v", config.Name, err)
+        }
+
+        // 仓位计算：AI只决定方向和信心度，仓位由单笔风险预算、ATR、凯利比例和币种配置核定
+        riskPerTradePct := config.RiskPerTradePct
+        if riskPerTradePct <= 0 && config.Database != nil && config.ID != "" {
+                pct, err := config.Database.GetTraderRiskPerTrade(config.ID)
+                if err != nil {
+                        log.Printf("⚠️ [%s] 读取单笔风险预算失败: %v", config.Name, err)
+                }
+                riskPerTradePct = pct
+        }
+        sizing := NewSizingEngine(LoadSizingConfig(config.Database, riskPerTradePct), symbolConfigManager, kellyManager)
+
+        // 交易时段：时段外和停牌事件（FOMC、CPI等）前后只管理现有持仓
+        schedule, err := ParseTradingSchedule(config.TradingSchedule)
+        if err != nil {
+                log.Printf("⚠️ [%s] %v，不限制交易时段", config.Name, err)
+        }
+        scheduler := NewTradingScheduler(LoadScheduleConfig(config.Database), schedule)
+
+        // 交易记忆：相似行情下的历史决策和结果注入prompt（mem0.enabled标志对该用户关闭时为nil）
+        memory := newTradeMemory(config)
+
+        // 手续费/资金费账单同步（用于区分毛盈亏和净盈亏）
+        var ledgerStore LedgerStore
+        if config.Database != nil {
+                ledgerStore = config.Database
+        }
+
         return &AutoTrader{
                 id:                    config.ID,
                 userID:                config.UserID,
@@ -260,7 +407,7 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
                 exchange:              config.Exchange,
                 config:                config,
                 trader:                trader,
-                mcpClient:             mcpClient,
+                aiClient:              decisionClient,
                 decisionLogger:        decisionLogger,
                                 kellyManager:         kellyManager,
                                 symbolConfigManager:   symbolConfigManager,
@@ -276,9 +423,199 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
                 callCount:             persistedCallCount,
                 isRunning:             false,
                 positionFirstSeenTime: make(map[string]int64),
+                triggerManager:        triggerManager,
+                orderManager:          orderManager,
+                execution:             execution,
+                riskGates:             riskGates,
+                trailingStops:         trailingStops,
+                lifecycle:             NewPositionLifecycleTracker(config.ID, trader),
+                ledgerStore:           ledgerStore,
+                sizing:                sizing,
+                liquidationGuard:      NewLiquidationGuard(config.Name, LoadLiquidationGuardConfig(config.Database), trader),
+                positionGroups:        positionGroups,
+                scheduler:             scheduler,
+                memory:                memory,
+                memoryInitAt:          time.Now(),
+                experiments:           newExperimentService(config),
         }, nil
 }
 
+// exchangeFeatureFlags 需要功能标志放行的交易所（新接入的交易所按用户灰度开放）
+var exchangeFeatureFlags = map[string]config.FeatureFlagType{
+        "okx":   config.ExchangeOKXEnabled,
+        "paper": config.ExchangePaperEnabled,
+}
+
+// checkExchangeFlag 检查交易所是否对用户开放（不受功能标志控制的交易所直接放行）
+func checkExchangeFlag(exchange, userID string) error {
+        flag, ok := exchangeFeatureFlags[exchange]
+        if !ok || config.GetGlobalFeatureFlags().IsEnabledForUser(flag, userID) {
+                return nil
+        }
+        return fmt.Errorf("交易平台 %s 未对当前用户开放（功能标志 %s）", exchange, flag)
+}
+
+// newExchangeTrader 根据配置创建对应交易所的交易器
+func newExchangeTrader(config AutoTraderConfig) (Trader, error) {
+        if err := checkExchangeFlag(config.Exchange, config.UserID); err != nil {
+                return nil, err
+        }
+
+        switch config.Exchange {
+        case "binance":
+                log.Printf("🏦 [%s] 使用币安合约交易", config.Name)
+                return NewFuturesTrader(config.BinanceAPIKey, config.BinanceSecretKey), nil
+        case "hyperliquid":
+                log.Printf("🏦 [%s] 使用Hyperliquid交易", config.Name)
+                trader, err := NewHyperliquidTrader(config.HyperliquidPrivateKey, config.HyperliquidWalletAddr, config.HyperliquidTestnet)
+                if err != nil {
+                        return nil, fmt.Errorf("初始化Hyperliquid交易器失败: %w", err)
+                }
+                return trader, nil
+        case "aster":
+                log.Printf("🏦 [%s] 使用Aster交易", config.Name)
+                trader, err := NewAsterTrader(config.AsterUser, config.AsterSigner, config.AsterPrivateKey)
+                if err != nil {
+                        return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
+                }
+                return trader, nil
+        case "okx":
+                log.Printf("🏦 [%s] 使用OKX交易", config.Name)
+                trader, err := NewOKXTrader(config.OKXAPIKey, config.OKXSecretKey, config.OKXPassphrase, config.OKXTestnet)
+                if err != nil {
+                        return nil, fmt.Errorf("初始化OKX交易器失败: %w", err)
+                }
+                return trader, nil
+        case "paper":
+                if config.InitialBalance <= 0 {
+                        return nil, fmt.Errorf("纸面交易需要设置初始金额")
+                }
+                log.Printf("📄 [%s] 使用纸面交易（实时行情模拟成交，初始资金 %.2f USDT）", config.Name, config.InitialBalance)
+                return NewPaperTrader(config.ID, config.InitialBalance, config.Database), nil
+        default:
+                return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
+        }
+}
+
+// aiProviderConfig 根据交易员配置生成主模型的提供商配置
+func aiProviderConfig(config AutoTraderConfig) ai.ProviderConfig {
+        provider := strings.ToLower(config.AIModel)
+        if config.UseQwen {
+                provider = "qwen"
+        }
+
+        apiKey := config.CustomAPIKey
+        switch provider {
+        case "qwen":
+                apiKey = config.QwenKey
+        case "deepseek":
+                apiKey = config.DeepSeekKey
+        }
+
+        return ai.ProviderConfig{
+                Provider: provider,
+                APIKey:   apiKey,
+                BaseURL:  config.CustomAPIURL,
+                Model:    config.CustomModelName,
+        }
+}
+
+// fallbackProviderConfig 读取备用模型配置（system_config.ai_fallback_model 为用户的AI模型ID）
+// 未配置或模型不可用时返回空配置
+func fallbackProviderConfig(config AutoTraderConfig) ai.ProviderConfig {
+        if config.Database == nil {
+                return ai.ProviderConfig{}
+        }
+        modelID, err := config.Database.GetSystemConfig("ai_fallback_model")
+        if err != nil || modelID == "" {
+                return ai.ProviderConfig{}
+        }
+
+        cfg, err := userModelProviderConfig(config, modelID)
+        if err != nil {
+                log.Printf("⚠️ [%s] 备用AI模型不可用: %v", config.Name, err)
+                return ai.ProviderConfig{}
+        }
+        if cfg.Provider == strings.ToLower(config.AIModel) {
+                return ai.ProviderConfig{}
+        }
+        return cfg
+}
+
+// userModelProviderConfig 根据用户的AI模型ID生成提供商配置
+func userModelProviderConfig(config AutoTraderConfig, modelID string) (ai.ProviderConfig, error) {
+        models, err := config.Database.GetAIModels(config.UserID)
+        if err != nil {
+                return ai.ProviderConfig{}, fmt.Errorf("加载AI模型失败: %w", err)
+        }
+        for _, model := range models {
+                if model.ID != modelID {
+                        continue
+                }
+                if !model.Enabled {
+                        return ai.ProviderConfig{}, fmt.Errorf("AI模型 %s 未启用", modelID)
+                }
+                apiKey, baseURL := model.APIKey, model.CustomAPIURL
+                if model.APIKey == "platform_managed" {
+                        apiKey, _ = config.Database.GetSystemConfig(model.Provider + "_api_key")
+                        if url, _ := config.Database.GetSystemConfig(model.Provider + "_api_url"); url != "" {
+                                baseURL = url
+                        }
+                }
+                return ai.ProviderConfig{
+                        Provider: model.Provider,
+                        APIKey:   apiKey,
+                        BaseURL:  baseURL,
+                        Model:    model.CustomModelName,
+                }, nil
+        }
+        return ai.ProviderConfig{}, fmt.Errorf("AI模型 %s 不存在", modelID)
+}
+
+// buildEnsembleClient 根据集成配置创建多模型投票客户端（未配置集成模型时返回nil）
+func buildEnsembleClient(config AutoTraderConfig, primaryCfg ai.ProviderConfig, primary decision.AIClient) (*decision.EnsembleClient, error) {
+        modelIDs, policyName := config.EnsembleModelIDs, config.EnsemblePolicy
+        if len(modelIDs) == 0 && config.Database != nil && config.ID != "" {
+                ids, policy, err := config.Database.GetTraderEnsemble(config.ID)
+                if err != nil {
+                        log.Printf("⚠️ [%s] 读取集成配置失败: %v", config.Name, err)
+                }
+                modelIDs, policyName = ids, policy
+        }
+        if len(modelIDs) == 0 {
+                return nil, nil
+        }
+        if config.Database == nil {
+                return nil, fmt.Errorf("集成模型需要数据库配置")
+        }
+
+        policy, err := decision.ParseEnsemblePolicy(policyName)
+        if err != nil {
+                return nil, err
+        }
+
+        members := []decision.EnsembleMember{{Name: primaryCfg.Provider, Client: primary}}
+        for _, id := range modelIDs {
+                cfg, err := userModelProviderConfig(config, id)
+                if err != nil {
+                        return nil, err
+                }
+                cfg.ToolCalling = primaryCfg.ToolCalling
+                model, err := ai.DefaultRegistry.Create(cfg)
+                if err != nil {
+                        return nil, fmt.Errorf("创建集成模型 %s 失败: %w", id, err)
+                }
+                members = append(members, decision.EnsembleMember{Name: id, Client: ai.NewDecisionClient(model)})
+        }
+
+        names := make([]string, len(members))
+        for i, m := range members {
+                names[i] = m.Name
+        }
+        log.Printf("🗳️ [%s] 多模型投票决策 (%s): %s", config.Name, policy, strings.Join(names, " + "))
+        return decision.NewEnsembleClient(policy, members...), nil
+}
+
 // Run 运行自动交易主循环
 func (at *AutoTrader) Run() error {
         at.isRunning = true
@@ -301,7 +638,29 @@ func (at *AutoTrader) Run() error {
         ticker := time.NewTicker(at.config.ScanInterval)
         defer ticker.Stop()
 
+        // 事件驱动触发器：在定时周期之外按事件提前执行决策
+        var triggerC <-chan []TriggerEvent
+        if at.triggerManager != nil {
+                at.triggerManager.Start()
+                defer at.triggerManager.Stop()
+                triggerC = at.triggerManager.C()
+        }
+
+        // 纸面交易等交易器的后台任务随交易员启动，Stop时停止
+        if bg, ok := at.trader.(BackgroundTrader); ok {
+                bg.Start()
+        }
+
+        // 后台订单对账：及时发现部分成交、拒单和被撤销的止损止盈单
+        at.orderManager.Start(orderReconcileInterval)
+        defer at.orderManager.Stop()
+
+        // 强平距离保护：决策周期之间持仓接近强平价时补设止损或部分平仓
+        at.liquidationGuard.Start(at.handleLiquidationRisk)
+        defer at.liquidationGuard.Stop()
+
         // 首次立即执行
+        at.cycleTrigger = []TriggerEvent{{Type: TriggerStartup, Time: time.Now()}}
         cycleStartTime := time.Now()
         log.Printf("⏱️  周期 #%d 开始执行 (首次立即执行)", at.callCount+1)
         if err := at.runCycle(); err != nil {
@@ -317,8 +676,25 @@ func (at *AutoTrader) Run() error {
 
         for at.isRunning {
                 select {
+                case events := <-triggerC:
+                        if !at.isRunning {
+                                continue
+                        }
+                        cycleStartTime := time.Now()
+                        at.cycleTrigger = events
+                        log.Printf("⏱️  周期 #%d 开始执行 (事件驱动: %s)", at.callCount+1, describeTriggers(events))
+
+                        if err := at.runCycle(); err != nil {
+                                log.Printf("❌ 周期 #%d 执行失败: %v", at.callCount, err)
+                        } else {
+                                log.Printf("✅ 周期 #%d 执行完成，耗时: %v", at.callCount, time.Since(cycleStartTime))
+                        }
+                        // 事件触发的周期之后重新计时，避免紧接着再执行一次定时周期
+                        ticker.Reset(at.config.ScanInterval)
+
                 case <-ticker.C:
                         cycleStartTime := time.Now()
+                        at.cycleTrigger = []TriggerEvent{{Type: TriggerScheduled, Time: cycleStartTime}}
                         log.Printf("⏱️  周期 #%d 开始执行 (Ticker驱动)", at.callCount+1)
 
                         if err := at.runCycle(); err != nil {
@@ -340,6 +716,14 @@ func (at *AutoTrader) Run() error {
 // Stop 停止自动交易
 func (at *AutoTrader) Stop() {
         at.isRunning = false
+        if at.triggerManager != nil {
+                at.triggerManager.Stop()
+        }
+        at.orderManager.Stop()
+        at.liquidationGuard.Stop()
+        if bg, ok := at.trader.(BackgroundTrader); ok {
+                bg.Close()
+        }
         log.Println("⏹ 自动交易系统停止")
 }
 
@@ -364,6 +748,16 @@ func (at *AutoTrader) runCycle() error {
                 ExecutionLog: []string{},
                 Success:      true,
         }
+        if len(at.cycleTrigger) > 0 {
+                record.Trigger = at.cycleTrigger[0].Type
+                for _, ev := range at.cycleTrigger {
+                        record.TriggerEvents = append(record.TriggerEvents, ev.String())
+                }
+                at.cycleTrigger = nil
+        }
+        if at.triggerManager != nil {
+                at.triggerManager.MarkCycle(time.Now())
+        }
 
         // 0. 积分消耗检查 (TopTrader专属)
         if at.name == "TopTrader" && at.creditService != nil && at.db != nil {
@@ -422,6 +816,19 @@ func (at *AutoTrader) runCycle() error {
                 return fmt.Errorf("构建交易上下文失败: %w", err)
         }
 
+        // 订单对账：将上个周期以来的部分成交、拒单、止损止盈被撤销等情况告知AI
+        at.orderManager.Reconcile()
+        ctx.OrderNotices = at.orderManager.DrainNotices()
+        record.OrderNotices = ctx.OrderNotices
+
+        // 风控闸门按本周期净值和持仓更新日/周盈亏、回撤和组合敞口
+        at.notifyRiskCycle(ctx)
+
+        // 持仓生命周期：识别上个周期以来的开平仓（含交易所侧止损止盈、强平），写入交易记录
+        at.trackPositionLifecycle()
+        at.syncLedger()
+        fees := at.GetLedgerSummary()
+
         // 保存账户状态快照
         record.AccountState = logger.AccountSnapshot{
                 TotalBalance:          ctx.Account.TotalEquity,
@@ -429,6 +836,8 @@ func (at *AutoTrader) runCycle() error {
                 TotalUnrealizedProfit: ctx.Account.TotalPnL,
                 PositionCount:         ctx.Account.PositionCount,
                 MarginUsedPct:         ctx.Account.MarginUsedPct,
+                TotalFees:             fees.Fees,
+                TotalFunding:          fees.Funding,
         }
 
         // 保存持仓快照
@@ -453,15 +862,45 @@ func (at *AutoTrader) runCycle() error {
         log.Printf("📊 账户净值: %.2f USDT | 可用: %.2f USDT | 持仓: %d",
                 ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
-        // 4. 调用AI获取完整决策
-        log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
-        decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+        // 交易时段：时段外和停牌事件前后只管理现有持仓，不开新仓（可选在事件前平掉所有持仓）
+        schedule := at.scheduler.Status(time.Now())
+        if !schedule.AllowEntries {
+                log.Printf("⏸ [%s] %s，本周期只管理现有持仓", at.name, schedule.Reason)
+                record.ExecutionLog = append(record.ExecutionLog, "⏸ "+schedule.Reason)
+                ctx.EntryRestriction = schedule.Reason
+                if schedule.Flatten {
+                        at.execMu.Lock()
+                        at.flattenBeforeEvent(*schedule.Event, ctx, record)
+                        at.execMu.Unlock()
+                }
+                if len(ctx.Positions) == 0 || schedule.Flatten {
+                        log.Printf("⏸ [%s] 没有需要管理的持仓，跳过AI决策", at.name)
+                        if err := at.decisionLogger.LogDecision(record); err != nil {
+                                log.Printf("⚠ 保存决策记录失败: %v", err)
+                        }
+                        return nil
+                }
+        }
+
+        // 4. 调用AI获取完整决策（参与A/B实验时使用分配到的变体）
+        setup := at.resolveCycleSetup(ctx, record)
+        log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", setup.promptTemplate)
+        decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, setup.client, setup.customPrompt, setup.overrideBase, setup.promptTemplate)
 
         // 即使有错误，也保存思维链、决策和输入prompt（用于debug）
         if decision != nil {
                 record.SystemPrompt = decision.SystemPrompt // 保存系统提示词
                 record.InputPrompt = decision.UserPrompt
                 record.CoTTrace = decision.CoTTrace
+                record.RawResponse = decision.RawResponse
+                record.DecisionMode = decision.DecisionMode
+                for _, d := range decision.Disagreements {
+                        disagreement := logger.ModelDisagreement{Symbol: d.Symbol, Outcome: d.Outcome}
+                        for _, v := range d.Votes {
+                                disagreement.Votes = append(disagreement.Votes, logger.ModelVote{Model: v.Model, Action: v.Action, Confidence: v.Confidence})
+                        }
+                        record.Disagreements = append(record.Disagreements, disagreement)
+                }
                 if len(decision.Decisions) > 0 {
                         decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
                         record.DecisionJSON = string(decisionJSON)
@@ -476,7 +915,7 @@ func (at *AutoTrader) runCycle() error {
                 if decision != nil {
                         if decision.SystemPrompt != "" {
                                 log.Println(strings.Repeat("=", 70))
-                                log.Printf("📋 系统提示词 [模板: %s] (错误情况)", at.systemPromptTemplate)
+                                log.Printf("📋 系统提示词 [模板: %s] (错误情况)", setup.promptTemplate)
                                 log.Println(strings.Repeat("=", 70))
                                 log.Println(decision.SystemPrompt)
                                 log.Println(strings.Repeat("=", 70))
@@ -529,7 +968,12 @@ func (at *AutoTrader) runCycle() error {
         }
         log.Println()
 
-        // 执行决策并记录结果
+        // 下单阶段与强平保护互斥（构建上下文和等待AI决策期间强平保护照常执行）
+        at.execMu.Lock()
+
+        // 执行决策并记录结果（开仓决策先经过风控闸门）
+        positionCount := ctx.Account.PositionCount
+        decisionStart := len(record.Decisions)
         for _, d := range sortedDecisions {
                 actionRecord := logger.DecisionAction{
                         Action:    d.Action,
@@ -541,6 +985,59 @@ func (at *AutoTrader) runCycle() error {
                         Success:   false,
                 }
 
+                isOpen := d.Action == "open_long" || d.Action == "open_short"
+                if (isOpen || d.Action == "open_pair") && !schedule.AllowEntries {
+                        log.Printf("⏸ 交易时段拦截 (%s %s): %s", d.Symbol, d.Action, schedule.Reason)
+                        actionRecord.Error = schedule.Reason
+                        record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⏸ %s %s 被交易时段拦截: %s", d.Symbol, d.Action, schedule.Reason))
+                        record.Decisions = append(record.Decisions, actionRecord)
+                        continue
+                }
+                var check RiskCheck
+                if isOpen {
+                        at.applySizing(&d, ctx, &actionRecord, record)
+                        check = at.riskCheck(&d, ctx, positionCount)
+                        ok, reason := at.checkRiskGates(check)
+                        if ok {
+                                // 超出剩余额度时缩减仓位，额度不足时拦截
+                                if size, why := at.limitRiskSize(check); size < d.PositionSizeUSD {
+                                        if size <= 0 {
+                                                ok, reason = false, why
+                                        } else {
+                                                log.Printf("📉 风控缩减仓位 (%s %s): %.2f → %.2f USDT, %s", d.Symbol, d.Action, d.PositionSizeUSD, size, why)
+                                                record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("📉 %s %s 仓位由 %.2f 缩减至 %.2f USDT: %s", d.Symbol, d.Action, d.PositionSizeUSD, size, why))
+                                                d.PositionSizeUSD = size
+                                                check.PositionSizeUSD = size
+                                        }
+                                }
+                        }
+                        if !ok {
+                                log.Printf("⛔ 风控拦截 (%s %s): %s", d.Symbol, d.Action, reason)
+                                actionRecord.Error = reason
+                                record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⛔ %s %s 被风控拦截: %s", d.Symbol, d.Action, reason))
+                                record.Decisions = append(record.Decisions, actionRecord)
+                                continue
+                        }
+                        actionRecord.PositionSizeUSD = d.PositionSizeUSD
+                }
+
+                // 组合开仓按各腿分别检查风控，任一腿被拦截则整个组合拦截
+                var pairChecks []RiskCheck
+                if d.Action == "open_pair" {
+                        actionRecord.RequestedSizeUSD = d.PositionSizeUSD
+                        var ok bool
+                        var reason string
+                        pairChecks, ok, reason = at.pairRiskChecks(&d, ctx, positionCount, record)
+                        if !ok {
+                                log.Printf("⛔ 风控拦截 (%s %s): %s", d.Symbol, d.Action, reason)
+                                actionRecord.Error = reason
+                                record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⛔ %s %s 被风控拦截: %s", d.Symbol, d.Action, reason))
+                                record.Decisions = append(record.Decisions, actionRecord)
+                                continue
+                        }
+                        actionRecord.PositionSizeUSD = d.PositionSizeUSD
+                }
+
                 if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
                         log.Printf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
                         actionRecord.Error = err.Error()
@@ -548,6 +1045,22 @@ func (at *AutoTrader) runCycle() error {
                 } else {
                         actionRecord.Success = true
                         record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+                        switch {
+                        case isOpen:
+                                positionCount++
+                                at.notifyRiskTradeOpened(check)
+                        case d.Action == "close_long" || d.Action == "close_short":
+                                positionCount--
+                                at.notifyRiskTradeClosed(&d, ctx)
+                        case d.Action == "open_pair":
+                                positionCount += len(pairChecks)
+                                for _, c := range pairChecks {
+                                        at.notifyRiskTradeOpened(c)
+                                }
+                        case d.Action == "close_pair":
+                                positionCount -= len(actionRecord.Legs)
+                                at.notifyPairClosed(&actionRecord, ctx)
+                        }
                         // 成功执行后短暂延迟
                         time.Sleep(1 * time.Second)
                 }
@@ -555,15 +1068,28 @@ func (at *AutoTrader) runCycle() error {
                 record.Decisions = append(record.Decisions, actionRecord)
         }
 
-        // 9. 检查并更新现有持仓的止盈止损单（使用凯利公式优化）
-        log.Println("🔄 开始执行凯利公式动态止盈止损检查...")
+        // 本周期成功执行的开平仓决策写入交易记忆（异步，不阻塞交易）
+        if memory := at.activeMemory(); memory != nil {
+                actions := append([]logger.DecisionAction(nil), record.Decisions[decisionStart:]...)
+                go memory.RecordDecisions(ctx, sortedDecisions, actions)
+        }
+
+        // 组合持仓按合计盈亏止损止盈（某条腿已平仓时平掉其余腿）
+        at.checkPositionGroups(ctx, record)
+
+        // 9. 检查并更新现有持仓的止盈止损单（保本止损 + 跟踪止损）
+        log.Println("🔄 开始执行跟踪止损检查...")
         if err := at.checkAndUpdateStopOrders(); err != nil {
                 log.Printf("⚠ 更新止盈止损单失败: %v", err)
                 record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠ 止盈止损更新失败: %v", err))
         } else {
-                log.Println("✅ 所有持仓的止盈止损单已更新（凯利公式优化）")
-                record.ExecutionLog = append(record.ExecutionLog, "✅ 止盈止损单已更新")
+                log.Println("✅ 所有持仓的止盈止损单已检查")
+                record.ExecutionLog = append(record.ExecutionLog, "✅ 止盈止损单已检查")
         }
+        at.execMu.Unlock()
+
+        // 更新触发器关注的币种（本周期开平仓后的持仓）
+        at.refreshTriggerWatch(ctx, record.Decisions)
 
         // 10. 保存决策记录
         if err := at.decisionLogger.LogDecision(record); err != nil {
@@ -581,28 +1107,10 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
                 return nil, fmt.Errorf("获取账户余额失败: %w", err)
         }
 
-        // 获取账户字段（使用OKXTrader返回的正确字段名）
-        totalWalletBalance := 0.0
-        totalUnrealizedProfit := 0.0
-        availableBalance := 0.0
-
-        // 从OKXTrader.GetBalance()返回的"total"字段获取总资产
-        if total, ok := balance["total"].(float64); ok {
-                totalWalletBalance = total
-                log.Printf("✓ 从OKX获取总资产: %.8f", total)
-        }
-        // 从OKXTrader.GetBalance()返回的"free"字段获取可用余额
-        if free, ok := balance["free"].(float64); ok {
-                availableBalance = free
-                log.Printf("✓ 从OKX获取可用余额: %.8f", free)
-        }
-        // 从OKXTrader.GetBalance()返回的"used"字段获取已用余额
-        if used, ok := balance["used"].(float64); ok {
-                log.Printf("✓ 从OKX获取已用余额: %.8f", used)
-        }
+        availableBalance := balance.AvailableBalance
 
         // Total Equity = 钱包余额 + 未实现盈亏
-        totalEquity := totalWalletBalance + totalUnrealizedProfit
+        totalEquity := balance.TotalEquity()
 
         // 2. 获取持仓信息
         positions, err := at.trader.GetPositions()
@@ -617,38 +1125,13 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
         currentPositionKeys := make(map[string]bool)
 
         for _, pos := range positions {
-                // 安全类型断言，避免nil值导致panic
-                symbol := ""
-                if s, ok := pos["symbol"].(string); ok {
-                        symbol = s
-                }
-                side := ""
-                if s, ok := pos["side"].(string); ok {
-                        side = s
-                }
-                entryPrice := 0.0
-                if ep, ok := pos["entryPrice"].(float64); ok {
-                        entryPrice = ep
-                }
-                markPrice := 0.0
-                if mp, ok := pos["markPrice"].(float64); ok {
-                        markPrice = mp
-                }
-                quantity := 0.0
-                if qty, ok := pos["positionAmt"].(float64); ok {
-                        quantity = qty
-                }
-                if quantity < 0 {
-                        quantity = -quantity // 空仓数量为负，转为正数
-                }
-                unrealizedPnl := 0.0
-                if upl, ok := pos["unRealizedProfit"].(float64); ok {
-                        unrealizedPnl = upl
-                }
-                liquidationPrice := 0.0
-                if lp, ok := pos["liquidationPrice"].(float64); ok {
-                        liquidationPrice = lp
-                }
+                symbol := pos.Symbol
+                side := pos.Side
+                entryPrice := pos.EntryPrice
+                markPrice := pos.MarkPrice
+                quantity := pos.Quantity
+                unrealizedPnl := pos.UnrealizedProfit
+                liquidationPrice := pos.LiquidationPrice
 
                 // 跳过无效持仓数据
                 if symbol == "" || side == "" || markPrice == 0 {
@@ -665,12 +1148,12 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
                         }
                 }
 
-                // 计算占用保证金（估算）
-                leverage := 10 // 默认值，实际应该从持仓信息获取
-                if lev, ok := pos["leverage"].(float64); ok {
-                        leverage = int(lev)
+                // 计算占用保证金（估算，杠杆未知时按10倍）
+                leverage := pos.Leverage
+                if leverage <= 0 {
+                        leverage = 10
                 }
-                marginUsed := (quantity * markPrice) / float64(leverage)
+                marginUsed := pos.MarginUsed()
                 totalMarginUsed += marginUsed
 
                 // 跟踪持仓首次出现时间
@@ -731,9 +1214,10 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
                 performance = nil
         }
 
-        // 6. 获取Mlion API Key用于新闻enrichment
+        // 6. 获取Mlion API Key用于新闻enrichment（news.decision_enrichment标志对该用户关闭时不注入新闻）
         mlionAPIKey := ""
-        if at.db != nil {
+        disableNews := !config.GetGlobalFeatureFlags().IsEnabledForUser(config.NewsDecisionEnrichment, at.userID)
+        if at.db != nil && !disableNews {
                 mlionAPIKey, _ = at.db.GetSystemConfig("mlion_api_key")
         }
 
@@ -769,12 +1253,24 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
                         PositionCount:    len(positionInfos),
                 },
                 Positions:       positionInfos,
+                PositionGroups:  at.positionGroupInfos(positions),
                 CandidateCoins:  candidateCoins,
                 OITopDataMap:    oiTopDataMap, // 注入OI Top数据
                 Performance:     performance, // 添加历史表现分析
                 LastCloseTime:   at.positionFirstSeenTime, // 平仓记录，用于冷却期检查
                 CooldownMinutes: 15, // 默认15分钟冷却期
                 MlionAPIKey:     mlionAPIKey, // Mlion新闻API密钥
+                DisableNews:     disableNews,
+        }
+
+        // 跨交易所路由时附带各交易所的余额
+        if router, ok := at.trader.(*ExchangeRouter); ok {
+                ctx.Account.Venues = router.VenueBalances()
+        }
+
+        // 相似历史交易检索（在决策引擎中与其他增强器一起执行）
+        if memory := at.activeMemory(); memory != nil {
+                ctx.Enrichers = append(ctx.Enrichers, memory.Enricher())
         }
 
         return ctx, nil
@@ -791,6 +1287,10 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
                 return at.executeCloseLongWithRecord(decision, actionRecord)
         case "close_short":
                 return at.executeCloseShortWithRecord(decision, actionRecord)
+        case "open_pair":
+                return at.executeOpenPairWithRecord(decision, actionRecord)
+        case "close_pair":
+                return at.executeClosePairWithRecord(decision, actionRecord)
         case "hold", "wait":
                 // 无需执行，仅记录
                 return nil
@@ -806,15 +1306,13 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
         // ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
         positions, err := at.trader.GetPositions()
         if err == nil {
-                for _, pos := range positions {
-                        if pos["symbol"] == decision.Symbol && pos["side"] == "long" {
-                                return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol)
-                        }
+                if _, exists := FindPosition(positions, decision.Symbol, "long"); exists {
+                        return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol)
                 }
         }
 
         // 获取当前价格
-        marketData, err := market.Get(decision.Symbol)
+        marketData, err := at.getMarketData(decision.Symbol)
         if err != nil {
                 return err
         }
@@ -823,10 +1321,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
         adjustedPositionSizeUSD := decision.PositionSizeUSD
         balance, balanceErr := at.trader.GetBalance()
         if balanceErr == nil {
-                availableBalance := 0.0
-                if free, ok := balance["free"].(float64); ok {
-                        availableBalance = free
-                }
+                availableBalance := balance.AvailableBalance
 
                 // 计算最大可开仓价值 = 可用保证金 * 80% * 杠杆
                 // 保留20%作为安全边际，防止价格波动导致保证金不足
@@ -873,27 +1368,29 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
         }
 
         // 开仓（将float64杠杆转为int，向下取整）
-        order, err := at.trader.OpenLong(decision.Symbol, quantity, int(decision.Leverage))
+        exec, err := at.orderManager.Open(decision.Symbol, "LONG", quantity, int(decision.Leverage), at.executionConfig(decision))
+        recordExecution(actionRecord, exec)
         if err != nil {
                 return err
         }
 
-        // 记录订单ID
-        if orderID, ok := order["orderId"].(int64); ok {
-                actionRecord.OrderID = orderID
-        }
+        log.Printf("  ✓ 开仓成功(%s)，订单ID: %s, 成交: %.4f/%.4f, 均价: %.4f", exec.Mode, exec.LastOrder().ExchangeOrderID, exec.FilledQty, quantity, exec.AvgPrice)
 
-        log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
+        // 部分成交时只为已成交数量设置止损止盈
+        if exec.FilledQty > 0 && exec.FilledQty < quantity {
+                log.Printf("  ⚠️ 订单部分成交: %.4f/%.4f", exec.FilledQty, quantity)
+                quantity = exec.FilledQty
+        }
 
         // 记录开仓时间
         posKey := decision.Symbol + "_long"
-        at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+        at.positionFirstSeenTime[posKey] = at.now().UnixMilli()
 
         // 设置止损止盈
-        if err := at.trader.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
+        if _, err := at.orderManager.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
                 log.Printf("  ⚠ 设置止损失败: %v", err)
         }
-        if err := at.trader.SetTakeProfit(decision.Symbol, "LONG", quantity, decision.TakeProfit); err != nil {
+        if _, err := at.orderManager.SetTakeProfit(decision.Symbol, "LONG", quantity, decision.TakeProfit); err != nil {
                 log.Printf("  ⚠ 设置止盈失败: %v", err)
         }
 
@@ -907,15 +1404,13 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
         // ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
         positions, err := at.trader.GetPositions()
         if err == nil {
-                for _, pos := range positions {
-                        if pos["symbol"] == decision.Symbol && pos["side"] == "short" {
-                                return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol)
-                        }
+                if _, exists := FindPosition(positions, decision.Symbol, "short"); exists {
+                        return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol)
                 }
         }
 
         // 获取当前价格
-        marketData, err := market.Get(decision.Symbol)
+        marketData, err := at.getMarketData(decision.Symbol)
         if err != nil {
                 return err
         }
@@ -924,10 +1419,7 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
         adjustedPositionSizeUSD := decision.PositionSizeUSD
         balance, balanceErr := at.trader.GetBalance()
         if balanceErr == nil {
-                availableBalance := 0.0
-                if free, ok := balance["free"].(float64); ok {
-                        availableBalance = free
-                }
+                availableBalance := balance.AvailableBalance
 
                 // 计算最大可开仓价值 = 可用保证金 * 80% * 杠杆
                 // 保留20%作为安全边际，防止价格波动导致保证金不足
@@ -974,27 +1466,29 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
         }
 
         // 开仓（将float64杠杆转为int，向下取整）
-        order, err := at.trader.OpenShort(decision.Symbol, quantity, int(decision.Leverage))
+        exec, err := at.orderManager.Open(decision.Symbol, "SHORT", quantity, int(decision.Leverage), at.executionConfig(decision))
+        recordExecution(actionRecord, exec)
         if err != nil {
                 return err
         }
 
-        // 记录订单ID
-        if orderID, ok := order["orderId"].(int64); ok {
-                actionRecord.OrderID = orderID
-        }
+        log.Printf("  ✓ 开仓成功(%s)，订单ID: %s, 成交: %.4f/%.4f, 均价: %.4f", exec.Mode, exec.LastOrder().ExchangeOrderID, exec.FilledQty, quantity, exec.AvgPrice)
 
-        log.Printf("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
+        // 部分成交时只为已成交数量设置止损止盈
+        if exec.FilledQty > 0 && exec.FilledQty < quantity {
+                log.Printf("  ⚠️ 订单部分成交: %.4f/%.4f", exec.FilledQty, quantity)
+                quantity = exec.FilledQty
+        }
 
         // 记录开仓时间
         posKey := decision.Symbol + "_short"
-        at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
+        at.positionFirstSeenTime[posKey] = at.now().UnixMilli()
 
         // 设置止损止盈
-        if err := at.trader.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
+        if _, err := at.orderManager.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
                 log.Printf("  ⚠ 设置止损失败: %v", err)
         }
-        if err := at.trader.SetTakeProfit(decision.Symbol, "SHORT", quantity, decision.TakeProfit); err != nil {
+        if _, err := at.orderManager.SetTakeProfit(decision.Symbol, "SHORT", quantity, decision.TakeProfit); err != nil {
                 log.Printf("  ⚠ 设置止盈失败: %v", err)
         }
 
@@ -1007,55 +1501,44 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 
         // 记录平仓前持仓信息（用于计算盈利）
         positions, err := at.trader.GetPositions()
-        if err == nil {
-                for _, pos := range positions {
-                        if pos["symbol"] == decision.Symbol && pos["side"] == "long" {
-                                // 获取持仓详情
-                                entryPrice, _ := pos["entryPrice"].(float64)
-                                markPrice, _ := pos["markPrice"].(float64)
-                                unrealizedPnl, _ := pos["unRealizedProfit"].(float64)
-
-                                // 计算盈亏百分比
-                                profitPct := 0.0
-                                if entryPrice > 0 {
-                                        profitPct = (markPrice - entryPrice) / entryPrice
-                                }
-
-                                // 记录交易结果（延迟到平仓成功后执行）
-                                defer func(symbol string, isWin bool, profit float64) {
-                                        at.recordTradeResult(symbol, isWin, profit)
-                                }(decision.Symbol, profitPct >= 0, profitPct*100)
+        if pos, ok := FindPosition(positions, decision.Symbol, "long"); err == nil && ok {
+                // 获取持仓详情
+                entryPrice := pos.EntryPrice
+                markPrice := pos.MarkPrice
+                unrealizedPnl := pos.UnrealizedProfit
 
-                                log.Printf("  📊 平仓前: 入场价=%.6f, 当前价=%.6f, 未实现盈亏=%.2f, 盈亏比例=%.2f%%",
-                                        entryPrice, markPrice, unrealizedPnl, profitPct*100)
-                                break
-                        }
+                // 计算盈亏百分比
+                profitPct := 0.0
+                if entryPrice > 0 {
+                        profitPct = (markPrice - entryPrice) / entryPrice
                 }
+
+                log.Printf("  📊 平仓前: 入场价=%.6f, 当前价=%.6f, 未实现盈亏=%.2f, 盈亏比例=%.2f%%",
+                        entryPrice, markPrice, unrealizedPnl, profitPct*100)
         }
 
         // 获取当前价格
-        marketData, err := market.Get(decision.Symbol)
+        marketData, err := at.getMarketData(decision.Symbol)
         if err != nil {
                 return err
         }
         actionRecord.Price = marketData.CurrentPrice
 
         // 平仓
-        order, err := at.trader.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
+        order, err := at.orderManager.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
+        recordOrder(actionRecord, order)
         if err != nil {
                 return err
         }
 
-        // 记录订单ID
-        if orderID, ok := order["orderId"].(int64); ok {
-                actionRecord.OrderID = orderID
-        }
-
         log.Printf("  ✓ 平多仓成功")
 
+        // 交易结果由持仓生命周期跟踪按实际成交记录（凯利统计、交易记录）
+        at.lifecycle.MarkClosing(decision.Symbol, "long", "decision")
+
         // 记录平仓时间（用于冷却期检查）
         closeKey := decision.Symbol + "|close_long"
-        at.positionFirstSeenTime[closeKey] = time.Now().UnixMilli()
+        at.positionFirstSeenTime[closeKey] = at.now().UnixMilli()
 
         return nil
 }
@@ -1066,59 +1549,108 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 
         // 记录平仓前持仓信息（用于计算盈利）
         positions, err := at.trader.GetPositions()
-        if err == nil {
-                for _, pos := range positions {
-                        if pos["symbol"] == decision.Symbol && pos["side"] == "short" {
-                                // 获取持仓详情
-                                entryPrice, _ := pos["entryPrice"].(float64)
-                                markPrice, _ := pos["markPrice"].(float64)
-                                unrealizedPnl, _ := pos["unRealizedProfit"].(float64)
-
-                                // 计算盈亏百分比（空仓相反）
-                                profitPct := 0.0
-                                if entryPrice > 0 {
-                                        profitPct = (entryPrice - markPrice) / entryPrice
-                                }
-
-                                // 记录交易结果（延迟到平仓成功后执行）
-                                defer func(symbol string, isWin bool, profit float64) {
-                                        at.recordTradeResult(symbol, isWin, profit)
-                                }(decision.Symbol, profitPct >= 0, profitPct*100)
-
-                                log.Printf("  📊 平仓前: 入场价=%.6f, 当前价=%.6f, 未实现盈亏=%.2f, 盈亏比例=%.2f%%",
-                                        entryPrice, markPrice, unrealizedPnl, profitPct*100)
-                                break
-                        }
+        if pos, ok := FindPosition(positions, decision.Symbol, "short"); err == nil && ok {
+                // 获取持仓详情
+                entryPrice := pos.EntryPrice
+                markPrice := pos.MarkPrice
+                unrealizedPnl := pos.UnrealizedProfit
+
+                // 计算盈亏百分比（空仓相反）
+                profitPct := 0.0
+                if entryPrice > 0 {
+                        profitPct = (entryPrice - markPrice) / entryPrice
                 }
+
+                log.Printf("  📊 平仓前: 入场价=%.6f, 当前价=%.6f, 未实现盈亏=%.2f, 盈亏比例=%.2f%%",
+                        entryPrice, markPrice, unrealizedPnl, profitPct*100)
         }
 
         // 获取当前价格
-        marketData, err := market.Get(decision.Symbol)
+        marketData, err := at.getMarketData(decision.Symbol)
         if err != nil {
                 return err
         }
         actionRecord.Price = marketData.CurrentPrice
 
         // 平仓
-        order, err := at.trader.CloseShort(decision.Symbol, 0) // 0 = 全部平仓
+        order, err := at.orderManager.CloseShort(decision.Symbol, 0) // 0 = 全部平仓
+        recordOrder(actionRecord, order)
         if err != nil {
                 return err
         }
 
-        // 记录订单ID
-        if orderID, ok := order["orderId"].(int64); ok {
-                actionRecord.OrderID = orderID
-        }
-
         log.Printf("  ✓ 平空仓成功")
 
+        // 交易结果由持仓生命周期跟踪按实际成交记录（凯利统计、交易记录）
+        at.lifecycle.MarkClosing(decision.Symbol, "short", "decision")
+
         // 记录平仓时间（用于冷却期检查）
         closeKey := decision.Symbol + "|close_short"
-        at.positionFirstSeenTime[closeKey] = time.Now().UnixMilli()
+        at.positionFirstSeenTime[closeKey] = at.now().UnixMilli()
 
         return nil
 }
 
+// recordOrder 将订单信息写入决策动作记录
+func recordOrder(actionRecord *logger.DecisionAction, order *Order) {
+        if order == nil {
+                return
+        }
+        actionRecord.OrderID = order.NumericOrderID()
+        actionRecord.ClientOrderID = order.ClientOrderID
+        actionRecord.ExchangeOrderID = order.ExchangeOrderID
+        actionRecord.OrderStatus = string(order.Status)
+        actionRecord.Fee = order.Fee
+}
+
+// recordExecution 将开仓执行结果写入决策动作记录（订单ID取最后一笔子订单，价格为实际成交均价）
+func recordExecution(actionRecord *logger.DecisionAction, exec *Execution) {
+        if exec == nil {
+                return
+        }
+        recordOrder(actionRecord, exec.LastOrder())
+        actionRecord.ExecutionMode = string(exec.Mode)
+        actionRecord.Fee = exec.Fee
+        if exec.AvgPrice > 0 {
+                actionRecord.Price = exec.AvgPrice
+        }
+}
+
+// executionConfig 决策使用的执行配置（决策指定了执行方式时覆盖交易员配置）
+func (at *AutoTrader) executionConfig(d *decision.Decision) ExecutionConfig {
+        cfg := at.execution
+        if cfg.Mode == "" {
+                cfg = DefaultExecutionConfig()
+        }
+        if d.Execution != "" {
+                if mode, err := decision.ParseExecutionMode(d.Execution); err == nil {
+                        cfg.Mode = mode
+                }
+        }
+        return cfg
+}
+
+// GetOrders 获取订单列表（status为空时返回全部状态）
+func (at *AutoTrader) GetOrders(status string, limit int) ([]*Order, error) {
+        return at.orderManager.Orders(OrderStatus(status), limit)
+}
+
+// getMarketData 获取执行决策所需的行情数据
+func (at *AutoTrader) getMarketData(symbol string) (*market.Data, error) {
+        if at.marketDataProvider != nil {
+                return at.marketDataProvider(symbol)
+        }
+        return market.Get(symbol)
+}
+
+// now 当前时间（决策回放时为录制时间）
+func (at *AutoTrader) now() time.Time {
+        if at.clock != nil {
+                return at.clock()
+        }
+        return time.Now()
+}
+
 // GetID 获取trader ID
 func (at *AutoTrader) GetID() string {
         return at.id
@@ -1161,6 +1693,16 @@ func (at *AutoTrader) SetLeverage(btcEth, altcoin int) {
         log.Printf("✓ Trader %s: 杠杆已更新为 BTC/ETH=%dx, 山寨币=%dx", at.name, btcEth, altcoin)
 }
 
+// SetTradingSchedule 设置交易时段和停牌事件（nil表示不限制开仓）
+func (at *AutoTrader) SetTradingSchedule(schedule *TradingSchedule) {
+        if at.scheduler == nil {
+                at.scheduler = NewTradingScheduler(LoadScheduleConfig(at.db), schedule)
+                return
+        }
+        at.scheduler.SetSchedule(schedule)
+        log.Printf("✓ Trader %s: 交易时段已更新", at.name)
+}
+
 // GetSystemPromptTemplate 获取当前系统提示词模板名称
 func (at *AutoTrader) GetSystemPromptTemplate() string {
         return at.systemPromptTemplate
@@ -1178,7 +1720,7 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
                 aiProvider = "Qwen"
         }
 
-        return map[string]interface{}{
+        status := map[string]interface{}{
                 "trader_id":       at.id,
                 "trader_name":     at.name,
                 "ai_model":        at.aiModel,
@@ -1193,6 +1735,17 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
                 "last_reset_time": at.lastResetTime.Format(time.RFC3339),
                 "ai_provider":     aiProvider,
         }
+        if router, ok := at.trader.(*ExchangeRouter); ok {
+                status["routing"] = router.GetStatus()
+        }
+        if at.scheduler != nil {
+                now := time.Now()
+                schedule := at.scheduler.Status(now)
+                status["entries_allowed"] = schedule.AllowEntries
+                status["entry_restriction"] = schedule.Reason
+                status["upcoming_blackouts"] = at.scheduler.NextEvents(now, 3)
+        }
+        return status
 }
 
 // GetAccountInfo 获取账户信息（用于API）
@@ -1202,28 +1755,12 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
                 return nil, fmt.Errorf("获取余额失败: %w", err)
         }
 
-        // 获取账户字段（使用OKXTrader返回的正确字段名）
-        totalWalletBalance := 0.0
-        totalUnrealizedProfit := 0.0
-        availableBalance := 0.0
-
-        // 从OKXTrader.GetBalance()返回的"total"字段获取总资产
-        if total, ok := balance["total"].(float64); ok {
-                totalWalletBalance = total
-                log.Printf("✓ 从OKX获取总资产: %.8f", total)
-        }
-        // 从OKXTrader.GetBalance()返回的"free"字段获取可用余额
-        if free, ok := balance["free"].(float64); ok {
-                availableBalance = free
-                log.Printf("✓ 从OKX获取可用余额: %.8f", free)
-        }
-        // 从OKXTrader.GetBalance()返回的"used"字段获取已用余额
-        if used, ok := balance["used"].(float64); ok {
-                log.Printf("✓ 从OKX获取已用余额: %.8f", used)
-        }
+        totalWalletBalance := balance.TotalWalletBalance
+        totalUnrealizedProfit := balance.TotalUnrealizedProfit
+        availableBalance := balance.AvailableBalance
 
         // Total Equity = 钱包余额 + 未实现盈亏
-        totalEquity := totalWalletBalance + totalUnrealizedProfit
+        totalEquity := balance.TotalEquity()
 
         if totalWalletBalance > 0 {
                 log.Printf("✓ 账户余额映射成功: 总资产=%.2f, 可用=%.2f",
@@ -1239,38 +1776,14 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
         totalMarginUsed := 0.0
         totalUnrealizedPnL := 0.0
         for _, pos := range positions {
-                // 安全类型断言，避免nil值导致panic
-                markPrice := 0.0
-                if mp, ok := pos["markPrice"].(float64); ok {
-                        markPrice = mp
-                }
-
-                quantity := 0.0
-                if qty, ok := pos["positionAmt"].(float64); ok {
-                        quantity = qty
-                }
-                if quantity < 0 {
-                        quantity = -quantity
-                }
-
-                unrealizedPnl := 0.0
-                if upl, ok := pos["unRealizedProfit"].(float64); ok {
-                        unrealizedPnl = upl
-                }
-                totalUnrealizedPnL += unrealizedPnl
-
-                leverage := 10
-                if lev, ok := pos["leverage"].(float64); ok {
-                        leverage = int(lev)
-                }
+                totalUnrealizedPnL += pos.UnrealizedProfit
 
                 // 跳过无效持仓数据
-                if markPrice == 0 || quantity == 0 {
+                if pos.MarkPrice == 0 || pos.Quantity == 0 {
                         continue
                 }
 
-                marginUsed := (quantity * markPrice) / float64(leverage)
-                totalMarginUsed += marginUsed
+                totalMarginUsed += pos.MarginUsed()
         }
 
         totalPnL := totalEquity - at.initialBalance
@@ -1284,6 +1797,14 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
                 marginUsedPct = (totalMarginUsed / totalEquity) * 100
         }
 
+        // 净值变化已扣除手续费和资金费，加回后为毛盈亏
+        fees := at.GetLedgerSummary()
+        grossPnL := totalPnL + fees.Total()
+        grossPnLPct := 0.0
+        if at.initialBalance > 0 {
+                grossPnLPct = (grossPnL / at.initialBalance) * 100
+        }
+
         return map[string]interface{}{
                 // 核心字段
                 "total_equity":      totalEquity,           // 账户净值 = wallet + unrealized
@@ -1297,6 +1818,11 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
                 "total_unrealized_pnl": totalUnrealizedPnL, // 未实现盈亏（从持仓计算）
                 "initial_balance":      at.initialBalance,  // 初始余额
                 "daily_pnl":            at.dailyPnL,        // 日盈亏
+                "gross_pnl":            grossPnL,           // 毛盈亏（不含手续费和资金费）
+                "gross_pnl_pct":        grossPnLPct,        // 毛盈亏百分比
+                "net_pnl":              totalPnL,           // 净盈亏（与total_pnl相同）
+                "total_fees":           fees.Fees,          // 累计手续费
+                "total_funding":        fees.Funding,       // 累计资金费（正数为支付）
 
                 // 持仓信息
                 "position_count":  len(positions),  // 持仓数量
@@ -1314,51 +1840,19 @@ func (at *AutoTrader) GetPositions() ([]map[string]interface{}, error) {
 
         var result []map[string]interface{}
         for _, pos := range positions {
-                // 安全类型断言，避免nil值导致panic
-                symbol := ""
-                if s, ok := pos["symbol"].(string); ok {
-                        symbol = s
-                }
-                side := ""
-                if s, ok := pos["side"].(string); ok {
-                        side = s
-                }
-                entryPrice := 0.0
-                if ep, ok := pos["entryPrice"].(float64); ok {
-                        entryPrice = ep
-                }
-                markPrice := 0.0
-                if mp, ok := pos["markPrice"].(float64); ok {
-                        markPrice = mp
-                }
-                quantity := 0.0
-                if qty, ok := pos["positionAmt"].(float64); ok {
-                        quantity = qty
-                }
-                if quantity < 0 {
-                        quantity = -quantity
-                }
-                unrealizedPnl := 0.0
-                if upl, ok := pos["unRealizedProfit"].(float64); ok {
-                        unrealizedPnl = upl
-                }
-                liquidationPrice := 0.0
-                if lp, ok := pos["liquidationPrice"].(float64); ok {
-                        liquidationPrice = lp
-                }
-
                 // 跳过无效持仓数据
-                if symbol == "" || side == "" || markPrice == 0 {
+                if pos.Symbol == "" || pos.Side == "" || pos.MarkPrice == 0 {
                         continue
                 }
 
-                leverage := 10
-                if lev, ok := pos["leverage"].(float64); ok {
-                        leverage = int(lev)
+                leverage := pos.Leverage
+                if leverage <= 0 {
+                        leverage = 10
                 }
 
                 // 计算占用保证金
-                marginUsed := (quantity * markPrice) / float64(leverage)
+                marginUsed := pos.MarginUsed()
+                unrealizedPnl := pos.UnrealizedProfit
 
                 // 计算盈亏百分比（基于保证金）
                 // 收益率 = 未实现盈亏 / 保证金 × 100%
@@ -1368,15 +1862,15 @@ func (at *AutoTrader) GetPositions() ([]map[string]interface{}, error) {
                 }
 
                 result = append(result, map[string]interface{}{
-                        "symbol":             symbol,
-                        "side":               side,
-                        "entry_price":        entryPrice,
-                        "mark_price":         markPrice,
-                        "quantity":           quantity,
+                        "symbol":             pos.Symbol,
+                        "side":               pos.Side,
+                        "entry_price":        pos.EntryPrice,
+                        "mark_price":         pos.MarkPrice,
+                        "quantity":           pos.Quantity,
                         "leverage":           leverage,
                         "unrealized_pnl":     unrealizedPnl,
                         "unrealized_pnl_pct": pnlPct,
-                        "liquidation_price":  liquidationPrice,
+                        "liquidation_price":  pos.LiquidationPrice,
                         "margin_used":        marginUsed,
                 })
         }
@@ -1394,9 +1888,9 @@ func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision
         // 定义优先级
         getActionPriority := func(action string) int {
                 switch action {
-                case "close_long", "close_short":
+                case "close_long", "close_short", "close_pair":
                         return 1 // 最高优先级：先平仓
-                case "open_long", "open_short":
+                case "open_long", "open_short", "open_pair":
                         return 2 // 次优先级：后开仓
                 case "hold", "wait":
                         return 3 // 最低优先级：观望
@@ -1492,8 +1986,8 @@ func normalizeSymbol(symbol string) string {
         return symbol
 }
 
-// checkAndUpdateStopOrders 检查并更新止盈止损单（使用凯利公式优化）
-// 该方法在每个交易周期末尾调用，确保现有持仓的止盈止损点是最优的
+// checkAndUpdateStopOrders 检查并更新止盈止损单（保本止损 + 跟踪止损）
+// 该方法在每个交易周期末尾调用：按持仓峰值计算止损，只有止损价实际变化时才替换交易所的止损止盈单
 func (at *AutoTrader) checkAndUpdateStopOrders() error {
         // 1. 获取当前持仓
         positions, err := at.trader.GetPositions()
@@ -1501,6 +1995,9 @@ func (at *AutoTrader) checkAndUpdateStopOrders() error {
                 return fmt.Errorf("获取持仓失败: %w", err)
         }
 
+        // 清理已平仓持仓的峰值记录
+        at.trailingStops.Prune(positions)
+
         if len(positions) == 0 {
                 log.Println("ℹ️ 当前无持仓，跳过止盈止损更新")
                 return nil
@@ -1508,118 +2005,106 @@ func (at *AutoTrader) checkAndUpdateStopOrders() error {
 
         log.Printf("📊 检查 %d 个持仓的止盈止损单...", len(positions))
 
-        // 2. 对每个持仓进行止盈止损检查
+        // 2. 对每个持仓更新峰值并计算止损
         for _, pos := range positions {
-                symbol := ""
-                if s, ok := pos["symbol"].(string); ok {
-                        symbol = s
-                }
-                side := ""
-                if s, ok := pos["side"].(string); ok {
-                        side = s
-                }
+                symbol := pos.Symbol
+                side := pos.Side
 
                 // 安全检查
                 if symbol == "" || side == "" {
-                        log.Printf("⚠️ 跳过无效持仓数据: %v", pos)
+                        log.Printf("⚠️ 跳过无效持仓数据: %+v", pos)
                         continue
                 }
-
-                // 3. 使用凯利公式计算动态止盈止损
-                entryPrice := 0.0
-                if ep, ok := pos["entryPrice"].(float64); ok {
-                        entryPrice = ep
-                }
-                currentPrice := 0.0
-                if mp, ok := pos["markPrice"].(float64); ok {
-                        currentPrice = mp
+                if pos.EntryPrice <= 0 || pos.MarkPrice <= 0 {
+                        log.Printf("⚠️ %s 无效价格: entry=%.6f, current=%.6f", symbol, pos.EntryPrice, pos.MarkPrice)
+                        continue
                 }
 
-                if entryPrice <= 0 || currentPrice <= 0 {
-                        log.Printf("⚠️ %s 无效价格: entry=%.6f, current=%.6f", symbol, entryPrice, currentPrice)
+                // 组合的腿按合计盈亏止损，不单独跟踪
+                if at.positionGroups.Contains(symbol, side) {
                         continue
                 }
 
-                // 计算当前盈利百分比
-                currentProfitPct := 0.0
-                if side == "long" {
-                        currentProfitPct = (currentPrice - entryPrice) / entryPrice
-                } else {
-                        currentProfitPct = (entryPrice - currentPrice) / entryPrice
+                // 交易所未返回止损止盈价时，使用订单管理器跟踪的条件单
+                positionSide := pos.PositionSide()
+                currentStop, currentTP := pos.StopLoss, pos.TakeProfit
+                if currentStop <= 0 {
+                        currentStop = at.orderManager.ActiveStopPrice(symbol, positionSide, OrderTypeStopMarket)
                 }
-
-                // 获取历史统计数据
-                stats := at.kellyManager.GetHistoricalStats(symbol)
-
-                log.Printf("🔄 [%s %s] 当前盈利: %.2f%%, 历史胜率: %.1f%%",
-                        symbol, side, currentProfitPct*100, func() float64 {
-                                if stats != nil {
-                                        return stats.WinRate * 100
-                                }
-                                return 0
-                        }())
-
-                // 计算最优止盈点
-                takeProfitPrice, err := at.kellyManager.CalculateOptimalTakeProfit(
-                        symbol, entryPrice, currentPrice, side,
-                )
-                if err != nil {
-                        log.Printf("⚠️ 计算止盈点失败 (%s): %v", symbol, err)
-                        continue
+                if currentTP <= 0 {
+                        currentTP = at.orderManager.ActiveStopPrice(symbol, positionSide, OrderTypeTakeProfitMarket)
                 }
 
-                // 计算动态止损点（保护已获利润）
-                stopLossPrice, err := at.kellyManager.CalculateDynamicStopLoss(
-                        symbol, entryPrice, currentPrice, 0, // maxProfitPct需要从历史数据计算
-                )
-                if err != nil {
-                        log.Printf("⚠️ 计算止损点失败 (%s): %v", symbol, err)
+                adj, changed := at.trailingStops.Update(pos, currentStop, currentTP, at.trailingATR(symbol))
+                if !changed {
                         continue
                 }
 
-                // 4. 更新止盈止损单
-                quantity := 0.0
-                if qty, ok := pos["positionAmt"].(float64); ok {
-                        quantity = qty
-                }
-                if quantity < 0 {
-                        quantity = -quantity
+                // 3. 止盈沿用当前委托，未知时使用凯利公式计算
+                takeProfit := at.trailingStops.TakeProfit(symbol, side)
+                if takeProfit <= 0 {
+                        if takeProfit, err = at.kellyManager.CalculateOptimalTakeProfit(symbol, pos.EntryPrice, pos.MarkPrice, side); err != nil {
+                                log.Printf("⚠️ 计算止盈点失败 (%s): %v", symbol, err)
+                                takeProfit = 0
+                        }
                 }
 
-                positionSide := strings.ToUpper(side)
-
-                // 更新止损单
-                if err := at.trader.SetStopLoss(symbol, positionSide, quantity, stopLossPrice); err != nil {
-                        log.Printf("⚠️ 更新止损单失败 (%s %s @ %.6f): %v", symbol, positionSide, stopLossPrice, err)
-                } else {
-                        log.Printf("✅ 更新止损单成功: %s %s @ %.6f (保护%.1f%%利润)",
-                                symbol, positionSide, stopLossPrice, currentProfitPct*100)
+                // 4. 替换止损止盈单（先撤销该方向的旧委托，避免交易所同时挂着新旧两个止损单；另一方向和限价挂单保留）
+                if err := at.orderManager.CancelProtectiveOrders(symbol, positionSide); err != nil {
+                        log.Printf("⚠️ 撤销旧止盈止损单失败 (%s): %v", symbol, err)
+                        continue
                 }
 
-                // 更新止盈单
-                if err := at.trader.SetTakeProfit(symbol, positionSide, quantity, takeProfitPrice); err != nil {
-                        log.Printf("⚠️ 更新止盈单失败 (%s %s @ %.6f): %v", symbol, positionSide, takeProfitPrice, err)
+                stopPlaced := true
+                if _, err := at.orderManager.SetStopLoss(symbol, positionSide, pos.Quantity, adj.NewStop); err != nil {
+                        stopPlaced = false
+                        log.Printf("⚠️ 更新止损单失败 (%s %s @ %.6f): %v", symbol, positionSide, adj.NewStop, err)
+                        // 旧止损已撤销，恢复原止损避免持仓失去保护
+                        if adj.OldStop > 0 {
+                                if _, err := at.orderManager.SetStopLoss(symbol, positionSide, pos.Quantity, adj.OldStop); err != nil {
+                                        log.Printf("❌ 恢复原止损单失败 (%s %s @ %.6f): %v", symbol, positionSide, adj.OldStop, err)
+                                }
+                        }
                 } else {
-                        log.Printf("✅ 更新止盈单成功: %s %s @ %.6f (目标%.1f%%收益)",
-                                symbol, positionSide, takeProfitPrice,
-                                func() float64 {
-                                        if side == "long" {
-                                                return (takeProfitPrice - entryPrice) / entryPrice * 100
+                        log.Printf("✅ 更新止损单成功: %s %s %.6f → %.6f (%s, 峰值价 %.6f)",
+                                symbol, positionSide, adj.OldStop, adj.NewStop, func() string {
+                                        if adj.Reason == "break_even" {
+                                                return "保本"
                                         }
-                                        return (entryPrice - takeProfitPrice) / entryPrice * 100
-                                }())
+                                        return "跟踪"
+                                }(), adj.PeakPrice)
                 }
 
-                // 更新历史统计数据（如果这次交易完成）
-                // 注意：这里仅在持仓盈利或亏损时更新，实际平仓时会在平仓逻辑中更新
+                if takeProfit > 0 {
+                        if _, err := at.orderManager.SetTakeProfit(symbol, positionSide, pos.Quantity, takeProfit); err != nil {
+                                log.Printf("⚠️ 更新止盈单失败 (%s %s @ %.6f): %v", symbol, positionSide, takeProfit, err)
+                                takeProfit = 0
+                        }
+                }
+
+                if stopPlaced {
+                        at.trailingStops.Commit(adj, takeProfit)
+                }
         }
 
         return nil
 }
 
+// trailingATR 跟踪距离使用的ATR（未启用ATR或无数据时返回0）
+func (at *AutoTrader) trailingATR(symbol string) float64 {
+        if at.trailingStops.Config().ATRMultiple <= 0 {
+                return 0
+        }
+        data, err := at.getMarketData(symbol)
+        if err != nil || data == nil || data.LongerTermContext == nil {
+                return 0
+        }
+        return data.LongerTermContext.ATR14
+}
+
 // recordTradeResult 记录交易结果到凯利公式管理器
 // isWin: 是否盈利
-// profitPct: 盈利百分比（正数为盈利，负数为亏损）
+// profitPct: 价格变动比例（小数，正数为盈利，负数为亏损）
 func (at *AutoTrader) recordTradeResult(symbol string, isWin bool, profitPct float64) {
         at.kellyManager.UpdateHistoricalStats(symbol, isWin, profitPct)
         log.Printf("📊 记录交易结果: %s %s, 盈利%.2f%%",
@@ -1628,7 +2113,7 @@ func (at *AutoTrader) recordTradeResult(symbol string, isWin bool, profitPct flo
                                 return "盈利"
                         }
                         return "亏损"
-                }(), profitPct)
+                }(), profitPct*100)
 }
 
 // createAndSavePerformanceSnapshot 创建并保存性能快照
@@ -1695,10 +2180,8 @@ func (at *AutoTrader) CalculateOptimalPositionSize(symbol string) float64 {
                 return at.initialBalance * 0.1
         }
 
-        var equity float64
-        if total, ok := balance["total"].(float64); ok {
-                equity = total
-        } else {
+        equity := balance.TotalEquity()
+        if equity <= 0 {
                 equity = at.initialBalance
         }
 
@@ -5,7 +5,6 @@ import (
 	"log"
 	"nofx/decision"
 	"nofx/logger"
-	"strings"
 	"time"
 )
 
@@ -66,10 +65,10 @@ func (eat *EnhancedAutoTrader) checkAndUpdateStopOrdersEnhanced() error {
 
 	// 2. 对每个持仓进行止盈止损检查
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		currentPrice := pos["markPrice"].(float64)
+		symbol := pos.Symbol
+		side := pos.Side
+		entryPrice := pos.EntryPrice
+		currentPrice := pos.MarkPrice
 
 		// 计算当前盈利百分比并更新峰值
 		var currentProfitPct float64
@@ -106,12 +105,8 @@ func (eat *EnhancedAutoTrader) checkAndUpdateStopOrdersEnhanced() error {
 		}
 
 		// 4. 更新止盈止损单
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity
-		}
-
-		positionSide := strings.ToUpper(side)
+		quantity := pos.Quantity
+		positionSide := pos.PositionSide()
 
 		// 更新止损单
 		if err := eat.trader.SetStopLoss(symbol, positionSide, quantity, dynamicStopLossPrice); err != nil {
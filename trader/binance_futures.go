@@ -4,24 +4,28 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/adshao/go-binance/v2"
 	"github.com/adshao/go-binance/v2/futures"
 )
 
 // FuturesTrader 币安合约交易器
 type FuturesTrader struct {
 	client *futures.Client
+	spot   *binance.Client // 现货客户端（组合持仓的现货腿）
 
 	// 余额缓存
-	cachedBalance     map[string]interface{}
+	cachedBalance     *Balance
 	balanceCacheTime  time.Time
 	balanceCacheMutex sync.RWMutex
 
 	// 持仓缓存
-	cachedPositions     []map[string]interface{}
+	cachedPositions     []Position
 	positionsCacheTime  time.Time
 	positionsCacheMutex sync.RWMutex
 
@@ -34,12 +38,13 @@ func NewFuturesTrader(apiKey, secretKey string) *FuturesTrader {
 	client := futures.NewClient(apiKey, secretKey)
 	return &FuturesTrader{
 		client:        client,
+		spot:          binance.NewClient(apiKey, secretKey),
 		cacheDuration: 15 * time.Second, // 15秒缓存
 	}
 }
 
 // GetBalance 获取账户余额（带缓存）
-func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
+func (t *FuturesTrader) GetBalance() (*Balance, error) {
 	// 先检查缓存是否有效
 	t.balanceCacheMutex.RLock()
 	if t.cachedBalance != nil && time.Since(t.balanceCacheTime) < t.cacheDuration {
@@ -58,10 +63,11 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取账户信息失败: %w", err)
 	}
 
-	result := make(map[string]interface{})
-	result["totalWalletBalance"], _ = strconv.ParseFloat(account.TotalWalletBalance, 64)
-	result["availableBalance"], _ = strconv.ParseFloat(account.AvailableBalance, 64)
-	result["totalUnrealizedProfit"], _ = strconv.ParseFloat(account.TotalUnrealizedProfit, 64)
+	result := &Balance{}
+	result.TotalWalletBalance, _ = strconv.ParseFloat(account.TotalWalletBalance, 64)
+	result.AvailableBalance, _ = strconv.ParseFloat(account.AvailableBalance, 64)
+	result.TotalUnrealizedProfit, _ = strconv.ParseFloat(account.TotalUnrealizedProfit, 64)
+	result.UsedMargin, _ = strconv.ParseFloat(account.TotalInitialMargin, 64)
 
 	log.Printf("✓ 币安API返回: 总余额=%s, 可用=%s, 未实现盈亏=%s",
 		account.TotalWalletBalance,
@@ -78,7 +84,7 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 }
 
 // GetPositions 获取所有持仓（带缓存）
-func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *FuturesTrader) GetPositions() ([]Position, error) {
 	// 先检查缓存是否有效
 	t.positionsCacheMutex.RLock()
 	if t.cachedPositions != nil && time.Since(t.positionsCacheTime) < t.cacheDuration {
@@ -96,30 +102,26 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
 
-	var result []map[string]interface{}
+	var result []Position
 	for _, pos := range positions {
 		posAmt, _ := strconv.ParseFloat(pos.PositionAmt, 64)
 		if posAmt == 0 {
 			continue // 跳过无持仓的
 		}
 
-		posMap := make(map[string]interface{})
-		posMap["symbol"] = pos.Symbol
-		posMap["positionAmt"], _ = strconv.ParseFloat(pos.PositionAmt, 64)
-		posMap["entryPrice"], _ = strconv.ParseFloat(pos.EntryPrice, 64)
-		posMap["markPrice"], _ = strconv.ParseFloat(pos.MarkPrice, 64)
-		posMap["unRealizedProfit"], _ = strconv.ParseFloat(pos.UnRealizedProfit, 64)
-		posMap["leverage"], _ = strconv.ParseFloat(pos.Leverage, 64)
-		posMap["liquidationPrice"], _ = strconv.ParseFloat(pos.LiquidationPrice, 64)
-
-		// 判断方向
-		if posAmt > 0 {
-			posMap["side"] = "long"
-		} else {
-			posMap["side"] = "short"
+		// 判断方向（positionAmt带符号，空仓为负数）
+		position := Position{Symbol: pos.Symbol, Side: "long", Quantity: posAmt}
+		if posAmt < 0 {
+			position.Side = "short"
+			position.Quantity = -posAmt
 		}
+		position.EntryPrice, _ = strconv.ParseFloat(pos.EntryPrice, 64)
+		position.MarkPrice, _ = strconv.ParseFloat(pos.MarkPrice, 64)
+		position.UnrealizedProfit, _ = strconv.ParseFloat(pos.UnRealizedProfit, 64)
+		position.Leverage, _ = strconv.Atoi(pos.Leverage)
+		position.LiquidationPrice, _ = strconv.ParseFloat(pos.LiquidationPrice, 64)
 
-		result = append(result, posMap)
+		result = append(result, position)
 	}
 
 	// 更新缓存
@@ -178,11 +180,9 @@ func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 	positions, err := t.GetPositions()
 	if err == nil {
 		for _, pos := range positions {
-			if pos["symbol"] == symbol {
-				if lev, ok := pos["leverage"].(float64); ok {
-					currentLeverage = int(lev)
-					break
-				}
+			if pos.Symbol == symbol {
+				currentLeverage = pos.Leverage
+				break
 			}
 		}
 	}
@@ -218,7 +218,12 @@ func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 }
 
 // OpenLong 开多仓
-func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openLong(symbol, quantity, leverage, "")
+}
+
+// openLong 开仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *FuturesTrader) openLong(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
 	// 先取消该币种的所有委托单（清理旧的止损止盈单）
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
@@ -238,7 +243,7 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 	}
 
 	// 创建市价买入订单
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.newOrderService(clientOrderID).
 		Symbol(symbol).
 		Side(futures.SideTypeBuy).
 		PositionSide(futures.PositionSideTypeLong).
@@ -253,15 +258,16 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 	log.Printf("✓ 开多仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf("  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return binanceOrderResult(order), nil
 }
 
 // OpenShort 开空仓
-func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openShort(symbol, quantity, leverage, "")
+}
+
+// openShort 开仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *FuturesTrader) openShort(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
 	// 先取消该币种的所有委托单（清理旧的止损止盈单）
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
@@ -281,7 +287,7 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 	}
 
 	// 创建市价卖出订单
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.newOrderService(clientOrderID).
 		Symbol(symbol).
 		Side(futures.SideTypeSell).
 		PositionSide(futures.PositionSideTypeShort).
@@ -296,15 +302,16 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 	log.Printf("✓ 开空仓成功: %s 数量: %s", symbol, quantityStr)
 	log.Printf("  订单ID: %d", order.OrderID)
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return binanceOrderResult(order), nil
 }
 
 // CloseLong 平多仓
-func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	return t.closeLong(symbol, quantity, "")
+}
+
+// closeLong 平仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *FuturesTrader) closeLong(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -312,11 +319,8 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 			return nil, err
 		}
 
-		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "long" {
-				quantity = pos["positionAmt"].(float64)
-				break
-			}
+		if pos, ok := FindPosition(positions, symbol, "long"); ok {
+			quantity = pos.Quantity
 		}
 
 		if quantity == 0 {
@@ -331,7 +335,7 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 	}
 
 	// 创建市价卖出订单（平多）
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.newOrderService(clientOrderID).
 		Symbol(symbol).
 		Side(futures.SideTypeSell).
 		PositionSide(futures.PositionSideTypeLong).
@@ -350,15 +354,16 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return binanceOrderResult(order), nil
 }
 
 // CloseShort 平空仓
-func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	return t.closeShort(symbol, quantity, "")
+}
+
+// closeShort 平仓下单（clientOrderID非空时作为newClientOrderId发送）
+func (t *FuturesTrader) closeShort(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -366,11 +371,8 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 			return nil, err
 		}
 
-		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "short" {
-				quantity = -pos["positionAmt"].(float64) // 空仓数量是负的，取绝对值
-				break
-			}
+		if pos, ok := FindPosition(positions, symbol, "short"); ok {
+			quantity = pos.Quantity
 		}
 
 		if quantity == 0 {
@@ -385,7 +387,7 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 	}
 
 	// 创建市价买入订单（平空）
-	order, err := t.client.NewCreateOrderService().
+	order, err := t.newOrderService(clientOrderID).
 		Symbol(symbol).
 		Side(futures.SideTypeBuy).
 		PositionSide(futures.PositionSideTypeShort).
@@ -404,11 +406,31 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = order.OrderID
-	result["symbol"] = order.Symbol
-	result["status"] = order.Status
-	return result, nil
+	return binanceOrderResult(order), nil
+}
+
+// PlaceMarketOrder 按动作下市价单并携带本地订单ID（实现ClientOrderPlacer）
+func (t *FuturesTrader) PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	switch action {
+	case "open_long":
+		return t.openLong(symbol, quantity, leverage, clientOrderID)
+	case "open_short":
+		return t.openShort(symbol, quantity, leverage, clientOrderID)
+	case "close_long":
+		return t.closeLong(symbol, quantity, clientOrderID)
+	case "close_short":
+		return t.closeShort(symbol, quantity, clientOrderID)
+	}
+	return nil, fmt.Errorf("未知的下单动作: %s", action)
+}
+
+// newOrderService 创建下单请求（clientOrderID非空时设置newClientOrderId）
+func (t *FuturesTrader) newOrderService(clientOrderID string) *futures.CreateOrderService {
+	svc := t.client.NewCreateOrderService()
+	if clientOrderID != "" {
+		svc.NewClientOrderID(clientOrderID)
+	}
+	return svc
 }
 
 // CancelAllOrders 取消该币种的所有挂单
@@ -425,6 +447,355 @@ func (t *FuturesTrader) CancelAllOrders(symbol string) error {
 	return nil
 }
 
+// QueryOrder 查询订单状态（实现OrderQuerier，手续费从账户成交记录汇总）
+func (t *FuturesTrader) QueryOrder(symbol, orderID string) (*Order, error) {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的订单ID: %s", orderID)
+	}
+
+	o, err := t.client.NewGetOrderService().Symbol(symbol).OrderID(id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	order := binanceOrder(o)
+
+	if order.FilledQty > 0 {
+		trades, err := t.client.NewListAccountTradeService().Symbol(symbol).OrderID(id).Do(context.Background())
+		if err != nil {
+			log.Printf("  ⚠ 获取订单成交记录失败: %v", err)
+		}
+		for _, trade := range trades {
+			fee, _ := strconv.ParseFloat(trade.Commission, 64)
+			order.Fee += fee
+		}
+	}
+	return order, nil
+}
+
+// GetOpenOrders 获取该币种当前挂单（实现OrderQuerier）
+func (t *FuturesTrader) GetOpenOrders(symbol string) ([]*Order, error) {
+	list, err := t.client.NewListOpenOrdersService().Symbol(symbol).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	orders := make([]*Order, 0, len(list))
+	for _, o := range list {
+		orders = append(orders, binanceOrder(o))
+	}
+	return orders, nil
+}
+
+// GetFillHistory 获取since之后该币种的成交记录（实现FillHistoryProvider）
+// 非USDT计价的手续费（如BNB抵扣）按当前价格折算为USDT
+func (t *FuturesTrader) GetFillHistory(symbol string, since time.Time) ([]Fill, error) {
+	trades, err := listBinanceTrades(since, time.Now(), func(start, end int64) ([]*futures.AccountTrade, error) {
+		return t.client.NewListAccountTradeService().Symbol(symbol).StartTime(start).EndTime(end).
+			Limit(binanceTradesLimit).Do(context.Background())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取成交记录失败: %w", err)
+	}
+
+	assetPrices := make(map[string]float64)
+	fills := make([]Fill, 0, len(trades))
+	for _, trade := range trades {
+		quantity, _ := strconv.ParseFloat(trade.Quantity, 64)
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		fee, _ := strconv.ParseFloat(trade.Commission, 64)
+		realized, _ := strconv.ParseFloat(trade.RealizedPnl, 64)
+
+		if asset := strings.ToUpper(trade.CommissionAsset); fee != 0 && asset != "" && asset != "USDT" {
+			assetPrice, ok := assetPrices[asset]
+			if !ok {
+				assetPrice, err = t.GetMarketPrice(asset + "USDT")
+				if err != nil {
+					return nil, fmt.Errorf("折算%s手续费失败: %w", asset, err)
+				}
+				assetPrices[asset] = assetPrice
+			}
+			fee *= assetPrice
+		}
+
+		positionSide := string(trade.PositionSide)
+		if positionSide == string(futures.PositionSideTypeBoth) {
+			positionSide = ""
+		}
+		fills = append(fills, Fill{
+			OrderID:      strconv.FormatInt(trade.OrderID, 10),
+			Symbol:       trade.Symbol,
+			Side:         strings.ToLower(string(trade.Side)),
+			PositionSide: positionSide,
+			Quantity:     quantity,
+			Price:        price,
+			Fee:          fee,
+			RealizedPnL:  realized,
+			Time:         time.UnixMilli(trade.Time),
+		})
+	}
+	return fills, nil
+}
+
+const (
+	binanceTradesLimit  = 1000                              // userTrades单次最多返回条数
+	binanceTradesWindow = 7*24*time.Hour - time.Millisecond // userTrades的startTime与endTime最多相隔7天
+)
+
+// listBinanceTrades 按7天时间窗口分页获取[since, until]内的成交记录（单个窗口返回满页时从最后一笔成交时间继续，按成交ID去重）
+func listBinanceTrades(since, until time.Time, fetch func(start, end int64) ([]*futures.AccountTrade, error)) ([]*futures.AccountTrade, error) {
+	var trades []*futures.AccountTrade
+	seen := make(map[int64]bool)
+	start, last := since.UnixMilli(), until.UnixMilli()
+	for start <= last {
+		end := start + binanceTradesWindow.Milliseconds()
+		if end > last {
+			end = last
+		}
+		page, err := fetch(start, end)
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, trade := range page {
+			if !seen[trade.ID] {
+				seen[trade.ID] = true
+				trades = append(trades, trade)
+				added++
+			}
+		}
+		if len(page) >= binanceTradesLimit && added > 0 {
+			start = page[len(page)-1].Time
+			continue
+		}
+		start = end + 1
+	}
+	return trades, nil
+}
+
+// GetFundingPayments 获取since之后该币种支付的资金费合计（实现FundingHistoryProvider）
+func (t *FuturesTrader) GetFundingPayments(symbol string, since time.Time) (float64, error) {
+	incomes, err := t.client.NewGetIncomeHistoryService().Symbol(symbol).IncomeType("FUNDING_FEE").
+		StartTime(since.UnixMilli()).Limit(1000).Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+
+	// 币安收入记录中正数为收取，取反后正数表示支付
+	paid := 0.0
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		paid -= amount
+	}
+	return paid, nil
+}
+
+// GetIncomeHistory 获取since之后账户的手续费和资金费流水（实现IncomeLedgerProvider）
+func (t *FuturesTrader) GetIncomeHistory(since time.Time) ([]IncomeRecord, error) {
+	var incomes []*futures.IncomeHistory
+	for _, incomeType := range []string{"COMMISSION", "FUNDING_FEE"} {
+		list, err := t.client.NewGetIncomeHistoryService().IncomeType(incomeType).
+			StartTime(since.UnixMilli()).Limit(1000).Do(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("获取%s账单失败: %w", incomeType, err)
+		}
+		incomes = append(incomes, list...)
+	}
+	return binanceIncomeRecords(incomes, func(symbol string) ([]*futures.AccountTrade, error) {
+		return listBinanceTrades(since, time.Now(), func(start, end int64) ([]*futures.AccountTrade, error) {
+			return t.client.NewListAccountTradeService().Symbol(symbol).StartTime(start).EndTime(end).
+				Limit(binanceTradesLimit).Do(context.Background())
+		})
+	})
+}
+
+// binanceIncomeRecords 转换币安账单并为手续费补充订单ID（Aster接口与币安兼容，共用此转换）
+// 手续费流水只带成交ID，按各币种的成交记录映射到所属订单，交易员据此认领自己的手续费
+func binanceIncomeRecords(incomes []*futures.IncomeHistory, listTrades func(symbol string) ([]*futures.AccountTrade, error)) ([]IncomeRecord, error) {
+	tradeOrders := make(map[string]map[string]string) // symbol -> 成交ID -> 订单ID
+	records := make([]IncomeRecord, 0, len(incomes))
+	for _, income := range incomes {
+		record := binanceIncomeRecord(income)
+		if income.IncomeType == "COMMISSION" && income.TradeID != "" {
+			orders, ok := tradeOrders[income.Symbol]
+			if !ok {
+				trades, err := listTrades(income.Symbol)
+				if err != nil {
+					return nil, fmt.Errorf("获取%s成交记录失败: %w", income.Symbol, err)
+				}
+				orders = make(map[string]string, len(trades))
+				for _, trade := range trades {
+					orders[strconv.FormatInt(trade.ID, 10)] = strconv.FormatInt(trade.OrderID, 10)
+				}
+				tradeOrders[income.Symbol] = orders
+			}
+			record.OrderID = orders[income.TradeID]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// binanceIncomeRecord 转换币安收入记录（Aster接口与币安兼容，同样使用该转换）
+// 币安收入记录中正数为收取，取反后正数表示支付
+func binanceIncomeRecord(income *futures.IncomeHistory) IncomeRecord {
+	amount, _ := strconv.ParseFloat(income.Income, 64)
+	typ := "fee"
+	if income.IncomeType == "FUNDING_FEE" {
+		typ = "funding"
+	}
+	return IncomeRecord{
+		Type:   typ,
+		Symbol: income.Symbol,
+		Amount: -amount,
+		Ref:    strconv.FormatInt(income.TranID, 10) + "_" + income.IncomeType,
+		Time:   time.UnixMilli(income.Time),
+	}
+}
+
+// binanceOrder 转换币安订单（Aster接口与币安兼容，同样使用该转换）
+func binanceOrder(o *futures.Order) *Order {
+	quantity, _ := strconv.ParseFloat(o.OrigQuantity, 64)
+	filled, _ := strconv.ParseFloat(o.ExecutedQuantity, 64)
+	avgPrice, _ := strconv.ParseFloat(o.AvgPrice, 64)
+	stopPrice, _ := strconv.ParseFloat(o.StopPrice, 64)
+
+	orderType := OrderTypeMarket
+	switch o.Type {
+	case futures.OrderTypeLimit:
+		orderType = OrderTypeLimit
+	case futures.OrderTypeStopMarket, futures.OrderTypeStop:
+		orderType = OrderTypeStopMarket
+	case futures.OrderTypeTakeProfitMarket, futures.OrderTypeTakeProfit:
+		orderType = OrderTypeTakeProfitMarket
+	}
+
+	// 单向持仓模式(BOTH)下根据方向推断保护的持仓：卖出平多，买入平空
+	positionSide := string(o.PositionSide)
+	if positionSide == "" || positionSide == string(futures.PositionSideTypeBoth) {
+		positionSide = "LONG"
+		if o.Side == futures.SideTypeBuy {
+			positionSide = "SHORT"
+		}
+	}
+
+	return &Order{
+		ExchangeOrderID: strconv.FormatInt(o.OrderID, 10),
+		Symbol:          o.Symbol,
+		PositionSide:    positionSide,
+		Type:            orderType,
+		Quantity:        quantity,
+		FilledQty:       filled,
+		AvgPrice:        avgPrice,
+		StopPrice:       stopPrice,
+		Status:          parseOrderStatus(string(o.Status)),
+	}
+}
+
+// PlaceLimitOrder 下限价开仓单（实现LimitOrderPlacer，postOnly时使用GTX，会立即成交的订单由交易所直接置为EXPIRED）
+func (t *FuturesTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	side, posSide := futures.SideTypeBuy, futures.PositionSideTypeLong
+	if positionSide == "SHORT" {
+		side, posSide = futures.SideTypeSell, futures.PositionSideTypeShort
+	}
+	// 多单价格向下取整、空单向上取整，避免取整后穿过盘口
+	priceStr, err := t.FormatPrice(symbol, price, positionSide != "SHORT")
+	if err != nil {
+		return nil, err
+	}
+
+	timeInForce := futures.TimeInForceTypeGTC
+	if postOnly {
+		timeInForce = futures.TimeInForceTypeGTX
+	}
+
+	order, err := t.newOrderService(clientOrderID).
+		Symbol(symbol).
+		Side(side).
+		PositionSide(posSide).
+		Type(futures.OrderTypeLimit).
+		TimeInForce(timeInForce).
+		Quantity(quantityStr).
+		Price(priceStr).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("下限价单失败: %w", err)
+	}
+
+	log.Printf("✓ 限价单已提交: %s %s 数量: %s 价格: %s (%s)", symbol, positionSide, quantityStr, priceStr, timeInForce)
+
+	return binanceOrderResult(order), nil
+}
+
+// binanceOrderResult 转换币安下单结果（Aster接口与币安兼容，共用此转换）
+func binanceOrderResult(order *futures.CreateOrderResponse) *OrderResult {
+	result := &OrderResult{
+		OrderID: strconv.FormatInt(order.OrderID, 10),
+		Symbol:  order.Symbol,
+		Status:  parseOrderStatus(string(order.Status)),
+	}
+	result.ExecutedQty, _ = strconv.ParseFloat(order.ExecutedQuantity, 64)
+	result.AvgPrice, _ = strconv.ParseFloat(order.AvgPrice, 64)
+	return result
+}
+
+// CancelOrder 撤销单个订单（实现LimitOrderPlacer）
+func (t *FuturesTrader) CancelOrder(symbol, orderID string) error {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID: %s", orderID)
+	}
+
+	if _, err := t.client.NewCancelOrderService().Symbol(symbol).OrderID(id).Do(context.Background()); err != nil {
+		return fmt.Errorf("撤销订单失败: %w", err)
+	}
+	return nil
+}
+
+// CancelStopOrder 撤销止损/止盈条件单（实现StopOrderCanceller，条件单与普通订单使用同一撤单接口）
+func (t *FuturesTrader) CancelStopOrder(order *Order) error {
+	return t.CancelOrder(order.Symbol, order.ExchangeOrderID)
+}
+
+// GetBestBidAsk 获取买一/卖一价（实现LimitOrderPlacer）
+func (t *FuturesTrader) GetBestBidAsk(symbol string) (float64, float64, error) {
+	tickers, err := t.client.NewListBookTickersService().Symbol(symbol).Do(context.Background())
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取盘口失败: %w", err)
+	}
+	if len(tickers) == 0 {
+		return 0, 0, fmt.Errorf("未找到 %s 的盘口", symbol)
+	}
+
+	bid, _ := strconv.ParseFloat(tickers[0].BidPrice, 64)
+	ask, _ := strconv.ParseFloat(tickers[0].AskPrice, 64)
+	return bid, ask, nil
+}
+
+// GetBookDepth 获取买一/卖一挂单数量（实现BookDepthProvider）
+func (t *FuturesTrader) GetBookDepth(symbol string) (float64, float64, error) {
+	tickers, err := t.client.NewListBookTickersService().Symbol(symbol).Do(context.Background())
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取盘口失败: %w", err)
+	}
+	if len(tickers) == 0 {
+		return 0, 0, fmt.Errorf("未找到 %s 的盘口", symbol)
+	}
+
+	bidQty, _ := strconv.ParseFloat(tickers[0].BidQuantity, 64)
+	askQty, _ := strconv.ParseFloat(tickers[0].AskQuantity, 64)
+	return bidQty, askQty, nil
+}
+
 // GetMarketPrice 获取市场价格
 func (t *FuturesTrader) GetMarketPrice(symbol string) (float64, error) {
 	prices, err := t.client.NewListPricesService().Symbol(symbol).Do(context.Background())
@@ -596,6 +967,39 @@ func trimTrailingZeros(s string) string {
 	return s
 }
 
+// FormatPrice 按PRICE_FILTER的tickSize格式化价格（roundDown=true向下取整，否则向上取整）
+func (t *FuturesTrader) FormatPrice(symbol string, price float64, roundDown bool) (string, error) {
+	exchangeInfo, err := t.client.NewExchangeInfoService().Do(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("获取交易规则失败: %w", err)
+	}
+
+	for _, s := range exchangeInfo.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		for _, filter := range s.Filters {
+			if filter["filterType"] != "PRICE_FILTER" {
+				continue
+			}
+			tickSizeStr, _ := filter["tickSize"].(string)
+			tickSize, _ := strconv.ParseFloat(tickSizeStr, 64)
+			if tickSize <= 0 {
+				break
+			}
+			ticks := price / tickSize
+			if roundDown {
+				ticks = math.Floor(ticks + 1e-9)
+			} else {
+				ticks = math.Ceil(ticks - 1e-9)
+			}
+			return strconv.FormatFloat(ticks*tickSize, 'f', calculatePrecision(tickSizeStr), 64), nil
+		}
+	}
+
+	return strconv.FormatFloat(price, 'f', -1, 64), nil
+}
+
 // FormatQuantity 格式化数量到正确的精度
 func (t *FuturesTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	precision, err := t.GetSymbolPrecision(symbol)
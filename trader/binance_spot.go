@@ -0,0 +1,161 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2"
+)
+
+// spotSymbolInfo 现货交易对规则
+func (t *FuturesTrader) spotSymbolInfo(symbol string) (*binance.Symbol, error) {
+	info, err := t.spot.NewExchangeInfoService().Symbol(symbol).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取现货交易规则失败: %w", err)
+	}
+	for i := range info.Symbols {
+		if info.Symbols[i].Symbol == symbol {
+			return &info.Symbols[i], nil
+		}
+	}
+	return nil, fmt.Errorf("未找到现货交易对 %s", symbol)
+}
+
+// formatSpotQuantity 按现货LOT_SIZE向下取整数量（避免卖出数量超过余额）
+func (t *FuturesTrader) formatSpotQuantity(symbol string, quantity float64) (string, error) {
+	info, err := t.spotSymbolInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+	lot := info.LotSizeFilter()
+	if lot == nil {
+		return strconv.FormatFloat(quantity, 'f', -1, 64), nil
+	}
+	step, _ := strconv.ParseFloat(lot.StepSize, 64)
+	if step <= 0 {
+		return strconv.FormatFloat(quantity, 'f', -1, 64), nil
+	}
+	steps := math.Floor(quantity/step + 1e-9)
+	if steps <= 0 {
+		return "", fmt.Errorf("%s 现货数量 %.8f 小于最小步长 %s", symbol, quantity, lot.StepSize)
+	}
+	return strconv.FormatFloat(steps*step, 'f', calculatePrecision(lot.StepSize), 64), nil
+}
+
+// SpotBuy 市价买入现货（实现SpotTrader，扣除以基础资产收取的手续费后返回实际到账数量）
+func (t *FuturesTrader) SpotBuy(symbol string, quantity float64) (*OrderResult, error) {
+	info, err := t.spotSymbolInfo(symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantityStr, err := t.formatSpotQuantity(symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := t.spot.NewCreateOrderService().
+		Symbol(symbol).
+		Side(binance.SideTypeBuy).
+		Type(binance.OrderTypeMarket).
+		Quantity(quantityStr).
+		NewOrderRespType(binance.NewOrderRespTypeFULL).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("买入现货失败: %w", err)
+	}
+
+	log.Printf("✓ 买入现货成功: %s 数量: %s", symbol, quantityStr)
+	return binanceSpotOrderResult(order, info.BaseAsset), nil
+}
+
+// SpotSell 市价卖出现货（实现SpotTrader）
+func (t *FuturesTrader) SpotSell(symbol string, quantity float64) (*OrderResult, error) {
+	info, err := t.spotSymbolInfo(symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantityStr, err := t.formatSpotQuantity(symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := t.spot.NewCreateOrderService().
+		Symbol(symbol).
+		Side(binance.SideTypeSell).
+		Type(binance.OrderTypeMarket).
+		Quantity(quantityStr).
+		NewOrderRespType(binance.NewOrderRespTypeFULL).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("卖出现货失败: %w", err)
+	}
+
+	log.Printf("✓ 卖出现货成功: %s 数量: %s", symbol, quantityStr)
+	return binanceSpotOrderResult(order, info.BaseAsset), nil
+}
+
+// GetSpotBalance 现货账户中该交易对基础资产的可用数量（实现SpotTrader）
+func (t *FuturesTrader) GetSpotBalance(symbol string) (float64, error) {
+	info, err := t.spotSymbolInfo(symbol)
+	if err != nil {
+		return 0, err
+	}
+	account, err := t.spot.NewGetAccountService().Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("获取现货账户失败: %w", err)
+	}
+	for _, b := range account.Balances {
+		if b.Asset == info.BaseAsset {
+			free, _ := strconv.ParseFloat(b.Free, 64)
+			return free, nil
+		}
+	}
+	return 0, nil
+}
+
+// GetSpotPrice 现货最新价格（实现SpotTrader）
+func (t *FuturesTrader) GetSpotPrice(symbol string) (float64, error) {
+	prices, err := t.spot.NewListPricesService().Symbol(symbol).Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("获取现货价格失败: %w", err)
+	}
+	if len(prices) == 0 {
+		return 0, fmt.Errorf("未找到 %s 现货价格", symbol)
+	}
+	return strconv.ParseFloat(prices[0].Price, 64)
+}
+
+// binanceSpotOrderResult 转换币安现货下单结果
+// 买入时手续费可能以基础资产收取，到账数量需扣除；手续费统一折算为计价资产
+func binanceSpotOrderResult(order *binance.CreateOrderResponse, baseAsset string) *OrderResult {
+	result := &OrderResult{
+		OrderID: strconv.FormatInt(order.OrderID, 10),
+		Symbol:  order.Symbol,
+		Status:  parseOrderStatus(string(order.Status)),
+	}
+	executed, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	quote, _ := strconv.ParseFloat(order.CummulativeQuoteQuantity, 64)
+	if executed > 0 {
+		result.AvgPrice = quote / executed
+	}
+
+	var baseFee float64
+	for _, fill := range order.Fills {
+		commission, _ := strconv.ParseFloat(fill.Commission, 64)
+		price, _ := strconv.ParseFloat(fill.Price, 64)
+		if fill.CommissionAsset == baseAsset {
+			baseFee += commission
+			result.Fee += commission * price
+		} else {
+			result.Fee += commission // 计价资产（BNB抵扣时按数量近似记录）
+		}
+	}
+	result.ExecutedQty = executed
+	if order.Side == binance.SideTypeBuy {
+		result.ExecutedQty = executed - baseFee
+	}
+	return result
+}
@@ -3,6 +3,7 @@ package trader
 import (
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"time"
 )
@@ -64,17 +65,12 @@ func (cm *ConstraintsManager) GetCurrentConstraints() Constraints {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	switch cm.currentStage {
-	case StageInfant:
-		return Constraints{
-			Stage:               StageInfant,
-			MaxLeverage:         1,
-			MaxDailyLoss:        0.05,  // 日亏损最多5%
-			MaxSingleLoss:       0.03,  // 单笔最多3%
-			MinHoldingMinutes:   30,    // 最少持30分钟
-			MaxConcurrentPos:    1,     // 最多1个仓位
-			AllowExceptionForAI: false, // 不允许例外
-		}
+	return constraintsForStage(cm.currentStage)
+}
+
+// constraintsForStage 各学习阶段的约束条件（未知阶段按婴儿期处理）
+func constraintsForStage(stage LearningStage) Constraints {
+	switch stage {
 	case StageChild:
 		return Constraints{
 			Stage:               StageChild,
@@ -96,21 +92,30 @@ func (cm *ConstraintsManager) GetCurrentConstraints() Constraints {
 			AllowExceptionForAI: true, // 允许AI例外放权
 		}
 	default:
-		return cm.GetCurrentConstraints()
+		return Constraints{
+			Stage:               StageInfant,
+			MaxLeverage:         1,
+			MaxDailyLoss:        0.05,  // 日亏损最多5%
+			MaxSingleLoss:       0.03,  // 单笔最多3%
+			MinHoldingMinutes:   30,    // 最少持30分钟
+			MaxConcurrentPos:    1,     // 最多1个仓位
+			AllowExceptionForAI: false, // 不允许例外
+		}
 	}
 }
 
 // ValidateDecision 验证AI决策是否符合约束
+// estimatedLoss为触发止损时的预估亏损（占账户净值的百分比）
 // 返回 (是否通过, 拒绝原因)
 func (cm *ConstraintsManager) ValidateDecision(
 	leverage int,
 	estimatedLoss float64,
-	position *Position,
+	position *TrackedPosition,
 ) (bool, string) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	constraints := cm.GetCurrentConstraints()
+	constraints := constraintsForStage(cm.currentStage)
 
 	// 约束1: 杠杆上限
 	if leverage > constraints.MaxLeverage {
@@ -153,6 +158,7 @@ func (cm *ConstraintsManager) ValidateDecision(
 }
 
 // RecordTradeResult 记录交易结果并更新阶段
+// pnl为占账户净值的百分比（亏损为负），pnlPct为收益率小数
 func (cm *ConstraintsManager) RecordTradeResult(isWin bool, pnl, pnlPct float64) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
@@ -169,7 +175,7 @@ func (cm *ConstraintsManager) RecordTradeResult(isWin bool, pnl, pnlPct float64)
 	cm.totalTrades++
 	if !isWin {
 		cm.consecutiveLosses++
-		cm.dailyLossAmount += pnl
+		cm.dailyLossAmount += math.Abs(pnl)
 		// 触发警告: 连续5笔亏损
 		if cm.consecutiveLosses >= 5 {
 			log.Printf("🚨 连续%d笔亏损,建议暂停交易检查策略", cm.consecutiveLosses)
@@ -263,14 +269,59 @@ func (cm *ConstraintsManager) RejectDecision(reason string) {
 	}
 }
 
+// Name 风控闸门名称
+func (cm *ConstraintsManager) Name() string {
+	return "constraints"
+}
+
+// Check 开仓前按当前学习阶段校验杠杆、预估亏损和并发仓位
+func (cm *ConstraintsManager) Check(check RiskCheck) (bool, string) {
+	cm.CheckDailyReset()
+
+	cm.mu.Lock()
+	cm.currentPositions = check.PositionCount
+	cm.mu.Unlock()
+
+	ok, reason := cm.ValidateDecision(check.Leverage, check.EstimatedLossPct(), nil)
+	if !ok {
+		cm.RejectDecision(reason)
+	}
+	return ok, reason
+}
+
+// OnTradeClosed 平仓后记录交易结果（用于阶段升级和日亏损统计）
+func (cm *ConstraintsManager) OnTradeClosed(trade ClosedTrade) {
+	cm.RecordTradeResult(trade.PnL > 0, trade.PnLPct, trade.PnLPct/100)
+}
+
+// GetStatus 获取约束管理器状态
+func (cm *ConstraintsManager) GetStatus() map[string]interface{} {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	constraints := constraintsForStage(cm.currentStage)
+	return map[string]interface{}{
+		"stage":               int(cm.currentStage),
+		"total_trades":        cm.totalTrades,
+		"consecutive_losses":  cm.consecutiveLosses,
+		"daily_loss_percent":  cm.dailyLossAmount,
+		"current_positions":   cm.currentPositions,
+		"rejections":          cm.decisionRejections,
+		"max_leverage":        constraints.MaxLeverage,
+		"max_daily_loss_pct":  constraints.MaxDailyLoss * 100,
+		"max_single_loss_pct": constraints.MaxSingleLoss * 100,
+		"max_concurrent_pos":  constraints.MaxConcurrentPos,
+	}
+}
+
 // PositionTracker 持仓跟踪
 type PositionTracker struct {
 	mu        sync.RWMutex
-	positions map[string]*Position
+	positions map[string]*TrackedPosition
 }
 
-// Position 持仓信息
-type Position struct {
+// TrackedPosition 约束管理器跟踪的持仓信息
+type TrackedPosition struct {
 	Symbol        string
 	OpenPrice     float64
 	OpenTime      time.Time
@@ -282,12 +333,12 @@ type Position struct {
 // NewPositionTracker 创建持仓跟踪器
 func NewPositionTracker() *PositionTracker {
 	return &PositionTracker{
-		positions: make(map[string]*Position),
+		positions: make(map[string]*TrackedPosition),
 	}
 }
 
 // OpenPosition 打开持仓
-func (pt *PositionTracker) OpenPosition(pos *Position) {
+func (pt *PositionTracker) OpenPosition(pos *TrackedPosition) {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
 
@@ -315,7 +366,7 @@ func (pt *PositionTracker) GetPositionCount() int {
 }
 
 // GetPosition 获取指定币种的持仓信息
-func (pt *PositionTracker) GetPosition(symbol string) *Position {
+func (pt *PositionTracker) GetPosition(symbol string) *TrackedPosition {
 	pt.mu.RLock()
 	defer pt.mu.RUnlock()
 
@@ -323,11 +374,11 @@ func (pt *PositionTracker) GetPosition(symbol string) *Position {
 }
 
 // GetAllPositions 获取所有持仓
-func (pt *PositionTracker) GetAllPositions() []*Position {
+func (pt *PositionTracker) GetAllPositions() []*TrackedPosition {
 	pt.mu.RLock()
 	defer pt.mu.RUnlock()
 
-	positions := make([]*Position, 0, len(pt.positions))
+	positions := make([]*TrackedPosition, 0, len(pt.positions))
 	for _, pos := range pt.positions {
 		positions = append(positions, pos)
 	}
@@ -0,0 +1,357 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+)
+
+// ReplayConfig 决策回放配置
+type ReplayConfig struct {
+	TraderID string
+	LogDir   string                   // 决策日志目录（decision_logs/<trader_id>）
+	Records  []*logger.DecisionRecord // 直接提供决策记录（优先于LogDir）
+
+	// FromCycle/ToCycle 只回放指定周期范围（0表示不限制）
+	FromCycle int
+	ToCycle   int
+
+	// InitialBalance 模拟账户初始净值（0表示使用第一条记录的账户净值）
+	InitialBalance float64
+	Exchange       SimulatedTraderConfig
+	IsCrossMargin  bool
+
+	BTCETHLeverage  int // BTC/ETH杠杆上限（默认5）
+	AltcoinLeverage int // 山寨币杠杆上限（默认5）
+	CooldownMinutes int // 平仓冷却期（默认15分钟）
+}
+
+// ReplayCycle 单个周期的回放结果
+type ReplayCycle struct {
+	CycleNumber     int                     `json:"cycle_number"`
+	Time            time.Time               `json:"time"`
+	Response        string                  `json:"response"`
+	Prices          map[string]float64      `json:"prices"`
+	Decisions       []decision.Decision     `json:"decisions"`        // 解析、验证、去重后的决策
+	Actions         []logger.DecisionAction `json:"actions"`          // 回放执行结果
+	RecordedActions []logger.DecisionAction `json:"recorded_actions"` // 实盘执行结果
+	Mismatches      []string                `json:"mismatches"`       // 回放与实盘不一致之处
+	Error           string                  `json:"error,omitempty"`
+	Equity          float64                 `json:"equity"`
+}
+
+// ReplayResult 决策回放结果
+type ReplayResult struct {
+	InitialBalance float64          `json:"initial_balance"`
+	FinalEquity    float64          `json:"final_equity"`
+	Cycles         []ReplayCycle    `json:"cycles"`
+	Skipped        int              `json:"skipped"` // 没有AI响应的周期数
+	Fills          []SimFill        `json:"fills"`
+	Trades         []SimClosedTrade `json:"trades"`
+}
+
+// MismatchCount 回放与实盘不一致的周期数
+func (r *ReplayResult) MismatchCount() int {
+	count := 0
+	for _, cycle := range r.Cycles {
+		if len(cycle.Mismatches) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// ReplayDecisionLogs 确定性回放决策日志
+// 对每个录制周期，使用录制的AI响应重新走 ParseAndValidateResponse → executeDecisionWithRecord 路径，
+// 在模拟交易所上以录制时的价格和时间执行，并与实盘执行结果逐条对比。
+// 验证和去重使用录制时AI看到的账户和持仓，执行使用模拟账户。
+func ReplayDecisionLogs(cfg ReplayConfig) (*ReplayResult, error) {
+	records := cfg.Records
+	if records == nil {
+		loaded, err := logger.LoadDecisionRecords(cfg.LogDir)
+		if err != nil {
+			return nil, fmt.Errorf("加载决策日志失败: %w", err)
+		}
+		records = loaded
+	}
+
+	var selected []*logger.DecisionRecord
+	for _, record := range records {
+		if cfg.FromCycle > 0 && record.CycleNumber < cfg.FromCycle {
+			continue
+		}
+		if cfg.ToCycle > 0 && record.CycleNumber > cfg.ToCycle {
+			continue
+		}
+		selected = append(selected, record)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("没有可回放的决策记录")
+	}
+
+	if cfg.BTCETHLeverage <= 0 {
+		cfg.BTCETHLeverage = 5
+	}
+	if cfg.AltcoinLeverage <= 0 {
+		cfg.AltcoinLeverage = 5
+	}
+	if cfg.CooldownMinutes <= 0 {
+		cfg.CooldownMinutes = 15
+	}
+
+	first := selected[0]
+	initialBalance := cfg.InitialBalance
+	if initialBalance <= 0 {
+		initialBalance = first.AccountState.TotalBalance
+	}
+	if initialBalance <= 0 {
+		return nil, fmt.Errorf("无法确定初始净值，请设置InitialBalance")
+	}
+
+	exchangeCfg := cfg.Exchange
+	exchangeCfg.InitialBalance = initialBalance
+	sim := NewSimulatedTrader(exchangeCfg)
+	seedFromRecord(sim, first, initialBalance)
+
+	var now time.Time
+	clock := func() time.Time { return now }
+	sim.SetClock(clock)
+
+	var prices map[string]float64
+	executor := NewSimulationExecutor(sim, SimulationConfig{
+		TraderID:       cfg.TraderID,
+		Exchange:       "replay",
+		InitialBalance: initialBalance,
+		IsCrossMargin:  cfg.IsCrossMargin,
+		Clock:          clock,
+		MarketData: func(symbol string) (*market.Data, error) {
+			price, ok := prices[symbol]
+			if !ok || price <= 0 {
+				return nil, fmt.Errorf("回放记录中没有 %s 的价格", symbol)
+			}
+			return &market.Data{Symbol: symbol, CurrentPrice: price}, nil
+		},
+	})
+	at := executor.at
+
+	result := &ReplayResult{InitialBalance: initialBalance}
+	for _, record := range selected {
+		now = record.Timestamp
+		prices = recordedPrices(record)
+
+		// 先用录制价格推进模拟持仓（触发两个周期之间的止损/止盈/强平）
+		for symbol, price := range prices {
+			sim.SetPrice(symbol, price)
+		}
+		for _, symbol := range sim.HeldSymbols() {
+			if price, ok := prices[symbol]; ok {
+				sim.OnBar(symbol, price, price, price)
+			}
+		}
+
+		cycle := ReplayCycle{
+			CycleNumber:     record.CycleNumber,
+			Time:            record.Timestamp,
+			Response:        record.AIResponse(),
+			Prices:          prices,
+			RecordedActions: record.Decisions,
+		}
+
+		if cycle.Response == "" {
+			result.Skipped++
+			continue
+		}
+
+		full, err := decision.ParseAndValidateResponse(at.replayContext(record, cfg), cycle.Response)
+		if err != nil {
+			cycle.Error = err.Error()
+		} else {
+			cycle.Decisions = full.Decisions
+			cycle.Actions = executor.Execute(full.Decisions)
+		}
+
+		cycle.Mismatches = compareReplayActions(record.Decisions, cycle.Actions)
+		cycle.Equity = sim.Equity()
+		result.Cycles = append(result.Cycles, cycle)
+
+		if len(cycle.Mismatches) > 0 {
+			log.Printf("⚠️ [回放] 周期 #%d 与实盘不一致: %v", cycle.CycleNumber, cycle.Mismatches)
+		}
+	}
+
+	result.FinalEquity = sim.Equity()
+	result.Fills = sim.GetFills()
+	result.Trades = sim.GetClosedTrades()
+	return result, nil
+}
+
+// replayContext 用录制的账户和持仓构建验证所需的上下文
+func (at *AutoTrader) replayContext(record *logger.DecisionRecord, cfg ReplayConfig) *decision.Context {
+	equity := record.AccountState.TotalBalance
+	if equity <= 0 {
+		if balance, err := at.trader.GetBalance(); err == nil {
+			equity = balance.TotalEquity()
+		}
+	}
+
+	ctx := &decision.Context{
+		CurrentTime: record.Timestamp.Format("2006-01-02 15:04:05"),
+		CallCount:   record.CycleNumber,
+		Account: decision.AccountInfo{
+			TotalEquity:      equity,
+			AvailableBalance: record.AccountState.AvailableBalance,
+			TotalPnL:         record.AccountState.TotalUnrealizedProfit,
+			MarginUsedPct:    record.AccountState.MarginUsedPct,
+			PositionCount:    record.AccountState.PositionCount,
+		},
+		BTCETHLeverage:  cfg.BTCETHLeverage,
+		AltcoinLeverage: cfg.AltcoinLeverage,
+		CooldownMinutes: cfg.CooldownMinutes,
+		LastCloseTime:   make(map[string]int64, len(at.positionFirstSeenTime)),
+	}
+
+	for _, pos := range record.Positions {
+		ctx.Positions = append(ctx.Positions, decision.PositionInfo{
+			Symbol:           pos.Symbol,
+			Side:             pos.Side,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        pos.MarkPrice,
+			Quantity:         math.Abs(pos.PositionAmt),
+			Leverage:         int(pos.Leverage),
+			UnrealizedPnL:    pos.UnrealizedProfit,
+			LiquidationPrice: pos.LiquidationPrice,
+		})
+	}
+
+	// 冷却期检查基于 time.Now()，将录制时间线平移到当前时间
+	offset := time.Since(record.Timestamp).Milliseconds()
+	for key, ts := range at.positionFirstSeenTime {
+		ctx.LastCloseTime[key] = ts + offset
+	}
+
+	return ctx
+}
+
+// seedFromRecord 用第一条记录的持仓初始化模拟账户
+func seedFromRecord(sim *SimulatedTrader, record *logger.DecisionRecord, equity float64) {
+	if len(record.Positions) == 0 {
+		return
+	}
+
+	state := SimulatedState{Balance: equity - record.AccountState.TotalUnrealizedProfit}
+	for _, pos := range record.Positions {
+		leverage := int(pos.Leverage)
+		quantity := math.Abs(pos.PositionAmt)
+		if leverage <= 0 || quantity <= 0 || pos.EntryPrice <= 0 {
+			continue
+		}
+		state.Positions = append(state.Positions, SimPositionState{
+			Symbol:     pos.Symbol,
+			Side:       pos.Side,
+			Quantity:   quantity,
+			EntryPrice: pos.EntryPrice,
+			Leverage:   leverage,
+			Margin:     quantity * pos.EntryPrice / float64(leverage),
+			OpenTime:   record.Timestamp,
+		})
+	}
+	sim.Restore(state)
+}
+
+var (
+	// 持仓行: "1. BTCUSDT LONG | 入场价95000.0000 当前价96000.0000 | ..."
+	replayPositionPriceRe = regexp.MustCompile(`(?m)^\d+\. ([A-Z0-9]+) (?:LONG|SHORT) \| 入场价[0-9.]+ 当前价([0-9.]+)`)
+	// 候选币种: "### 1. ETHUSDT ...\n\ncurrent_price = 3500.00"
+	replayCandidatePriceRe = regexp.MustCompile(`(?m)^### \d+\. ([A-Z0-9]+)[^\n]*\n+current_price = ([0-9.]+)`)
+)
+
+// recordedPrices 提取录制周期中各币种的价格
+// 优先级: 实盘成交价 > 持仓标记价 > 输入prompt中的当前价
+func recordedPrices(record *logger.DecisionRecord) map[string]float64 {
+	prices := make(map[string]float64)
+	set := func(symbol string, price float64) {
+		if price > 0 {
+			prices[symbol] = price
+		}
+	}
+
+	for _, match := range replayCandidatePriceRe.FindAllStringSubmatch(record.InputPrompt, -1) {
+		price, _ := strconv.ParseFloat(match[2], 64)
+		set(match[1], price)
+	}
+	for _, match := range replayPositionPriceRe.FindAllStringSubmatch(record.InputPrompt, -1) {
+		price, _ := strconv.ParseFloat(match[2], 64)
+		set(match[1], price)
+	}
+	for _, pos := range record.Positions {
+		set(pos.Symbol, pos.MarkPrice)
+	}
+	for _, action := range record.Decisions {
+		set(action.Symbol, action.Price)
+	}
+	return prices
+}
+
+// sortDecisionsBySymbol 按币种和动作排序（去重后的决策来自map，排序保证回放顺序确定）
+func sortDecisionsBySymbol(decisions []decision.Decision) []decision.Decision {
+	sorted := make([]decision.Decision, len(decisions))
+	copy(sorted, decisions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Symbol != sorted[j].Symbol {
+			return sorted[i].Symbol < sorted[j].Symbol
+		}
+		return sorted[i].Action < sorted[j].Action
+	})
+	return sorted
+}
+
+// compareReplayActions 对比实盘与回放的执行结果
+func compareReplayActions(recorded, replayed []logger.DecisionAction) []string {
+	key := func(a logger.DecisionAction) string { return a.Symbol + " " + a.Action }
+
+	recordedMap := make(map[string]logger.DecisionAction, len(recorded))
+	for _, a := range recorded {
+		recordedMap[key(a)] = a
+	}
+	replayedMap := make(map[string]logger.DecisionAction, len(replayed))
+	for _, a := range replayed {
+		replayedMap[key(a)] = a
+	}
+
+	var mismatches []string
+	for _, a := range replayed {
+		orig, ok := recordedMap[key(a)]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: 实盘未执行，回放%s", key(a), describeReplayAction(a)))
+			continue
+		}
+		if orig.Success != a.Success {
+			mismatches = append(mismatches, fmt.Sprintf("%s: 实盘%s，回放%s", key(a), describeReplayAction(orig), describeReplayAction(a)))
+		}
+	}
+	for _, a := range recorded {
+		if _, ok := replayedMap[key(a)]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: 实盘%s，回放未执行", key(a), describeReplayAction(a)))
+		}
+	}
+	return mismatches
+}
+
+// describeReplayAction 描述执行结果
+func describeReplayAction(a logger.DecisionAction) string {
+	if a.Success {
+		return "成功"
+	}
+	if a.Error != "" {
+		return "失败(" + a.Error + ")"
+	}
+	return "失败"
+}
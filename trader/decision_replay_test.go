@@ -0,0 +1,107 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/logger"
+)
+
+func TestRecordedPrices(t *testing.T) {
+	record := &logger.DecisionRecord{
+		InputPrompt: "## 当前持仓\n1. SOLUSDT LONG | 入场价150.0000 当前价155.5000 | 盈亏+3.67% | 杠杆3x\n\n" +
+			"## 候选币种 (2个)\n\n### 1. BTCUSDT\n\ncurrent_price = 95000.00, current_ema20 = 1.000\n\n" +
+			"### 2. ETHUSDT (OI_Top持仓增长)\n\ncurrent_price = 3500.00, current_ema20 = 1.000\n\n",
+		Decisions: []logger.DecisionAction{
+			{Symbol: "BTCUSDT", Action: "open_long", Price: 95100},
+		},
+	}
+
+	prices := recordedPrices(record)
+	if prices["SOLUSDT"] != 155.5 || prices["ETHUSDT"] != 3500 {
+		t.Errorf("应从输入prompt中提取价格: %v", prices)
+	}
+	if prices["BTCUSDT"] != 95100 {
+		t.Errorf("实盘成交价应优先于prompt价格: %v", prices["BTCUSDT"])
+	}
+}
+
+func TestReplayDecisionLogs(t *testing.T) {
+	start := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+	openResponse := "突破，开多\n[{\"symbol\":\"BTCUSDT\",\"action\":\"open_long\",\"leverage\":2,\"position_size_usd\":500,\"stop_loss\":95,\"take_profit\":130,\"confidence\":80,\"reasoning\":\"test\"}]"
+
+	records := []*logger.DecisionRecord{
+		{
+			Timestamp:    start,
+			CycleNumber:  1,
+			InputPrompt:  "### 1. BTCUSDT\n\ncurrent_price = 100.00\n",
+			RawResponse:  openResponse,
+			AccountState: logger.AccountSnapshot{TotalBalance: 1000, AvailableBalance: 1000},
+			Decisions: []logger.DecisionAction{
+				{Symbol: "BTCUSDT", Action: "open_long", Price: 100, Success: true},
+			},
+		},
+		{
+			Timestamp:    start.Add(3 * time.Minute),
+			CycleNumber:  2,
+			RawResponse:  "走弱，平多\n[{\"symbol\":\"BTCUSDT\",\"action\":\"close_long\",\"reasoning\":\"test\"}]",
+			AccountState: logger.AccountSnapshot{TotalBalance: 990, PositionCount: 1},
+			Positions: []logger.PositionSnapshot{
+				{Symbol: "BTCUSDT", Side: "long", PositionAmt: 5, EntryPrice: 100, MarkPrice: 98, Leverage: 2},
+			},
+			Decisions: []logger.DecisionAction{
+				{Symbol: "BTCUSDT", Action: "close_long", Price: 98, Success: true},
+			},
+		},
+		{
+			// 平仓5分钟后再次开多，处于冷却期，应被过滤
+			Timestamp:    start.Add(8 * time.Minute),
+			CycleNumber:  3,
+			InputPrompt:  "### 1. BTCUSDT\n\ncurrent_price = 99.00\n",
+			RawResponse:  openResponse,
+			AccountState: logger.AccountSnapshot{TotalBalance: 989},
+			Decisions: []logger.DecisionAction{
+				{Symbol: "BTCUSDT", Action: "open_long", Price: 99, Success: true},
+			},
+		},
+		{
+			// AI调用失败的周期没有响应
+			Timestamp:   start.Add(11 * time.Minute),
+			CycleNumber: 4,
+		},
+	}
+
+	result, err := ReplayDecisionLogs(ReplayConfig{
+		TraderID: "replay_test",
+		Records:  records,
+		Exchange: SimulatedTraderConfig{TakerFeeRate: 0.001, MaintenanceMarginRate: 0.005},
+	})
+	if err != nil {
+		t.Fatalf("回放失败: %v", err)
+	}
+
+	if len(result.Cycles) != 3 || result.Skipped != 1 {
+		t.Fatalf("应回放3个周期并跳过1个: %d / %d", len(result.Cycles), result.Skipped)
+	}
+	if len(result.Fills) != 2 {
+		t.Fatalf("应有开仓和平仓2笔成交, got %d", len(result.Fills))
+	}
+	if result.Fills[0].Price != 100 || result.Fills[1].Price != 98 {
+		t.Errorf("应按录制价格成交: %+v", result.Fills)
+	}
+	if !result.Fills[1].Time.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("成交时间应为录制时间: %v", result.Fills[1].Time)
+	}
+
+	for _, cycle := range result.Cycles[:2] {
+		if len(cycle.Mismatches) != 0 {
+			t.Errorf("周期 #%d 应与实盘一致: %v", cycle.CycleNumber, cycle.Mismatches)
+		}
+	}
+	if len(result.Cycles[2].Decisions) != 0 || len(result.Cycles[2].Mismatches) != 1 {
+		t.Errorf("冷却期内的开仓应被过滤并报告不一致: %+v", result.Cycles[2])
+	}
+	if result.MismatchCount() != 1 {
+		t.Errorf("应有1个不一致周期, got %d", result.MismatchCount())
+	}
+}
@@ -0,0 +1,859 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/decision"
+)
+
+// defaultTakerFeeRate 未配置费率的交易所按该吃单费率计算
+const defaultTakerFeeRate = 0.0005
+
+// maxRouteHistory 保留的最近路由记录数
+const maxRouteHistory = 50
+
+// BookDepthProvider 可查询盘口挂单数量的交易器（可选实现，用于按流动性路由）
+type BookDepthProvider interface {
+	// GetBookDepth 获取买一/卖一挂单数量（币）
+	GetBookDepth(symbol string) (bidQty, askQty float64, err error)
+}
+
+// ExchangeRouterConfig 跨交易所路由配置
+type ExchangeRouterConfig struct {
+	FailureThreshold int                // 连续失败次数达到后暂停该交易所
+	FailoverCooldown time.Duration      // 暂停时长
+	TakerFees        map[string]float64 // 各交易所吃单费率
+}
+
+// DefaultExchangeRouterConfig 默认跨交易所路由配置
+func DefaultExchangeRouterConfig() ExchangeRouterConfig {
+	return ExchangeRouterConfig{
+		FailureThreshold: 3,
+		FailoverCooldown: 2 * time.Minute,
+		TakerFees: map[string]float64{
+			"binance":     0.0005,
+			"okx":         0.0005,
+			"hyperliquid": 0.00045,
+			"aster":       0.00035,
+		},
+	}
+}
+
+// LoadExchangeRouterConfig 从系统配置加载跨交易所路由配置
+func LoadExchangeRouterConfig(db *config.Database) ExchangeRouterConfig {
+	cfg := DefaultExchangeRouterConfig()
+	if db == nil {
+		return cfg
+	}
+	get := db.SystemConfigValue
+	if n, err := strconv.Atoi(get("routing_failure_threshold")); err == nil && n > 0 {
+		cfg.FailureThreshold = n
+	}
+	if seconds, err := strconv.Atoi(get("routing_failover_cooldown_seconds")); err == nil && seconds > 0 {
+		cfg.FailoverCooldown = time.Duration(seconds) * time.Second
+	}
+	if raw := get("routing_taker_fees"); raw != "" {
+		var fees map[string]float64
+		if err := json.Unmarshal([]byte(raw), &fees); err != nil {
+			log.Printf("⚠️ 解析 routing_taker_fees 失败: %v", err)
+		} else {
+			for exchange, fee := range fees {
+				cfg.TakerFees[strings.ToLower(exchange)] = fee
+			}
+		}
+	}
+	return cfg
+}
+
+// TakerFee 交易所的吃单费率
+func (c ExchangeRouterConfig) TakerFee(exchange string) float64 {
+	if fee, ok := c.TakerFees[exchange]; ok {
+		return fee
+	}
+	return defaultTakerFeeRate
+}
+
+// RouteVenue 参与路由的一个交易所账户
+type RouteVenue struct {
+	Exchange string // 交易所ID（binance/okx/hyperliquid/aster）
+	Trader   Trader
+}
+
+// venueState 交易所账户的健康状态和最近一次余额/持仓
+type venueState struct {
+	RouteVenue
+	failures    int
+	pausedUntil time.Time
+	lastError   string
+	balance     *Balance
+	positions   []Position
+}
+
+// RouteQuote 一个交易所的报价（路由评分依据）
+type RouteQuote struct {
+	Exchange    string  `json:"exchange"`
+	VenueSymbol string  `json:"venue_symbol"` // 交易所的交易对格式
+	Price       float64 `json:"price"`        // 开多为卖一价，开空为买一价
+	FeeRate     float64 `json:"fee_rate"`
+	EffectivePx float64 `json:"effective_price"` // 含手续费的成交价
+	DepthQty    float64 `json:"depth_qty"`       // 对手盘一档挂单数量（0表示未知）
+	Error       string  `json:"error,omitempty"`
+}
+
+// enoughDepth 一档挂单是否足够成交（未知时视为足够）
+func (q RouteQuote) enoughDepth(quantity float64) bool {
+	return q.DepthQty <= 0 || q.DepthQty >= quantity
+}
+
+// RouteDecision 一次开仓的路由结果
+type RouteDecision struct {
+	Time     time.Time    `json:"time"`
+	Symbol   string       `json:"symbol"`
+	Side     string       `json:"side"` // long / short
+	Quantity float64      `json:"quantity"`
+	Exchange string       `json:"exchange"` // 实际下单的交易所（下单失败时为空）
+	Quotes   []RouteQuote `json:"quotes"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// ExchangeRouter 跨交易所路由：一个交易员持有多个交易所账户
+// 开仓按含手续费的盘口价格和一档深度选择交易所，平仓和止损止盈发往持仓所在的交易所，
+// 余额和持仓按交易所汇总。各交易器内部负责交易对格式转换（convertToOKXSymbol、convertSymbolToHyperliquid等）。
+// 报价失败的交易所在本次路由中跳过，连续失败达到阈值后暂停一段时间（故障转移）；
+// 下单请求本身失败时不转投其他交易所，避免请求实际已成交时重复开仓。
+// 路由模式不实现限价单接口，开仓统一使用市价单。
+type ExchangeRouter struct {
+	config ExchangeRouterConfig
+	clock  func() time.Time
+
+	mu     sync.Mutex
+	venues []*venueState // 顺序即优先级（第一个为主交易所）
+	routes []RouteDecision
+}
+
+// NewExchangeRouter 创建跨交易所路由（venues的第一个为主交易所）
+func NewExchangeRouter(cfg ExchangeRouterConfig, venues []RouteVenue) *ExchangeRouter {
+	r := &ExchangeRouter{config: cfg, clock: time.Now}
+	for _, v := range venues {
+		r.venues = append(r.venues, &venueState{RouteVenue: v})
+	}
+	return r
+}
+
+// Exchanges 参与路由的交易所
+func (r *ExchangeRouter) Exchanges() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.venues))
+	for _, v := range r.venues {
+		names = append(names, v.Exchange)
+	}
+	return names
+}
+
+// venueSymbol 交易对在交易所的格式（仅用于展示，下单时由各交易器转换）
+func venueSymbol(exchange, symbol string) string {
+	switch exchange {
+	case "okx":
+		return convertToOKXSymbol(symbol)
+	case "hyperliquid":
+		return convertSymbolToHyperliquid(symbol)
+	}
+	return symbol
+}
+
+// available 交易所当前是否可用（未暂停）
+func (r *ExchangeRouter) available(v *venueState) bool {
+	return !r.clock().Before(v.pausedUntil)
+}
+
+// recordFailure 记录交易所调用失败，连续失败达到阈值后暂停
+func (r *ExchangeRouter) recordFailure(v *venueState, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v.failures++
+	v.lastError = err.Error()
+	if v.failures >= r.config.FailureThreshold && r.available(v) {
+		v.pausedUntil = r.clock().Add(r.config.FailoverCooldown)
+		log.Printf("🚫 交易所 %s 连续失败 %d 次，暂停路由 %v: %v", v.Exchange, v.failures, r.config.FailoverCooldown, err)
+	}
+}
+
+// recordSuccess 交易所调用成功，清除失败计数
+func (r *ExchangeRouter) recordSuccess(v *venueState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !v.pausedUntil.IsZero() {
+		log.Printf("✅ 交易所 %s 已恢复", v.Exchange)
+	}
+	v.failures = 0
+	v.pausedUntil = time.Time{}
+	v.lastError = ""
+}
+
+// activeVenues 当前可用的交易所（全部暂停时返回全部，仍尝试调用）
+func (r *ExchangeRouter) activeVenues() []*venueState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var active []*venueState
+	for _, v := range r.venues {
+		if r.available(v) {
+			active = append(active, v)
+		}
+	}
+	if len(active) == 0 {
+		return append([]*venueState(nil), r.venues...)
+	}
+	return active
+}
+
+// holdings 持有指定币种和方向的交易所及持仓数量（按最近一次持仓查询）
+func (r *ExchangeRouter) holdings(symbol, side string) ([]*venueState, []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var venues []*venueState
+	var quantities []float64
+	for _, v := range r.venues {
+		if pos, ok := FindPosition(v.positions, symbol, side); ok {
+			venues = append(venues, v)
+			quantities = append(quantities, pos.Quantity)
+		}
+	}
+	return venues, quantities
+}
+
+// GetBalance 汇总各交易所余额（查询失败的交易所使用最近一次余额）
+func (r *ExchangeRouter) GetBalance() (*Balance, error) {
+	total := &Balance{}
+	found := false
+	var errs []string
+	for _, v := range r.snapshotVenues() {
+		balance, err := v.Trader.GetBalance()
+		if err != nil {
+			r.recordFailure(v, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", v.Exchange, err))
+			r.mu.Lock()
+			balance = v.balance
+			r.mu.Unlock()
+			if balance == nil {
+				continue
+			}
+		} else {
+			r.recordSuccess(v)
+			r.mu.Lock()
+			v.balance = balance
+			r.mu.Unlock()
+		}
+		found = true
+		total.TotalWalletBalance += balance.TotalWalletBalance
+		total.AvailableBalance += balance.AvailableBalance
+		total.TotalUnrealizedProfit += balance.TotalUnrealizedProfit
+		total.UsedMargin += balance.UsedMargin
+	}
+	if !found {
+		return nil, fmt.Errorf("所有交易所余额查询失败: %s", strings.Join(errs, "; "))
+	}
+	if len(errs) > 0 {
+		log.Printf("⚠️ 部分交易所余额查询失败，使用最近一次余额: %s", strings.Join(errs, "; "))
+	}
+	return total, nil
+}
+
+// VenueBalances 各交易所最近一次查询的余额（GetBalance之后调用）
+func (r *ExchangeRouter) VenueBalances() []decision.VenueBalance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	balances := make([]decision.VenueBalance, 0, len(r.venues))
+	for _, v := range r.venues {
+		vb := decision.VenueBalance{Exchange: v.Exchange, Available: r.available(v)}
+		if v.balance != nil {
+			vb.TotalEquity = v.balance.TotalEquity()
+			vb.AvailableBalance = v.balance.AvailableBalance
+		}
+		balances = append(balances, vb)
+	}
+	return balances
+}
+
+// GetPositions 汇总各交易所持仓
+// 查询失败的交易所上次有持仓时返回错误（避免持仓跟踪把它们当作已平仓），否则跳过
+func (r *ExchangeRouter) GetPositions() ([]Position, error) {
+	var all []Position
+	for _, v := range r.snapshotVenues() {
+		positions, err := v.Trader.GetPositions()
+		if err != nil {
+			r.recordFailure(v, err)
+			r.mu.Lock()
+			held := len(v.positions)
+			r.mu.Unlock()
+			if held > 0 {
+				return nil, fmt.Errorf("交易所 %s 持仓查询失败（有 %d 个持仓）: %w", v.Exchange, held, err)
+			}
+			log.Printf("⚠️ 交易所 %s 持仓查询失败（无持仓，跳过）: %v", v.Exchange, err)
+			continue
+		}
+		r.recordSuccess(v)
+		r.mu.Lock()
+		v.positions = positions
+		r.mu.Unlock()
+		all = append(all, positions...)
+	}
+	return mergePositions(all), nil
+}
+
+// mergePositions 合并不同交易所同币种同方向的持仓（数量相加，开仓价按数量加权）
+func mergePositions(positions []Position) []Position {
+	merged := make([]Position, 0, len(positions))
+	index := make(map[string]int, len(positions))
+	for _, pos := range positions {
+		key := pos.Symbol + "_" + pos.Side
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, pos)
+			continue
+		}
+		m := &merged[i]
+		qty := m.Quantity + pos.Quantity
+		if qty > 0 {
+			m.EntryPrice = (m.EntryPrice*m.Quantity + pos.EntryPrice*pos.Quantity) / qty
+		}
+		m.Quantity = qty
+		m.UnrealizedProfit += pos.UnrealizedProfit
+		// 强平价取离标记价更近的一个
+		if pos.LiquidationPrice > 0 && (m.LiquidationPrice <= 0 || math.Abs(pos.MarkPrice-pos.LiquidationPrice) < math.Abs(m.MarkPrice-m.LiquidationPrice)) {
+			m.LiquidationPrice = pos.LiquidationPrice
+		}
+	}
+	return merged
+}
+
+// snapshotVenues 所有交易所（复制切片，调用交易所API时不持锁）
+func (r *ExchangeRouter) snapshotVenues() []*venueState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*venueState(nil), r.venues...)
+}
+
+// OpenLong 开多仓（按路由选择交易所）
+func (r *ExchangeRouter) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return r.open(symbol, "long", quantity, leverage, "")
+}
+
+// OpenShort 开空仓（按路由选择交易所）
+func (r *ExchangeRouter) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return r.open(symbol, "short", quantity, leverage, "")
+}
+
+// PlaceMarketOrder 按动作下市价单，本地订单ID随订单发往所选交易所（实现ClientOrderPlacer）
+func (r *ExchangeRouter) PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	switch action {
+	case "open_long":
+		return r.open(symbol, "long", quantity, leverage, clientOrderID)
+	case "open_short":
+		return r.open(symbol, "short", quantity, leverage, clientOrderID)
+	case "close_long":
+		return r.close(symbol, "long", quantity, clientOrderID)
+	case "close_short":
+		return r.close(symbol, "short", quantity, clientOrderID)
+	}
+	return nil, fmt.Errorf("未知的下单动作: %s", action)
+}
+
+// open 选择交易所并下单（已有同方向持仓时加仓到同一交易所）
+func (r *ExchangeRouter) open(symbol, side string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	route := RouteDecision{Time: r.clock(), Symbol: symbol, Side: side, Quantity: quantity}
+	var venue *venueState
+	if held, _ := r.holdings(symbol, side); len(held) > 0 {
+		venue = held[0]
+	} else {
+		var quotes []RouteQuote
+		venue, quotes = r.selectVenue(symbol, side, quantity)
+		route.Quotes = quotes
+	}
+	if venue == nil {
+		route.Error = "所有交易所报价失败"
+		r.recordRoute(route)
+		return nil, fmt.Errorf("%s 无可用交易所: 所有交易所报价失败", symbol)
+	}
+	route.Exchange = venue.Exchange
+
+	result, err := placeMarketOrder(venue.Trader, "open_"+side, symbol, quantity, leverage, clientOrderID)
+	if err != nil {
+		r.recordFailure(venue, err)
+		route.Error = err.Error()
+		r.recordRoute(route)
+		return nil, fmt.Errorf("[%s] %w", venue.Exchange, err)
+	}
+	r.recordSuccess(venue)
+	r.recordRoute(route)
+	log.Printf("🔀 %s %s 路由到 %s (%s)", symbol, side, venue.Exchange, venueSymbol(venue.Exchange, symbol))
+	return result, nil
+}
+
+// selectVenue 按含手续费的成交价选择交易所，一档深度不足的交易所排在后面
+func (r *ExchangeRouter) selectVenue(symbol, side string, quantity float64) (*venueState, []RouteQuote) {
+	type candidate struct {
+		venue *venueState
+		quote RouteQuote
+		order int
+	}
+	var candidates []candidate
+	var quotes []RouteQuote
+	for i, v := range r.activeVenues() {
+		quote, err := r.quote(v, symbol, side)
+		if err != nil {
+			r.recordFailure(v, err)
+			quote.Error = err.Error()
+			quotes = append(quotes, quote)
+			continue
+		}
+		r.recordSuccess(v)
+		quotes = append(quotes, quote)
+		candidates = append(candidates, candidate{venue: v, quote: quote, order: i})
+	}
+	if len(candidates) == 0 {
+		return nil, quotes
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].quote, candidates[j].quote
+		if da, db := a.enoughDepth(quantity), b.enoughDepth(quantity); da != db {
+			return da
+		}
+		if a.EffectivePx != b.EffectivePx {
+			// 开多买入越便宜越好，开空卖出越贵越好
+			if side == "short" {
+				return a.EffectivePx > b.EffectivePx
+			}
+			return a.EffectivePx < b.EffectivePx
+		}
+		return candidates[i].order < candidates[j].order
+	})
+	return candidates[0].venue, quotes
+}
+
+// quote 获取交易所的开仓报价（支持盘口的交易所使用对手价，否则使用最新价）
+func (r *ExchangeRouter) quote(v *venueState, symbol, side string) (RouteQuote, error) {
+	quote := RouteQuote{Exchange: v.Exchange, VenueSymbol: venueSymbol(v.Exchange, symbol), FeeRate: r.config.TakerFee(v.Exchange)}
+	if book, ok := v.Trader.(LimitOrderPlacer); ok {
+		bid, ask, err := book.GetBestBidAsk(symbol)
+		if err != nil {
+			return quote, err
+		}
+		quote.Price = ask
+		if side == "short" {
+			quote.Price = bid
+		}
+	} else {
+		price, err := v.Trader.GetMarketPrice(symbol)
+		if err != nil {
+			return quote, err
+		}
+		quote.Price = price
+	}
+	if quote.Price <= 0 {
+		return quote, fmt.Errorf("%s 报价无效: %.6f", symbol, quote.Price)
+	}
+
+	if side == "short" {
+		quote.EffectivePx = quote.Price * (1 - quote.FeeRate)
+	} else {
+		quote.EffectivePx = quote.Price * (1 + quote.FeeRate)
+	}
+	if depth, ok := v.Trader.(BookDepthProvider); ok {
+		if bidQty, askQty, err := depth.GetBookDepth(symbol); err == nil {
+			quote.DepthQty = askQty
+			if side == "short" {
+				quote.DepthQty = bidQty
+			}
+		}
+	}
+	return quote, nil
+}
+
+// recordRoute 保存路由记录
+func (r *ExchangeRouter) recordRoute(route RouteDecision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route)
+	if len(r.routes) > maxRouteHistory {
+		r.routes = r.routes[len(r.routes)-maxRouteHistory:]
+	}
+}
+
+// CloseLong 平多仓（发往持仓所在的交易所）
+func (r *ExchangeRouter) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	return r.close(symbol, "long", quantity, "")
+}
+
+// CloseShort 平空仓（发往持仓所在的交易所）
+func (r *ExchangeRouter) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	return r.close(symbol, "short", quantity, "")
+}
+
+// close 按持仓所在交易所平仓（quantity=0表示全部平仓，部分平仓时依次从各交易所平掉）
+// 各交易所的平仓单使用同一个本地订单ID（不同交易所之间不冲突）
+func (r *ExchangeRouter) close(symbol, side string, quantity float64, clientOrderID string) (*OrderResult, error) {
+	venues, quantities := r.holdings(symbol, side)
+	if len(venues) == 0 {
+		// 持仓缓存可能过期，刷新后再查
+		if _, err := r.GetPositions(); err != nil {
+			return nil, err
+		}
+		venues, quantities = r.holdings(symbol, side)
+	}
+	if len(venues) == 0 {
+		return nil, fmt.Errorf("没有找到 %s 的%s仓", symbol, side)
+	}
+
+	merged := &OrderResult{Symbol: symbol}
+	var pricedQty float64
+	remaining := quantity
+	for i, v := range venues {
+		qty := 0.0 // 全部平仓
+		if quantity > 0 {
+			if remaining <= 0 {
+				break
+			}
+			qty = math.Min(remaining, quantities[i])
+			remaining -= qty
+			if qty >= quantities[i] {
+				qty = 0
+			}
+		}
+
+		result, err := placeMarketOrder(v.Trader, "close_"+side, symbol, qty, 0, clientOrderID)
+		if err != nil {
+			r.recordFailure(v, err)
+			return nil, fmt.Errorf("[%s] %w", v.Exchange, err)
+		}
+		r.recordSuccess(v)
+		if result == nil {
+			continue
+		}
+		merged.OrderID, merged.Status = result.OrderID, result.Status
+		merged.Fee += result.Fee
+		if result.ExecutedQty > 0 && result.AvgPrice > 0 {
+			merged.AvgPrice = (merged.AvgPrice*pricedQty + result.AvgPrice*result.ExecutedQty) / (pricedQty + result.ExecutedQty)
+			pricedQty += result.ExecutedQty
+		}
+		merged.ExecutedQty += result.ExecutedQty
+	}
+	return merged, nil
+}
+
+// SetLeverage 在所有可用交易所设置杠杆（全部失败时返回错误）
+func (r *ExchangeRouter) SetLeverage(symbol string, leverage int) error {
+	return r.broadcast("设置杠杆", func(t Trader) error { return t.SetLeverage(symbol, leverage) })
+}
+
+// SetMarginMode 在所有可用交易所设置仓位模式（全部失败时返回错误）
+func (r *ExchangeRouter) SetMarginMode(symbol string, isCrossMargin bool) error {
+	return r.broadcast("设置仓位模式", func(t Trader) error { return t.SetMarginMode(symbol, isCrossMargin) })
+}
+
+// broadcast 在所有可用交易所执行同一操作
+func (r *ExchangeRouter) broadcast(op string, fn func(t Trader) error) error {
+	var errs []string
+	venues := r.activeVenues()
+	for _, v := range venues {
+		if err := fn(v.Trader); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", v.Exchange, err))
+		}
+	}
+	if len(errs) == len(venues) && len(errs) > 0 {
+		return fmt.Errorf("%s失败: %s", op, strings.Join(errs, "; "))
+	}
+	if len(errs) > 0 {
+		log.Printf("⚠️ 部分交易所%s失败: %s", op, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// GetMarketPrice 获取市场价格（按优先级使用第一个可用的交易所）
+func (r *ExchangeRouter) GetMarketPrice(symbol string) (float64, error) {
+	var errs []string
+	for _, v := range r.activeVenues() {
+		price, err := v.Trader.GetMarketPrice(symbol)
+		if err == nil {
+			return price, nil
+		}
+		r.recordFailure(v, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", v.Exchange, err))
+	}
+	return 0, fmt.Errorf("获取 %s 价格失败: %s", symbol, strings.Join(errs, "; "))
+}
+
+// SetStopLoss 在持仓所在交易所设置止损单（多个交易所持仓时按数量比例拆分）
+func (r *ExchangeRouter) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return r.protect(symbol, positionSide, quantity, func(t Trader, qty float64) error {
+		return t.SetStopLoss(symbol, positionSide, qty, stopPrice)
+	})
+}
+
+// SetTakeProfit 在持仓所在交易所设置止盈单（多个交易所持仓时按数量比例拆分）
+func (r *ExchangeRouter) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return r.protect(symbol, positionSide, quantity, func(t Trader, qty float64) error {
+		return t.SetTakeProfit(symbol, positionSide, qty, takeProfitPrice)
+	})
+}
+
+// protect 按持仓所在交易所下条件单
+func (r *ExchangeRouter) protect(symbol, positionSide string, quantity float64, fn func(t Trader, qty float64) error) error {
+	side := strings.ToLower(positionSide)
+	venues, quantities := r.holdings(symbol, side)
+	if len(venues) == 0 {
+		if _, err := r.GetPositions(); err != nil {
+			return err
+		}
+		venues, quantities = r.holdings(symbol, side)
+	}
+	if len(venues) == 0 {
+		return fmt.Errorf("没有找到 %s 的%s仓", symbol, side)
+	}
+
+	var total float64
+	for _, q := range quantities {
+		total += q
+	}
+	for i, v := range venues {
+		qty := quantity
+		if len(venues) > 1 && total > 0 {
+			qty = quantity * quantities[i] / total
+		}
+		if err := fn(v.Trader, qty); err != nil {
+			return fmt.Errorf("[%s] %w", v.Exchange, err)
+		}
+	}
+	return nil
+}
+
+// CancelAllOrders 在所有可用交易所取消该币种的挂单（全部失败时返回错误）
+func (r *ExchangeRouter) CancelAllOrders(symbol string) error {
+	return r.broadcast("取消挂单", func(t Trader) error { return t.CancelAllOrders(symbol) })
+}
+
+// GetOpenOrders 汇总各交易所挂单（实现StopOrderCanceller，Exchange字段标记挂单所在交易所，撤单时据此路由）
+// 任一交易所查询失败时返回错误，避免调用方漏撤该交易所的条件单
+func (r *ExchangeRouter) GetOpenOrders(symbol string) ([]*Order, error) {
+	var orders []*Order
+	for _, v := range r.snapshotVenues() {
+		canceller, ok := v.Trader.(StopOrderCanceller)
+		if !ok {
+			continue
+		}
+		list, err := canceller.GetOpenOrders(symbol)
+		if err != nil {
+			r.recordFailure(v, err)
+			return nil, fmt.Errorf("%s 获取挂单失败: %w", v.Exchange, err)
+		}
+		for _, o := range list {
+			routed := *o
+			routed.Exchange = v.Exchange
+			orders = append(orders, &routed)
+		}
+	}
+	return orders, nil
+}
+
+// CancelStopOrder 撤销止损/止盈条件单（实现StopOrderCanceller，发往挂单所在的交易所）
+func (r *ExchangeRouter) CancelStopOrder(order *Order) error {
+	for _, v := range r.snapshotVenues() {
+		if v.Exchange != order.Exchange {
+			continue
+		}
+		canceller, ok := v.Trader.(StopOrderCanceller)
+		if !ok {
+			return fmt.Errorf("%s 不支持撤销单个条件单", v.Exchange)
+		}
+		return canceller.CancelStopOrder(order)
+	}
+	return fmt.Errorf("未找到挂单所在的交易所: %s", order.Exchange)
+}
+
+// FormatQuantity 格式化数量（持仓所在或主交易所的精度）
+func (r *ExchangeRouter) FormatQuantity(symbol string, quantity float64) (string, error) {
+	for _, side := range []string{"long", "short"} {
+		if venues, _ := r.holdings(symbol, side); len(venues) > 0 {
+			return venues[0].Trader.FormatQuantity(symbol, quantity)
+		}
+	}
+	return r.activeVenues()[0].Trader.FormatQuantity(symbol, quantity)
+}
+
+// GetFillHistory 汇总各交易所的成交记录（实现FillHistoryProvider）
+func (r *ExchangeRouter) GetFillHistory(symbol string, since time.Time) ([]Fill, error) {
+	var fills []Fill
+	for _, v := range r.snapshotVenues() {
+		provider, ok := v.Trader.(FillHistoryProvider)
+		if !ok {
+			continue
+		}
+		venueFills, err := provider.GetFillHistory(symbol, since)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", v.Exchange, err)
+		}
+		fills = append(fills, venueFills...)
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+	return fills, nil
+}
+
+// GetFundingPayments 汇总各交易所的资金费（实现FundingHistoryProvider）
+func (r *ExchangeRouter) GetFundingPayments(symbol string, since time.Time) (float64, error) {
+	var total float64
+	for _, v := range r.snapshotVenues() {
+		provider, ok := v.Trader.(FundingHistoryProvider)
+		if !ok {
+			continue
+		}
+		amount, err := provider.GetFundingPayments(symbol, since)
+		if err != nil {
+			return 0, fmt.Errorf("[%s] %w", v.Exchange, err)
+		}
+		total += amount
+	}
+	return total, nil
+}
+
+// GetIncomeHistory 汇总各交易所的手续费/资金费流水（实现IncomeLedgerProvider，流水ID加交易所前缀避免冲突）
+func (r *ExchangeRouter) GetIncomeHistory(since time.Time) ([]IncomeRecord, error) {
+	var records []IncomeRecord
+	for _, v := range r.snapshotVenues() {
+		provider, ok := v.Trader.(IncomeLedgerProvider)
+		if !ok {
+			continue
+		}
+		venueRecords, err := provider.GetIncomeHistory(since)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", v.Exchange, err)
+		}
+		for _, rec := range venueRecords {
+			rec.Ref = v.Exchange + ":" + rec.Ref
+			records = append(records, rec)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, nil
+}
+
+// GetStatus 路由状态（各交易所健康状态和最近的路由记录）
+func (r *ExchangeRouter) GetStatus() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	venues := make([]map[string]interface{}, 0, len(r.venues))
+	for _, v := range r.venues {
+		status := map[string]interface{}{
+			"exchange":  v.Exchange,
+			"available": r.available(v),
+			"failures":  v.failures,
+			"positions": len(v.positions),
+		}
+		if v.lastError != "" {
+			status["last_error"] = v.lastError
+		}
+		if !v.pausedUntil.IsZero() {
+			status["paused_until"] = v.pausedUntil
+		}
+		if v.balance != nil {
+			status["total_equity"] = v.balance.TotalEquity()
+			status["available_balance"] = v.balance.AvailableBalance
+		}
+		venues = append(venues, status)
+	}
+	return map[string]interface{}{
+		"venues":        venues,
+		"recent_routes": append([]RouteDecision(nil), r.routes...),
+	}
+}
+
+// ApplyExchangeCredentials 把交易所配置的凭证写入交易员配置中对应交易所的字段
+func ApplyExchangeCredentials(cfg *AutoTraderConfig, ex *config.ExchangeConfig) {
+	switch ex.ID {
+	case "binance":
+		cfg.BinanceAPIKey = ex.APIKey
+		cfg.BinanceSecretKey = ex.SecretKey
+	case "hyperliquid":
+		cfg.HyperliquidPrivateKey = ex.APIKey // hyperliquid用APIKey存储private key
+		cfg.HyperliquidWalletAddr = ex.HyperliquidWalletAddr
+		cfg.HyperliquidTestnet = ex.Testnet
+	case "aster":
+		cfg.AsterUser = ex.AsterUser
+		cfg.AsterSigner = ex.AsterSigner
+		cfg.AsterPrivateKey = ex.AsterPrivateKey
+	case "okx":
+		cfg.OKXAPIKey = ex.APIKey
+		cfg.OKXSecretKey = ex.SecretKey
+		cfg.OKXPassphrase = ex.OKXPassphrase
+	}
+}
+
+// newExchangeRouterTrader 创建跨交易所路由（主交易所在前），额外交易所的凭证从用户的交易所配置读取
+// 额外交易所均不可用时直接使用主交易所
+func newExchangeRouterTrader(cfg AutoTraderConfig, primary Trader, exchanges []string) Trader {
+	if cfg.Exchange == "paper" {
+		log.Printf("⚠️ [%s] 纸面交易不支持跨交易所路由，忽略 %v", cfg.Name, exchanges)
+		return primary
+	}
+
+	var exchangeCfgs []*config.ExchangeConfig
+	if cfg.Database != nil && cfg.UserID != "" {
+		var err error
+		if exchangeCfgs, err = cfg.Database.GetExchanges(cfg.UserID); err != nil {
+			log.Printf("⚠️ [%s] 获取交易所配置失败: %v", cfg.Name, err)
+		}
+	}
+
+	venues := []RouteVenue{{Exchange: cfg.Exchange, Trader: primary}}
+	seen := map[string]bool{cfg.Exchange: true}
+	for _, name := range exchanges {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "paper" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		venueCfg := cfg
+		venueCfg.Exchange = name
+		if ex := findExchangeConfig(exchangeCfgs, name); ex != nil {
+			if !ex.Enabled {
+				log.Printf("⚠️ [%s] 路由交易所 %s 未启用，跳过", cfg.Name, name)
+				continue
+			}
+			ApplyExchangeCredentials(&venueCfg, ex)
+		}
+		t, err := newExchangeTrader(venueCfg)
+		if err != nil {
+			log.Printf("⚠️ [%s] 路由交易所 %s 初始化失败，跳过: %v", cfg.Name, name, err)
+			continue
+		}
+		venues = append(venues, RouteVenue{Exchange: name, Trader: t})
+	}
+	if len(venues) < 2 {
+		return primary
+	}
+
+	router := NewExchangeRouter(LoadExchangeRouterConfig(cfg.Database), venues)
+	log.Printf("🔀 [%s] 启用跨交易所路由: %s", cfg.Name, strings.Join(router.Exchanges(), ", "))
+	return router
+}
+
+// findExchangeConfig 按交易所ID查找配置
+func findExchangeConfig(exchanges []*config.ExchangeConfig, id string) *config.ExchangeConfig {
+	for _, ex := range exchanges {
+		if ex.ID == id {
+			return ex
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyVenue 可模拟API故障的交易所
+type flakyVenue struct {
+	*SimulatedTrader
+	down     bool
+	bidDepth float64
+	askDepth float64
+}
+
+var errVenueDown = errors.New("connection refused")
+
+func (f *flakyVenue) GetBestBidAsk(symbol string) (float64, float64, error) {
+	if f.down {
+		return 0, 0, errVenueDown
+	}
+	return f.SimulatedTrader.GetBestBidAsk(symbol)
+}
+
+func (f *flakyVenue) GetBalance() (*Balance, error) {
+	if f.down {
+		return nil, errVenueDown
+	}
+	return f.SimulatedTrader.GetBalance()
+}
+
+func (f *flakyVenue) GetPositions() ([]Position, error) {
+	if f.down {
+		return nil, errVenueDown
+	}
+	return f.SimulatedTrader.GetPositions()
+}
+
+func (f *flakyVenue) GetBookDepth(symbol string) (float64, float64, error) {
+	return f.bidDepth, f.askDepth, nil
+}
+
+// newTestRouter binance和okx两个模拟交易所，okx价格更低
+func newTestRouter() (*ExchangeRouter, *flakyVenue, *flakyVenue, *time.Time) {
+	binance := &flakyVenue{SimulatedTrader: newTestSimulatedTrader()}
+	okx := &flakyVenue{SimulatedTrader: newTestSimulatedTrader()}
+	binance.SetPrice("BTCUSDT", 100)
+	okx.SetPrice("BTCUSDT", 99.9)
+
+	cfg := DefaultExchangeRouterConfig()
+	cfg.TakerFees = map[string]float64{"binance": 0.0005, "okx": 0.0005}
+	router := NewExchangeRouter(cfg, []RouteVenue{{Exchange: "binance", Trader: binance}, {Exchange: "okx", Trader: okx}})
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	router.clock = func() time.Time { return now }
+	return router, binance, okx, &now
+}
+
+func TestExchangeRouterSelectsBestVenue(t *testing.T) {
+	router, binance, okx, _ := newTestRouter()
+
+	if _, err := router.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if positions, _ := okx.GetPositions(); len(positions) != 1 {
+		t.Fatalf("开多应路由到价格更低的okx: %+v", positions)
+	}
+
+	// 开空卖出价越高越好
+	binance.SetPrice("ETHUSDT", 50.1)
+	okx.SetPrice("ETHUSDT", 50)
+	if _, err := router.OpenShort("ETHUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, ok := FindPosition(mustPositions(t, binance), "ETHUSDT", "short"); !ok {
+		t.Error("开空应路由到价格更高的binance")
+	}
+
+	// 手续费更低的交易所胜出
+	router.config.TakerFees["okx"] = 0.003
+	binance.SetPrice("SOLUSDT", 100)
+	okx.SetPrice("SOLUSDT", 99.9)
+	if _, err := router.OpenLong("SOLUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, ok := FindPosition(mustPositions(t, binance), "SOLUSDT", "long"); !ok {
+		t.Error("计入手续费后binance更便宜")
+	}
+
+	// 一档深度不足的交易所排在后面
+	okx.askDepth, router.config.TakerFees["okx"] = 0.5, 0.0005
+	binance.SetPrice("BNBUSDT", 100)
+	okx.SetPrice("BNBUSDT", 99)
+	if _, err := router.OpenLong("BNBUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, ok := FindPosition(mustPositions(t, binance), "BNBUSDT", "long"); !ok {
+		t.Error("okx深度不足时应路由到binance")
+	}
+
+	status := router.GetStatus()
+	if routes := status["recent_routes"].([]RouteDecision); len(routes) != 4 || routes[0].Exchange != "okx" || len(routes[0].Quotes) != 2 {
+		t.Errorf("应记录路由结果和报价: %+v", routes)
+	}
+}
+
+func TestExchangeRouterAggregatesAndClosesOnHoldingVenue(t *testing.T) {
+	router, binance, okx, _ := newTestRouter()
+	if _, err := router.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+
+	balance, err := router.GetBalance()
+	if err != nil {
+		t.Fatalf("获取余额失败: %v", err)
+	}
+	if balance.TotalWalletBalance < 1999 || balance.TotalWalletBalance > 2000 {
+		t.Errorf("余额应为两个交易所之和: %+v", balance)
+	}
+	if venues := router.VenueBalances(); len(venues) != 2 || venues[0].Exchange != "binance" || !venues[1].Available {
+		t.Errorf("应返回各交易所余额: %+v", venues)
+	}
+
+	positions, err := router.GetPositions()
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("应汇总持仓: %v %+v", err, positions)
+	}
+
+	// 已有持仓时加仓到同一交易所（即使其他交易所更便宜）
+	binance.SetPrice("BTCUSDT", 99)
+	if _, err := router.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("加仓失败: %v", err)
+	}
+	if pos, _ := FindPosition(mustPositions(t, okx), "BTCUSDT", "long"); !approxEqual(pos.Quantity, 2) {
+		t.Errorf("加仓应发往持仓所在交易所: %+v", pos)
+	}
+
+	if err := router.SetStopLoss("BTCUSDT", "LONG", 2, 90); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	if _, err := router.CloseLong("BTCUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	if positions := mustPositions(t, okx); len(positions) != 0 {
+		t.Errorf("平仓应发往持仓所在交易所: %+v", positions)
+	}
+}
+
+func TestExchangeRouterFailover(t *testing.T) {
+	router, _, okx, now := newTestRouter()
+	okx.down = true
+
+	// okx报价失败时转到binance，连续失败3次后暂停
+	for i := 0; i < 3; i++ {
+		if _, err := router.OpenLong("BTCUSDT", 0.1, 5); err != nil {
+			t.Fatalf("应故障转移到binance: %v", err)
+		}
+	}
+	if venues := router.VenueBalances(); venues[1].Available {
+		t.Error("okx连续失败后应暂停")
+	}
+
+	// okx无持仓时查询失败不影响持仓汇总
+	if positions, err := router.GetPositions(); err != nil || len(positions) != 1 {
+		t.Errorf("应跳过无持仓的故障交易所: %v %+v", err, positions)
+	}
+
+	// 暂停期结束且恢复后重新参与路由
+	okx.down = false
+	*now = now.Add(3 * time.Minute)
+	if _, err := router.OpenShort("BTCUSDT", 0.1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if venues := router.VenueBalances(); !venues[1].Available {
+		t.Error("okx应恢复")
+	}
+}
+
+func TestExchangeRouterPositionsErrorWhenHoldingVenueDown(t *testing.T) {
+	router, _, okx, _ := newTestRouter()
+	if _, err := router.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, err := router.GetPositions(); err != nil {
+		t.Fatalf("获取持仓失败: %v", err)
+	}
+	if _, err := router.GetBalance(); err != nil {
+		t.Fatalf("获取余额失败: %v", err)
+	}
+
+	okx.down = true
+	if _, err := router.GetPositions(); err == nil {
+		t.Error("有持仓的交易所查询失败时应返回错误，避免误判为已平仓")
+	}
+	if balance, err := router.GetBalance(); err != nil || balance.TotalWalletBalance < 1999 {
+		t.Errorf("余额查询失败时应使用最近一次余额: %v %+v", err, balance)
+	}
+}
+
+func mustPositions(t *testing.T, tr Trader) []Position {
+	t.Helper()
+	positions, err := tr.GetPositions()
+	if err != nil {
+		t.Fatalf("获取持仓失败: %v", err)
+	}
+	return positions
+}
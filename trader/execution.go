@@ -0,0 +1,321 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/config"
+	"nofx/decision"
+)
+
+// executionPollInterval 限价单等待成交时的查询间隔
+const executionPollInterval = 2 * time.Second
+
+// LimitOrderPlacer 支持限价单的交易器（中间价限价、只做Maker、TWAP执行依赖此接口）
+// 未实现该接口或未实现OrderQuerier的交易器只能使用市价单
+type LimitOrderPlacer interface {
+	// PlaceLimitOrder 下限价开仓单（postOnly=true时只做Maker，会立即成交的订单被交易所拒绝或撤销）
+	// clientOrderID为订单管理器生成的本地订单ID，交易所支持时随订单发送（为空表示不指定）
+	PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error)
+
+	// CancelOrder 撤销单个订单
+	CancelOrder(symbol, orderID string) error
+
+	// GetBestBidAsk 获取买一/卖一价
+	GetBestBidAsk(symbol string) (bid, ask float64, err error)
+}
+
+// ExecutionConfig 开仓执行配置
+type ExecutionConfig struct {
+	Mode            decision.ExecutionMode
+	LimitTimeout    time.Duration // 限价单等待成交的最长时间，超时撤单
+	MarketFallback  bool          // 限价单超时未成交的部分是否转市价
+	TWAPSlices      int           // TWAP拆分笔数
+	TWAPInterval    time.Duration // TWAP每笔之间的间隔
+	TWAPMinNotional float64       // 仓位价值低于该值时不拆分（USDT）
+}
+
+// DefaultExecutionConfig 默认执行配置（市价单）
+func DefaultExecutionConfig() ExecutionConfig {
+	return ExecutionConfig{
+		Mode:            decision.ExecutionMarket,
+		LimitTimeout:    30 * time.Second,
+		MarketFallback:  true,
+		TWAPSlices:      4,
+		TWAPInterval:    20 * time.Second,
+		TWAPMinNotional: 2000,
+	}
+}
+
+// LoadExecutionConfig 从系统配置加载执行参数，mode为交易员配置的执行方式（为空表示市价单）
+func LoadExecutionConfig(db *config.Database, mode string) ExecutionConfig {
+	cfg := DefaultExecutionConfig()
+	if parsed, err := decision.ParseExecutionMode(mode); err == nil {
+		cfg.Mode = parsed
+	} else {
+		log.Printf("⚠️ %v，使用市价单", err)
+	}
+	if db == nil {
+		return cfg
+	}
+
+	get := db.SystemConfigValue
+	if seconds, err := strconv.Atoi(get("execution_limit_timeout_seconds")); err == nil && seconds > 0 {
+		cfg.LimitTimeout = time.Duration(seconds) * time.Second
+	}
+	if value := get("execution_market_fallback"); value != "" {
+		cfg.MarketFallback = value == "true"
+	}
+	if slices, err := strconv.Atoi(get("execution_twap_slices")); err == nil && slices > 0 {
+		cfg.TWAPSlices = slices
+	}
+	if seconds, err := strconv.Atoi(get("execution_twap_interval_seconds")); err == nil && seconds >= 0 {
+		cfg.TWAPInterval = time.Duration(seconds) * time.Second
+	}
+	if notional, err := strconv.ParseFloat(get("execution_twap_min_notional_usd"), 64); err == nil && notional >= 0 {
+		cfg.TWAPMinNotional = notional
+	}
+	return cfg
+}
+
+// Execution 一次开仓执行的汇总结果（可能包含多笔子订单）
+type Execution struct {
+	Mode      decision.ExecutionMode
+	Orders    []*Order
+	Quantity  float64 // 目标数量
+	FilledQty float64
+	AvgPrice  float64 // 成交均价（按成交量加权）
+	Fee       float64
+
+	pricedQty float64 // 已知成交价的数量（计算均价用）
+}
+
+// add 累加子订单的成交
+// 市价单下单结果未返回成交量时按全部成交计（由后续对账修正）
+func (e *Execution) add(order *Order) {
+	if order == nil {
+		return
+	}
+	e.Orders = append(e.Orders, order)
+
+	filled := order.FilledQty
+	if filled == 0 && (order.Status == OrderStatusFilled || order.Type == OrderTypeMarket && !order.Status.IsTerminal()) {
+		filled = order.Quantity
+	}
+	if filled <= 0 {
+		return
+	}
+	if order.AvgPrice > 0 {
+		e.AvgPrice = (e.AvgPrice*e.pricedQty + order.AvgPrice*filled) / (e.pricedQty + filled)
+		e.pricedQty += filled
+	}
+	e.FilledQty += filled
+	e.Fee += order.Fee
+}
+
+// LastOrder 最后一笔子订单（为nil时表示没有下单）
+func (e *Execution) LastOrder() *Order {
+	if len(e.Orders) == 0 {
+		return nil
+	}
+	return e.Orders[len(e.Orders)-1]
+}
+
+// Open 按执行配置开仓（positionSide为LONG/SHORT）
+// 交易器不支持限价单或订单查询时退回市价单
+func (m *OrderManager) Open(symbol, positionSide string, quantity float64, leverage int, cfg ExecutionConfig) (*Execution, error) {
+	exec := &Execution{Mode: cfg.Mode, Quantity: quantity}
+	if cfg.Mode == "" || cfg.Mode == decision.ExecutionMarket {
+		exec.Mode = decision.ExecutionMarket
+		return exec, m.openMarket(exec, symbol, positionSide, quantity, leverage)
+	}
+
+	placer, ok := m.trader.(LimitOrderPlacer)
+	if _, canQuery := m.trader.(OrderQuerier); !ok || !canQuery {
+		log.Printf("⚠️ [%s] 交易所不支持限价执行，%s 改用市价单", m.traderID, cfg.Mode)
+		exec.Mode = decision.ExecutionMarket
+		return exec, m.openMarket(exec, symbol, positionSide, quantity, leverage)
+	}
+
+	if cfg.Mode == decision.ExecutionTWAP {
+		return exec, m.openTWAP(exec, placer, symbol, positionSide, quantity, leverage, cfg)
+	}
+	return exec, m.openLimit(exec, placer, symbol, positionSide, quantity, leverage, cfg.Mode == decision.ExecutionPostOnly, cfg.LimitTimeout, cfg.MarketFallback)
+}
+
+// openMarket 市价开仓
+func (m *OrderManager) openMarket(exec *Execution, symbol, positionSide string, quantity float64, leverage int) error {
+	var order *Order
+	var err error
+	if positionSide == "SHORT" {
+		order, err = m.OpenShort(symbol, quantity, leverage)
+	} else {
+		order, err = m.OpenLong(symbol, quantity, leverage)
+	}
+	exec.add(order)
+	return err
+}
+
+// openLimit 限价开仓：limit_mid挂在盘口中间价，post_only挂在买一（多）/卖一（空）
+// 超时未成交则撤单，剩余数量按配置转市价
+func (m *OrderManager) openLimit(exec *Execution, placer LimitOrderPlacer, symbol, positionSide string, quantity float64, leverage int, postOnly bool, timeout time.Duration, fallback bool) error {
+	bid, ask, err := placer.GetBestBidAsk(symbol)
+	if err != nil || bid <= 0 || ask <= 0 {
+		log.Printf("⚠️ [%s] 获取 %s 盘口失败(%v)，改用市价单", m.traderID, symbol, err)
+		return m.openMarket(exec, symbol, positionSide, quantity, leverage)
+	}
+
+	price := (bid + ask) / 2
+	if postOnly {
+		price = bid
+		if positionSide == "SHORT" {
+			price = ask
+		}
+	}
+
+	action := "open_" + strings.ToLower(positionSide)
+	order, err := m.submit(symbol, action, positionSide, OrderTypeLimit, quantity, price, func(clientOrderID string) (*OrderResult, error) {
+		return placer.PlaceLimitOrder(symbol, positionSide, quantity, price, leverage, postOnly, clientOrderID)
+	})
+	if err == nil {
+		order = m.awaitFill(placer, order, timeout)
+	}
+	exec.add(order)
+
+	remaining := quantity - order.FilledQty
+	if remaining > 0 && fallback && m.tradable(symbol, remaining) {
+		log.Printf("⏱️ [%s] %s 限价单未完全成交(%s)，剩余 %.6f 转市价", m.traderID, symbol, order.Status, remaining)
+		return m.openMarket(exec, symbol, positionSide, remaining, leverage)
+	}
+	if err != nil {
+		return err
+	}
+	if order.FilledQty == 0 {
+		return fmt.Errorf("限价单未成交: %s", order.String())
+	}
+	return nil
+}
+
+// openTWAP 分批执行：按时间均匀拆分为多笔中间价限价单，每笔只暴露一部分数量（冰山）
+// 按累计进度计算每笔数量，前面未成交的部分顺延到后续批次
+func (m *OrderManager) openTWAP(exec *Execution, placer LimitOrderPlacer, symbol, positionSide string, quantity float64, leverage int, cfg ExecutionConfig) error {
+	slices := cfg.TWAPSlices
+	if bid, ask, err := placer.GetBestBidAsk(symbol); err == nil && (bid+ask)/2*quantity < cfg.TWAPMinNotional {
+		slices = 1
+	}
+	if slices <= 1 {
+		return m.openLimit(exec, placer, symbol, positionSide, quantity, leverage, false, cfg.LimitTimeout, cfg.MarketFallback)
+	}
+
+	timeout := cfg.LimitTimeout
+	if cfg.TWAPInterval > 0 && timeout > cfg.TWAPInterval {
+		timeout = cfg.TWAPInterval
+	}
+	log.Printf("🧊 [%s] TWAP执行 %s %s: 共 %.6f，拆分为 %d 笔，间隔 %v", m.traderID, symbol, positionSide, quantity, slices, cfg.TWAPInterval)
+
+	var lastErr error
+	for i := 0; i < slices; i++ {
+		if i > 0 {
+			m.wait(cfg.TWAPInterval)
+		}
+		target := quantity*float64(i+1)/float64(slices) - exec.FilledQty
+		if target <= 0 || !m.tradable(symbol, target) {
+			continue
+		}
+		if err := m.openLimit(exec, placer, symbol, positionSide, target, leverage, false, timeout, cfg.MarketFallback); err != nil {
+			lastErr = err
+			log.Printf("⚠️ [%s] TWAP第 %d/%d 笔未完成，已成交 %.6f/%.6f: %v", m.traderID, i+1, slices, exec.FilledQty, quantity, err)
+		}
+	}
+	if exec.FilledQty == 0 && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
+
+// awaitFill 轮询限价单直到成交或超时，超时后撤单并同步最终成交量
+func (m *OrderManager) awaitFill(placer LimitOrderPlacer, order *Order, timeout time.Duration) *Order {
+	if order.Status.IsTerminal() {
+		return order
+	}
+	if order.ExchangeOrderID == "" {
+		log.Printf("⚠️ [%s] 限价单没有交易所订单ID，无法跟踪成交: %s", m.traderID, order.ClientOrderID)
+		return order
+	}
+	querier := m.trader.(OrderQuerier)
+
+	for waited := time.Duration(0); waited < timeout && !order.Status.IsTerminal(); waited += executionPollInterval {
+		m.wait(executionPollInterval)
+		remote, err := querier.QueryOrder(order.Symbol, order.ExchangeOrderID)
+		if err != nil {
+			log.Printf("⚠️ [%s] 查询限价单 %s 失败: %v", m.traderID, order.ExchangeOrderID, err)
+			continue
+		}
+		order = m.updateOrder(order.ClientOrderID, remote, "")
+	}
+	if order.Status.IsTerminal() {
+		return order
+	}
+
+	if err := placer.CancelOrder(order.Symbol, order.ExchangeOrderID); err != nil {
+		log.Printf("⚠️ [%s] 撤销限价单 %s 失败: %v", m.traderID, order.ExchangeOrderID, err)
+	}
+	// 撤单后再查询一次，避免漏掉撤单前的成交
+	remote, err := querier.QueryOrder(order.Symbol, order.ExchangeOrderID)
+	if err != nil {
+		remote = &Order{}
+	}
+	if !remote.Status.IsTerminal() {
+		remote.Status = OrderStatusCancelled
+	}
+	return m.updateOrder(order.ClientOrderID, remote, "限价单超时撤销")
+}
+
+// updateOrder 合并交易所返回的订单状态并持久化，返回更新后的副本
+func (m *OrderManager) updateOrder(clientOrderID string, remote *Order, reason string) *Order {
+	m.mu.Lock()
+	cur, ok := m.orders[clientOrderID]
+	if !ok {
+		m.mu.Unlock()
+		copied := *remote
+		return &copied
+	}
+	before := *cur
+	mergeRemoteOrder(cur, remote)
+	if reason != "" && cur.Status == OrderStatusCancelled {
+		cur.Error = reason
+	}
+	changed := orderChanged(before, *cur) || before.Error != cur.Error
+	if changed {
+		cur.UpdatedAt = m.now()
+	}
+	snapshot := *cur
+	m.mu.Unlock()
+
+	if changed {
+		m.save(snapshot)
+	}
+	return &snapshot
+}
+
+// tradable 数量按交易所精度取整后是否仍大于0
+func (m *OrderManager) tradable(symbol string, quantity float64) bool {
+	formatted, err := m.trader.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return quantity > 0
+	}
+	value, err := strconv.ParseFloat(formatted, 64)
+	return err == nil && value > 0
+}
+
+// wait 执行过程中的等待（测试和决策回放时可替换为不等待）
+func (m *OrderManager) wait(d time.Duration) {
+	if m.sleep != nil {
+		m.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
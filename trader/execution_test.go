@@ -0,0 +1,136 @@
+package trader
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"nofx/decision"
+)
+
+// marketOnlyTrader 只暴露基础接口的交易器（不支持限价单和订单查询）
+type marketOnlyTrader struct {
+	Trader
+}
+
+func testExecutionConfig(mode decision.ExecutionMode) ExecutionConfig {
+	cfg := DefaultExecutionConfig()
+	cfg.Mode = mode
+	cfg.LimitTimeout = 4 * time.Second
+	return cfg
+}
+
+func TestExecutionLimitMid(t *testing.T) {
+	sim := newOrderTestSim()
+	om := NewOrderManager("t1", "paper", sim, newMemOrderStore())
+
+	// 无滑点时盘口中间价即市价，限价单立即成交
+	exec, err := om.Open("BTCUSDT", "LONG", 1, 5, testExecutionConfig(decision.ExecutionLimitMid))
+	if err != nil {
+		t.Fatalf("限价开仓失败: %v", err)
+	}
+	order := exec.LastOrder()
+	if len(exec.Orders) != 1 || order.Type != OrderTypeLimit || order.Price != 100 || order.Status != OrderStatusFilled {
+		t.Fatalf("应为已成交的限价单: %+v", order)
+	}
+	if exec.FilledQty != 1 || exec.AvgPrice != 100 {
+		t.Errorf("成交汇总错误: %+v", exec)
+	}
+}
+
+func TestExecutionPostOnly(t *testing.T) {
+	sim := NewSimulatedTrader(SimulatedTraderConfig{InitialBalance: 10000, MakerFeeRate: 0.0002, SlippageBps: 10})
+	sim.SetPrice("BTCUSDT", 100)
+	om := NewOrderManager("t1", "paper", sim, newMemOrderStore())
+	cfg := testExecutionConfig(decision.ExecutionPostOnly)
+	cfg.MarketFallback = false
+
+	// 挂在买一，等待期间价格回落到挂单价后成交
+	om.sleep = func(time.Duration) { sim.OnBar("BTCUSDT", 100, 99.8, 99.9) }
+	exec, err := om.Open("BTCUSDT", "LONG", 1, 5, cfg)
+	if err != nil {
+		t.Fatalf("只做Maker开仓失败: %v", err)
+	}
+	order := exec.LastOrder()
+	if len(exec.Orders) != 1 || order.Status != OrderStatusFilled || math.Abs(order.Price-99.9) > 1e-9 {
+		t.Fatalf("只做Maker订单应挂在买一并成交: %+v", order)
+	}
+	if exec.FilledQty != 1 || math.Abs(exec.AvgPrice-99.9) > 1e-9 || math.Abs(exec.Fee-99.9*0.0002) > 1e-9 {
+		t.Errorf("应按挂单价和Maker费率成交: %+v", exec)
+	}
+}
+
+func TestExecutionTimeoutFallback(t *testing.T) {
+	sim := NewSimulatedTrader(SimulatedTraderConfig{InitialBalance: 10000, SlippageBps: 10})
+	sim.SetPrice("BTCUSDT", 100)
+	om := NewOrderManager("t1", "paper", sim, newMemOrderStore())
+	om.sleep = func(time.Duration) {}
+
+	cfg := testExecutionConfig(decision.ExecutionPostOnly)
+	exec, err := om.Open("BTCUSDT", "SHORT", 2, 5, cfg)
+	if err != nil {
+		t.Fatalf("超时转市价失败: %v", err)
+	}
+	if len(exec.Orders) != 2 {
+		t.Fatalf("应有限价单和市价单各一笔: %+v", exec.Orders)
+	}
+	if first := exec.Orders[0]; first.Status != OrderStatusCancelled || first.Error != "限价单超时撤销" {
+		t.Errorf("超时限价单应被撤销: %+v", first)
+	}
+	if second := exec.Orders[1]; second.Type != OrderTypeMarket || second.Quantity != 2 || exec.FilledQty != 2 {
+		t.Errorf("剩余数量应转市价成交: %+v", second)
+	}
+	if orders, _ := sim.GetOpenOrders("BTCUSDT"); len(orders) != 0 {
+		t.Errorf("超时后不应留有挂单: %+v", orders)
+	}
+
+	// 不允许转市价时返回未成交错误
+	cfg.MarketFallback = false
+	exec, err = om.Open("BTCUSDT", "SHORT", 1, 5, cfg)
+	if err == nil || exec.FilledQty != 0 {
+		t.Errorf("限价单未成交且不转市价时应返回错误: %+v", exec)
+	}
+}
+
+func TestExecutionTWAP(t *testing.T) {
+	sim := newOrderTestSim()
+	om := NewOrderManager("t1", "paper", sim, newMemOrderStore())
+	var waits []time.Duration
+	om.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	// 仓位价值4000超过拆分门槛，分4笔执行
+	exec, err := om.Open("BTCUSDT", "LONG", 40, 5, testExecutionConfig(decision.ExecutionTWAP))
+	if err != nil {
+		t.Fatalf("TWAP执行失败: %v", err)
+	}
+	if len(exec.Orders) != 4 || exec.FilledQty != 40 {
+		t.Fatalf("应拆分为4笔并全部成交: %+v", exec)
+	}
+	for _, order := range exec.Orders {
+		if order.Type != OrderTypeLimit || order.Quantity != 10 {
+			t.Errorf("每笔应为10的限价单: %+v", order)
+		}
+	}
+	if len(waits) != 3 || waits[0] != 20*time.Second {
+		t.Errorf("批次之间应等待TWAP间隔: %v", waits)
+	}
+
+	// 小额仓位不拆分
+	exec, _ = om.Open("BTCUSDT", "LONG", 1, 5, testExecutionConfig(decision.ExecutionTWAP))
+	if len(exec.Orders) != 1 {
+		t.Errorf("低于拆分门槛时应只下一笔: %+v", exec.Orders)
+	}
+}
+
+func TestExecutionFallsBackToMarket(t *testing.T) {
+	sim := newOrderTestSim()
+	om := NewOrderManager("t1", "paper", marketOnlyTrader{sim}, newMemOrderStore())
+
+	exec, err := om.Open("BTCUSDT", "LONG", 1, 5, testExecutionConfig(decision.ExecutionLimitMid))
+	if err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if exec.Mode != decision.ExecutionMarket || exec.LastOrder().Type != OrderTypeMarket || exec.FilledQty != 1 {
+		t.Errorf("不支持限价单的交易所应改用市价单: %+v", exec)
+	}
+}
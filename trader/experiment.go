@@ -0,0 +1,112 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+
+	"nofx/ai"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/service/experiment"
+)
+
+// cycleSetup 本周期的决策配置（参与A/B实验时由变体覆盖交易员自身配置）
+type cycleSetup struct {
+	client         decision.AIClient
+	customPrompt   string
+	overrideBase   bool
+	promptTemplate string
+}
+
+// resolveCycleSetup 获取本周期的决策配置：未参与实验时使用交易员自身配置，
+// 参与实验时按分配到的变体覆盖prompt模板、自定义prompt、AI模型和记忆开关（变体中为空的项沿用自身配置）
+func (at *AutoTrader) resolveCycleSetup(ctx *decision.Context, record *logger.DecisionRecord) cycleSetup {
+	setup := cycleSetup{
+		client:         at.aiClient,
+		customPrompt:   at.customPrompt,
+		overrideBase:   at.overrideBasePrompt,
+		promptTemplate: at.systemPromptTemplate,
+	}
+	if at.experiments == nil {
+		return setup
+	}
+
+	assignment, err := at.experiments.Assign(at.id, at.callCount)
+	if err != nil {
+		log.Printf("⚠️ [%s] 实验变体分配失败，使用自身配置: %v", at.name, err)
+		return setup
+	}
+	if assignment == nil {
+		return setup
+	}
+
+	v := assignment.Variant
+	if v.PromptTemplate != "" {
+		setup.promptTemplate = v.PromptTemplate
+	}
+	if v.CustomPrompt != "" {
+		setup.customPrompt = v.CustomPrompt
+		setup.overrideBase = v.OverrideBasePrompt
+	}
+	if v.AIModelID != "" {
+		if client, err := at.variantClient(v.AIModelID); err != nil {
+			log.Printf("⚠️ [%s] 实验变体 %s 的AI模型不可用，使用自身模型: %v", at.name, v.Name, err)
+		} else {
+			setup.client = client
+		}
+	}
+	if memory := at.activeMemory(); v.Memory != nil && !*v.Memory && memory != nil {
+		enrichers := ctx.Enrichers[:0]
+		for _, e := range ctx.Enrichers {
+			if e != memory.Enricher() {
+				enrichers = append(enrichers, e)
+			}
+		}
+		ctx.Enrichers = enrichers
+	} else if v.Memory != nil && *v.Memory && memory == nil {
+		log.Printf("⚠️ [%s] 实验变体 %s 要求注入历史交易记忆，但mem0未对该用户启用", at.name, v.Name)
+	}
+
+	record.Experiment = assignment.ExperimentName
+	record.ExperimentVariant = v.Name
+	record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🧪 实验 %s: 变体 %s", assignment.ExperimentName, v.Name))
+	log.Printf("🧪 [%s] 实验 %s 本周期使用变体 %s", at.name, assignment.ExperimentName, v.Name)
+	return setup
+}
+
+// variantClient 获取实验变体使用的AI客户端（按用户AI模型ID创建并缓存）
+func (at *AutoTrader) variantClient(modelID string) (decision.AIClient, error) {
+	if client, ok := at.variantClients[modelID]; ok {
+		return client, nil
+	}
+	if at.db == nil {
+		return nil, fmt.Errorf("实验模型需要数据库配置")
+	}
+
+	cfg, err := userModelProviderConfig(at.config, modelID)
+	if err != nil {
+		return nil, err
+	}
+	if mode, err := at.db.GetSystemConfig("ai_tool_calling"); err == nil && mode != "" {
+		cfg.ToolCalling = mode
+	}
+	model, err := ai.DefaultRegistry.Create(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("创建AI模型 %s 失败: %w", modelID, err)
+	}
+
+	client := ai.NewDecisionClient(model)
+	if at.variantClients == nil {
+		at.variantClients = make(map[string]decision.AIClient)
+	}
+	at.variantClients[modelID] = client
+	return client, nil
+}
+
+// newExperimentService 创建实验服务（没有数据库时返回nil）
+func newExperimentService(config AutoTraderConfig) *experiment.Service {
+	if config.Database == nil {
+		return nil
+	}
+	return experiment.NewService(config.Database)
+}
@@ -2,10 +2,13 @@ package trader
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/sonirico/go-hyperliquid"
@@ -73,7 +76,7 @@ func NewHyperliquidTrader(privateKeyHex string, walletAddr string, testnet bool)
 }
 
 // GetBalance 获取账户余额
-func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
+func (t *HyperliquidTrader) GetBalance() (*Balance, error) {
 	log.Printf("🔄 正在调用Hyperliquid API获取账户余额...")
 
 	// 获取账户状态
@@ -84,8 +87,6 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 	}
 
 	// 解析余额信息（MarginSummary字段都是string）
-	result := make(map[string]interface{})
-
 	// 🔍 调试：打印API返回的完整CrossMarginSummary结构
 	summaryJSON, _ := json.MarshalIndent(accountState.MarginSummary, "  ", "  ")
 	log.Printf("🔍 [DEBUG] Hyperliquid API CrossMarginSummary完整数据:")
@@ -105,33 +106,35 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 	// AccountValue = 总账户净值（已包含空闲资金+持仓价值+未实现盈亏）
 	// TotalMarginUsed = 持仓占用的保证金（已包含在AccountValue中，仅用于显示）
 	//
-	// 为了兼容auto_trader.go的计算逻辑（totalEquity = totalWalletBalance + totalUnrealizedProfit）
-	// 需要返回"不包含未实现盈亏的钱包余额"
+	// Balance.TotalWalletBalance 为不包含未实现盈亏的钱包余额（TotalEquity = 钱包余额 + 未实现盈亏）
 	walletBalanceWithoutUnrealized := accountValue - totalUnrealizedPnl
 
-	result["totalWalletBalance"] = walletBalanceWithoutUnrealized // 钱包余额（不含未实现盈亏）
-	result["availableBalance"] = accountValue - totalMarginUsed   // 可用余额（总净值 - 占用保证金）
-	result["totalUnrealizedProfit"] = totalUnrealizedPnl          // 未实现盈亏
+	result := &Balance{
+		TotalWalletBalance:    walletBalanceWithoutUnrealized, // 钱包余额（不含未实现盈亏）
+		AvailableBalance:      accountValue - totalMarginUsed, // 可用余额（总净值 - 占用保证金）
+		TotalUnrealizedProfit: totalUnrealizedPnl,             // 未实现盈亏
+		UsedMargin:            totalMarginUsed,
+	}
 
 	log.Printf("✓ Hyperliquid 账户: 总净值=%.2f (钱包%.2f+未实现%.2f), 可用=%.2f, 保证金占用=%.2f",
 		accountValue,
 		walletBalanceWithoutUnrealized,
 		totalUnrealizedPnl,
-		result["availableBalance"],
+		result.AvailableBalance,
 		totalMarginUsed)
 
 	return result, nil
 }
 
 // GetPositions 获取所有持仓
-func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *HyperliquidTrader) GetPositions() ([]Position, error) {
 	// 获取账户状态
 	accountState, err := t.exchange.Info().UserState(t.ctx, t.walletAddr)
 	if err != nil {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
 
-	var result []map[string]interface{}
+	var result []Position
 
 	// 遍历所有持仓
 	for _, assetPos := range accountState.AssetPositions {
@@ -144,19 +147,16 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 			continue // 跳过无持仓的
 		}
 
-		posMap := make(map[string]interface{})
-
 		// 标准化symbol格式（Hyperliquid使用如"BTC"，我们转换为"BTCUSDT"）
-		symbol := position.Coin + "USDT"
-		posMap["symbol"] = symbol
+		pos := Position{Symbol: position.Coin + "USDT"}
 
 		// 持仓数量和方向
 		if posAmt > 0 {
-			posMap["side"] = "long"
-			posMap["positionAmt"] = posAmt
+			pos.Side = "long"
+			pos.Quantity = posAmt
 		} else {
-			posMap["side"] = "short"
-			posMap["positionAmt"] = -posAmt // 转为正数
+			pos.Side = "short"
+			pos.Quantity = -posAmt // 转为正数
 		}
 
 		// 价格信息（EntryPx和LiquidationPx是指针类型）
@@ -177,13 +177,13 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 			markPrice = positionValue / absFloat(posAmt)
 		}
 
-		posMap["entryPrice"] = entryPrice
-		posMap["markPrice"] = markPrice
-		posMap["unRealizedProfit"] = unrealizedPnl
-		posMap["leverage"] = float64(position.Leverage.Value)
-		posMap["liquidationPrice"] = liquidationPx
+		pos.EntryPrice = entryPrice
+		pos.MarkPrice = markPrice
+		pos.UnrealizedProfit = unrealizedPnl
+		pos.Leverage = position.Leverage.Value
+		pos.LiquidationPrice = liquidationPx
 
-		result = append(result, posMap)
+		result = append(result, pos)
 	}
 
 	return result, nil
@@ -218,7 +218,12 @@ func (t *HyperliquidTrader) SetLeverage(symbol string, leverage int) error {
 }
 
 // OpenLong 开多仓
-func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openLong(symbol, quantity, leverage, "")
+}
+
+// openLong 开仓下单（clientOrderID非空时转换为cloid发送）
+func (t *HyperliquidTrader) openLong(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
 	// 先取消该币种的所有委托单
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败: %v", err)
@@ -257,26 +262,31 @@ func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage i
 				Tif: hyperliquid.TifIoc, // Immediate or Cancel (类似市价单)
 			},
 		},
-		ReduceOnly: false,
+		ReduceOnly:    false,
+		ClientOrderID: hyperliquidCloid(clientOrderID),
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	status, err := t.exchange.Order(t.ctx, order, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开多仓失败: %w", err)
+	}
+	result, err := hyperliquidOrderResult(symbol, status)
 	if err != nil {
 		return nil, fmt.Errorf("开多仓失败: %w", err)
 	}
 
 	log.Printf("✓ 开多仓成功: %s 数量: %.4f", symbol, roundedQuantity)
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0 // Hyperliquid没有返回order ID
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
 	return result, nil
 }
 
 // OpenShort 开空仓
-func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.openShort(symbol, quantity, leverage, "")
+}
+
+// openShort 开仓下单（clientOrderID非空时转换为cloid发送）
+func (t *HyperliquidTrader) openShort(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
 	// 先取消该币种的所有委托单
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败: %v", err)
@@ -315,26 +325,31 @@ func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage
 				Tif: hyperliquid.TifIoc,
 			},
 		},
-		ReduceOnly: false,
+		ReduceOnly:    false,
+		ClientOrderID: hyperliquidCloid(clientOrderID),
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	status, err := t.exchange.Order(t.ctx, order, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开空仓失败: %w", err)
+	}
+	result, err := hyperliquidOrderResult(symbol, status)
 	if err != nil {
 		return nil, fmt.Errorf("开空仓失败: %w", err)
 	}
 
 	log.Printf("✓ 开空仓成功: %s 数量: %.4f", symbol, roundedQuantity)
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
 	return result, nil
 }
 
 // CloseLong 平多仓
-func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	return t.closeLong(symbol, quantity, "")
+}
+
+// closeLong 平仓下单（clientOrderID非空时转换为cloid发送）
+func (t *HyperliquidTrader) closeLong(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -342,11 +357,8 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 			return nil, err
 		}
 
-		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "long" {
-				quantity = pos["positionAmt"].(float64)
-				break
-			}
+		if pos, ok := FindPosition(positions, symbol, "long"); ok {
+			quantity = pos.Quantity
 		}
 
 		if quantity == 0 {
@@ -382,10 +394,15 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 				Tif: hyperliquid.TifIoc,
 			},
 		},
-		ReduceOnly: true, // 只平仓，不开新仓
+		ReduceOnly:    true, // 只平仓，不开新仓
+		ClientOrderID: hyperliquidCloid(clientOrderID),
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	status, err := t.exchange.Order(t.ctx, order, nil)
+	if err != nil {
+		return nil, fmt.Errorf("平多仓失败: %w", err)
+	}
+	result, err := hyperliquidOrderResult(symbol, status)
 	if err != nil {
 		return nil, fmt.Errorf("平多仓失败: %w", err)
 	}
@@ -397,16 +414,16 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
 	return result, nil
 }
 
 // CloseShort 平空仓
-func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	return t.closeShort(symbol, quantity, "")
+}
+
+// closeShort 平仓下单（clientOrderID非空时转换为cloid发送）
+func (t *HyperliquidTrader) closeShort(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -414,11 +431,8 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 			return nil, err
 		}
 
-		for _, pos := range positions {
-			if pos["symbol"] == symbol && pos["side"] == "short" {
-				quantity = pos["positionAmt"].(float64)
-				break
-			}
+		if pos, ok := FindPosition(positions, symbol, "short"); ok {
+			quantity = pos.Quantity
 		}
 
 		if quantity == 0 {
@@ -454,10 +468,15 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 				Tif: hyperliquid.TifIoc,
 			},
 		},
-		ReduceOnly: true,
+		ReduceOnly:    true,
+		ClientOrderID: hyperliquidCloid(clientOrderID),
 	}
 
-	_, err = t.exchange.Order(t.ctx, order, nil)
+	status, err := t.exchange.Order(t.ctx, order, nil)
+	if err != nil {
+		return nil, fmt.Errorf("平空仓失败: %w", err)
+	}
+	result, err := hyperliquidOrderResult(symbol, status)
 	if err != nil {
 		return nil, fmt.Errorf("平空仓失败: %w", err)
 	}
@@ -469,14 +488,40 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 		log.Printf("  ⚠ 取消挂单失败: %v", err)
 	}
 
-	result := make(map[string]interface{})
-	result["orderId"] = 0
-	result["symbol"] = symbol
-	result["status"] = "FILLED"
-
 	return result, nil
 }
 
+// PlaceMarketOrder 按动作下市价单并携带本地订单ID（实现ClientOrderPlacer）
+func (t *HyperliquidTrader) PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	switch action {
+	case "open_long":
+		return t.openLong(symbol, quantity, leverage, clientOrderID)
+	case "open_short":
+		return t.openShort(symbol, quantity, leverage, clientOrderID)
+	case "close_long":
+		return t.closeLong(symbol, quantity, clientOrderID)
+	case "close_short":
+		return t.closeShort(symbol, quantity, clientOrderID)
+	}
+	return nil, fmt.Errorf("未知的下单动作: %s", action)
+}
+
+// hyperliquidCloid 将本地订单ID转换为Hyperliquid cloid（0x + 32位十六进制）
+// 订单管理器生成的ID（nx + 十六进制）去掉前缀后补零，可反查本地订单；其他格式按FNV-128a哈希
+func hyperliquidCloid(clientOrderID string) *string {
+	if clientOrderID == "" {
+		return nil
+	}
+	digits := strings.TrimPrefix(clientOrderID, "nx")
+	if len(digits) == len(clientOrderID) || len(digits) > 32 || strings.Trim(digits, "0123456789abcdef") != "" {
+		h := fnv.New128a()
+		h.Write([]byte(clientOrderID))
+		digits = hex.EncodeToString(h.Sum(nil))
+	}
+	cloid := "0x" + strings.Repeat("0", 32-len(digits)) + digits
+	return &cloid
+}
+
 // CancelAllOrders 取消该币种的所有挂单
 func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 	coin := convertSymbolToHyperliquid(symbol)
@@ -501,6 +546,204 @@ func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 	return nil
 }
 
+// hyperliquidOrderResult 将下单返回的订单状态转换为统一的下单结果
+// IOC订单立即成交时返回filled（含成交均价），挂单时返回resting，被拒时返回error
+func hyperliquidOrderResult(symbol string, status hyperliquid.OrderStatus) (*OrderResult, error) {
+	if status.Error != nil {
+		return nil, fmt.Errorf("%s", *status.Error)
+	}
+
+	result := &OrderResult{Symbol: symbol, Status: OrderStatusFilled}
+	switch {
+	case status.Filled != nil:
+		result.OrderID = strconv.Itoa(status.Filled.Oid)
+		result.ExecutedQty, _ = strconv.ParseFloat(status.Filled.TotalSz, 64)
+		result.AvgPrice, _ = strconv.ParseFloat(status.Filled.AvgPx, 64)
+	case status.Resting != nil:
+		result.OrderID = strconv.FormatInt(status.Resting.Oid, 10)
+		result.Status = OrderStatusNew
+	}
+	return result, nil
+}
+
+// QueryOrder 查询订单状态（实现OrderQuerier，成交均价和手续费从最近成交记录汇总）
+func (t *HyperliquidTrader) QueryOrder(symbol, orderID string) (*Order, error) {
+	oid, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的订单ID: %s", orderID)
+	}
+
+	res, err := t.exchange.Info().QueryOrderByOid(t.ctx, t.walletAddr, oid)
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	if res.Status != hyperliquid.OrderQueryStatusSuccess {
+		return nil, fmt.Errorf("订单不存在: %s", orderID)
+	}
+
+	q := res.Order.Order
+	origSz, _ := strconv.ParseFloat(q.OrigSz, 64)
+	remaining, _ := strconv.ParseFloat(q.Sz, 64)
+	stopPrice, _ := strconv.ParseFloat(q.TriggerPx, 64)
+	order := &Order{
+		ExchangeOrderID: orderID,
+		Symbol:          symbol,
+		PositionSide:    hyperliquidPositionSide(q.Side, q.ReduceOnly || q.IsTrigger),
+		Type:            hyperliquidOrderType(q.OrderType, q.IsTrigger),
+		Quantity:        origSz,
+		FilledQty:       origSz - remaining,
+		StopPrice:       stopPrice,
+		Status:          parseOrderStatus(string(res.Order.Status)),
+	}
+
+	if order.FilledQty > 0 {
+		fills, err := t.exchange.Info().UserFills(t.ctx, t.walletAddr)
+		if err != nil {
+			log.Printf("  ⚠ 获取成交记录失败: %v", err)
+		}
+		var notional, size float64
+		for _, fill := range fills {
+			if fill.Oid != oid {
+				continue
+			}
+			px, _ := strconv.ParseFloat(fill.Price, 64)
+			sz, _ := strconv.ParseFloat(fill.Size, 64)
+			fee, _ := strconv.ParseFloat(fill.Fee, 64)
+			notional += px * sz
+			size += sz
+			order.Fee += fee
+		}
+		if size > 0 {
+			order.AvgPrice = notional / size
+		}
+	}
+	return order, nil
+}
+
+// GetOpenOrders 获取该币种当前挂单（实现OrderQuerier）
+func (t *HyperliquidTrader) GetOpenOrders(symbol string) ([]*Order, error) {
+	coin := convertSymbolToHyperliquid(symbol)
+
+	list, err := t.exchange.Info().FrontendOpenOrders(t.ctx, t.walletAddr)
+	if err != nil {
+		return nil, fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	var orders []*Order
+	for _, o := range list {
+		if o.Coin != coin {
+			continue
+		}
+		orders = append(orders, &Order{
+			ExchangeOrderID: strconv.FormatInt(o.Oid, 10),
+			Symbol:          symbol,
+			PositionSide:    hyperliquidPositionSide(o.Side, o.ReduceOnly || o.IsTrigger),
+			Type:            hyperliquidOrderType(o.OrderType, o.IsTrigger),
+			Quantity:        o.OrigSz,
+			FilledQty:       o.OrigSz - o.Sz,
+			StopPrice:       o.TriggerPx,
+			Status:          OrderStatusNew,
+		})
+	}
+	return orders, nil
+}
+
+// hyperliquidOrderType 根据订单类型描述（如 "Stop Market"、"Take Profit Market"）转换订单类型
+func hyperliquidOrderType(orderType string, isTrigger bool) OrderType {
+	if !isTrigger {
+		if strings.EqualFold(orderType, "limit") {
+			return OrderTypeLimit
+		}
+		return OrderTypeMarket
+	}
+	if strings.Contains(strings.ToLower(orderType), "take profit") {
+		return OrderTypeTakeProfitMarket
+	}
+	return OrderTypeStopMarket
+}
+
+// hyperliquidPositionSide 推断订单对应的持仓方向（平仓单：卖出平多，买入平空）
+func hyperliquidPositionSide(side hyperliquid.OrderSide, closing bool) string {
+	isBuy := side == hyperliquid.OrderSideBid
+	if isBuy != closing {
+		return "LONG"
+	}
+	return "SHORT"
+}
+
+// PlaceLimitOrder 下限价开仓单（实现LimitOrderPlacer，postOnly时使用Alo，会立即成交的订单被拒绝）
+func (t *HyperliquidTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+	if err := t.SetLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	coin := convertSymbolToHyperliquid(symbol)
+	roundedQuantity := t.roundToSzDecimals(coin, quantity)
+	roundedPrice := t.roundPriceToSigfigs(price)
+
+	tif := hyperliquid.TifGtc
+	if postOnly {
+		tif = hyperliquid.TifAlo
+	}
+
+	order := hyperliquid.CreateOrderRequest{
+		Coin:  coin,
+		IsBuy: positionSide != "SHORT",
+		Size:  roundedQuantity,
+		Price: roundedPrice,
+		OrderType: hyperliquid.OrderType{
+			Limit: &hyperliquid.LimitOrderType{
+				Tif: tif,
+			},
+		},
+		ReduceOnly:    false,
+		ClientOrderID: hyperliquidCloid(clientOrderID),
+	}
+
+	status, err := t.exchange.Order(t.ctx, order, nil)
+	if err != nil {
+		return nil, fmt.Errorf("下限价单失败: %w", err)
+	}
+	result, err := hyperliquidOrderResult(symbol, status)
+	if err != nil {
+		return nil, fmt.Errorf("下限价单失败: %w", err)
+	}
+
+	log.Printf("✓ 限价单已提交: %s %s 数量: %.4f 价格: %.4f (%s)", symbol, positionSide, roundedQuantity, roundedPrice, tif)
+	return result, nil
+}
+
+// CancelOrder 撤销单个订单（实现LimitOrderPlacer）
+func (t *HyperliquidTrader) CancelOrder(symbol, orderID string) error {
+	oid, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID: %s", orderID)
+	}
+
+	if _, err := t.exchange.Cancel(t.ctx, convertSymbolToHyperliquid(symbol), oid); err != nil {
+		return fmt.Errorf("撤销订单失败: %w", err)
+	}
+	return nil
+}
+
+// CancelStopOrder 撤销止损/止盈条件单（实现StopOrderCanceller，触发单与普通订单使用同一撤单接口）
+func (t *HyperliquidTrader) CancelStopOrder(order *Order) error {
+	return t.CancelOrder(order.Symbol, order.ExchangeOrderID)
+}
+
+// GetBestBidAsk 获取买一/卖一价（实现LimitOrderPlacer）
+func (t *HyperliquidTrader) GetBestBidAsk(symbol string) (float64, float64, error) {
+	book, err := t.exchange.Info().L2Snapshot(t.ctx, convertSymbolToHyperliquid(symbol))
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取盘口失败: %w", err)
+	}
+	// Levels[0]为买盘，Levels[1]为卖盘
+	if book == nil || len(book.Levels) < 2 || len(book.Levels[0]) == 0 || len(book.Levels[1]) == 0 {
+		return 0, 0, fmt.Errorf("%s 盘口为空", symbol)
+	}
+	return book.Levels[0][0].Px, book.Levels[1][0].Px, nil
+}
+
 // GetMarketPrice 获取市场价格
 func (t *HyperliquidTrader) GetMarketPrice(symbol string) (float64, error) {
 	coin := convertSymbolToHyperliquid(symbol)
@@ -64,22 +64,22 @@ type CreditConsumer interface {
 // 支持多个交易平台（币安、Hyperliquid等）
 type Trader interface {
 	// GetBalance 获取账户余额
-	GetBalance() (map[string]interface{}, error)
+	GetBalance() (*Balance, error)
 
 	// GetPositions 获取所有持仓
-	GetPositions() ([]map[string]interface{}, error)
+	GetPositions() ([]Position, error)
 
 	// OpenLong 开多仓
-	OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error)
+	OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error)
 
 	// OpenShort 开空仓
-	OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error)
+	OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error)
 
 	// CloseLong 平多仓（quantity=0表示全部平仓）
-	CloseLong(symbol string, quantity float64) (map[string]interface{}, error)
+	CloseLong(symbol string, quantity float64) (*OrderResult, error)
 
 	// CloseShort 平空仓（quantity=0表示全部平仓）
-	CloseShort(symbol string, quantity float64) (map[string]interface{}, error)
+	CloseShort(symbol string, quantity float64) (*OrderResult, error)
 
 	// SetLeverage 设置杠杆
 	SetLeverage(symbol string, leverage int) error
@@ -102,3 +102,28 @@ type Trader interface {
 	// FormatQuantity 格式化数量到正确的精度
 	FormatQuantity(symbol string, quantity float64) (string, error)
 }
+
+// BackgroundTrader 带后台任务的交易器（如纸面交易的止损/止盈/强平检查）
+// 随交易员启动和停止，避免停止或删除的交易员留下后台goroutine
+type BackgroundTrader interface {
+	// Start 启动后台任务（可重复调用）
+	Start()
+
+	// Close 停止后台任务（可重复调用，停止后可再次Start）
+	Close()
+}
+
+// SpotTrader 可交易现货的交易器（可选实现，用于组合持仓中的现货腿，如现货-永续资金费套利）
+type SpotTrader interface {
+	// SpotBuy 市价买入现货（quantity为币的数量），ExecutedQty为扣除手续费后实际到账的数量
+	SpotBuy(symbol string, quantity float64) (*OrderResult, error)
+
+	// SpotSell 市价卖出现货
+	SpotSell(symbol string, quantity float64) (*OrderResult, error)
+
+	// GetSpotBalance 现货账户中该交易对基础资产的可用数量
+	GetSpotBalance(symbol string) (float64, error)
+
+	// GetSpotPrice 现货最新价格
+	GetSpotPrice(symbol string) (float64, error)
+}
@@ -0,0 +1,174 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"nofx/database"
+)
+
+// IncomeRecord 交易所账单中的一条手续费或资金费流水
+type IncomeRecord struct {
+	Type    string // fee / funding
+	Symbol  string
+	Amount  float64 // USDT，正数为支付，负数为收取（如maker返佣、收取资金费）
+	Ref     string  // 交易所流水ID（同类型内唯一）
+	OrderID string  // 手续费所属的交易所订单ID（资金费或交易所未返回时为空）
+	Time    time.Time
+}
+
+// IncomeLedgerProvider 可查询手续费/资金费账单的交易所（可选实现）
+type IncomeLedgerProvider interface {
+	// GetIncomeHistory 获取since之后账户的手续费和资金费流水
+	GetIncomeHistory(since time.Time) ([]IncomeRecord, error)
+}
+
+// LedgerStore 费用流水存储（*config.Database 实现该接口）
+type LedgerStore interface {
+	SaveLedgerEntries(entries []database.LedgerEntry) (int, error)
+	GetLedgerSummary(traderID string, since time.Time) (database.LedgerSummary, error)
+	GetLatestLedgerTime(traderID string) (time.Time, error)
+}
+
+// ledgerState 费用流水同步状态
+type ledgerState struct {
+	mu     sync.Mutex
+	since  time.Time              // 下次同步的起始时间
+	totals database.LedgerSummary // 累计手续费和资金费
+}
+
+// ledgerInitialLookback 首次同步账单的回溯时间（交易所账单接口通常只保留近7天明细）
+const ledgerInitialLookback = 7 * 24 * time.Hour
+
+// ledgerOrderLookup 认领账单流水时读取的最近订单数量
+const ledgerOrderLookup = 5000
+
+// syncLedger 从交易所账单同步手续费和资金费流水，并刷新累计费用
+func (at *AutoTrader) syncLedger() {
+	if at.ledgerStore == nil {
+		return
+	}
+	provider, ok := at.trader.(IncomeLedgerProvider)
+	if !ok {
+		return
+	}
+
+	at.ledger.mu.Lock()
+	since := at.ledger.since
+	at.ledger.mu.Unlock()
+	if since.IsZero() {
+		latest, err := at.ledgerStore.GetLatestLedgerTime(at.id)
+		if err != nil {
+			log.Printf("⚠️ [%s] %v", at.name, err)
+			return
+		}
+		since = latest
+		if since.IsZero() {
+			since = at.now().Add(-ledgerInitialLookback)
+		}
+	}
+	// 交易员创建之前的流水不属于该交易员
+	if since.Before(at.config.CreatedAt) {
+		since = at.config.CreatedAt
+	}
+
+	records, err := provider.GetIncomeHistory(since)
+	if err != nil {
+		log.Printf("⚠️ [%s] 同步手续费/资金费账单失败: %v", at.name, err)
+		return
+	}
+	own, err := at.ownLedgerRecords(records)
+	if err != nil {
+		log.Printf("⚠️ [%s] 认领手续费/资金费流水失败: %v", at.name, err)
+		return
+	}
+
+	entries := make([]database.LedgerEntry, 0, len(own))
+	for _, r := range own {
+		entries = append(entries, database.LedgerEntry{
+			TraderID:    at.id,
+			Symbol:      r.Symbol,
+			EntryType:   r.Type,
+			Amount:      r.Amount,
+			ExchangeRef: r.Ref,
+			CreatedAt:   r.Time,
+		})
+	}
+	for _, r := range records {
+		if r.Time.After(since) {
+			since = r.Time
+		}
+	}
+	if len(entries) > 0 {
+		inserted, err := at.ledgerStore.SaveLedgerEntries(entries)
+		if err != nil {
+			log.Printf("⚠️ [%s] %v", at.name, err)
+			return
+		}
+		if inserted > 0 {
+			log.Printf("🧾 [%s] 已同步 %d 条手续费/资金费流水", at.name, inserted)
+		}
+	}
+	summary, err := at.ledgerStore.GetLedgerSummary(at.id, time.Time{})
+	if err != nil {
+		log.Printf("⚠️ [%s] %v", at.name, err)
+	}
+
+	at.ledger.mu.Lock()
+	defer at.ledger.mu.Unlock()
+	// 下次从最新一条流水开始查询（边界上的重复流水按交易所流水ID去重）
+	at.ledger.since = since
+	if err == nil {
+		at.ledger.totals = summary
+	}
+}
+
+// ownLedgerRecords 从账户级账单中筛选属于本交易员的流水
+// 账单接口返回整个交易所账户的流水，多个交易员可能共用一个账户：
+// 带订单ID的手续费只认领本交易员下过的订单；资金费和未带订单ID的手续费只认领本交易员此前下过单的币种；
+// 交易员创建之前的流水一律不计入
+func (at *AutoTrader) ownLedgerRecords(records []IncomeRecord) ([]IncomeRecord, error) {
+	if len(records) == 0 || at.orderManager == nil {
+		return nil, nil
+	}
+	orders, err := at.orderManager.Orders("", ledgerOrderLookup)
+	if err != nil {
+		return nil, fmt.Errorf("获取订单失败: %w", err)
+	}
+	orderIDs := make(map[string]bool, len(orders))
+	firstOrder := make(map[string]time.Time) // symbol -> 本交易员在该币种的首笔订单时间
+	for _, o := range orders {
+		if o.ExchangeOrderID != "" {
+			orderIDs[o.ExchangeOrderID] = true
+		}
+		if first, ok := firstOrder[o.Symbol]; !ok || o.CreatedAt.Before(first) {
+			firstOrder[o.Symbol] = o.CreatedAt
+		}
+	}
+
+	var own []IncomeRecord
+	for _, r := range records {
+		if r.Time.Before(at.config.CreatedAt) {
+			continue
+		}
+		if r.Type == "fee" && r.OrderID != "" {
+			if orderIDs[r.OrderID] {
+				own = append(own, r)
+			}
+			continue
+		}
+		if first, ok := firstOrder[r.Symbol]; ok && !r.Time.Before(first) {
+			own = append(own, r)
+		}
+	}
+	return own, nil
+}
+
+// GetLedgerSummary 累计手续费和资金费（最近一次同步的结果）
+func (at *AutoTrader) GetLedgerSummary() database.LedgerSummary {
+	at.ledger.mu.Lock()
+	defer at.ledger.mu.Unlock()
+	return at.ledger.totals
+}
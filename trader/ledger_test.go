@@ -0,0 +1,140 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/database"
+)
+
+// memLedgerStore 内存费用流水存储
+type memLedgerStore struct {
+	entries map[string]database.LedgerEntry
+}
+
+func (s *memLedgerStore) SaveLedgerEntries(entries []database.LedgerEntry) (int, error) {
+	inserted := 0
+	for _, e := range entries {
+		key := e.TraderID + "/" + e.EntryType + "/" + e.ExchangeRef
+		if _, ok := s.entries[key]; ok {
+			continue
+		}
+		s.entries[key] = e
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (s *memLedgerStore) GetLedgerSummary(traderID string, since time.Time) (database.LedgerSummary, error) {
+	var summary database.LedgerSummary
+	for _, e := range s.entries {
+		if e.TraderID != traderID || e.CreatedAt.Before(since) {
+			continue
+		}
+		if e.EntryType == "fee" {
+			summary.Fees += e.Amount
+		} else {
+			summary.Funding += e.Amount
+		}
+	}
+	return summary, nil
+}
+
+func (s *memLedgerStore) GetLatestLedgerTime(traderID string) (time.Time, error) {
+	var latest time.Time
+	for _, e := range s.entries {
+		if e.TraderID == traderID && e.CreatedAt.After(latest) {
+			latest = e.CreatedAt
+		}
+	}
+	return latest, nil
+}
+
+func TestAutoTraderLedgerGrossVsNet(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	store := &memLedgerStore{entries: make(map[string]database.LedgerEntry)}
+	at := &AutoTrader{id: "t1", name: "test", trader: sim, ledgerStore: store, initialBalance: 1000,
+		orderManager: NewOrderManager("t1", "sim", sim, nil)}
+
+	sim.SetPrice("BTCUSDT", 100)
+	if _, err := at.orderManager.OpenLong("BTCUSDT", 10, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	sim.ApplyFunding("BTCUSDT", 0.001)
+	sim.SetPrice("BTCUSDT", 101)
+	if _, err := at.orderManager.CloseLong("BTCUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+
+	at.syncLedger()
+	at.syncLedger() // 重复同步按流水ID去重
+	if len(store.entries) != 3 {
+		t.Fatalf("应记录开平仓手续费和一笔资金费, got %d", len(store.entries))
+	}
+
+	// 手续费 1 + 1.01，资金费 1（按价格100结算）
+	summary := at.GetLedgerSummary()
+	if !approxEqual(summary.Fees, 2.01) || !approxEqual(summary.Funding, 1) {
+		t.Fatalf("累计费用错误: %+v", summary)
+	}
+
+	info, err := at.GetAccountInfo()
+	if err != nil {
+		t.Fatalf("获取账户信息失败: %v", err)
+	}
+	// 价格盈亏 +10，净盈亏 = 10 - 2.01 - 1
+	if net := info["net_pnl"].(float64); !approxEqual(net, 6.99) {
+		t.Errorf("净盈亏错误: %v", net)
+	}
+	if gross := info["gross_pnl"].(float64); !approxEqual(gross, 10) {
+		t.Errorf("毛盈亏应加回手续费和资金费: %v", gross)
+	}
+}
+
+func TestAutoTraderLedgerSharedAccount(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	sim := newTestSimulatedTrader()
+	sim.SetClock(clock)
+	sim.SetPrice("BTCUSDT", 100)
+	sim.SetPrice("ETHUSDT", 10)
+	store := &memLedgerStore{entries: make(map[string]database.LedgerEntry)}
+
+	// 交易员创建之前账户上已有的成交
+	if _, err := sim.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, err := sim.CloseLong("BTCUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	now = now.Add(time.Hour)
+
+	// 两个交易员共用同一个交易所账户
+	newTrader := func(id string) *AutoTrader {
+		om := NewOrderManager(id, "sim", sim, nil)
+		om.clock = clock
+		return &AutoTrader{id: id, name: id, trader: sim, ledgerStore: store, orderManager: om, clock: clock,
+			config: AutoTraderConfig{ID: id, CreatedAt: now}}
+	}
+	a, b := newTrader("a"), newTrader("b")
+	now = now.Add(time.Minute)
+	if _, err := a.orderManager.OpenLong("BTCUSDT", 10, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, err := b.orderManager.OpenLong("ETHUSDT", 10, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	now = now.Add(time.Minute)
+	sim.ApplyFunding("ETHUSDT", 0.001)
+
+	a.syncLedger()
+	b.syncLedger()
+
+	// 各自只认领自己订单的手续费和自己交易币种的资金费，创建前的成交不计入
+	if got := a.GetLedgerSummary(); !approxEqual(got.Fees, 1) || got.Funding != 0 {
+		t.Errorf("交易员a费用错误: %+v", got)
+	}
+	if got := b.GetLedgerSummary(); !approxEqual(got.Fees, 0.1) || !approxEqual(got.Funding, 0.1) {
+		t.Errorf("交易员b费用错误: %+v", got)
+	}
+}
@@ -0,0 +1,429 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+	"nofx/market"
+)
+
+// liquidationPositionRefresh 两次决策周期之间从交易所刷新持仓（强平价、数量）的间隔，价格使用WSMonitor实时行情
+const liquidationPositionRefresh = time.Minute
+
+// 强平距离处置方式
+const (
+	LiquidationProtect = "protect" // 补设保护止损
+	LiquidationReduce  = "reduce"  // 部分平仓
+)
+
+// LiquidationGuardConfig 强平距离保护配置（距离 = 标记价与强平价之差 / 标记价）
+type LiquidationGuardConfig struct {
+	Enabled             bool
+	WarnDistancePct     float64       // 距离低于该百分比时告警并补设保护止损
+	CriticalDistancePct float64       // 距离低于该百分比时部分平仓（保护止损也设在该距离处）
+	ReducePct           float64       // 部分平仓的比例（%）
+	CheckInterval       time.Duration // 检查间隔
+	Cooldown            time.Duration // 同一持仓两次处置的最小间隔
+}
+
+// DefaultLiquidationGuardConfig 默认强平距离保护配置
+func DefaultLiquidationGuardConfig() LiquidationGuardConfig {
+	return LiquidationGuardConfig{
+		Enabled:             true,
+		WarnDistancePct:     10,
+		CriticalDistancePct: 5,
+		ReducePct:           50,
+		CheckInterval:       10 * time.Second,
+		Cooldown:            5 * time.Minute,
+	}
+}
+
+// LoadLiquidationGuardConfig 从系统配置加载强平距离保护配置
+func LoadLiquidationGuardConfig(db *config.Database) LiquidationGuardConfig {
+	cfg := DefaultLiquidationGuardConfig()
+	if db == nil {
+		return cfg
+	}
+
+	get := db.SystemConfigValue
+	if value := get("liquidation_guard_enabled"); value != "" {
+		cfg.Enabled = value == "true"
+	}
+	parse := func(key string, dst *float64) {
+		if v, err := strconv.ParseFloat(get(key), 64); err == nil && v > 0 {
+			*dst = v
+		}
+	}
+	parse("liquidation_warn_distance_pct", &cfg.WarnDistancePct)
+	parse("liquidation_critical_distance_pct", &cfg.CriticalDistancePct)
+	parse("liquidation_reduce_pct", &cfg.ReducePct)
+	if cfg.ReducePct > 100 {
+		cfg.ReducePct = 100
+	}
+	if seconds, err := strconv.Atoi(get("liquidation_check_interval_seconds")); err == nil && seconds > 0 {
+		cfg.CheckInterval = time.Duration(seconds) * time.Second
+	}
+	if minutes, err := strconv.Atoi(get("liquidation_cooldown_minutes")); err == nil && minutes >= 0 {
+		cfg.Cooldown = time.Duration(minutes) * time.Minute
+	}
+	return cfg
+}
+
+// LiquidationAction 持仓接近强平价时的处置
+type LiquidationAction struct {
+	Type             string // protect / reduce
+	Position         Position
+	MarkPrice        float64
+	DistancePct      float64 // 标记价距强平价的百分比
+	StopPrice        float64 // 保护止损价（距强平价CriticalDistancePct处，已越过时取标记价与强平价的中点）
+	ReduceQuantity   float64 // 部分平仓数量（reduce时）
+	LiquidationPrice float64
+}
+
+// String 处置描述（写入告警和决策记录）
+func (a LiquidationAction) String() string {
+	desc := fmt.Sprintf("%s %s 标记价 %.6f 距强平价 %.6f 仅 %.2f%%", a.Position.Symbol, a.Position.Side, a.MarkPrice, a.LiquidationPrice, a.DistancePct)
+	if a.Type == LiquidationReduce {
+		return fmt.Sprintf("%s，减仓 %.6f 并设置保护止损 %.6f", desc, a.ReduceQuantity, a.StopPrice)
+	}
+	return fmt.Sprintf("%s，设置保护止损 %.6f", desc, a.StopPrice)
+}
+
+// LiquidationGuard 强平距离看门狗
+// 在两次决策周期之间持续比较持仓的实时价格与强平价，距离过近时交由处理函数补设止损或部分平仓，
+// 避免隔夜插针在下一个决策周期之前直接触发强平。
+type LiquidationGuard struct {
+	name   string
+	cfg    LiquidationGuardConfig
+	trader Trader
+	price  func(symbol string) float64 // 实时价格（无数据时返回0，回退到持仓标记价）
+	clock  func() time.Time
+
+	mu         sync.Mutex
+	positions  []Position
+	fetchedAt  time.Time
+	lastAction map[string]time.Time // key: symbol_side
+	running    bool
+	stopCh     chan struct{}
+}
+
+// NewLiquidationGuard 创建强平距离看门狗
+func NewLiquidationGuard(name string, cfg LiquidationGuardConfig, t Trader) *LiquidationGuard {
+	return &LiquidationGuard{
+		name:       name,
+		cfg:        cfg,
+		trader:     t,
+		price:      wsLatestPrice,
+		clock:      time.Now,
+		lastAction: make(map[string]time.Time),
+	}
+}
+
+// wsLatestPrice WSMonitor缓存的最新3分钟K线收盘价
+func wsLatestPrice(symbol string) float64 {
+	if market.WSMonitorCli == nil {
+		return 0
+	}
+	klines, err := market.WSMonitorCli.GetCurrentKlines(symbol, "3m")
+	if err != nil || len(klines) == 0 {
+		return 0
+	}
+	return klines[len(klines)-1].Close
+}
+
+// Config 当前配置
+func (g *LiquidationGuard) Config() LiquidationGuardConfig {
+	return g.cfg
+}
+
+// Start 启动后台检查，需要处置时调用handle
+func (g *LiquidationGuard) Start(handle func([]LiquidationAction)) {
+	if !g.cfg.Enabled {
+		return
+	}
+
+	g.mu.Lock()
+	if g.running {
+		g.mu.Unlock()
+		return
+	}
+	g.running = true
+	stopCh := make(chan struct{})
+	g.stopCh = stopCh
+	g.mu.Unlock()
+
+	log.Printf("🛡️ [%s] 强平距离保护已启动 (告警<%.1f%%, 减仓<%.1f%%, 检查间隔%v)",
+		g.name, g.cfg.WarnDistancePct, g.cfg.CriticalDistancePct, g.cfg.CheckInterval)
+
+	go func() {
+		ticker := time.NewTicker(g.cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if actions := g.Check(); len(actions) > 0 {
+					handle(actions)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查（可重复调用）
+func (g *LiquidationGuard) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.running {
+		return
+	}
+	g.running = false
+	close(g.stopCh)
+}
+
+// Invalidate 持仓发生变化后，下次检查重新从交易所获取持仓
+func (g *LiquidationGuard) Invalidate() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.fetchedAt = time.Time{}
+}
+
+// Check 检查所有持仓的强平距离，返回需要处置的持仓（处于冷却期的持仓跳过）
+func (g *LiquidationGuard) Check() []LiquidationAction {
+	now := g.clock()
+
+	g.mu.Lock()
+	stale := now.Sub(g.fetchedAt) >= liquidationPositionRefresh
+	g.mu.Unlock()
+	if stale {
+		positions, err := g.trader.GetPositions()
+		if err != nil {
+			log.Printf("⚠️ [%s] 强平距离检查获取持仓失败: %v", g.name, err)
+			return nil
+		}
+		g.mu.Lock()
+		g.positions, g.fetchedAt = positions, now
+		g.mu.Unlock()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var actions []LiquidationAction
+	for _, pos := range g.positions {
+		key := pos.Symbol + "_" + pos.Side
+		if last, ok := g.lastAction[key]; ok && now.Sub(last) < g.cfg.Cooldown {
+			continue
+		}
+		markPrice := g.price(pos.Symbol)
+		if markPrice <= 0 {
+			markPrice = pos.MarkPrice
+		}
+		action, ok := g.evaluate(pos, markPrice)
+		if !ok {
+			continue
+		}
+		g.lastAction[key] = now
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// evaluate 计算单个持仓的强平距离和处置方式
+func (g *LiquidationGuard) evaluate(pos Position, markPrice float64) (LiquidationAction, bool) {
+	liq := pos.LiquidationPrice
+	if liq <= 0 || markPrice <= 0 || pos.Quantity <= 0 {
+		return LiquidationAction{}, false
+	}
+
+	critical := g.cfg.CriticalDistancePct / 100
+	var distance, stopPrice float64
+	switch pos.Side {
+	case "long":
+		distance = (markPrice - liq) / markPrice
+		stopPrice = liq / (1 - critical)
+	case "short":
+		distance = (liq - markPrice) / markPrice
+		stopPrice = liq / (1 + critical)
+	default:
+		return LiquidationAction{}, false
+	}
+	if distance*100 >= g.cfg.WarnDistancePct {
+		return LiquidationAction{}, false
+	}
+	// 已进入减仓区时，保护止损设在标记价与强平价中间（止损价不能越过当前价）
+	if (pos.Side == "long" && stopPrice >= markPrice) || (pos.Side == "short" && stopPrice <= markPrice) {
+		stopPrice = (markPrice + liq) / 2
+	}
+
+	action := LiquidationAction{
+		Type:             LiquidationProtect,
+		Position:         pos,
+		MarkPrice:        markPrice,
+		DistancePct:      distance * 100,
+		StopPrice:        stopPrice,
+		LiquidationPrice: liq,
+	}
+	if distance*100 < g.cfg.CriticalDistancePct {
+		action.Type = LiquidationReduce
+		action.ReduceQuantity = pos.Quantity * g.cfg.ReducePct / 100
+	}
+	return action, true
+}
+
+// handleLiquidationRisk 处置接近强平价的持仓：部分平仓、补设保护止损，并告警和写入决策记录
+// 与决策周期的下单阶段互斥执行：周期正在下单时等待其完成，并按最新持仓重新核对处置
+func (at *AutoTrader) handleLiquidationRisk(actions []LiquidationAction) {
+	at.execMu.Lock()
+	defer at.execMu.Unlock()
+
+	actions = at.refreshLiquidationActions(actions)
+	if len(actions) == 0 {
+		return
+	}
+
+	record := &logger.DecisionRecord{
+		Trigger:      TriggerLiquidation,
+		ExecutionLog: []string{},
+		Success:      true,
+	}
+
+	for _, a := range actions {
+		pos := a.Position
+		positionSide := pos.PositionSide()
+		log.Printf("🚨 [%s] 强平预警: %s", at.name, a)
+		record.TriggerEvents = append(record.TriggerEvents, a.String())
+		record.Positions = append(record.Positions, logger.PositionSnapshot{
+			Symbol:           pos.Symbol,
+			Side:             pos.Side,
+			PositionAmt:      pos.Quantity,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        a.MarkPrice,
+			UnrealizedProfit: pos.UnrealizedProfit,
+			Leverage:         float64(pos.Leverage),
+			LiquidationPrice: a.LiquidationPrice,
+		})
+
+		remaining := pos.Quantity
+		if a.Type == LiquidationReduce && a.ReduceQuantity > 0 {
+			action := "close_" + pos.Side
+			actionRecord := logger.DecisionAction{
+				Action:    action,
+				Symbol:    pos.Symbol,
+				Quantity:  a.ReduceQuantity,
+				Leverage:  float64(pos.Leverage),
+				Price:     a.MarkPrice,
+				Timestamp: time.Now(),
+			}
+			at.lifecycle.MarkClosing(pos.Symbol, pos.Side, "liquidation_guard")
+			var order *Order
+			var err error
+			if pos.Side == "long" {
+				order, err = at.orderManager.CloseLong(pos.Symbol, a.ReduceQuantity)
+			} else {
+				order, err = at.orderManager.CloseShort(pos.Symbol, a.ReduceQuantity)
+			}
+			if err != nil {
+				log.Printf("❌ [%s] 强平保护减仓失败 (%s %s): %v", at.name, pos.Symbol, positionSide, err)
+				actionRecord.Error = err.Error()
+				record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 减仓失败: %v", pos.Symbol, action, err))
+			} else {
+				recordOrder(&actionRecord, order)
+				actionRecord.Success = true
+				remaining -= a.ReduceQuantity
+				record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✂️ %s %s 减仓 %.6f", pos.Symbol, action, a.ReduceQuantity))
+			}
+			record.Decisions = append(record.Decisions, actionRecord)
+		}
+
+		if remaining > 0 {
+			at.protectFromLiquidation(pos, remaining, a.StopPrice, record)
+		}
+	}
+
+	if at.liquidationGuard != nil {
+		at.liquidationGuard.Invalidate()
+	}
+	if at.triggerManager != nil {
+		for _, a := range actions {
+			at.triggerManager.Notify(TriggerEvent{
+				Type:   TriggerLiquidation,
+				Symbol: a.Position.Symbol,
+				Detail: fmt.Sprintf("%s 距强平价 %.2f%%", a.Position.Side, a.DistancePct),
+				Time:   time.Now(),
+			})
+		}
+	}
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠️ [%s] 保存强平保护记录失败: %v", at.name, err)
+	}
+}
+
+// refreshLiquidationActions 按最新持仓核对处置（决策周期可能已平仓或调整仓位）
+// 已平仓的持仓跳过，仓位变化时按比例调整减仓数量
+func (at *AutoTrader) refreshLiquidationActions(actions []LiquidationAction) []LiquidationAction {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠️ [%s] 强平保护获取最新持仓失败，按原处置执行: %v", at.name, err)
+		return actions
+	}
+
+	var refreshed []LiquidationAction
+	for _, a := range actions {
+		pos, ok := FindPosition(positions, a.Position.Symbol, a.Position.Side)
+		if !ok {
+			log.Printf("ℹ️ [%s] %s %s 已在决策周期中平仓，跳过强平保护", at.name, a.Position.Symbol, a.Position.Side)
+			continue
+		}
+		if a.Position.Quantity > 0 {
+			a.ReduceQuantity *= pos.Quantity / a.Position.Quantity
+		}
+		a.Position = pos
+		refreshed = append(refreshed, a)
+	}
+	return refreshed
+}
+
+// protectFromLiquidation 当前止损比保护止损更远（或没有止损）时，替换为保护止损
+func (at *AutoTrader) protectFromLiquidation(pos Position, quantity, stopPrice float64, record *logger.DecisionRecord) {
+	symbol, positionSide := pos.Symbol, pos.PositionSide()
+	currentStop := pos.StopLoss
+	if currentStop <= 0 {
+		currentStop = at.orderManager.ActiveStopPrice(symbol, positionSide, OrderTypeStopMarket)
+	}
+	if currentStop > 0 && ((pos.Side == "long" && currentStop >= stopPrice) || (pos.Side == "short" && currentStop <= stopPrice)) {
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("ℹ️ %s %s 已有止损 %.6f，无需调整", symbol, positionSide, currentStop))
+		return
+	}
+
+	// 先撤销该方向的旧委托再重新挂止损止盈，避免交易所同时挂着新旧两个止损单（另一方向和限价挂单保留）
+	takeProfit := pos.TakeProfit
+	if takeProfit <= 0 {
+		takeProfit = at.orderManager.ActiveStopPrice(symbol, positionSide, OrderTypeTakeProfitMarket)
+	}
+	if err := at.orderManager.CancelProtectiveOrders(symbol, positionSide); err != nil {
+		log.Printf("⚠️ [%s] 撤销旧止盈止损单失败 (%s): %v", at.name, symbol, err)
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠️ %s 撤销旧止盈止损单失败: %v", symbol, err))
+		return
+	}
+	if _, err := at.orderManager.SetStopLoss(symbol, positionSide, quantity, stopPrice); err != nil {
+		log.Printf("❌ [%s] 设置强平保护止损失败 (%s %s @ %.6f): %v", at.name, symbol, positionSide, stopPrice, err)
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 保护止损 %.6f 设置失败: %v", symbol, positionSide, stopPrice, err))
+		record.Success = false
+		record.ErrorMessage = err.Error()
+	} else {
+		log.Printf("🛡️ [%s] 已设置强平保护止损: %s %s %.6f → %.6f", at.name, symbol, positionSide, currentStop, stopPrice)
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🛡️ %s %s 止损 %.6f → %.6f", symbol, positionSide, currentStop, stopPrice))
+	}
+	if takeProfit > 0 {
+		if _, err := at.orderManager.SetTakeProfit(symbol, positionSide, quantity, takeProfit); err != nil {
+			log.Printf("⚠️ [%s] 恢复止盈单失败 (%s %s @ %.6f): %v", at.name, symbol, positionSide, takeProfit, err)
+		}
+	}
+}
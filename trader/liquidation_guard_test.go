@@ -0,0 +1,181 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/logger"
+)
+
+// newLiquidationTestGuard 使用固定时钟和可控实时价格的看门狗
+func newLiquidationTestGuard(sim *SimulatedTrader, prices map[string]float64) (*LiquidationGuard, *time.Time) {
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	guard := NewLiquidationGuard("test", DefaultLiquidationGuardConfig(), sim)
+	guard.clock = func() time.Time { return now }
+	guard.price = func(symbol string) float64 { return prices[symbol] }
+	return guard, &now
+}
+
+func TestLiquidationGuardCheck(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("BTCUSDT", 100)
+	// 10倍多单强平价 100 × (1 - 0.1 + 0.005) = 90.5
+	if _, err := sim.OpenLong("BTCUSDT", 1, 10); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	prices := map[string]float64{"BTCUSDT": 100}
+	guard, now := newLiquidationTestGuard(sim, prices)
+
+	actions := guard.Check()
+	if len(actions) != 1 || actions[0].Type != LiquidationProtect {
+		t.Fatalf("距强平价9.5%%应补设保护止损: %+v", actions)
+	}
+	if !approxEqual(actions[0].DistancePct, 9.5) || !approxEqual(actions[0].StopPrice, 90.5/0.95) {
+		t.Errorf("距离或保护止损价错误: %+v", actions[0])
+	}
+
+	// 冷却期内不重复处置
+	prices["BTCUSDT"] = 93
+	if actions := guard.Check(); len(actions) != 0 {
+		t.Errorf("冷却期内不应重复处置: %+v", actions)
+	}
+
+	// 冷却期后价格插针到减仓区：保护止损不能越过当前价
+	*now = now.Add(6 * time.Minute)
+	actions = guard.Check()
+	if len(actions) != 1 || actions[0].Type != LiquidationReduce || !approxEqual(actions[0].ReduceQuantity, 0.5) {
+		t.Fatalf("距强平价低于5%%应部分平仓: %+v", actions)
+	}
+	if !approxEqual(actions[0].StopPrice, (93+90.5)/2) || actions[0].MarkPrice != 93 {
+		t.Errorf("减仓区保护止损应取标记价与强平价中点: %+v", actions[0])
+	}
+
+	// 价格远离强平价时不处置
+	*now = now.Add(6 * time.Minute)
+	prices["BTCUSDT"] = 110
+	if actions := guard.Check(); len(actions) != 0 {
+		t.Errorf("安全距离内不应处置: %+v", actions)
+	}
+}
+
+func TestLiquidationGuardShort(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("ETHUSDT", 100)
+	// 20倍空单强平价 100 × (1 + 0.05 - 0.005) = 104.5
+	if _, err := sim.OpenShort("ETHUSDT", 2, 20); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	guard, _ := newLiquidationTestGuard(sim, map[string]float64{})
+
+	// 无实时价格时使用持仓标记价
+	actions := guard.Check()
+	if len(actions) != 1 || actions[0].Type != LiquidationReduce || !approxEqual(actions[0].ReduceQuantity, 1) {
+		t.Fatalf("距强平价4.5%%应部分平仓: %+v", actions)
+	}
+	if actions[0].StopPrice <= 100 || actions[0].StopPrice >= 104.5 {
+		t.Errorf("空单保护止损应在标记价和强平价之间: %+v", actions[0])
+	}
+}
+
+func TestAutoTraderHandleLiquidationRisk(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("SOLUSDT", 100)
+	if _, err := sim.OpenLong("SOLUSDT", 1, 20); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := sim.SetStopLoss("SOLUSDT", "LONG", 1, 80); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	if _, err := sim.PlaceLimitOrder("SOLUSDT", "SHORT", 1, 110, 5, false, ""); err != nil {
+		t.Fatalf("限价挂单失败: %v", err)
+	}
+	sim.SetPrice("SOLUSDT", 99)
+
+	decisionLogger := logger.NewDecisionLogger(t.TempDir())
+	at := &AutoTrader{
+		name:           "test",
+		trader:         sim,
+		orderManager:   NewOrderManager("t1", "sim", sim, nil),
+		decisionLogger: decisionLogger,
+	}
+	guard, _ := newLiquidationTestGuard(sim, map[string]float64{"SOLUSDT": 99})
+	at.liquidationGuard = guard
+
+	actions := guard.Check()
+	if len(actions) != 1 {
+		t.Fatalf("应产生一个处置: %+v", actions)
+	}
+	at.handleLiquidationRisk(actions)
+
+	positions, err := sim.GetPositions()
+	if err != nil {
+		t.Fatalf("获取持仓失败: %v", err)
+	}
+	if len(positions) != 1 || !approxEqual(positions[0].Quantity, 0.5) {
+		t.Fatalf("应减仓一半: %+v", positions)
+	}
+	if stop := at.orderManager.ActiveStopPrice("SOLUSDT", "LONG", OrderTypeStopMarket); !approxEqual(stop, actions[0].StopPrice) {
+		t.Errorf("应替换为保护止损 %.4f, got %.4f", actions[0].StopPrice, stop)
+	}
+	opens, _ := sim.GetOpenOrders("SOLUSDT")
+	limits := 0
+	for _, o := range opens {
+		if o.Type == OrderTypeLimit {
+			limits++
+		}
+	}
+	if limits != 1 {
+		t.Errorf("替换保护止损不应撤销限价挂单: %+v", opens)
+	}
+
+	records, err := decisionLogger.GetLatestRecords(1)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("应写入决策记录: %v %+v", err, records)
+	}
+	record := records[0]
+	if record.Trigger != TriggerLiquidation || len(record.Decisions) != 1 || !record.Decisions[0].Success || record.Decisions[0].Action != "close_long" {
+		t.Errorf("决策记录应包含强平保护减仓: %+v", record)
+	}
+}
+
+func TestAutoTraderHandleLiquidationRiskWaitsForCycle(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("SOLUSDT", 100)
+	if _, err := sim.OpenLong("SOLUSDT", 1, 20); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	sim.SetPrice("SOLUSDT", 99)
+
+	decisionLogger := logger.NewDecisionLogger(t.TempDir())
+	at := &AutoTrader{
+		name:           "test",
+		trader:         sim,
+		orderManager:   NewOrderManager("t1", "sim", sim, nil),
+		decisionLogger: decisionLogger,
+	}
+	guard, _ := newLiquidationTestGuard(sim, map[string]float64{"SOLUSDT": 99})
+	actions := guard.Check()
+	if len(actions) != 1 {
+		t.Fatalf("应产生一个处置: %+v", actions)
+	}
+
+	// 决策周期下单中：强平保护等待下单完成，周期内已平仓的持仓不再处置
+	at.execMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		at.handleLiquidationRisk(actions)
+		close(done)
+	}()
+	if _, err := sim.CloseLong("SOLUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	at.execMu.Unlock()
+	<-done
+
+	if orders, _ := at.orderManager.Orders("", 10); len(orders) != 0 {
+		t.Errorf("已平仓的持仓不应再下单: %+v", orders)
+	}
+	if records, _ := decisionLogger.GetLatestRecords(1); len(records) != 0 {
+		t.Errorf("没有处置时不应写入决策记录: %+v", records)
+	}
+}
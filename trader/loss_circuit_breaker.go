@@ -8,17 +8,26 @@ import (
 	"time"
 )
 
+// LossEventStore 亏损事件持久化（config.Database实现）
+type LossEventStore interface {
+	SaveLossEvent(event *database.LossEvent) error
+	GetLatestLossEvent(traderID string) (*database.LossEvent, error)
+}
+
 // LossCircuitBreaker 损失断路器 - 防止灾难性亏损
 // 实现硬性限制：连续亏损、日亏损、周亏损、最大回撤
+// 日/周亏损触发后在下一个自然日/周自动恢复，连续亏损和回撤触发后需手动重置
 type LossCircuitBreaker struct {
 	traderID string
-	db       *database.Database
+	userID   string
+	store    LossEventStore
+	now      func() time.Time
 
 	// Hard limits configuration
-	MaxConsecutiveLosses   int     // 最多连续亏损笔数（默认：5）
-	MaxDailyLossPercent    float64 // 日亏损上限百分比（默认：12%）
-	MaxWeeklyLossPercent   float64 // 周亏损上限百分比（默认：20%）
-	MaxDrawdownPercent     float64 // 最大回撤上限（默认：15%）
+	MaxConsecutiveLosses int     // 最多连续亏损笔数（默认：5）
+	MaxDailyLossPercent  float64 // 日亏损上限百分比（默认：12%）
+	MaxWeeklyLossPercent float64 // 周亏损上限百分比（默认：20%）
+	MaxDrawdownPercent   float64 // 最大回撤上限（默认：15%）
 
 	// Current state tracking
 	consecutiveLosses      int
@@ -27,32 +36,73 @@ type LossCircuitBreaker struct {
 	currentDrawdownPercent float64
 	accountPeak            float64
 	lastAccountValue       float64
+	dayStartEquity         float64
+	weekStartEquity        float64
+	dayStart               time.Time
+	weekStart              time.Time
 
 	// Thread safety
 	mu sync.RWMutex
 
 	// Event tracking
-	breachedAt        time.Time
-	breachType        string
-	breachReason      string
-	isBroken          bool
-	recoveryAttempt   int
+	breachedAt      time.Time
+	breachType      string
+	breachReason    string
+	isBroken        bool
+	recoveryAttempt int
 }
 
-// NewLossCircuitBreaker 创建新的断路器
-func NewLossCircuitBreaker(traderID string, db *database.Database) *LossCircuitBreaker {
+// NewLossCircuitBreaker 创建新的断路器（store为nil时不持久化）
+func NewLossCircuitBreaker(traderID, userID string, store LossEventStore) *LossCircuitBreaker {
 	return &LossCircuitBreaker{
-		traderID:               traderID,
-		db:                     db,
-		MaxConsecutiveLosses:   5,
-		MaxDailyLossPercent:    12.0,
-		MaxWeeklyLossPercent:   20.0,
-		MaxDrawdownPercent:     15.0,
-		accountPeak:            100.0,
-		lastAccountValue:       100.0,
+		traderID:             traderID,
+		userID:               userID,
+		store:                store,
+		now:                  time.Now,
+		MaxConsecutiveLosses: 5,
+		MaxDailyLossPercent:  12.0,
+		MaxWeeklyLossPercent: 20.0,
+		MaxDrawdownPercent:   15.0,
 	}
 }
 
+// Load 从最新的亏损事件恢复断路器状态（触发状态、连续亏损、净值峰值和日/周起始净值）
+func (lcb *LossCircuitBreaker) Load() error {
+	if lcb.store == nil {
+		return nil
+	}
+	event, err := lcb.store.GetLatestLossEvent(lcb.traderID)
+	if err != nil {
+		return fmt.Errorf("恢复断路器状态失败: %w", err)
+	}
+	if event == nil {
+		return nil
+	}
+
+	lcb.mu.Lock()
+	defer lcb.mu.Unlock()
+
+	lcb.consecutiveLosses = event.ConsecutiveLosses
+	lcb.recoveryAttempt = event.RecoveryAttempt
+	lcb.accountPeak = event.AccountPeak
+	lcb.lastAccountValue = event.AccountEquity
+	// 日/周起始净值只在同一自然日/周内有效
+	if day := startOfDay(event.CreatedAt); day.Equal(startOfDay(lcb.now())) {
+		lcb.dayStart, lcb.dayStartEquity = day, event.DayStartEquity
+	}
+	if week := startOfWeek(event.CreatedAt); week.Equal(startOfWeek(lcb.now())) {
+		lcb.weekStart, lcb.weekStartEquity = week, event.WeekStartEquity
+	}
+	if event.BreachTriggered {
+		lcb.isBroken = true
+		lcb.breachedAt = event.CreatedAt
+		lcb.breachType = event.EventType
+		lcb.breachReason = event.EventReason
+		log.Printf("🚨 [%s] 恢复断路器触发状态 (%s): %s", lcb.traderID, lcb.breachType, lcb.breachReason)
+	}
+	return nil
+}
+
 // CanTrade 检查是否允许交易
 // 返回 (允许, 原因)
 func (lcb *LossCircuitBreaker) CanTrade() (bool, string) {
@@ -64,7 +114,7 @@ func (lcb *LossCircuitBreaker) CanTrade() (bool, string) {
 		return false, fmt.Sprintf(
 			"🚨 断路器已触发 (%s): %s | 触发时间: %s ago",
 			lcb.breachType, lcb.breachReason,
-			time.Since(lcb.breachedAt).String())
+			lcb.now().Sub(lcb.breachedAt).Round(time.Second).String())
 	}
 
 	// 检查连续亏损
@@ -98,6 +148,38 @@ func (lcb *LossCircuitBreaker) CanTrade() (bool, string) {
 	return true, ""
 }
 
+// Name 风控闸门名称
+func (lcb *LossCircuitBreaker) Name() string {
+	return "circuit_breaker"
+}
+
+// Check 开仓前检查（断路器触发后拦截所有开仓，平仓不受影响）
+func (lcb *LossCircuitBreaker) Check(check RiskCheck) (bool, string) {
+	return lcb.CanTrade()
+}
+
+// OnEquity 每个周期根据账户净值更新日/周盈亏和回撤
+func (lcb *LossCircuitBreaker) OnEquity(equity float64) {
+	lcb.UpdateEquity(equity)
+}
+
+// OnTradeClosed 平仓后更新连续亏损
+func (lcb *LossCircuitBreaker) OnTradeClosed(trade ClosedTrade) {
+	lcb.UpdateAfterTrade(trade.PnL > 0, trade.PnLPct, trade.Equity)
+}
+
+// UpdateEquity 根据当前账户净值更新日/周盈亏和回撤，超限时触发断路器
+func (lcb *LossCircuitBreaker) UpdateEquity(equity float64) {
+	if equity <= 0 {
+		return
+	}
+
+	lcb.mu.Lock()
+	defer lcb.mu.Unlock()
+
+	lcb.updateEquityLocked(equity)
+}
+
 // UpdateAfterTrade 在交易后更新断路器状态
 func (lcb *LossCircuitBreaker) UpdateAfterTrade(
 	isWin bool,
@@ -114,109 +196,124 @@ func (lcb *LossCircuitBreaker) UpdateAfterTrade(
 	// 更新连续亏损
 	if !isWin {
 		lcb.consecutiveLosses++
+		lcb.logLossEvent("trade_loss", fmt.Sprintf("亏损平仓 %.2f%%", pnlPercent), false)
 
 		if lcb.consecutiveLosses >= lcb.MaxConsecutiveLosses {
 			lcb.triggerBreaker("consecutive_loss",
-				fmt.Sprintf("%d笔连续亏损", lcb.consecutiveLosses))
-			log.Printf("🚨 [%s] 断路器触发: 连续%d笔亏损 (上限: %d)",
-				lcb.traderID, lcb.consecutiveLosses, lcb.MaxConsecutiveLosses)
+				fmt.Sprintf("%d笔连续亏损 (上限: %d)", lcb.consecutiveLosses, lcb.MaxConsecutiveLosses))
 		}
-	} else {
+	} else if lcb.consecutiveLosses > 0 {
 		lcb.consecutiveLosses = 0
+		lcb.logLossEvent("loss_streak_reset", "盈利平仓，连续亏损清零", false)
 	}
 
-	// 更新账户价值
-	lcb.lastAccountValue = currentAccountValue
-
-	// 更新账户峰值和回撤
-	if currentAccountValue > lcb.accountPeak {
-		lcb.accountPeak = currentAccountValue
+	if currentAccountValue > 0 {
+		lcb.updateEquityLocked(currentAccountValue)
 	}
+}
 
-	if lcb.accountPeak > 0 {
-		lcb.currentDrawdownPercent = ((lcb.accountPeak - currentAccountValue) / lcb.accountPeak) * 100
-
-		if lcb.currentDrawdownPercent > lcb.MaxDrawdownPercent {
-			lcb.triggerBreaker("max_drawdown",
-				fmt.Sprintf("回撤 %.2f%% 超过上限 %.2f%%",
-					lcb.currentDrawdownPercent, lcb.MaxDrawdownPercent))
-			log.Printf("🚨 [%s] 断路器触发: 回撤 %.2f%% (上限: %.2f%%)",
-				lcb.traderID, lcb.currentDrawdownPercent, lcb.MaxDrawdownPercent)
+// updateEquityLocked 更新净值相关状态（调用方需持有写锁）
+func (lcb *LossCircuitBreaker) updateEquityLocked(equity float64) {
+	now := lcb.now()
+	lcb.lastAccountValue = equity
+
+	// 跨日/跨周时以当前净值作为新的起始净值，日/周亏损触发的断路器自动恢复
+	recovered := ""
+	if day := startOfDay(now); !day.Equal(lcb.dayStart) || lcb.dayStartEquity <= 0 {
+		lcb.dayStart, lcb.dayStartEquity = day, equity
+		if lcb.isBroken && lcb.breachType == "daily_loss" {
+			recovered = "新的交易日，日亏损断路器自动恢复"
 		}
 	}
-
-	// 更新日亏损（需要查询今日交易）
-	if lcb.shouldCheckDailyLimit() {
-		lcb.updateDailyPnL()
-
-		if lcb.todayPnLPercent < -lcb.MaxDailyLossPercent {
-			lcb.triggerBreaker("daily_loss",
-				fmt.Sprintf("日亏损 %.2f%% 超过上限 %.2f%%",
-					lcb.todayPnLPercent, lcb.MaxDailyLossPercent))
-			log.Printf("🚨 [%s] 断路器触发: 日亏损 %.2f%% (上限: %.2f%%)",
-				lcb.traderID, lcb.todayPnLPercent, lcb.MaxDailyLossPercent)
+	if week := startOfWeek(now); !week.Equal(lcb.weekStart) || lcb.weekStartEquity <= 0 {
+		lcb.weekStart, lcb.weekStartEquity = week, equity
+		if lcb.isBroken && lcb.breachType == "weekly_loss" {
+			recovered = "新的交易周，周亏损断路器自动恢复"
 		}
 	}
 
-	// 更新周亏损（需要查询本周交易）
-	if lcb.shouldCheckWeeklyLimit() {
-		lcb.updateWeeklyPnL()
+	// 更新账户峰值和回撤
+	if equity > lcb.accountPeak {
+		lcb.accountPeak = equity
+	}
+	lcb.currentDrawdownPercent = (lcb.accountPeak - equity) / lcb.accountPeak * 100
+	lcb.todayPnLPercent = (equity - lcb.dayStartEquity) / lcb.dayStartEquity * 100
+	lcb.weeklyPnLPercent = (equity - lcb.weekStartEquity) / lcb.weekStartEquity * 100
+
+	if recovered != "" {
+		lcb.resetLocked(recovered)
+		lcb.logLossEvent("auto_reset", recovered, false)
+	}
+	if lcb.isBroken {
+		return
+	}
 
-		if lcb.weeklyPnLPercent < -lcb.MaxWeeklyLossPercent {
-			lcb.triggerBreaker("weekly_loss",
-				fmt.Sprintf("周亏损 %.2f%% 超过上限 %.2f%%",
-					lcb.weeklyPnLPercent, lcb.MaxWeeklyLossPercent))
-			log.Printf("🚨 [%s] 断路器触发: 周亏损 %.2f%% (上限: %.2f%%)",
-				lcb.traderID, lcb.weeklyPnLPercent, lcb.MaxWeeklyLossPercent)
-		}
+	switch {
+	case lcb.currentDrawdownPercent > lcb.MaxDrawdownPercent:
+		lcb.triggerBreaker("max_drawdown",
+			fmt.Sprintf("回撤 %.2f%% 超过上限 %.2f%%",
+				lcb.currentDrawdownPercent, lcb.MaxDrawdownPercent))
+	case lcb.todayPnLPercent < -lcb.MaxDailyLossPercent:
+		lcb.triggerBreaker("daily_loss",
+			fmt.Sprintf("日亏损 %.2f%% 超过上限 %.2f%%",
+				lcb.todayPnLPercent, lcb.MaxDailyLossPercent))
+	case lcb.weeklyPnLPercent < -lcb.MaxWeeklyLossPercent:
+		lcb.triggerBreaker("weekly_loss",
+			fmt.Sprintf("周亏损 %.2f%% 超过上限 %.2f%%",
+				lcb.weeklyPnLPercent, lcb.MaxWeeklyLossPercent))
 	}
 }
 
 // triggerBreaker 触发断路器
 func (lcb *LossCircuitBreaker) triggerBreaker(breachType, reason string) {
 	lcb.isBroken = true
-	lcb.breachedAt = time.Now()
+	lcb.breachedAt = lcb.now()
 	lcb.breachType = breachType
 	lcb.breachReason = reason
 
-	// 记录到数据库
-	if lcb.db != nil {
-		lcb.logLossEvent(breachType, reason)
-	}
-}
-
-// logLossEvent 记录亏损事件
-func (lcb *LossCircuitBreaker) logLossEvent(eventType, reason string) {
-	// 这里会在实现数据库持久化时填充
-	// 暂时仅记录日志
-	log.Printf("📊 [%s] 亏损事件: type=%s, reason=%s, time=%s",
-		lcb.traderID, eventType, reason, time.Now().Format("2006-01-02 15:04:05"))
+	log.Printf("🚨 [%s] 断路器触发 (%s): %s", lcb.traderID, breachType, reason)
+	lcb.logLossEvent(breachType, reason, true)
 }
 
-// updateDailyPnL 更新日亏损百分比
-func (lcb *LossCircuitBreaker) updateDailyPnL() {
-	// TODO: 查询数据库获取今天的所有交易
-	// 计算日PnL百分比
-	// lcb.todayPnLPercent = ...
-}
+// logLossEvent 记录亏损事件（持久化当前断路器状态，重启后由Load恢复）
+func (lcb *LossCircuitBreaker) logLossEvent(eventType, reason string, breach bool) {
+	log.Printf("📊 [%s] 亏损事件: type=%s, reason=%s", lcb.traderID, eventType, reason)
+	if lcb.store == nil {
+		return
+	}
 
-// updateWeeklyPnL 更新周亏损百分比
-func (lcb *LossCircuitBreaker) updateWeeklyPnL() {
-	// TODO: 查询数据库获取本周的所有交易
-	// 计算周PnL百分比
-	// lcb.weeklyPnLPercent = ...
+	event := &database.LossEvent{
+		UserID:            lcb.userID,
+		TraderID:          lcb.traderID,
+		EventType:         eventType,
+		EventReason:       reason,
+		ConsecutiveLosses: lcb.consecutiveLosses,
+		DailyLossPct:      lcb.todayPnLPercent,
+		WeeklyLossPct:     lcb.weeklyPnLPercent,
+		MaxDrawdownPct:    lcb.currentDrawdownPercent,
+		AccountEquity:     lcb.lastAccountValue,
+		BreachTriggered:   breach,
+		RecoveryAttempt:   lcb.recoveryAttempt,
+		AccountPeak:       lcb.accountPeak,
+		DayStartEquity:    lcb.dayStartEquity,
+		WeekStartEquity:   lcb.weekStartEquity,
+		CreatedAt:         lcb.now(),
+	}
+	if err := lcb.store.SaveLossEvent(event); err != nil {
+		log.Printf("⚠️ [%s] %v", lcb.traderID, err)
+	}
 }
 
-// shouldCheckDailyLimit 是否应该检查日限制
-func (lcb *LossCircuitBreaker) shouldCheckDailyLimit() bool {
-	// 在交易时检查
-	return true
+// startOfDay 当天零点（本地时区）
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
 }
 
-// shouldCheckWeeklyLimit 是否应该检查周限制
-func (lcb *LossCircuitBreaker) shouldCheckWeeklyLimit() bool {
-	// 在交易时检查
-	return true
+// startOfWeek 本周一零点（本地时区）
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return startOfDay(t).AddDate(0, 0, -offset)
 }
 
 // GetStatus 获取断路器状态
@@ -224,36 +321,56 @@ func (lcb *LossCircuitBreaker) GetStatus() map[string]interface{} {
 	lcb.mu.RLock()
 	defer lcb.mu.RUnlock()
 
+	breachedAt := ""
+	if lcb.isBroken {
+		breachedAt = lcb.breachedAt.Format(time.RFC3339)
+	}
+
 	return map[string]interface{}{
-		"is_broken":              lcb.isBroken,
-		"breach_type":            lcb.breachType,
-		"breach_reason":          lcb.breachReason,
-		"consecutive_losses":     lcb.consecutiveLosses,
-		"today_pnl_percent":      lcb.todayPnLPercent,
-		"weekly_pnl_percent":     lcb.weeklyPnLPercent,
-		"current_drawdown":       lcb.currentDrawdownPercent,
-		"account_peak":           lcb.accountPeak,
-		"last_account_value":     lcb.lastAccountValue,
-		"breached_at":            lcb.breachedAt.String(),
-		"max_consecutive_limit":  lcb.MaxConsecutiveLosses,
-		"max_daily_loss_limit":   lcb.MaxDailyLossPercent,
-		"max_weekly_loss_limit":  lcb.MaxWeeklyLossPercent,
-		"max_drawdown_limit":     lcb.MaxDrawdownPercent,
+		"is_broken":             lcb.isBroken,
+		"breach_type":           lcb.breachType,
+		"breach_reason":         lcb.breachReason,
+		"consecutive_losses":    lcb.consecutiveLosses,
+		"today_pnl_percent":     lcb.todayPnLPercent,
+		"weekly_pnl_percent":    lcb.weeklyPnLPercent,
+		"current_drawdown":      lcb.currentDrawdownPercent,
+		"account_peak":          lcb.accountPeak,
+		"last_account_value":    lcb.lastAccountValue,
+		"breached_at":           breachedAt,
+		"recovery_attempt":      lcb.recoveryAttempt,
+		"max_consecutive_limit": lcb.MaxConsecutiveLosses,
+		"max_daily_loss_limit":  lcb.MaxDailyLossPercent,
+		"max_weekly_loss_limit": lcb.MaxWeeklyLossPercent,
+		"max_drawdown_limit":    lcb.MaxDrawdownPercent,
 	}
 }
 
-// Reset 重置断路器（用于每日或每周重置）
+// Reset 手动重置断路器（清除触发状态和连续亏损，日/周盈亏和回撤从当前净值重新计算）
 func (lcb *LossCircuitBreaker) Reset(reason string) {
 	lcb.mu.Lock()
 	defer lcb.mu.Unlock()
 
+	lcb.resetLocked(reason)
+	lcb.consecutiveLosses = 0
+	if lcb.lastAccountValue > 0 {
+		lcb.accountPeak = lcb.lastAccountValue
+		lcb.dayStartEquity = lcb.lastAccountValue
+		lcb.weekStartEquity = lcb.lastAccountValue
+	}
+	lcb.currentDrawdownPercent = 0
+	lcb.todayPnLPercent = 0
+	lcb.weeklyPnLPercent = 0
+	lcb.logLossEvent("manual_reset", reason, false)
+}
+
+// resetLocked 清除触发状态（调用方需持有写锁）
+func (lcb *LossCircuitBreaker) resetLocked(reason string) {
 	log.Printf("🔄 [%s] 断路器重置: %s", lcb.traderID, reason)
 
 	lcb.isBroken = false
 	lcb.breachedAt = time.Time{}
 	lcb.breachType = ""
 	lcb.breachReason = ""
-	lcb.consecutiveLosses = 0
 	lcb.recoveryAttempt++
 }
 
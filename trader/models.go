@@ -0,0 +1,69 @@
+package trader
+
+import (
+	"strings"
+	"time"
+)
+
+// Balance 账户余额（各交易所统一口径，单位 USDT）
+type Balance struct {
+	TotalWalletBalance    float64 `json:"totalWalletBalance"`    // 钱包余额（不含未实现盈亏）
+	AvailableBalance      float64 `json:"availableBalance"`      // 可用于开仓的余额
+	TotalUnrealizedProfit float64 `json:"totalUnrealizedProfit"` // 未实现盈亏
+	UsedMargin            float64 `json:"usedMargin"`            // 持仓占用保证金
+}
+
+// TotalEquity 账户净值（钱包余额 + 未实现盈亏）
+func (b *Balance) TotalEquity() float64 {
+	return b.TotalWalletBalance + b.TotalUnrealizedProfit
+}
+
+// Position 持仓（各交易所统一口径）
+type Position struct {
+	Symbol           string    `json:"symbol"`           // 统一为 BTCUSDT 格式
+	Side             string    `json:"side"`             // long / short
+	Quantity         float64   `json:"positionAmt"`      // 持仓数量（币的数量，恒为正数）
+	EntryPrice       float64   `json:"entryPrice"`       // 开仓均价
+	MarkPrice        float64   `json:"markPrice"`        // 标记价格
+	UnrealizedProfit float64   `json:"unRealizedProfit"` // 未实现盈亏
+	Leverage         int       `json:"leverage"`         // 杠杆倍数
+	LiquidationPrice float64   `json:"liquidationPrice"` // 强平价
+	StopLoss         float64   `json:"stopLoss,omitempty"`
+	TakeProfit       float64   `json:"takeProfit,omitempty"`
+	OpenTime         time.Time `json:"openTime,omitempty"` // 开仓时间（交易所不提供时为零值）
+}
+
+// PositionSide 持仓方向（LONG/SHORT，用于止损止盈等接口）
+func (p Position) PositionSide() string {
+	return strings.ToUpper(p.Side)
+}
+
+// MarginUsed 持仓占用保证金（杠杆未知时按10倍估算）
+func (p Position) MarginUsed() float64 {
+	leverage := p.Leverage
+	if leverage <= 0 {
+		leverage = 10
+	}
+	return p.Quantity * p.MarkPrice / float64(leverage)
+}
+
+// FindPosition 查找指定币种和方向的持仓
+func FindPosition(positions []Position, symbol, side string) (Position, bool) {
+	side = strings.ToLower(side)
+	for _, pos := range positions {
+		if pos.Symbol == symbol && pos.Side == side {
+			return pos, true
+		}
+	}
+	return Position{}, false
+}
+
+// OrderResult 下单结果（各交易所统一口径）
+type OrderResult struct {
+	OrderID     string      `json:"orderId"` // 交易所订单ID（无法获取时为空）
+	Symbol      string      `json:"symbol"`
+	Status      OrderStatus `json:"status"`        // 为空表示交易所未返回状态
+	ExecutedQty float64     `json:"executedQty"`   // 已成交数量（币）
+	AvgPrice    float64     `json:"avgPrice"`      // 成交均价
+	Fee         float64     `json:"fee,omitempty"` // 手续费（交易所未返回时为0）
+}
@@ -0,0 +1,779 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/database"
+	"nofx/decision"
+	"nofx/logger"
+)
+
+// 组合平仓原因
+const (
+	GroupCloseDecision   = "decision"    // AI决策 close_pair
+	GroupCloseStopLoss   = "stop_loss"   // 合计亏损达到组合止损
+	GroupCloseTakeProfit = "take_profit" // 合计盈利达到组合止盈
+	GroupCloseLegMissing = "leg_missing" // 某条腿已在交易所平仓（止损/强平保护/手动），平掉其余腿
+)
+
+// minLegSizeUSD 组合中单条腿的最小开仓金额
+const minLegSizeUSD = 10.0
+
+// partialFillTolerance 腿的成交比例低于目标比例超过该幅度时视为部分成交（忽略数量精度取整造成的差异）
+const partialFillTolerance = 0.01
+
+// PositionGroupStore 组合持仓存储（*config.Database 实现）
+type PositionGroupStore interface {
+	SavePositionGroup(g database.PositionGroup) error
+	GetOpenPositionGroups(traderID string) ([]database.PositionGroup, error)
+	ClosePositionGroup(id string, realizedPnL float64, reason string, closedAt time.Time) error
+}
+
+// PositionGroupManager 组合持仓管理：记录多腿决策开出的各腿，按合计盈亏执行组合止损止盈
+// 组合的腿不单独设置交易所止损止盈，也不参与跟踪止损（强平保护仍按单腿生效）
+type PositionGroupManager struct {
+	traderID string
+	store    PositionGroupStore // nil时只在内存中管理
+	clock    func() time.Time
+
+	mu     sync.Mutex
+	groups map[string]*database.PositionGroup
+}
+
+// NewPositionGroupManager 创建组合持仓管理器
+func NewPositionGroupManager(traderID string, store PositionGroupStore) *PositionGroupManager {
+	return &PositionGroupManager{
+		traderID: traderID,
+		store:    store,
+		clock:    time.Now,
+		groups:   make(map[string]*database.PositionGroup),
+	}
+}
+
+// Load 从数据库恢复未平仓的组合持仓
+func (m *PositionGroupManager) Load() error {
+	if m == nil || m.store == nil {
+		return nil
+	}
+	groups, err := m.store.GetOpenPositionGroups(m.traderID)
+	if err != nil {
+		return fmt.Errorf("加载组合持仓失败: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range groups {
+		m.groups[groups[i].ID] = &groups[i]
+	}
+	if len(groups) > 0 {
+		log.Printf("🔗 [%s] 恢复 %d 个组合持仓", m.traderID, len(groups))
+	}
+	return nil
+}
+
+// Add 记录新开的组合持仓（ID为空时自动生成）
+// 持久化失败时仍在内存中管理，返回的错误只影响重启后的恢复
+func (m *PositionGroupManager) Add(g database.PositionGroup) (database.PositionGroup, error) {
+	now := m.clock()
+	if g.ID == "" {
+		g.ID = fmt.Sprintf("pg_%d", now.UnixNano())
+	}
+	g.TraderID = m.traderID
+	g.Status = "open"
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = now
+	}
+
+	m.mu.Lock()
+	m.groups[g.ID] = &g
+	m.mu.Unlock()
+
+	if m.store != nil {
+		if err := m.store.SavePositionGroup(g); err != nil {
+			return g, err
+		}
+	}
+	return g, nil
+}
+
+// Get 按ID获取未平仓的组合持仓
+func (m *PositionGroupManager) Get(id string) (database.PositionGroup, bool) {
+	if m == nil {
+		return database.PositionGroup{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.groups[id]
+	if !ok {
+		return database.PositionGroup{}, false
+	}
+	return *g, true
+}
+
+// Groups 所有未平仓的组合持仓（按开仓时间排序）
+func (m *PositionGroupManager) Groups() []database.PositionGroup {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	groups := make([]database.PositionGroup, 0, len(m.groups))
+	for _, g := range m.groups {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].CreatedAt.Equal(groups[j].CreatedAt) {
+			return groups[i].ID < groups[j].ID
+		}
+		return groups[i].CreatedAt.Before(groups[j].CreatedAt)
+	})
+	return groups
+}
+
+// Contains 指定币种和方向的合约持仓是否属于某个组合（现货腿不对应合约持仓）
+func (m *PositionGroupManager) Contains(symbol, side string) bool {
+	if m == nil {
+		return false
+	}
+	side = strings.ToLower(side)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, g := range m.groups {
+		for _, leg := range g.Legs {
+			if leg.Market != decision.LegMarketSpot && leg.Symbol == symbol && leg.Side == side {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close 标记组合已平仓（数据库更新失败时同样从内存中移除，交易所侧已无持仓）
+func (m *PositionGroupManager) Close(id string, realizedPnL float64, reason string) error {
+	m.mu.Lock()
+	delete(m.groups, id)
+	m.mu.Unlock()
+
+	if m.store == nil {
+		return nil
+	}
+	return m.store.ClosePositionGroup(id, realizedPnL, reason, m.clock())
+}
+
+// GroupStatus 组合持仓的当前盈亏
+type GroupStatus struct {
+	Group         database.PositionGroup
+	EntryNotional float64  // 各腿开仓名义价值合计
+	UnrealizedPnL float64  // 各腿按标记价计算的盈亏合计
+	PnLPct        float64  // 合计盈亏 / 开仓名义价值（%）
+	MissingLegs   []string // 交易所已不存在的腿（symbol_side）
+}
+
+// ExitReason 触发的组合平仓原因（未触发时为空）
+func (s GroupStatus) ExitReason() string {
+	switch {
+	case len(s.MissingLegs) > 0:
+		return GroupCloseLegMissing
+	case s.Group.StopLossPct > 0 && s.PnLPct <= -s.Group.StopLossPct:
+		return GroupCloseStopLoss
+	case s.Group.TakeProfitPct > 0 && s.PnLPct >= s.Group.TakeProfitPct:
+		return GroupCloseTakeProfit
+	}
+	return ""
+}
+
+// EvaluateGroup 按持仓标记价计算组合的合计盈亏（现货腿按 spotLegPositions 生成的现货持仓计算）
+func EvaluateGroup(g database.PositionGroup, positions []Position) GroupStatus {
+	status := GroupStatus{Group: g}
+	for _, leg := range g.Legs {
+		status.EntryNotional += leg.Quantity * leg.EntryPrice
+		pos, ok := FindPosition(positions, legPositionSymbol(leg), leg.Side)
+		if !ok {
+			status.MissingLegs = append(status.MissingLegs, legPositionSymbol(leg)+"_"+leg.Side)
+			continue
+		}
+		status.UnrealizedPnL += legPnL(leg, pos.MarkPrice, math.Min(leg.Quantity, pos.Quantity))
+	}
+	if status.EntryNotional > 0 {
+		status.PnLPct = status.UnrealizedPnL / status.EntryNotional * 100
+	}
+	return status
+}
+
+// legPnL 一条腿按指定价格平仓的盈亏
+func legPnL(leg database.PositionGroupLeg, price, quantity float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	if leg.Side == "short" {
+		return (leg.EntryPrice - price) * quantity
+	}
+	return (price - leg.EntryPrice) * quantity
+}
+
+// groupDecisionLegs 组合持仓的腿（决策上下文格式）
+func groupDecisionLegs(g database.PositionGroup) []decision.DecisionLeg {
+	legs := make([]decision.DecisionLeg, 0, len(g.Legs))
+	for _, leg := range g.Legs {
+		legs = append(legs, decision.DecisionLeg{Symbol: leg.Symbol, Side: leg.Side, Ratio: leg.Ratio, Market: leg.Market})
+	}
+	return legs
+}
+
+// legPositionSymbol 腿在组合检查持仓列表中的币种（现货腿为 BTCUSDT(spot)，与同币种的合约持仓区分）
+func legPositionSymbol(leg database.PositionGroupLeg) string {
+	return decision.DecisionLeg{Symbol: leg.Symbol, Market: leg.Market}.Label()
+}
+
+// spotLegPositions 各组合现货腿对应的现货持仓（只用于组合盈亏和腿缺失检查，现货余额为0时视为腿已不存在）
+func (at *AutoTrader) spotLegPositions() ([]Position, error) {
+	var positions []Position
+	for _, g := range at.positionGroups.Groups() {
+		for _, leg := range g.Legs {
+			if leg.Market != decision.LegMarketSpot {
+				continue
+			}
+			spot, ok := at.trader.(SpotTrader)
+			if !ok {
+				return nil, fmt.Errorf("当前交易所不支持现货腿")
+			}
+			quantity, err := spot.GetSpotBalance(leg.Symbol)
+			if err != nil {
+				return nil, fmt.Errorf("获取 %s 现货余额失败: %w", leg.Symbol, err)
+			}
+			if quantity <= 0 {
+				continue
+			}
+			price, err := spot.GetSpotPrice(leg.Symbol)
+			if err != nil {
+				return nil, fmt.Errorf("获取 %s 现货价格失败: %w", leg.Symbol, err)
+			}
+			positions = append(positions, Position{
+				Symbol:     legPositionSymbol(leg),
+				Side:       leg.Side,
+				Quantity:   quantity,
+				EntryPrice: leg.EntryPrice,
+				MarkPrice:  price,
+				Leverage:   1,
+			})
+		}
+	}
+	return positions, nil
+}
+
+// groupPositions 组合检查用的持仓列表：合约持仓加上现货腿的现货持仓
+func (at *AutoTrader) groupPositions() ([]Position, error) {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+	spot, err := at.spotLegPositions()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]Position(nil), positions...), spot...), nil
+}
+
+// positionGroupInfos 组合持仓的盈亏信息（供AI决策上下文使用）
+func (at *AutoTrader) positionGroupInfos(positions []Position) []decision.PositionGroupInfo {
+	groups := at.positionGroups.Groups()
+	if len(groups) == 0 {
+		return nil
+	}
+	if spot, err := at.spotLegPositions(); err != nil {
+		log.Printf("⚠️ 获取组合现货腿持仓失败: %v", err)
+	} else if len(spot) > 0 {
+		positions = append(append([]Position(nil), positions...), spot...)
+	}
+	infos := make([]decision.PositionGroupInfo, 0, len(groups))
+	for _, g := range groups {
+		status := EvaluateGroup(g, positions)
+		infos = append(infos, decision.PositionGroupInfo{
+			ID:            g.ID,
+			Legs:          groupDecisionLegs(g),
+			EntryNotional: status.EntryNotional,
+			UnrealizedPnL: status.UnrealizedPnL,
+			PnLPct:        status.PnLPct,
+			StopLossPct:   g.StopLossPct,
+			TakeProfitPct: g.TakeProfitPct,
+			HoldingMins:   int(at.now().Sub(g.CreatedAt).Minutes()),
+		})
+	}
+	return infos
+}
+
+// pairRiskChecks 组合开仓按各腿分别检查风控闸门
+// 任一腿被拦截则整个组合拦截；任一腿被缩减则所有腿按同一比例缩减，保持对冲比例
+func (at *AutoTrader) pairRiskChecks(d *decision.Decision, ctx *decision.Context, positionCount int, record *logger.DecisionRecord) ([]RiskCheck, bool, string) {
+	notionals := decision.LegNotionals(d.Legs, d.PositionSizeUSD)
+	checks := make([]RiskCheck, len(d.Legs))
+	scale, scaleReason := 1.0, ""
+	for i, leg := range d.Legs {
+		leverage := d.Leverage
+		if leg.IsSpot() {
+			leverage = 1 // 现货腿不加杠杆
+		}
+		legDecision := decision.Decision{Symbol: leg.Symbol, Action: "open_" + leg.Side, Leverage: leverage, PositionSizeUSD: notionals[i]}
+		check := at.riskCheck(&legDecision, ctx, positionCount+i)
+		// 组合止损按合计盈亏触发，单腿按组合止损百分比估算亏损
+		if check.EntryPrice > 0 && d.StopLossPct > 0 {
+			if leg.Side == "short" {
+				check.StopLoss = check.EntryPrice * (1 + d.StopLossPct/100)
+			} else {
+				check.StopLoss = check.EntryPrice * (1 - d.StopLossPct/100)
+			}
+		}
+		if ok, reason := at.checkRiskGates(check); !ok {
+			return nil, false, fmt.Sprintf("%s: %s", leg.Symbol, reason)
+		}
+		if size, why := at.limitRiskSize(check); size < check.PositionSizeUSD {
+			if size <= 0 {
+				return nil, false, fmt.Sprintf("%s: %s", leg.Symbol, why)
+			}
+			if ratio := size / check.PositionSizeUSD; ratio < scale {
+				scale, scaleReason = ratio, fmt.Sprintf("%s: %s", leg.Symbol, why)
+			}
+		}
+		checks[i] = check
+	}
+
+	if scale < 1 {
+		size := d.PositionSizeUSD * scale
+		log.Printf("📉 风控缩减组合仓位 (%s): %.2f → %.2f USDT, %s", d.Symbol, d.PositionSizeUSD, size, scaleReason)
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("📉 %s %s 仓位由 %.2f 缩减至 %.2f USDT: %s", d.Symbol, d.Action, d.PositionSizeUSD, size, scaleReason))
+		d.PositionSizeUSD = size
+		for i := range checks {
+			checks[i].PositionSizeUSD *= scale
+		}
+	}
+	return checks, true, ""
+}
+
+// executeOpenPairWithRecord 执行组合开仓：各腿依次市价开仓，任一腿失败时回滚已成交的腿
+// 某条腿部分成交时，其余腿按同一成交比例调整（已成交的腿减仓，后续腿按比例下单），调整后腿金额过小则平掉所有腿
+// 现货腿通过 SpotTrader 买入（不经过订单管理器），交易所不支持现货时整个组合拒绝
+func (at *AutoTrader) executeOpenPairWithRecord(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	log.Printf("  🔗 开组合仓: %s", d.Symbol)
+	if at.positionGroups == nil {
+		return fmt.Errorf("未启用组合持仓管理")
+	}
+
+	// 组合的腿不能与现有持仓叠加，否则无法区分组合仓位
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return fmt.Errorf("获取持仓失败，无法检查组合的腿是否与现有持仓叠加: %w", err)
+	}
+	for _, leg := range d.Legs {
+		if leg.IsSpot() {
+			continue
+		}
+		if _, exists := FindPosition(positions, leg.Symbol, leg.Side); exists {
+			return fmt.Errorf("❌ %s 已有%s仓，组合的腿不能与现有持仓叠加", leg.Symbol, leg.Side)
+		}
+	}
+	for _, leg := range d.Legs {
+		if !leg.IsSpot() {
+			continue
+		}
+		if _, ok := at.trader.(SpotTrader); !ok {
+			return fmt.Errorf("当前交易所不支持现货腿: %s", leg.Label())
+		}
+		// 现货余额无法区分所属组合，同一币种的现货腿只能属于一个组合
+		for _, g := range at.positionGroups.Groups() {
+			for _, held := range g.Legs {
+				if held.Market == decision.LegMarketSpot && held.Symbol == leg.Symbol {
+					return fmt.Errorf("❌ %s 已属于组合 %s，不能重复开现货腿", leg.Label(), g.ID)
+				}
+			}
+		}
+	}
+
+	// 保证金检查：合约腿按杠杆占用保证金，现货腿全额占用资金，超出可用保证金80%时各腿按同一比例缩减
+	totalUSD := d.PositionSizeUSD
+	if balance, err := at.trader.GetBalance(); err == nil {
+		required := pairCapital(d.Legs, totalUSD, d.Leverage)
+		if maxCapital := balance.AvailableBalance * 0.80; required > maxCapital {
+			maxPositionValue := totalUSD * maxCapital / required
+			log.Printf("  ⚠️ 保证金检查: 组合请求 $%.2f，最大可开仓 $%.2f，各腿按比例缩减", totalUSD, maxPositionValue)
+			totalUSD = maxPositionValue
+		}
+	} else {
+		log.Printf("  ⚠️ 无法获取账户余额进行保证金检查: %v, 继续使用AI决定的仓位", err)
+	}
+
+	// 先确定各腿数量，全部可开仓后再下单
+	notionals := decision.LegNotionals(d.Legs, totalUSD)
+	legs := make([]database.PositionGroupLeg, len(d.Legs))
+	for i, leg := range d.Legs {
+		if notionals[i] < minLegSizeUSD {
+			return fmt.Errorf("%s 腿开仓金额过小: $%.2f < 最小要求 $%.2f", leg.Symbol, notionals[i], minLegSizeUSD)
+		}
+		price, err := at.legPrice(leg)
+		if err != nil {
+			return err
+		}
+		legs[i] = database.PositionGroupLeg{
+			Symbol:     leg.Symbol,
+			Side:       leg.Side,
+			Ratio:      leg.Ratio,
+			Quantity:   notionals[i] / price,
+			EntryPrice: price,
+			Market:     leg.Market,
+		}
+	}
+
+	// 各腿使用市价单，缩短腿之间的成交时间差
+	targets := make([]float64, len(legs))
+	for i := range legs {
+		targets[i] = legs[i].Quantity
+	}
+	fillRatio := 1.0 // 各腿统一的成交比例（相对目标数量）
+	fillBase := len(actionRecord.Legs)
+	var opened []database.PositionGroupLeg
+	for i := range legs {
+		leg := &legs[i]
+		leg.Quantity = targets[i] * fillRatio
+		order, err := at.openLeg(*leg, int(d.Leverage))
+		fill := logger.LegFill{Symbol: leg.Symbol, Side: leg.Side, Market: leg.Market, Quantity: leg.Quantity, Price: leg.EntryPrice}
+		if order != nil {
+			fill.ClientOrderID = order.ClientOrderID
+			actionRecord.Fee += order.Fee
+			if err == nil && order.FilledQty <= 0 {
+				err = fmt.Errorf("订单未成交")
+			}
+		}
+		if err != nil {
+			fill.Error = err.Error()
+			actionRecord.Legs = append(actionRecord.Legs, fill)
+			if rollbackErr := at.rollbackPairLegs(opened); rollbackErr != nil {
+				return fmt.Errorf("组合 %s 的 %s 腿开仓失败: %v；回滚已成交的腿失败，请人工处理: %w", d.Symbol, leg.Symbol, err, rollbackErr)
+			}
+			return fmt.Errorf("组合 %s 的 %s 腿开仓失败，已回滚 %d 条已成交的腿: %w", d.Symbol, leg.Symbol, len(opened), err)
+		}
+
+		leg.Quantity = order.FilledQty
+		if order.AvgPrice > 0 {
+			leg.EntryPrice = order.AvgPrice
+		}
+		fill.Quantity, fill.Price = leg.Quantity, leg.EntryPrice
+		actionRecord.Legs = append(actionRecord.Legs, fill)
+		opened = legs[:i+1]
+		log.Printf("    ✓ %s %s 成交 %.4f @ %.4f", legPositionSymbol(*leg), leg.Side, leg.Quantity, leg.EntryPrice)
+
+		// 部分成交：已成交的腿减仓到同一比例，后续腿按该比例下单
+		if ratio := leg.Quantity / targets[i]; ratio < fillRatio*(1-partialFillTolerance) {
+			log.Printf("  ⚠️ %s 部分成交 %.4f/%.4f (%.1f%%)，各腿按该比例调整", legPositionSymbol(*leg), leg.Quantity, targets[i], ratio*100)
+			fillRatio = ratio
+			if err := at.rebalancePairLegs(opened, targets, fillRatio, actionRecord); err != nil {
+				if rollbackErr := at.rollbackPairLegs(opened); rollbackErr != nil {
+					return fmt.Errorf("组合 %s 部分成交后调整失败: %v；回滚已成交的腿失败，请人工处理: %w", d.Symbol, err, rollbackErr)
+				}
+				return fmt.Errorf("组合 %s 部分成交后调整失败，已回滚 %d 条已成交的腿: %w", d.Symbol, len(opened), err)
+			}
+		}
+	}
+
+	// 部分成交调整后任一腿低于最小金额时平掉所有腿（过小的组合无法有效对冲）
+	for i, leg := range legs {
+		actionRecord.Legs[fillBase+i].Quantity = leg.Quantity
+		if notional := leg.Quantity * leg.EntryPrice; notional < minLegSizeUSD {
+			err := fmt.Errorf("%s 腿部分成交后金额过小: $%.2f < 最小要求 $%.2f", legPositionSymbol(leg), notional, minLegSizeUSD)
+			if rollbackErr := at.rollbackPairLegs(legs); rollbackErr != nil {
+				return fmt.Errorf("组合 %s 部分成交: %v；平掉已成交的腿失败，请人工处理: %w", d.Symbol, err, rollbackErr)
+			}
+			return fmt.Errorf("组合 %s 部分成交，已平掉所有腿: %w", d.Symbol, err)
+		}
+	}
+
+	group, err := at.positionGroups.Add(database.PositionGroup{
+		Legs:          legs,
+		Leverage:      int(d.Leverage),
+		StopLossPct:   d.StopLossPct,
+		TakeProfitPct: d.TakeProfitPct,
+		CreatedAt:     at.now(),
+	})
+	if err != nil {
+		log.Printf("  ⚠️ 保存组合持仓失败（重启后无法恢复组合止损）: %v", err)
+	}
+	actionRecord.GroupID = group.ID
+
+	for _, leg := range legs {
+		if leg.Market != decision.LegMarketSpot {
+			at.positionFirstSeenTime[leg.Symbol+"_"+leg.Side] = at.now().UnixMilli()
+		}
+	}
+
+	log.Printf("  ✓ 组合开仓成功: %s (%s), 组合止损 %.2f%%, 组合止盈 %.2f%%", group.ID, d.Symbol, d.StopLossPct, d.TakeProfitPct)
+	return nil
+}
+
+// rebalancePairLegs 部分成交后把已成交的腿减仓到目标数量×成交比例，保持各腿的对冲比例
+func (at *AutoTrader) rebalancePairLegs(legs []database.PositionGroupLeg, targets []float64, ratio float64, actionRecord *logger.DecisionAction) error {
+	for i := range legs {
+		leg := &legs[i]
+		excess := leg.Quantity - targets[i]*ratio
+		if excess <= leg.Quantity*partialFillTolerance {
+			continue
+		}
+		order, err := at.closeLeg(*leg, excess)
+		if err != nil {
+			return fmt.Errorf("%s 减仓失败: %w", legPositionSymbol(*leg), err)
+		}
+		reduced := excess
+		if order != nil {
+			actionRecord.Fee += order.Fee
+			if order.FilledQty > 0 {
+				reduced = math.Min(order.FilledQty, leg.Quantity)
+			}
+		}
+		leg.Quantity -= reduced
+		log.Printf("    ↘️ %s %s 减仓 %.4f，剩余 %.4f", legPositionSymbol(*leg), leg.Side, reduced, leg.Quantity)
+	}
+	return nil
+}
+
+// rollbackPairLegs 组合开仓失败时按成交数量平掉已成交的腿（逆序）
+func (at *AutoTrader) rollbackPairLegs(legs []database.PositionGroupLeg) error {
+	var failed []string
+	for i := len(legs) - 1; i >= 0; i-- {
+		leg := legs[i]
+		log.Printf("  ↩️ 回滚组合腿: %s %s %.4f", legPositionSymbol(leg), leg.Side, leg.Quantity)
+		if _, err := at.closeLeg(leg, leg.Quantity); err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s: %v", legPositionSymbol(leg), leg.Side, err))
+			continue
+		}
+		at.markLegClosing(leg, "pair_rollback")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// legPrice 腿的开仓参考价（现货腿使用现货价格）
+func (at *AutoTrader) legPrice(leg decision.DecisionLeg) (float64, error) {
+	if leg.IsSpot() {
+		price, err := at.trader.(SpotTrader).GetSpotPrice(leg.Symbol)
+		if err != nil {
+			return 0, fmt.Errorf("获取 %s 现货价格失败: %w", leg.Symbol, err)
+		}
+		return price, nil
+	}
+	marketData, err := at.getMarketData(leg.Symbol)
+	if err != nil {
+		return 0, fmt.Errorf("获取 %s 行情失败: %w", leg.Symbol, err)
+	}
+	return marketData.CurrentPrice, nil
+}
+
+// pairCapital 组合占用的资金：合约腿按杠杆计算保证金，现货腿全额占用
+func pairCapital(legs []decision.DecisionLeg, totalUSD, leverage float64) float64 {
+	if leverage <= 0 {
+		leverage = 1
+	}
+	var capital float64
+	for i, notional := range decision.LegNotionals(legs, totalUSD) {
+		if legs[i].IsSpot() {
+			capital += notional
+		} else {
+			capital += notional / leverage
+		}
+	}
+	return capital
+}
+
+// openLeg 市价开一条腿，返回成交汇总（FilledQty/AvgPrice/Fee）
+func (at *AutoTrader) openLeg(leg database.PositionGroupLeg, leverage int) (*Order, error) {
+	if leg.Market == decision.LegMarketSpot {
+		result, err := at.trader.(SpotTrader).SpotBuy(leg.Symbol, leg.Quantity)
+		return spotLegOrder(leg, "spot_buy", result), err
+	}
+
+	if err := at.trader.SetMarginMode(leg.Symbol, at.config.IsCrossMargin); err != nil {
+		log.Printf("  ⚠️ 设置 %s 仓位模式失败: %v", leg.Symbol, err)
+	}
+	exec, err := at.orderManager.Open(leg.Symbol, strings.ToUpper(leg.Side), leg.Quantity, leverage, ExecutionConfig{Mode: decision.ExecutionMarket})
+	if exec == nil {
+		return nil, err
+	}
+	order := &Order{Symbol: leg.Symbol, FilledQty: exec.FilledQty, AvgPrice: exec.AvgPrice, Fee: exec.Fee}
+	if last := exec.LastOrder(); last != nil {
+		order.ClientOrderID = last.ClientOrderID
+	}
+	return order, err
+}
+
+// closeLeg 平掉一条腿（quantity=0表示全部平仓，现货腿必须指定数量，避免卖出不属于组合的现货）
+func (at *AutoTrader) closeLeg(leg database.PositionGroupLeg, quantity float64) (*Order, error) {
+	if leg.Market == decision.LegMarketSpot {
+		spot, ok := at.trader.(SpotTrader)
+		if !ok {
+			return nil, fmt.Errorf("当前交易所不支持现货腿")
+		}
+		if quantity <= 0 {
+			quantity = leg.Quantity
+		}
+		result, err := spot.SpotSell(leg.Symbol, quantity)
+		return spotLegOrder(leg, "spot_sell", result), err
+	}
+	if leg.Side == "short" {
+		return at.orderManager.CloseShort(leg.Symbol, quantity)
+	}
+	return at.orderManager.CloseLong(leg.Symbol, quantity)
+}
+
+// spotLegOrder 现货腿下单结果（现货订单不经过订单管理器）
+func spotLegOrder(leg database.PositionGroupLeg, action string, result *OrderResult) *Order {
+	if result == nil {
+		return nil
+	}
+	return &Order{
+		ExchangeOrderID: result.OrderID,
+		Symbol:          leg.Symbol,
+		Action:          action,
+		PositionSide:    "LONG",
+		Type:            OrderTypeMarket,
+		FilledQty:       result.ExecutedQty,
+		AvgPrice:        result.AvgPrice,
+		Fee:             result.Fee,
+		Status:          result.Status,
+	}
+}
+
+// markLegClosing 标记合约腿正在平仓（现货腿不属于合约持仓生命周期）
+func (at *AutoTrader) markLegClosing(leg database.PositionGroupLeg, reason string) {
+	if leg.Market != decision.LegMarketSpot {
+		at.lifecycle.MarkClosing(leg.Symbol, leg.Side, reason)
+	}
+}
+
+// executeClosePairWithRecord 执行组合平仓：平掉组合的所有腿
+func (at *AutoTrader) executeClosePairWithRecord(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	group, ok := at.positionGroups.Get(d.GroupID)
+	if !ok {
+		return fmt.Errorf("组合持仓不存在或已平仓: %s", d.GroupID)
+	}
+	actionRecord.GroupID = group.ID
+	actionRecord.Symbol = decision.PairLabel(groupDecisionLegs(group))
+	log.Printf("  🔗 平组合仓: %s (%s)", group.ID, actionRecord.Symbol)
+
+	positions, err := at.groupPositions()
+	if err != nil {
+		return err
+	}
+	pnl, err := at.closePositionGroup(group, GroupCloseDecision, positions, actionRecord)
+	if err != nil {
+		return err
+	}
+	log.Printf("  ✓ 组合平仓成功，合计盈亏 %.2f USDT", pnl)
+	return nil
+}
+
+// closePositionGroup 平掉组合仍存在的腿，全部成功后按成交价计算合计盈亏并关闭组合
+// 已不存在的腿（止损/强平保护/手动平仓）的盈亏由持仓生命周期记录，不计入组合盈亏
+// 部分腿平仓失败时组合保持打开，下一周期按腿缺失继续平仓
+func (at *AutoTrader) closePositionGroup(g database.PositionGroup, reason string, positions []Position, actionRecord *logger.DecisionAction) (float64, error) {
+	var realized float64
+	var failed []string
+	for _, leg := range g.Legs {
+		pos, ok := FindPosition(positions, legPositionSymbol(leg), leg.Side)
+		if !ok {
+			continue
+		}
+		// 合约腿全部平仓；现货腿只卖出组合买入的数量
+		quantity := 0.0
+		if leg.Market == decision.LegMarketSpot {
+			quantity = math.Min(leg.Quantity, pos.Quantity)
+		}
+		fill := logger.LegFill{Symbol: leg.Symbol, Side: leg.Side, Market: leg.Market, Quantity: pos.Quantity, Price: pos.MarkPrice}
+		if quantity > 0 {
+			fill.Quantity = quantity
+		}
+		order, err := at.closeLeg(leg, quantity)
+		if err != nil {
+			fill.Error = err.Error()
+			actionRecord.Legs = append(actionRecord.Legs, fill)
+			failed = append(failed, fmt.Sprintf("%s %s: %v", legPositionSymbol(leg), leg.Side, err))
+			continue
+		}
+		at.markLegClosing(leg, "group_"+reason)
+
+		if order != nil {
+			fill.ClientOrderID = order.ClientOrderID
+			if order.AvgPrice > 0 {
+				fill.Price = order.AvgPrice
+			}
+			actionRecord.Fee += order.Fee
+		}
+		realized += legPnL(leg, fill.Price, math.Min(leg.Quantity, pos.Quantity))
+		actionRecord.Legs = append(actionRecord.Legs, fill)
+		if leg.Market != decision.LegMarketSpot {
+			at.positionFirstSeenTime[leg.Symbol+"|close_"+leg.Side] = at.now().UnixMilli()
+		}
+	}
+	if len(failed) > 0 {
+		return realized, fmt.Errorf("组合 %s 部分腿平仓失败: %s", g.ID, strings.Join(failed, "; "))
+	}
+
+	if err := at.positionGroups.Close(g.ID, realized, reason); err != nil {
+		log.Printf("  ⚠️ 更新组合持仓状态失败: %v", err)
+	}
+	return realized, nil
+}
+
+// notifyPairClosed 组合平仓成功后按各腿通知风控闸门
+func (at *AutoTrader) notifyPairClosed(actionRecord *logger.DecisionAction, ctx *decision.Context) {
+	for _, leg := range actionRecord.Legs {
+		if leg.Error != "" || leg.Market == decision.LegMarketSpot {
+			continue
+		}
+		at.notifyRiskTradeClosed(&decision.Decision{Symbol: leg.Symbol, Action: "close_" + leg.Side}, ctx)
+	}
+}
+
+// checkPositionGroups 按合计盈亏检查组合止损止盈，某条腿已不存在时平掉其余腿
+func (at *AutoTrader) checkPositionGroups(ctx *decision.Context, record *logger.DecisionRecord) {
+	groups := at.positionGroups.Groups()
+	if len(groups) == 0 {
+		return
+	}
+	positions, err := at.groupPositions()
+	if err != nil {
+		log.Printf("⚠️ 检查组合持仓失败: %v", err)
+		return
+	}
+
+	for _, g := range groups {
+		status := EvaluateGroup(g, positions)
+		reason := status.ExitReason()
+		if reason == "" {
+			continue
+		}
+
+		label := decision.PairLabel(groupDecisionLegs(g))
+		log.Printf("🔗 组合 %s (%s) 触发平仓 [%s]: 合计盈亏 %.2f USDT (%.2f%%), 缺失腿 %v",
+			g.ID, label, reason, status.UnrealizedPnL, status.PnLPct, status.MissingLegs)
+		actionRecord := logger.DecisionAction{
+			Action:    decision.ActionClosePair,
+			Symbol:    label,
+			GroupID:   g.ID,
+			Leverage:  float64(g.Leverage),
+			Timestamp: at.now(),
+		}
+		pnl, err := at.closePositionGroup(g, reason, positions, &actionRecord)
+		if err != nil {
+			actionRecord.Error = err.Error()
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ 组合 %s 平仓失败 [%s]: %v", label, reason, err))
+		} else {
+			actionRecord.Success = true
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🔗 组合 %s 平仓 [%s]，合计盈亏 %.2f USDT", label, reason, pnl))
+			at.notifyPairClosed(&actionRecord, ctx)
+		}
+		record.Decisions = append(record.Decisions, actionRecord)
+	}
+}
@@ -0,0 +1,288 @@
+package trader
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+)
+
+// failingShortTrader 开空仓失败的交易器（模拟组合第二条腿下单失败）
+type failingShortTrader struct {
+	*SimulatedTrader
+}
+
+func (f *failingShortTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return nil, errors.New("余额不足")
+}
+
+// partialShortTrader 开空仓只成交部分数量的交易器（模拟组合第二条腿部分成交）
+type partialShortTrader struct {
+	*SimulatedTrader
+	ratio float64
+}
+
+func (p *partialShortTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return p.SimulatedTrader.OpenShort(symbol, quantity*p.ratio, leverage)
+}
+
+// positionsErrorTrader 查询持仓失败的交易器
+type positionsErrorTrader struct {
+	*SimulatedTrader
+}
+
+func (p *positionsErrorTrader) GetPositions() ([]Position, error) {
+	return nil, errors.New("网络超时")
+}
+
+// newPairTestTrader 带组合持仓管理的测试交易器（ETH=100, BTC=1000）
+func newPairTestTrader(t Trader, sim *SimulatedTrader) *AutoTrader {
+	sim.SetPrice("ETHUSDT", 100)
+	sim.SetPrice("BTCUSDT", 1000)
+	return &AutoTrader{
+		name:                  "test",
+		trader:                t,
+		orderManager:          NewOrderManager("t1", "sim", t, nil),
+		positionGroups:        NewPositionGroupManager("t1", nil),
+		positionFirstSeenTime: make(map[string]int64),
+		marketDataProvider: func(symbol string) (*market.Data, error) {
+			price, _ := sim.GetMarketPrice(symbol)
+			return &market.Data{Symbol: symbol, CurrentPrice: price}, nil
+		},
+	}
+}
+
+func testPairDecision() *decision.Decision {
+	return &decision.Decision{
+		Symbol:          "ETHUSDT/BTCUSDT",
+		Action:          decision.ActionOpenPair,
+		Legs:            []decision.DecisionLeg{{Symbol: "ETHUSDT", Side: "long", Ratio: 1}, {Symbol: "BTCUSDT", Side: "short", Ratio: 1}},
+		Leverage:        5,
+		PositionSizeUSD: 1000,
+		StopLossPct:     2,
+		TakeProfitPct:   4,
+	}
+}
+
+func TestAutoTraderOpenPairAndGroupStop(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(sim, sim)
+
+	action := &logger.DecisionAction{}
+	if err := at.executeOpenPairWithRecord(testPairDecision(), action); err != nil {
+		t.Fatalf("组合开仓失败: %v", err)
+	}
+	if action.GroupID == "" || len(action.Legs) != 2 {
+		t.Fatalf("应记录组合ID和各腿成交: %+v", action)
+	}
+	positions, _ := sim.GetPositions()
+	eth, okEth := FindPosition(positions, "ETHUSDT", "long")
+	btc, okBtc := FindPosition(positions, "BTCUSDT", "short")
+	if !okEth || !okBtc || !approxEqual(eth.Quantity, 5) || !approxEqual(btc.Quantity, 0.5) {
+		t.Fatalf("两条腿应按权重开仓: %+v", positions)
+	}
+	if !at.positionGroups.Contains("ETHUSDT", "LONG") || at.positionGroups.Contains("ETHUSDT", "short") {
+		t.Error("组合的腿应被识别")
+	}
+
+	// 两条腿同涨：对冲后合计盈亏接近0，不触发组合止损
+	sim.SetPrice("ETHUSDT", 105)
+	sim.SetPrice("BTCUSDT", 1050)
+	positions, _ = sim.GetPositions()
+	if status := EvaluateGroup(at.positionGroups.Groups()[0], positions); status.ExitReason() != "" {
+		t.Fatalf("对冲后不应触发组合止损: %+v", status)
+	}
+
+	// 价差向不利方向移动 -3%，触发组合止损并平掉所有腿
+	sim.SetPrice("ETHUSDT", 97)
+	sim.SetPrice("BTCUSDT", 1030)
+	record := &logger.DecisionRecord{}
+	at.checkPositionGroups(&decision.Context{}, record)
+	if len(record.Decisions) != 1 || !record.Decisions[0].Success || record.Decisions[0].Action != decision.ActionClosePair {
+		t.Fatalf("应记录组合止损平仓: %+v", record.Decisions)
+	}
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Errorf("组合止损应平掉所有腿: %+v", positions)
+	}
+	if len(at.positionGroups.Groups()) != 0 {
+		t.Error("组合平仓后应移除")
+	}
+}
+
+func TestAutoTraderOpenPairRollback(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(&failingShortTrader{sim}, sim)
+
+	action := &logger.DecisionAction{}
+	err := at.executeOpenPairWithRecord(testPairDecision(), action)
+	if err == nil {
+		t.Fatal("第二条腿失败时应返回错误")
+	}
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Errorf("已成交的腿应被回滚: %+v", positions)
+	}
+	if len(action.Legs) != 2 || action.Legs[1].Error == "" {
+		t.Errorf("应记录失败的腿: %+v", action.Legs)
+	}
+	if len(at.positionGroups.Groups()) != 0 {
+		t.Error("开仓失败不应记录组合")
+	}
+}
+
+func TestAutoTraderOpenPairPositionsError(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(&positionsErrorTrader{sim}, sim)
+
+	if err := at.executeOpenPairWithRecord(testPairDecision(), &logger.DecisionAction{}); err == nil {
+		t.Fatal("无法获取持仓时应拒绝开组合仓")
+	}
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Errorf("不应下单: %+v", positions)
+	}
+}
+
+func TestAutoTraderOpenPairPartialFill(t *testing.T) {
+	// 第二条腿只成交40%：第一条腿减仓到同一比例，组合按实际数量记录
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(&partialShortTrader{SimulatedTrader: sim, ratio: 0.4}, sim)
+	action := &logger.DecisionAction{}
+	if err := at.executeOpenPairWithRecord(testPairDecision(), action); err != nil {
+		t.Fatalf("部分成交后应调整而不是失败: %v", err)
+	}
+	positions, _ := sim.GetPositions()
+	eth, _ := FindPosition(positions, "ETHUSDT", "long")
+	btc, _ := FindPosition(positions, "BTCUSDT", "short")
+	if !approxEqual(eth.Quantity, 2) || !approxEqual(btc.Quantity, 0.2) {
+		t.Fatalf("已成交的腿应减仓到部分成交比例: eth=%.4f btc=%.4f", eth.Quantity, btc.Quantity)
+	}
+	group := at.positionGroups.Groups()[0]
+	if !approxEqual(group.Legs[0].Quantity, 2) || !approxEqual(group.Legs[1].Quantity, 0.2) || !approxEqual(action.Legs[0].Quantity, 2) {
+		t.Errorf("组合和成交记录应使用调整后的数量: %+v %+v", group.Legs, action.Legs)
+	}
+
+	// 成交比例过低，调整后的腿低于最小金额：平掉所有腿
+	sim = newTestSimulatedTrader()
+	at = newPairTestTrader(&partialShortTrader{SimulatedTrader: sim, ratio: 0.01}, sim)
+	if err := at.executeOpenPairWithRecord(testPairDecision(), &logger.DecisionAction{}); err == nil {
+		t.Fatal("调整后腿金额过小时应返回错误")
+	}
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Errorf("应平掉所有已成交的腿: %+v", positions)
+	}
+	if len(at.positionGroups.Groups()) != 0 {
+		t.Error("平掉所有腿后不应记录组合")
+	}
+}
+
+func TestCheckPositionGroupsLegMissing(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(sim, sim)
+	if err := at.executeOpenPairWithRecord(testPairDecision(), &logger.DecisionAction{}); err != nil {
+		t.Fatalf("组合开仓失败: %v", err)
+	}
+
+	// 一条腿在交易所侧被平掉（止损/强平保护），其余腿随之平仓
+	if _, err := sim.CloseShort("BTCUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	record := &logger.DecisionRecord{}
+	at.checkPositionGroups(&decision.Context{}, record)
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Errorf("腿缺失时应平掉其余腿: %+v", positions)
+	}
+	if len(record.Decisions) != 1 || len(record.Decisions[0].Legs) != 1 {
+		t.Errorf("应只平掉仍存在的腿: %+v", record.Decisions)
+	}
+}
+
+// perpOnlyTrader 不支持现货的交易器
+type perpOnlyTrader struct {
+	Trader
+}
+
+func TestAutoTraderSpotPerpCarry(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(sim, sim)
+	carry := &decision.Decision{
+		Symbol:          "BTCUSDT(spot)/BTCUSDT",
+		Action:          decision.ActionOpenPair,
+		Legs:            []decision.DecisionLeg{{Symbol: "BTCUSDT", Side: "long", Ratio: 1, Market: decision.LegMarketSpot}, {Symbol: "BTCUSDT", Side: "short", Ratio: 1}},
+		Leverage:        5,
+		PositionSizeUSD: 1000,
+		StopLossPct:     2,
+		TakeProfitPct:   4,
+	}
+
+	// 不支持现货的交易所整个组合拒绝，不下任何单
+	unsupported := newPairTestTrader(&perpOnlyTrader{sim}, sim)
+	if err := unsupported.executeOpenPairWithRecord(carry, &logger.DecisionAction{}); err == nil {
+		t.Fatal("不支持现货时应拒绝现货腿")
+	}
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Fatalf("拒绝时不应开仓: %+v", positions)
+	}
+
+	action := &logger.DecisionAction{}
+	if err := at.executeOpenPairWithRecord(carry, action); err != nil {
+		t.Fatalf("现货-永续组合开仓失败: %v", err)
+	}
+	held, _ := sim.GetSpotBalance("BTCUSDT")
+	positions, _ := sim.GetPositions()
+	short, ok := FindPosition(positions, "BTCUSDT", "short")
+	if !approxEqual(held, 0.5) || !ok || !approxEqual(short.Quantity, 0.5) || len(positions) != 1 {
+		t.Fatalf("现货腿和永续腿应各开0.5: spot=%.4f positions=%+v", held, positions)
+	}
+	if action.Legs[0].Market != decision.LegMarketSpot {
+		t.Errorf("成交记录应标记现货腿: %+v", action.Legs)
+	}
+	if at.positionGroups.Contains("BTCUSDT", "long") || !at.positionGroups.Contains("BTCUSDT", "short") {
+		t.Error("现货腿不应被识别为合约持仓")
+	}
+
+	// 价格上涨10%：现货和空头对冲，不触发组合止损
+	sim.SetPrice("BTCUSDT", 1100)
+	record := &logger.DecisionRecord{}
+	at.checkPositionGroups(&decision.Context{}, record)
+	if len(record.Decisions) != 0 {
+		t.Fatalf("对冲组合不应触发平仓: %+v", record.Decisions)
+	}
+	positions, _ = sim.GetPositions()
+	if infos := at.positionGroupInfos(positions); len(infos) != 1 || math.Abs(infos[0].UnrealizedPnL) > 1e-6 {
+		t.Errorf("组合合计盈亏应包含现货腿: %+v", infos)
+	}
+
+	// 按组合平仓：卖出现货并平掉空头
+	if err := at.executeClosePairWithRecord(&decision.Decision{Action: decision.ActionClosePair, GroupID: action.GroupID}, &logger.DecisionAction{}); err != nil {
+		t.Fatalf("组合平仓失败: %v", err)
+	}
+	held, _ = sim.GetSpotBalance("BTCUSDT")
+	if positions, _ := sim.GetPositions(); len(positions) != 0 || held != 0 {
+		t.Errorf("组合平仓应卖出现货并平掉空头: spot=%.4f positions=%+v", held, positions)
+	}
+}
+
+func TestAutoTraderSpotLegMissing(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	at := newPairTestTrader(sim, sim)
+	carry := testPairDecision()
+	carry.Legs = []decision.DecisionLeg{{Symbol: "ETHUSDT", Side: "long", Ratio: 1, Market: decision.LegMarketSpot}, {Symbol: "ETHUSDT", Side: "short", Ratio: 1}}
+	if err := at.executeOpenPairWithRecord(carry, &logger.DecisionAction{}); err != nil {
+		t.Fatalf("组合开仓失败: %v", err)
+	}
+
+	// 现货在组合外被卖出，平掉永续腿
+	if _, err := sim.SpotSell("ETHUSDT", 0); err != nil {
+		t.Fatalf("卖出现货失败: %v", err)
+	}
+	record := &logger.DecisionRecord{}
+	at.checkPositionGroups(&decision.Context{}, record)
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Errorf("现货腿缺失时应平掉永续腿: %+v", positions)
+	}
+	if len(record.Decisions) != 1 || len(record.Decisions[0].Legs) != 1 {
+		t.Errorf("应只平掉仍存在的腿: %+v", record.Decisions)
+	}
+}
@@ -10,6 +10,7 @@ import (
         "log"
         "math"
         "net/http"
+        "sort"
         "strconv"
         "strings"
         "sync"
@@ -26,11 +27,11 @@ type OKXTrader struct {
         client       *http.Client
 
         // 缓存机制（遵循现有模式）
-        cachedBalance     map[string]interface{}
+        cachedBalance     *Balance
         balanceCacheTime  time.Time
         balanceCacheMutex sync.RWMutex
 
-        cachedPositions     []map[string]interface{}
+        cachedPositions     []Position
         positionsCacheTime  time.Time
         positionsCacheMutex sync.RWMutex
 
@@ -73,7 +74,7 @@ func NewOKXTrader(apiKey, secretKey, passphrase string, testnet bool) (*OKXTrade
 }
 
 // GetBalance 获取账户余额（带缓存）
-func (t *OKXTrader) GetBalance() (map[string]interface{}, error) {
+func (t *OKXTrader) GetBalance() (*Balance, error) {
         // 先检查缓存是否有效
         t.balanceCacheMutex.RLock()
         if t.cachedBalance != nil && time.Since(t.balanceCacheTime) < t.cacheDuration {
@@ -100,40 +101,47 @@ func (t *OKXTrader) GetBalance() (map[string]interface{}, error) {
         t.balanceCacheTime = time.Now()
         t.balanceCacheMutex.Unlock()
 
-        log.Printf("✅ OKX余额获取成功: total=%v, used=%v, free=%v",
-                balance["total"], balance["used"], balance["free"])
+        log.Printf("✅ OKX余额获取成功: 净值=%.2f, 已用=%.2f, 可用=%.2f",
+                balance.TotalEquity(), balance.UsedMargin, balance.AvailableBalance)
 
         return balance, nil
 }
 
 // parseBalance 解析OKX余额响应
-func (t *OKXTrader) parseBalance(resp map[string]interface{}) map[string]interface{} {
-        result := map[string]interface{}{
-                "total": float64(0),
-                "used":  float64(0),
-                "free":  float64(0),
+// totalEq为含未实现盈亏的账户净值，可用余额优先取USDT明细的availEq，缺失时使用adjEq
+func (t *OKXTrader) parseBalance(resp map[string]interface{}) *Balance {
+        result := &Balance{}
+
+        data, ok := resp["data"].([]interface{})
+        if !ok || len(data) == 0 {
+                return result
+        }
+        balance, ok := data[0].(map[string]interface{})
+        if !ok {
+                return result
         }
 
-        if data, ok := resp["data"].([]interface{}); ok && len(data) > 0 {
-                if balance, ok := data[0].(map[string]interface{}); ok {
-                        // 总资产
-                        if totalEq, ok := balance["totalEq"].(string); ok {
-                                if total, err := strconv.ParseFloat(totalEq, 64); err == nil {
-                                        result["total"] = total
-                                }
-                        }
-                        // 已用资产（isoEq）
-                        if isoEq, ok := balance["isoEq"].(string); ok {
-                                if used, err := strconv.ParseFloat(isoEq, 64); err == nil {
-                                        result["used"] = used
-                                }
+        totalEq := parseOKXFloat(getStringValue(balance, "totalEq"))
+        result.TotalUnrealizedProfit = parseOKXFloat(getStringValue(balance, "upl"))
+        result.TotalWalletBalance = totalEq - result.TotalUnrealizedProfit
+        result.AvailableBalance = parseOKXFloat(getStringValue(balance, "adjEq"))
+
+        // 已用保证金（imr为占用保证金，缺失时使用逐仓权益isoEq）
+        result.UsedMargin = parseOKXFloat(getStringValue(balance, "imr"))
+        if result.UsedMargin == 0 {
+                result.UsedMargin = parseOKXFloat(getStringValue(balance, "isoEq"))
+        }
+
+        if details, ok := balance["details"].([]interface{}); ok {
+                for _, item := range details {
+                        detail, ok := item.(map[string]interface{})
+                        if !ok || detail["ccy"] != "USDT" {
+                                continue
                         }
-                        // 可用资产（adjEq）
-                        if adjEq, ok := balance["adjEq"].(string); ok {
-                                if free, err := strconv.ParseFloat(adjEq, 64); err == nil {
-                                        result["free"] = free
-                                }
+                        if availEq := parseOKXFloat(getStringValue(detail, "availEq")); availEq > 0 {
+                                result.AvailableBalance = availEq
                         }
+                        break
                 }
         }
 
@@ -141,7 +149,7 @@ func (t *OKXTrader) parseBalance(resp map[string]interface{}) map[string]interfa
 }
 
 // GetPositions 获取所有持仓
-func (t *OKXTrader) GetPositions() ([]map[string]interface{}, error) {
+func (t *OKXTrader) GetPositions() ([]Position, error) {
         // 检查缓存
         t.positionsCacheMutex.RLock()
         if t.cachedPositions != nil && time.Since(t.positionsCacheTime) < t.cacheDuration {
@@ -173,56 +181,46 @@ func (t *OKXTrader) GetPositions() ([]map[string]interface{}, error) {
 }
 
 // parsePositions 解析OKX持仓响应
-func (t *OKXTrader) parsePositions(resp map[string]interface{}) []map[string]interface{} {
-        var positions []map[string]interface{}
-
-        if data, ok := resp["data"].([]interface{}); ok {
-                for _, item := range data {
-                        if pos, ok := item.(map[string]interface{}); ok {
-                                // 辅助函数：安全解析float字符串
-                                parseFloat := func(key string) float64 {
-                                        if valStr, ok := pos[key].(string); ok && valStr != "" {
-                                                if val, err := strconv.ParseFloat(valStr, 64); err == nil {
-                                                        return val
-                                                }
-                                        }
-                                        return 0.0
-                                }
+// OKX的pos字段是合约张数，按合约面值(ctVal)换算为币数量
+func (t *OKXTrader) parsePositions(resp map[string]interface{}) []Position {
+        var positions []Position
 
-                                // 解析关键数值字段
-                                markPrice := parseFloat("markPx")
-                                entryPrice := parseFloat("avgPx")
-                                quantity := parseFloat("pos")
-                                upl := parseFloat("upl")
-                                liqPx := parseFloat("liqPx")
-                                leverage := parseFloat("lever")
-
-                                // 将OKX格式的symbol (如 BTC-USDT-SWAP) 转换为内部格式 (如 BTCUSDT)
-                                // 这是关键修复：确保返回的symbol格式与market.Get()期望的格式一致
-                                okxInstId, _ := pos["instId"].(string)
-                                internalSymbol := convertFromOKXSymbol(okxInstId)
-
-                                // 标准化持仓数据格式 (适配 AutoTrader 要求)
-                                standardizedPos := map[string]interface{}{
-                                        // 核心字段 (AutoTrader 必需)
-                                        "symbol":           internalSymbol, // 使用内部格式 (BTCUSDT) 而非OKX格式 (BTC-USDT-SWAP)
-                                        "side":             pos["posSide"], // AutoTrader期望 key="side"
-                                        "markPrice":        markPrice,      // AutoTrader期望 key="markPrice" (float64)
-                                        "entryPrice":       entryPrice,     // AutoTrader期望 key="entryPrice" (float64)
-                                        "positionAmt":      quantity,       // AutoTrader期望 key="positionAmt" (float64)
-                                        "unRealizedProfit": upl,            // AutoTrader期望 key="unRealizedProfit" (float64)
-                                        "leverage":         leverage,       // AutoTrader期望 key="leverage" (float64)
-                                        "liquidationPrice": liqPx,          // AutoTrader期望 key="liquidationPrice" (float64)
-
-                                        // 兼容性/原始字段
-                                        "posSide":    pos["posSide"],
-                                        "marginMode": pos["mgnMode"],
-                                        "uplRatio":   pos["uplRatio"],
-                                        "okxInstId":  okxInstId, // 保留原始OKX格式供调试用
-                                }
-                                positions = append(positions, standardizedPos)
+        data, ok := resp["data"].([]interface{})
+        if !ok {
+                return positions
+        }
+        for _, item := range data {
+                pos, ok := item.(map[string]interface{})
+                if !ok {
+                        continue
+                }
+
+                contracts := parseOKXFloat(getStringValue(pos, "pos"))
+                if contracts == 0 {
+                        continue
+                }
+
+                // 将OKX格式的symbol (如 BTC-USDT-SWAP) 转换为内部格式 (如 BTCUSDT)
+                okxInstId, _ := pos["instId"].(string)
+                side, _ := pos["posSide"].(string)
+                if side != "long" && side != "short" {
+                        // 单向持仓模式(net)下通过持仓数量的正负判断方向
+                        side = "long"
+                        if contracts < 0 {
+                                side = "short"
                         }
                 }
+
+                positions = append(positions, Position{
+                        Symbol:           convertFromOKXSymbol(okxInstId),
+                        Side:             side,
+                        Quantity:         math.Abs(contracts) * t.getContractValue(okxInstId),
+                        EntryPrice:       parseOKXFloat(getStringValue(pos, "avgPx")),
+                        MarkPrice:        parseOKXFloat(getStringValue(pos, "markPx")),
+                        UnrealizedProfit: parseOKXFloat(getStringValue(pos, "upl")),
+                        Leverage:         int(parseOKXFloat(getStringValue(pos, "lever"))),
+                        LiquidationPrice: parseOKXFloat(getStringValue(pos, "liqPx")),
+                })
         }
 
         return positions
@@ -230,9 +228,10 @@ func (t *OKXTrader) parsePositions(resp map[string]interface{}) []map[string]int
 
 // ContractSpec 合约规格
 type ContractSpec struct {
-        CtVal float64 // 合约面值（1张合约对应多少币）
-        MinSz float64 // 最小下单张数
-        LotSz float64 // 下单精度（必须是lotSz的整数倍）
+        CtVal  float64 // 合约面值（1张合约对应多少币）
+        MinSz  float64 // 最小下单张数
+        LotSz  float64 // 下单精度（必须是lotSz的整数倍）
+        TickSz float64 // 价格精度（限价单价格必须是tickSz的整数倍，0表示未知）
 }
 
 // getContractSpec 获取合约规格(ctVal, minSz, lotSz)
@@ -275,6 +274,11 @@ func (t *OKXTrader) getContractSpec(instId string) (*ContractSpec, error) {
                                         spec.LotSz = v
                                 }
                         }
+                        if tickSzStr, ok := inst["tickSz"].(string); ok {
+                                if v, err := strconv.ParseFloat(tickSzStr, 64); err == nil {
+                                        spec.TickSz = v
+                                }
+                        }
 
                         log.Printf("📋 合约规格 %s: ctVal=%.6f, minSz=%.4f, lotSz=%.4f", instId, spec.CtVal, spec.MinSz, spec.LotSz)
                         return spec, nil
@@ -333,7 +337,7 @@ func (t *OKXTrader) convertToContractSize(instId string, coinAmount float64) (st
         // 例如: lotSz=1 时，3.7 -> 3; lotSz=0.1 时，3.75 -> 3.7; lotSz=0.01 时，3.756 -> 3.75
         contractSize := rawContractSize
         if spec.LotSz > 0 {
-                contractSize = math.Floor(rawContractSize/spec.LotSz) * spec.LotSz
+                contractSize = math.Floor(rawContractSize/spec.LotSz+1e-9) * spec.LotSz
         }
 
         // 检查取整后是否为0或小于最小下单量
@@ -370,7 +374,12 @@ func (t *OKXTrader) convertToContractSize(instId string, coinAmount float64) (st
 }
 
 // OpenLong 开多仓
-func (t *OKXTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *OKXTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+        return t.openLong(symbol, quantity, leverage, "")
+}
+
+// openLong 开仓下单（clientOrderID非空时作为clOrdId发送）
+func (t *OKXTrader) openLong(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
         if quantity <= 0 {
                 return nil, fmt.Errorf("开仓数量必须大于0")
         }
@@ -404,11 +413,16 @@ func (t *OKXTrader) OpenLong(symbol string, quantity float64, leverage int) (map
                 "sz":      contractSize, // 合约张数（不是币数量）
         }
 
-        return t.placeOrder(order)
+        return t.placeOrder(order, clientOrderID)
 }
 
 // OpenShort 开空仓
-func (t *OKXTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+func (t *OKXTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+        return t.openShort(symbol, quantity, leverage, "")
+}
+
+// openShort 开仓下单（clientOrderID非空时作为clOrdId发送）
+func (t *OKXTrader) openShort(symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
         if quantity <= 0 {
                 return nil, fmt.Errorf("开仓数量必须大于0")
         }
@@ -442,11 +456,16 @@ func (t *OKXTrader) OpenShort(symbol string, quantity float64, leverage int) (ma
                 "sz":      contractSize, // 合约张数（不是币数量）
         }
 
-        return t.placeOrder(order)
+        return t.placeOrder(order, clientOrderID)
 }
 
 // CloseLong 平多仓
-func (t *OKXTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *OKXTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+        return t.closeLong(symbol, quantity, "")
+}
+
+// closeLong 平仓下单（clientOrderID非空时作为clOrdId发送）
+func (t *OKXTrader) closeLong(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
         // 转换交易对格式
         okxSymbol := convertToOKXSymbol(symbol)
         log.Printf("📊 OKX平多: 原始交易对=%s, OKX格式=%s", symbol, okxSymbol)
@@ -459,15 +478,8 @@ func (t *OKXTrader) CloseLong(symbol string, quantity float64) (map[string]inter
         }
 
         var positionSize float64
-        for _, pos := range positions {
-                posSymbol := pos["symbol"].(string)
-                // 比较时也需要转换格式
-                if (posSymbol == okxSymbol || convertToOKXSymbol(posSymbol) == okxSymbol) && pos["posSide"] == "long" {
-                        if size, ok := pos["positionAmt"].(float64); ok {
-                                positionSize = size
-                                break
-                        }
-                }
+        if pos, ok := FindPosition(positions, convertFromOKXSymbol(okxSymbol), "long"); ok {
+                positionSize = pos.Quantity
         }
 
         if positionSize <= 0 {
@@ -484,20 +496,31 @@ func (t *OKXTrader) CloseLong(symbol string, quantity float64) (map[string]inter
                 quantity = positionSize
         }
 
+        // 持仓数量为币数量，下单前换算为合约张数
+        contractSize, err := t.convertToContractSize(okxSymbol, quantity)
+        if err != nil {
+                return nil, fmt.Errorf("转换合约张数失败: %w", err)
+        }
+
         order := map[string]string{
                 "instId":  okxSymbol,
                 "tdMode":  "cross",
                 "side":    "sell", // 卖出平仓
                 "posSide": "long", // 仓位方向：平多仓 - OKX多空模式必须
                 "ordType": "market",
-                "sz":      strconv.FormatFloat(quantity, 'f', -1, 64),
+                "sz":      contractSize, // 合约张数（不是币数量）
         }
 
-        return t.placeOrder(order)
+        return t.placeOrder(order, clientOrderID)
 }
 
 // CloseShort 平空仓
-func (t *OKXTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+func (t *OKXTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+        return t.closeShort(symbol, quantity, "")
+}
+
+// closeShort 平仓下单（clientOrderID非空时作为clOrdId发送）
+func (t *OKXTrader) closeShort(symbol string, quantity float64, clientOrderID string) (*OrderResult, error) {
         // 转换交易对格式
         okxSymbol := convertToOKXSymbol(symbol)
         log.Printf("📊 OKX平空: 原始交易对=%s, OKX格式=%s", symbol, okxSymbol)
@@ -508,15 +531,8 @@ func (t *OKXTrader) CloseShort(symbol string, quantity float64) (map[string]inte
         }
 
         var positionSize float64
-        for _, pos := range positions {
-                posSymbol := pos["symbol"].(string)
-                // 比较时也需要转换格式
-                if (posSymbol == okxSymbol || convertToOKXSymbol(posSymbol) == okxSymbol) && pos["posSide"] == "short" {
-                        if size, ok := pos["positionAmt"].(float64); ok {
-                                positionSize = size
-                                break
-                        }
-                }
+        if pos, ok := FindPosition(positions, convertFromOKXSymbol(okxSymbol), "short"); ok {
+                positionSize = pos.Quantity
         }
 
         if positionSize <= 0 {
@@ -531,20 +547,44 @@ func (t *OKXTrader) CloseShort(symbol string, quantity float64) (map[string]inte
                 quantity = positionSize
         }
 
+        contractSize, err := t.convertToContractSize(okxSymbol, quantity)
+        if err != nil {
+                return nil, fmt.Errorf("转换合约张数失败: %w", err)
+        }
+
         order := map[string]string{
                 "instId":  okxSymbol,
                 "tdMode":  "cross",
                 "side":    "buy",   // 买入平仓
                 "posSide": "short", // 仓位方向：平空仓 - OKX多空模式必须
                 "ordType": "market",
-                "sz":      strconv.FormatFloat(quantity, 'f', -1, 64),
+                "sz":      contractSize, // 合约张数（不是币数量）
         }
 
-        return t.placeOrder(order)
+        return t.placeOrder(order, clientOrderID)
+}
+
+// PlaceMarketOrder 按动作下市价单并携带本地订单ID（实现ClientOrderPlacer）
+func (t *OKXTrader) PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+        switch action {
+        case "open_long":
+                return t.openLong(symbol, quantity, leverage, clientOrderID)
+        case "open_short":
+                return t.openShort(symbol, quantity, leverage, clientOrderID)
+        case "close_long":
+                return t.closeLong(symbol, quantity, clientOrderID)
+        case "close_short":
+                return t.closeShort(symbol, quantity, clientOrderID)
+        }
+        return nil, fmt.Errorf("未知的下单动作: %s", action)
 }
 
-// placeOrder 下单统一方法
-func (t *OKXTrader) placeOrder(order map[string]string) (map[string]interface{}, error) {
+// placeOrder 下单统一方法（clientOrderID非空时作为clOrdId发送，OKX要求字母数字且不超过32位）
+func (t *OKXTrader) placeOrder(order map[string]string, clientOrderID string) (*OrderResult, error) {
+        if clientOrderID != "" {
+                order["clOrdId"] = clientOrderID
+        }
+
         // ========== 保证金预检查 ==========
         // 只对开仓订单进行保证金检查（side=buy/sell 且 ordType=market）
         // 平仓订单不需要额外保证金
@@ -561,7 +601,7 @@ func (t *OKXTrader) placeOrder(order map[string]string) (map[string]interface{},
                 if err != nil {
                         log.Printf("⚠️ 获取余额失败，跳过保证金检查: %v", err)
                 } else {
-                        availableMargin, _ := balance["free"].(float64)
+                        availableMargin := balance.AvailableBalance
 
                         // 获取订单参数
                         instId := order["instId"]
@@ -612,6 +652,9 @@ func (t *OKXTrader) placeOrder(order map[string]string) (map[string]interface{},
                 return nil, fmt.Errorf("OKX下单失败: %w", err)
         }
 
+        // 下单接口不返回成交信息，成交状态由QueryOrder对账获取
+        result := &OrderResult{Symbol: convertFromOKXSymbol(order["instId"])}
+
         // 检查data数组中的详细错误信息
         if data, ok := resp["data"].([]interface{}); ok && len(data) > 0 {
                 if orderResp, ok := data[0].(map[string]interface{}); ok {
@@ -623,13 +666,14 @@ func (t *OKXTrader) placeOrder(order map[string]string) (map[string]interface{},
                         }
                         // 获取订单ID
                         if ordId, ok := orderResp["ordId"].(string); ok && ordId != "" {
+                                result.OrderID = ordId
                                 log.Printf("✅ OKX下单成功: ordId=%s, side=%s, symbol=%s, quantity=%s",
                                         ordId, order["side"], order["instId"], order["sz"])
                         }
                 }
         }
 
-        return resp, nil
+        return result, nil
 }
 
 // placeAlgoOrder 下单条件单/策略单统一方法 (止损/止盈)
@@ -913,55 +957,22 @@ func (t *OKXTrader) CancelAllOrders(symbol string) error {
         return nil
 }
 
-// ClosePosition 关闭指定持仓
-func (t *OKXTrader) ClosePosition(symbol string, side string) (map[string]interface{}, error) {
-        // 转换交易对格式
-        okxSymbol := convertToOKXSymbol(symbol)
-
-        // 获取当前持仓
-        positions, err := t.GetPositions()
-        if err != nil {
-                return nil, fmt.Errorf("获取持仓失败: %w", err)
-        }
-
-        // 查找匹配的持仓
-        var position map[string]interface{}
-        for _, pos := range positions {
-                posSymbol := pos["symbol"].(string)
-                if (posSymbol == okxSymbol || convertToOKXSymbol(posSymbol) == okxSymbol) && pos["side"] == side {
-                        position = pos
-                        break
-                }
-        }
-
-        if position == nil {
-                return nil, fmt.Errorf("未找到持仓: symbol=%s, side=%s", symbol, side)
-        }
-
-        quantity := position["positionAmt"].(float64)
-
-        // 根据持仓方向决定平仓方向
-        var closeSide string
+// ClosePosition 关闭指定持仓（side为long/short）
+func (t *OKXTrader) ClosePosition(symbol string, side string) (*OrderResult, error) {
+        var (
+                result *OrderResult
+                err    error
+        )
         if side == "long" {
-                closeSide = "sell" // 多头平仓需要卖出
+                result, err = t.CloseLong(symbol, 0)
         } else {
-                closeSide = "buy" // 空头平仓需要买入
+                result, err = t.CloseShort(symbol, 0)
         }
-
-        order := map[string]string{
-                "instId":  symbol,
-                "tdMode":  "cross", // 默认全仓模式
-                "side":    closeSide,
-                "ordType": "market", // 市价平仓
-                "sz":      fmt.Sprintf("%.4f", quantity),
-        }
-
-        result, err := t.placeOrder(order)
         if err != nil {
                 return nil, fmt.Errorf("平仓失败: %w", err)
         }
 
-        log.Printf("✅ OKX平仓成功: symbol=%s, side=%s, quantity=%.4f", symbol, side, quantity)
+        log.Printf("✅ OKX平仓成功: symbol=%s, side=%s", symbol, side)
         return result, nil
 }
 
@@ -1017,6 +1028,359 @@ func (t *OKXTrader) GetFills(symbol string, limit int) ([]map[string]interface{}
         return fills, nil
 }
 
+// GetFillHistory 获取since之后该币种的成交记录（实现FillHistoryProvider）
+// OKX数量单位为合约张数，这里按合约面值换算为币数量
+func (t *OKXTrader) GetFillHistory(symbol string, since time.Time) ([]Fill, error) {
+        okxSymbol := convertToOKXSymbol(symbol)
+        params := map[string]string{
+                "instType": "SWAP",
+                "instId":   okxSymbol,
+                "begin":    strconv.FormatInt(since.UnixMilli(), 10),
+                "limit":    "100",
+        }
+
+        // OKX API: GET /api/v5/trade/fills-history（近3个月）
+        resp, err := t.makeRequest("GET", "/api/v5/trade/fills-history", params)
+        if err != nil {
+                return nil, fmt.Errorf("获取OKX成交记录失败: %w", err)
+        }
+
+        data, _ := resp["data"].([]interface{})
+        ctVal := t.getContractValue(okxSymbol)
+        fills := make([]Fill, 0, len(data))
+        for _, raw := range data {
+                item, ok := raw.(map[string]interface{})
+                if !ok {
+                        continue
+                }
+
+                positionSide := strings.ToUpper(getStringValue(item, "posSide"))
+                if positionSide == "NET" {
+                        positionSide = ""
+                }
+                fills = append(fills, Fill{
+                        OrderID:      getStringValue(item, "ordId"),
+                        Symbol:       symbol,
+                        Side:         getStringValue(item, "side"),
+                        PositionSide: positionSide,
+                        Quantity:     parseOKXFloat(getStringValue(item, "fillSz")) * ctVal,
+                        Price:        parseOKXFloat(getStringValue(item, "fillPx")),
+                        Fee:          -parseOKXFloat(getStringValue(item, "fee")), // OKX手续费为负数表示扣除
+                        RealizedPnL:  parseOKXFloat(getStringValue(item, "fillPnl")),
+                        Time:         time.UnixMilli(parseOKXTimestamp(getStringValue(item, "ts"))),
+                })
+        }
+
+        // OKX按时间倒序返回，统一为正序
+        sort.Slice(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+        return fills, nil
+}
+
+// GetFundingPayments 获取since之后该币种支付的资金费合计（实现FundingHistoryProvider）
+func (t *OKXTrader) GetFundingPayments(symbol string, since time.Time) (float64, error) {
+        okxSymbol := convertToOKXSymbol(symbol)
+        params := map[string]string{
+                "instType": "SWAP",
+                "type":     "8", // 资金费
+                "begin":    strconv.FormatInt(since.UnixMilli(), 10),
+                "limit":    "100",
+        }
+
+        // OKX API: GET /api/v5/account/bills（近7天）
+        resp, err := t.makeRequest("GET", "/api/v5/account/bills", params)
+        if err != nil {
+                return 0, fmt.Errorf("获取OKX资金费账单失败: %w", err)
+        }
+
+        // 账单余额变动正数为收取，取反后正数表示支付
+        paid := 0.0
+        data, _ := resp["data"].([]interface{})
+        for _, raw := range data {
+                item, ok := raw.(map[string]interface{})
+                if !ok || getStringValue(item, "instId") != okxSymbol {
+                        continue
+                }
+                paid -= parseOKXFloat(getStringValue(item, "balChg"))
+        }
+        return paid, nil
+}
+
+// GetIncomeHistory 获取since之后账户的手续费和资金费流水（实现IncomeLedgerProvider）
+func (t *OKXTrader) GetIncomeHistory(since time.Time) ([]IncomeRecord, error) {
+        var records []IncomeRecord
+        // 账单类型：2=交易（手续费），8=资金费
+        for _, billType := range []string{"2", "8"} {
+                params := map[string]string{
+                        "instType": "SWAP",
+                        "type":     billType,
+                        "begin":    strconv.FormatInt(since.UnixMilli(), 10),
+                        "limit":    "100",
+                }
+
+                // OKX API: GET /api/v5/account/bills（近7天）
+                resp, err := t.makeRequest("GET", "/api/v5/account/bills", params)
+                if err != nil {
+                        return nil, fmt.Errorf("获取OKX账单失败: %w", err)
+                }
+
+                data, _ := resp["data"].([]interface{})
+                for _, raw := range data {
+                        item, ok := raw.(map[string]interface{})
+                        if !ok {
+                                continue
+                        }
+                        // OKX手续费和余额变动为负数表示扣除，取反后正数表示支付
+                        record := IncomeRecord{
+                                Type:    "fee",
+                                Symbol:  convertFromOKXSymbol(getStringValue(item, "instId")),
+                                Amount:  -parseOKXFloat(getStringValue(item, "fee")),
+                                Ref:     getStringValue(item, "billId"),
+                                OrderID: getStringValue(item, "ordId"),
+                                Time:    time.UnixMilli(parseOKXTimestamp(getStringValue(item, "ts"))),
+                        }
+                        if billType == "8" {
+                                record.Type = "funding"
+                                record.Amount = -parseOKXFloat(getStringValue(item, "balChg"))
+                                record.OrderID = ""
+                        }
+                        if record.Ref == "" || record.Amount == 0 {
+                                continue
+                        }
+                        records = append(records, record)
+                }
+        }
+        return records, nil
+}
+
+// QueryOrder 查询订单状态（实现OrderQuerier）
+// OKX数量单位为合约张数，这里按合约面值换算为币数量
+func (t *OKXTrader) QueryOrder(symbol, orderID string) (*Order, error) {
+        okxSymbol := convertToOKXSymbol(symbol)
+        params := map[string]string{
+                "instId": okxSymbol,
+                "ordId":  orderID,
+        }
+
+        // OKX API: GET /api/v5/trade/order
+        resp, err := t.makeRequest("GET", "/api/v5/trade/order", params)
+        if err != nil {
+                return nil, fmt.Errorf("查询OKX订单失败: %w", err)
+        }
+
+        data, ok := resp["data"].([]interface{})
+        if !ok || len(data) == 0 {
+                return nil, fmt.Errorf("OKX订单不存在: %s", orderID)
+        }
+        item, ok := data[0].(map[string]interface{})
+        if !ok {
+                return nil, fmt.Errorf("OKX订单数据格式错误: %s", orderID)
+        }
+
+        ctVal := t.getContractValue(okxSymbol)
+        orderType := OrderTypeMarket
+        if ordType := getStringValue(item, "ordType"); ordType == "limit" || ordType == "post_only" {
+                orderType = OrderTypeLimit
+        }
+        return &Order{
+                ExchangeOrderID: orderID,
+                Symbol:          symbol,
+                PositionSide:    strings.ToUpper(getStringValue(item, "posSide")),
+                Type:            orderType,
+                Quantity:        parseOKXFloat(getStringValue(item, "sz")) * ctVal,
+                FilledQty:       parseOKXFloat(getStringValue(item, "accFillSz")) * ctVal,
+                AvgPrice:        parseOKXFloat(getStringValue(item, "avgPx")),
+                Fee:             math.Abs(parseOKXFloat(getStringValue(item, "fee"))), // OKX手续费为负数表示扣除
+                Status:          parseOrderStatus(getStringValue(item, "state")),
+        }, nil
+}
+
+// GetOpenOrders 获取该币种当前的止损止盈条件单（实现OrderQuerier）
+func (t *OKXTrader) GetOpenOrders(symbol string) ([]*Order, error) {
+        okxSymbol := convertToOKXSymbol(symbol)
+        params := map[string]string{
+                "instId":  okxSymbol,
+                "ordType": "conditional",
+        }
+
+        // OKX API: GET /api/v5/trade/orders-algo-pending
+        resp, err := t.makeRequest("GET", "/api/v5/trade/orders-algo-pending", params)
+        if err != nil {
+                return nil, fmt.Errorf("获取OKX条件单失败: %w", err)
+        }
+
+        data, _ := resp["data"].([]interface{})
+        orders := make([]*Order, 0, len(data))
+        for _, raw := range data {
+                item, ok := raw.(map[string]interface{})
+                if !ok {
+                        continue
+                }
+
+                base := Order{
+                        ExchangeOrderID: getStringValue(item, "algoId"),
+                        Symbol:          symbol,
+                        PositionSide:    strings.ToUpper(getStringValue(item, "posSide")),
+                        Quantity:        parseOKXFloat(getStringValue(item, "sz")),
+                        Status:          OrderStatusNew,
+                }
+
+                // 同一条件单可能同时带有止损和止盈
+                if px := parseOKXFloat(getStringValue(item, "slTriggerPx")); px > 0 {
+                        sl := base
+                        sl.Type = OrderTypeStopMarket
+                        sl.StopPrice = px
+                        orders = append(orders, &sl)
+                }
+                if px := parseOKXFloat(getStringValue(item, "tpTriggerPx")); px > 0 {
+                        tp := base
+                        tp.Type = OrderTypeTakeProfitMarket
+                        tp.StopPrice = px
+                        orders = append(orders, &tp)
+                }
+        }
+        return orders, nil
+}
+
+// PlaceLimitOrder 下限价开仓单（实现LimitOrderPlacer，postOnly时ordType=post_only，会立即成交的订单被OKX撤销）
+func (t *OKXTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+        if quantity <= 0 || price <= 0 {
+                return nil, fmt.Errorf("限价单数量和价格必须大于0")
+        }
+
+        okxSymbol := convertToOKXSymbol(symbol)
+
+        if err := t.EnsureLongShortMode(); err != nil {
+                log.Printf("⚠️ 设置持仓模式失败: %v，继续尝试下单", err)
+        }
+        if err := t.SetLeverage(okxSymbol, leverage); err != nil {
+                log.Printf("⚠️ 设置杠杆失败: %v", err)
+        }
+
+        contractSize, err := t.convertToContractSize(okxSymbol, quantity)
+        if err != nil {
+                return nil, fmt.Errorf("转换合约张数失败: %w", err)
+        }
+
+        // 价格按tickSz取整：多单向下、空单向上，避免取整后穿过盘口
+        side, posSide := "buy", "long"
+        if positionSide == "SHORT" {
+                side, posSide = "sell", "short"
+        }
+        if spec, err := t.getContractSpec(okxSymbol); err == nil && spec.TickSz > 0 {
+                ticks := price / spec.TickSz
+                if side == "buy" {
+                        ticks = math.Floor(ticks + 1e-9)
+                } else {
+                        ticks = math.Ceil(ticks - 1e-9)
+                }
+                price = ticks * spec.TickSz
+        }
+
+        ordType := "limit"
+        if postOnly {
+                ordType = "post_only"
+        }
+
+        order := map[string]string{
+                "instId":  okxSymbol,
+                "tdMode":  "cross",
+                "side":    side,
+                "posSide": posSide,
+                "ordType": ordType,
+                "sz":      contractSize,
+                "px":      strconv.FormatFloat(price, 'f', -1, 64),
+        }
+
+        return t.placeOrder(order, clientOrderID)
+}
+
+// CancelOrder 撤销单个订单（实现LimitOrderPlacer）
+func (t *OKXTrader) CancelOrder(symbol, orderID string) error {
+        params := map[string]string{
+                "instId": convertToOKXSymbol(symbol),
+                "ordId":  orderID,
+        }
+
+        // OKX API: POST /api/v5/trade/cancel-order
+        resp, err := t.makeRequest("POST", "/api/v5/trade/cancel-order", params)
+        if err != nil {
+                return fmt.Errorf("撤销OKX订单失败: %w", err)
+        }
+        if data, ok := resp["data"].([]interface{}); ok && len(data) > 0 {
+                if item, ok := data[0].(map[string]interface{}); ok {
+                        if sCode := getStringValue(item, "sCode"); sCode != "" && sCode != "0" {
+                                return fmt.Errorf("撤销OKX订单失败 [%s]: %s", sCode, getStringValue(item, "sMsg"))
+                        }
+                }
+        }
+        return nil
+}
+
+// CancelStopOrder 撤销止损/止盈条件单（实现StopOrderCanceller，条件单为策略订单，按algoId撤销）
+func (t *OKXTrader) CancelStopOrder(order *Order) error {
+        payload := []map[string]string{{
+                "instId": convertToOKXSymbol(order.Symbol),
+                "algoId": order.ExchangeOrderID,
+        }}
+
+        // OKX API: POST /api/v5/trade/cancel-algos
+        resp, err := t.makeJSONRequest("/api/v5/trade/cancel-algos", payload)
+        if err != nil {
+                return fmt.Errorf("撤销OKX条件单失败: %w", err)
+        }
+        if data, ok := resp["data"].([]interface{}); ok && len(data) > 0 {
+                if item, ok := data[0].(map[string]interface{}); ok {
+                        if sCode := getStringValue(item, "sCode"); sCode != "" && sCode != "0" {
+                                return fmt.Errorf("撤销OKX条件单失败 [%s]: %s", sCode, getStringValue(item, "sMsg"))
+                        }
+                }
+        }
+        return nil
+}
+
+// GetBestBidAsk 获取买一/卖一价（实现LimitOrderPlacer）
+func (t *OKXTrader) GetBestBidAsk(symbol string) (float64, float64, error) {
+        params := map[string]string{
+                "instId": convertToOKXSymbol(symbol),
+        }
+
+        // OKX API: GET /api/v5/market/ticker
+        resp, err := t.makeRequest("GET", "/api/v5/market/ticker", params)
+        if err != nil {
+                return 0, 0, fmt.Errorf("获取OKX盘口失败: %w", err)
+        }
+
+        data, ok := resp["data"].([]interface{})
+        if !ok || len(data) == 0 {
+                return 0, 0, fmt.Errorf("无法解析OKX盘口数据")
+        }
+        ticker, ok := data[0].(map[string]interface{})
+        if !ok {
+                return 0, 0, fmt.Errorf("无法解析OKX盘口数据")
+        }
+        return parseOKXFloat(getStringValue(ticker, "bidPx")), parseOKXFloat(getStringValue(ticker, "askPx")), nil
+}
+
+// GetBookDepth 获取买一/卖一挂单数量（实现BookDepthProvider，合约张数按面值换算为币数量）
+func (t *OKXTrader) GetBookDepth(symbol string) (float64, float64, error) {
+        okxSymbol := convertToOKXSymbol(symbol)
+        resp, err := t.makeRequest("GET", "/api/v5/market/ticker", map[string]string{"instId": okxSymbol})
+        if err != nil {
+                return 0, 0, fmt.Errorf("获取OKX盘口失败: %w", err)
+        }
+
+        data, ok := resp["data"].([]interface{})
+        if !ok || len(data) == 0 {
+                return 0, 0, fmt.Errorf("无法解析OKX盘口数据")
+        }
+        ticker, ok := data[0].(map[string]interface{})
+        if !ok {
+                return 0, 0, fmt.Errorf("无法解析OKX盘口数据")
+        }
+        ctVal := t.getContractValue(okxSymbol)
+        return parseOKXFloat(getStringValue(ticker, "bidSz")) * ctVal, parseOKXFloat(getStringValue(ticker, "askSz")) * ctVal, nil
+}
+
 // standardizeSide 标准化交易方向
 func (t *OKXTrader) standardizeSide(side string) string {
         switch strings.ToLower(side) {
@@ -1063,11 +1427,8 @@ func (t *OKXTrader) generateSignature(timestamp, method, requestPath, body strin
 
 // makeRequest 发送HTTP请求（遵循KISS原则）
 func (t *OKXTrader) makeRequest(method, endpoint string, params map[string]string) (map[string]interface{}, error) {
-        timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
-
         var body string
         var requestPath string = endpoint
-        var fullURL string = t.baseURL + endpoint
 
         if method == "GET" && len(params) > 0 {
                 // GET请求：参数需要添加到URL查询字符串中
@@ -1078,7 +1439,6 @@ func (t *OKXTrader) makeRequest(method, endpoint string, params map[string]strin
                 }
                 queryString := strings.Join(queryParts, "&")
                 requestPath = endpoint + "?" + queryString
-                fullURL = t.baseURL + requestPath
                 log.Printf("📡 OKX GET请求: %s", requestPath)
         } else if method == "POST" && len(params) > 0 {
                 // POST请求：参数放在body中
@@ -1090,6 +1450,23 @@ func (t *OKXTrader) makeRequest(method, endpoint string, params map[string]strin
                 log.Printf("📡 OKX POST请求: %s, body: %s", endpoint, body)
         }
 
+        return t.send(method, requestPath, body)
+}
+
+// makeJSONRequest 发送请求体为任意JSON（如批量接口的数组）的POST请求
+func (t *OKXTrader) makeJSONRequest(endpoint string, payload interface{}) (map[string]interface{}, error) {
+        jsonBody, err := json.Marshal(payload)
+        if err != nil {
+                return nil, fmt.Errorf("序列化请求参数失败: %w", err)
+        }
+        log.Printf("📡 OKX POST请求: %s, body: %s", endpoint, string(jsonBody))
+        return t.send("POST", endpoint, string(jsonBody))
+}
+
+// send 签名并发送请求，检查OKX错误码（requestPath含GET查询字符串）
+func (t *OKXTrader) send(method, requestPath, body string) (map[string]interface{}, error) {
+        timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+
         // 生成签名（使用完整的请求路径）
         signature := t.generateSignature(timestamp, method, requestPath, body)
 
@@ -1099,7 +1476,7 @@ func (t *OKXTrader) makeRequest(method, endpoint string, params map[string]strin
                 reqBody = strings.NewReader(body)
         }
 
-        req, err := http.NewRequest(method, fullURL, reqBody)
+        req, err := http.NewRequest(method, t.baseURL+requestPath, reqBody)
         if err != nil {
                 return nil, fmt.Errorf("创建请求失败: %w", err)
         }
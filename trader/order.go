@@ -0,0 +1,246 @@
+package trader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/database"
+)
+
+// OrderStatus 订单状态（各交易所状态统一映射到这里）
+type OrderStatus string
+
+const (
+	OrderStatusNew             OrderStatus = "new"              // 已提交，等待成交或触发
+	OrderStatusPartiallyFilled OrderStatus = "partially_filled" // 部分成交
+	OrderStatusFilled          OrderStatus = "filled"           // 完全成交（条件单为已触发）
+	OrderStatusCancelled       OrderStatus = "cancelled"        // 已撤销
+	OrderStatusRejected        OrderStatus = "rejected"         // 被交易所拒绝
+	OrderStatusExpired         OrderStatus = "expired"          // 已失效（如持仓平掉后的止损止盈单）
+)
+
+// IsTerminal 是否为终态（终态订单不再对账）
+func (s OrderStatus) IsTerminal() bool {
+	switch s {
+	case OrderStatusFilled, OrderStatusCancelled, OrderStatusRejected, OrderStatusExpired:
+		return true
+	}
+	return false
+}
+
+// OrderType 订单类型
+type OrderType string
+
+const (
+	OrderTypeMarket           OrderType = "market"
+	OrderTypeLimit            OrderType = "limit"              // 限价单（含只做Maker）
+	OrderTypeStopMarket       OrderType = "stop_market"        // 止损单
+	OrderTypeTakeProfitMarket OrderType = "take_profit_market" // 止盈单
+)
+
+// IsProtective 是否为止损/止盈条件单
+func (t OrderType) IsProtective() bool {
+	return t == OrderTypeStopMarket || t == OrderTypeTakeProfitMarket
+}
+
+// Order 交易所无关的订单模型
+type Order struct {
+	ClientOrderID   string      `json:"client_order_id"`             // 本地生成的订单ID
+	ExchangeOrderID string      `json:"exchange_order_id,omitempty"` // 交易所订单ID（OKX/Hyperliquid等为字符串或无法获取）
+	TraderID        string      `json:"trader_id"`
+	Exchange        string      `json:"exchange"`
+	Symbol          string      `json:"symbol"`
+	Action          string      `json:"action"`        // open_long, open_short, close_long, close_short, stop_loss, take_profit
+	PositionSide    string      `json:"position_side"` // LONG / SHORT
+	Type            OrderType   `json:"type"`
+	Quantity        float64     `json:"quantity"` // 下单数量（平仓数量为0表示全部平仓）
+	FilledQty       float64     `json:"filled_qty"`
+	AvgPrice        float64     `json:"avg_price"`
+	Price           float64     `json:"price,omitempty"`      // 限价单委托价
+	StopPrice       float64     `json:"stop_price,omitempty"` // 条件单触发价
+	Fee             float64     `json:"fee"`
+	Status          OrderStatus `json:"status"`
+	Error           string      `json:"error,omitempty"` // 拒绝/撤销原因
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+}
+
+// OrderQuerier 可查询订单状态的交易器（订单管理器对账时使用）
+// 未实现该接口的交易器只记录下单时的同步结果
+type OrderQuerier interface {
+	// QueryOrder 查询单个订单的最新状态
+	QueryOrder(symbol, orderID string) (*Order, error)
+
+	// GetOpenOrders 获取该币种当前所有挂单（含止损止盈条件单）
+	GetOpenOrders(symbol string) ([]*Order, error)
+}
+
+// StopOrderCanceller 可按订单ID撤销止损/止盈条件单的交易器
+// 订单管理器替换某一方向的条件单时使用，不影响另一方向的条件单和限价挂单
+type StopOrderCanceller interface {
+	// GetOpenOrders 获取该币种当前所有挂单（含止损止盈条件单）
+	GetOpenOrders(symbol string) ([]*Order, error)
+
+	// CancelStopOrder 撤销单个止损/止盈条件单（order为GetOpenOrders返回的挂单）
+	CancelStopOrder(order *Order) error
+}
+
+// ClientOrderPlacer 下单时可携带本地订单ID的交易器
+// 订单管理器在下单前生成ClientOrderID并随订单发往交易所（Binance/Aster newClientOrderId、OKX clOrdId、Hyperliquid cloid），
+// 下单请求超时等情况下可按该ID在交易所找到订单；未实现该接口的交易器按普通方式下单
+type ClientOrderPlacer interface {
+	// PlaceMarketOrder 按动作下市价单（open_long/open_short/close_long/close_short，平仓quantity=0表示全部平仓）
+	PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error)
+}
+
+// placeMarketOrder 下市价单，交易器支持时携带本地订单ID
+func placeMarketOrder(t Trader, action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	if placer, ok := t.(ClientOrderPlacer); ok {
+		return placer.PlaceMarketOrder(action, symbol, quantity, leverage, clientOrderID)
+	}
+	switch action {
+	case "open_long":
+		return t.OpenLong(symbol, quantity, leverage)
+	case "open_short":
+		return t.OpenShort(symbol, quantity, leverage)
+	case "close_long":
+		return t.CloseLong(symbol, quantity)
+	case "close_short":
+		return t.CloseShort(symbol, quantity)
+	}
+	return nil, fmt.Errorf("未知的下单动作: %s", action)
+}
+
+// OrderStore 订单持久化（config.Database实现）
+type OrderStore interface {
+	SaveOrder(record database.OrderRecord) error
+	GetActiveOrders(traderID string) ([]database.OrderRecord, error)
+	GetOrders(traderID, status string, limit int) ([]database.OrderRecord, error)
+}
+
+// toRecord 转换为数据库记录
+func (o *Order) toRecord() database.OrderRecord {
+	return database.OrderRecord{
+		ClientOrderID:   o.ClientOrderID,
+		ExchangeOrderID: o.ExchangeOrderID,
+		TraderID:        o.TraderID,
+		Exchange:        o.Exchange,
+		Symbol:          o.Symbol,
+		Action:          o.Action,
+		PositionSide:    o.PositionSide,
+		OrderType:       string(o.Type),
+		Quantity:        o.Quantity,
+		FilledQty:       o.FilledQty,
+		AvgPrice:        o.AvgPrice,
+		Price:           o.Price,
+		StopPrice:       o.StopPrice,
+		Fee:             o.Fee,
+		Status:          string(o.Status),
+		Error:           o.Error,
+		CreatedAt:       o.CreatedAt,
+		UpdatedAt:       o.UpdatedAt,
+	}
+}
+
+// orderFromRecord 从数据库记录恢复订单
+func orderFromRecord(r database.OrderRecord) *Order {
+	return &Order{
+		ClientOrderID:   r.ClientOrderID,
+		ExchangeOrderID: r.ExchangeOrderID,
+		TraderID:        r.TraderID,
+		Exchange:        r.Exchange,
+		Symbol:          r.Symbol,
+		Action:          r.Action,
+		PositionSide:    r.PositionSide,
+		Type:            OrderType(r.OrderType),
+		Quantity:        r.Quantity,
+		FilledQty:       r.FilledQty,
+		AvgPrice:        r.AvgPrice,
+		Price:           r.Price,
+		StopPrice:       r.StopPrice,
+		Fee:             r.Fee,
+		Status:          OrderStatus(r.Status),
+		Error:           r.Error,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+}
+
+// NumericOrderID 数字形式的交易所订单ID（无法转换时返回0，用于兼容DecisionAction.OrderID）
+func (o *Order) NumericOrderID() int64 {
+	id, err := strconv.ParseInt(o.ExchangeOrderID, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// String 订单摘要（日志和提示词使用）
+func (o *Order) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s %s", o.Symbol, o.Action, o.Status)
+	if o.Type.IsProtective() {
+		fmt.Fprintf(&sb, " 触发价%.4f", o.StopPrice)
+	} else if o.Type == OrderTypeLimit {
+		fmt.Fprintf(&sb, " 限价%.4f", o.Price)
+	}
+	if o.FilledQty > 0 {
+		fmt.Fprintf(&sb, " 成交%.4f/%.4f 均价%.4f", o.FilledQty, o.Quantity, o.AvgPrice)
+	}
+	if o.Error != "" {
+		fmt.Fprintf(&sb, " (%s)", o.Error)
+	}
+	return sb.String()
+}
+
+// parseOrderStatus 将交易所状态映射为统一状态
+// 兼容 Binance/Aster (NEW, FILLED...)、OKX (live, canceled...)、Hyperliquid (open, triggered...)
+func parseOrderStatus(s string) OrderStatus {
+	switch strings.ToLower(s) {
+	case "new", "live", "open", "effective_pending", "new_insurance", "new_adl":
+		return OrderStatusNew
+	case "partially_filled", "partiallyfilled":
+		return OrderStatusPartiallyFilled
+	case "filled", "triggered", "effective":
+		return OrderStatusFilled
+	case "rejected", "order_failed":
+		return OrderStatusRejected
+	case "expired":
+		return OrderStatusExpired
+	case "":
+		return ""
+	}
+	if strings.Contains(strings.ToLower(s), "rejected") {
+		return OrderStatusRejected
+	}
+	if strings.Contains(strings.ToLower(s), "cancel") {
+		return OrderStatusCancelled
+	}
+	return ""
+}
+
+// applyOrderResult 将下单结果写入订单
+func applyOrderResult(order *Order, result *OrderResult) {
+	if result == nil {
+		return
+	}
+
+	order.ExchangeOrderID = result.OrderID
+	if result.Status != "" {
+		order.Status = result.Status
+	}
+	if result.ExecutedQty > 0 {
+		order.FilledQty = result.ExecutedQty
+	}
+	if result.AvgPrice > 0 {
+		order.AvgPrice = result.AvgPrice
+	}
+	if result.Fee > 0 {
+		order.Fee = result.Fee
+	}
+	if order.Status == OrderStatusFilled && order.FilledQty == 0 {
+		order.FilledQty = order.Quantity
+	}
+}
@@ -0,0 +1,619 @@
+package trader
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	orderReconcileInterval = 30 * time.Second // 后台对账间隔
+	orderRetention         = 24 * time.Hour   // 终态订单在内存中保留的时长
+)
+
+// OrderManager 订单管理器
+// 包装Trader的下单接口，为每笔订单（含止损止盈条件单）生成本地订单ID并跟踪状态：
+// 下单结果写入订单，随后轮询交易所对账，使部分成交、拒单、条件单被撤销等情况对交易员和API可见。
+type OrderManager struct {
+	traderID string
+	exchange string
+	trader   Trader
+	store    OrderStore          // 可为nil（回放/测试时只在内存中跟踪）
+	clock    func() time.Time    // nil时使用time.Now
+	sleep    func(time.Duration) // 限价/TWAP执行时的等待（nil时使用time.Sleep）
+
+	mu      sync.Mutex
+	orders  map[string]*Order // client_order_id -> order
+	seq     int64
+	notices []string // 等待告知AI的订单状态变化
+	running bool
+	stopCh  chan struct{}
+
+	reconcileMu sync.Mutex // 保证同一时间只有一次对账
+}
+
+// NewOrderManager 创建订单管理器
+func NewOrderManager(traderID, exchange string, t Trader, store OrderStore) *OrderManager {
+	return &OrderManager{
+		traderID: traderID,
+		exchange: exchange,
+		trader:   t,
+		store:    store,
+		orders:   make(map[string]*Order),
+	}
+}
+
+func (m *OrderManager) now() time.Time {
+	if m.clock != nil {
+		return m.clock()
+	}
+	return time.Now()
+}
+
+// Load 从数据库恢复未完结的订单（重启后继续对账）
+func (m *OrderManager) Load() error {
+	if m.store == nil {
+		return nil
+	}
+	records, err := m.store.GetActiveOrders(m.traderID)
+	if err != nil {
+		return fmt.Errorf("加载未完结订单失败: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range records {
+		m.orders[r.ClientOrderID] = orderFromRecord(r)
+	}
+	if len(records) > 0 {
+		log.Printf("📋 [%s] 恢复 %d 个未完结订单", m.traderID, len(records))
+	}
+	return nil
+}
+
+// Start 启动后台对账
+func (m *OrderManager) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = orderReconcileInterval
+	}
+
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = true
+	stopCh := make(chan struct{})
+	m.stopCh = stopCh
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				m.Reconcile()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台对账
+func (m *OrderManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running {
+		return
+	}
+	m.running = false
+	close(m.stopCh)
+}
+
+// OpenLong 开多仓
+func (m *OrderManager) OpenLong(symbol string, quantity float64, leverage int) (*Order, error) {
+	return m.submit(symbol, "open_long", "LONG", OrderTypeMarket, quantity, 0, func(clientOrderID string) (*OrderResult, error) {
+		return placeMarketOrder(m.trader, "open_long", symbol, quantity, leverage, clientOrderID)
+	})
+}
+
+// OpenShort 开空仓
+func (m *OrderManager) OpenShort(symbol string, quantity float64, leverage int) (*Order, error) {
+	return m.submit(symbol, "open_short", "SHORT", OrderTypeMarket, quantity, 0, func(clientOrderID string) (*OrderResult, error) {
+		return placeMarketOrder(m.trader, "open_short", symbol, quantity, leverage, clientOrderID)
+	})
+}
+
+// CloseLong 平多仓（quantity=0表示全部平仓）
+func (m *OrderManager) CloseLong(symbol string, quantity float64) (*Order, error) {
+	return m.submit(symbol, "close_long", "LONG", OrderTypeMarket, quantity, 0, func(clientOrderID string) (*OrderResult, error) {
+		return placeMarketOrder(m.trader, "close_long", symbol, quantity, 0, clientOrderID)
+	})
+}
+
+// CloseShort 平空仓（quantity=0表示全部平仓）
+func (m *OrderManager) CloseShort(symbol string, quantity float64) (*Order, error) {
+	return m.submit(symbol, "close_short", "SHORT", OrderTypeMarket, quantity, 0, func(clientOrderID string) (*OrderResult, error) {
+		return placeMarketOrder(m.trader, "close_short", symbol, quantity, 0, clientOrderID)
+	})
+}
+
+// SetStopLoss 设置止损单
+func (m *OrderManager) SetStopLoss(symbol, positionSide string, quantity, stopPrice float64) (*Order, error) {
+	return m.protect(symbol, positionSide, OrderTypeStopMarket, quantity, stopPrice, func() error {
+		return m.trader.SetStopLoss(symbol, positionSide, quantity, stopPrice)
+	})
+}
+
+// SetTakeProfit 设置止盈单
+func (m *OrderManager) SetTakeProfit(symbol, positionSide string, quantity, takeProfitPrice float64) (*Order, error) {
+	return m.protect(symbol, positionSide, OrderTypeTakeProfitMarket, quantity, takeProfitPrice, func() error {
+		return m.trader.SetTakeProfit(symbol, positionSide, quantity, takeProfitPrice)
+	})
+}
+
+// CancelAllOrders 取消该币种的所有挂单，并将跟踪中的条件单标记为已撤销
+func (m *OrderManager) CancelAllOrders(symbol string) error {
+	if err := m.trader.CancelAllOrders(symbol); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	changed := m.closeProtectiveLocked(symbol, "", "", OrderStatusCancelled, "主动撤销")
+	m.mu.Unlock()
+
+	m.save(changed...)
+	return nil
+}
+
+// CancelProtectiveOrders 撤销跟踪中的该币种、该持仓方向的止损/止盈条件单，并标记为已撤销
+// 只撤销本地跟踪的条件单在交易所对应的挂单（分散到多个交易所的同价条件单一并撤销），
+// 另一方向的条件单和限价开仓挂单不受影响
+func (m *OrderManager) CancelProtectiveOrders(symbol, positionSide string) error {
+	m.mu.Lock()
+	var tracked []Order
+	for _, o := range m.orders {
+		if o.Symbol == symbol && o.Type.IsProtective() && !o.Status.IsTerminal() && strings.EqualFold(o.PositionSide, positionSide) {
+			tracked = append(tracked, *o)
+		}
+	}
+	m.mu.Unlock()
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	canceller, ok := m.trader.(StopOrderCanceller)
+	if !ok {
+		return fmt.Errorf("交易所不支持撤销单个条件单")
+	}
+	opens, err := canceller.GetOpenOrders(symbol)
+	if err != nil {
+		return fmt.Errorf("获取挂单失败: %w", err)
+	}
+
+	cancelled := make(map[*Order]bool)
+	for i := range tracked {
+		match := matchProtectiveOrder(opens, &tracked[i])
+		if match == nil {
+			continue // 已不在挂单列表（已触发或已被撤销）
+		}
+		for _, open := range opens {
+			if cancelled[open] || (open != match && (open.Type != match.Type ||
+				!strings.EqualFold(open.PositionSide, match.PositionSide) || open.StopPrice != match.StopPrice)) {
+				continue
+			}
+			if err := canceller.CancelStopOrder(open); err != nil {
+				return fmt.Errorf("撤销%s条件单失败: %w", open.Symbol, err)
+			}
+			cancelled[open] = true
+		}
+	}
+
+	m.mu.Lock()
+	changed := m.closeProtectiveLocked(symbol, positionSide, "", OrderStatusCancelled, "主动撤销")
+	m.mu.Unlock()
+
+	m.save(changed...)
+	return nil
+}
+
+// submit 提交市价/限价单并记录结果（price为限价单委托价）
+// 下单前先生成本地订单ID并传给place，交易所订单与本地记录使用同一个ClientOrderID
+func (m *OrderManager) submit(symbol, action, positionSide string, typ OrderType, quantity, price float64, place func(clientOrderID string) (*OrderResult, error)) (*Order, error) {
+	m.mu.Lock()
+	order := m.newOrderLocked(symbol, action, positionSide, typ, quantity, 0)
+	order.Price = price
+	clientOrderID := order.ClientOrderID
+	m.mu.Unlock()
+
+	result, err := place(clientOrderID)
+
+	m.mu.Lock()
+	order.UpdatedAt = m.now()
+	changed := []Order{}
+	if err != nil {
+		order.Status = OrderStatusRejected
+		order.Error = err.Error()
+	} else {
+		applyOrderResult(order, result)
+		if _, ok := m.trader.(OrderQuerier); typ == OrderTypeMarket && (!ok || order.ExchangeOrderID == "") && !order.Status.IsTerminal() {
+			// 无法查询订单状态时，市价单按已成交处理
+			order.Status = OrderStatusFilled
+			if order.FilledQty == 0 {
+				order.FilledQty = order.Quantity
+			}
+		}
+		if strings.HasPrefix(action, "close_") {
+			changed = m.closeProtectiveLocked(symbol, positionSide, "", OrderStatusExpired, "持仓已平仓")
+		}
+	}
+	snapshot := *order
+	m.mu.Unlock()
+
+	m.save(append(changed, snapshot)...)
+	return &snapshot, err
+}
+
+// protect 提交止损/止盈条件单并记录结果
+func (m *OrderManager) protect(symbol, positionSide string, typ OrderType, quantity, stopPrice float64, place func() error) (*Order, error) {
+	err := place()
+
+	action := "stop_loss"
+	if typ == OrderTypeTakeProfitMarket {
+		action = "take_profit"
+	}
+
+	m.mu.Lock()
+	var changed []Order
+	if err == nil {
+		// 同币种同方向的旧条件单视为被新单替换
+		changed = m.closeProtectiveLocked(symbol, positionSide, typ, OrderStatusCancelled, "已被新的委托替换")
+	}
+	order := m.newOrderLocked(symbol, action, strings.ToUpper(positionSide), typ, quantity, stopPrice)
+	if err != nil {
+		order.Status = OrderStatusRejected
+		order.Error = err.Error()
+		m.notices = append(m.notices, order.String())
+	}
+	snapshot := *order
+	m.mu.Unlock()
+
+	m.save(append(changed, snapshot)...)
+	return &snapshot, err
+}
+
+// newOrderLocked 创建并登记新订单（调用方需持有锁）
+func (m *OrderManager) newOrderLocked(symbol, action, positionSide string, typ OrderType, quantity, stopPrice float64) *Order {
+	now := m.now()
+	m.seq++
+	order := &Order{
+		ClientOrderID: m.newClientOrderID(now),
+		TraderID:      m.traderID,
+		Exchange:      m.exchange,
+		Symbol:        symbol,
+		Action:        action,
+		PositionSide:  positionSide,
+		Type:          typ,
+		Quantity:      quantity,
+		StopPrice:     stopPrice,
+		Status:        OrderStatusNew,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	m.orders[order.ClientOrderID] = order
+	return order
+}
+
+// newClientOrderID 生成随订单发往交易所的本地订单ID
+// 格式 nx + 交易员ID哈希(8位) + 毫秒时间戳(11位) + 序号(4位)，均为十六进制：
+// 共25个字母数字字符，同时满足Binance/Aster newClientOrderId（≤36位）、OKX clOrdId（字母数字≤32位），
+// 并可补零为Hyperliquid cloid（16字节十六进制）
+func (m *OrderManager) newClientOrderID(now time.Time) string {
+	h := fnv.New32a()
+	h.Write([]byte(m.traderID))
+	return fmt.Sprintf("nx%08x%011x%04x", h.Sum32(), now.UnixMilli()&0xfffffffffff, m.seq&0xffff)
+}
+
+// closeProtectiveLocked 将符合条件的未完结条件单置为终态（positionSide/typ为空表示不限）
+func (m *OrderManager) closeProtectiveLocked(symbol, positionSide string, typ OrderType, status OrderStatus, reason string) []Order {
+	var changed []Order
+	for _, o := range m.orders {
+		if o.Symbol != symbol || !o.Type.IsProtective() || o.Status.IsTerminal() {
+			continue
+		}
+		if positionSide != "" && !strings.EqualFold(o.PositionSide, positionSide) {
+			continue
+		}
+		if typ != "" && o.Type != typ {
+			continue
+		}
+		o.Status = status
+		o.Error = reason
+		o.UpdatedAt = m.now()
+		changed = append(changed, *o)
+	}
+	return changed
+}
+
+// Reconcile 轮询交易所对账，返回状态发生变化的订单
+// 市价单按交易所订单ID查询成交；条件单按挂单列表核对，消失时根据持仓判断是已触发/失效还是被撤销
+func (m *OrderManager) Reconcile() []Order {
+	m.reconcileMu.Lock()
+	defer m.reconcileMu.Unlock()
+
+	m.mu.Lock()
+	active := make([]Order, 0, len(m.orders))
+	for _, o := range m.orders {
+		if !o.Status.IsTerminal() {
+			active = append(active, *o)
+		}
+	}
+	m.mu.Unlock()
+	if len(active) == 0 {
+		return nil
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+
+	querier, canQuery := m.trader.(OrderQuerier)
+	openOrders := make(map[string][]*Order)
+	var positions map[string]bool
+	positionsLoaded := false
+
+	// hasPosition 判断持仓是否仍然存在（获取失败时返回ok=false，跳过判断）
+	hasPosition := func(symbol, positionSide string) (open bool, ok bool) {
+		if !positionsLoaded {
+			positionsLoaded = true
+			list, err := m.trader.GetPositions()
+			if err != nil {
+				log.Printf("⚠️ [%s] 订单对账获取持仓失败: %v", m.traderID, err)
+				return false, false
+			}
+			positions = make(map[string]bool)
+			for _, pos := range list {
+				positions[pos.Symbol+"_"+pos.Side] = true
+			}
+		}
+		if positions == nil {
+			return false, false
+		}
+		return positions[symbol+"_"+strings.ToLower(positionSide)], true
+	}
+
+	var updated []Order
+	for _, o := range active {
+		next := o
+		if !o.Type.IsProtective() {
+			if !canQuery || o.ExchangeOrderID == "" {
+				continue
+			}
+			remote, err := querier.QueryOrder(o.Symbol, o.ExchangeOrderID)
+			if err != nil {
+				log.Printf("⚠️ [%s] 查询订单 %s 失败: %v", m.traderID, o.ExchangeOrderID, err)
+				continue
+			}
+			mergeRemoteOrder(&next, remote)
+		} else {
+			if canQuery {
+				opens, loaded := openOrders[o.Symbol]
+				if !loaded {
+					list, err := querier.GetOpenOrders(o.Symbol)
+					if err != nil {
+						log.Printf("⚠️ [%s] 获取 %s 挂单失败: %v", m.traderID, o.Symbol, err)
+						continue
+					}
+					opens = list
+					openOrders[o.Symbol] = opens
+				}
+				if match := matchProtectiveOrder(opens, &o); match != nil {
+					if next.ExchangeOrderID == "" {
+						next.ExchangeOrderID = match.ExchangeOrderID
+					}
+					if !orderChanged(o, next) {
+						continue
+					}
+					updated = append(updated, next)
+					continue
+				}
+				// 已不在挂单列表：有订单ID时先查询确切状态
+				if o.ExchangeOrderID != "" {
+					if remote, err := querier.QueryOrder(o.Symbol, o.ExchangeOrderID); err == nil && remote.Status.IsTerminal() {
+						mergeRemoteOrder(&next, remote)
+						updated = append(updated, next)
+						continue
+					}
+				}
+			}
+
+			open, ok := hasPosition(o.Symbol, o.PositionSide)
+			switch {
+			case !ok:
+				continue
+			case !open:
+				next.Status = OrderStatusExpired
+				next.Error = "持仓已平仓"
+			case canQuery:
+				next.Status = OrderStatusCancelled
+				next.Error = "条件单已不在交易所挂单列表中，持仓仍在"
+			}
+		}
+
+		if orderChanged(o, next) {
+			updated = append(updated, next)
+		}
+	}
+
+	now := m.now()
+	m.mu.Lock()
+	var changed []Order
+	for _, next := range updated {
+		cur, ok := m.orders[next.ClientOrderID]
+		// 对账期间订单已被下单流程更新（如被新条件单替换）时以本地状态为准
+		if !ok || cur.Status.IsTerminal() {
+			continue
+		}
+		next.UpdatedAt = now
+		*cur = next
+		changed = append(changed, next)
+		if notice := orderNotice(&next); notice != "" {
+			m.notices = append(m.notices, notice)
+			log.Printf("📋 [%s] 订单状态变化: %s", m.traderID, notice)
+		}
+	}
+	// 清理过期的终态订单
+	for id, o := range m.orders {
+		if o.Status.IsTerminal() && now.Sub(o.UpdatedAt) > orderRetention {
+			delete(m.orders, id)
+		}
+	}
+	m.mu.Unlock()
+
+	m.save(changed...)
+	return changed
+}
+
+// DrainNotices 取出等待告知AI的订单状态变化（取出后清空）
+func (m *OrderManager) DrainNotices() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	notices := m.notices
+	m.notices = nil
+	return notices
+}
+
+// ActiveStopPrice 跟踪中的最新未完结条件单触发价（没有时返回0）
+func (m *OrderManager) ActiveStopPrice(symbol, positionSide string, typ OrderType) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var latest *Order
+	for _, o := range m.orders {
+		if o.Symbol != symbol || o.Type != typ || o.Status.IsTerminal() || !strings.EqualFold(o.PositionSide, positionSide) {
+			continue
+		}
+		if latest == nil || o.CreatedAt.After(latest.CreatedAt) {
+			latest = o
+		}
+	}
+	if latest == nil {
+		return 0
+	}
+	return latest.StopPrice
+}
+
+// Orders 获取订单列表（按创建时间倒序，status为空时不过滤）
+// 配置了数据库时从数据库读取完整历史，否则返回内存中跟踪的订单
+func (m *OrderManager) Orders(status OrderStatus, limit int) ([]*Order, error) {
+	if m.store != nil {
+		records, err := m.store.GetOrders(m.traderID, string(status), limit)
+		if err != nil {
+			return nil, err
+		}
+		orders := make([]*Order, 0, len(records))
+		for _, r := range records {
+			orders = append(orders, orderFromRecord(r))
+		}
+		return orders, nil
+	}
+
+	m.mu.Lock()
+	orders := make([]*Order, 0, len(m.orders))
+	for _, o := range m.orders {
+		if status == "" || o.Status == status {
+			copied := *o
+			orders = append(orders, &copied)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.After(orders[j].CreatedAt) })
+	if limit > 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// save 持久化订单（失败只记录日志，不影响交易）
+func (m *OrderManager) save(orders ...Order) {
+	if m.store == nil {
+		return
+	}
+	for i := range orders {
+		if err := m.store.SaveOrder(orders[i].toRecord()); err != nil {
+			log.Printf("⚠️ [%s] 保存订单 %s 失败: %v", m.traderID, orders[i].ClientOrderID, err)
+		}
+	}
+}
+
+// mergeRemoteOrder 将交易所查询到的订单状态合并到本地订单
+func mergeRemoteOrder(dst *Order, remote *Order) {
+	if remote == nil {
+		return
+	}
+	if remote.Status != "" {
+		dst.Status = remote.Status
+	}
+	if remote.FilledQty > 0 {
+		dst.FilledQty = remote.FilledQty
+	}
+	if remote.AvgPrice > 0 {
+		dst.AvgPrice = remote.AvgPrice
+	}
+	if remote.Fee > 0 {
+		dst.Fee = remote.Fee
+	}
+	if dst.Quantity == 0 && remote.Quantity > 0 {
+		dst.Quantity = remote.Quantity
+	}
+	if dst.ExchangeOrderID == "" {
+		dst.ExchangeOrderID = remote.ExchangeOrderID
+	}
+	if remote.Error != "" {
+		dst.Error = remote.Error
+	}
+}
+
+// matchProtectiveOrder 在交易所挂单中查找与本地条件单对应的订单
+// 优先按订单ID匹配，否则取同类型、同方向中触发价最接近的挂单
+func matchProtectiveOrder(opens []*Order, o *Order) *Order {
+	var best *Order
+	bestDiff := math.MaxFloat64
+	for _, open := range opens {
+		if o.ExchangeOrderID != "" && open.ExchangeOrderID == o.ExchangeOrderID {
+			return open
+		}
+		if open.Type != o.Type || !strings.EqualFold(open.PositionSide, o.PositionSide) {
+			continue
+		}
+		if diff := math.Abs(open.StopPrice - o.StopPrice); diff < bestDiff {
+			best = open
+			bestDiff = diff
+		}
+	}
+	return best
+}
+
+// orderChanged 对账结果是否有变化
+func orderChanged(a, b Order) bool {
+	return a.Status != b.Status || a.FilledQty != b.FilledQty || a.AvgPrice != b.AvgPrice ||
+		a.Fee != b.Fee || a.ExchangeOrderID != b.ExchangeOrderID || a.Quantity != b.Quantity
+}
+
+// orderNotice 需要告知AI的订单状态变化（部分成交、拒单、撤单、条件单触发）
+func orderNotice(o *Order) string {
+	switch o.Status {
+	case OrderStatusPartiallyFilled, OrderStatusRejected, OrderStatusCancelled:
+		return o.String()
+	case OrderStatusFilled:
+		if o.Type.IsProtective() {
+			return o.String()
+		}
+	}
+	return ""
+}
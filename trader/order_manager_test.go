@@ -0,0 +1,322 @@
+package trader
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"nofx/database"
+	"nofx/decision"
+)
+
+// memOrderStore 内存订单存储
+type memOrderStore struct {
+	records map[string]database.OrderRecord
+}
+
+func newMemOrderStore() *memOrderStore {
+	return &memOrderStore{records: make(map[string]database.OrderRecord)}
+}
+
+func (s *memOrderStore) SaveOrder(record database.OrderRecord) error {
+	s.records[record.ClientOrderID] = record
+	return nil
+}
+
+func (s *memOrderStore) GetActiveOrders(traderID string) ([]database.OrderRecord, error) {
+	var records []database.OrderRecord
+	for _, r := range s.records {
+		if r.TraderID == traderID && !OrderStatus(r.Status).IsTerminal() {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (s *memOrderStore) GetOrders(traderID, status string, limit int) ([]database.OrderRecord, error) {
+	var records []database.OrderRecord
+	for _, r := range s.records {
+		if r.TraderID == traderID && (status == "" || r.Status == status) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// partialFillTrader 查询订单时返回部分成交的交易器
+type partialFillTrader struct {
+	*SimulatedTrader
+}
+
+func (t partialFillTrader) QueryOrder(symbol, orderID string) (*Order, error) {
+	return &Order{ExchangeOrderID: orderID, Quantity: 1, FilledQty: 0.4, AvgPrice: 101, Fee: 0.02, Status: parseOrderStatus("PARTIALLY_FILLED")}, nil
+}
+
+// clientIDTrader 记录下单时收到的本地订单ID，并检查该订单此时是否已在订单管理器中登记
+type clientIDTrader struct {
+	*SimulatedTrader
+	om      *OrderManager
+	ids     []string
+	tracked []bool
+}
+
+func (t *clientIDTrader) record(clientOrderID string) {
+	t.om.mu.Lock()
+	o, ok := t.om.orders[clientOrderID]
+	t.tracked = append(t.tracked, ok && o.Status == OrderStatusNew)
+	t.om.mu.Unlock()
+	t.ids = append(t.ids, clientOrderID)
+}
+
+func (t *clientIDTrader) PlaceMarketOrder(action, symbol string, quantity float64, leverage int, clientOrderID string) (*OrderResult, error) {
+	t.record(clientOrderID)
+	return placeMarketOrder(t.SimulatedTrader, action, symbol, quantity, leverage, clientOrderID)
+}
+
+func (t *clientIDTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+	t.record(clientOrderID)
+	return t.SimulatedTrader.PlaceLimitOrder(symbol, positionSide, quantity, price, leverage, postOnly, clientOrderID)
+}
+
+func newOrderTestSim() *SimulatedTrader {
+	sim := NewSimulatedTrader(SimulatedTraderConfig{InitialBalance: 10000})
+	sim.SetPrice("BTCUSDT", 100)
+	return sim
+}
+
+func TestOrderManagerLifecycle(t *testing.T) {
+	sim := newOrderTestSim()
+	store := newMemOrderStore()
+	om := NewOrderManager("t1", "paper", sim, store)
+
+	open, err := om.OpenLong("BTCUSDT", 1, 5)
+	if err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if open.Status != OrderStatusFilled || open.ExchangeOrderID != "1" || open.FilledQty != 1 || open.AvgPrice != 100 {
+		t.Errorf("开仓订单状态错误: %+v", open)
+	}
+	sl, _ := om.SetStopLoss("BTCUSDT", "LONG", 1, 95)
+	om.SetTakeProfit("BTCUSDT", "LONG", 1, 120)
+
+	// 条件单仍在挂单列表中：状态不变
+	if changed := om.Reconcile(); len(changed) != 0 {
+		t.Errorf("条件单未变化时不应有更新: %+v", changed)
+	}
+
+	// 新止损替换旧止损
+	om.SetStopLoss("BTCUSDT", "LONG", 1, 97)
+	if got := store.records[sl.ClientOrderID]; got.Status != string(OrderStatusCancelled) {
+		t.Errorf("旧止损单应被替换: %+v", got)
+	}
+
+	// 交易所侧撤销了条件单但持仓仍在：标记为已撤销并告知AI
+	sim.CancelAllOrders("BTCUSDT")
+	changed := om.Reconcile()
+	if len(changed) != 2 {
+		t.Fatalf("应有2个条件单被撤销, got %+v", changed)
+	}
+	for _, o := range changed {
+		if o.Status != OrderStatusCancelled {
+			t.Errorf("条件单应为cancelled: %+v", o)
+		}
+	}
+	if notices := om.DrainNotices(); len(notices) != 2 || !strings.Contains(notices[0], "BTCUSDT") {
+		t.Errorf("应产生订单状态通知: %v", notices)
+	}
+
+	// 止损触发平仓后，剩余条件单失效
+	om.SetStopLoss("BTCUSDT", "LONG", 1, 95)
+	sim.OnBar("BTCUSDT", 100, 94, 94)
+	changed = om.Reconcile()
+	if len(changed) != 1 || changed[0].Status != OrderStatusExpired {
+		t.Errorf("持仓平仓后条件单应失效: %+v", changed)
+	}
+
+	// 拒单：保证金不足
+	rejected, err := om.OpenShort("BTCUSDT", 1000, 1)
+	if err == nil || rejected.Status != OrderStatusRejected || rejected.Error == "" {
+		t.Errorf("保证金不足应记录拒单: %+v", rejected)
+	}
+
+	orders, _ := om.Orders(OrderStatusRejected, 10)
+	if len(orders) != 1 || orders[0].ClientOrderID != rejected.ClientOrderID {
+		t.Errorf("应能按状态查询订单: %+v", orders)
+	}
+}
+
+func TestOrderManagerPartialFillAndRestore(t *testing.T) {
+	sim := newOrderTestSim()
+	store := newMemOrderStore()
+	om := NewOrderManager("t1", "binance", partialFillTrader{sim}, store)
+
+	// 模拟盘返回FILLED，这里手动构造一个未完结的订单以验证对账
+	order, _ := om.OpenLong("BTCUSDT", 1, 5)
+	om.mu.Lock()
+	om.orders[order.ClientOrderID].Status = OrderStatusNew
+	om.mu.Unlock()
+
+	changed := om.Reconcile()
+	if len(changed) != 1 || changed[0].Status != OrderStatusPartiallyFilled || changed[0].FilledQty != 0.4 || changed[0].Fee != 0.02 {
+		t.Fatalf("应同步部分成交: %+v", changed)
+	}
+
+	// 重启后从存储恢复未完结订单
+	restored := NewOrderManager("t1", "binance", partialFillTrader{sim}, store)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("恢复订单失败: %v", err)
+	}
+	if len(restored.orders) != 1 || restored.orders[order.ClientOrderID].FilledQty != 0.4 {
+		t.Errorf("应恢复未完结订单: %+v", restored.orders)
+	}
+}
+
+func TestApplyOrderResult(t *testing.T) {
+	order := &Order{Quantity: 0.5, Status: OrderStatusNew}
+	applyOrderResult(order, &OrderResult{OrderID: "42", Status: OrderStatusFilled, ExecutedQty: 0.5, AvgPrice: 100.5, Fee: 0.03})
+	if order.ExchangeOrderID != "42" || order.Status != OrderStatusFilled || order.FilledQty != 0.5 || order.AvgPrice != 100.5 || order.Fee != 0.03 {
+		t.Errorf("成交信息解析错误: %+v", order)
+	}
+
+	// 下单接口只返回订单ID时（如OKX）保持提交状态，等待对账
+	order = &Order{Quantity: 0.5, Status: OrderStatusNew}
+	applyOrderResult(order, &OrderResult{OrderID: "789"})
+	if order.ExchangeOrderID != "789" || order.Status != OrderStatusNew || order.FilledQty != 0 {
+		t.Errorf("未返回状态时不应改变订单状态: %+v", order)
+	}
+
+	// 已成交但未返回成交数量时按下单数量计
+	order = &Order{Quantity: 0.5, Status: OrderStatusNew}
+	applyOrderResult(order, &OrderResult{OrderID: "1", Status: OrderStatusFilled})
+	if order.FilledQty != 0.5 {
+		t.Errorf("成交数量应默认为下单数量: %+v", order)
+	}
+
+	for status, want := range map[string]OrderStatus{
+		"live": OrderStatusNew, "canceled": OrderStatusCancelled, "triggered": OrderStatusFilled,
+		"siblingFilledCanceled": OrderStatusCancelled, "perpMarginRejected": OrderStatusRejected,
+	} {
+		if got := parseOrderStatus(status); got != want {
+			t.Errorf("parseOrderStatus(%q) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestOrderManagerCancelProtectiveOrders(t *testing.T) {
+	sim := newOrderTestSim()
+	om := NewOrderManager("t1", "paper", sim, nil)
+
+	// 双向持仓：多仓和空仓各有条件单，另有一个限价开仓挂单
+	om.OpenLong("BTCUSDT", 1, 5)
+	om.OpenShort("BTCUSDT", 1, 5)
+	longSL, _ := om.SetStopLoss("BTCUSDT", "LONG", 1, 95)
+	om.SetTakeProfit("BTCUSDT", "LONG", 1, 120)
+	shortSL, _ := om.SetStopLoss("BTCUSDT", "SHORT", 1, 105)
+	if _, err := sim.PlaceLimitOrder("BTCUSDT", "LONG", 1, 90, 5, false, ""); err != nil {
+		t.Fatalf("限价挂单失败: %v", err)
+	}
+
+	if err := om.CancelProtectiveOrders("BTCUSDT", "LONG"); err != nil {
+		t.Fatalf("撤销条件单失败: %v", err)
+	}
+	opens, _ := sim.GetOpenOrders("BTCUSDT")
+	if len(opens) != 2 {
+		t.Fatalf("空仓止损和限价挂单应保留: %+v", opens)
+	}
+	for _, o := range opens {
+		if o.PositionSide == "LONG" && o.Type.IsProtective() {
+			t.Errorf("多仓条件单应被撤销: %+v", o)
+		}
+	}
+	om.mu.Lock()
+	longStatus, shortStatus := om.orders[longSL.ClientOrderID].Status, om.orders[shortSL.ClientOrderID].Status
+	om.mu.Unlock()
+	if longStatus != OrderStatusCancelled || shortStatus != OrderStatusNew {
+		t.Errorf("只应标记多仓条件单为已撤销: long=%s short=%s", longStatus, shortStatus)
+	}
+
+	// 跨交易所路由：分散到多个交易所的止损单一并撤销
+	router, binance, okx, _ := newTestRouter()
+	binance.OpenLong("BTCUSDT", 1, 5)
+	okx.OpenLong("BTCUSDT", 1, 5)
+	okx.SetStopLoss("BTCUSDT", "SHORT", 1, 110) // 无空仓，设置失败，不影响
+	mustPositions(t, router)
+	rom := NewOrderManager("t2", "router", router, nil)
+	if _, err := rom.SetStopLoss("BTCUSDT", "LONG", 2, 90); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	if err := rom.CancelProtectiveOrders("BTCUSDT", "LONG"); err != nil {
+		t.Fatalf("撤销条件单失败: %v", err)
+	}
+	if opens, _ := router.GetOpenOrders("BTCUSDT"); len(opens) != 0 {
+		t.Errorf("各交易所的止损单都应被撤销: %+v", opens)
+	}
+}
+
+func TestOrderManagerClientOrderID(t *testing.T) {
+	trader := &clientIDTrader{SimulatedTrader: newOrderTestSim()}
+	store := newMemOrderStore()
+	om := NewOrderManager("trader-with-a-long-id", "paper", trader, store)
+	trader.om = om
+
+	var orders []*Order
+	market, err := om.OpenLong("BTCUSDT", 1, 5)
+	if err != nil {
+		t.Fatalf("市价开仓失败: %v", err)
+	}
+	orders = append(orders, market)
+	exec, err := om.Open("BTCUSDT", "LONG", 1, 5, testExecutionConfig(decision.ExecutionLimitMid))
+	if err != nil {
+		t.Fatalf("限价开仓失败: %v", err)
+	}
+	orders = append(orders, exec.LastOrder())
+	closed, err := om.CloseLong("BTCUSDT", 0)
+	if err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	orders = append(orders, closed)
+
+	// 交易所收到的ID即本地订单ID，且下单时订单已登记
+	if len(trader.ids) != len(orders) {
+		t.Fatalf("交易所收到 %d 个订单ID, want %d", len(trader.ids), len(orders))
+	}
+	okxID := regexp.MustCompile(`^[A-Za-z0-9]{1,32}$`)
+	seen := make(map[string]bool)
+	for i, o := range orders {
+		if trader.ids[i] != o.ClientOrderID || !trader.tracked[i] {
+			t.Errorf("第%d笔订单: 交易所ID=%q 本地ID=%q 下单时已登记=%v", i+1, trader.ids[i], o.ClientOrderID, trader.tracked[i])
+		}
+		if rec, ok := store.records[o.ClientOrderID]; !ok || rec.Status != string(OrderStatusFilled) {
+			t.Errorf("订单 %s 未按同一ID保存为已成交: %+v", o.ClientOrderID, rec)
+		}
+		if !okxID.MatchString(o.ClientOrderID) || seen[o.ClientOrderID] {
+			t.Errorf("订单ID %q 不符合交易所格式或重复", o.ClientOrderID)
+		}
+		seen[o.ClientOrderID] = true
+
+		cloid := hyperliquidCloid(o.ClientOrderID)
+		if cloid == nil || !regexp.MustCompile(`^0x[0-9a-f]{32}$`).MatchString(*cloid) || !strings.HasSuffix(*cloid, o.ClientOrderID[2:]) {
+			t.Errorf("Hyperliquid cloid 转换错误: %s -> %v", o.ClientOrderID, cloid)
+		}
+	}
+	if cloid := hyperliquidCloid("legacy-id-1"); cloid == nil || len(*cloid) != 34 {
+		t.Errorf("非本地格式的ID应哈希为cloid: %v", cloid)
+	}
+	if hyperliquidCloid("") != nil {
+		t.Error("空ID不应设置cloid")
+	}
+}
+
+// 各交易所适配器都应随订单发送本地订单ID
+var (
+	_ ClientOrderPlacer = (*FuturesTrader)(nil)
+	_ ClientOrderPlacer = (*AsterTrader)(nil)
+	_ ClientOrderPlacer = (*OKXTrader)(nil)
+	_ ClientOrderPlacer = (*HyperliquidTrader)(nil)
+	_ ClientOrderPlacer = (*ExchangeRouter)(nil)
+	_ LimitOrderPlacer  = (*FuturesTrader)(nil)
+	_ LimitOrderPlacer  = (*AsterTrader)(nil)
+	_ LimitOrderPlacer  = (*OKXTrader)(nil)
+	_ LimitOrderPlacer  = (*HyperliquidTrader)(nil)
+	_ LimitOrderPlacer  = (*PaperTrader)(nil)
+)
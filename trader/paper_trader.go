@@ -0,0 +1,252 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/market"
+)
+
+// paperTriggerInterval 纸面交易检查止损/止盈/强平的间隔
+const paperTriggerInterval = 5 * time.Second
+
+// paperHistoryLimit 纸面账户在内存中保留的成交/资金费/平仓记录条数（长期运行时防止无限增长）
+const paperHistoryLimit = 1000
+
+// PaperTrader 纸面交易器
+// 使用 market.WSMonitorCli 的实时价格撮合，模拟手续费、滑点和强平，不需要任何交易所API密钥。
+// 账户状态保存在 system_config 中，重启后继续之前的纸面账户。
+// 后台触发检查随交易员启动（Start）和停止（Close），停止期间查询持仓/余额时仍按最新价格检查触发。
+type PaperTrader struct {
+	*SimulatedTrader
+
+	traderID string
+	db       *config.Database
+
+	watchMu sync.Mutex
+	running bool
+	stopCh  chan struct{}
+}
+
+// NewPaperTrader 创建纸面交易器
+func NewPaperTrader(traderID string, initialBalance float64, db *config.Database) *PaperTrader {
+	cfg := DefaultSimulatedTraderConfig(initialBalance)
+	cfg.MaxHistory = paperHistoryLimit
+	sim := NewSimulatedTrader(cfg)
+	sim.SetPriceSource(paperMarketPrice)
+
+	pt := &PaperTrader{
+		SimulatedTrader: sim,
+		traderID:        traderID,
+		db:              db,
+	}
+	pt.loadState()
+	return pt
+}
+
+// OpenLong 开多仓
+func (pt *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.OpenLong(symbol, quantity, leverage)
+}
+
+// OpenShort 开空仓
+func (pt *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.OpenShort(symbol, quantity, leverage)
+}
+
+// CloseLong 平多仓（quantity=0表示全部平仓）
+func (pt *PaperTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.CloseLong(symbol, quantity)
+}
+
+// CloseShort 平空仓（quantity=0表示全部平仓）
+func (pt *PaperTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.CloseShort(symbol, quantity)
+}
+
+// PlaceLimitOrder 下限价开仓单（立即成交时保存账户状态）
+func (pt *PaperTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.PlaceLimitOrder(symbol, positionSide, quantity, price, leverage, postOnly, clientOrderID)
+}
+
+// SpotBuy 市价买入现货
+func (pt *PaperTrader) SpotBuy(symbol string, quantity float64) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.SpotBuy(symbol, quantity)
+}
+
+// SpotSell 市价卖出现货
+func (pt *PaperTrader) SpotSell(symbol string, quantity float64) (*OrderResult, error) {
+	defer pt.saveState()
+	return pt.SimulatedTrader.SpotSell(symbol, quantity)
+}
+
+// SetLeverage 设置杠杆
+func (pt *PaperTrader) SetLeverage(symbol string, leverage int) error {
+	defer pt.saveState()
+	return pt.SimulatedTrader.SetLeverage(symbol, leverage)
+}
+
+// SetStopLoss 设置止损单
+func (pt *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	defer pt.saveState()
+	return pt.SimulatedTrader.SetStopLoss(symbol, positionSide, quantity, stopPrice)
+}
+
+// SetTakeProfit 设置止盈单
+func (pt *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	defer pt.saveState()
+	return pt.SimulatedTrader.SetTakeProfit(symbol, positionSide, quantity, takeProfitPrice)
+}
+
+// CancelAllOrders 取消该币种的所有挂单
+func (pt *PaperTrader) CancelAllOrders(symbol string) error {
+	defer pt.saveState()
+	return pt.SimulatedTrader.CancelAllOrders(symbol)
+}
+
+// CancelStopOrder 撤销止损/止盈条件单
+func (pt *PaperTrader) CancelStopOrder(order *Order) error {
+	defer pt.saveState()
+	return pt.SimulatedTrader.CancelStopOrder(order)
+}
+
+// GetPositions 获取所有持仓（先用最新价格刷新标记价格并检查触发）
+func (pt *PaperTrader) GetPositions() ([]Position, error) {
+	pt.checkTriggers()
+	return pt.SimulatedTrader.GetPositions()
+}
+
+// GetBalance 获取账户余额（先用最新价格刷新标记价格并检查触发）
+func (pt *PaperTrader) GetBalance() (*Balance, error) {
+	pt.checkTriggers()
+	return pt.SimulatedTrader.GetBalance()
+}
+
+// Start 启动后台触发检查（实现BackgroundTrader，可重复调用）
+func (pt *PaperTrader) Start() {
+	pt.watchMu.Lock()
+	defer pt.watchMu.Unlock()
+	if pt.running {
+		return
+	}
+	pt.running = true
+	pt.stopCh = make(chan struct{})
+	go pt.watchTriggers(pt.stopCh)
+}
+
+// Close 停止后台触发检查（实现BackgroundTrader，可重复调用，停止后可再次Start）
+func (pt *PaperTrader) Close() {
+	pt.watchMu.Lock()
+	defer pt.watchMu.Unlock()
+	if !pt.running {
+		return
+	}
+	pt.running = false
+	close(pt.stopCh)
+}
+
+// watchTriggers 定期用实时价格检查止损、止盈和强平
+func (pt *PaperTrader) watchTriggers(stopCh chan struct{}) {
+	ticker := time.NewTicker(paperTriggerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			pt.checkTriggers()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// checkTriggers 用最新价格推进所有持仓，触发止损/止盈/强平时保存状态
+func (pt *PaperTrader) checkTriggers() {
+	symbols := pt.HeldSymbols()
+	if len(symbols) == 0 {
+		return
+	}
+
+	fillsBefore := pt.FillCount()
+	for _, symbol := range symbols {
+		price, err := paperMarketPrice(symbol)
+		if err != nil || price <= 0 {
+			continue
+		}
+		pt.OnBar(symbol, price, price, price)
+	}
+
+	fills := pt.FillsSince(fillsBefore)
+	if len(fills) == 0 {
+		return
+	}
+	for _, fill := range fills {
+		log.Printf("📄 [纸面交易 %s] %s %s 触发%s: 数量 %.6f @ %.6f, 盈亏 %.2f USDT",
+			pt.traderID, fill.Symbol, fill.Action, fill.Reason, fill.Quantity, fill.Price, fill.RealizedPnL)
+	}
+	pt.saveState()
+}
+
+// stateKey 纸面账户状态在 system_config 中的键
+func (pt *PaperTrader) stateKey() string {
+	return fmt.Sprintf("paper_trader_%s_state", pt.traderID)
+}
+
+// loadState 从数据库恢复纸面账户
+func (pt *PaperTrader) loadState() {
+	if pt.db == nil {
+		return
+	}
+
+	data, err := pt.db.GetSystemConfig(pt.stateKey())
+	if err != nil || data == "" {
+		return
+	}
+
+	var state SimulatedState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		log.Printf("⚠️ [纸面交易 %s] 解析账户状态失败: %v，使用初始资金重新开始", pt.traderID, err)
+		return
+	}
+	pt.Restore(state)
+	log.Printf("📄 [纸面交易 %s] 已恢复账户: 余额 %.2f USDT, 持仓 %d 个", pt.traderID, state.Balance, len(state.Positions))
+}
+
+// saveState 保存纸面账户到数据库
+func (pt *PaperTrader) saveState() {
+	if pt.db == nil {
+		return
+	}
+
+	data, err := json.Marshal(pt.Snapshot())
+	if err != nil {
+		log.Printf("⚠️ [纸面交易 %s] 序列化账户状态失败: %v", pt.traderID, err)
+		return
+	}
+	if err := pt.db.SetSystemConfig(pt.stateKey(), string(data)); err != nil {
+		log.Printf("⚠️ [纸面交易 %s] 保存账户状态失败: %v", pt.traderID, err)
+	}
+}
+
+// paperMarketPrice 从WebSocket行情缓存获取最新价格，缓存不可用时回退到REST接口
+func paperMarketPrice(symbol string) (float64, error) {
+	symbol = market.Normalize(symbol)
+	if market.WSMonitorCli != nil {
+		// GetCurrentKlines 在首次订阅时会同时返回K线和错误，只要有K线就使用
+		klines, _ := market.WSMonitorCli.GetCurrentKlines(symbol, "3m")
+		if len(klines) > 0 && klines[len(klines)-1].Close > 0 {
+			return klines[len(klines)-1].Close, nil
+		}
+	}
+	return market.NewAPIClient().GetCurrentPrice(symbol)
+}
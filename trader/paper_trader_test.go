@@ -0,0 +1,119 @@
+package trader
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"nofx/config"
+)
+
+func TestPaperTraderStateRoundTrip(t *testing.T) {
+	pt := NewPaperTrader("paper_test", 1000, nil)
+	defer pt.Close()
+	pt.SetPriceSource(nil)
+	pt.SetPrice("BTCUSDT", 100)
+
+	if _, err := pt.OpenLong("BTCUSDT", 2, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := pt.SetStopLoss("BTCUSDT", "LONG", 2, 95); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	pt.SetPrice("ETHUSDT", 10)
+	limit, err := pt.PlaceLimitOrder("ETHUSDT", "LONG", 5, 9, 3, true, "")
+	if err != nil {
+		t.Fatalf("挂限价单失败: %v", err)
+	}
+
+	data, err := json.Marshal(pt.Snapshot())
+	if err != nil {
+		t.Fatalf("序列化账户状态失败: %v", err)
+	}
+
+	var state SimulatedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("解析账户状态失败: %v", err)
+	}
+
+	restored := NewPaperTrader("paper_test", 1000, nil)
+	defer restored.Close()
+	restored.SetPriceSource(nil)
+	restored.SetPrice("BTCUSDT", 100)
+	restored.SetPrice("ETHUSDT", 10)
+	restored.Restore(state)
+
+	if !approxEqual(restored.Equity(), pt.Equity()) {
+		t.Errorf("恢复后净值应一致: %v vs %v", restored.Equity(), pt.Equity())
+	}
+	if symbols := restored.HeldSymbols(); len(symbols) != 2 || symbols[0] != "BTCUSDT" || symbols[1] != "ETHUSDT" {
+		t.Fatalf("恢复后应持有BTCUSDT并保留ETHUSDT挂单: %v", symbols)
+	}
+
+	// 恢复的限价挂单在价格穿过委托价时成交
+	restored.OnBar("ETHUSDT", 10, 8.5, 9.5)
+	if order, err := restored.QueryOrder("ETHUSDT", limit.OrderID); err != nil || order.Status != OrderStatusFilled || order.AvgPrice != 9 {
+		t.Fatalf("恢复的限价单应按委托价成交: %+v, %v", order, err)
+	}
+	next, err := restored.PlaceLimitOrder("ETHUSDT", "LONG", 1, 8, 3, true, "")
+	if err != nil {
+		t.Fatalf("恢复后挂限价单失败: %v", err)
+	}
+	prevID, _ := strconv.ParseInt(limit.OrderID, 10, 64)
+	if id, _ := strconv.ParseInt(next.OrderID, 10, 64); id <= prevID {
+		t.Errorf("恢复后新订单ID不应与挂单重复: %s", next.OrderID)
+	}
+
+	// 恢复的止损仍然生效
+	restored.OnBar("BTCUSDT", 100, 94, 96)
+	trades := restored.GetClosedTrades()
+	if len(trades) != 1 || trades[0].Reason != "stop_loss" {
+		t.Errorf("恢复的止损应触发: %+v", trades)
+	}
+}
+
+func TestNewExchangeTraderRespectsFeatureFlag(t *testing.T) {
+	flags := config.NewFeatureFlagManager()
+	prev := config.GetGlobalFeatureFlags()
+	config.SetGlobalFeatureFlags(flags)
+	t.Cleanup(func() { config.SetGlobalFeatureFlags(prev) })
+
+	cfg := AutoTraderConfig{ID: "paper_flag", UserID: "user-1", Name: "paper", Exchange: "paper", InitialBalance: 1000}
+	flags.SaveFlag(config.FeatureFlag{Name: config.ExchangePaperEnabled, Enabled: true, Percentage: 0, AllowUsers: []string{"user-2"}})
+	if _, err := newExchangeTrader(cfg); err == nil || !strings.Contains(err.Error(), string(config.ExchangePaperEnabled)) {
+		t.Fatalf("纸面交易未对user-1开放时应拒绝创建: %v", err)
+	}
+
+	cfg.UserID = "user-2"
+	trader, err := newExchangeTrader(cfg)
+	if err != nil {
+		t.Fatalf("白名单用户应允许创建: %v", err)
+	}
+	trader.(*PaperTrader).Close()
+}
+
+func TestPaperTraderWatcherFollowsAutoTrader(t *testing.T) {
+	pt := NewPaperTrader("paper_watch", 1000, nil)
+	defer pt.Close()
+	if pt.running {
+		t.Fatal("创建纸面交易器时不应启动后台检查")
+	}
+
+	at := &AutoTrader{
+		trader:           pt,
+		orderManager:     NewOrderManager("paper_watch", "paper", pt, nil),
+		liquidationGuard: NewLiquidationGuard("paper_watch", DefaultLiquidationGuardConfig(), pt),
+	}
+	pt.Start()
+	at.Stop()
+	if pt.running {
+		t.Error("交易员停止时应停止纸面交易后台检查")
+	}
+
+	// 停止后可再次启动（交易员重新Run）
+	pt.Start()
+	if !pt.running {
+		t.Error("应能重新启动后台检查")
+	}
+}
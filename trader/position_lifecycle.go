@@ -0,0 +1,426 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/database"
+)
+
+// Fill 成交明细（各交易所统一口径）
+type Fill struct {
+	OrderID      string
+	Symbol       string
+	Side         string // buy / sell
+	PositionSide string // LONG / SHORT（单向持仓模式或交易所未返回时为空）
+	Quantity     float64
+	Price        float64
+	Fee          float64 // 手续费（USDT，正数为支付）
+	RealizedPnL  float64 // 平仓盈亏（交易所未返回时为0）
+	Reason       string  // 触发原因（stop_loss / take_profit / liquidation，未知时为空）
+	Time         time.Time
+}
+
+// FillHistoryProvider 可查询成交历史的交易所（可选实现）
+type FillHistoryProvider interface {
+	// GetFillHistory 获取since之后该币种的成交记录（按时间正序）
+	GetFillHistory(symbol string, since time.Time) ([]Fill, error)
+}
+
+// FundingHistoryProvider 可查询资金费结算的交易所（可选实现）
+type FundingHistoryProvider interface {
+	// GetFundingPayments 获取since之后该币种支付的资金费合计（USDT，负数为收取）
+	GetFundingPayments(symbol string, since time.Time) (float64, error)
+}
+
+// PositionEvent 持仓生命周期事件（开仓、平仓或部分平仓）
+type PositionEvent struct {
+	Type        string // open / close
+	Symbol      string
+	Side        string // long / short
+	Quantity    float64
+	EntryPrice  float64
+	ExitPrice   float64
+	Leverage    int
+	OpenTime    time.Time
+	CloseTime   time.Time
+	RealizedPnL float64 // 价格盈亏（不含费用）
+	Fees        float64 // 开平仓手续费
+	Funding     float64 // 持仓期间支付的资金费
+	Reason      string  // decision / stop_loss / take_profit / liquidation / exchange
+}
+
+// NetPnL 扣除手续费和资金费后的净盈亏
+func (e PositionEvent) NetPnL() float64 {
+	return e.RealizedPnL - e.Fees - e.Funding
+}
+
+// HoldingTime 持仓时长
+func (e PositionEvent) HoldingTime() time.Duration {
+	if e.OpenTime.IsZero() || e.CloseTime.Before(e.OpenTime) {
+		return 0
+	}
+	return e.CloseTime.Sub(e.OpenTime)
+}
+
+// MarginReturnPct 净盈亏占保证金的百分比（与回测和TradeAnalyzer口径一致）
+func (e PositionEvent) MarginReturnPct() float64 {
+	leverage := e.Leverage
+	if leverage <= 0 {
+		leverage = 1
+	}
+	margin := e.Quantity * e.EntryPrice / float64(leverage)
+	if margin <= 0 {
+		return 0
+	}
+	return e.NetPnL() / margin * 100
+}
+
+// PriceMovePct 价格变动比例（小数，空仓取反，凯利统计使用该口径）
+func (e PositionEvent) PriceMovePct() float64 {
+	if e.EntryPrice <= 0 {
+		return 0
+	}
+	move := (e.ExitPrice - e.EntryPrice) / e.EntryPrice
+	if e.Side == "short" {
+		move = -move
+	}
+	return move
+}
+
+// PositionListener 接收平仓事件（学习协调器等，需并发安全）
+type PositionListener interface {
+	OnPositionClosed(traderID string, event PositionEvent)
+}
+
+// trackedPosition 跟踪中的持仓
+type trackedPosition struct {
+	Position
+	openTime  time.Time
+	lastSeen  time.Time
+	settledAt time.Time // 已计入平仓事件的手续费/资金费截止时间（部分平仓后避免重复计算）
+}
+
+// PositionLifecycleTracker 持仓生命周期跟踪器
+// 对比相邻两次持仓快照识别开仓和平仓（包括交易所侧止损止盈、强平），
+// 交易所支持时用成交记录补全真实的平仓价、手续费和资金费
+type PositionLifecycleTracker struct {
+	mu          sync.Mutex
+	traderID    string
+	trader      Trader
+	positions   map[string]*trackedPosition // key: symbol_side
+	closing     map[string]string           // 本系统主动平仓的原因（key: symbol_side）
+	initialized bool
+	now         func() time.Time
+}
+
+// NewPositionLifecycleTracker 创建持仓生命周期跟踪器
+func NewPositionLifecycleTracker(traderID string, t Trader) *PositionLifecycleTracker {
+	return &PositionLifecycleTracker{
+		traderID:  traderID,
+		trader:    t,
+		positions: make(map[string]*trackedPosition),
+		closing:   make(map[string]string),
+		now:       time.Now,
+	}
+}
+
+// MarkClosing 标记本系统主动平仓（下一次快照中消失的持仓按该原因记录）
+func (pt *PositionLifecycleTracker) MarkClosing(symbol, side, reason string) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.closing[symbol+"_"+side] = reason
+}
+
+// Observe 对比持仓快照，返回新开仓、平仓和部分平仓事件
+// 第一次调用只建立基线（启动前已有的持仓不产生开仓事件，平仓时照常记录）
+func (pt *PositionLifecycleTracker) Observe(positions []Position) []PositionEvent {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := pt.now()
+	current := make(map[string]Position, len(positions))
+	for _, pos := range positions {
+		if pos.Symbol == "" || pos.Side == "" || pos.Quantity <= 0 {
+			continue
+		}
+		current[pos.Symbol+"_"+pos.Side] = pos
+	}
+
+	if !pt.initialized {
+		pt.initialized = true
+		for key, pos := range current {
+			pt.positions[key] = newTrackedPosition(pos, now)
+		}
+		return nil
+	}
+
+	var events []PositionEvent
+	for key, tracked := range pt.positions {
+		pos, ok := current[key]
+		switch {
+		case !ok:
+			events = append(events, pt.closeEventLocked(key, tracked, tracked.Quantity, now))
+			delete(pt.positions, key)
+		case pos.Quantity < tracked.Quantity*(1-1e-6):
+			// 部分平仓：剩余仓位继续跟踪
+			events = append(events, pt.closeEventLocked(key, tracked, tracked.Quantity-pos.Quantity, now))
+			tracked.update(pos, now)
+		default:
+			tracked.update(pos, now)
+		}
+	}
+
+	for key, pos := range current {
+		if _, ok := pt.positions[key]; ok {
+			continue
+		}
+		tracked := newTrackedPosition(pos, now)
+		pt.positions[key] = tracked
+		events = append(events, PositionEvent{
+			Type:       "open",
+			Symbol:     pos.Symbol,
+			Side:       pos.Side,
+			Quantity:   pos.Quantity,
+			EntryPrice: pos.EntryPrice,
+			Leverage:   pos.Leverage,
+			OpenTime:   tracked.openTime,
+		})
+	}
+	return events
+}
+
+// closeEventLocked 生成平仓事件，优先使用成交记录中的平仓价、手续费和已实现盈亏（调用方需持有锁）
+func (pt *PositionLifecycleTracker) closeEventLocked(key string, tracked *trackedPosition, quantity float64, now time.Time) PositionEvent {
+	event := PositionEvent{
+		Type:       "close",
+		Symbol:     tracked.Symbol,
+		Side:       tracked.Side,
+		Quantity:   quantity,
+		EntryPrice: tracked.EntryPrice,
+		ExitPrice:  tracked.MarkPrice, // 无成交记录时按最后一次看到的标记价格估算
+		Leverage:   tracked.Leverage,
+		OpenTime:   tracked.openTime,
+		CloseTime:  now,
+	}
+
+	sign := 1.0
+	if tracked.Side == "short" {
+		sign = -1.0
+	}
+	pnlFromFills := false
+	if provider, ok := pt.trader.(FillHistoryProvider); ok {
+		fills, err := provider.GetFillHistory(tracked.Symbol, tracked.openTime.Add(-time.Minute))
+		if err != nil {
+			log.Printf("⚠️ [%s] 获取 %s 成交记录失败: %v", pt.traderID, tracked.Symbol, err)
+		}
+		closeSide := "sell"
+		if tracked.Side == "short" {
+			closeSide = "buy"
+		}
+		var closedQty, closedValue, realized float64
+		var lastFill time.Time
+		for _, fill := range fills {
+			if fill.PositionSide != "" && !strings.EqualFold(fill.PositionSide, tracked.Side) {
+				continue
+			}
+			// 开仓和平仓手续费都计入本笔交易（部分平仓已计入的成交不再重复）
+			if !fill.Time.After(tracked.settledAt) {
+				continue
+			}
+			event.Fees += fill.Fee
+			if fill.Side != closeSide || fill.Time.Before(tracked.lastSeen.Add(-time.Minute)) {
+				continue
+			}
+			closedQty += fill.Quantity
+			closedValue += fill.Quantity * fill.Price
+			realized += fill.RealizedPnL
+			if fill.Time.After(lastFill) {
+				lastFill = fill.Time
+			}
+			if fill.Reason != "" && fill.Reason != "order" {
+				event.Reason = fill.Reason
+			}
+		}
+		if closedQty > 0 {
+			event.ExitPrice = closedValue / closedQty
+			event.CloseTime = lastFill
+			if realized != 0 {
+				event.RealizedPnL, pnlFromFills = realized, true
+			}
+		}
+	}
+	if !pnlFromFills {
+		event.RealizedPnL = sign * (event.ExitPrice - event.EntryPrice) * quantity
+	}
+
+	if provider, ok := pt.trader.(FundingHistoryProvider); ok {
+		since := tracked.openTime
+		if tracked.settledAt.After(since) {
+			since = tracked.settledAt
+		}
+		funding, err := provider.GetFundingPayments(tracked.Symbol, since)
+		if err != nil {
+			log.Printf("⚠️ [%s] 获取 %s 资金费失败: %v", pt.traderID, tracked.Symbol, err)
+		}
+		event.Funding = funding
+	}
+
+	tracked.settledAt = event.CloseTime
+
+	if reason, ok := pt.closing[key]; ok {
+		event.Reason = reason
+		delete(pt.closing, key)
+	} else if event.Reason == "" {
+		event.Reason = inferCloseReason(tracked.Position, event.ExitPrice)
+	}
+	return event
+}
+
+// inferCloseReason 根据平仓价与止损/止盈/强平价的距离推断交易所侧平仓原因
+func inferCloseReason(pos Position, exitPrice float64) string {
+	near := func(level float64) bool {
+		return level > 0 && exitPrice > 0 && math.Abs(exitPrice-level)/level < 0.005
+	}
+	switch {
+	case near(pos.LiquidationPrice):
+		return "liquidation"
+	case near(pos.StopLoss):
+		return "stop_loss"
+	case near(pos.TakeProfit):
+		return "take_profit"
+	}
+	return "exchange"
+}
+
+// newTrackedPosition 开始跟踪持仓（交易所未提供开仓时间时以首次看到的时间为准）
+func newTrackedPosition(pos Position, now time.Time) *trackedPosition {
+	openTime := pos.OpenTime
+	if openTime.IsZero() {
+		openTime = now
+	}
+	return &trackedPosition{Position: pos, openTime: openTime, lastSeen: now}
+}
+
+// update 更新持仓快照（止损止盈价缺失时保留上一次的值）
+func (t *trackedPosition) update(pos Position, now time.Time) {
+	if pos.StopLoss <= 0 {
+		pos.StopLoss = t.StopLoss
+	}
+	if pos.TakeProfit <= 0 {
+		pos.TakeProfit = t.TakeProfit
+	}
+	t.Position = pos
+	t.lastSeen = now
+}
+
+// String 事件描述（日志）
+func (e PositionEvent) String() string {
+	if e.Type == "open" {
+		return fmt.Sprintf("%s %s 开仓 %.4f @ %.6f", e.Symbol, e.Side, e.Quantity, e.EntryPrice)
+	}
+	return fmt.Sprintf("%s %s 平仓(%s) %.4f @ %.6f → %.6f, 盈亏 %.2f, 手续费 %.2f, 资金费 %.2f, 净盈亏 %.2f, 持仓 %s",
+		e.Symbol, e.Side, e.Reason, e.Quantity, e.EntryPrice, e.ExitPrice,
+		e.RealizedPnL, e.Fees, e.Funding, e.NetPnL(), e.HoldingTime().Round(time.Second))
+}
+
+// AddPositionListener 注册平仓事件监听者（需在Run之前调用）
+func (at *AutoTrader) AddPositionListener(listener PositionListener) {
+	at.positionListeners = append(at.positionListeners, listener)
+}
+
+// trackPositionLifecycle 对比持仓快照，将平仓结果写入凯利统计、交易记录，并通知风控闸门和监听者
+func (at *AutoTrader) trackPositionLifecycle() {
+	if at.lifecycle == nil {
+		return
+	}
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠️ [%s] 持仓生命周期跟踪获取持仓失败: %v", at.name, err)
+		return
+	}
+	// 交易所未返回止损止盈价时用本地挂单补全（用于推断平仓原因）
+	if at.orderManager != nil {
+		for i := range positions {
+			posSide := strings.ToUpper(positions[i].Side)
+			if positions[i].StopLoss <= 0 {
+				positions[i].StopLoss = at.orderManager.ActiveStopPrice(positions[i].Symbol, posSide, OrderTypeStopMarket)
+			}
+			if positions[i].TakeProfit <= 0 {
+				positions[i].TakeProfit = at.orderManager.ActiveStopPrice(positions[i].Symbol, posSide, OrderTypeTakeProfitMarket)
+			}
+		}
+	}
+
+	for _, event := range at.lifecycle.Observe(positions) {
+		if event.Type == "open" {
+			log.Printf("📥 [%s] %s", at.name, event)
+			continue
+		}
+		log.Printf("📤 [%s] %s", at.name, event)
+		at.recordClosedPosition(event)
+	}
+}
+
+// recordClosedPosition 记录一笔平仓（凯利统计、交易记录、风控闸门和监听者）
+func (at *AutoTrader) recordClosedPosition(event PositionEvent) {
+	if at.kellyManager != nil {
+		at.recordTradeResult(event.Symbol, event.NetPnL() > 0, event.PriceMovePct())
+	}
+
+	if at.db != nil {
+		marginMode := "isolated"
+		if at.config.IsCrossMargin {
+			marginMode = "cross"
+		}
+		record := database.TradeRecord{
+			TraderID:           at.id,
+			Symbol:             event.Symbol,
+			Side:               event.Side,
+			EntryPrice:         event.EntryPrice,
+			ExitPrice:          event.ExitPrice,
+			Quantity:           event.Quantity,
+			ProfitPct:          event.MarginReturnPct(),
+			Leverage:           event.Leverage,
+			HoldingTimeSeconds: int64(event.HoldingTime().Seconds()),
+			MarginMode:         marginMode,
+			RealizedPnL:        event.RealizedPnL,
+			Fees:               event.Fees,
+			Funding:            event.Funding,
+			CloseReason:        event.Reason,
+			CreatedAt:          event.CloseTime,
+		}
+		if err := at.db.SaveTradeRecord(record); err != nil {
+			log.Printf("⚠️ [%s] 保存交易记录失败: %v", at.name, err)
+		}
+	}
+
+	// 决策平仓已在执行后通知风控闸门，这里只补充交易所侧的平仓
+	if event.Reason != "decision" {
+		trade := ClosedTrade{Symbol: event.Symbol, Side: event.Side, PnL: event.NetPnL()}
+		if balance, err := at.trader.GetBalance(); err == nil && balance.TotalEquity() > 0 {
+			trade.Equity = balance.TotalEquity()
+			trade.PnLPct = trade.PnL / trade.Equity * 100
+		}
+		for _, gate := range at.riskGates {
+			if observer, ok := gate.(RiskTradeObserver); ok {
+				observer.OnTradeClosed(trade)
+			}
+		}
+	}
+
+	if memory := at.activeMemory(); memory != nil {
+		go memory.RecordOutcome(event)
+	}
+
+	for _, listener := range at.positionListeners {
+		listener.OnPositionClosed(at.id, event)
+	}
+}
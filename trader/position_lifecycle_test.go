@@ -0,0 +1,193 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/decision"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// newLifecycleTestTrader 使用可推进时钟的模拟交易所和跟踪器
+func newLifecycleTestTrader() (*SimulatedTrader, *PositionLifecycleTracker, *time.Time) {
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	sim := newTestSimulatedTrader()
+	sim.SetClock(clock)
+	tracker := NewPositionLifecycleTracker("t1", sim)
+	tracker.now = clock
+	return sim, tracker, &now
+}
+
+// observe 读取模拟交易所持仓并交给跟踪器
+func observe(t *testing.T, sim *SimulatedTrader, tracker *PositionLifecycleTracker) []PositionEvent {
+	t.Helper()
+	positions, err := sim.GetPositions()
+	if err != nil {
+		t.Fatalf("获取持仓失败: %v", err)
+	}
+	return tracker.Observe(positions)
+}
+
+func TestPositionLifecycleStopLossFromFills(t *testing.T) {
+	sim, tracker, now := newLifecycleTestTrader()
+	if events := observe(t, sim, tracker); len(events) != 0 {
+		t.Fatalf("首次快照只建立基线: %+v", events)
+	}
+
+	sim.SetPrice("BTCUSDT", 100)
+	if _, err := sim.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := sim.SetStopLoss("BTCUSDT", "LONG", 1, 95); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	*now = now.Add(time.Hour)
+	events := observe(t, sim, tracker)
+	if len(events) != 1 || events[0].Type != "open" || events[0].EntryPrice != 100 {
+		t.Fatalf("应产生开仓事件: %+v", events)
+	}
+
+	sim.ApplyFunding("BTCUSDT", 0.001)
+	*now = now.Add(2 * time.Hour)
+	sim.OnBar("BTCUSDT", 100, 94, 94)
+
+	*now = now.Add(time.Hour)
+	events = observe(t, sim, tracker)
+	if len(events) != 1 || events[0].Type != "close" {
+		t.Fatalf("交易所侧止损应产生平仓事件: %+v", events)
+	}
+	e := events[0]
+	if e.Reason != "stop_loss" || e.ExitPrice != 95 || !approxEqual(e.RealizedPnL, -5) {
+		t.Errorf("应使用成交记录的平仓价和原因: %+v", e)
+	}
+	// 开仓手续费0.1 + 平仓手续费0.095，资金费0.1
+	if !approxEqual(e.Fees, 0.195) || !approxEqual(e.Funding, 0.1) || !approxEqual(e.NetPnL(), -5.295) {
+		t.Errorf("手续费或资金费错误: %+v", e)
+	}
+	if e.HoldingTime() != 3*time.Hour {
+		t.Errorf("持仓时长应按开仓到止损成交计算, got %v", e.HoldingTime())
+	}
+	if !approxEqual(e.PriceMovePct(), -0.05) || !approxEqual(e.MarginReturnPct(), -5.295/20*100) {
+		t.Errorf("收益率口径错误: move=%v margin=%v", e.PriceMovePct(), e.MarginReturnPct())
+	}
+}
+
+func TestPositionLifecyclePartialAndDecisionClose(t *testing.T) {
+	sim, tracker, now := newLifecycleTestTrader()
+	sim.SetPrice("ETHUSDT", 100)
+	if _, err := sim.OpenShort("ETHUSDT", 2, 10); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	observe(t, sim, tracker)
+
+	// 部分平仓：剩余仓位继续跟踪
+	*now = now.Add(time.Hour)
+	sim.SetPrice("ETHUSDT", 90)
+	if _, err := sim.CloseShort("ETHUSDT", 1); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	events := observe(t, sim, tracker)
+	if len(events) != 1 || events[0].Quantity != 1 || events[0].ExitPrice != 90 || !approxEqual(events[0].RealizedPnL, 10) {
+		t.Fatalf("应产生部分平仓事件: %+v", events)
+	}
+	if !approxEqual(events[0].Fees, 0.2+0.09) {
+		t.Errorf("首次平仓应计入开仓手续费: %+v", events[0])
+	}
+
+	// 决策平仓剩余仓位：开仓手续费不重复计入
+	*now = now.Add(time.Hour)
+	sim.SetPrice("ETHUSDT", 80)
+	tracker.MarkClosing("ETHUSDT", "short", "decision")
+	if _, err := sim.CloseShort("ETHUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+	events = observe(t, sim, tracker)
+	if len(events) != 1 || events[0].Reason != "decision" || events[0].ExitPrice != 80 {
+		t.Fatalf("应按决策平仓记录: %+v", events)
+	}
+	if !approxEqual(events[0].Fees, 0.08) || !approxEqual(events[0].RealizedPnL, 20) {
+		t.Errorf("部分平仓后手续费不应重复计算: %+v", events[0])
+	}
+}
+
+// recordingPositionListener 记录平仓事件
+type recordingPositionListener struct {
+	events []PositionEvent
+}
+
+func (l *recordingPositionListener) OnPositionClosed(traderID string, event PositionEvent) {
+	l.events = append(l.events, event)
+}
+
+func TestAutoTraderTrackPositionLifecycle(t *testing.T) {
+	sim, tracker, now := newLifecycleTestTrader()
+	listener := &recordingPositionListener{}
+	at := &AutoTrader{
+		id:           "t1",
+		name:         "test",
+		trader:       sim,
+		kellyManager: decision.NewKellyStopManager(),
+		lifecycle:    tracker,
+	}
+	at.AddPositionListener(listener)
+	at.trackPositionLifecycle()
+
+	sim.SetPrice("SOLUSDT", 100)
+	if _, err := sim.OpenLong("SOLUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := sim.SetTakeProfit("SOLUSDT", "LONG", 1, 110); err != nil {
+		t.Fatalf("设置止盈失败: %v", err)
+	}
+	at.trackPositionLifecycle()
+
+	*now = now.Add(time.Hour)
+	sim.OnBar("SOLUSDT", 111, 100, 110)
+	at.trackPositionLifecycle()
+
+	if len(listener.events) != 1 || listener.events[0].Reason != "take_profit" {
+		t.Fatalf("止盈平仓应通知监听者: %+v", listener.events)
+	}
+	stats := at.kellyManager.GetHistoricalStats("SOLUSDT")
+	if stats == nil || stats.TotalTrades != 1 || stats.ProfitableTrades != 1 || !approxEqual(stats.AvgWinPct, 0.1) {
+		t.Errorf("凯利统计应按实际成交记录: %+v", stats)
+	}
+}
+
+func TestListBinanceTradesPagesByWindow(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(20 * 24 * time.Hour)
+
+	// 第一天有1001笔成交（超过单页上限），第15天有1笔
+	var all []*futures.AccountTrade
+	for i := 0; i <= binanceTradesLimit; i++ {
+		all = append(all, &futures.AccountTrade{ID: int64(i + 1), Time: since.Add(time.Duration(i) * time.Second).UnixMilli()})
+	}
+	all = append(all, &futures.AccountTrade{ID: 5000, Time: since.Add(15 * 24 * time.Hour).UnixMilli()})
+
+	var windows [][2]int64
+	trades, err := listBinanceTrades(since, until, func(start, end int64) ([]*futures.AccountTrade, error) {
+		if end-start > 7*24*time.Hour.Milliseconds() {
+			t.Fatalf("时间窗口超过7天: %d-%d", start, end)
+		}
+		windows = append(windows, [2]int64{start, end})
+		var page []*futures.AccountTrade
+		for _, trade := range all {
+			if trade.Time >= start && trade.Time <= end && len(page) < binanceTradesLimit {
+				page = append(page, trade)
+			}
+		}
+		return page, nil
+	})
+	if err != nil {
+		t.Fatalf("分页获取失败: %v", err)
+	}
+	if len(trades) != len(all) {
+		t.Fatalf("成交数 = %d, want %d", len(trades), len(all))
+	}
+	if last := windows[len(windows)-1]; last[1] != until.UnixMilli() {
+		t.Fatalf("最后一个窗口应截止到until: %v", last)
+	}
+}
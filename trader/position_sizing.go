@@ -0,0 +1,254 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+)
+
+// 仓位计算方式
+const (
+	SizingModeOff       = "off"       // 直接使用AI给出的仓位
+	SizingModeClamp     = "clamp"     // AI给出的仓位作为上限，再按风险预算/凯利比例/币种配置压缩
+	SizingModeRecompute = "recompute" // 忽略AI给出的仓位，按风险预算重新计算（AI只决定方向和信心度）
+)
+
+// 最终仓位的决定因素（记录到决策日志）
+const (
+	SizingMethodAIRequest     = "ai_request"     // AI原始请求
+	SizingMethodRiskBudget    = "risk_budget"    // 单笔风险预算 ÷ 止损距离
+	SizingMethodATRTarget     = "atr_target"     // 单笔风险预算 ÷ ATR止损距离（波动率目标）
+	SizingMethodSymbolDefault = "symbol_default" // 币种默认仓位（无止损和ATR数据时）
+	SizingMethodSymbolCap     = "symbol_cap"     // 币种最大仓位
+	SizingMethodKellyCap      = "kelly_cap"      // 凯利比例上限
+)
+
+// minConvictionScale 信心度对仓位的最小缩放比例
+const minConvictionScale = 0.5
+
+// SizingConfig 仓位计算配置
+type SizingConfig struct {
+	Mode            string  // off / clamp / recompute
+	RiskPerTradePct float64 // 单笔风险预算（止损时亏损占账户净值的百分比）
+	ATRStopMultiple float64 // 按ATR估算止损距离的倍数（止损比ATR×倍数更近时按ATR计算）
+	KellyFraction   float64 // 凯利比例的使用系数（保证金上限 = 净值 × 凯利比例 × 系数）
+}
+
+// DefaultSizingConfig 默认仓位计算配置
+func DefaultSizingConfig() SizingConfig {
+	return SizingConfig{
+		Mode:            SizingModeRecompute,
+		RiskPerTradePct: 1,
+		ATRStopMultiple: 1.5,
+		KellyFraction:   1,
+	}
+}
+
+// LoadSizingConfig 从系统配置加载仓位计算配置（riskPerTradePct>0时覆盖系统默认的单笔风险预算）
+func LoadSizingConfig(db *config.Database, riskPerTradePct float64) SizingConfig {
+	cfg := DefaultSizingConfig()
+	if db != nil {
+		get := db.SystemConfigValue
+		switch mode := strings.ToLower(get("sizing_mode")); mode {
+		case SizingModeOff, SizingModeClamp, SizingModeRecompute:
+			cfg.Mode = mode
+		}
+		parse := func(key string, dst *float64) {
+			if v, err := strconv.ParseFloat(get(key), 64); err == nil && v > 0 {
+				*dst = v
+			}
+		}
+		parse("sizing_risk_per_trade_pct", &cfg.RiskPerTradePct)
+		parse("sizing_atr_stop_multiple", &cfg.ATRStopMultiple)
+		parse("sizing_kelly_fraction", &cfg.KellyFraction)
+	}
+	if riskPerTradePct > 0 {
+		cfg.RiskPerTradePct = riskPerTradePct
+	}
+	return cfg
+}
+
+// SizingInput 仓位计算输入
+type SizingInput struct {
+	Symbol       string
+	Action       string // open_long / open_short
+	Equity       float64
+	Leverage     float64
+	EntryPrice   float64
+	StopLoss     float64
+	ATR          float64
+	Confidence   int     // 0-100，0表示未给出
+	RequestedUSD float64 // AI请求的仓位价值
+}
+
+// SizeResult 仓位计算结果
+type SizeResult struct {
+	RequestedUSD float64
+	FinalUSD     float64
+	Method       string // 决定最终仓位的因素
+	Reason       string
+}
+
+// SizingEngine 服务端仓位计算：按账户净值、ATR、币种配置、凯利比例和单笔风险预算核定开仓仓位
+type SizingEngine struct {
+	config  SizingConfig
+	symbols *decision.SymbolConfigManager
+	kelly   *decision.KellyStopManager
+}
+
+// NewSizingEngine 创建仓位计算引擎（symbols/kelly为nil时不使用对应上限）
+func NewSizingEngine(cfg SizingConfig, symbols *decision.SymbolConfigManager, kelly *decision.KellyStopManager) *SizingEngine {
+	return &SizingEngine{config: cfg, symbols: symbols, kelly: kelly}
+}
+
+// Config 当前仓位计算配置
+func (e *SizingEngine) Config() SizingConfig {
+	return e.config
+}
+
+// Size 计算开仓仓位价值（USDT）
+func (e *SizingEngine) Size(in SizingInput) SizeResult {
+	result := SizeResult{RequestedUSD: in.RequestedUSD, FinalUSD: in.RequestedUSD, Method: SizingMethodAIRequest}
+	if e.config.Mode == SizingModeOff {
+		return result
+	}
+	if in.Equity <= 0 {
+		result.Reason = "账户净值无效，使用AI请求仓位"
+		return result
+	}
+	leverage := in.Leverage
+	if leverage < 1 {
+		leverage = 1
+	}
+	conviction := convictionScale(in.Confidence)
+
+	// 单笔风险预算：止损时亏损 = 净值 × 风险百分比 × 信心度缩放
+	riskUSD := in.Equity * e.config.RiskPerTradePct / 100 * conviction
+	stopDist, stopMethod := e.stopDistance(in)
+
+	var candidates []sizeCandidate
+	if stopDist > 0 {
+		candidates = append(candidates, sizeCandidate{
+			size:   riskUSD / stopDist,
+			method: stopMethod,
+			reason: fmt.Sprintf("风险预算%.2f USDT / 止损距离%.2f%%", riskUSD, stopDist*100),
+		})
+	}
+	if e.symbols != nil {
+		symbolCap := e.symbols.CalculatePositionSize(in.Symbol, in.Equity) * leverage
+		if stopDist <= 0 {
+			candidates = append(candidates, sizeCandidate{
+				size:   symbolCap * conviction,
+				method: SizingMethodSymbolDefault,
+				reason: fmt.Sprintf("无止损/ATR数据，按币种默认仓位 × 信心度%.0f%%", conviction*100),
+			})
+		}
+		candidates = append(candidates, sizeCandidate{
+			size:   symbolCap,
+			method: SizingMethodSymbolCap,
+			reason: fmt.Sprintf("币种最大仓位%.2f USDT", symbolCap),
+		})
+	}
+	if e.kelly != nil {
+		ratio := e.kelly.CalculateKellyOptimalRatio(in.Symbol) * e.config.KellyFraction
+		candidates = append(candidates, sizeCandidate{
+			size:   in.Equity * ratio * leverage,
+			method: SizingMethodKellyCap,
+			reason: fmt.Sprintf("凯利比例%.0f%%", ratio*100),
+		})
+	}
+
+	if e.config.Mode == SizingModeRecompute {
+		// AI仓位不参与计算，只在没有任何可用依据时回退
+		if len(candidates) == 0 {
+			result.Reason = "缺少止损/ATR/币种配置，使用AI请求仓位"
+			return result
+		}
+		result.FinalUSD = math.Inf(1)
+	}
+	for _, c := range candidates {
+		if c.size > 0 && c.size < result.FinalUSD {
+			result.FinalUSD, result.Method, result.Reason = c.size, c.method, c.reason
+		}
+	}
+	if math.IsInf(result.FinalUSD, 1) {
+		result.FinalUSD, result.Method, result.Reason = in.RequestedUSD, SizingMethodAIRequest, "计算结果无效，使用AI请求仓位"
+	}
+	return result
+}
+
+// sizeCandidate 一个仓位上限
+type sizeCandidate struct {
+	size   float64
+	method string
+	reason string
+}
+
+// stopDistance 止损距离（相对开仓价的比例）：决策止损和ATR×倍数取较远者，避免止损过近导致仓位过大
+func (e *SizingEngine) stopDistance(in SizingInput) (float64, string) {
+	if in.EntryPrice <= 0 {
+		return 0, ""
+	}
+	var stopDist float64
+	if in.StopLoss > 0 {
+		if in.Action == "open_long" && in.StopLoss < in.EntryPrice {
+			stopDist = (in.EntryPrice - in.StopLoss) / in.EntryPrice
+		} else if in.Action == "open_short" && in.StopLoss > in.EntryPrice {
+			stopDist = (in.StopLoss - in.EntryPrice) / in.EntryPrice
+		}
+	}
+	if atrDist := in.ATR * e.config.ATRStopMultiple / in.EntryPrice; atrDist > stopDist {
+		return atrDist, SizingMethodATRTarget
+	}
+	if stopDist > 0 {
+		return stopDist, SizingMethodRiskBudget
+	}
+	return 0, ""
+}
+
+// convictionScale 信心度对应的仓位缩放比例（未给出信心度时不缩放）
+func convictionScale(confidence int) float64 {
+	if confidence <= 0 || confidence >= 100 {
+		return 1
+	}
+	return math.Max(float64(confidence)/100, minConvictionScale)
+}
+
+// applySizing 开仓前按仓位计算引擎核定仓位，并记录AI原始请求和计算方式
+func (at *AutoTrader) applySizing(d *decision.Decision, ctx *decision.Context, actionRecord *logger.DecisionAction, record *logger.DecisionRecord) {
+	actionRecord.RequestedSizeUSD = d.PositionSizeUSD
+	if at.sizing == nil {
+		return
+	}
+
+	in := SizingInput{
+		Symbol:       d.Symbol,
+		Action:       d.Action,
+		Equity:       ctx.Account.TotalEquity,
+		Leverage:     d.Leverage,
+		StopLoss:     d.StopLoss,
+		Confidence:   d.Confidence,
+		RequestedUSD: d.PositionSizeUSD,
+	}
+	if data, ok := ctx.MarketDataMap[d.Symbol]; ok && data != nil {
+		in.EntryPrice = data.CurrentPrice
+		if data.LongerTermContext != nil {
+			in.ATR = data.LongerTermContext.ATR14
+		}
+	}
+
+	result := at.sizing.Size(in)
+	actionRecord.SizingMethod = result.Method
+	if result.FinalUSD == d.PositionSizeUSD {
+		return
+	}
+	log.Printf("📐 仓位核定 (%s %s): AI请求 %.2f → %.2f USDT [%s] %s", d.Symbol, d.Action, d.PositionSizeUSD, result.FinalUSD, result.Method, result.Reason)
+	record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("📐 %s %s 仓位由 %.2f 核定为 %.2f USDT [%s]: %s", d.Symbol, d.Action, d.PositionSizeUSD, result.FinalUSD, result.Method, result.Reason))
+	d.PositionSizeUSD = result.FinalUSD
+}
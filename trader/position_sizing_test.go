@@ -0,0 +1,114 @@
+package trader
+
+import (
+	"testing"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+)
+
+// newTestSizingEngine 净值1000时SOLUSDT的币种上限为200×杠杆，默认凯利比例40%
+func newTestSizingEngine(mode string) *SizingEngine {
+	cfg := DefaultSizingConfig()
+	cfg.Mode = mode
+	return NewSizingEngine(cfg, decision.NewSymbolConfigManager(), decision.NewKellyStopManager())
+}
+
+func TestSizingEngineRecompute(t *testing.T) {
+	base := SizingInput{Symbol: "SOLUSDT", Action: "open_long", Equity: 1000, Leverage: 5, EntryPrice: 100, RequestedUSD: 5000}
+
+	tests := []struct {
+		name   string
+		modify func(in *SizingInput)
+		want   float64
+		method string
+	}{
+		{"止损距离决定仓位", func(in *SizingInput) { in.StopLoss = 95 }, 200, SizingMethodRiskBudget},
+		{"止损过近时按ATR计算", func(in *SizingInput) { in.StopLoss = 99; in.ATR = 10 }, 10 / 0.15, SizingMethodATRTarget},
+		{"信心度缩放风险预算", func(in *SizingInput) { in.StopLoss = 95; in.Confidence = 50 }, 100, SizingMethodRiskBudget},
+		{"空单止损在上方", func(in *SizingInput) { in.Action = "open_short"; in.StopLoss = 102 }, 500, SizingMethodRiskBudget},
+		{"止损极近时受币种上限约束", func(in *SizingInput) { in.StopLoss = 99.9 }, 1000, SizingMethodSymbolCap},
+		{"无止损和ATR时使用币种默认仓位", func(in *SizingInput) { in.Confidence = 80 }, 800, SizingMethodSymbolDefault},
+		{"止损方向错误时忽略止损", func(in *SizingInput) { in.StopLoss = 105 }, 1000, SizingMethodSymbolDefault},
+	}
+
+	engine := newTestSizingEngine(SizingModeRecompute)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base
+			tt.modify(&in)
+			got := engine.Size(in)
+			if !approxEqual(got.FinalUSD, tt.want) || got.Method != tt.method {
+				t.Errorf("got %.4f [%s], want %.4f [%s]: %s", got.FinalUSD, got.Method, tt.want, tt.method, got.Reason)
+			}
+			if got.RequestedUSD != 5000 {
+				t.Errorf("应保留AI原始请求: %+v", got)
+			}
+		})
+	}
+}
+
+func TestSizingEngineKellyCap(t *testing.T) {
+	engine := newTestSizingEngine(SizingModeRecompute)
+	// 胜率25%、盈亏比0.2 → 凯利比例取下限10%
+	engine.kelly.UpdateHistoricalStats("SOLUSDT", true, 0.01)
+	for i := 0; i < 3; i++ {
+		engine.kelly.UpdateHistoricalStats("SOLUSDT", false, -0.05)
+	}
+
+	got := engine.Size(SizingInput{Symbol: "SOLUSDT", Action: "open_long", Equity: 1000, Leverage: 5, EntryPrice: 100, StopLoss: 99.9, RequestedUSD: 5000})
+	if !approxEqual(got.FinalUSD, 500) || got.Method != SizingMethodKellyCap {
+		t.Errorf("凯利比例应限制仓位: %+v", got)
+	}
+}
+
+func TestSizingEngineClampAndOff(t *testing.T) {
+	in := SizingInput{Symbol: "SOLUSDT", Action: "open_long", Equity: 1000, Leverage: 5, EntryPrice: 100, StopLoss: 95}
+
+	clamp := newTestSizingEngine(SizingModeClamp)
+	in.RequestedUSD = 150
+	if got := clamp.Size(in); got.FinalUSD != 150 || got.Method != SizingMethodAIRequest {
+		t.Errorf("AI仓位低于上限时应保留: %+v", got)
+	}
+	in.RequestedUSD = 5000
+	if got := clamp.Size(in); !approxEqual(got.FinalUSD, 200) || got.Method != SizingMethodRiskBudget {
+		t.Errorf("AI仓位超过风险预算时应压缩: %+v", got)
+	}
+
+	off := newTestSizingEngine(SizingModeOff)
+	if got := off.Size(in); got.FinalUSD != 5000 || got.Method != SizingMethodAIRequest {
+		t.Errorf("关闭时应使用AI仓位: %+v", got)
+	}
+}
+
+func TestLoadSizingConfigTraderOverride(t *testing.T) {
+	if cfg := LoadSizingConfig(nil, 0); cfg.RiskPerTradePct != 1 || cfg.Mode != SizingModeRecompute {
+		t.Errorf("默认配置错误: %+v", cfg)
+	}
+	if cfg := LoadSizingConfig(nil, 2.5); cfg.RiskPerTradePct != 2.5 {
+		t.Errorf("交易员风险预算应覆盖系统默认值: %+v", cfg)
+	}
+}
+
+func TestAutoTraderApplySizingRecordsRequest(t *testing.T) {
+	at := &AutoTrader{sizing: newTestSizingEngine(SizingModeRecompute)}
+	d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 5000, StopLoss: 95, Confidence: 90}
+	ctx := &decision.Context{
+		Account:       decision.AccountInfo{TotalEquity: 1000},
+		MarketDataMap: map[string]*market.Data{"SOLUSDT": {Symbol: "SOLUSDT", CurrentPrice: 100}},
+	}
+	action := &logger.DecisionAction{}
+	record := &logger.DecisionRecord{}
+
+	at.applySizing(d, ctx, action, record)
+	if !approxEqual(d.PositionSizeUSD, 180) {
+		t.Errorf("决策仓位应改为核定值, got %.2f", d.PositionSizeUSD)
+	}
+	if action.RequestedSizeUSD != 5000 || action.SizingMethod != SizingMethodRiskBudget {
+		t.Errorf("应记录AI原始请求和计算方式: %+v", action)
+	}
+	if len(record.ExecutionLog) != 1 {
+		t.Errorf("仓位调整应写入执行日志: %v", record.ExecutionLog)
+	}
+}
@@ -0,0 +1,270 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"nofx/config"
+	"nofx/decision"
+)
+
+// RiskGate 开仓前风控闸门，每个开仓决策执行前依次检查，任一闸门拒绝即拦截
+type RiskGate interface {
+	// Name 闸门名称（用于日志和状态接口）
+	Name() string
+
+	// Check 检查开仓决策，返回 (是否允许, 拒绝原因)
+	Check(check RiskCheck) (bool, string)
+}
+
+// RiskEquityObserver 需要每周期账户净值的风控闸门（可选实现）
+type RiskEquityObserver interface {
+	OnEquity(equity float64)
+}
+
+// RiskContextObserver 需要每周期账户和持仓快照的风控闸门（可选实现）
+type RiskContextObserver interface {
+	OnContext(ctx *decision.Context)
+}
+
+// RiskTradeObserver 需要平仓结果的风控闸门（可选实现）
+type RiskTradeObserver interface {
+	OnTradeClosed(trade ClosedTrade)
+}
+
+// RiskOpenObserver 需要开仓结果的风控闸门（可选实现），check中的仓位为实际下单前的最终仓位
+type RiskOpenObserver interface {
+	OnTradeOpened(check RiskCheck)
+}
+
+// RiskSizeLimiter 按剩余额度限制开仓仓位的风控闸门（可选实现）
+// 返回允许的最大仓位价值（USDT）和限制原因，小于请求仓位时缩减，<=0时拦截
+type RiskSizeLimiter interface {
+	MaxPositionSize(check RiskCheck) (float64, string)
+}
+
+// riskStatusReporter 可输出状态的风控闸门
+type riskStatusReporter interface {
+	GetStatus() map[string]interface{}
+}
+
+// riskResetter 可手动重置的风控闸门
+type riskResetter interface {
+	Reset(reason string)
+}
+
+// RiskCheck 开仓决策的风控检查输入
+type RiskCheck struct {
+	Symbol          string
+	Action          string // open_long / open_short
+	Leverage        int
+	PositionSizeUSD float64 // 仓位名义价值
+	StopLoss        float64
+	EntryPrice      float64 // 当前价格（无行情时为0）
+	Equity          float64 // 账户净值
+	PositionCount   int     // 开仓前的持仓数量
+}
+
+// Side 开仓方向（long/short）
+func (c RiskCheck) Side() string {
+	if c.Action == "open_short" {
+		return "short"
+	}
+	return "long"
+}
+
+// EstimatedLossPct 触发止损时的预估亏损（占账户净值的百分比）
+// 缺少止损或价格时按全部保证金亏损估算
+func (c RiskCheck) EstimatedLossPct() float64 {
+	if c.Equity <= 0 {
+		return 0
+	}
+	if c.StopLoss > 0 && c.EntryPrice > 0 {
+		diff := c.EntryPrice - c.StopLoss
+		if diff < 0 {
+			diff = -diff
+		}
+		return diff / c.EntryPrice * c.PositionSizeUSD / c.Equity * 100
+	}
+	leverage := c.Leverage
+	if leverage <= 0 {
+		leverage = 1
+	}
+	return c.PositionSizeUSD / float64(leverage) / c.Equity * 100
+}
+
+// ClosedTrade 平仓结果（按平仓前的未实现盈亏计算）
+type ClosedTrade struct {
+	Symbol string
+	Side   string  // long / short
+	PnL    float64 // 盈亏（USDT）
+	PnLPct float64 // 盈亏占账户净值的百分比
+	Equity float64 // 账户净值
+}
+
+// RiskGateConfig 风控闸门配置
+type RiskGateConfig struct {
+	MaxConsecutiveLosses int     // 连续亏损笔数上限
+	MaxDailyLossPct      float64 // 日亏损百分比上限
+	MaxWeeklyLossPct     float64 // 周亏损百分比上限
+	MaxDrawdownPct       float64 // 最大回撤百分比上限
+	ConstraintsEnabled   bool    // 是否启用学习阶段约束
+}
+
+// DefaultRiskGateConfig 默认风控闸门配置
+func DefaultRiskGateConfig() RiskGateConfig {
+	return RiskGateConfig{
+		MaxConsecutiveLosses: 5,
+		MaxDailyLossPct:      10,
+		MaxWeeklyLossPct:     20,
+		MaxDrawdownPct:       20,
+	}
+}
+
+// LoadRiskGateConfig 加载风控闸门配置，日亏损/回撤上限来自交易员配置（<=0时使用默认值）
+func LoadRiskGateConfig(db *config.Database, maxDailyLoss, maxDrawdown float64) RiskGateConfig {
+	cfg := DefaultRiskGateConfig()
+	if maxDailyLoss > 0 {
+		cfg.MaxDailyLossPct = maxDailyLoss
+	}
+	if maxDrawdown > 0 {
+		cfg.MaxDrawdownPct = maxDrawdown
+	}
+	if db == nil {
+		return cfg
+	}
+
+	get := db.SystemConfigValue
+	if n, err := strconv.Atoi(get("risk_max_consecutive_losses")); err == nil && n > 0 {
+		cfg.MaxConsecutiveLosses = n
+	}
+	if pct, err := strconv.ParseFloat(get("risk_max_weekly_loss_pct"), 64); err == nil && pct > 0 {
+		cfg.MaxWeeklyLossPct = pct
+	}
+	cfg.ConstraintsEnabled = get("risk_constraints_enabled") == "true"
+	return cfg
+}
+
+// AddRiskGate 添加开仓前风控闸门（需在Run之前调用）
+func (at *AutoTrader) AddRiskGate(gate RiskGate) {
+	at.riskGates = append(at.riskGates, gate)
+}
+
+// checkRiskGates 依次检查所有风控闸门，返回第一个拒绝原因
+func (at *AutoTrader) checkRiskGates(check RiskCheck) (bool, string) {
+	for _, gate := range at.riskGates {
+		if ok, reason := gate.Check(check); !ok {
+			return false, fmt.Sprintf("[%s] %s", gate.Name(), reason)
+		}
+	}
+	return true, ""
+}
+
+// limitRiskSize 计算各风控闸门允许的最大仓位，返回最严格的限制及原因
+func (at *AutoTrader) limitRiskSize(check RiskCheck) (float64, string) {
+	size, reason := check.PositionSizeUSD, ""
+	for _, gate := range at.riskGates {
+		limiter, ok := gate.(RiskSizeLimiter)
+		if !ok {
+			continue
+		}
+		if limit, why := limiter.MaxPositionSize(check); limit < size {
+			size, reason = limit, fmt.Sprintf("[%s] %s", gate.Name(), why)
+		}
+	}
+	return size, reason
+}
+
+// riskCheck 构建开仓决策的风控检查输入
+func (at *AutoTrader) riskCheck(d *decision.Decision, ctx *decision.Context, positionCount int) RiskCheck {
+	check := RiskCheck{
+		Symbol:          d.Symbol,
+		Action:          d.Action,
+		Leverage:        int(d.Leverage),
+		PositionSizeUSD: d.PositionSizeUSD,
+		StopLoss:        d.StopLoss,
+		Equity:          ctx.Account.TotalEquity,
+		PositionCount:   positionCount,
+	}
+	if data, ok := ctx.MarketDataMap[d.Symbol]; ok && data != nil {
+		check.EntryPrice = data.CurrentPrice
+	}
+	return check
+}
+
+// notifyRiskCycle 将本周期账户净值和持仓同步给风控闸门
+func (at *AutoTrader) notifyRiskCycle(ctx *decision.Context) {
+	for _, gate := range at.riskGates {
+		if observer, ok := gate.(RiskEquityObserver); ok {
+			observer.OnEquity(ctx.Account.TotalEquity)
+		}
+		if observer, ok := gate.(RiskContextObserver); ok {
+			observer.OnContext(ctx)
+		}
+	}
+}
+
+// notifyRiskTradeOpened 开仓成功后通知风控闸门
+func (at *AutoTrader) notifyRiskTradeOpened(check RiskCheck) {
+	for _, gate := range at.riskGates {
+		if observer, ok := gate.(RiskOpenObserver); ok {
+			observer.OnTradeOpened(check)
+		}
+	}
+}
+
+// notifyRiskTradeClosed 平仓成功后按平仓前的持仓盈亏通知风控闸门
+func (at *AutoTrader) notifyRiskTradeClosed(d *decision.Decision, ctx *decision.Context) {
+	side := "long"
+	if d.Action == "close_short" {
+		side = "short"
+	}
+	for _, pos := range ctx.Positions {
+		if pos.Symbol != d.Symbol || pos.Side != side {
+			continue
+		}
+		trade := ClosedTrade{Symbol: pos.Symbol, Side: side, PnL: pos.UnrealizedPnL, Equity: ctx.Account.TotalEquity}
+		if trade.Equity > 0 {
+			trade.PnLPct = trade.PnL / trade.Equity * 100
+		}
+		for _, gate := range at.riskGates {
+			if observer, ok := gate.(RiskTradeObserver); ok {
+				observer.OnTradeClosed(trade)
+			}
+		}
+		return
+	}
+}
+
+// GetRiskStatus 获取各风控闸门的状态
+func (at *AutoTrader) GetRiskStatus() map[string]interface{} {
+	gates := make([]map[string]interface{}, 0, len(at.riskGates))
+	for _, gate := range at.riskGates {
+		entry := map[string]interface{}{"name": gate.Name()}
+		if reporter, ok := gate.(riskStatusReporter); ok {
+			entry["status"] = reporter.GetStatus()
+		}
+		gates = append(gates, entry)
+	}
+	return map[string]interface{}{
+		"trader_id": at.id,
+		"gates":     gates,
+	}
+}
+
+// ResetRiskGate 手动重置风控闸门（如断路器），重置事件会持久化
+func (at *AutoTrader) ResetRiskGate(reason string) error {
+	reset := 0
+	for _, gate := range at.riskGates {
+		if resetter, ok := gate.(riskResetter); ok {
+			resetter.Reset(reason)
+			reset++
+		}
+	}
+	if reset == 0 {
+		return fmt.Errorf("没有可重置的风控闸门")
+	}
+	log.Printf("🔄 [%s] 风控闸门已手动重置: %s", at.name, reason)
+	return nil
+}
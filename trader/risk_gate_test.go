@@ -0,0 +1,195 @@
+package trader
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/database"
+	"nofx/decision"
+)
+
+// memLossEventStore 内存亏损事件存储
+type memLossEventStore struct {
+	events []*database.LossEvent
+}
+
+func (s *memLossEventStore) SaveLossEvent(event *database.LossEvent) error {
+	copied := *event
+	s.events = append(s.events, &copied)
+	return nil
+}
+
+func (s *memLossEventStore) GetLatestLossEvent(traderID string) (*database.LossEvent, error) {
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].TraderID == traderID {
+			copied := *s.events[i]
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func newTestBreaker(store LossEventStore, now *time.Time) *LossCircuitBreaker {
+	lcb := NewLossCircuitBreaker("t1", "u1", store)
+	lcb.now = func() time.Time { return *now }
+	lcb.SetLimits(3, 10, 20, 20)
+	return lcb
+}
+
+func TestLossCircuitBreakerEquityLimits(t *testing.T) {
+	store := &memLossEventStore{}
+	now := time.Date(2026, 1, 6, 9, 0, 0, 0, time.Local) // 周二
+	lcb := newTestBreaker(store, &now)
+
+	lcb.UpdateEquity(1000)
+	if ok, reason := lcb.CanTrade(); !ok {
+		t.Fatalf("初始状态应允许开仓: %s", reason)
+	}
+
+	// 日内亏损15%超过日亏损上限
+	now = now.Add(2 * time.Hour)
+	lcb.UpdateEquity(850)
+	if ok, _ := lcb.CanTrade(); ok {
+		t.Fatal("日亏损超限后应拦截开仓")
+	}
+	if last := store.events[len(store.events)-1]; last.EventType != "daily_loss" || !last.BreachTriggered {
+		t.Errorf("应持久化日亏损触发事件: %+v", last)
+	}
+
+	// 次日自动恢复，起始净值重新计算
+	now = now.Add(24 * time.Hour)
+	lcb.UpdateEquity(850)
+	if ok, reason := lcb.CanTrade(); !ok {
+		t.Fatalf("新的交易日应自动恢复: %s", reason)
+	}
+
+	// 回撤从峰值1000计算，超过20%后次日也不恢复
+	lcb.UpdateEquity(790)
+	if ok, reason := lcb.CanTrade(); ok || !strings.Contains(reason, "max_drawdown") {
+		t.Fatalf("回撤超限应触发断路器: %s", reason)
+	}
+	now = now.Add(24 * time.Hour)
+	lcb.UpdateEquity(790)
+	if ok, _ := lcb.CanTrade(); ok {
+		t.Fatal("回撤触发后不应自动恢复")
+	}
+
+	lcb.Reset("复盘完成")
+	if ok, reason := lcb.CanTrade(); !ok {
+		t.Fatalf("手动重置后应允许开仓: %s", reason)
+	}
+	lcb.UpdateEquity(790)
+	if ok, reason := lcb.CanTrade(); !ok {
+		t.Errorf("重置后回撤应从当前净值重新计算: %s", reason)
+	}
+}
+
+func TestLossCircuitBreakerPersistence(t *testing.T) {
+	store := &memLossEventStore{}
+	now := time.Date(2026, 1, 6, 9, 0, 0, 0, time.Local)
+	lcb := newTestBreaker(store, &now)
+	lcb.UpdateEquity(1000)
+
+	for i := 0; i < 3; i++ {
+		lcb.OnTradeClosed(ClosedTrade{Symbol: "BTCUSDT", PnL: -5, PnLPct: -0.5, Equity: 1000 - float64(i+1)*5})
+	}
+	if ok, reason := lcb.CanTrade(); ok || !strings.Contains(reason, "consecutive_loss") {
+		t.Fatalf("连续亏损达到上限应触发断路器: %s", reason)
+	}
+
+	// 重启后恢复触发状态
+	restored := newTestBreaker(store, &now)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if ok, _ := restored.CanTrade(); ok {
+		t.Fatal("重启后应恢复断路器触发状态")
+	}
+	if status := restored.GetStatus(); status["consecutive_losses"] != 3 || status["account_peak"] != 1000.0 {
+		t.Errorf("应恢复连续亏损和净值峰值: %+v", status)
+	}
+
+	// 手动重置同样持久化
+	restored.Reset("手动重置")
+	again := newTestBreaker(store, &now)
+	if err := again.Load(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if ok, reason := again.CanTrade(); !ok {
+		t.Errorf("重置后重启应允许开仓: %s", reason)
+	}
+}
+
+func TestConstraintsGate(t *testing.T) {
+	cm := NewConstraintsManager()
+	check := RiskCheck{Symbol: "BTCUSDT", Action: "open_long", Leverage: 1, PositionSizeUSD: 500,
+		StopLoss: 98, EntryPrice: 100, Equity: 1000}
+
+	// 止损2%、仓位500、净值1000 → 预估亏损1%
+	if loss := check.EstimatedLossPct(); loss < 0.99 || loss > 1.01 {
+		t.Fatalf("预估亏损应为1%%, got %.4f", loss)
+	}
+	if ok, reason := cm.Check(check); !ok {
+		t.Fatalf("婴儿期1倍杠杆应通过: %s", reason)
+	}
+
+	highLeverage := check
+	highLeverage.Leverage = 5
+	if ok, _ := cm.Check(highLeverage); ok {
+		t.Error("婴儿期超过1倍杠杆应被拦截")
+	}
+
+	full := check
+	full.PositionCount = 1
+	if ok, _ := cm.Check(full); ok {
+		t.Error("婴儿期已有1个仓位时应拦截新开仓")
+	}
+
+	// 亏损计入日亏损，超过5%后拦截
+	cm.OnTradeClosed(ClosedTrade{PnL: -45, PnLPct: -4.5, Equity: 1000})
+	if ok, reason := cm.Check(check); ok || !strings.Contains(reason, "日亏损") {
+		t.Errorf("日亏损超限应拦截: %s", reason)
+	}
+}
+
+func TestAutoTraderRiskGates(t *testing.T) {
+	now := time.Date(2026, 1, 6, 9, 0, 0, 0, time.Local)
+	breaker := newTestBreaker(&memLossEventStore{}, &now)
+	at := &AutoTrader{id: "t1", name: "test"}
+	at.AddRiskGate(breaker)
+
+	ctx := &decision.Context{
+		Account: decision.AccountInfo{TotalEquity: 1000},
+		Positions: []decision.PositionInfo{
+			{Symbol: "ETHUSDT", Side: "short", UnrealizedPnL: -20},
+		},
+	}
+	at.notifyRiskCycle(ctx)
+
+	open := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 500}
+	if ok, reason := at.checkRiskGates(at.riskCheck(open, ctx, 1)); !ok {
+		t.Fatalf("断路器未触发时应允许开仓: %s", reason)
+	}
+
+	// 平仓结果按平仓前的持仓盈亏传给断路器
+	closeShort := &decision.Decision{Symbol: "ETHUSDT", Action: "close_short"}
+	for i := 0; i < 3; i++ {
+		at.notifyRiskTradeClosed(closeShort, ctx)
+	}
+	ok, reason := at.checkRiskGates(at.riskCheck(open, ctx, 0))
+	if ok || !strings.HasPrefix(reason, "[circuit_breaker]") {
+		t.Fatalf("连续亏损后应被断路器拦截: %s", reason)
+	}
+
+	if err := at.ResetRiskGate("测试"); err != nil {
+		t.Fatalf("重置失败: %v", err)
+	}
+	if ok, reason := at.checkRiskGates(at.riskCheck(open, ctx, 0)); !ok {
+		t.Errorf("重置后应允许开仓: %s", reason)
+	}
+	gates := at.GetRiskStatus()["gates"].([]map[string]interface{})
+	if len(gates) != 1 || gates[0]["name"] != "circuit_breaker" || gates[0]["status"] == nil {
+		t.Errorf("状态应包含断路器: %+v", gates)
+	}
+}
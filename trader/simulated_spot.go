@@ -0,0 +1,127 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SpotBuy 市价买入现货（实现SpotTrader，按1倍多仓记账，买入成本全额占用可用资金）
+func (t *SimulatedTrader) SpotBuy(symbol string, quantity float64) (*OrderResult, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("买入数量必须大于0: %.8f", quantity)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price, err := t.priceLocked(symbol)
+	if err != nil {
+		return nil, err
+	}
+	fillPrice := t.applySlippage(price, true)
+	cost := quantity * fillPrice
+	fee := cost * t.cfg.TakerFeeRate
+
+	free := t.balance + t.unrealizedPnLLocked() - t.usedMarginLocked()
+	if cost+fee > free {
+		return nil, fmt.Errorf("可用资金不足: 需要 %.2f USDT, 可用 %.2f USDT", cost+fee, free)
+	}
+
+	holding, exists := t.spot[symbol]
+	if exists {
+		totalQty := holding.quantity + quantity
+		holding.entryPrice = (holding.entryPrice*holding.quantity + fillPrice*quantity) / totalQty
+		holding.quantity = totalQty
+		holding.margin += cost
+		holding.fees += fee
+	} else {
+		t.spot[symbol] = &simPosition{
+			symbol:     symbol,
+			side:       "long",
+			quantity:   quantity,
+			entryPrice: fillPrice,
+			leverage:   1,
+			margin:     cost,
+			openTime:   t.clock(),
+			fees:       fee,
+		}
+	}
+
+	t.balance -= fee
+	t.totalFees += fee
+	orderID := t.recordFill(0, symbol, "spot_buy", quantity, fillPrice, fee, 0, "order")
+	return filledResult(orderID, symbol, quantity, fillPrice, fee), nil
+}
+
+// SpotSell 市价卖出现货（实现SpotTrader，quantity为0或超过持有数量时全部卖出）
+func (t *SimulatedTrader) SpotSell(symbol string, quantity float64) (*OrderResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	holding, ok := t.spot[symbol]
+	if !ok {
+		return nil, fmt.Errorf("没有 %s 的现货持仓", symbol)
+	}
+	if quantity <= 0 || quantity > holding.quantity {
+		quantity = holding.quantity
+	}
+
+	price, err := t.priceLocked(symbol)
+	if err != nil {
+		return nil, err
+	}
+	fillPrice := t.applySlippage(price, false)
+
+	ratio := quantity / holding.quantity
+	pnl := (fillPrice - holding.entryPrice) * quantity
+	fee := quantity * fillPrice * t.cfg.TakerFeeRate
+	cost := holding.margin * ratio
+	openFee := holding.fees * ratio
+
+	t.balance += pnl - fee
+	t.totalFees += fee
+	t.closedTrades = append(t.closedTrades, SimClosedTrade{
+		Symbol:      symbol,
+		Side:        "long",
+		Quantity:    quantity,
+		EntryPrice:  holding.entryPrice,
+		ExitPrice:   fillPrice,
+		Leverage:    1,
+		Margin:      cost,
+		OpenTime:    holding.openTime,
+		CloseTime:   t.clock(),
+		RealizedPnL: pnl,
+		Fees:        openFee + fee,
+		Reason:      "spot",
+	})
+
+	holding.quantity -= quantity
+	holding.margin -= cost
+	holding.fees -= openFee
+	if holding.quantity <= 1e-12 {
+		delete(t.spot, symbol)
+	}
+
+	orderID := t.recordFill(0, symbol, "spot_sell", quantity, fillPrice, fee, pnl, "order")
+	return filledResult(orderID, symbol, quantity, fillPrice, fee), nil
+}
+
+// GetSpotBalance 现货持有数量（实现SpotTrader）
+func (t *SimulatedTrader) GetSpotBalance(symbol string) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if holding, ok := t.spot[symbol]; ok {
+		return holding.quantity, nil
+	}
+	return 0, nil
+}
+
+// GetSpotPrice 现货最新价格（实现SpotTrader，模拟盘现货与合约使用同一行情）
+func (t *SimulatedTrader) GetSpotPrice(symbol string) (float64, error) {
+	return t.GetMarketPrice(symbol)
+}
+
+// isSpotFill 是否为现货成交
+func isSpotFill(f SimFill) bool {
+	return strings.HasPrefix(f.Action, "spot_")
+}
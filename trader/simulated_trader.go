@@ -0,0 +1,1232 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceSource 行情价格来源
+type PriceSource func(symbol string) (float64, error)
+
+// SimulatedTraderConfig 模拟交易器配置
+type SimulatedTraderConfig struct {
+	InitialBalance        float64 // 初始资金 (USDT)
+	TakerFeeRate          float64 // 吃单手续费率 (默认 0.05%)
+	MakerFeeRate          float64 // 挂单手续费率 (默认 0.02%，限价单挂单成交时使用)
+	SlippageBps           float64 // 市价成交滑点 (基点, 默认 2bp)
+	MaintenanceMarginRate float64 // 维持保证金率 (默认 0.5%)
+	MaxHistory            int     // 成交/资金费/平仓记录各自最多保留的条数（0为不限制，回测需要完整记录）
+}
+
+// DefaultSimulatedTraderConfig 默认模拟交易器配置
+func DefaultSimulatedTraderConfig(initialBalance float64) SimulatedTraderConfig {
+	return SimulatedTraderConfig{
+		InitialBalance:        initialBalance,
+		TakerFeeRate:          0.0005,
+		MakerFeeRate:          0.0002,
+		SlippageBps:           2,
+		MaintenanceMarginRate: 0.005,
+	}
+}
+
+// SimFill 模拟成交记录
+type SimFill struct {
+	OrderID     int64     `json:"order_id"`
+	Time        time.Time `json:"time"`
+	Symbol      string    `json:"symbol"`
+	Action      string    `json:"action"` // open_long, open_short, close_long, close_short
+	Quantity    float64   `json:"quantity"`
+	Price       float64   `json:"price"`
+	Fee         float64   `json:"fee"`
+	RealizedPnL float64   `json:"realized_pnl"` // 平仓盈亏（不含手续费）
+	Reason      string    `json:"reason"`       // order, stop_loss, take_profit, liquidation
+}
+
+// SimFunding 模拟资金费结算记录
+type SimFunding struct {
+	Time     time.Time `json:"time"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"`
+	Rate     float64   `json:"rate"`
+	Notional float64   `json:"notional"`
+	Payment  float64   `json:"payment"` // 正数=支付，负数=收取
+}
+
+// SimClosedTrade 模拟平仓交易（一次开仓到平仓的完整记录）
+type SimClosedTrade struct {
+	Symbol      string    `json:"symbol"`
+	Side        string    `json:"side"`
+	Quantity    float64   `json:"quantity"`
+	EntryPrice  float64   `json:"entry_price"`
+	ExitPrice   float64   `json:"exit_price"`
+	Leverage    int       `json:"leverage"`
+	Margin      float64   `json:"margin"`
+	OpenTime    time.Time `json:"open_time"`
+	CloseTime   time.Time `json:"close_time"`
+	RealizedPnL float64   `json:"realized_pnl"` // 价格盈亏（不含费用）
+	Fees        float64   `json:"fees"`         // 开平仓手续费
+	Funding     float64   `json:"funding"`      // 持仓期间支付的资金费
+	Reason      string    `json:"reason"`
+}
+
+// NetPnL 扣除手续费和资金费后的净盈亏
+func (t SimClosedTrade) NetPnL() float64 {
+	return t.RealizedPnL - t.Fees - t.Funding
+}
+
+// simPosition 模拟持仓
+type simPosition struct {
+	symbol     string
+	side       string // long / short
+	quantity   float64
+	entryPrice float64
+	leverage   int
+	margin     float64
+	stopLoss   float64
+	takeProfit float64
+	openTime   time.Time
+	fees       float64 // 已分摊的开仓手续费
+	funding    float64 // 累计资金费
+}
+
+// simLimitOrder 模拟限价挂单（K线最低/最高价穿过委托价时按委托价成交）
+type simLimitOrder struct {
+	id        int64
+	symbol    string
+	side      string // long / short
+	quantity  float64
+	price     float64
+	leverage  int
+	status    OrderStatus
+	fillPrice float64
+	fee       float64
+	reason    string // 拒绝/撤销原因
+}
+
+// SimulatedTrader 模拟交易器
+// 实现 Trader 接口，在内存中撮合订单并模拟手续费、滑点、资金费和强平
+type SimulatedTrader struct {
+	mu          sync.Mutex
+	cfg         SimulatedTraderConfig
+	balance     float64 // 钱包余额（已实现盈亏和费用已计入）
+	positions   map[string]*simPosition
+	spot        map[string]*simPosition // 现货持仓（按1倍多仓记账，成本计入占用资金，不参与资金费和强平）
+	leverages   map[string]int
+	prices      map[string]float64
+	priceSource PriceSource
+	clock       func() time.Time
+	nextOrderID int64
+	limitOrders map[int64]*simLimitOrder
+
+	fills          []SimFill
+	fundings       []SimFunding
+	closedTrades   []SimClosedTrade
+	fillsDropped   int // 超出MaxHistory被淘汰的成交记录数（成交序号 = 淘汰数 + 下标）
+	fundingDropped int // 超出MaxHistory被淘汰的资金费记录数
+	totalFees      float64
+	totalFunding   float64
+}
+
+// NewSimulatedTrader 创建模拟交易器
+func NewSimulatedTrader(cfg SimulatedTraderConfig) *SimulatedTrader {
+	defaults := DefaultSimulatedTraderConfig(cfg.InitialBalance)
+	if cfg.TakerFeeRate < 0 {
+		cfg.TakerFeeRate = 0
+	}
+	if cfg.MakerFeeRate < 0 {
+		cfg.MakerFeeRate = 0
+	}
+	if cfg.SlippageBps < 0 {
+		cfg.SlippageBps = 0
+	}
+	if cfg.MaintenanceMarginRate <= 0 {
+		cfg.MaintenanceMarginRate = defaults.MaintenanceMarginRate
+	}
+
+	return &SimulatedTrader{
+		cfg:         cfg,
+		balance:     cfg.InitialBalance,
+		positions:   make(map[string]*simPosition),
+		spot:        make(map[string]*simPosition),
+		leverages:   make(map[string]int),
+		prices:      make(map[string]float64),
+		clock:       time.Now,
+		nextOrderID: 1,
+		limitOrders: make(map[int64]*simLimitOrder),
+	}
+}
+
+// SetClock 设置时钟（回测时使用K线时间）
+func (t *SimulatedTrader) SetClock(clock func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock = clock
+}
+
+// SetPriceSource 设置实时价格来源（未设置时使用 SetPrice/OnBar 推送的价格）
+func (t *SimulatedTrader) SetPriceSource(source PriceSource) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.priceSource = source
+}
+
+// SetPrice 更新最新价格
+func (t *SimulatedTrader) SetPrice(symbol string, price float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices[symbol] = price
+}
+
+// OnBar 推进一根K线：先按最高/最低价检查强平、止损、止盈，再以收盘价更新标记价格
+// 同一根K线内止损与止盈同时触发时，保守地按止损处理
+func (t *SimulatedTrader) OnBar(symbol string, high, low, close float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, side := range []string{"long", "short"} {
+		pos, ok := t.positions[positionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+
+		liqPrice := t.liquidationPrice(pos)
+		if side == "long" {
+			switch {
+			case low <= liqPrice:
+				t.closePositionLocked(pos, pos.quantity, liqPrice, "liquidation")
+			case pos.stopLoss > 0 && low <= pos.stopLoss:
+				t.closePositionLocked(pos, pos.quantity, t.applySlippage(pos.stopLoss, false), "stop_loss")
+			case pos.takeProfit > 0 && high >= pos.takeProfit:
+				t.closePositionLocked(pos, pos.quantity, pos.takeProfit, "take_profit")
+			}
+		} else {
+			switch {
+			case high >= liqPrice:
+				t.closePositionLocked(pos, pos.quantity, liqPrice, "liquidation")
+			case pos.stopLoss > 0 && high >= pos.stopLoss:
+				t.closePositionLocked(pos, pos.quantity, t.applySlippage(pos.stopLoss, true), "stop_loss")
+			case pos.takeProfit > 0 && low <= pos.takeProfit:
+				t.closePositionLocked(pos, pos.quantity, pos.takeProfit, "take_profit")
+			}
+		}
+	}
+
+	for _, order := range t.restingLimitOrdersLocked(symbol) {
+		if (order.side == "long" && low <= order.price) || (order.side == "short" && high >= order.price) {
+			t.fillLimitOrderLocked(order, order.price, t.cfg.MakerFeeRate)
+		}
+	}
+
+	t.prices[symbol] = close
+}
+
+// ApplyFunding 按资金费率结算持仓资金费（多头支付正费率，空头收取）
+func (t *SimulatedTrader) ApplyFunding(symbol string, rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price := t.prices[symbol]
+	if price <= 0 || rate == 0 {
+		return
+	}
+
+	for _, side := range []string{"long", "short"} {
+		pos, ok := t.positions[positionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+		notional := pos.quantity * price
+		payment := notional * rate
+		if side == "short" {
+			payment = -payment
+		}
+
+		t.balance -= payment
+		t.totalFunding += payment
+		pos.funding += payment
+		t.fundings = append(t.fundings, SimFunding{
+			Time:     t.clock(),
+			Symbol:   symbol,
+			Side:     side,
+			Rate:     rate,
+			Notional: notional,
+			Payment:  payment,
+		})
+		var dropped int
+		t.fundings, dropped = trimHistory(t.fundings, t.cfg.MaxHistory)
+		t.fundingDropped += dropped
+	}
+}
+
+// GetBalance 获取账户余额
+func (t *SimulatedTrader) GetBalance() (*Balance, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unrealized := t.unrealizedPnLLocked()
+	used := t.usedMarginLocked()
+	free := t.balance + unrealized - used
+	if free < 0 {
+		free = 0
+	}
+
+	return &Balance{
+		TotalWalletBalance:    t.balance,
+		AvailableBalance:      free,
+		TotalUnrealizedProfit: unrealized,
+		UsedMargin:            used,
+	}, nil
+}
+
+// GetPositions 获取所有持仓
+func (t *SimulatedTrader) GetPositions() ([]Position, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]string, 0, len(t.positions))
+	for key := range t.positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]Position, 0, len(keys))
+	for _, key := range keys {
+		pos := t.positions[key]
+		markPrice := t.markPriceLocked(pos)
+		result = append(result, Position{
+			Symbol:           pos.symbol,
+			Side:             pos.side,
+			Quantity:         pos.quantity,
+			EntryPrice:       pos.entryPrice,
+			MarkPrice:        markPrice,
+			UnrealizedProfit: positionPnL(pos, markPrice),
+			Leverage:         pos.leverage,
+			LiquidationPrice: t.liquidationPrice(pos),
+			StopLoss:         pos.stopLoss,
+			TakeProfit:       pos.takeProfit,
+			OpenTime:         pos.openTime,
+		})
+	}
+
+	return result, nil
+}
+
+// OpenLong 开多仓
+func (t *SimulatedTrader) OpenLong(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.open(symbol, "long", quantity, leverage)
+}
+
+// OpenShort 开空仓
+func (t *SimulatedTrader) OpenShort(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return t.open(symbol, "short", quantity, leverage)
+}
+
+// CloseLong 平多仓（quantity=0表示全部平仓）
+func (t *SimulatedTrader) CloseLong(symbol string, quantity float64) (*OrderResult, error) {
+	return t.close(symbol, "long", quantity)
+}
+
+// CloseShort 平空仓（quantity=0表示全部平仓）
+func (t *SimulatedTrader) CloseShort(symbol string, quantity float64) (*OrderResult, error) {
+	return t.close(symbol, "short", quantity)
+}
+
+// SetLeverage 设置杠杆
+func (t *SimulatedTrader) SetLeverage(symbol string, leverage int) error {
+	if leverage <= 0 {
+		return fmt.Errorf("无效的杠杆倍数: %d", leverage)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leverages[symbol] = leverage
+	return nil
+}
+
+// SetMarginMode 设置仓位模式（模拟盘统一按逐仓计算强平价）
+func (t *SimulatedTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	return nil
+}
+
+// GetMarketPrice 获取市场价格
+func (t *SimulatedTrader) GetMarketPrice(symbol string) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.priceLocked(symbol)
+}
+
+// SetStopLoss 设置止损单
+func (t *SimulatedTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pos, ok := t.positions[positionKey(symbol, sideFromPositionSide(positionSide))]
+	if !ok {
+		return fmt.Errorf("没有找到 %s %s 持仓", symbol, positionSide)
+	}
+	pos.stopLoss = stopPrice
+	return nil
+}
+
+// SetTakeProfit 设置止盈单
+func (t *SimulatedTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pos, ok := t.positions[positionKey(symbol, sideFromPositionSide(positionSide))]
+	if !ok {
+		return fmt.Errorf("没有找到 %s %s 持仓", symbol, positionSide)
+	}
+	pos.takeProfit = takeProfitPrice
+	return nil
+}
+
+// CancelAllOrders 取消该币种的所有挂单（清除止损止盈）
+func (t *SimulatedTrader) CancelAllOrders(symbol string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, side := range []string{"long", "short"} {
+		if pos, ok := t.positions[positionKey(symbol, side)]; ok {
+			pos.stopLoss = 0
+			pos.takeProfit = 0
+		}
+	}
+	for _, order := range t.restingLimitOrdersLocked(symbol) {
+		order.status = OrderStatusCancelled
+	}
+	return nil
+}
+
+// PlaceLimitOrder 下限价开仓单（实现LimitOrderPlacer）
+// 委托价优于当前价时立即按当前价吃单成交（postOnly时直接置为失效），否则挂单等待价格穿过委托价
+func (t *SimulatedTrader) PlaceLimitOrder(symbol, positionSide string, quantity, price float64, leverage int, postOnly bool, clientOrderID string) (*OrderResult, error) {
+	if quantity <= 0 || price <= 0 {
+		return nil, fmt.Errorf("限价单数量和价格必须大于0: %.8f @ %.8f", quantity, price)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	market, err := t.priceLocked(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &simLimitOrder{
+		id:       t.nextOrderID,
+		symbol:   symbol,
+		side:     sideFromPositionSide(positionSide),
+		quantity: quantity,
+		price:    price,
+		leverage: leverage,
+		status:   OrderStatusNew,
+	}
+	t.nextOrderID++
+	t.limitOrders[order.id] = order
+
+	if (order.side == "long" && price >= market) || (order.side == "short" && price <= market) {
+		if postOnly {
+			order.status = OrderStatusExpired
+			order.reason = "post-only订单会立即成交，已取消"
+		} else if err := t.fillLimitOrderLocked(order, market, t.cfg.TakerFeeRate); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.limitOrderResultLocked(order), nil
+}
+
+// CancelOrder 撤销限价挂单（实现LimitOrderPlacer）
+func (t *SimulatedTrader) CancelOrder(symbol, orderID string) error {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的订单ID: %s", orderID)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	order, ok := t.limitOrders[id]
+	if !ok || order.symbol != symbol {
+		return fmt.Errorf("订单不存在: %s", orderID)
+	}
+	if order.status == OrderStatusNew {
+		order.status = OrderStatusCancelled
+	}
+	return nil
+}
+
+// CancelStopOrder 撤销止损/止盈条件单（实现StopOrderCanceller，模拟盘每个持仓方向最多一个止损单和一个止盈单）
+func (t *SimulatedTrader) CancelStopOrder(order *Order) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pos, ok := t.positions[positionKey(order.Symbol, sideFromPositionSide(order.PositionSide))]
+	if !ok {
+		return fmt.Errorf("没有找到 %s %s 持仓", order.Symbol, order.PositionSide)
+	}
+	switch order.Type {
+	case OrderTypeStopMarket:
+		pos.stopLoss = 0
+	case OrderTypeTakeProfitMarket:
+		pos.takeProfit = 0
+	default:
+		return fmt.Errorf("不是条件单: %s", order.Type)
+	}
+	return nil
+}
+
+// GetBestBidAsk 获取买一/卖一价（实现LimitOrderPlacer，以当前价加减滑点模拟盘口价差）
+func (t *SimulatedTrader) GetBestBidAsk(symbol string) (float64, float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price, err := t.priceLocked(symbol)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.applySlippage(price, false), t.applySlippage(price, true), nil
+}
+
+// QueryOrder 查询订单（实现OrderQuerier，模拟盘市价单下单即成交）
+func (t *SimulatedTrader) QueryOrder(symbol, orderID string) (*Order, error) {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的订单ID: %s", orderID)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if order, ok := t.limitOrders[id]; ok {
+		return order.toOrder(), nil
+	}
+
+	for i := len(t.fills) - 1; i >= 0; i-- {
+		f := t.fills[i]
+		if f.OrderID != id {
+			continue
+		}
+		return &Order{
+			ExchangeOrderID: orderID,
+			Symbol:          f.Symbol,
+			Action:          f.Action,
+			Type:            OrderTypeMarket,
+			Quantity:        f.Quantity,
+			FilledQty:       f.Quantity,
+			AvgPrice:        f.Price,
+			Fee:             f.Fee,
+			Status:          OrderStatusFilled,
+		}, nil
+	}
+	return nil, fmt.Errorf("订单不存在: %s", orderID)
+}
+
+// GetOpenOrders 获取该币种当前挂单（实现OrderQuerier，即持仓上设置的止损止盈）
+func (t *SimulatedTrader) GetOpenOrders(symbol string) ([]*Order, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var orders []*Order
+	for _, side := range []string{"long", "short"} {
+		pos, ok := t.positions[positionKey(symbol, side)]
+		if !ok {
+			continue
+		}
+		if pos.stopLoss > 0 {
+			orders = append(orders, &Order{Symbol: symbol, PositionSide: strings.ToUpper(side), Type: OrderTypeStopMarket,
+				Quantity: pos.quantity, StopPrice: pos.stopLoss, Status: OrderStatusNew})
+		}
+		if pos.takeProfit > 0 {
+			orders = append(orders, &Order{Symbol: symbol, PositionSide: strings.ToUpper(side), Type: OrderTypeTakeProfitMarket,
+				Quantity: pos.quantity, StopPrice: pos.takeProfit, Status: OrderStatusNew})
+		}
+	}
+	for _, order := range t.restingLimitOrdersLocked(symbol) {
+		orders = append(orders, order.toOrder())
+	}
+	return orders, nil
+}
+
+// FormatQuantity 格式化数量到正确的精度
+func (t *SimulatedTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return strconv.FormatFloat(quantity, 'f', 6, 64), nil
+}
+
+// Equity 当前账户净值（钱包余额 + 未实现盈亏）
+func (t *SimulatedTrader) Equity() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.balance + t.unrealizedPnLLocked()
+}
+
+// GetFills 获取全部成交记录
+func (t *SimulatedTrader) GetFills() []SimFill {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SimFill(nil), t.fills...)
+}
+
+// FillCount 累计成交笔数（包括超出MaxHistory已淘汰的记录）
+func (t *SimulatedTrader) FillCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fillsDropped + len(t.fills)
+}
+
+// FillsSince 获取累计序号n之后的成交记录（n通常为之前FillCount的返回值）
+func (t *SimulatedTrader) FillsSince(n int) []SimFill {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start := n - t.fillsDropped
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(t.fills) {
+		return nil
+	}
+	return append([]SimFill(nil), t.fills[start:]...)
+}
+
+// GetFundings 获取全部资金费结算记录
+func (t *SimulatedTrader) GetFundings() []SimFunding {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SimFunding(nil), t.fundings...)
+}
+
+// GetFillHistory 获取since之后该币种的成交记录（实现FillHistoryProvider）
+func (t *SimulatedTrader) GetFillHistory(symbol string, since time.Time) ([]Fill, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var fills []Fill
+	for _, f := range t.fills {
+		// 现货成交不属于合约持仓
+		if f.Symbol != symbol || f.Time.Before(since) || isSpotFill(f) {
+			continue
+		}
+		side := "buy"
+		if f.Action == "open_short" || f.Action == "close_long" {
+			side = "sell"
+		}
+		positionSide := "LONG"
+		if strings.HasSuffix(f.Action, "short") {
+			positionSide = "SHORT"
+		}
+		fills = append(fills, Fill{
+			OrderID:      strconv.FormatInt(f.OrderID, 10),
+			Symbol:       f.Symbol,
+			Side:         side,
+			PositionSide: positionSide,
+			Quantity:     f.Quantity,
+			Price:        f.Price,
+			Fee:          f.Fee,
+			RealizedPnL:  f.RealizedPnL,
+			Reason:       f.Reason,
+			Time:         f.Time,
+		})
+	}
+	return fills, nil
+}
+
+// GetFundingPayments 获取since之后该币种支付的资金费合计（实现FundingHistoryProvider）
+func (t *SimulatedTrader) GetFundingPayments(symbol string, since time.Time) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := 0.0
+	for _, f := range t.fundings {
+		if f.Symbol == symbol && !f.Time.Before(since) {
+			total += f.Payment
+		}
+	}
+	return total, nil
+}
+
+// GetIncomeHistory 获取since之后的手续费和资金费流水（实现IncomeLedgerProvider）
+func (t *SimulatedTrader) GetIncomeHistory(since time.Time) ([]IncomeRecord, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var records []IncomeRecord
+	for i, f := range t.fills {
+		if f.Fee == 0 || f.Time.Before(since) {
+			continue
+		}
+		records = append(records, IncomeRecord{
+			Type:    "fee",
+			Symbol:  f.Symbol,
+			Amount:  f.Fee,
+			Ref:     "fill_" + strconv.Itoa(t.fillsDropped+i),
+			OrderID: strconv.FormatInt(f.OrderID, 10),
+			Time:    f.Time,
+		})
+	}
+	for i, f := range t.fundings {
+		if f.Time.Before(since) {
+			continue
+		}
+		records = append(records, IncomeRecord{
+			Type:   "funding",
+			Symbol: f.Symbol,
+			Amount: f.Payment,
+			Ref:    "funding_" + strconv.Itoa(t.fundingDropped+i),
+			Time:   f.Time,
+		})
+	}
+	return records, nil
+}
+
+// GetClosedTrades 获取已平仓交易
+func (t *SimulatedTrader) GetClosedTrades() []SimClosedTrade {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SimClosedTrade(nil), t.closedTrades...)
+}
+
+// GetTotalFees 累计手续费
+func (t *SimulatedTrader) GetTotalFees() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.totalFees
+}
+
+// GetTotalFunding 累计资金费（正数=净支付）
+func (t *SimulatedTrader) GetTotalFunding() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.totalFunding
+}
+
+// SimPositionState 持仓状态（用于持久化）
+type SimPositionState struct {
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	EntryPrice float64   `json:"entry_price"`
+	Leverage   int       `json:"leverage"`
+	Margin     float64   `json:"margin"`
+	StopLoss   float64   `json:"stop_loss"`
+	TakeProfit float64   `json:"take_profit"`
+	OpenTime   time.Time `json:"open_time"`
+	Fees       float64   `json:"fees"`
+	Funding    float64   `json:"funding"`
+}
+
+// SimLimitOrderState 等待成交的限价挂单（持久化格式）
+type SimLimitOrderState struct {
+	ID       int64   `json:"id"`
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price"`
+	Leverage int     `json:"leverage"`
+}
+
+// SimulatedState 模拟账户状态（用于持久化，不含成交明细）
+type SimulatedState struct {
+	Balance      float64              `json:"balance"`
+	Positions    []SimPositionState   `json:"positions"`
+	Spot         []SimPositionState   `json:"spot,omitempty"`         // 现货持仓
+	LimitOrders  []SimLimitOrderState `json:"limit_orders,omitempty"` // 等待成交的限价挂单
+	Leverages    map[string]int       `json:"leverages"`
+	NextOrderID  int64                `json:"next_order_id"`
+	TotalFees    float64              `json:"total_fees"`
+	TotalFunding float64              `json:"total_funding"`
+}
+
+// Snapshot 导出当前账户状态
+func (t *SimulatedTrader) Snapshot() SimulatedState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := SimulatedState{
+		Balance:      t.balance,
+		Leverages:    make(map[string]int, len(t.leverages)),
+		NextOrderID:  t.nextOrderID,
+		TotalFees:    t.totalFees,
+		TotalFunding: t.totalFunding,
+	}
+	for symbol, lev := range t.leverages {
+		state.Leverages[symbol] = lev
+	}
+	for _, pos := range t.positions {
+		state.Positions = append(state.Positions, pos.state())
+	}
+	sort.Slice(state.Positions, func(i, j int) bool {
+		return positionKey(state.Positions[i].Symbol, state.Positions[i].Side) < positionKey(state.Positions[j].Symbol, state.Positions[j].Side)
+	})
+	for _, holding := range t.spot {
+		state.Spot = append(state.Spot, holding.state())
+	}
+	sort.Slice(state.Spot, func(i, j int) bool { return state.Spot[i].Symbol < state.Spot[j].Symbol })
+	for _, order := range t.limitOrders {
+		if order.status != OrderStatusNew {
+			continue
+		}
+		state.LimitOrders = append(state.LimitOrders, SimLimitOrderState{
+			ID:       order.id,
+			Symbol:   order.symbol,
+			Side:     order.side,
+			Quantity: order.quantity,
+			Price:    order.price,
+			Leverage: order.leverage,
+		})
+	}
+	sort.Slice(state.LimitOrders, func(i, j int) bool { return state.LimitOrders[i].ID < state.LimitOrders[j].ID })
+	return state
+}
+
+// Restore 从持久化状态恢复账户
+func (t *SimulatedTrader) Restore(state SimulatedState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.balance = state.Balance
+	t.totalFees = state.TotalFees
+	t.totalFunding = state.TotalFunding
+	if state.NextOrderID > t.nextOrderID {
+		t.nextOrderID = state.NextOrderID
+	}
+	t.leverages = make(map[string]int, len(state.Leverages))
+	for symbol, lev := range state.Leverages {
+		t.leverages[symbol] = lev
+	}
+	t.positions = make(map[string]*simPosition, len(state.Positions))
+	for _, p := range state.Positions {
+		if p.Quantity <= 0 || p.Leverage <= 0 {
+			continue
+		}
+		t.positions[positionKey(p.Symbol, p.Side)] = p.position()
+	}
+	t.spot = make(map[string]*simPosition, len(state.Spot))
+	for _, p := range state.Spot {
+		if p.Quantity <= 0 {
+			continue
+		}
+		t.spot[p.Symbol] = p.position()
+	}
+	t.limitOrders = make(map[int64]*simLimitOrder, len(state.LimitOrders))
+	for _, o := range state.LimitOrders {
+		if o.Quantity <= 0 || o.Price <= 0 || o.Leverage <= 0 {
+			continue
+		}
+		t.limitOrders[o.ID] = &simLimitOrder{
+			id:       o.ID,
+			symbol:   o.Symbol,
+			side:     o.Side,
+			quantity: o.Quantity,
+			price:    o.Price,
+			leverage: o.Leverage,
+			status:   OrderStatusNew,
+		}
+		if o.ID >= t.nextOrderID {
+			t.nextOrderID = o.ID + 1
+		}
+	}
+}
+
+// state 转换为持久化状态
+func (pos *simPosition) state() SimPositionState {
+	return SimPositionState{
+		Symbol:     pos.symbol,
+		Side:       pos.side,
+		Quantity:   pos.quantity,
+		EntryPrice: pos.entryPrice,
+		Leverage:   pos.leverage,
+		Margin:     pos.margin,
+		StopLoss:   pos.stopLoss,
+		TakeProfit: pos.takeProfit,
+		OpenTime:   pos.openTime,
+		Fees:       pos.fees,
+		Funding:    pos.funding,
+	}
+}
+
+// position 从持久化状态恢复持仓
+func (p SimPositionState) position() *simPosition {
+	return &simPosition{
+		symbol:     p.Symbol,
+		side:       p.Side,
+		quantity:   p.Quantity,
+		entryPrice: p.EntryPrice,
+		leverage:   p.Leverage,
+		margin:     p.Margin,
+		stopLoss:   p.StopLoss,
+		takeProfit: p.TakeProfit,
+		openTime:   p.OpenTime,
+		fees:       p.Fees,
+		funding:    p.Funding,
+	}
+}
+
+// HeldSymbols 当前有持仓（含现货）或限价挂单的币种（需要推进行情的币种）
+func (t *SimulatedTrader) HeldSymbols() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, pos := range t.positions {
+		if !seen[pos.symbol] {
+			seen[pos.symbol] = true
+			symbols = append(symbols, pos.symbol)
+		}
+	}
+	for symbol := range t.spot {
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, order := range t.limitOrders {
+		if order.status == OrderStatusNew && !seen[order.symbol] {
+			seen[order.symbol] = true
+			symbols = append(symbols, order.symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// open 开仓（同方向已有持仓时加仓并重新计算均价）
+func (t *SimulatedTrader) open(symbol, side string, quantity float64, leverage int) (*OrderResult, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("开仓数量必须大于0: %.8f", quantity)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price, err := t.priceLocked(symbol)
+	if err != nil {
+		return nil, err
+	}
+	fillPrice := t.applySlippage(price, side == "long")
+	fee, err := t.openPositionLocked(symbol, side, quantity, leverage, fillPrice, t.cfg.TakerFeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := t.recordFill(0, symbol, "open_"+side, quantity, fillPrice, fee, 0, "order")
+	return filledResult(orderID, symbol, quantity, fillPrice, fee), nil
+}
+
+// openPositionLocked 以指定价格和费率开仓或加仓，返回手续费（调用方需持有锁）
+func (t *SimulatedTrader) openPositionLocked(symbol, side string, quantity float64, leverage int, fillPrice, feeRate float64) (float64, error) {
+	if leverage <= 0 {
+		leverage = t.leverages[symbol]
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+
+	notional := quantity * fillPrice
+	margin := notional / float64(leverage)
+	fee := notional * feeRate
+
+	free := t.balance + t.unrealizedPnLLocked() - t.usedMarginLocked()
+	if margin+fee > free {
+		return 0, fmt.Errorf("保证金不足: 需要 %.2f USDT, 可用 %.2f USDT", margin+fee, free)
+	}
+
+	key := positionKey(symbol, side)
+	pos, exists := t.positions[key]
+	if exists {
+		totalQty := pos.quantity + quantity
+		pos.entryPrice = (pos.entryPrice*pos.quantity + fillPrice*quantity) / totalQty
+		pos.quantity = totalQty
+		pos.margin += margin
+		pos.leverage = leverage
+		pos.fees += fee
+	} else {
+		pos = &simPosition{
+			symbol:     symbol,
+			side:       side,
+			quantity:   quantity,
+			entryPrice: fillPrice,
+			leverage:   leverage,
+			margin:     margin,
+			openTime:   t.clock(),
+			fees:       fee,
+		}
+		t.positions[key] = pos
+	}
+
+	t.balance -= fee
+	t.totalFees += fee
+	t.leverages[symbol] = leverage
+	return fee, nil
+}
+
+// fillLimitOrderLocked 限价单成交：按成交价开仓，保证金不足时置为拒绝（调用方需持有锁）
+func (t *SimulatedTrader) fillLimitOrderLocked(order *simLimitOrder, price, feeRate float64) error {
+	fee, err := t.openPositionLocked(order.symbol, order.side, order.quantity, order.leverage, price, feeRate)
+	if err != nil {
+		order.status = OrderStatusRejected
+		order.reason = err.Error()
+		return err
+	}
+	order.status = OrderStatusFilled
+	order.fillPrice = price
+	order.fee = fee
+	t.recordFill(order.id, order.symbol, "open_"+order.side, order.quantity, price, fee, 0, "limit")
+	return nil
+}
+
+// restingLimitOrdersLocked 该币种等待成交的限价单（按下单顺序，调用方需持有锁）
+func (t *SimulatedTrader) restingLimitOrdersLocked(symbol string) []*simLimitOrder {
+	var orders []*simLimitOrder
+	for _, order := range t.limitOrders {
+		if order.symbol == symbol && order.status == OrderStatusNew {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].id < orders[j].id })
+	return orders
+}
+
+// limitOrderResultLocked 限价单下单结果
+func (t *SimulatedTrader) limitOrderResultLocked(order *simLimitOrder) *OrderResult {
+	if order.status == OrderStatusFilled {
+		return filledResult(order.id, order.symbol, order.quantity, order.fillPrice, order.fee)
+	}
+	return &OrderResult{
+		OrderID: strconv.FormatInt(order.id, 10),
+		Symbol:  order.symbol,
+		Status:  order.status,
+	}
+}
+
+// toOrder 转换为统一订单模型
+func (o *simLimitOrder) toOrder() *Order {
+	order := &Order{
+		ExchangeOrderID: strconv.FormatInt(o.id, 10),
+		Symbol:          o.symbol,
+		Action:          "open_" + o.side,
+		PositionSide:    strings.ToUpper(o.side),
+		Type:            OrderTypeLimit,
+		Quantity:        o.quantity,
+		Price:           o.price,
+		Status:          o.status,
+		Error:           o.reason,
+	}
+	if o.status == OrderStatusFilled {
+		order.FilledQty = o.quantity
+		order.AvgPrice = o.fillPrice
+		order.Fee = o.fee
+	}
+	return order
+}
+
+// close 平仓
+func (t *SimulatedTrader) close(symbol, side string, quantity float64) (*OrderResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pos, ok := t.positions[positionKey(symbol, side)]
+	if !ok {
+		return nil, fmt.Errorf("没有找到 %s 的%s仓", symbol, side)
+	}
+	if quantity <= 0 || quantity > pos.quantity {
+		quantity = pos.quantity
+	}
+
+	price, err := t.priceLocked(symbol)
+	if err != nil {
+		return nil, err
+	}
+	fillPrice := t.applySlippage(price, side == "short")
+	orderID, fee := t.closePositionLocked(pos, quantity, fillPrice, "order")
+	return filledResult(orderID, symbol, quantity, fillPrice, fee), nil
+}
+
+// filledResult 模拟盘市价单成交结果
+func filledResult(orderID int64, symbol string, quantity, price, fee float64) *OrderResult {
+	return &OrderResult{
+		OrderID:     strconv.FormatInt(orderID, 10),
+		Symbol:      symbol,
+		Status:      OrderStatusFilled,
+		ExecutedQty: quantity,
+		AvgPrice:    price,
+		Fee:         fee,
+	}
+}
+
+// closePositionLocked 以指定价格平掉部分或全部持仓（调用方需持有锁）
+func (t *SimulatedTrader) closePositionLocked(pos *simPosition, quantity, price float64, reason string) (int64, float64) {
+	ratio := quantity / pos.quantity
+	pnl := (price - pos.entryPrice) * quantity
+	if pos.side == "short" {
+		pnl = -pnl
+	}
+	fee := quantity * price * t.cfg.TakerFeeRate
+	margin := pos.margin * ratio
+
+	// 强平时最多损失该仓位的全部保证金
+	if reason == "liquidation" && pnl < -margin {
+		pnl = -margin
+	}
+
+	t.balance += pnl - fee
+	t.totalFees += fee
+
+	openFee := pos.fees * ratio
+	funding := pos.funding * ratio
+	t.closedTrades = append(t.closedTrades, SimClosedTrade{
+		Symbol:      pos.symbol,
+		Side:        pos.side,
+		Quantity:    quantity,
+		EntryPrice:  pos.entryPrice,
+		ExitPrice:   price,
+		Leverage:    pos.leverage,
+		Margin:      margin,
+		OpenTime:    pos.openTime,
+		CloseTime:   t.clock(),
+		RealizedPnL: pnl,
+		Fees:        openFee + fee,
+		Funding:     funding,
+		Reason:      reason,
+	})
+	t.closedTrades, _ = trimHistory(t.closedTrades, t.cfg.MaxHistory)
+
+	pos.quantity -= quantity
+	pos.margin -= margin
+	pos.fees -= openFee
+	pos.funding -= funding
+	if pos.quantity <= 1e-12 {
+		delete(t.positions, positionKey(pos.symbol, pos.side))
+	}
+
+	orderID := t.recordFill(0, pos.symbol, "close_"+pos.side, quantity, price, fee, pnl, reason)
+	return orderID, fee
+}
+
+// recordFill 记录成交并返回订单ID（orderID为0时分配新ID，调用方需持有锁）
+func (t *SimulatedTrader) recordFill(orderID int64, symbol, action string, quantity, price, fee, pnl float64, reason string) int64 {
+	if orderID == 0 {
+		orderID = t.nextOrderID
+		t.nextOrderID++
+	}
+	t.fills = append(t.fills, SimFill{
+		OrderID:     orderID,
+		Time:        t.clock(),
+		Symbol:      symbol,
+		Action:      action,
+		Quantity:    quantity,
+		Price:       price,
+		Fee:         fee,
+		RealizedPnL: pnl,
+		Reason:      reason,
+	})
+	var dropped int
+	t.fills, dropped = trimHistory(t.fills, t.cfg.MaxHistory)
+	t.fillsDropped += dropped
+	return orderID
+}
+
+// trimHistory 超出max条时淘汰最早的记录，返回保留的记录和淘汰的条数（max<=0时不限制）
+func trimHistory[T any](items []T, max int) ([]T, int) {
+	if max <= 0 || len(items) <= max {
+		return items, 0
+	}
+	dropped := len(items) - max
+	return append([]T(nil), items[dropped:]...), dropped
+}
+
+// priceLocked 获取最新价格（调用方需持有锁）
+func (t *SimulatedTrader) priceLocked(symbol string) (float64, error) {
+	if t.priceSource != nil {
+		price, err := t.priceSource(symbol)
+		if err != nil {
+			return 0, fmt.Errorf("获取 %s 价格失败: %w", symbol, err)
+		}
+		if price > 0 {
+			t.prices[symbol] = price
+			return price, nil
+		}
+	}
+	price, ok := t.prices[symbol]
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("没有 %s 的价格数据", symbol)
+	}
+	return price, nil
+}
+
+// markPriceLocked 持仓标记价格（没有行情时使用开仓价）
+func (t *SimulatedTrader) markPriceLocked(pos *simPosition) float64 {
+	if price, ok := t.prices[pos.symbol]; ok && price > 0 {
+		return price
+	}
+	return pos.entryPrice
+}
+
+// unrealizedPnLLocked 所有持仓（含现货）未实现盈亏
+func (t *SimulatedTrader) unrealizedPnLLocked() float64 {
+	total := 0.0
+	for _, pos := range t.positions {
+		total += positionPnL(pos, t.markPriceLocked(pos))
+	}
+	for _, holding := range t.spot {
+		total += positionPnL(holding, t.markPriceLocked(holding))
+	}
+	return total
+}
+
+// usedMarginLocked 所有持仓占用保证金（现货按买入成本全额占用）
+func (t *SimulatedTrader) usedMarginLocked() float64 {
+	total := 0.0
+	for _, pos := range t.positions {
+		total += pos.margin
+	}
+	for _, holding := range t.spot {
+		total += holding.margin
+	}
+	return total
+}
+
+// liquidationPrice 计算逐仓强平价
+func (t *SimulatedTrader) liquidationPrice(pos *simPosition) float64 {
+	lev := float64(pos.leverage)
+	if pos.side == "long" {
+		return math.Max(0, pos.entryPrice*(1-1/lev+t.cfg.MaintenanceMarginRate))
+	}
+	return pos.entryPrice * (1 + 1/lev - t.cfg.MaintenanceMarginRate)
+}
+
+// applySlippage 按买卖方向施加滑点（买入价格上浮，卖出价格下浮）
+func (t *SimulatedTrader) applySlippage(price float64, isBuy bool) float64 {
+	slip := t.cfg.SlippageBps / 10000
+	if isBuy {
+		return price * (1 + slip)
+	}
+	return price * (1 - slip)
+}
+
+// positionPnL 计算持仓在指定价格下的盈亏
+func positionPnL(pos *simPosition, price float64) float64 {
+	pnl := (price - pos.entryPrice) * pos.quantity
+	if pos.side == "short" {
+		return -pnl
+	}
+	return pnl
+}
+
+// positionKey 持仓唯一键
+func positionKey(symbol, side string) string {
+	return symbol + "_" + side
+}
+
+// sideFromPositionSide 将 LONG/SHORT 转换为 long/short
+func sideFromPositionSide(positionSide string) string {
+	if positionSide == "SHORT" || positionSide == "short" {
+		return "short"
+	}
+	return "long"
+}
@@ -0,0 +1,187 @@
+package trader
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func newTestSimulatedTrader() *SimulatedTrader {
+	return NewSimulatedTrader(SimulatedTraderConfig{
+		InitialBalance:        1000,
+		TakerFeeRate:          0.001,
+		SlippageBps:           0,
+		MaintenanceMarginRate: 0.005,
+	})
+}
+
+func TestSimulatedTraderOpenCloseWithFees(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("BTCUSDT", 100)
+
+	order, err := sim.OpenLong("BTCUSDT", 2, 5)
+	if err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if order.OrderID == "" || order.Status != OrderStatusFilled || order.ExecutedQty != 2 || !approxEqual(order.AvgPrice, 100) {
+		t.Fatalf("市价单应立即成交: %+v", order)
+	}
+
+	balance, _ := sim.GetBalance()
+	if !approxEqual(balance.UsedMargin, 40) {
+		t.Errorf("占用保证金应为40, got %v", balance.UsedMargin)
+	}
+
+	sim.SetPrice("BTCUSDT", 110)
+	if _, err := sim.CloseLong("BTCUSDT", 0); err != nil {
+		t.Fatalf("平仓失败: %v", err)
+	}
+
+	// 盈利20，开仓手续费0.2，平仓手续费0.22
+	if !approxEqual(sim.Equity(), 1000+20-0.2-0.22) {
+		t.Errorf("净值计算错误: %v", sim.Equity())
+	}
+	if !approxEqual(sim.GetTotalFees(), 0.42) {
+		t.Errorf("手续费计算错误: %v", sim.GetTotalFees())
+	}
+
+	trades := sim.GetClosedTrades()
+	if len(trades) != 1 {
+		t.Fatalf("应有1笔平仓交易, got %d", len(trades))
+	}
+	if !approxEqual(trades[0].NetPnL(), 19.58) {
+		t.Errorf("净盈亏计算错误: %v", trades[0].NetPnL())
+	}
+}
+
+func TestSimulatedTraderStopLossAndTakeProfit(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("ETHUSDT", 100)
+
+	if _, err := sim.OpenShort("ETHUSDT", 1, 2); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := sim.SetStopLoss("ETHUSDT", "SHORT", 1, 105); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	if err := sim.SetTakeProfit("ETHUSDT", "SHORT", 1, 90); err != nil {
+		t.Fatalf("设置止盈失败: %v", err)
+	}
+
+	// 未触发
+	sim.OnBar("ETHUSDT", 104, 95, 98)
+	if positions, _ := sim.GetPositions(); len(positions) != 1 {
+		t.Fatalf("持仓不应被平掉")
+	}
+
+	// 同一根K线同时触发止损和止盈，按止损处理
+	sim.OnBar("ETHUSDT", 106, 89, 95)
+	if positions, _ := sim.GetPositions(); len(positions) != 0 {
+		t.Fatalf("持仓应被止损平掉")
+	}
+	trades := sim.GetClosedTrades()
+	if len(trades) != 1 || trades[0].Reason != "stop_loss" || !approxEqual(trades[0].ExitPrice, 105) {
+		t.Errorf("应按止损价105平仓: %+v", trades)
+	}
+}
+
+func TestSimulatedTraderLiquidation(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("SOLUSDT", 100)
+
+	if _, err := sim.OpenLong("SOLUSDT", 10, 10); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	positions, _ := sim.GetPositions()
+	liq := positions[0].LiquidationPrice
+	if !approxEqual(liq, 90.5) {
+		t.Fatalf("强平价应为90.5, got %v", liq)
+	}
+
+	sim.OnBar("SOLUSDT", 100, 80, 85)
+	trades := sim.GetClosedTrades()
+	if len(trades) != 1 || trades[0].Reason != "liquidation" {
+		t.Fatalf("应触发强平: %+v", trades)
+	}
+	// 强平最多损失全部保证金
+	if trades[0].RealizedPnL < -100-1e-9 {
+		t.Errorf("强平亏损不应超过保证金: %v", trades[0].RealizedPnL)
+	}
+}
+
+func TestSimulatedTraderFunding(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("BTCUSDT", 100)
+	sim.OpenLong("BTCUSDT", 1, 1)
+	sim.SetPrice("ETHUSDT", 100)
+	sim.OpenShort("ETHUSDT", 1, 1)
+
+	sim.ApplyFunding("BTCUSDT", 0.001)
+	sim.ApplyFunding("ETHUSDT", 0.001)
+
+	fundings := sim.GetFundings()
+	if len(fundings) != 2 {
+		t.Fatalf("应有2条资金费记录, got %d", len(fundings))
+	}
+	if !approxEqual(fundings[0].Payment, 0.1) || !approxEqual(fundings[1].Payment, -0.1) {
+		t.Errorf("多头应支付、空头应收取资金费: %+v", fundings)
+	}
+	if !approxEqual(sim.GetTotalFunding(), 0) {
+		t.Errorf("净资金费应为0, got %v", sim.GetTotalFunding())
+	}
+}
+
+func TestSimulatedTraderInsufficientMargin(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("BTCUSDT", 100)
+
+	if _, err := sim.OpenLong("BTCUSDT", 100, 5); err == nil {
+		t.Fatal("保证金不足时应拒绝开仓")
+	}
+}
+
+func TestSimulatedTraderMaxHistory(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.cfg.MaxHistory = 2
+	sim.SetPrice("BTCUSDT", 100)
+
+	for i := 0; i < 3; i++ {
+		if _, err := sim.OpenLong("BTCUSDT", 1, 5); err != nil {
+			t.Fatalf("开仓失败: %v", err)
+		}
+		sim.ApplyFunding("BTCUSDT", 0.001)
+		if _, err := sim.CloseLong("BTCUSDT", 0); err != nil {
+			t.Fatalf("平仓失败: %v", err)
+		}
+	}
+
+	// 只保留最近的记录，累计序号仍包括已淘汰的成交
+	if len(sim.GetFills()) != 2 || len(sim.GetFundings()) != 2 || len(sim.GetClosedTrades()) != 2 {
+		t.Fatalf("记录应限制在2条: fills=%d fundings=%d trades=%d",
+			len(sim.GetFills()), len(sim.GetFundings()), len(sim.GetClosedTrades()))
+	}
+	if sim.FillCount() != 6 {
+		t.Errorf("累计成交笔数应为6: %d", sim.FillCount())
+	}
+	before := sim.FillCount()
+	if _, err := sim.OpenShort("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if fills := sim.FillsSince(before); len(fills) != 1 || fills[0].Action != "open_short" {
+		t.Errorf("应只返回新成交: %+v", fills)
+	}
+
+	// 账单引用按累计序号编号，淘汰旧记录后不会与已同步的流水重复
+	records, _ := sim.GetIncomeHistory(time.Time{})
+	refs := make(map[string]bool)
+	for _, r := range records {
+		refs[r.Ref] = true
+	}
+	if !refs["fill_6"] || !refs["funding_2"] || refs["fill_0"] {
+		t.Errorf("账单引用应按累计序号编号: %+v", records)
+	}
+}
@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+)
+
+// SimulationConfig 模拟执行配置
+type SimulationConfig struct {
+	TraderID       string
+	Exchange       string // 订单记录中的交易所标识（如 replay、backtest）
+	InitialBalance float64
+	IsCrossMargin  bool
+	Execution      ExecutionConfig                           // 开仓执行配置（为空时使用默认配置，决策可单独指定）
+	Clock          func() time.Time                          // 模拟时钟
+	MarketData     func(symbol string) (*market.Data, error) // 执行决策时的行情
+}
+
+// SimulationExecutor 在模拟交易所上执行AI决策（决策回放和历史回测共用）
+// 与实盘走同一条 executeDecisionWithRecord 路径：同向持仓拒绝、80%保证金上限、$10最小金额、
+// OrderManager执行模式（市价/限价/TWAP）、止损止盈挂单和多腿组合决策
+type SimulationExecutor struct {
+	at *AutoTrader
+}
+
+// NewSimulationExecutor 创建模拟执行器
+func NewSimulationExecutor(sim *SimulatedTrader, cfg SimulationConfig) *SimulationExecutor {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
+	orderManager := NewOrderManager(cfg.TraderID, cfg.Exchange, sim, nil)
+	orderManager.clock = clock
+	orderManager.sleep = func(time.Duration) {} // 模拟执行时限价/TWAP不等待
+
+	positionGroups := NewPositionGroupManager(cfg.TraderID, nil)
+	positionGroups.clock = clock
+
+	return &SimulationExecutor{at: &AutoTrader{
+		id:                    cfg.TraderID,
+		name:                  cfg.TraderID,
+		exchange:              cfg.Exchange,
+		config:                AutoTraderConfig{ID: cfg.TraderID, IsCrossMargin: cfg.IsCrossMargin},
+		trader:                sim,
+		kellyManager:          decision.NewKellyStopManager(),
+		initialBalance:        cfg.InitialBalance,
+		positionFirstSeenTime: make(map[string]int64),
+		orderManager:          orderManager,
+		positionGroups:        positionGroups,
+		execution:             cfg.Execution,
+		clock:                 clock,
+		marketDataProvider:    cfg.MarketData,
+	}}
+}
+
+// Execute 按实盘顺序（先平仓后开仓）执行决策，返回每个决策的执行记录
+func (x *SimulationExecutor) Execute(decisions []decision.Decision) []logger.DecisionAction {
+	var actions []logger.DecisionAction
+	for _, d := range sortDecisionsByPriority(sortDecisionsBySymbol(decisions)) {
+		action := logger.DecisionAction{
+			Action:    d.Action,
+			Symbol:    d.Symbol,
+			Leverage:  d.Leverage,
+			Timestamp: x.at.now(),
+		}
+		if err := x.at.executeDecisionWithRecord(&d, &action); err != nil {
+			action.Error = err.Error()
+		} else {
+			action.Success = true
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// CheckPositionGroups 按合计盈亏检查组合止损止盈（对应实盘每个周期开始时的检查），返回触发的组合平仓记录
+func (x *SimulationExecutor) CheckPositionGroups() []logger.DecisionAction {
+	record := &logger.DecisionRecord{}
+	x.at.checkPositionGroups(&decision.Context{}, record)
+	return record.Decisions
+}
+
+// PositionGroups 组合持仓的盈亏信息（供决策上下文使用）
+func (x *SimulationExecutor) PositionGroups(positions []Position) []decision.PositionGroupInfo {
+	return x.at.positionGroupInfos(positions)
+}
+
+// TradeTimes 开平仓时间记录（symbol_side、symbol|close_side -> 模拟时钟毫秒时间戳），用于冷却期检查
+func (x *SimulationExecutor) TradeTimes() map[string]int64 {
+	times := make(map[string]int64, len(x.at.positionFirstSeenTime))
+	for key, ts := range x.at.positionFirstSeenTime {
+		times[key] = ts
+	}
+	return times
+}
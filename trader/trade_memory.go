@@ -0,0 +1,366 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/database"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/mem0"
+)
+
+// 进程内共享的记忆存储（所有交易员共用，记忆按trader_id区分）和按用户区分的冷启动知识库
+var (
+	sharedMemoryMu    sync.Mutex
+	sharedMemoryStore mem0.MemoryStore
+	sharedMemoryCfg   *mem0.Config
+	sharedColdStarts  = make(map[string]*mem0.ColdStartFallback) // 用户ID -> 冷启动知识库
+)
+
+// memoryInitRetryInterval mem0.enabled标志开启后创建交易记忆失败的重试间隔
+const memoryInitRetryInterval = 10 * time.Minute
+
+// loadSharedMemoryStore 按系统配置创建共享记忆存储和该用户的冷启动知识库（失败不缓存以便下次重试）
+func loadSharedMemoryStore(db *config.Database, userID string) (mem0.MemoryStore, *mem0.Config, *mem0.ColdStartFallback, error) {
+	sharedMemoryMu.Lock()
+	defer sharedMemoryMu.Unlock()
+	if sharedMemoryStore == nil {
+		cfg, err := mem0.LoadSettings(db)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		store, err := mem0.NewMemoryStore(context.Background(), cfg, db.GetDB())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sharedMemoryStore, sharedMemoryCfg = store, cfg
+	}
+
+	coldStart := sharedColdStarts[userID]
+	if coldStart == nil {
+		coldStart = mem0.NewColdStartFallback(newUserKnowledgeBase(sharedMemoryStore, userID, db.GetTraders))
+		sharedColdStarts[userID] = coldStart
+	}
+	return sharedMemoryStore, sharedMemoryCfg, coldStart, nil
+}
+
+// newUserKnowledgeBase 创建用户的冷启动知识库：只加载该用户名下交易员的高质量已评估记忆
+// （每次同步时重新获取交易员列表），知识库为空时使用默认参考案例
+func newUserKnowledgeBase(store mem0.MemoryStore, userID string, listTraders func(userID string) ([]*config.TraderRecord, error)) *mem0.GlobalKnowledgeBase {
+	kb := mem0.NewGlobalKnowledgeBase(store).SetFilterFunc(func() []mem0.QueryFilter {
+		traderIDs := []string{}
+		traders, err := listTraders(userID)
+		if err != nil {
+			log.Printf("⚠️ 获取用户 %s 的交易员失败，冷启动知识库暂不加载记忆: %v", userID, err)
+		}
+		for _, t := range traders {
+			traderIDs = append(traderIDs, t.ID)
+		}
+		return []mem0.QueryFilter{{Field: "metadata.trader_id", Operator: "in", Value: traderIDs}}
+	})
+	if err := kb.Initialize(context.Background()); err != nil {
+		log.Printf("⚠️ 记忆知识库初始化失败，冷启动使用默认参考案例: %v", err)
+	}
+	return kb
+}
+
+// newTradeMemory 为交易员创建交易记忆（mem0.enabled标志对交易员所属用户关闭或初始化失败时返回nil，不影响交易）
+func newTradeMemory(cfg AutoTraderConfig) *TradeMemory {
+	if cfg.Database == nil || !config.GetGlobalFeatureFlags().IsEnabledForUser(config.Mem0Enabled, cfg.UserID) {
+		return nil
+	}
+	store, memCfg, coldStart, err := loadSharedMemoryStore(cfg.Database, cfg.UserID)
+	if err != nil {
+		log.Printf("⚠️ [%s] 交易记忆不可用: %v", cfg.Name, err)
+		return nil
+	}
+
+	tm := NewTradeMemory(cfg.ID, store, memCfg, coldStart)
+	if trades, err := cfg.Database.GetTradesInPeriod(cfg.ID, time.Time{}, time.Now()); err == nil {
+		tm.SeedStage(trades)
+	}
+	log.Printf("🧠 [%s] 已启用交易记忆 (学习阶段: %s)", cfg.Name, tm.Stage())
+	return tm
+}
+
+// activeMemory 获取本周期使用的交易记忆：mem0.enabled标志关闭时返回nil（已创建的记忆暂停使用），
+// 标志在运行中开启时按间隔尝试创建
+func (at *AutoTrader) activeMemory() *TradeMemory {
+	if !config.GetGlobalFeatureFlags().IsEnabledForUser(config.Mem0Enabled, at.userID) {
+		return nil
+	}
+	if at.memory == nil && time.Since(at.memoryInitAt) >= memoryInitRetryInterval {
+		at.memoryInitAt = time.Now()
+		at.memory = newTradeMemory(at.config)
+	}
+	return at.memory
+}
+
+// TradeMemory 交易记忆
+// 决策前按候选币种检索相似行情下的历史交易注入prompt（按Kelly学习阶段过滤），
+// 决策执行后写入决策记忆，平仓后写入关联开仓决策的结果记忆
+type TradeMemory struct {
+	traderID string
+	store    mem0.MemoryStore
+	enricher *decision.MemoryEnricher
+	stage    *decision.LearningStageManager
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	pending map[string]pendingMemory // symbol_side -> 等待平仓结果的决策记忆
+}
+
+// pendingMemory 等待平仓结果的决策记忆
+type pendingMemory struct {
+	openID   string                 // 开仓决策记忆ID
+	closeIDs []string               // 平仓决策记忆ID（决策平仓时）
+	setup    string                 // 开仓时的行情描述
+	metadata map[string]interface{} // 开仓决策的元数据（仓位比例、杠杆等）
+}
+
+// NewTradeMemory 创建交易记忆
+func NewTradeMemory(traderID string, store mem0.MemoryStore, cfg *mem0.Config, coldStart *mem0.ColdStartFallback) *TradeMemory {
+	tm := &TradeMemory{
+		traderID: traderID,
+		store:    store,
+		stage:    decision.NewLearningStageManager(),
+		timeout:  10 * time.Second,
+		pending:  make(map[string]pendingMemory),
+	}
+	tm.enricher = decision.NewMemoryEnricher(store, cfg).
+		SetStageProvider(tm.Stage).
+		SetColdStartFallback(coldStart).
+		SetFilters(mem0.QueryFilter{Field: "metadata.trader_id", Operator: "eq", Value: traderID})
+	return tm
+}
+
+// Enricher 决策上下文增强器（检索相似历史交易）
+func (tm *TradeMemory) Enricher() decision.ContextEnricher {
+	return tm.enricher
+}
+
+// SeedStage 用历史平仓记录恢复学习阶段（重启后不必从infant阶段重新开始）
+func (tm *TradeMemory) SeedStage(trades []database.TradeRecord) {
+	for _, t := range trades {
+		tm.stage.UpdateTradeStats(t.ProfitPct > 0)
+	}
+}
+
+// Stage 交易员当前的Kelly学习阶段（决定注入哪些历史记忆）
+func (tm *TradeMemory) Stage() mem0.KellyStage {
+	switch tm.stage.GetCurrentStage() {
+	case decision.StageMature:
+		return mem0.StageMature
+	case decision.StageChild:
+		return mem0.StageChild
+	default:
+		return mem0.StageInfant
+	}
+}
+
+// RecordDecisions 将本周期成功执行的开平仓决策写入记忆（decisions与actions一一对应）
+func (tm *TradeMemory) RecordDecisions(ctx *decision.Context, decisions []decision.Decision, actions []logger.DecisionAction) {
+	type entry struct {
+		key    string
+		isOpen bool
+		setup  string
+		memory mem0.Memory
+	}
+	var entries []entry
+	for i, d := range decisions {
+		if i >= len(actions) || !actions[i].Success {
+			continue
+		}
+		a := actions[i]
+		var side string
+		switch d.Action {
+		case "open_long", "close_long":
+			side = "long"
+		case "open_short", "close_short":
+			side = "short"
+		default:
+			continue
+		}
+		isOpen := strings.HasPrefix(d.Action, "open_")
+
+		setup := decision.DescribeSetup(d.Symbol, ctx.MarketDataMap[d.Symbol])
+		metadata := map[string]interface{}{
+			"trader_id":  tm.traderID,
+			"symbol":     d.Symbol,
+			"side":       side,
+			"action":     d.Action,
+			"confidence": d.Confidence,
+			"price":      a.Price,
+			"cycle":      ctx.CallCount,
+		}
+		content := fmt.Sprintf("%s | 决策: %s", setup, d.Action)
+		if isOpen {
+			leverage := a.Leverage
+			if leverage <= 0 {
+				leverage = 1
+			}
+			metadata["leverage"] = leverage
+			if equity := ctx.Account.TotalEquity; equity > 0 && a.PositionSizeUSD > 0 {
+				// 保证金占净值比例即下注比例；有止损时按止损亏损占净值计算凯利比例
+				margin := a.PositionSizeUSD / leverage
+				metadata["position_size"] = roundTo(margin/equity, 4)
+				metadata["kelly_fraction"] = roundTo(margin/equity, 4)
+				if a.Price > 0 && d.StopLoss > 0 {
+					metadata["kelly_fraction"] = roundTo(math.Abs(a.Price-d.StopLoss)/a.Price*a.PositionSizeUSD/equity, 4)
+				}
+			}
+			content += fmt.Sprintf(" 杠杆%.0fx 信心%d", leverage, d.Confidence)
+		}
+		if reasoning := truncateRunes(strings.TrimSpace(d.Reasoning), 300); reasoning != "" {
+			content += " | 理由: " + reasoning
+		}
+
+		entries = append(entries, entry{
+			key:    d.Symbol + "_" + side,
+			isOpen: isOpen,
+			setup:  setup,
+			memory: mem0.Memory{
+				Content:      content,
+				Type:         "decision",
+				Status:       "pending",
+				QualityScore: 0.5, // 结果未知，平仓后由结果记忆给出质量分
+				Metadata:     metadata,
+			},
+		})
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	memories := make([]mem0.Memory, len(entries))
+	for i, e := range entries {
+		memories[i] = e.memory
+	}
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), tm.timeout)
+	defer cancel()
+	ids, err := tm.store.SaveBatch(ctxTimeout, memories, nil)
+	if err != nil {
+		log.Printf("⚠️ [%s] 保存决策记忆失败: %v", tm.traderID, err)
+		return
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for i, e := range entries {
+		if i >= len(ids) || ids[i] == "" {
+			continue
+		}
+		if e.isOpen {
+			tm.pending[e.key] = pendingMemory{openID: ids[i], setup: e.setup, metadata: e.memory.Metadata}
+			continue
+		}
+		p := tm.pending[e.key]
+		p.closeIDs = append(p.closeIDs, ids[i])
+		tm.pending[e.key] = p
+	}
+}
+
+// RecordOutcome 平仓后写入结果记忆（关联开仓决策并标记为已评估），同时更新学习阶段
+func (tm *TradeMemory) RecordOutcome(event PositionEvent) {
+	tm.stage.UpdateTradeStats(event.NetPnL() > 0)
+
+	key := event.Symbol + "_" + event.Side
+	tm.mu.Lock()
+	p, ok := tm.pending[key]
+	delete(tm.pending, key)
+	tm.mu.Unlock()
+
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), tm.timeout)
+	defer cancel()
+
+	// 重启后内存中没有开仓决策时，查找该方向最近一条未评估的开仓决策
+	if !ok {
+		results, err := tm.store.Search(ctxTimeout, mem0.Query{
+			Type: "direct_lookup",
+			Filters: []mem0.QueryFilter{
+				{Field: "type", Operator: "eq", Value: "decision"},
+				{Field: "status", Operator: "eq", Value: "pending"},
+				{Field: "metadata.trader_id", Operator: "eq", Value: tm.traderID},
+				{Field: "metadata.symbol", Operator: "eq", Value: event.Symbol},
+				{Field: "metadata.side", Operator: "eq", Value: event.Side},
+				{Field: "metadata.action", Operator: "eq", Value: "open_" + event.Side},
+			},
+			Limit: 1,
+		})
+		if err == nil && len(results) > 0 {
+			p = pendingMemory{openID: results[0].ID, setup: strings.SplitN(results[0].Content, " | ", 2)[0], metadata: results[0].Metadata}
+		}
+	}
+
+	returnPct := event.MarginReturnPct()
+	metadata := map[string]interface{}{}
+	for k, v := range p.metadata {
+		metadata[k] = v
+	}
+	metadata["trader_id"] = tm.traderID
+	metadata["symbol"] = event.Symbol
+	metadata["side"] = event.Side
+	metadata["action"] = "close_" + event.Side
+	metadata["close_reason"] = event.Reason
+	metadata["pnl"] = roundTo(event.NetPnL(), 4)
+	metadata["return_pct"] = roundTo(returnPct, 2)
+	metadata["holding_minutes"] = int(event.HoldingTime().Minutes())
+	metadata["win"] = event.NetPnL() > 0
+
+	setup := p.setup
+	if setup == "" {
+		setup = event.Symbol
+	}
+	memory := mem0.Memory{
+		Content: fmt.Sprintf("%s | 结果: %s %s平仓(%s) 净盈亏%+.2f USDT (保证金收益%+.1f%%) 持仓%d分钟",
+			setup, event.Symbol, strings.ToUpper(event.Side), event.Reason, event.NetPnL(), returnPct, int(event.HoldingTime().Minutes())),
+		Type:         "outcome",
+		Status:       "evaluated",
+		QualityScore: outcomeQuality(returnPct),
+		Metadata:     metadata,
+	}
+	if p.openID != "" {
+		metadata["decision_id"] = p.openID
+		memory.Relationships = []mem0.Relationship{{Type: "outcome_of", Target: p.openID, Weight: 1}}
+	}
+
+	if _, err := tm.store.Save(ctxTimeout, memory, nil); err != nil {
+		log.Printf("⚠️ [%s] 保存交易结果记忆失败: %v", tm.traderID, err)
+		return
+	}
+	for _, id := range append([]string{p.openID}, p.closeIDs...) {
+		if id == "" {
+			continue
+		}
+		if err := tm.store.UpdateStatus(ctxTimeout, id, "evaluated"); err != nil {
+			log.Printf("⚠️ [%s] 更新决策记忆状态失败 (%s): %v", tm.traderID, id, err)
+		}
+	}
+}
+
+// outcomeQuality 结果记忆的质量分：保证金收益+20%及以上为1，-18%及以下为0.05，按收益线性插值
+func outcomeQuality(returnPct float64) float64 {
+	return roundTo(math.Max(0.05, math.Min(1, 0.5+returnPct/40)), 2)
+}
+
+// roundTo 按小数位四舍五入
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,187 @@
+package trader
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/config"
+	"nofx/database"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mem0"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestTradeMemory(t *testing.T) (*TradeMemory, mem0.MemoryStore) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := mem0.NewPostgresStore(context.Background(), db, mem0.NewHashEmbedder(256),
+		mem0.PostgresStoreOptions{VectorSearch: mem0.VectorSearchGo})
+	if err != nil {
+		t.Fatalf("创建记忆存储失败: %v", err)
+	}
+	return NewTradeMemory("trader-1", store, nil, nil), store
+}
+
+func TestTradeMemoryRecordsDecisionAndOutcome(t *testing.T) {
+	tm, store := newTestTradeMemory(t)
+	ctx := &decision.Context{
+		CallCount:     7,
+		Account:       decision.AccountInfo{TotalEquity: 1000},
+		MarketDataMap: map[string]*market.Data{"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 100000, PriceChange1h: 1.2}},
+	}
+	decisions := []decision.Decision{
+		{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1500, StopLoss: 98000, Confidence: 80, Reasoning: "突破确认"},
+		{Symbol: "ETHUSDT", Action: "open_short", Leverage: 3, PositionSizeUSD: 600},
+		{Symbol: "SOLUSDT", Action: "hold"},
+	}
+	actions := []logger.DecisionAction{
+		{Action: "open_long", Symbol: "BTCUSDT", Leverage: 5, Price: 100000, PositionSizeUSD: 1500, Success: true},
+		{Action: "open_short", Symbol: "ETHUSDT", Error: "风控拦截"},
+		{Action: "hold", Symbol: "SOLUSDT", Success: true},
+	}
+	tm.RecordDecisions(ctx, decisions, actions)
+
+	saved, err := store.SearchByType(context.Background(), "decision", 10)
+	if err != nil || len(saved) != 1 {
+		t.Fatalf("只应记录成功的开平仓决策: %+v, err=%v", saved, err)
+	}
+	open := saved[0]
+	if open.Status != "pending" || open.Metadata["trader_id"] != "trader-1" || !strings.Contains(open.Content, "BTCUSDT 1h强势上涨") {
+		t.Errorf("决策记忆内容不正确: %+v", open)
+	}
+	// 保证金300/净值1000；止损2%对应名义1500的风险30/净值1000
+	if !approxEqual(open.Metadata["position_size"].(float64), 0.3) || !approxEqual(open.Metadata["kelly_fraction"].(float64), 0.03) {
+		t.Errorf("仓位比例或凯利比例不正确: %+v", open.Metadata)
+	}
+
+	now := time.Now()
+	tm.RecordOutcome(PositionEvent{
+		Type: "closed", Symbol: "BTCUSDT", Side: "long", Quantity: 0.015, EntryPrice: 100000, ExitPrice: 102000,
+		Leverage: 5, OpenTime: now.Add(-90 * time.Minute), CloseTime: now, RealizedPnL: 30, Fees: 1, Reason: "take_profit",
+	})
+
+	outcomes, err := store.SearchByType(context.Background(), "outcome", 10)
+	if err != nil || len(outcomes) != 1 {
+		t.Fatalf("应记录一条结果记忆: %+v, err=%v", outcomes, err)
+	}
+	outcome := outcomes[0]
+	if outcome.Status != "evaluated" || outcome.Metadata["decision_id"] != open.ID || outcome.QualityScore <= 0.5 ||
+		!strings.Contains(outcome.Content, "take_profit") || !strings.Contains(outcome.Content, "持仓90分钟") {
+		t.Errorf("结果记忆内容不正确: %+v", outcome)
+	}
+	if rels, _ := store.GetRelationships(context.Background(), outcome.ID); len(rels) == 0 || rels[0].Target != open.ID {
+		t.Errorf("结果记忆应关联开仓决策: %+v", rels)
+	}
+	if got, _ := store.GetByID(context.Background(), open.ID); got == nil || got.Status != "evaluated" {
+		t.Errorf("开仓决策应标记为已评估: %+v", got)
+	}
+}
+
+func TestTradeMemoryOutcomeAfterRestart(t *testing.T) {
+	tm, store := newTestTradeMemory(t)
+	id, err := store.Save(context.Background(), mem0.Memory{
+		Content: "ETHUSDT 1h下跌 | 决策: open_short", Type: "decision", Status: "pending", QualityScore: 0.5,
+		Metadata: map[string]interface{}{"trader_id": "trader-1", "symbol": "ETHUSDT", "side": "short", "action": "open_short"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	// 重启后内存中没有待评估决策，按币种和方向查找
+	now := time.Now()
+	tm.RecordOutcome(PositionEvent{Symbol: "ETHUSDT", Side: "short", Quantity: 1, EntryPrice: 3000, ExitPrice: 3100,
+		Leverage: 3, OpenTime: now.Add(-time.Hour), CloseTime: now, RealizedPnL: -100, Reason: "stop_loss"})
+
+	outcomes, _ := store.SearchByType(context.Background(), "outcome", 10)
+	if len(outcomes) != 1 || outcomes[0].Metadata["decision_id"] != id || outcomes[0].QualityScore >= 0.5 ||
+		!strings.HasPrefix(outcomes[0].Content, "ETHUSDT 1h下跌 | 结果") {
+		t.Fatalf("结果记忆应关联重启前的决策: %+v", outcomes)
+	}
+}
+
+func TestTradeMemoryStage(t *testing.T) {
+	tm, _ := newTestTradeMemory(t)
+	if tm.Stage() != mem0.StageInfant {
+		t.Fatalf("初始应为infant阶段: %s", tm.Stage())
+	}
+	trades := make([]database.TradeRecord, 20)
+	for i := range trades {
+		trades[i].ProfitPct = float64(i%3) - 1
+	}
+	tm.SeedStage(trades[:5])
+	if tm.Stage() != mem0.StageChild {
+		t.Errorf("5笔交易后应为child阶段: %s", tm.Stage())
+	}
+	tm.SeedStage(trades[5:])
+	if tm.Stage() != mem0.StageMature {
+		t.Errorf("20笔交易后应为mature阶段: %s", tm.Stage())
+	}
+}
+
+func TestUserKnowledgeBaseScopedToUserTraders(t *testing.T) {
+	_, store := newTestTradeMemory(t)
+	for _, traderID := range []string{"own-1", "own-2", "other-1"} {
+		if _, err := store.Save(context.Background(), mem0.Memory{
+			Content:      traderID + " 高质量交易",
+			Type:         "outcome",
+			Status:       "evaluated",
+			QualityScore: 0.9,
+			Metadata:     map[string]interface{}{"trader_id": traderID},
+		}, nil); err != nil {
+			t.Fatalf("保存记忆失败: %v", err)
+		}
+	}
+
+	listTraders := func(userID string) ([]*config.TraderRecord, error) {
+		if userID != "user-1" {
+			return nil, nil
+		}
+		return []*config.TraderRecord{{ID: "own-1"}, {ID: "own-2"}}, nil
+	}
+	refs := newUserKnowledgeBase(store, "user-1", listTraders).GetReferencesForColdStart(5)
+	if len(refs) != 2 {
+		t.Fatalf("冷启动参考应只包含该用户交易员的记忆: %+v", refs)
+	}
+	for _, m := range refs {
+		if m.Metadata["trader_id"] == "other-1" {
+			t.Errorf("不应包含其他用户交易员的记忆: %+v", m)
+		}
+	}
+	if refs := newUserKnowledgeBase(store, "user-2", listTraders).GetReferencesForColdStart(5); len(refs) != 0 {
+		t.Errorf("没有交易员的用户不应加载任何记忆: %+v", refs)
+	}
+}
+
+func TestActiveMemoryFollowsFeatureFlag(t *testing.T) {
+	flags := config.NewFeatureFlagManager()
+	prev := config.GetGlobalFeatureFlags()
+	config.SetGlobalFeatureFlags(flags)
+	t.Cleanup(func() { config.SetGlobalFeatureFlags(prev) })
+
+	tm, _ := newTestTradeMemory(t)
+	at := &AutoTrader{userID: "user-1", memory: tm, memoryInitAt: time.Now()}
+	if at.activeMemory() != nil {
+		t.Error("mem0.enabled默认关闭时不应使用交易记忆")
+	}
+
+	flags.SaveFlag(config.FeatureFlag{Name: config.Mem0Enabled, Enabled: true, Percentage: 100, DenyUsers: []string{"user-2"}})
+	if at.activeMemory() != tm {
+		t.Error("标志开启后应恢复使用已创建的交易记忆")
+	}
+	at.userID = "user-2"
+	if at.activeMemory() != nil {
+		t.Error("黑名单用户不应使用交易记忆")
+	}
+}
@@ -0,0 +1,427 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+)
+
+// TradingSchedule 交易员的交易时段和停牌事件配置（JSON存储在 traders.trading_schedule）
+// 时段外和停牌事件前后只管理现有持仓，不开新仓
+type TradingSchedule struct {
+	Timezone           string          `json:"timezone,omitempty"`             // IANA时区（如 America/New_York），为空表示UTC
+	Windows            []string        `json:"windows,omitempty"`              // 允许开仓的每周时段，如 "mon-fri 08:00-20:00"，为空表示全天
+	UseCalendar        bool            `json:"use_calendar,omitempty"`         // 是否启用系统停牌日历（FOMC、CPI等）
+	Blackouts          []BlackoutEvent `json:"blackouts,omitempty"`            // 交易员自定义的停牌事件
+	BeforeMinutes      int             `json:"before_minutes,omitempty"`       // 事件前禁止开仓的分钟数（0使用系统配置）
+	AfterMinutes       int             `json:"after_minutes,omitempty"`        // 事件后禁止开仓的分钟数（0使用系统配置）
+	FlattenBeforeEvent bool            `json:"flatten_before_event,omitempty"` // 进入事件禁止开仓期后平掉所有持仓
+
+	location *time.Location
+	windows  []scheduleWindow
+}
+
+// BlackoutEvent 停牌事件（宏观数据发布、议息会议等）
+type BlackoutEvent struct {
+	Name          string    `json:"name"`
+	Time          time.Time `json:"time"`
+	BeforeMinutes int       `json:"before_minutes,omitempty"` // 覆盖交易员/系统的事件前分钟数
+	AfterMinutes  int       `json:"after_minutes,omitempty"`  // 覆盖交易员/系统的事件后分钟数
+}
+
+// key 事件唯一标识（用于记录已执行的事件前平仓）
+func (e BlackoutEvent) key() string {
+	return fmt.Sprintf("%s@%d", e.Name, e.Time.Unix())
+}
+
+// scheduleWindow 解析后的每周时段（分钟为当天0点起的分钟数，end<=start表示跨午夜）
+type scheduleWindow struct {
+	spec  string
+	days  [7]bool
+	start int
+	end   int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseTradingSchedule 解析并校验交易时段配置（空字符串表示不限制，返回nil）
+func ParseTradingSchedule(raw string) (*TradingSchedule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var s TradingSchedule
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, fmt.Errorf("交易时段配置格式错误: %w", err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// compile 校验时区、时段和事件，生成内部使用的时段表
+func (s *TradingSchedule) compile() error {
+	s.location = time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("无效的时区 %s: %w", s.Timezone, err)
+		}
+		s.location = loc
+	}
+	s.windows = nil
+	for _, spec := range s.Windows {
+		w, err := parseScheduleWindow(spec)
+		if err != nil {
+			return err
+		}
+		s.windows = append(s.windows, w)
+	}
+	if s.BeforeMinutes < 0 || s.AfterMinutes < 0 {
+		return fmt.Errorf("事件前后禁止开仓分钟数不能为负数")
+	}
+	for _, e := range s.Blackouts {
+		if strings.TrimSpace(e.Name) == "" || e.Time.IsZero() {
+			return fmt.Errorf("停牌事件必须提供 name 和 time")
+		}
+		if e.BeforeMinutes < 0 || e.AfterMinutes < 0 {
+			return fmt.Errorf("停牌事件 %s 的前后分钟数不能为负数", e.Name)
+		}
+	}
+	return nil
+}
+
+// parseScheduleWindow 解析 "mon-fri 08:00-20:00"、"sat,sun 10:00-14:00"、"* 22:00-06:00" 或 "08:00-20:00"（每天）
+func parseScheduleWindow(spec string) (scheduleWindow, error) {
+	w := scheduleWindow{spec: spec}
+	fields := strings.Fields(strings.ToLower(spec))
+	var days, hours string
+	switch len(fields) {
+	case 1:
+		days, hours = "*", fields[0]
+	case 2:
+		days, hours = fields[0], fields[1]
+	default:
+		return w, fmt.Errorf("无效的交易时段 %q，格式如 \"mon-fri 08:00-20:00\"", spec)
+	}
+
+	for _, part := range strings.Split(days, ",") {
+		if part == "*" {
+			w.days = [7]bool{true, true, true, true, true, true, true}
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, ok := weekdayNames[from]
+		if !ok {
+			return w, fmt.Errorf("交易时段 %q 中无效的星期: %s", spec, from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdayNames[to]; !ok {
+				return w, fmt.Errorf("交易时段 %q 中无效的星期: %s", spec, to)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return w, fmt.Errorf("无效的交易时段 %q，时间格式如 08:00-20:00", spec)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return w, fmt.Errorf("交易时段 %q: %w", spec, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return w, fmt.Errorf("交易时段 %q: %w", spec, err)
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("交易时段 %q 开始和结束时间相同", spec)
+	}
+	return w, nil
+}
+
+// parseClock 解析 HH:MM 为当天分钟数（允许 24:00）
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("无效的时间 %s", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// contains 时段是否包含该本地时间
+func (w scheduleWindow) contains(t time.Time) bool {
+	day := t.Weekday()
+	minutes := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[day] && minutes >= w.start && minutes < w.end
+	}
+	// 跨午夜：前一天开始的时段延续到当天 end
+	return (w.days[day] && minutes >= w.start) || (w.days[(day+6)%7] && minutes < w.end)
+}
+
+// inWindow 当前时间是否在允许开仓的时段内（未配置时段表示全天）
+func (s *TradingSchedule) inWindow(now time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+	local := now.In(s.location)
+	for _, w := range s.windows {
+		if w.contains(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleConfig 交易时段的系统配置
+type ScheduleConfig struct {
+	CalendarFile  string // 停牌日历文件（JSON）
+	BeforeMinutes int    // 事件前默认禁止开仓分钟数
+	AfterMinutes  int    // 事件后默认禁止开仓分钟数
+}
+
+// DefaultScheduleConfig 默认交易时段配置
+func DefaultScheduleConfig() ScheduleConfig {
+	return ScheduleConfig{
+		CalendarFile:  "calendar/blackout_events.json",
+		BeforeMinutes: 30,
+		AfterMinutes:  30,
+	}
+}
+
+// LoadScheduleConfig 从系统配置加载交易时段配置
+func LoadScheduleConfig(db *config.Database) ScheduleConfig {
+	cfg := DefaultScheduleConfig()
+	if db == nil {
+		return cfg
+	}
+	get := db.SystemConfigValue
+	if path := get("blackout_calendar_file"); path != "" {
+		cfg.CalendarFile = path
+	}
+	if n, err := strconv.Atoi(get("blackout_before_minutes")); err == nil && n >= 0 {
+		cfg.BeforeMinutes = n
+	}
+	if n, err := strconv.Atoi(get("blackout_after_minutes")); err == nil && n >= 0 {
+		cfg.AfterMinutes = n
+	}
+	return cfg
+}
+
+// LoadBlackoutCalendar 读取停牌日历文件（{"events": [{"name": "FOMC", "time": "2026-01-28T14:00:00-05:00"}]}）
+func LoadBlackoutCalendar(path string) ([]BlackoutEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取停牌日历失败: %w", err)
+	}
+	var calendar struct {
+		Events []BlackoutEvent `json:"events"`
+	}
+	if err := json.Unmarshal(data, &calendar); err != nil {
+		return nil, fmt.Errorf("解析停牌日历失败: %w", err)
+	}
+	events := make([]BlackoutEvent, 0, len(calendar.Events))
+	for _, e := range calendar.Events {
+		if e.Name == "" || e.Time.IsZero() {
+			log.Printf("⚠️ 停牌日历中缺少 name 或 time 的事件已忽略: %+v", e)
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+// ScheduleStatus 某一时刻的交易时段状态
+type ScheduleStatus struct {
+	AllowEntries bool           // 是否允许开新仓
+	Reason       string         // 禁止开仓的原因
+	Event        *BlackoutEvent // 所在的停牌事件（时段外时为nil）
+	Flatten      bool           // 需要在事件前平掉所有持仓（每个事件只执行一次）
+}
+
+// TradingScheduler 交易员运行时的交易时段判断（nil表示不限制）
+type TradingScheduler struct {
+	mu        sync.Mutex
+	schedule  *TradingSchedule
+	config    ScheduleConfig
+	calendar  []BlackoutEvent
+	flattened map[string]bool // 已执行过事件前平仓的事件
+}
+
+// NewTradingScheduler 创建交易时段判断器（schedule为nil时不限制开仓）
+func NewTradingScheduler(cfg ScheduleConfig, schedule *TradingSchedule) *TradingScheduler {
+	s := &TradingScheduler{config: cfg, flattened: make(map[string]bool)}
+	s.SetSchedule(schedule)
+	return s
+}
+
+// SetSchedule 更新交易时段配置（启用日历时重新读取日历文件）
+func (s *TradingScheduler) SetSchedule(schedule *TradingSchedule) {
+	var calendar []BlackoutEvent
+	if schedule != nil && schedule.UseCalendar && s.config.CalendarFile != "" {
+		events, err := LoadBlackoutCalendar(s.config.CalendarFile)
+		if err != nil {
+			log.Printf("⚠️ %v（仅使用交易员自定义的停牌事件）", err)
+		} else {
+			calendar = events
+			log.Printf("📅 已加载停牌日历 %s: %d 个事件", s.config.CalendarFile, len(events))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedule = schedule
+	s.calendar = calendar
+}
+
+// Status 判断该时刻是否允许开新仓（停牌事件优先于时段）
+func (s *TradingScheduler) Status(now time.Time) ScheduleStatus {
+	if s == nil {
+		return ScheduleStatus{AllowEntries: true}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schedule == nil {
+		return ScheduleStatus{AllowEntries: true}
+	}
+
+	if event, ok := s.activeEvent(now); ok {
+		return ScheduleStatus{
+			Reason:  fmt.Sprintf("停牌事件 %s（%s）前后禁止开新仓", event.Name, event.Time.In(s.schedule.location).Format("01-02 15:04 MST")),
+			Event:   &event,
+			Flatten: s.schedule.FlattenBeforeEvent && now.Before(event.Time) && !s.flattened[event.key()],
+		}
+	}
+	if !s.schedule.inWindow(now) {
+		return ScheduleStatus{Reason: fmt.Sprintf("不在交易时段内（%s %s）", strings.Join(s.schedule.Windows, ", "), s.schedule.location)}
+	}
+	return ScheduleStatus{AllowEntries: true}
+}
+
+// activeEvent 该时刻所在禁止开仓期的停牌事件（多个时取最早的）
+func (s *TradingScheduler) activeEvent(now time.Time) (BlackoutEvent, bool) {
+	var active BlackoutEvent
+	found := false
+	for _, events := range [][]BlackoutEvent{s.schedule.Blackouts, s.calendar} {
+		for _, e := range events {
+			before := firstPositive(e.BeforeMinutes, s.schedule.BeforeMinutes, s.config.BeforeMinutes)
+			after := firstPositive(e.AfterMinutes, s.schedule.AfterMinutes, s.config.AfterMinutes)
+			start := e.Time.Add(-time.Duration(before) * time.Minute)
+			end := e.Time.Add(time.Duration(after) * time.Minute)
+			if now.Before(start) || !now.Before(end) {
+				continue
+			}
+			if !found || e.Time.Before(active.Time) {
+				active, found = e, true
+			}
+		}
+	}
+	return active, found
+}
+
+// MarkFlattened 记录事件前平仓已执行（同一事件不再重复平仓）
+func (s *TradingScheduler) MarkFlattened(event BlackoutEvent) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flattened[event.key()] = true
+}
+
+// NextEvents 即将到来的停牌事件（用于状态展示）
+func (s *TradingScheduler) NextEvents(now time.Time, limit int) []BlackoutEvent {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schedule == nil {
+		return nil
+	}
+	var upcoming []BlackoutEvent
+	for _, events := range [][]BlackoutEvent{s.schedule.Blackouts, s.calendar} {
+		for _, e := range events {
+			if e.Time.After(now) {
+				upcoming = append(upcoming, e)
+			}
+		}
+	}
+	sort.Slice(upcoming, func(i, j int) bool { return upcoming[i].Time.Before(upcoming[j].Time) })
+	if len(upcoming) > limit {
+		upcoming = upcoming[:limit]
+	}
+	return upcoming
+}
+
+// firstPositive 返回第一个大于0的值（都不大于0时返回最后一个）
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return values[len(values)-1]
+}
+
+// flattenBeforeEvent 停牌事件前平掉所有持仓（全部平仓成功后该事件不再重复平仓）
+func (at *AutoTrader) flattenBeforeEvent(event BlackoutEvent, ctx *decision.Context, record *logger.DecisionRecord) {
+	log.Printf("🌙 [%s] 停牌事件 %s 前平掉所有持仓 (%d 个)", at.name, event.Name, len(ctx.Positions))
+	allClosed := true
+	for _, pos := range ctx.Positions {
+		action := "close_" + pos.Side
+		actionRecord := logger.DecisionAction{
+			Action:    action,
+			Symbol:    pos.Symbol,
+			Quantity:  pos.Quantity,
+			Leverage:  float64(pos.Leverage),
+			Price:     pos.MarkPrice,
+			Timestamp: time.Now(),
+		}
+		at.lifecycle.MarkClosing(pos.Symbol, pos.Side, "blackout")
+		var order *Order
+		var err error
+		if pos.Side == "long" {
+			order, err = at.orderManager.CloseLong(pos.Symbol, 0)
+		} else {
+			order, err = at.orderManager.CloseShort(pos.Symbol, 0)
+		}
+		if err != nil {
+			allClosed = false
+			log.Printf("❌ [%s] 停牌前平仓失败 (%s %s): %v", at.name, pos.Symbol, action, err)
+			actionRecord.Error = err.Error()
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 停牌前平仓失败: %v", pos.Symbol, action, err))
+		} else {
+			recordOrder(&actionRecord, order)
+			actionRecord.Success = true
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🌙 %s %s 停牌事件 %s 前平仓", pos.Symbol, action, event.Name))
+		}
+		record.Decisions = append(record.Decisions, actionRecord)
+	}
+	if allClosed {
+		at.scheduler.MarkFlattened(event)
+	}
+}
@@ -0,0 +1,161 @@
+package trader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nofx/decision"
+	"nofx/logger"
+)
+
+func TestParseTradingSchedule(t *testing.T) {
+	schedule, err := ParseTradingSchedule(`{"timezone":"America/New_York","windows":["mon-fri 09:30-16:00","sun 22:00-02:00"]}`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	cases := []struct {
+		time time.Time
+		want bool
+	}{
+		{time.Date(2026, 1, 14, 10, 0, 0, 0, ny), true},       // 周三盘中
+		{time.Date(2026, 1, 14, 16, 0, 0, 0, ny), false},      // 结束时间不含
+		{time.Date(2026, 1, 17, 10, 0, 0, 0, ny), false},      // 周六
+		{time.Date(2026, 1, 18, 23, 0, 0, 0, ny), true},       // 周日夜盘
+		{time.Date(2026, 1, 19, 1, 30, 0, 0, ny), true},       // 跨午夜延续到周一
+		{time.Date(2026, 1, 19, 2, 30, 0, 0, ny), false},      // 夜盘结束、日盘未开
+		{time.Date(2026, 1, 14, 15, 0, 0, 0, time.UTC), true}, // UTC 15:00 = 纽约 10:00
+	}
+	for _, c := range cases {
+		if got := schedule.inWindow(c.time); got != c.want {
+			t.Errorf("%s: inWindow=%v, want %v", c.time, got, c.want)
+		}
+	}
+
+	if s, err := ParseTradingSchedule(""); s != nil || err != nil {
+		t.Errorf("空配置表示不限制: %v %v", s, err)
+	}
+	for _, raw := range []string{
+		`{"timezone":"Mars/Olympus"}`,
+		`{"windows":["weekdays 09:00-17:00"]}`,
+		`{"windows":["mon 09:00"]}`,
+		`{"windows":["mon 25:00-26:00"]}`,
+		`{"blackouts":[{"name":"CPI"}]}`,
+	} {
+		if _, err := ParseTradingSchedule(raw); err == nil {
+			t.Errorf("%s 应校验失败", raw)
+		}
+	}
+}
+
+func TestTradingSchedulerBlackout(t *testing.T) {
+	dir := t.TempDir()
+	calendarFile := filepath.Join(dir, "calendar.json")
+	if err := os.WriteFile(calendarFile, []byte(`{"events":[{"name":"FOMC","time":"2026-01-28T19:00:00Z","after_minutes":60}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := ParseTradingSchedule(`{"use_calendar":true,"flatten_before_event":true,"blackouts":[{"name":"CPI","time":"2026-01-13T13:30:00Z","before_minutes":10}]}`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	cfg := DefaultScheduleConfig()
+	cfg.CalendarFile = calendarFile
+	scheduler := NewTradingScheduler(cfg, schedule)
+
+	fomc := time.Date(2026, 1, 28, 19, 0, 0, 0, time.UTC)
+	if status := scheduler.Status(fomc.Add(-31 * time.Minute)); !status.AllowEntries {
+		t.Errorf("事件前30分钟之外应允许开仓: %+v", status)
+	}
+	status := scheduler.Status(fomc.Add(-10 * time.Minute))
+	if status.AllowEntries || status.Event == nil || status.Event.Name != "FOMC" || !status.Flatten {
+		t.Fatalf("日历事件前应禁止开仓并平仓: %+v", status)
+	}
+	scheduler.MarkFlattened(*status.Event)
+	if status := scheduler.Status(fomc.Add(-5 * time.Minute)); status.AllowEntries || status.Flatten {
+		t.Errorf("已平仓的事件不再重复平仓: %+v", status)
+	}
+	if status := scheduler.Status(fomc.Add(59 * time.Minute)); status.AllowEntries || status.Flatten {
+		t.Errorf("事件后使用事件自身的60分钟: %+v", status)
+	}
+	if status := scheduler.Status(fomc.Add(time.Hour)); !status.AllowEntries {
+		t.Errorf("事件结束后应恢复开仓: %+v", status)
+	}
+
+	// 交易员自定义事件覆盖默认的事件前分钟数
+	cpi := time.Date(2026, 1, 13, 13, 30, 0, 0, time.UTC)
+	if status := scheduler.Status(cpi.Add(-15 * time.Minute)); !status.AllowEntries {
+		t.Errorf("CPI事件前10分钟之外应允许开仓: %+v", status)
+	}
+	if status := scheduler.Status(cpi.Add(20 * time.Minute)); status.AllowEntries || status.Event.Name != "CPI" {
+		t.Errorf("CPI事件后使用默认30分钟: %+v", status)
+	}
+
+	if events := scheduler.NextEvents(cpi.Add(time.Hour), 3); len(events) != 1 || events[0].Name != "FOMC" {
+		t.Errorf("即将到来的事件: %+v", events)
+	}
+
+	// 未配置时段时不限制
+	var none *TradingScheduler
+	if !none.Status(fomc).AllowEntries || !NewTradingScheduler(cfg, nil).Status(fomc).AllowEntries {
+		t.Error("未配置交易时段时应允许开仓")
+	}
+}
+
+func TestBlackoutCalendarFile(t *testing.T) {
+	events, err := LoadBlackoutCalendar("../calendar/blackout_events.json")
+	if err != nil {
+		t.Fatalf("默认停牌日历应可解析: %v", err)
+	}
+	if len(events) == 0 {
+		t.Fatal("默认停牌日历不应为空")
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Time.Before(events[i-1].Time) {
+			t.Fatalf("事件应按时间排序: %+v", events)
+		}
+	}
+}
+
+func TestAutoTraderFlattenBeforeEvent(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("BTCUSDT", 100)
+	sim.SetPrice("ETHUSDT", 50)
+	if _, err := sim.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if _, err := sim.OpenShort("ETHUSDT", 2, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+
+	schedule, _ := ParseTradingSchedule(`{"flatten_before_event":true,"blackouts":[{"name":"FOMC","time":"2026-01-28T19:00:00Z"}]}`)
+	at := &AutoTrader{
+		name:         "test",
+		trader:       sim,
+		orderManager: NewOrderManager("t1", "sim", sim, nil),
+		scheduler:    NewTradingScheduler(DefaultScheduleConfig(), schedule),
+	}
+	now := time.Date(2026, 1, 28, 18, 45, 0, 0, time.UTC)
+	status := at.scheduler.Status(now)
+	if !status.Flatten {
+		t.Fatalf("事件前应平仓: %+v", status)
+	}
+
+	ctx := &decision.Context{Positions: []decision.PositionInfo{
+		{Symbol: "BTCUSDT", Side: "long", Quantity: 1, Leverage: 5, MarkPrice: 100},
+		{Symbol: "ETHUSDT", Side: "short", Quantity: 2, Leverage: 5, MarkPrice: 50},
+	}}
+	record := &logger.DecisionRecord{}
+	at.flattenBeforeEvent(*status.Event, ctx, record)
+
+	if positions := mustPositions(t, sim); len(positions) != 0 {
+		t.Errorf("应平掉所有持仓: %+v", positions)
+	}
+	if len(record.Decisions) != 2 || !record.Decisions[0].Success || record.Decisions[1].Action != "close_short" {
+		t.Errorf("决策记录应包含平仓动作: %+v", record.Decisions)
+	}
+	if status := at.scheduler.Status(now); status.AllowEntries || status.Flatten {
+		t.Errorf("平仓完成后仍禁止开仓但不再重复平仓: %+v", status)
+	}
+}
@@ -0,0 +1,278 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/database"
+)
+
+// TrailingStopConfig 跟踪止损配置（百分比均为价格变动，不含杠杆）
+type TrailingStopConfig struct {
+	Enabled             bool
+	TrailPct            float64 // 止损距峰值价的百分比
+	ATRMultiple         float64 // >0时改用ATR倍数作为跟踪距离（无ATR数据时回退到TrailPct）
+	ActivationPct       float64 // 峰值盈利达到该百分比后开始跟踪
+	BreakEvenTriggerPct float64 // 峰值盈利达到该百分比后止损移至保本（<=0表示不启用）
+	BreakEvenOffsetPct  float64 // 保本止损相对开仓价的偏移
+	MinMovePct          float64 // 新止损与当前止损相差小于该百分比（相对开仓价）时不更新
+}
+
+// DefaultTrailingStopConfig 默认跟踪止损配置
+func DefaultTrailingStopConfig() TrailingStopConfig {
+	return TrailingStopConfig{
+		Enabled:             true,
+		TrailPct:            3,
+		ActivationPct:       2,
+		BreakEvenTriggerPct: 1,
+		BreakEvenOffsetPct:  0.1,
+		MinMovePct:          0.2,
+	}
+}
+
+// LoadTrailingStopConfig 从系统配置加载跟踪止损配置
+func LoadTrailingStopConfig(db *config.Database) TrailingStopConfig {
+	cfg := DefaultTrailingStopConfig()
+	if db == nil {
+		return cfg
+	}
+
+	get := db.SystemConfigValue
+	if value := get("trailing_stop_enabled"); value != "" {
+		cfg.Enabled = value == "true"
+	}
+	parse := func(key string, dst *float64) {
+		if v, err := strconv.ParseFloat(get(key), 64); err == nil && v >= 0 {
+			*dst = v
+		}
+	}
+	parse("trailing_stop_pct", &cfg.TrailPct)
+	parse("trailing_stop_atr_multiple", &cfg.ATRMultiple)
+	parse("trailing_activation_pct", &cfg.ActivationPct)
+	parse("break_even_trigger_pct", &cfg.BreakEvenTriggerPct)
+	parse("break_even_offset_pct", &cfg.BreakEvenOffsetPct)
+	parse("trailing_min_move_pct", &cfg.MinMovePct)
+	return cfg
+}
+
+// PositionPeakStore 持仓峰值存储（*config.Database 实现该接口）
+type PositionPeakStore interface {
+	SavePositionPeak(peak database.PositionPeak) error
+	GetPositionPeaks(traderID string) ([]database.PositionPeak, error)
+	DeletePositionPeak(traderID, symbol, side string) error
+}
+
+// StopAdjustment 需要提交到交易所的止损调整
+type StopAdjustment struct {
+	Symbol    string
+	Side      string // long / short
+	OldStop   float64
+	NewStop   float64
+	PeakPrice float64
+	Reason    string // break_even / trailing
+}
+
+// TrailingStopManager 跟踪止损管理器
+// 按持仓记录最有利价格（高水位），止损只向有利方向移动，状态持久化后重启可恢复
+type TrailingStopManager struct {
+	mu       sync.Mutex
+	traderID string
+	config   TrailingStopConfig
+	store    PositionPeakStore
+	peaks    map[string]*database.PositionPeak // key: symbol_side
+	now      func() time.Time
+}
+
+// NewTrailingStopManager 创建跟踪止损管理器（store为nil时仅在内存中跟踪）
+func NewTrailingStopManager(traderID string, cfg TrailingStopConfig, store PositionPeakStore) *TrailingStopManager {
+	return &TrailingStopManager{
+		traderID: traderID,
+		config:   cfg,
+		store:    store,
+		peaks:    make(map[string]*database.PositionPeak),
+		now:      time.Now,
+	}
+}
+
+// Load 从存储恢复持仓峰值
+func (m *TrailingStopManager) Load() error {
+	if m.store == nil {
+		return nil
+	}
+	peaks, err := m.store.GetPositionPeaks(m.traderID)
+	if err != nil {
+		return fmt.Errorf("恢复持仓峰值失败: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range peaks {
+		peak := peaks[i]
+		m.peaks[peak.Symbol+"_"+peak.Side] = &peak
+	}
+	if len(peaks) > 0 {
+		log.Printf("📈 [%s] 已恢复 %d 个持仓的跟踪止损状态", m.traderID, len(peaks))
+	}
+	return nil
+}
+
+// Config 当前配置
+func (m *TrailingStopManager) Config() TrailingStopConfig {
+	return m.config
+}
+
+// Update 用最新持仓更新峰值并计算止损
+// currentStop 为交易所当前的止损价（未知时为0），每次都以其为准：强平保护等其他模块可能已挂了更紧的止损
+// currentTP 为交易所当前的止盈价（未知时为0），仅在首次跟踪该持仓时使用
+// atr 为ATR数据（无数据时为0）；返回需要提交的止损调整，止损无需变动时返回false
+func (m *TrailingStopManager) Update(pos Position, currentStop, currentTP, atr float64) (StopAdjustment, bool) {
+	if !m.config.Enabled || pos.EntryPrice <= 0 || pos.MarkPrice <= 0 {
+		return StopAdjustment{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := pos.Symbol + "_" + pos.Side
+	peak, ok := m.peaks[key]
+	dirty := false
+	if !ok || math.Abs(peak.EntryPrice-pos.EntryPrice)/pos.EntryPrice > 1e-6 {
+		// 新持仓（或开仓均价变化）：从开仓价开始跟踪
+		peak = &database.PositionPeak{
+			TraderID:   m.traderID,
+			Symbol:     pos.Symbol,
+			Side:       pos.Side,
+			EntryPrice: pos.EntryPrice,
+			PeakPrice:  pos.EntryPrice,
+			StopLoss:   currentStop,
+			TakeProfit: currentTP,
+		}
+		m.peaks[key] = peak
+		dirty = true
+	} else if currentStop > 0 && currentStop != peak.StopLoss {
+		peak.StopLoss = currentStop
+		dirty = true
+	}
+
+	sign := 1.0
+	if pos.Side == "short" {
+		sign = -1.0
+	}
+	// better 价格a是否比b对持仓更有利
+	better := func(a, b float64) bool { return sign*(a-b) > 0 }
+
+	if better(pos.MarkPrice, peak.PeakPrice) {
+		peak.PeakPrice = pos.MarkPrice
+		dirty = true
+	}
+	if dirty {
+		m.saveLocked(peak)
+	}
+
+	peakProfitPct := sign * (peak.PeakPrice - peak.EntryPrice) / peak.EntryPrice * 100
+
+	target, reason := 0.0, ""
+	if m.config.BreakEvenTriggerPct > 0 && peakProfitPct >= m.config.BreakEvenTriggerPct {
+		target, reason = peak.EntryPrice*(1+sign*m.config.BreakEvenOffsetPct/100), "break_even"
+	}
+	if peakProfitPct >= m.config.ActivationPct {
+		distance := peak.PeakPrice * m.config.TrailPct / 100
+		if m.config.ATRMultiple > 0 && atr > 0 {
+			distance = atr * m.config.ATRMultiple
+		}
+		if trail := peak.PeakPrice - sign*distance; distance > 0 && (target == 0 || better(trail, target)) {
+			target, reason = trail, "trailing"
+		}
+	}
+	if target <= 0 {
+		return StopAdjustment{}, false
+	}
+
+	// 止损只向有利方向移动，且不能越过当前价格（否则会立即触发）
+	if peak.StopLoss > 0 && !better(target, peak.StopLoss) {
+		return StopAdjustment{}, false
+	}
+	if !better(pos.MarkPrice, target) {
+		return StopAdjustment{}, false
+	}
+	if peak.StopLoss > 0 && math.Abs(target-peak.StopLoss)/peak.EntryPrice*100 < m.config.MinMovePct {
+		return StopAdjustment{}, false
+	}
+
+	return StopAdjustment{
+		Symbol:    pos.Symbol,
+		Side:      pos.Side,
+		OldStop:   peak.StopLoss,
+		NewStop:   target,
+		PeakPrice: peak.PeakPrice,
+		Reason:    reason,
+	}, true
+}
+
+// Commit 交易所止损止盈更新成功后记录新的价格
+func (m *TrailingStopManager) Commit(adj StopAdjustment, takeProfit float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	peak, ok := m.peaks[adj.Symbol+"_"+adj.Side]
+	if !ok {
+		return
+	}
+	peak.StopLoss = adj.NewStop
+	if takeProfit > 0 {
+		peak.TakeProfit = takeProfit
+	}
+	if adj.Reason == "break_even" {
+		peak.BreakEven = true
+	}
+	m.saveLocked(peak)
+}
+
+// TakeProfit 已记录的止盈价（未知时为0）
+func (m *TrailingStopManager) TakeProfit(symbol, side string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if peak, ok := m.peaks[symbol+"_"+side]; ok {
+		return peak.TakeProfit
+	}
+	return 0
+}
+
+// Prune 清理已平仓持仓的峰值记录
+func (m *TrailingStopManager) Prune(positions []Position) {
+	open := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		open[pos.Symbol+"_"+pos.Side] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, peak := range m.peaks {
+		if open[key] {
+			continue
+		}
+		delete(m.peaks, key)
+		if m.store != nil {
+			if err := m.store.DeletePositionPeak(m.traderID, peak.Symbol, peak.Side); err != nil {
+				log.Printf("⚠️ [%s] %v", m.traderID, err)
+			}
+		}
+	}
+}
+
+// saveLocked 持久化持仓峰值（调用方需持有锁）
+func (m *TrailingStopManager) saveLocked(peak *database.PositionPeak) {
+	peak.UpdatedAt = m.now()
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SavePositionPeak(*peak); err != nil {
+		log.Printf("⚠️ [%s] %v", m.traderID, err)
+	}
+}
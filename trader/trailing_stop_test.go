@@ -0,0 +1,234 @@
+package trader
+
+import (
+	"testing"
+
+	"nofx/database"
+	"nofx/logger"
+)
+
+// memPositionPeakStore 内存持仓峰值存储
+type memPositionPeakStore struct {
+	peaks map[string]database.PositionPeak
+}
+
+func newMemPositionPeakStore() *memPositionPeakStore {
+	return &memPositionPeakStore{peaks: make(map[string]database.PositionPeak)}
+}
+
+func (s *memPositionPeakStore) SavePositionPeak(peak database.PositionPeak) error {
+	s.peaks[peak.TraderID+"/"+peak.Symbol+"_"+peak.Side] = peak
+	return nil
+}
+
+func (s *memPositionPeakStore) GetPositionPeaks(traderID string) ([]database.PositionPeak, error) {
+	var peaks []database.PositionPeak
+	for _, peak := range s.peaks {
+		if peak.TraderID == traderID {
+			peaks = append(peaks, peak)
+		}
+	}
+	return peaks, nil
+}
+
+func (s *memPositionPeakStore) DeletePositionPeak(traderID, symbol, side string) error {
+	delete(s.peaks, traderID+"/"+symbol+"_"+side)
+	return nil
+}
+
+func TestTrailingStopLong(t *testing.T) {
+	m := NewTrailingStopManager("t1", DefaultTrailingStopConfig(), nil)
+	pos := Position{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, MarkPrice: 100.5}
+
+	if _, ok := m.Update(pos, 95, 110, 0); ok {
+		t.Fatal("盈利未达到保本阈值时不应调整止损")
+	}
+
+	// 峰值盈利1.2% → 止损移至保本（开仓价+0.1%）
+	pos.MarkPrice = 101.2
+	adj, ok := m.Update(pos, 0, 0, 0)
+	if !ok || adj.Reason != "break_even" || !approxEqual(adj.NewStop, 100.1) || adj.OldStop != 95 {
+		t.Fatalf("应移动到保本止损: %+v", adj)
+	}
+	m.Commit(adj, 0)
+	if _, ok := m.Update(pos, 0, 0, 0); ok {
+		t.Error("止损未变化时不应重复更新")
+	}
+
+	// 峰值盈利4% → 跟踪止损 = 104 × 97%
+	pos.MarkPrice = 104
+	adj, ok = m.Update(pos, 0, 0, 0)
+	if !ok || adj.Reason != "trailing" || !approxEqual(adj.NewStop, 100.88) {
+		t.Fatalf("应按峰值跟踪止损: %+v", adj)
+	}
+	m.Commit(adj, 0)
+
+	// 回调时止损不下移
+	pos.MarkPrice = 102
+	if _, ok := m.Update(pos, 0, 0, 0); ok {
+		t.Error("价格回调时止损不应下移")
+	}
+
+	// 新峰值只高出一点，止损变化小于最小调整幅度
+	pos.MarkPrice = 104.1
+	if _, ok := m.Update(pos, 0, 0, 0); ok {
+		t.Error("止损变化小于最小调整幅度时不应更新交易所委托")
+	}
+	if tp := m.TakeProfit("BTCUSDT", "long"); tp != 110 {
+		t.Errorf("应保留首次跟踪时的止盈价, got %v", tp)
+	}
+}
+
+func TestTrailingStopShortATR(t *testing.T) {
+	cfg := DefaultTrailingStopConfig()
+	cfg.ATRMultiple = 2
+	m := NewTrailingStopManager("t1", cfg, nil)
+
+	// 空仓峰值为最低价，跟踪距离为2倍ATR
+	pos := Position{Symbol: "ETHUSDT", Side: "short", EntryPrice: 100, MarkPrice: 95}
+	adj, ok := m.Update(pos, 103, 0, 1)
+	if !ok || adj.Reason != "trailing" || !approxEqual(adj.NewStop, 97) || !approxEqual(adj.PeakPrice, 95) {
+		t.Fatalf("空仓应按ATR跟踪止损: %+v", adj)
+	}
+
+	// ATR跟踪距离过大时，保本止损更优
+	adj, ok = m.Update(pos, 103, 0, 4)
+	if !ok || adj.Reason != "break_even" || !approxEqual(adj.NewStop, 99.9) {
+		t.Fatalf("跟踪止损不如保本时应使用保本止损: %+v", adj)
+	}
+
+	// 止损不能越过当前价格
+	cfg.ATRMultiple = 0
+	cfg.TrailPct = 0
+	cfg.BreakEvenOffsetPct = -6
+	m = NewTrailingStopManager("t1", cfg, nil)
+	if adj, ok := m.Update(pos, 103, 0, 0); ok {
+		t.Errorf("会立即触发的止损不应提交: %+v", adj)
+	}
+}
+
+func TestTrailingStopPersistence(t *testing.T) {
+	store := newMemPositionPeakStore()
+	m := NewTrailingStopManager("t1", DefaultTrailingStopConfig(), store)
+	pos := Position{Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, MarkPrice: 105}
+	adj, ok := m.Update(pos, 95, 0, 0)
+	if !ok {
+		t.Fatal("应调整止损")
+	}
+	m.Commit(adj, 0)
+
+	// 重启后从高水位继续跟踪：价格回落不会降低止损
+	restored := NewTrailingStopManager("t1", DefaultTrailingStopConfig(), store)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	pos.MarkPrice = 103
+	if _, ok := restored.Update(pos, 0, 0, 0); ok {
+		t.Error("重启后应按恢复的峰值和止损判断，不应调整")
+	}
+	if peak := store.peaks["t1/BTCUSDT_long"]; peak.PeakPrice != 105 || !approxEqual(peak.StopLoss, 101.85) {
+		t.Errorf("应持久化峰值和止损: %+v", peak)
+	}
+
+	// 开仓均价变化视为新持仓，重新跟踪
+	pos.EntryPrice = 103
+	if _, ok := restored.Update(pos, 0, 0, 0); ok {
+		t.Error("新持仓未盈利时不应调整止损")
+	}
+	if peak := store.peaks["t1/BTCUSDT_long"]; peak.PeakPrice != 103 {
+		t.Errorf("新持仓应从开仓价开始跟踪: %+v", peak)
+	}
+
+	restored.Prune(nil)
+	if len(store.peaks) != 0 {
+		t.Errorf("平仓后应删除峰值记录: %+v", store.peaks)
+	}
+}
+
+// countingStopTrader 统计止损单提交次数的模拟交易所
+type countingStopTrader struct {
+	*SimulatedTrader
+	stopCalls int
+}
+
+func (t *countingStopTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	t.stopCalls++
+	return t.SimulatedTrader.SetStopLoss(symbol, positionSide, quantity, stopPrice)
+}
+
+func TestAutoTraderTrailingStopOrders(t *testing.T) {
+	sim := &countingStopTrader{SimulatedTrader: newTestSimulatedTrader()}
+	sim.SetPrice("BTCUSDT", 100)
+	if _, err := sim.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := sim.SetStopLoss("BTCUSDT", "LONG", 1, 95); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	if err := sim.SetTakeProfit("BTCUSDT", "LONG", 1, 120); err != nil {
+		t.Fatalf("设置止盈失败: %v", err)
+	}
+	sim.stopCalls = 0
+
+	at := &AutoTrader{
+		id:            "t1",
+		name:          "test",
+		trader:        sim,
+		orderManager:  NewOrderManager("t1", "sim", sim, nil),
+		trailingStops: NewTrailingStopManager("t1", DefaultTrailingStopConfig(), nil),
+	}
+
+	for _, price := range []float64{100.5, 104, 103, 104.05} {
+		sim.SetPrice("BTCUSDT", price)
+		if err := at.checkAndUpdateStopOrders(); err != nil {
+			t.Fatalf("更新止盈止损失败: %v", err)
+		}
+	}
+	if sim.stopCalls != 1 {
+		t.Errorf("只有止损价变化时才应提交止损单, got %d次", sim.stopCalls)
+	}
+
+	positions, _ := sim.GetPositions()
+	if len(positions) != 1 || !approxEqual(positions[0].StopLoss, 100.88) || positions[0].TakeProfit != 120 {
+		t.Fatalf("应替换为跟踪止损并保留原止盈: %+v", positions)
+	}
+}
+
+func TestAutoTraderTrailingStopKeepsGuardStop(t *testing.T) {
+	sim := newTestSimulatedTrader()
+	sim.SetPrice("BTCUSDT", 100)
+	if _, err := sim.OpenLong("BTCUSDT", 1, 5); err != nil {
+		t.Fatalf("开仓失败: %v", err)
+	}
+	if err := sim.SetTakeProfit("BTCUSDT", "LONG", 1, 120); err != nil {
+		t.Fatalf("设置止盈失败: %v", err)
+	}
+	at := &AutoTrader{
+		id:            "t1",
+		name:          "test",
+		trader:        sim,
+		orderManager:  NewOrderManager("t1", "sim", sim, nil),
+		trailingStops: NewTrailingStopManager("t1", DefaultTrailingStopConfig(), nil),
+	}
+
+	// 保本止损 100.1
+	sim.SetPrice("BTCUSDT", 101.2)
+	if err := at.checkAndUpdateStopOrders(); err != nil {
+		t.Fatalf("更新止盈止损失败: %v", err)
+	}
+
+	// 强平保护挂了更紧的止损（不经过跟踪止损管理器）
+	sim.SetPrice("BTCUSDT", 103)
+	positions, _ := sim.GetPositions()
+	at.protectFromLiquidation(positions[0], positions[0].Quantity, 102.5, &logger.DecisionRecord{})
+
+	// 峰值104时跟踪止损为100.88，比交易所当前止损宽松，不应替换
+	sim.SetPrice("BTCUSDT", 104)
+	if err := at.checkAndUpdateStopOrders(); err != nil {
+		t.Fatalf("更新止盈止损失败: %v", err)
+	}
+	positions, _ = sim.GetPositions()
+	if len(positions) != 1 || positions[0].StopLoss != 102.5 {
+		t.Fatalf("强平保护止损不应被更宽松的跟踪止损替换: %+v", positions)
+	}
+}
@@ -0,0 +1,572 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/pool"
+	"nofx/service/news"
+)
+
+// 决策周期触发类型
+const (
+	TriggerStartup     = "startup"      // 启动后首次执行
+	TriggerScheduled   = "scheduled"    // ScanInterval定时触发
+	TriggerPriceMove   = "price_move"   // 持仓币种价格大幅波动
+	TriggerFundingFlip = "funding_flip" // 持仓币种资金费率方向反转
+	TriggerOISpike     = "oi_spike"     // 持仓量异常增长
+	TriggerNews        = "news"         // 高影响力新闻
+	TriggerLiquidation = "liquidation"  // 持仓接近强平价（强平距离保护已处置）
+)
+
+// TriggerConfig 事件驱动触发器配置
+type TriggerConfig struct {
+	Enabled      bool
+	PriceMovePct float64       // 持仓币种价格相对上次决策变动超过该百分比时触发（0表示关闭）
+	FundingFlip  bool          // 持仓币种资金费率正负反转时触发
+	OISpikePct   float64       // OI Top中持仓量增长超过该百分比时触发（0表示关闭）
+	NewsKeywords []string      // 新闻标题/摘要包含任一关键词时触发（为空表示关闭）
+	MinInterval  time.Duration // 两次决策周期的最小间隔（限流）
+	Debounce     time.Duration // 事件合并窗口：最后一个事件之后静默该时长才触发
+	PollInterval time.Duration // 资金费率/OI轮询间隔
+	UserID       string        // 交易员所属用户（用户自建新闻源的文章只触发该用户的交易员）
+}
+
+// TriggerSettings 交易员单独的触发器设置（JSON，未填写的字段沿用系统配置）
+type TriggerSettings struct {
+	Enabled            *bool    `json:"enabled,omitempty"`
+	PriceMovePct       *float64 `json:"price_move_pct,omitempty"`
+	FundingFlip        *bool    `json:"funding_flip,omitempty"`
+	OISpikePct         *float64 `json:"oi_spike_pct,omitempty"`
+	NewsKeywords       []string `json:"news_keywords,omitempty"`
+	MinIntervalMinutes *int     `json:"min_interval_minutes,omitempty"`
+	DebounceSeconds    *int     `json:"debounce_seconds,omitempty"`
+}
+
+// ParseTriggerSettings 解析交易员的触发器设置（为空表示全部沿用系统配置）
+func ParseTriggerSettings(raw string) (*TriggerSettings, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var s TriggerSettings
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, fmt.Errorf("触发器设置格式错误: %w", err)
+	}
+	if s.PriceMovePct != nil && *s.PriceMovePct < 0 {
+		return nil, fmt.Errorf("价格波动阈值不能为负数")
+	}
+	if s.OISpikePct != nil && *s.OISpikePct < 0 {
+		return nil, fmt.Errorf("OI增长阈值不能为负数")
+	}
+	if s.MinIntervalMinutes != nil && *s.MinIntervalMinutes <= 0 {
+		return nil, fmt.Errorf("最小决策间隔必须大于0分钟")
+	}
+	if s.DebounceSeconds != nil && *s.DebounceSeconds < 0 {
+		return nil, fmt.Errorf("防抖窗口不能为负数")
+	}
+	return &s, nil
+}
+
+// apply 用交易员设置覆盖系统配置
+func (s *TriggerSettings) apply(cfg TriggerConfig) TriggerConfig {
+	if s == nil {
+		return cfg
+	}
+	if s.Enabled != nil {
+		cfg.Enabled = *s.Enabled
+	}
+	if s.PriceMovePct != nil {
+		cfg.PriceMovePct = *s.PriceMovePct
+	}
+	if s.FundingFlip != nil {
+		cfg.FundingFlip = *s.FundingFlip
+	}
+	if s.OISpikePct != nil {
+		cfg.OISpikePct = *s.OISpikePct
+	}
+	if s.NewsKeywords != nil {
+		cfg.NewsKeywords = nil
+		for _, kw := range s.NewsKeywords {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				cfg.NewsKeywords = append(cfg.NewsKeywords, kw)
+			}
+		}
+	}
+	if s.MinIntervalMinutes != nil {
+		cfg.MinInterval = time.Duration(*s.MinIntervalMinutes) * time.Minute
+	}
+	if s.DebounceSeconds != nil {
+		cfg.Debounce = time.Duration(*s.DebounceSeconds) * time.Second
+	}
+	return cfg
+}
+
+// LoadTriggerConfig 从系统配置加载触发器配置，settings为交易员的触发器设置（JSON，为空表示沿用系统配置）
+func LoadTriggerConfig(db *config.Database, settings string) TriggerConfig {
+	cfg := TriggerConfig{
+		MinInterval:  5 * time.Minute,
+		Debounce:     30 * time.Second,
+		PollInterval: 5 * time.Minute,
+	}
+	overrides, err := ParseTriggerSettings(settings)
+	if err != nil {
+		log.Printf("⚠️ %v，使用系统触发器配置", err)
+	}
+
+	get := db.SystemConfigValue
+	cfg.Enabled = get("trigger_enabled") == "true"
+	cfg.PriceMovePct, _ = strconv.ParseFloat(get("trigger_price_move_pct"), 64)
+	cfg.FundingFlip = get("trigger_funding_flip") == "true"
+	cfg.OISpikePct, _ = strconv.ParseFloat(get("trigger_oi_spike_pct"), 64)
+	for _, kw := range strings.Split(get("trigger_news_keywords"), ",") {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			cfg.NewsKeywords = append(cfg.NewsKeywords, kw)
+		}
+	}
+	if minutes, err := strconv.Atoi(get("trigger_min_interval_minutes")); err == nil && minutes > 0 {
+		cfg.MinInterval = time.Duration(minutes) * time.Minute
+	}
+	if seconds, err := strconv.Atoi(get("trigger_debounce_seconds")); err == nil && seconds >= 0 {
+		cfg.Debounce = time.Duration(seconds) * time.Second
+	}
+	return overrides.apply(cfg)
+}
+
+// TriggerEvent 触发决策周期的事件
+type TriggerEvent struct {
+	Type   string
+	Symbol string
+	Detail string
+	Time   time.Time
+}
+
+// String 事件描述（写入决策记录）
+func (e TriggerEvent) String() string {
+	if e.Symbol == "" {
+		return fmt.Sprintf("%s: %s", e.Type, e.Detail)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Type, e.Symbol, e.Detail)
+}
+
+// triggerGate 触发事件的防抖与限流
+type triggerGate struct {
+	debounce    time.Duration
+	minInterval time.Duration
+	pending     []TriggerEvent
+	lastEvent   time.Time
+	lastCycle   time.Time
+}
+
+// offer 加入事件（同类型同币种的事件只保留最新一条）
+func (g *triggerGate) offer(ev TriggerEvent) {
+	for i, p := range g.pending {
+		if p.Type == ev.Type && p.Symbol == ev.Symbol {
+			g.pending[i] = ev
+			g.lastEvent = ev.Time
+			return
+		}
+	}
+	g.pending = append(g.pending, ev)
+	g.lastEvent = ev.Time
+}
+
+// ready 检查是否可以触发；不能触发时返回还需等待的时长（没有待处理事件时为0）
+func (g *triggerGate) ready(now time.Time) ([]TriggerEvent, time.Duration) {
+	if len(g.pending) == 0 {
+		return nil, 0
+	}
+	wait := g.lastEvent.Add(g.debounce).Sub(now)
+	if rl := g.lastCycle.Add(g.minInterval).Sub(now); rl > wait {
+		wait = rl
+	}
+	if wait > 0 {
+		return nil, wait
+	}
+	events := g.pending
+	g.pending = nil
+	return events, 0
+}
+
+// markCycle 记录一次决策周期（该周期已覆盖之前的待处理事件）
+func (g *triggerGate) markCycle(t time.Time) {
+	g.lastCycle = t
+	g.pending = nil
+}
+
+// TriggerManager 单个交易员的事件驱动触发器
+type TriggerManager struct {
+	name           string
+	cfg            TriggerConfig
+	signalProvider *pool.SignalProvider
+	fundingRate    func(symbol string) (float64, error)
+	clock          func() time.Time
+
+	mu           sync.Mutex
+	gate         triggerGate
+	held         map[string]bool    // 持仓币种（价格/资金费率触发）
+	candidates   map[string]bool    // 交易币种（OI触发，为空时不限制）
+	priceRefs    map[string]float64 // 上次决策后的参考价格
+	fundingRates map[string]float64 // 上次观察到的资金费率
+	oiSeen       map[string]bool    // 已触发过的OI异动（回落到阈值以下后重置）
+
+	wake    chan struct{}
+	fire    chan []TriggerEvent
+	stopCh  chan struct{}
+	running bool
+	cleanup []func()
+}
+
+// NewTriggerManager 创建触发器
+func NewTriggerManager(name string, cfg TriggerConfig, signalProvider *pool.SignalProvider) *TriggerManager {
+	return &TriggerManager{
+		name:           name,
+		cfg:            cfg,
+		signalProvider: signalProvider,
+		fundingRate:    market.GetFundingRate,
+		clock:          time.Now,
+		gate:           triggerGate{debounce: cfg.Debounce, minInterval: cfg.MinInterval},
+		held:           make(map[string]bool),
+		candidates:     make(map[string]bool),
+		priceRefs:      make(map[string]float64),
+		fundingRates:   make(map[string]float64),
+		oiSeen:         make(map[string]bool),
+		wake:           make(chan struct{}, 1),
+		fire:           make(chan []TriggerEvent, 1),
+	}
+}
+
+// C 触发事件通道（每次发送一批合并后的事件）
+func (tm *TriggerManager) C() <-chan []TriggerEvent {
+	return tm.fire
+}
+
+// Start 启动事件监听
+func (tm *TriggerManager) Start() {
+	tm.mu.Lock()
+	if tm.running {
+		tm.mu.Unlock()
+		return
+	}
+	tm.running = true
+	stopCh := make(chan struct{})
+	tm.stopCh = stopCh
+	tm.mu.Unlock()
+
+	// 注册监听时不能持有tm.mu：行情回调会获取tm.mu
+	var cleanup []func()
+	if tm.cfg.PriceMovePct > 0 && market.WSMonitorCli != nil {
+		cleanup = append(cleanup, market.WSMonitorCli.AddKlineListener(tm.onKline))
+	}
+	if len(tm.cfg.NewsKeywords) > 0 {
+		articles, unsubscribe := news.Subscribe(50)
+		cleanup = append(cleanup, unsubscribe)
+		go tm.watchNews(articles)
+	}
+	if tm.cfg.FundingFlip || tm.cfg.OISpikePct > 0 {
+		go tm.pollLoop(stopCh)
+	}
+	go tm.loop(stopCh)
+
+	tm.mu.Lock()
+	tm.cleanup = cleanup
+	tm.mu.Unlock()
+
+	log.Printf("⚡ [%s] 事件触发器已启动 (价格±%.1f%%, 资金费率反转=%v, OI>%.0f%%, 新闻关键词=%d, 限流=%v, 防抖=%v)",
+		tm.name, tm.cfg.PriceMovePct, tm.cfg.FundingFlip, tm.cfg.OISpikePct, len(tm.cfg.NewsKeywords), tm.cfg.MinInterval, tm.cfg.Debounce)
+}
+
+// Stop 停止事件监听（可重复调用）
+func (tm *TriggerManager) Stop() {
+	tm.mu.Lock()
+	if !tm.running {
+		tm.mu.Unlock()
+		return
+	}
+	tm.running = false
+	close(tm.stopCh)
+	cleanup := tm.cleanup
+	tm.cleanup = nil
+	tm.mu.Unlock()
+
+	for _, fn := range cleanup {
+		fn()
+	}
+}
+
+// SetWatchedSymbols 更新关注的币种（每个决策周期后调用）
+func (tm *TriggerManager) SetWatchedSymbols(held, candidates []string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.held = make(map[string]bool, len(held))
+	for _, s := range held {
+		tm.held[s] = true
+	}
+	tm.candidates = make(map[string]bool, len(candidates))
+	for _, s := range candidates {
+		tm.candidates[s] = true
+	}
+	for s := range tm.fundingRates {
+		if !tm.held[s] {
+			delete(tm.fundingRates, s)
+		}
+	}
+}
+
+// MarkCycle 记录一次决策周期：重置价格参考点并清空已被覆盖的待处理事件
+func (tm *TriggerManager) MarkCycle(t time.Time) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.gate.markCycle(t)
+	tm.priceRefs = make(map[string]float64)
+}
+
+// Notify 提交外部事件（如强平预警），按防抖和限流规则提前触发决策周期
+func (tm *TriggerManager) Notify(ev TriggerEvent) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.emit(ev)
+}
+
+// emit 提交事件（调用方需持有锁）
+func (tm *TriggerManager) emit(ev TriggerEvent) {
+	log.Printf("⚡ [%s] 触发事件: %s", tm.name, ev)
+	tm.gate.offer(ev)
+	select {
+	case tm.wake <- struct{}{}:
+	default:
+	}
+}
+
+// onKline K线更新：持仓币种价格相对参考价波动超过阈值时触发
+func (tm *TriggerManager) onKline(symbol, interval string, kline market.Kline) {
+	if interval != "3m" || kline.Close <= 0 {
+		return
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if !tm.held[symbol] {
+		return
+	}
+	ref, ok := tm.priceRefs[symbol]
+	if !ok {
+		tm.priceRefs[symbol] = kline.Close
+		return
+	}
+	changePct := (kline.Close - ref) / ref * 100
+	if math.Abs(changePct) < tm.cfg.PriceMovePct {
+		return
+	}
+	tm.priceRefs[symbol] = kline.Close
+	tm.emit(TriggerEvent{
+		Type:   TriggerPriceMove,
+		Symbol: symbol,
+		Detail: fmt.Sprintf("价格 %.4f → %.4f (%+.2f%%)", ref, kline.Close, changePct),
+		Time:   tm.clock(),
+	})
+}
+
+// checkFunding 检查持仓币种资金费率是否反转
+func (tm *TriggerManager) checkFunding() {
+	tm.mu.Lock()
+	symbols := make([]string, 0, len(tm.held))
+	for s := range tm.held {
+		symbols = append(symbols, s)
+	}
+	tm.mu.Unlock()
+
+	for _, symbol := range symbols {
+		rate, err := tm.fundingRate(symbol)
+		if err != nil {
+			continue
+		}
+
+		tm.mu.Lock()
+		prev, ok := tm.fundingRates[symbol]
+		tm.fundingRates[symbol] = rate
+		if ok && prev*rate < 0 {
+			tm.emit(TriggerEvent{
+				Type:   TriggerFundingFlip,
+				Symbol: symbol,
+				Detail: fmt.Sprintf("资金费率 %.4f%% → %.4f%%", prev*100, rate*100),
+				Time:   tm.clock(),
+			})
+		}
+		tm.mu.Unlock()
+	}
+}
+
+// checkOI 检查OI Top中是否出现持仓量异常增长的关注币种
+func (tm *TriggerManager) checkOI(positions []pool.OIPosition) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, p := range positions {
+		watched := tm.held[p.Symbol] || tm.candidates[p.Symbol] || len(tm.candidates) == 0
+		if !watched {
+			continue
+		}
+		if p.OIDeltaPercent < tm.cfg.OISpikePct {
+			delete(tm.oiSeen, p.Symbol)
+			continue
+		}
+		if tm.oiSeen[p.Symbol] {
+			continue
+		}
+		tm.oiSeen[p.Symbol] = true
+		tm.emit(TriggerEvent{
+			Type:   TriggerOISpike,
+			Symbol: p.Symbol,
+			Detail: fmt.Sprintf("持仓量增长 %.2f%% (价格 %+.2f%%)", p.OIDeltaPercent, p.PriceDeltaPercent),
+			Time:   tm.clock(),
+		})
+	}
+}
+
+// onNews 新闻到达：命中关键词时触发
+func (tm *TriggerManager) onNews(a news.Article) {
+	if a.OwnerID != "" && a.OwnerID != tm.cfg.UserID {
+		return
+	}
+	text := strings.ToLower(a.Headline + " " + a.Summary)
+	for _, kw := range tm.cfg.NewsKeywords {
+		if !strings.Contains(text, strings.ToLower(kw)) {
+			continue
+		}
+		tm.mu.Lock()
+		tm.emit(TriggerEvent{
+			Type:   TriggerNews,
+			Detail: fmt.Sprintf("[%s] %s (关键词: %s)", a.Source, a.Headline, kw),
+			Time:   tm.clock(),
+		})
+		tm.mu.Unlock()
+		return
+	}
+}
+
+// watchNews 消费新闻订阅
+func (tm *TriggerManager) watchNews(articles <-chan news.Article) {
+	for a := range articles {
+		tm.onNews(a)
+	}
+}
+
+// pollLoop 定期轮询资金费率和OI
+func (tm *TriggerManager) pollLoop(stopCh <-chan struct{}) {
+	interval := tm.cfg.PollInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if tm.cfg.FundingFlip {
+				tm.checkFunding()
+			}
+			if tm.cfg.OISpikePct > 0 && tm.signalProvider != nil {
+				if positions, err := tm.signalProvider.GetOITopPositions(); err == nil {
+					tm.checkOI(positions)
+				}
+			}
+		}
+	}
+}
+
+// loop 按防抖和限流规则把待处理事件发送到C()
+func (tm *TriggerManager) loop(stopCh <-chan struct{}) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-tm.wake:
+		case <-timer.C:
+		}
+
+		tm.mu.Lock()
+		events, wait := tm.gate.ready(tm.clock())
+		tm.mu.Unlock()
+
+		if len(events) > 0 {
+			select {
+			case tm.fire <- events:
+			default:
+				// 上一批事件尚未处理，这批事件会被即将执行的周期覆盖
+			}
+		} else if wait > 0 {
+			timer.Reset(wait)
+		}
+	}
+}
+
+// describeTriggers 触发事件摘要（日志用）
+func describeTriggers(events []TriggerEvent) string {
+	parts := make([]string, 0, len(events))
+	for _, ev := range events {
+		parts = append(parts, ev.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// refreshTriggerWatch 按周期结束时的持仓更新触发器关注的币种
+func (at *AutoTrader) refreshTriggerWatch(ctx *decision.Context, actions []logger.DecisionAction) {
+	if at.triggerManager == nil || ctx == nil {
+		return
+	}
+
+	held := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		held[pos.Symbol] = true
+	}
+	for _, a := range actions {
+		if !a.Success {
+			continue
+		}
+		switch a.Action {
+		case "open_long", "open_short":
+			held[a.Symbol] = true
+		case "close_long", "close_short":
+			delete(held, a.Symbol)
+		case "open_pair", "close_pair":
+			for _, leg := range a.Legs {
+				if leg.Error != "" {
+					continue
+				}
+				if a.Action == "open_pair" {
+					held[leg.Symbol] = true
+				} else {
+					delete(held, leg.Symbol)
+				}
+			}
+		}
+	}
+
+	heldSymbols := make([]string, 0, len(held))
+	for symbol := range held {
+		heldSymbols = append(heldSymbols, symbol)
+	}
+	candidates := make([]string, 0, len(ctx.CandidateCoins))
+	for _, coin := range ctx.CandidateCoins {
+		candidates = append(candidates, coin.Symbol)
+	}
+	at.triggerManager.SetWatchedSymbols(heldSymbols, candidates)
+}
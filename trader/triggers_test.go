@@ -0,0 +1,123 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/pool"
+	"nofx/service/news"
+)
+
+func TestTriggerGateDebounceAndRateLimit(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := triggerGate{debounce: 30 * time.Second, minInterval: 5 * time.Minute}
+	g.markCycle(start)
+
+	g.offer(TriggerEvent{Type: TriggerPriceMove, Symbol: "BTCUSDT", Time: start.Add(time.Minute)})
+	g.offer(TriggerEvent{Type: TriggerPriceMove, Symbol: "BTCUSDT", Time: start.Add(time.Minute + 10*time.Second)})
+	g.offer(TriggerEvent{Type: TriggerNews, Time: start.Add(time.Minute + 20*time.Second)})
+
+	// 限流：距上次周期不足5分钟
+	events, wait := g.ready(start.Add(2 * time.Minute))
+	if events != nil || wait != 3*time.Minute {
+		t.Fatalf("限流期内不应触发, wait=%v", wait)
+	}
+
+	// 防抖：最后一个事件之后需静默30秒
+	g.offer(TriggerEvent{Type: TriggerOISpike, Symbol: "ETHUSDT", Time: start.Add(5*time.Minute - 10*time.Second)})
+	if events, wait = g.ready(start.Add(5 * time.Minute)); events != nil || wait != 20*time.Second {
+		t.Fatalf("防抖期内不应触发, wait=%v", wait)
+	}
+
+	events, _ = g.ready(start.Add(5*time.Minute + 20*time.Second))
+	if len(events) != 3 {
+		t.Fatalf("同类型同币种事件应合并, got %d: %v", len(events), events)
+	}
+	if events, _ = g.ready(start.Add(10 * time.Minute)); events != nil {
+		t.Error("事件已发送后不应重复触发")
+	}
+
+	// 定时周期覆盖待处理事件
+	g.offer(TriggerEvent{Type: TriggerNews, Time: start.Add(11 * time.Minute)})
+	g.markCycle(start.Add(11 * time.Minute))
+	if events, _ = g.ready(start.Add(20 * time.Minute)); events != nil {
+		t.Error("已被周期覆盖的事件不应再触发")
+	}
+}
+
+func TestTriggerManagerDetectors(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm := NewTriggerManager("test", TriggerConfig{
+		Enabled:      true,
+		PriceMovePct: 2,
+		FundingFlip:  true,
+		OISpikePct:   20,
+		NewsKeywords: []string{"SEC"},
+		UserID:       "user-1",
+	}, nil)
+	tm.clock = func() time.Time { return now }
+	rates := map[string]float64{"BTCUSDT": 0.0001}
+	tm.fundingRate = func(symbol string) (float64, error) { return rates[symbol], nil }
+	tm.SetWatchedSymbols([]string{"BTCUSDT"}, []string{"BTCUSDT", "SOLUSDT"})
+
+	// 价格：首次记录参考价，波动超过2%触发；未持仓币种忽略
+	tm.onKline("BTCUSDT", "3m", market.Kline{Close: 100})
+	tm.onKline("BTCUSDT", "3m", market.Kline{Close: 101.5})
+	tm.onKline("ETHUSDT", "3m", market.Kline{Close: 1})
+	tm.onKline("ETHUSDT", "3m", market.Kline{Close: 2})
+	tm.onKline("BTCUSDT", "3m", market.Kline{Close: 97.9})
+
+	// 资金费率反转
+	tm.checkFunding()
+	rates["BTCUSDT"] = -0.0002
+	tm.checkFunding()
+
+	// OI：只对关注币种触发一次
+	oi := []pool.OIPosition{{Symbol: "SOLUSDT", OIDeltaPercent: 25}, {Symbol: "DOGEUSDT", OIDeltaPercent: 50}}
+	tm.checkOI(oi)
+	tm.checkOI(oi)
+
+	// 新闻关键词（不区分大小写）；其他用户自建新闻源的文章忽略
+	tm.onNews(news.Article{Headline: "Weekly market recap"})
+	tm.onNews(news.Article{Headline: "SEC sues exchange", OwnerID: "user-2"})
+	tm.onNews(news.Article{Headline: "sec approves spot ETF", Source: "Finnhub"})
+
+	got := make(map[string]int)
+	for _, ev := range tm.gate.pending {
+		got[ev.Type]++
+	}
+	want := map[string]int{TriggerPriceMove: 1, TriggerFundingFlip: 1, TriggerOISpike: 1, TriggerNews: 1}
+	for typ, n := range want {
+		if got[typ] != n {
+			t.Errorf("%s 触发次数 = %d, want %d (%v)", typ, got[typ], n, tm.gate.pending)
+		}
+	}
+	if len(tm.gate.pending) != 4 {
+		t.Errorf("待处理事件数量错误: %v", tm.gate.pending)
+	}
+}
+
+func TestLoadTriggerConfigTraderSettings(t *testing.T) {
+	cfg := LoadTriggerConfig(nil, `{"enabled":true,"price_move_pct":2.5,"news_keywords":[" SEC ",""],"min_interval_minutes":15,"debounce_seconds":0}`)
+	if !cfg.Enabled || cfg.PriceMovePct != 2.5 {
+		t.Fatalf("交易员设置未生效: %+v", cfg)
+	}
+	if len(cfg.NewsKeywords) != 1 || cfg.NewsKeywords[0] != "SEC" {
+		t.Fatalf("新闻关键词 = %v", cfg.NewsKeywords)
+	}
+	if cfg.MinInterval != 15*time.Minute || cfg.Debounce != 0 {
+		t.Fatalf("限流/防抖 = %v/%v", cfg.MinInterval, cfg.Debounce)
+	}
+	if cfg.FundingFlip || cfg.OISpikePct != 0 || cfg.PollInterval != 5*time.Minute {
+		t.Fatalf("未设置的字段应沿用系统配置: %+v", cfg)
+	}
+
+	// 格式错误或取值非法时整体回退到系统配置
+	for _, raw := range []string{`{"enabled":`, `{"enabled":true,"min_interval_minutes":0}`} {
+		cfg = LoadTriggerConfig(nil, raw)
+		if cfg.Enabled || cfg.MinInterval != 5*time.Minute {
+			t.Fatalf("%s: 应回退到系统配置: %+v", raw, cfg)
+		}
+	}
+}